@@ -2,6 +2,7 @@ package api
 
 import (
 	"fmt"
+	"net"
 
 	"github.com/luxdefi/node/api/admin"
 	"github.com/luxdefi/node/api/health"
@@ -41,7 +42,7 @@ type NewAPIClientF func(ipAddr string, port uint16) Client
 
 // NewAPIClient initialize most of node apis
 func NewAPIClient(ipAddr string, port uint16) Client {
-	uri := fmt.Sprintf("http://%s:%d", ipAddr, port)
+	uri := "http://" + net.JoinHostPort(ipAddr, fmt.Sprintf("%d", port))
 	return &APIClient{
 		platform:     platformvm.NewClient(uri),
 		xChain:       avm.NewClient(uri, "X"),
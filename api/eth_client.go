@@ -4,12 +4,15 @@ import (
 	"context"
 	"fmt"
 	"math/big"
+	"net"
+	"net/http"
 	"sync"
 
 	"github.com/luxdefi/node/ids"
 	"github.com/luxdefi/coreth/core/types"
 	"github.com/luxdefi/coreth/ethclient"
 	"github.com/luxdefi/coreth/interfaces"
+	"github.com/luxdefi/coreth/rpc"
 	"github.com/ethereum/go-ethereum/common"
 )
 
@@ -24,6 +27,7 @@ type EthClient interface {
 	BlockByNumber(context.Context, *big.Int) (*types.Block, error)
 	BlockByHash(context.Context, common.Hash) (*types.Block, error)
 	BlockNumber(context.Context) (uint64, error)
+	ChainID(context.Context) (*big.Int, error)
 	CallContract(context.Context, interfaces.CallMsg, *big.Int) ([]byte, error)
 	NonceAt(context.Context, common.Address, *big.Int) (uint64, error)
 	AssetBalanceAt(context.Context, common.Address, ids.ID, *big.Int) (*big.Int, error)
@@ -45,8 +49,10 @@ type ethClient struct {
 	ipAddr  string
 	chainID string
 	port    uint
-	client  ethclient.Client
-	lock    sync.Mutex
+	// headers of the websocket handshake, if any
+	header http.Header
+	client ethclient.Client
+	lock   sync.Mutex
 }
 
 // NewEthClient mainly takes ip/port info for usage in future calls
@@ -67,14 +73,34 @@ func NewEthClientWithChainID(ipAddr string, port uint, chainID string) EthClient
 	}
 }
 
+// NewEthClientWithOptions is like NewEthClientWithChainID, and the
+// websocket handshake carries the headers given by [opts]
+func NewEthClientWithOptions(ipAddr string, port uint, chainID string, opts ClientOptions) EthClient {
+	return &ethClient{
+		ipAddr:  ipAddr,
+		port:    port,
+		chainID: chainID,
+		header:  opts.header(),
+	}
+}
+
 // connect attempts to connect with websocket ethclient API
 func (c *ethClient) connect() error {
 	if c.client == ethclient.Client(nil) {
-		client, err := ethclient.Dial(fmt.Sprintf("ws://%s:%d/ext/bc/%s/ws", c.ipAddr, c.port, c.chainID))
+		url := fmt.Sprintf("ws://%s/ext/bc/%s/ws", net.JoinHostPort(c.ipAddr, fmt.Sprintf("%d", c.port)), c.chainID)
+		if c.header == nil {
+			client, err := ethclient.Dial(url)
+			if err != nil {
+				return err
+			}
+			c.client = client
+			return nil
+		}
+		rpcClient, err := rpc.DialOptions(context.Background(), url, rpc.WithHeaders(c.header))
 		if err != nil {
 			return err
 		}
-		c.client = client
+		c.client = ethclient.NewClient(rpcClient)
 	}
 	return nil
 }
@@ -143,6 +169,15 @@ func (c *ethClient) BlockNumber(ctx context.Context) (uint64, error) {
 	return c.client.BlockNumber(ctx)
 }
 
+func (c *ethClient) ChainID(ctx context.Context) (*big.Int, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if err := c.connect(); err != nil {
+		return nil, err
+	}
+	return c.client.ChainID(ctx)
+}
+
 func (c *ethClient) CallContract(ctx context.Context, msg interfaces.CallMsg, blockNumber *big.Int) ([]byte, error) {
 	c.lock.Lock()
 	defer c.lock.Unlock()
@@ -0,0 +1,104 @@
+// Copyright (C) 2021-2024, Lux Partners Limited. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package api
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"sync"
+)
+
+// ClientOptions of the requests of an API client to a node
+type ClientOptions struct {
+	// Token sent as bearer token, for nodes with api-auth-required enabled
+	AuthToken string `json:"authToken,omitempty"`
+	// Headers sent along every request (e.g. for a proxy in front of the node)
+	Headers map[string]string `json:"headers,omitempty"`
+}
+
+// Returns the headers given by [opts], or nil if there are none
+func (opts ClientOptions) header() http.Header {
+	if opts.AuthToken == "" && len(opts.Headers) == 0 {
+		return nil
+	}
+	header := http.Header{}
+	for k, v := range opts.Headers {
+		header.Set(k, v)
+	}
+	if opts.AuthToken != "" {
+		header.Set("Authorization", "Bearer "+opts.AuthToken)
+	}
+	return header
+}
+
+// NewAPIClientWithOptions is like NewAPIClient, and the requests of
+// the client, and of any other client of the node, carry the headers
+// given by [opts]
+func NewAPIClientWithOptions(ipAddr string, port uint16, opts ClientOptions) Client {
+	header := opts.header()
+	defaultHeaderTransport.set(net.JoinHostPort(ipAddr, fmt.Sprintf("%d", port)), header)
+	c := NewAPIClient(ipAddr, port).(*APIClient)
+	c.cChainEth = NewEthClientWithOptions(ipAddr, uint(port), "C", opts)
+	return c
+}
+
+// NewAPIClientFWithOptions returns a NewAPIClientF
+// creating clients with NewAPIClientWithOptions
+func NewAPIClientFWithOptions(opts ClientOptions) NewAPIClientF {
+	return func(ipAddr string, port uint16) Client {
+		return NewAPIClientWithOptions(ipAddr, port, opts)
+	}
+}
+
+// headerTransport adds headers to the requests to the hosts they
+// are set for. The node API clients send their requests with
+// http.DefaultClient, so it is installed as its transport once
+// headers are first set.
+type headerTransport struct {
+	install sync.Once
+	base    http.RoundTripper
+
+	lock sync.RWMutex
+	// host:port --> headers of the requests to it
+	headers map[string]http.Header
+}
+
+var defaultHeaderTransport = &headerTransport{headers: map[string]http.Header{}}
+
+// Sets the headers of the requests to [host], removing them if [header] is nil
+func (t *headerTransport) set(host string, header http.Header) {
+	if header != nil {
+		t.install.Do(func() {
+			t.base = http.DefaultClient.Transport
+			if t.base == nil {
+				t.base = http.DefaultTransport
+			}
+			http.DefaultClient.Transport = t
+		})
+	}
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	if header == nil {
+		delete(t.headers, host)
+		return
+	}
+	t.headers[host] = header
+}
+
+func (t *headerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	t.lock.RLock()
+	header, ok := t.headers[req.URL.Host]
+	t.lock.RUnlock()
+	if !ok {
+		return t.base.RoundTrip(req)
+	}
+	// a round tripper must not modify the request
+	req = req.Clone(req.Context())
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	return t.base.RoundTrip(req)
+}
@@ -0,0 +1,97 @@
+// Copyright (C) 2021-2024, Lux Partners Limited. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestClientOptionsHeader(t *testing.T) {
+	tests := []struct {
+		name           string
+		opts           ClientOptions
+		expectedHeader http.Header
+	}{
+		{
+			name: "no options",
+		},
+		{
+			name: "auth token",
+			opts: ClientOptions{AuthToken: "token"},
+			expectedHeader: http.Header{
+				"Authorization": {"Bearer token"},
+			},
+		},
+		{
+			name: "headers",
+			opts: ClientOptions{Headers: map[string]string{"x-team": "team-a"}},
+			expectedHeader: http.Header{
+				"X-Team": {"team-a"},
+			},
+		},
+		{
+			name: "auth token over headers",
+			opts: ClientOptions{
+				AuthToken: "token",
+				Headers:   map[string]string{"Authorization": "Basic creds", "X-Team": "team-a"},
+			},
+			expectedHeader: http.Header{
+				"Authorization": {"Bearer token"},
+				"X-Team":        {"team-a"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.expectedHeader, tt.opts.header())
+		})
+	}
+}
+
+func TestHeaderTransport(t *testing.T) {
+	require := require.New(t)
+
+	received := make(chan http.Header, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+		received <- r.Header
+	}))
+	defer server.Close()
+	serverURL, err := url.Parse(server.URL)
+	require.NoError(err)
+
+	transport := &headerTransport{base: http.DefaultTransport, headers: map[string]http.Header{}}
+	// not installed on http.DefaultClient
+	transport.install.Do(func() {})
+	client := &http.Client{Transport: transport}
+	get := func() http.Header {
+		req, err := http.NewRequest(http.MethodGet, server.URL, nil)
+		require.NoError(err)
+		req.Header.Set("X-Request", "1")
+		resp, err := client.Do(req)
+		require.NoError(err)
+		resp.Body.Close()
+		// the request itself is left as is
+		require.Equal(http.Header{"X-Request": {"1"}}, req.Header)
+		return <-received
+	}
+
+	require.Empty(get().Get("Authorization"))
+
+	transport.set(serverURL.Host, ClientOptions{AuthToken: "token"}.header())
+	header := get()
+	require.Equal("Bearer token", header.Get("Authorization"))
+	require.Equal("1", header.Get("X-Request"))
+
+	// the headers of other hosts aren't sent
+	transport.set("127.0.0.1:1", ClientOptions{AuthToken: "other"}.header())
+	require.Equal("Bearer token", get().Get("Authorization"))
+
+	transport.set(serverURL.Host, nil)
+	require.Empty(get().Get("Authorization"))
+}
@@ -0,0 +1,325 @@
+// Copyright (C) 2021-2024, Lux Partners Limited. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+// Package binaries downloads luxd releases, verifies their sha256
+// checksums and caches them, so that a network config can give the
+// version of its nodes (e.g. v1.10.3) instead of the path to a local
+// binary.
+package binaries
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"os/user"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+
+	"golang.org/x/mod/semver"
+)
+
+const (
+	// URL the releases are downloaded from per default. The archive of a
+	// release is at <base URL>/<version>/luxd-<os>-<arch>-<version>.tar.gz,
+	// and its sha256 checksum at the same URL with a .sha256 suffix.
+	DefaultBaseURL = "https://github.com/luxdefi/node/releases/download"
+	// Name of the node binary in the release archives
+	BinaryName = "luxd"
+
+	checksumSuffix = ".sha256"
+	// max size of a checksum file
+	maxChecksumSize = 1024
+)
+
+var (
+	ErrInvalidVersion   = errors.New("invalid luxd version")
+	ErrChecksumMismatch = errors.New("luxd release checksum mismatch")
+	ErrNoBinary         = errors.New("no luxd binary in release archive")
+
+	errFound = errors.New("found")
+
+	defaultManager     *Manager
+	defaultManagerErr  error
+	defaultManagerOnce sync.Once
+)
+
+// Manager downloads the releases into its cache dir, as
+// <cache dir>/<version>/luxd along with the rest of the archive
+// (e.g. the plugins dir), and returns the cached ones.
+type Manager struct {
+	// Dir the releases are cached in
+	CacheDir string
+	// URL the releases are downloaded from. Defaults to DefaultBaseURL.
+	BaseURL string
+	// Version --> expected sha256 of its archive for this platform, as hex.
+	// The checksum published along a version not in here is used.
+	Checksums map[string]string
+	// Client the releases are downloaded with. Defaults to http.DefaultClient.
+	Client *http.Client
+
+	// held while downloading, so that a version is downloaded once
+	lock sync.Mutex
+}
+
+// New returns a manager caching the releases in [cacheDir]
+func New(cacheDir string) *Manager {
+	return &Manager{CacheDir: cacheDir}
+}
+
+// DefaultCacheDir returns the dir the releases are cached in per
+// default, ~/.netrunner/binaries
+func DefaultCacheDir() (string, error) {
+	usr, err := user.Current()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(usr.HomeDir, ".netrunner", "binaries"), nil
+}
+
+// Get returns the path to the luxd binary of [version], downloading
+// it into the default cache dir unless already there
+func Get(ctx context.Context, version string) (string, error) {
+	defaultManagerOnce.Do(func() {
+		var cacheDir string
+		cacheDir, defaultManagerErr = DefaultCacheDir()
+		defaultManager = New(cacheDir)
+	})
+	if defaultManagerErr != nil {
+		return "", defaultManagerErr
+	}
+	return defaultManager.Get(ctx, version)
+}
+
+// Get returns the path to the luxd binary of [version], downloading
+// and verifying it unless already cached
+func (m *Manager) Get(ctx context.Context, version string) (string, error) {
+	if !semver.IsValid(version) || !strings.HasPrefix(version, "v") {
+		return "", fmt.Errorf("%w %q: expected a semantic version as v1.2.3", ErrInvalidVersion, version)
+	}
+	versionDir := filepath.Join(m.CacheDir, version)
+	binaryPath := filepath.Join(versionDir, BinaryName)
+	if isBinary(binaryPath) {
+		return binaryPath, nil
+	}
+
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	// downloaded while waiting for the lock
+	if isBinary(binaryPath) {
+		return binaryPath, nil
+	}
+	if err := os.MkdirAll(m.CacheDir, 0o755); err != nil {
+		return "", fmt.Errorf("couldn't create cache dir: %w", err)
+	}
+	// the release is extracted apart, and moved to its dir once complete,
+	// so that an interrupted download is never taken as cached
+	tmpDir, err := os.MkdirTemp(m.CacheDir, ".download-"+version+"-")
+	if err != nil {
+		return "", err
+	}
+	defer os.RemoveAll(tmpDir)
+
+	archiveURL := m.archiveURL(version)
+	if err := m.download(ctx, version, archiveURL, tmpDir); err != nil {
+		return "", fmt.Errorf("couldn't download luxd %s from %s: %w", version, archiveURL, err)
+	}
+	releaseDir, err := findBinaryDir(tmpDir)
+	if err != nil {
+		return "", err
+	}
+	// a leftover of an earlier failed rename, or another process
+	// having cached it meanwhile
+	if isBinary(binaryPath) {
+		return binaryPath, nil
+	}
+	if err := os.RemoveAll(versionDir); err != nil {
+		return "", err
+	}
+	if err := os.Rename(releaseDir, versionDir); err != nil {
+		return "", fmt.Errorf("couldn't move luxd %s into cache: %w", version, err)
+	}
+	return binaryPath, nil
+}
+
+// Returns the URL of the archive of [version] for this platform
+func (m *Manager) archiveURL(version string) string {
+	baseURL := m.BaseURL
+	if baseURL == "" {
+		baseURL = DefaultBaseURL
+	}
+	archiveName := fmt.Sprintf("%s-%s-%s-%s.tar.gz", BinaryName, runtime.GOOS, runtime.GOARCH, version)
+	return fmt.Sprintf("%s/%s/%s", strings.TrimSuffix(baseURL, "/"), version, archiveName)
+}
+
+func (m *Manager) client() *http.Client {
+	if m.Client != nil {
+		return m.Client
+	}
+	return http.DefaultClient
+}
+
+// Downloads the archive at [archiveURL] into [dir], and extracts
+// it there once its checksum is verified
+func (m *Manager) download(ctx context.Context, version string, archiveURL string, dir string) error {
+	expected, ok := m.Checksums[version]
+	if !ok {
+		var err error
+		expected, err = m.fetchChecksum(ctx, archiveURL+checksumSuffix)
+		if err != nil {
+			return fmt.Errorf("couldn't get checksum: %w", err)
+		}
+	}
+	expected = strings.ToLower(strings.TrimSpace(expected))
+
+	body, err := m.fetch(ctx, archiveURL)
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+
+	archivePath := filepath.Join(dir, "release.tar.gz")
+	archive, err := os.Create(archivePath)
+	if err != nil {
+		return err
+	}
+	defer archive.Close()
+	hash := sha256.New()
+	if _, err := io.Copy(io.MultiWriter(archive, hash), body); err != nil {
+		return err
+	}
+	if actual := hex.EncodeToString(hash.Sum(nil)); actual != expected {
+		return fmt.Errorf("%w: expected %s, got %s", ErrChecksumMismatch, expected, actual)
+	}
+	if _, err := archive.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	if err := extract(archive, filepath.Join(dir, "release")); err != nil {
+		return fmt.Errorf("couldn't extract archive: %w", err)
+	}
+	return os.Remove(archivePath)
+}
+
+// Returns the checksum held by the file at [checksumURL], as written
+// by sha256sum: the hex checksum, optionally followed by the file name
+func (m *Manager) fetchChecksum(ctx context.Context, checksumURL string) (string, error) {
+	body, err := m.fetch(ctx, checksumURL)
+	if err != nil {
+		return "", err
+	}
+	defer body.Close()
+	b, err := io.ReadAll(io.LimitReader(body, maxChecksumSize))
+	if err != nil {
+		return "", err
+	}
+	fields := strings.Fields(string(b))
+	if len(fields) == 0 {
+		return "", fmt.Errorf("empty checksum file %s", checksumURL)
+	}
+	return fields[0], nil
+}
+
+// Returns the body of a successful GET of [url]
+func (m *Manager) fetch(ctx context.Context, url string) (io.ReadCloser, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := m.client().Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("unexpected status %q for %s", resp.Status, url)
+	}
+	return resp.Body, nil
+}
+
+// Extracts the gzipped tar [r] into [dir], skipping the entries
+// other than dirs and regular files
+func extract(r io.Reader, dir string) error {
+	gz, err := gzip.NewReader(r)
+	if err != nil {
+		return err
+	}
+	defer gz.Close()
+	tr := tar.NewReader(gz)
+	for {
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		path := filepath.Join(dir, hdr.Name)
+		if rel, err := filepath.Rel(dir, path); err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return fmt.Errorf("archive entry %q outside of the archive dir", hdr.Name)
+		}
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			if err := os.MkdirAll(path, 0o755); err != nil {
+				return err
+			}
+		case tar.TypeReg:
+			if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+				return err
+			}
+			if err := writeFile(path, tr, hdr.FileInfo().Mode().Perm()); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func writeFile(path string, r io.Reader, perm fs.FileMode) error {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, perm)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(f, r); err != nil {
+		_ = f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// Returns the dir holding the luxd binary extracted under [dir]. The
+// archives hold it at their top or under a single release dir.
+func findBinaryDir(dir string) (string, error) {
+	var binaryDir string
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() && d.Name() == BinaryName {
+			binaryDir = filepath.Dir(path)
+			return errFound
+		}
+		return nil
+	})
+	if err != nil && !errors.Is(err, errFound) {
+		return "", err
+	}
+	if binaryDir == "" {
+		return "", ErrNoBinary
+	}
+	return binaryDir, nil
+}
+
+// Returns true if there is an executable file at [path]
+func isBinary(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && info.Mode().IsRegular() && info.Mode().Perm()&0o111 != 0
+}
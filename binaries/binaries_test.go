@@ -0,0 +1,156 @@
+// Copyright (C) 2021-2024, Lux Partners Limited. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package binaries
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// Returns a release archive holding [files], as name --> content
+func newArchive(t *testing.T, files map[string]string) []byte {
+	buf := &bytes.Buffer{}
+	gz := gzip.NewWriter(buf)
+	tw := tar.NewWriter(gz)
+	for name, content := range files {
+		require.NoError(t, tw.WriteHeader(&tar.Header{
+			Name:     name,
+			Mode:     0o755,
+			Size:     int64(len(content)),
+			Typeflag: tar.TypeReg,
+		}))
+		_, err := tw.Write([]byte(content))
+		require.NoError(t, err)
+	}
+	require.NoError(t, tw.Close())
+	require.NoError(t, gz.Close())
+	return buf.Bytes()
+}
+
+// Returns a server of [archive] as the release of [version], along with
+// its checksum file if [checksum] isn't empty, and its number of downloads
+func newReleaseServer(t *testing.T, version string, archive []byte, checksum string) (*httptest.Server, *int32) {
+	downloads := new(int32)
+	archivePath := fmt.Sprintf("/%s/luxd-%s-%s-%s.tar.gz", version, runtime.GOOS, runtime.GOARCH, version)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case archivePath:
+			atomic.AddInt32(downloads, 1)
+			_, _ = w.Write(archive)
+		case archivePath + checksumSuffix:
+			if checksum == "" {
+				http.NotFound(w, r)
+				return
+			}
+			_, _ = fmt.Fprintf(w, "%s  luxd.tar.gz\n", checksum)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server, downloads
+}
+
+func sha256Hex(b []byte) string {
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
+
+func TestGet(t *testing.T) {
+	require := require.New(t)
+
+	archive := newArchive(t, map[string]string{
+		"luxd-v1.10.3/luxd":               "binary",
+		"luxd-v1.10.3/plugins/evm-plugin": "plugin",
+	})
+	server, downloads := newReleaseServer(t, "v1.10.3", archive, sha256Hex(archive))
+
+	m := New(t.TempDir())
+	m.BaseURL = server.URL
+	path, err := m.Get(context.Background(), "v1.10.3")
+	require.NoError(err)
+	require.Equal(filepath.Join(m.CacheDir, "v1.10.3", BinaryName), path)
+	b, err := os.ReadFile(path)
+	require.NoError(err)
+	require.Equal("binary", string(b))
+	// the rest of the release is kept along the binary
+	b, err = os.ReadFile(filepath.Join(m.CacheDir, "v1.10.3", "plugins", "evm-plugin"))
+	require.NoError(err)
+	require.Equal("plugin", string(b))
+	// no leftover of the download
+	entries, err := os.ReadDir(m.CacheDir)
+	require.NoError(err)
+	require.Len(entries, 1)
+
+	// cached
+	path2, err := m.Get(context.Background(), "v1.10.3")
+	require.NoError(err)
+	require.Equal(path, path2)
+	require.EqualValues(1, atomic.LoadInt32(downloads))
+}
+
+func TestGetPinnedChecksum(t *testing.T) {
+	require := require.New(t)
+
+	archive := newArchive(t, map[string]string{"luxd": "binary"})
+	// no published checksum
+	server, _ := newReleaseServer(t, "v1.10.3", archive, "")
+
+	m := New(t.TempDir())
+	m.BaseURL = server.URL
+	_, err := m.Get(context.Background(), "v1.10.3")
+	require.Error(err)
+
+	m.Checksums = map[string]string{"v1.10.3": sha256Hex(archive)}
+	path, err := m.Get(context.Background(), "v1.10.3")
+	require.NoError(err)
+	require.Equal(filepath.Join(m.CacheDir, "v1.10.3", BinaryName), path)
+}
+
+func TestGetErrors(t *testing.T) {
+	require := require.New(t)
+
+	archive := newArchive(t, map[string]string{"luxd": "binary"})
+	server, _ := newReleaseServer(t, "v1.10.3", archive, sha256Hex([]byte("other")))
+
+	m := New(t.TempDir())
+	m.BaseURL = server.URL
+	_, err := m.Get(context.Background(), "v1.10.3")
+	require.ErrorIs(err, ErrChecksumMismatch)
+	_, err = os.Stat(filepath.Join(m.CacheDir, "v1.10.3"))
+	require.True(os.IsNotExist(err))
+
+	for _, version := range []string{"", "1.10.3", "latest", "v1.10.3/../.."} {
+		_, err = m.Get(context.Background(), version)
+		require.ErrorIs(err, ErrInvalidVersion, version)
+	}
+
+	noBinary := newArchive(t, map[string]string{"README": "no binary"})
+	server, _ = newReleaseServer(t, "v1.10.4", noBinary, sha256Hex(noBinary))
+	m.BaseURL = server.URL
+	_, err = m.Get(context.Background(), "v1.10.4")
+	require.ErrorIs(err, ErrNoBinary)
+
+	escaping := newArchive(t, map[string]string{"../luxd": "binary"})
+	server, _ = newReleaseServer(t, "v1.10.5", escaping, sha256Hex(escaping))
+	m.BaseURL = server.URL
+	_, err = m.Get(context.Background(), "v1.10.5")
+	require.Error(err)
+	_, err = os.Stat(filepath.Join(m.CacheDir, "luxd"))
+	require.True(os.IsNotExist(err))
+}
@@ -0,0 +1,208 @@
+// Copyright (C) 2021-2024, Lux Partners Limited. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package binaries
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os/exec"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// max time to run a binary to get its version or flags
+const inspectTimeout = 30 * time.Second
+
+var (
+	ErrInvalidVersionOutput = errors.New("invalid luxd version output")
+	ErrIncompatibleBinaries = errors.New("incompatible luxd binaries")
+
+	// a flag of the --help output, e.g. "      --api-admin-enabled   ..."
+	helpFlagRegex = regexp.MustCompile(`^\s*(?:-\w,\s+)?--([a-zA-Z0-9][a-zA-Z0-9._-]*)`)
+)
+
+// Capabilities of a luxd binary, as reported by its
+// --version-json (or --version) and --help outputs
+type Capabilities struct {
+	// Path of the binary
+	Path string `json:"path"`
+	// Name of the application (e.g. node)
+	Application string `json:"application"`
+	// Semantic version of the binary (e.g. v1.10.3)
+	Version string `json:"version"`
+	// Version of the database format (e.g. v1.4.5)
+	Database string `json:"database,omitempty"`
+	// Version of the rpcchainvm protocol of the VM plugins the binary runs,
+	// 0 if unknown. The binaries of a network can only share their plugins
+	// if their protocol versions are the same.
+	RPCChainVMProtocol uint   `json:"rpcChainVMProtocol,omitempty"`
+	Commit             string `json:"commit,omitempty"`
+	GoVersion          string `json:"goVersion,omitempty"`
+	// Flags accepted by the binary, sorted, without the -- prefix
+	Flags []string `json:"flags"`
+}
+
+// SupportsFlag returns true if the binary accepts the flag [name]
+func (c *Capabilities) SupportsFlag(name string) bool {
+	i := sort.SearchStrings(c.Flags, name)
+	return i < len(c.Flags) && c.Flags[i] == name
+}
+
+// Inspect runs the binary at [binaryPath] to get its capabilities
+func Inspect(ctx context.Context, binaryPath string) (*Capabilities, error) {
+	ctx, cancel := context.WithTimeout(ctx, inspectTimeout)
+	defer cancel()
+
+	c := &Capabilities{Path: binaryPath}
+	// older binaries have no --version-json
+	if out, err := exec.CommandContext(ctx, binaryPath, "--version-json").Output(); err == nil {
+		err = c.parseVersionJSON(out)
+		if err != nil {
+			return nil, fmt.Errorf("binary %q: %w", binaryPath, err)
+		}
+	} else {
+		out, err := exec.CommandContext(ctx, binaryPath, "--version").Output()
+		if err != nil {
+			return nil, fmt.Errorf("couldn't get version of binary %q: %w", binaryPath, err)
+		}
+		if err := c.parseVersion(string(out)); err != nil {
+			return nil, fmt.Errorf("binary %q: %w", binaryPath, err)
+		}
+	}
+	// the usage may be written along a non-zero exit code, so
+	// the output is taken as long as it lists flags
+	out, err := exec.CommandContext(ctx, binaryPath, "--help").CombinedOutput()
+	c.Flags = parseHelpFlags(string(out))
+	if len(c.Flags) == 0 {
+		if err == nil {
+			err = errors.New("no flag listed")
+		}
+		return nil, fmt.Errorf("couldn't get flags of binary %q: %w", binaryPath, err)
+	}
+	return c, nil
+}
+
+// Parses the --version-json output [b], e.g.
+// {"application":"node/1.10.3","database":"v1.4.5","rpcchainvm":28,"commit":"...","go":"1.20.1"}
+func (c *Capabilities) parseVersionJSON(b []byte) error {
+	v := struct {
+		Application string `json:"application"`
+		Database    string `json:"database"`
+		RPCChainVM  uint   `json:"rpcchainvm"`
+		Commit      string `json:"commit"`
+		Go          string `json:"go"`
+	}{}
+	if err := json.Unmarshal(b, &v); err != nil {
+		return fmt.Errorf("%w %q: %s", ErrInvalidVersionOutput, b, err)
+	}
+	if err := c.parseApplication(v.Application); err != nil {
+		return err
+	}
+	c.Database = v.Database
+	c.RPCChainVMProtocol = v.RPCChainVM
+	c.Commit = v.Commit
+	c.GoVersion = v.Go
+	return nil
+}
+
+// Parses the --version output [out], e.g.
+// node/1.10.3 [database=v1.4.5, rpcchainvm=28, commit=..., go=1.20.1]
+func (c *Capabilities) parseVersion(out string) error {
+	out = strings.TrimSpace(out)
+	application, details, _ := strings.Cut(out, " ")
+	if err := c.parseApplication(application); err != nil {
+		return err
+	}
+	details = strings.TrimSuffix(strings.TrimPrefix(strings.TrimSpace(details), "["), "]")
+	for _, field := range strings.Split(details, ",") {
+		key, value, ok := strings.Cut(strings.TrimSpace(field), "=")
+		if !ok {
+			continue
+		}
+		switch key {
+		case "database":
+			c.Database = value
+		case "rpcchainvm":
+			protocol, err := strconv.ParseUint(value, 10, 32)
+			if err != nil {
+				return fmt.Errorf("%w %q: invalid rpcchainvm %q", ErrInvalidVersionOutput, out, value)
+			}
+			c.RPCChainVMProtocol = uint(protocol)
+		case "commit":
+			c.Commit = value
+		case "go":
+			c.GoVersion = value
+		}
+	}
+	return nil
+}
+
+// Parses the application [s] of the version output, as <name>/<version>
+func (c *Capabilities) parseApplication(s string) error {
+	name, version, ok := strings.Cut(s, "/")
+	if !ok || name == "" || version == "" {
+		return fmt.Errorf("%w: invalid application %q, expected <name>/<version>", ErrInvalidVersionOutput, s)
+	}
+	c.Application = name
+	c.Version = "v" + strings.TrimPrefix(version, "v")
+	return nil
+}
+
+// Returns the sorted flags listed by the --help output [out]
+func parseHelpFlags(out string) []string {
+	flags := map[string]struct{}{}
+	for _, line := range strings.Split(out, "\n") {
+		if m := helpFlagRegex.FindStringSubmatch(line); m != nil && m[1] != "help" {
+			flags[m[1]] = struct{}{}
+		}
+	}
+	sorted := make([]string, 0, len(flags))
+	for flag := range flags {
+		sorted = append(sorted, flag)
+	}
+	sort.Strings(sorted)
+	return sorted
+}
+
+// CheckCompatible returns an error if the binaries of [capabilities]
+// can't run the nodes of one network with the node [flags]: if their
+// rpcchainvm protocol versions differ, so that they can't share VM
+// plugins, or if one of them doesn't accept one of [flags]
+func CheckCompatible(capabilities []*Capabilities, flags []string) error {
+	var protocolBinary *Capabilities
+	for _, c := range capabilities {
+		if c.RPCChainVMProtocol == 0 {
+			continue
+		}
+		if protocolBinary == nil {
+			protocolBinary = c
+			continue
+		}
+		if c.RPCChainVMProtocol != protocolBinary.RPCChainVMProtocol {
+			return fmt.Errorf("%w: %s (%s) runs rpcchainvm protocol %d, %s (%s) runs %d",
+				ErrIncompatibleBinaries,
+				protocolBinary.Path, protocolBinary.Version, protocolBinary.RPCChainVMProtocol,
+				c.Path, c.Version, c.RPCChainVMProtocol,
+			)
+		}
+	}
+	for _, c := range capabilities {
+		unsupported := []string{}
+		for _, flag := range flags {
+			if !c.SupportsFlag(flag) {
+				unsupported = append(unsupported, flag)
+			}
+		}
+		if len(unsupported) != 0 {
+			return fmt.Errorf("%w: %s (%s) doesn't accept flags %s",
+				ErrIncompatibleBinaries, c.Path, c.Version, strings.Join(unsupported, ", "))
+		}
+	}
+	return nil
+}
@@ -0,0 +1,88 @@
+// Copyright (C) 2021-2024, Lux Partners Limited. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package binaries
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+const testHelpOutput = `Usage of luxd:
+      --api-admin-enabled                     If true, this node exposes the Admin API
+      --http-port uint                        Port of the HTTP server (default 9650)
+  -h, --help                                  Help
+      --staking.tls-key-file string           Path to the TLS private key
+      --track-subnets string                  List of subnets to track
+`
+
+// Returns the path of a fake binary printing [versionOutput] for --version,
+// [versionJSON] for --version-json if not empty, and [testHelpOutput] for --help
+func newFakeBinary(t *testing.T, versionOutput string, versionJSON string) string {
+	if runtime.GOOS == "windows" {
+		t.Skip("fake binary is a shell script")
+	}
+	versionJSONCase := `  --version-json) exit 1 ;;`
+	if versionJSON != "" {
+		versionJSONCase = `  --version-json) echo '` + versionJSON + `' ;;`
+	}
+	script := "#!/bin/sh\ncase \"$1\" in\n" +
+		"  --version) echo '" + versionOutput + "' ;;\n" +
+		versionJSONCase + "\n" +
+		"  --help) cat <<'EOF'\n" + testHelpOutput + "EOF\n    exit 2 ;;\n" +
+		"esac\n"
+	path := filepath.Join(t.TempDir(), BinaryName)
+	require.NoError(t, os.WriteFile(path, []byte(script), 0o755))
+	return path
+}
+
+func TestInspect(t *testing.T) {
+	require := require.New(t)
+
+	path := newFakeBinary(t, "node/1.10.3 [database=v1.4.5, rpcchainvm=28, commit=abc, go=1.20.1]", "")
+	c, err := Inspect(context.Background(), path)
+	require.NoError(err)
+	require.Equal(&Capabilities{
+		Path:               path,
+		Application:        "node",
+		Version:            "v1.10.3",
+		Database:           "v1.4.5",
+		RPCChainVMProtocol: 28,
+		Commit:             "abc",
+		GoVersion:          "1.20.1",
+		Flags:              []string{"api-admin-enabled", "http-port", "staking.tls-key-file", "track-subnets"},
+	}, c)
+	require.True(c.SupportsFlag("track-subnets"))
+	require.False(c.SupportsFlag("help"))
+	require.False(c.SupportsFlag("unknown"))
+
+	path = newFakeBinary(t, "unused", `{"application":"node/1.11.0","database":"v1.4.5","rpcchainvm":33,"commit":"def","go":"1.21.7"}`)
+	c, err = Inspect(context.Background(), path)
+	require.NoError(err)
+	require.Equal("v1.11.0", c.Version)
+	require.EqualValues(33, c.RPCChainVMProtocol)
+	require.Equal("def", c.Commit)
+
+	path = newFakeBinary(t, "no version", "")
+	_, err = Inspect(context.Background(), path)
+	require.ErrorIs(err, ErrInvalidVersionOutput)
+}
+
+func TestCheckCompatible(t *testing.T) {
+	require := require.New(t)
+
+	v1 := &Capabilities{Path: "a", Version: "v1.10.3", RPCChainVMProtocol: 28, Flags: []string{"http-port", "track-subnets"}}
+	v2 := &Capabilities{Path: "b", Version: "v1.10.4", RPCChainVMProtocol: 28, Flags: []string{"http-port"}}
+	v3 := &Capabilities{Path: "c", Version: "v1.11.0", RPCChainVMProtocol: 33, Flags: []string{"http-port", "track-subnets"}}
+	unknown := &Capabilities{Path: "d", Version: "v1.9.0", Flags: []string{"http-port", "track-subnets"}}
+
+	require.NoError(CheckCompatible([]*Capabilities{v1, v2}, []string{"http-port"}))
+	require.NoError(CheckCompatible([]*Capabilities{v1, unknown}, []string{"track-subnets"}))
+	require.ErrorIs(CheckCompatible([]*Capabilities{v1, v2}, []string{"track-subnets"}), ErrIncompatibleBinaries)
+	require.ErrorIs(CheckCompatible([]*Capabilities{v1, v3}, nil), ErrIncompatibleBinaries)
+}
@@ -6,25 +6,38 @@ package client
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
+	"fmt"
 	"io"
+	"os"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
 
 	"github.com/luxdefi/netrunner/local"
+	"github.com/luxdefi/netrunner/network/node"
 	"github.com/luxdefi/netrunner/rpcpb"
+	"github.com/luxdefi/netrunner/utils"
+	"github.com/luxdefi/netrunner/utils/constants"
+	"github.com/luxdefi/node/config"
 	"github.com/luxdefi/node/utils/logging"
 	"go.uber.org/zap"
 	"google.golang.org/grpc"
 	"google.golang.org/grpc/codes"
 	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
 	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/durationpb"
 )
 
 type Config struct {
 	Endpoint    string
 	DialTimeout time.Duration
+	// Tenant token sent on every call, for servers with tenancy enabled
+	AuthToken string
 }
 
 type Client interface {
@@ -41,7 +54,11 @@ type Client interface {
 	URIs(ctx context.Context) ([]string, error)
 	Status(ctx context.Context) (*rpcpb.StatusResponse, error)
 	StreamStatus(ctx context.Context, pushInterval time.Duration) (<-chan *rpcpb.ClusterInfo, error)
-	RemoveNode(ctx context.Context, name string) (*rpcpb.RemoveNodeResponse, error)
+	StreamLogs(ctx context.Context, nodeName string, logName string, follow bool) (<-chan string, error)
+	PutFile(ctx context.Context, nodeName string, path string, r io.Reader, mode os.FileMode) (*rpcpb.PutFileResponse, error)
+	GetFile(ctx context.Context, nodeName string, path string, w io.Writer) (*rpcpb.GetFileResponse, error)
+	AttachConsole(ctx context.Context, nodeName string) (rpcpb.ControlService_AttachConsoleClient, error)
+	RemoveNode(ctx context.Context, name string, opts ...OpOption) (*rpcpb.RemoveNodeResponse, error)
 	PauseNode(ctx context.Context, name string) (*rpcpb.PauseNodeResponse, error)
 	ResumeNode(ctx context.Context, name string) (*rpcpb.ResumeNodeResponse, error)
 	RestartNode(ctx context.Context, name string, opts ...OpOption) (*rpcpb.RestartNodeResponse, error)
@@ -54,6 +71,13 @@ type Client interface {
 	LoadSnapshot(ctx context.Context, snapshotName string, opts ...OpOption) (*rpcpb.LoadSnapshotResponse, error)
 	RemoveSnapshot(ctx context.Context, snapshotName string) (*rpcpb.RemoveSnapshotResponse, error)
 	GetSnapshotNames(ctx context.Context) ([]string, error)
+	SetNodeGroup(ctx context.Context, groupName string, nodeNames []string) (*rpcpb.SetNodeGroupResponse, error)
+	RemoveNodeGroup(ctx context.Context, groupName string) (*rpcpb.RemoveNodeGroupResponse, error)
+	GetNodeGroups(ctx context.Context) ([]*rpcpb.NodeGroup, error)
+	GroupOperation(ctx context.Context, groupName string, operation string) (*rpcpb.GroupOperationResponse, error)
+	AddPrimaryValidator(ctx context.Context, name string, opts ...OpOption) (*rpcpb.AddPrimaryValidatorResponse, error)
+	StartFromTemplate(ctx context.Context, name string, opts ...OpOption) (*rpcpb.StartFromTemplateResponse, error)
+	GC(ctx context.Context, confirm bool) (*rpcpb.GCResponse, error)
 }
 
 type client struct {
@@ -72,12 +96,18 @@ type client struct {
 func New(cfg Config, log logging.Logger) (Client, error) {
 	log.Debug("dialing server at ", zap.String("endpoint", cfg.Endpoint))
 
+	dialOpts := []grpc.DialOption{
+		grpc.WithBlock(),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	}
+	if cfg.AuthToken != "" {
+		dialOpts = append(dialOpts, grpc.WithPerRPCCredentials(tokenCredentials(cfg.AuthToken)))
+	}
 	ctx, cancel := context.WithTimeout(context.Background(), cfg.DialTimeout)
 	conn, err := grpc.DialContext(
 		ctx,
 		cfg.Endpoint,
-		grpc.WithBlock(),
-		grpc.WithTransportCredentials(insecure.NewCredentials()),
+		dialOpts...,
 	)
 	cancel()
 	if err != nil {
@@ -138,9 +168,10 @@ func (c *client) Start(ctx context.Context, execPath string, opts ...OpOption) (
 	}
 	req.ReassignPortsIfUsed = &ret.reassignPortsIfUsed
 	req.DynamicPorts = &ret.dynamicPorts
+	req.Labels = ret.labels
 
 	c.log.Info("start")
-	return c.controlc.Start(ctx, req)
+	return c.controlc.Start(ret.withNetworkTTL(ctx), req)
 }
 
 func (c *client) CreateBlockchains(ctx context.Context, blockchainSpecs []*rpcpb.BlockchainSpec) (*rpcpb.CreateBlockchainsResponse, error) {
@@ -259,6 +290,146 @@ func (c *client) StreamStatus(ctx context.Context, pushInterval time.Duration) (
 	return ch, nil
 }
 
+// StreamLogs returns the lines of the log [logName] of [nodeName] ("main" if
+// empty). If [follow], the lines written later are received until [ctx] is
+// done. The channel is closed once the stream ends.
+func (c *client) StreamLogs(ctx context.Context, nodeName string, logName string, follow bool) (<-chan string, error) {
+	c.log.Info("stream logs", zap.String("name", nodeName), zap.String("log-name", logName), zap.Bool("follow", follow))
+	stream, err := c.controlc.StreamLogs(ctx, &rpcpb.StreamLogsRequest{
+		Name:    nodeName,
+		LogName: logName,
+		Follow:  follow,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	ch := make(chan string, 1)
+	go func() {
+		defer close(ch)
+		for {
+			msg, err := stream.Recv()
+			if err == nil {
+				select {
+				case ch <- msg.GetLine():
+					continue
+				case <-ctx.Done():
+					return
+				case <-c.closed:
+					return
+				}
+			}
+			if errors.Is(err, io.EOF) {
+				return
+			}
+			if isClientCanceled(stream.Context().Err(), err) {
+				c.log.Debug("log stream canceled", zap.Error(err))
+			} else {
+				c.log.Warn("failed to receive logs from gRPC stream", zap.Error(err))
+			}
+			return
+		}
+	}()
+	return ch, nil
+}
+
+// size of the chunks of the files sent by PutFile
+const fileChunkSize = 64 * 1024
+
+// PutFile writes the content of [r] to the file [path], relative to the
+// root dir of [nodeName], with the permission bits [mode] (0644 if zero).
+// The server writes the file once it checks the checksum sent last.
+func (c *client) PutFile(ctx context.Context, nodeName string, path string, r io.Reader, mode os.FileMode) (*rpcpb.PutFileResponse, error) {
+	c.log.Info("put file", zap.String("name", nodeName), zap.String("path", path))
+	stream, err := c.controlc.PutFile(ctx)
+	if err != nil {
+		return nil, err
+	}
+	hash := sha256.New()
+	r = io.TeeReader(r, hash)
+	buf := make([]byte, fileChunkSize)
+	req := &rpcpb.PutFileRequest{Name: nodeName, Path: path, Mode: uint32(mode.Perm())}
+	for {
+		n, err := io.ReadFull(r, buf)
+		if n > 0 {
+			req.Chunk = buf[:n]
+			if err := stream.Send(req); err != nil {
+				// the server error is given on close
+				if errors.Is(err, io.EOF) {
+					return stream.CloseAndRecv()
+				}
+				return nil, err
+			}
+			req = &rpcpb.PutFileRequest{}
+		}
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			break
+		}
+		if err != nil {
+			_ = stream.CloseSend()
+			return nil, err
+		}
+	}
+	// the last message, or the first one of an empty file, gives the checksum
+	req.Sha256 = hex.EncodeToString(hash.Sum(nil))
+	if err := stream.Send(req); err != nil && !errors.Is(err, io.EOF) {
+		return nil, err
+	}
+	return stream.CloseAndRecv()
+}
+
+// GetFile writes the file [path], relative to the root dir of [nodeName],
+// to [w], and returns an error if its content doesn't match the checksum
+// sent last by the server. The response gives the size and checksum.
+func (c *client) GetFile(ctx context.Context, nodeName string, path string, w io.Writer) (*rpcpb.GetFileResponse, error) {
+	c.log.Info("get file", zap.String("name", nodeName), zap.String("path", path))
+	stream, err := c.controlc.GetFile(ctx, &rpcpb.GetFileRequest{Name: nodeName, Path: path})
+	if err != nil {
+		return nil, err
+	}
+	hash := sha256.New()
+	w = io.MultiWriter(w, hash)
+	resp := &rpcpb.GetFileResponse{}
+	for {
+		msg, err := stream.Recv()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if msg.Size != 0 {
+			resp.Size = msg.Size
+		}
+		if msg.Sha256 != "" {
+			resp.Sha256 = msg.Sha256
+		}
+		if _, err := w.Write(msg.Chunk); err != nil {
+			return nil, err
+		}
+	}
+	sum := hex.EncodeToString(hash.Sum(nil))
+	if resp.Sha256 != sum {
+		return nil, fmt.Errorf("checksum mismatch of file %q of node %q: expected %s, got %s", path, nodeName, resp.Sha256, sum)
+	}
+	return resp, nil
+}
+
+// AttachConsole returns a stream receiving the lines written by [nodeName]
+// to its stdout and stderr, and the results of the actions sent on it
+// (signal, dump or restart), until [ctx] is cancelled
+func (c *client) AttachConsole(ctx context.Context, nodeName string) (rpcpb.ControlService_AttachConsoleClient, error) {
+	c.log.Info("attach console", zap.String("name", nodeName))
+	stream, err := c.controlc.AttachConsole(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := stream.Send(&rpcpb.AttachConsoleRequest{Name: nodeName}); err != nil {
+		return nil, err
+	}
+	return stream, nil
+}
+
 func (c *client) Stop(ctx context.Context) (*rpcpb.StopResponse, error) {
 	c.log.Info("stop")
 	return c.controlc.Stop(ctx, &rpcpb.StopRequest{})
@@ -268,13 +439,31 @@ func (c *client) AddNode(ctx context.Context, name string, execPath string, opts
 	ret := &Op{}
 	ret.applyOpts(opts)
 
+	for key, value := range map[string]string{
+		node.RoleConfigKey: ret.nodeRole,
+		config.DBTypeKey:   ret.nodeDBType,
+	} {
+		if value == "" {
+			continue
+		}
+		if ret.globalNodeConfig == "" {
+			ret.globalNodeConfig = "{}"
+		}
+		nodeConfig, err := utils.SetJSONKey(ret.globalNodeConfig, key, value)
+		if err != nil {
+			return nil, err
+		}
+		ret.globalNodeConfig = nodeConfig
+	}
+
 	req := &rpcpb.AddNodeRequest{
-		Name:           name,
-		ExecPath:       execPath,
-		NodeConfig:     &ret.globalNodeConfig,
-		ChainConfigs:   ret.chainConfigs,
-		UpgradeConfigs: ret.upgradeConfigs,
-		SubnetConfigs:  ret.subnetConfigs,
+		Name:             name,
+		ExecPath:         execPath,
+		NodeConfig:       &ret.globalNodeConfig,
+		ChainConfigs:     ret.chainConfigs,
+		UpgradeConfigs:   ret.upgradeConfigs,
+		SubnetConfigs:    ret.subnetConfigs,
+		PrimaryValidator: ret.primaryValidator,
 	}
 
 	if ret.pluginDir != "" {
@@ -285,9 +474,19 @@ func (c *client) AddNode(ctx context.Context, name string, execPath string, opts
 	return c.controlc.AddNode(ctx, req)
 }
 
-func (c *client) RemoveNode(ctx context.Context, name string) (*rpcpb.RemoveNodeResponse, error) {
-	c.log.Info("remove node", zap.String("name", name))
-	return c.controlc.RemoveNode(ctx, &rpcpb.RemoveNodeRequest{Name: name})
+func (c *client) RemoveNode(ctx context.Context, name string, opts ...OpOption) (*rpcpb.RemoveNodeResponse, error) {
+	ret := &Op{}
+	ret.applyOpts(opts)
+
+	c.log.Info("remove node", zap.String("name", name), zap.Bool("drain", ret.drain))
+	return c.controlc.RemoveNode(ctx, &rpcpb.RemoveNodeRequest{
+		Name:                   name,
+		Drain:                  ret.drain,
+		DrainTimeout:           int64(ret.drainTimeout),
+		RemoveSubnetValidators: ret.removeSubnetValidators,
+		GracePeriod:            int64(ret.gracePeriod),
+		AwaitPeerUpdate:        ret.awaitPeerUpdate,
+	})
 }
 
 func (c *client) PauseNode(ctx context.Context, name string) (*rpcpb.PauseNodeResponse, error) {
@@ -365,7 +564,7 @@ func (c *client) LoadSnapshot(ctx context.Context, snapshotName string, opts ...
 		req.GlobalNodeConfig = &ret.globalNodeConfig
 	}
 	req.ReassignPortsIfUsed = &ret.reassignPortsIfUsed
-	return c.controlc.LoadSnapshot(ctx, &req)
+	return c.controlc.LoadSnapshot(ret.withNetworkTTL(ctx), &req)
 }
 
 func (c *client) RemoveSnapshot(ctx context.Context, snapshotName string) (*rpcpb.RemoveSnapshotResponse, error) {
@@ -382,6 +581,62 @@ func (c *client) GetSnapshotNames(ctx context.Context) ([]string, error) {
 	return resp.SnapshotNames, nil
 }
 
+func (c *client) SetNodeGroup(ctx context.Context, groupName string, nodeNames []string) (*rpcpb.SetNodeGroupResponse, error) {
+	c.log.Info("set node group", zap.String("group", groupName), zap.Strings("nodes", nodeNames))
+	return c.controlc.SetNodeGroup(ctx, &rpcpb.SetNodeGroupRequest{Name: groupName, NodeNames: nodeNames})
+}
+
+func (c *client) RemoveNodeGroup(ctx context.Context, groupName string) (*rpcpb.RemoveNodeGroupResponse, error) {
+	c.log.Info("remove node group", zap.String("group", groupName))
+	return c.controlc.RemoveNodeGroup(ctx, &rpcpb.RemoveNodeGroupRequest{Name: groupName})
+}
+
+func (c *client) GetNodeGroups(ctx context.Context) ([]*rpcpb.NodeGroup, error) {
+	c.log.Info("get node groups")
+	resp, err := c.controlc.GetNodeGroups(ctx, &rpcpb.GetNodeGroupsRequest{})
+	if err != nil {
+		return nil, err
+	}
+	return resp.Groups, nil
+}
+
+// GroupOperation applies [operation] (e.g. "restart") to the nodes of the group
+func (c *client) GroupOperation(ctx context.Context, groupName string, operation string) (*rpcpb.GroupOperationResponse, error) {
+	c.log.Info("group operation", zap.String("group", groupName), zap.String("operation", operation))
+	return c.controlc.GroupOperation(ctx, &rpcpb.GroupOperationRequest{Group: groupName, Operation: operation})
+}
+
+// AddPrimaryValidator registers the running node [name] as a primary network
+// validator, with the stake and duration given by WithPrimaryValidator, if any
+func (c *client) AddPrimaryValidator(ctx context.Context, name string, opts ...OpOption) (*rpcpb.AddPrimaryValidatorResponse, error) {
+	ret := &Op{}
+	ret.applyOpts(opts)
+
+	req := &rpcpb.AddPrimaryValidatorRequest{
+		Name:             name,
+		PrimaryValidator: ret.primaryValidator,
+	}
+
+	c.log.Info("add primary validator", zap.String("name", name), zap.Uint64("stake", req.GetPrimaryValidator().GetStake()))
+	return c.controlc.AddPrimaryValidator(ctx, req)
+}
+
+// StartFromTemplate starts a network from the template [name] registered
+// on the server, with the lifetime given by WithNetworkTTL, if any
+func (c *client) StartFromTemplate(ctx context.Context, name string, opts ...OpOption) (*rpcpb.StartFromTemplateResponse, error) {
+	ret := &Op{}
+	ret.applyOpts(opts)
+
+	c.log.Info("start from template", zap.String("name", name))
+	return c.controlc.StartFromTemplate(ret.withNetworkTTL(ctx), &rpcpb.StartFromTemplateRequest{Name: name})
+}
+
+// GC reports the orphaned network root dirs, deleting them if [confirm]
+func (c *client) GC(ctx context.Context, confirm bool) (*rpcpb.GCResponse, error) {
+	c.log.Info("gc", zap.Bool("confirm", confirm))
+	return c.controlc.GC(ctx, &rpcpb.GCRequest{Confirm: confirm})
+}
+
 func (c *client) Close() error {
 	c.closeOnce.Do(func() {
 		close(c.closed)
@@ -390,20 +645,31 @@ func (c *client) Close() error {
 }
 
 type Op struct {
-	numNodes            uint32
-	execPath            string
-	trackSubnets        string
-	globalNodeConfig    string
-	rootDataDir         string
-	pluginDir           string
-	blockchainSpecs     []*rpcpb.BlockchainSpec
-	customNodeConfigs   map[string]string
-	numSubnets          uint32
-	chainConfigs        map[string]string
-	upgradeConfigs      map[string]string
-	subnetConfigs       map[string]string
-	reassignPortsIfUsed bool
-	dynamicPorts        bool
+	numNodes               uint32
+	execPath               string
+	trackSubnets           string
+	globalNodeConfig       string
+	rootDataDir            string
+	pluginDir              string
+	blockchainSpecs        []*rpcpb.BlockchainSpec
+	customNodeConfigs      map[string]string
+	numSubnets             uint32
+	chainConfigs           map[string]string
+	upgradeConfigs         map[string]string
+	subnetConfigs          map[string]string
+	reassignPortsIfUsed    bool
+	dynamicPorts           bool
+	labels                 map[string]string
+	nodeRole               string
+	nodeDBType             string
+	primaryValidator       *rpcpb.PrimaryValidatorSpec
+	networkTTL             time.Duration
+	networkTTLSnapshot     bool
+	drain                  bool
+	drainTimeout           time.Duration
+	removeSubnetValidators bool
+	gracePeriod            time.Duration
+	awaitPeerUpdate        bool
 }
 
 type OpOption func(*Op)
@@ -503,12 +769,85 @@ func WithReassignPortsIfUsed(reassignPortsIfUsed bool) OpOption {
 	}
 }
 
+// WithNodeRole sets the role of the added node (e.g. node.RoleAPI)
+func WithNodeRole(nodeRole string) OpOption {
+	return func(op *Op) {
+		op.nodeRole = nodeRole
+	}
+}
+
+// WithNodeDBType sets the database backend of the added node (e.g. node.DBTypePebbleDB)
+func WithNodeDBType(dbType string) OpOption {
+	return func(op *Op) {
+		op.nodeDBType = dbType
+	}
+}
+
+// WithPrimaryValidator registers the added node as a primary network
+// validator, returning once it is validating. Zero values take the defaults.
+func WithPrimaryValidator(stake uint64, duration time.Duration) OpOption {
+	return func(op *Op) {
+		op.primaryValidator = &rpcpb.PrimaryValidatorSpec{
+			Stake:    stake,
+			Duration: durationpb.New(duration),
+		}
+	}
+}
+
 func WithDynamicPorts(dynamicPorts bool) OpOption {
 	return func(op *Op) {
 		op.dynamicPorts = dynamicPorts
 	}
 }
 
+// WithLabels sets the labels of the started network
+func WithLabels(labels map[string]string) OpOption {
+	return func(op *Op) {
+		op.labels = labels
+	}
+}
+
+// WithNetworkTTL sets the lifetime of the started or loaded network, which
+// the server stops once it elapses, saving a snapshot first if [snapshot]
+func WithNetworkTTL(ttl time.Duration, snapshot bool) OpOption {
+	return func(op *Op) {
+		op.networkTTL = ttl
+		op.networkTTLSnapshot = snapshot
+	}
+}
+
+// WithDrain makes RemoveNode stop the node once its API connections are
+// closed, waiting up to [timeout] (0 for the server default), and remove
+// it as a validator of its subnets first if [removeSubnetValidators]
+func WithDrain(timeout time.Duration, removeSubnetValidators bool) OpOption {
+	return func(op *Op) {
+		op.drain = true
+		op.drainTimeout = timeout
+		op.removeSubnetValidators = removeSubnetValidators
+	}
+}
+
+// WithDrainGracePeriod makes RemoveNode with WithDrain kill the node if
+// it's still running [gracePeriod] after being sent a SIGTERM, and wait for
+// its peers to drop it if [awaitPeerUpdate]
+func WithDrainGracePeriod(gracePeriod time.Duration, awaitPeerUpdate bool) OpOption {
+	return func(op *Op) {
+		op.gracePeriod = gracePeriod
+		op.awaitPeerUpdate = awaitPeerUpdate
+	}
+}
+
+// Returns [ctx] with the network TTL of [op], if any, in its metadata
+func (op *Op) withNetworkTTL(ctx context.Context) context.Context {
+	if op.networkTTL == 0 {
+		return ctx
+	}
+	return metadata.AppendToOutgoingContext(ctx,
+		constants.NetworkTTLMetadataKey, op.networkTTL.String(),
+		constants.NetworkTTLSnapshotMetadataKey, strconv.FormatBool(op.networkTTLSnapshot),
+	)
+}
+
 func isClientCanceled(ctxErr error, err error) bool {
 	if ctxErr != nil {
 		return true
@@ -540,3 +879,15 @@ func isClientCanceled(ctxErr error, err error) bool {
 	}
 	return false
 }
+
+// tokenCredentials sends a tenant token as a bearer authorization header
+type tokenCredentials string
+
+func (t tokenCredentials) GetRequestMetadata(context.Context, ...string) (map[string]string, error) {
+	return map[string]string{"authorization": "Bearer " + string(t)}, nil
+}
+
+// The server may be reached through an insecure connection
+func (tokenCredentials) RequireTransportSecurity() bool {
+	return false
+}
@@ -0,0 +1,94 @@
+// Copyright (C) 2021-2024, Lux Partners Limited. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package client
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/grandcat/zeroconf"
+	"github.com/luxdefi/netrunner/utils/constants"
+)
+
+// DiscoveredServer is a netrunner server advertised over mDNS
+type DiscoveredServer struct {
+	Instance string
+	// gRPC endpoint, usable as Config.Endpoint
+	Endpoint string
+	// grpc-gateway endpoint, empty if the gateway is disabled
+	GatewayEndpoint string
+	RPCVersion      uint32
+	// True if the server requires a tenant token
+	Tenancy bool
+}
+
+// Discover browses mDNS for netrunner servers on the same machine or LAN
+// until [ctx] is done, and returns the servers found, sorted by endpoint.
+func Discover(ctx context.Context) ([]DiscoveredServer, error) {
+	resolver, err := zeroconf.NewResolver(nil)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't create mDNS resolver: %w", err)
+	}
+	entries := make(chan *zeroconf.ServiceEntry)
+	found := map[string]DiscoveredServer{}
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for entry := range entries {
+			server, ok := toDiscoveredServer(entry)
+			if ok {
+				found[server.Endpoint] = server
+			}
+		}
+	}()
+	if err := resolver.Browse(ctx, constants.MDNSService, constants.MDNSDomain, entries); err != nil {
+		return nil, fmt.Errorf("couldn't browse mDNS: %w", err)
+	}
+	<-ctx.Done()
+	// the resolver closes [entries] once [ctx] is done
+	<-done
+
+	servers := make([]DiscoveredServer, 0, len(found))
+	for _, server := range found {
+		servers = append(servers, server)
+	}
+	sort.Slice(servers, func(i, j int) bool {
+		return servers[i].Endpoint < servers[j].Endpoint
+	})
+	return servers, nil
+}
+
+func toDiscoveredServer(entry *zeroconf.ServiceEntry) (DiscoveredServer, bool) {
+	var ip net.IP
+	switch {
+	case len(entry.AddrIPv4) > 0:
+		ip = entry.AddrIPv4[0]
+	case len(entry.AddrIPv6) > 0:
+		ip = entry.AddrIPv6[0]
+	default:
+		return DiscoveredServer{}, false
+	}
+	server := DiscoveredServer{
+		Instance: entry.Instance,
+		Endpoint: net.JoinHostPort(ip.String(), strconv.Itoa(entry.Port)),
+	}
+	for _, txt := range entry.Text {
+		k, v, _ := strings.Cut(txt, "=")
+		switch k {
+		case constants.MDNSGatewayPortKey:
+			server.GatewayEndpoint = net.JoinHostPort(ip.String(), v)
+		case constants.MDNSRPCVersionKey:
+			if version, err := strconv.ParseUint(v, 10, 32); err == nil {
+				server.RPCVersion = uint32(version)
+			}
+		case constants.MDNSTenancyKey:
+			server.Tenancy = v == "true"
+		}
+	}
+	return server, true
+}
@@ -0,0 +1,97 @@
+// Copyright (C) 2021-2024, Lux Partners Limited. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package client
+
+import (
+	"net"
+	"testing"
+
+	"github.com/grandcat/zeroconf"
+	"github.com/luxdefi/netrunner/utils/constants"
+	"github.com/stretchr/testify/require"
+)
+
+func TestToDiscoveredServer(t *testing.T) {
+	tests := []struct {
+		name           string
+		entry          *zeroconf.ServiceEntry
+		expectedServer DiscoveredServer
+		expectedOk     bool
+	}{
+		{
+			name: "IPv4",
+			entry: &zeroconf.ServiceEntry{
+				ServiceRecord: zeroconf.ServiceRecord{Instance: "netrunner-host"},
+				Port:          8080,
+				AddrIPv4:      []net.IP{net.ParseIP("192.168.1.10"), net.ParseIP("10.0.0.1")},
+				AddrIPv6:      []net.IP{net.ParseIP("fe80::1")},
+				Text: []string{
+					constants.MDNSGatewayPortKey + "=8081",
+					constants.MDNSRPCVersionKey + "=30",
+					constants.MDNSTenancyKey + "=true",
+				},
+			},
+			expectedServer: DiscoveredServer{
+				Instance:        "netrunner-host",
+				Endpoint:        "192.168.1.10:8080",
+				GatewayEndpoint: "192.168.1.10:8081",
+				RPCVersion:      30,
+				Tenancy:         true,
+			},
+			expectedOk: true,
+		},
+		{
+			name: "IPv6, no gateway",
+			entry: &zeroconf.ServiceEntry{
+				ServiceRecord: zeroconf.ServiceRecord{Instance: "netrunner-host"},
+				Port:          8080,
+				AddrIPv6:      []net.IP{net.ParseIP("fe80::1")},
+				Text: []string{
+					constants.MDNSRPCVersionKey + "=30",
+					constants.MDNSTenancyKey + "=false",
+				},
+			},
+			expectedServer: DiscoveredServer{
+				Instance:   "netrunner-host",
+				Endpoint:   "[fe80::1]:8080",
+				RPCVersion: 30,
+			},
+			expectedOk: true,
+		},
+		{
+			name: "invalid and unknown txt records",
+			entry: &zeroconf.ServiceEntry{
+				ServiceRecord: zeroconf.ServiceRecord{Instance: "netrunner-host"},
+				Port:          8080,
+				AddrIPv4:      []net.IP{net.ParseIP("127.0.0.1")},
+				Text: []string{
+					constants.MDNSRPCVersionKey + "=latest",
+					"unknown=1",
+					"novalue",
+				},
+			},
+			expectedServer: DiscoveredServer{
+				Instance: "netrunner-host",
+				Endpoint: "127.0.0.1:8080",
+			},
+			expectedOk: true,
+		},
+		{
+			name: "no address",
+			entry: &zeroconf.ServiceEntry{
+				ServiceRecord: zeroconf.ServiceRecord{Instance: "netrunner-host"},
+				Port:          8080,
+			},
+			expectedOk: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require := require.New(t)
+			server, ok := toDiscoveredServer(tt.entry)
+			require.Equal(tt.expectedOk, ok)
+			require.Equal(tt.expectedServer, server)
+		})
+	}
+}
@@ -0,0 +1,256 @@
+// Copyright (C) 2021-2024, Lux Partners Limited. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package client
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/luxdefi/netrunner/api"
+	"github.com/luxdefi/node/ids"
+	"github.com/luxdefi/node/vms/platformvm/status"
+)
+
+const (
+	defaultWaitInitialInterval = 250 * time.Millisecond
+	defaultWaitMaxInterval     = 5 * time.Second
+	defaultWaitBackoffFactor   = 2
+)
+
+var ErrWaitTimeout = errors.New("timed out waiting")
+
+// Condition returns nil if the condition waited for holds, or else
+// an error describing why it doesn't hold yet (e.g. "height 3 < 10")
+type Condition func(ctx context.Context) error
+
+// stopError stops a wait, as the condition can't hold anymore
+type stopError struct {
+	err error
+}
+
+func (e *stopError) Error() string {
+	return e.err.Error()
+}
+
+func (e *stopError) Unwrap() error {
+	return e.err
+}
+
+// StopWaiting wraps [err] so that, returned by a condition, the wait
+// stops at once with [err] instead of checking the condition again
+func StopWaiting(err error) error {
+	return &stopError{err: err}
+}
+
+type WaitOp struct {
+	description     string
+	timeout         time.Duration
+	initialInterval time.Duration
+	maxInterval     time.Duration
+	backoffFactor   float64
+	// options of the API client of the node, for the helpers checking a node
+	apiClientOptions *api.ClientOptions
+}
+
+type WaitOption func(*WaitOp)
+
+func (op *WaitOp) applyOpts(opts []WaitOption) {
+	for _, opt := range opts {
+		opt(op)
+	}
+}
+
+// Describes the condition in the timeout error. Defaults to "condition".
+func WithDescription(description string) WaitOption {
+	return func(op *WaitOp) {
+		op.description = description
+	}
+}
+
+// Max time to wait. If zero, the wait lasts until the context is done.
+func WithWaitTimeout(timeout time.Duration) WaitOption {
+	return func(op *WaitOp) {
+		op.timeout = timeout
+	}
+}
+
+// Backoff between two checks of the condition, starting at [initial] and
+// multiplied by [factor] after each check, up to [max]
+func WithBackoff(initial time.Duration, max time.Duration, factor float64) WaitOption {
+	return func(op *WaitOp) {
+		op.initialInterval = initial
+		op.maxInterval = max
+		op.backoffFactor = factor
+	}
+}
+
+// Auth token and headers of the API calls of the helpers checking a
+// node, e.g. if the node is started with api-auth-required enabled
+func WithAPIClientOptions(opts api.ClientOptions) WaitOption {
+	return func(op *WaitOp) {
+		op.apiClientOptions = &opts
+	}
+}
+
+// WaitFor checks [condition] until it holds, backing off between the
+// checks. If it doesn't hold in time, the returned error wraps
+// ErrWaitTimeout and the last reason the condition didn't hold.
+func WaitFor(ctx context.Context, condition Condition, opts ...WaitOption) error {
+	op := WaitOp{
+		description:     "condition",
+		initialInterval: defaultWaitInitialInterval,
+		maxInterval:     defaultWaitMaxInterval,
+		backoffFactor:   defaultWaitBackoffFactor,
+	}
+	op.applyOpts(opts)
+	if op.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, op.timeout)
+		defer cancel()
+	}
+
+	start := time.Now()
+	interval := op.initialInterval
+	for attempts := 1; ; attempts++ {
+		err := condition(ctx)
+		if err == nil {
+			return nil
+		}
+		var stop *stopError
+		if errors.As(err, &stop) {
+			return fmt.Errorf("stopped waiting for %s: %w", op.description, stop.err)
+		}
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("%w for %s after %s (%d attempts), last check: %v",
+				ErrWaitTimeout, op.description, time.Since(start).Round(time.Millisecond), attempts, err)
+		case <-time.After(interval):
+		}
+		interval = time.Duration(float64(interval) * op.backoffFactor)
+		if interval > op.maxInterval {
+			interval = op.maxInterval
+		}
+	}
+}
+
+// WaitForHeight waits until the height of [chain], "P" or "C", on the
+// node at [uri] (e.g. one of Client.URIs) is at least [height]
+func WaitForHeight(ctx context.Context, uri string, chain string, height uint64, opts ...WaitOption) error {
+	apiClient, err := newNodeAPIClient(uri, opts)
+	if err != nil {
+		return err
+	}
+	if chain != "P" && chain != "C" {
+		return fmt.Errorf("unknown chain %q", chain)
+	}
+	defer apiClient.CChainEthAPI().Close()
+	opts = append([]WaitOption{WithDescription(fmt.Sprintf("%s-Chain height %d on %s", chain, height, uri))}, opts...)
+	return WaitFor(ctx, func(ctx context.Context) error {
+		var (
+			current uint64
+			err     error
+		)
+		if chain == "P" {
+			current, err = apiClient.PChainAPI().GetHeight(ctx)
+		} else {
+			current, err = apiClient.CChainEthAPI().BlockNumber(ctx)
+		}
+		if err != nil {
+			return err
+		}
+		if current < height {
+			return fmt.Errorf("height %d < %d", current, height)
+		}
+		return nil
+	}, opts...)
+}
+
+// WaitForValidatorActive waits until [nodeID] is a current validator of
+// [subnetID], as seen by the node at [uri]. The empty ID is the primary network.
+func WaitForValidatorActive(ctx context.Context, uri string, subnetID ids.ID, nodeID ids.NodeID, opts ...WaitOption) error {
+	apiClient, err := newNodeAPIClient(uri, opts)
+	if err != nil {
+		return err
+	}
+	opts = append([]WaitOption{WithDescription(fmt.Sprintf("validator %s of subnet %s", nodeID, subnetID))}, opts...)
+	return WaitFor(ctx, func(ctx context.Context) error {
+		vdrs, err := apiClient.PChainAPI().GetCurrentValidators(ctx, subnetID, []ids.NodeID{nodeID})
+		if err != nil {
+			return err
+		}
+		if len(vdrs) == 0 {
+			return errors.New("not a current validator")
+		}
+		return nil
+	}, opts...)
+}
+
+// WaitForPChainTxAccepted waits until the P-Chain tx [txID] is committed,
+// as seen by the node at [uri]. The wait stops if the tx is dropped.
+func WaitForPChainTxAccepted(ctx context.Context, uri string, txID ids.ID, opts ...WaitOption) error {
+	apiClient, err := newNodeAPIClient(uri, opts)
+	if err != nil {
+		return err
+	}
+	opts = append([]WaitOption{WithDescription(fmt.Sprintf("P-Chain tx %s", txID))}, opts...)
+	return WaitFor(ctx, func(ctx context.Context) error {
+		resp, err := apiClient.PChainAPI().GetTxStatus(ctx, txID)
+		if err != nil {
+			return err
+		}
+		switch resp.Status {
+		case status.Committed:
+			return nil
+		case status.Aborted, status.Dropped:
+			return StopWaiting(fmt.Errorf("tx %s: %s", resp.Status, resp.Reason))
+		default:
+			return fmt.Errorf("tx %s", resp.Status)
+		}
+	}, opts...)
+}
+
+// WaitForCChainTxAccepted waits until the C-Chain tx [txHash] is accepted
+// on the node at [uri]. The wait stops if the tx is reverted.
+func WaitForCChainTxAccepted(ctx context.Context, uri string, txHash common.Hash, opts ...WaitOption) error {
+	apiClient, err := newNodeAPIClient(uri, opts)
+	if err != nil {
+		return err
+	}
+	defer apiClient.CChainEthAPI().Close()
+	opts = append([]WaitOption{WithDescription(fmt.Sprintf("C-Chain tx %s", txHash))}, opts...)
+	return WaitFor(ctx, func(ctx context.Context) error {
+		receipt, err := apiClient.CChainEthAPI().TransactionReceipt(ctx, txHash)
+		if err != nil {
+			return err
+		}
+		if receipt.Status == 0 {
+			return StopWaiting(fmt.Errorf("tx reverted in block %s", receipt.BlockNumber))
+		}
+		return nil
+	}, opts...)
+}
+
+// Returns the API client of the node at [uri], as returned by Client.URIs,
+// with the API client options given in [opts], if any
+func newNodeAPIClient(uri string, opts []WaitOption) (api.Client, error) {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return nil, fmt.Errorf("invalid node URI %q: %w", uri, err)
+	}
+	port, err := strconv.ParseUint(u.Port(), 10, 16)
+	if err != nil {
+		return nil, fmt.Errorf("invalid port of node URI %q: %w", uri, err)
+	}
+	op := WaitOp{}
+	op.applyOpts(opts)
+	if op.apiClientOptions != nil {
+		return api.NewAPIClientWithOptions(u.Hostname(), uint16(port), *op.apiClientOptions), nil
+	}
+	return api.NewAPIClient(u.Hostname(), uint16(port)), nil
+}
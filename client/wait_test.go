@@ -0,0 +1,186 @@
+// Copyright (C) 2021-2024, Lux Partners Limited. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package client
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/luxdefi/node/ids"
+	"github.com/stretchr/testify/require"
+)
+
+// a backoff short enough for the tests
+var testBackoff = WithBackoff(time.Millisecond, 5*time.Millisecond, 2)
+
+func TestWaitFor(t *testing.T) {
+	tests := []struct {
+		name             string
+		holdsAt          int32
+		stop             bool
+		timeout          time.Duration
+		expectedErr      error
+		expectedErrText  string
+		expectedAttempts int32
+	}{
+		{
+			name:             "holds at once",
+			holdsAt:          1,
+			expectedAttempts: 1,
+		},
+		{
+			name:             "holds after retries",
+			holdsAt:          3,
+			expectedAttempts: 3,
+		},
+		{
+			name:            "timeout",
+			timeout:         20 * time.Millisecond,
+			expectedErr:     ErrWaitTimeout,
+			expectedErrText: "for height of node1 after",
+		},
+		{
+			name:             "stopped",
+			stop:             true,
+			expectedErrText:  "stopped waiting for height of node1: height can't be reached",
+			expectedAttempts: 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require := require.New(t)
+			attempts := int32(0)
+			condition := func(context.Context) error {
+				attempt := atomic.AddInt32(&attempts, 1)
+				switch {
+				case tt.stop:
+					return StopWaiting(errors.New("height can't be reached"))
+				case tt.holdsAt != 0 && attempt >= tt.holdsAt:
+					return nil
+				default:
+					return fmt.Errorf("height %d < 10", attempt)
+				}
+			}
+			err := WaitFor(context.Background(), condition,
+				WithDescription("height of node1"),
+				WithWaitTimeout(tt.timeout),
+				testBackoff,
+			)
+			if tt.expectedErr == nil && tt.expectedErrText == "" {
+				require.NoError(err)
+			}
+			if tt.expectedErr != nil {
+				require.ErrorIs(err, tt.expectedErr)
+				// the last reason the condition didn't hold is given
+				require.ErrorContains(err, fmt.Sprintf("last check: height %d < 10", atomic.LoadInt32(&attempts)))
+			}
+			if tt.expectedErrText != "" {
+				require.ErrorContains(err, tt.expectedErrText)
+			}
+			if tt.expectedAttempts != 0 {
+				require.Equal(tt.expectedAttempts, atomic.LoadInt32(&attempts))
+			}
+		})
+	}
+}
+
+func TestWaitForContextDone(t *testing.T) {
+	require := require.New(t)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err := WaitFor(ctx, func(context.Context) error {
+		return errors.New("not yet")
+	})
+	require.ErrorIs(err, ErrWaitTimeout)
+	require.ErrorContains(err, "for condition after")
+	require.ErrorContains(err, "(1 attempts), last check: not yet")
+}
+
+// Starts a node API serving the P-Chain JSON-RPC methods
+// with the results returned by [results] for each call
+func newTestPChainServer(t *testing.T, results func(method string) interface{}) string {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		req := struct {
+			ID     json.RawMessage `json:"id"`
+			Method string          `json:"method"`
+		}{}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{
+			"jsonrpc": "2.0",
+			"id":      req.ID,
+			"result":  results(req.Method),
+		})
+	}))
+	t.Cleanup(server.Close)
+	return server.URL
+}
+
+func TestWaitForHeight(t *testing.T) {
+	require := require.New(t)
+
+	calls := int32(0)
+	uri := newTestPChainServer(t, func(string) interface{} {
+		height := atomic.AddInt32(&calls, 1)
+		return map[string]string{"height": fmt.Sprint(height)}
+	})
+	require.NoError(WaitForHeight(context.Background(), uri, "P", 3, testBackoff))
+	require.Equal(int32(3), atomic.LoadInt32(&calls))
+
+	err := WaitForHeight(context.Background(), uri, "X", 3)
+	require.ErrorContains(err, `unknown chain "X"`)
+	err = WaitForHeight(context.Background(), "http://127.0.0.1", "P", 3)
+	require.ErrorContains(err, "invalid port of node URI")
+}
+
+func TestWaitForPChainTxAccepted(t *testing.T) {
+	tests := []struct {
+		name            string
+		statuses        []string
+		expectedErrText string
+		expectedCalls   int32
+	}{
+		{
+			name:          "committed",
+			statuses:      []string{"Processing", "Processing", "Committed"},
+			expectedCalls: 3,
+		},
+		{
+			name:            "dropped",
+			statuses:        []string{"Processing", "Dropped"},
+			expectedErrText: "stopped waiting for P-Chain tx",
+			expectedCalls:   2,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require := require.New(t)
+			calls := int32(0)
+			uri := newTestPChainServer(t, func(string) interface{} {
+				call := atomic.AddInt32(&calls, 1)
+				return map[string]string{"status": tt.statuses[call-1], "reason": "conflicting tx"}
+			})
+			err := WaitForPChainTxAccepted(context.Background(), uri, ids.GenerateTestID(), testBackoff, WithWaitTimeout(10*time.Second))
+			if tt.expectedErrText == "" {
+				require.NoError(err)
+			} else {
+				require.ErrorContains(err, tt.expectedErrText)
+				require.ErrorContains(err, "tx Dropped: conflicting tx")
+				require.NotErrorIs(err, ErrWaitTimeout)
+			}
+			require.Equal(tt.expectedCalls, atomic.LoadInt32(&calls))
+		})
+	}
+}
@@ -4,18 +4,26 @@
 package control
 
 import (
+	"bufio"
 	"context"
 	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"os"
 	"os/signal"
 	"path/filepath"
+	"strings"
+	"sync"
 	"syscall"
 	"time"
 
 	"github.com/luxdefi/netrunner/client"
 	"github.com/luxdefi/netrunner/local"
+	"github.com/luxdefi/netrunner/network"
+	"github.com/luxdefi/netrunner/network/node"
+	"github.com/luxdefi/netrunner/presets"
 	"github.com/luxdefi/netrunner/rpcpb"
 	"github.com/luxdefi/netrunner/utils"
 	"github.com/luxdefi/netrunner/utils/constants"
@@ -23,6 +31,7 @@ import (
 	"github.com/luxdefi/node/utils/logging"
 	"github.com/spf13/cobra"
 	"go.uber.org/zap"
+	"golang.org/x/exp/maps"
 )
 
 func init() {
@@ -38,9 +47,16 @@ var (
 	endpoint       string
 	dialTimeout    time.Duration
 	requestTimeout time.Duration
+	authToken      string
 	log            logging.Logger
 )
 
+const (
+	// --endpoint value that discovers the server over mDNS
+	autoEndpoint           = "auto"
+	defaultDiscoverTimeout = 2 * time.Second
+)
+
 // NOTE: Naming convention for node names is currently `node` + number, i.e. `node1,node2,node3,...node101`
 
 func NewCommand() *cobra.Command {
@@ -51,12 +67,14 @@ func NewCommand() *cobra.Command {
 
 	cmd.PersistentFlags().StringVar(&logLevel, "log-level", logging.Info.String(), "log level")
 	cmd.PersistentFlags().StringVar(&logDir, "log-dir", "", "log directory")
-	cmd.PersistentFlags().StringVar(&endpoint, "endpoint", "0.0.0.0:8080", "server endpoint")
+	cmd.PersistentFlags().StringVar(&endpoint, "endpoint", "0.0.0.0:8080", "server endpoint, or 'auto' to use the first server discovered over mDNS")
 	cmd.PersistentFlags().DurationVar(&dialTimeout, "dial-timeout", 10*time.Second, "server dial timeout")
 	cmd.PersistentFlags().DurationVar(&requestTimeout, "request-timeout", 3*time.Minute, "client request timeout")
+	cmd.PersistentFlags().StringVar(&authToken, "auth-token", "", "tenant token, for servers with tenancy enabled")
 
 	cmd.AddCommand(
 		newRPCVersionCommand(),
+		newDiscoverCommand(),
 		newStartCommand(),
 		newCreateBlockchainsCommand(),
 		newCreateSubnetsCommand(),
@@ -68,6 +86,10 @@ func NewCommand() *cobra.Command {
 		newURIsCommand(),
 		newStatusCommand(),
 		newStreamStatusCommand(),
+		newStreamLogsCommand(),
+		newPutFileCommand(),
+		newGetFileCommand(),
+		newAttachConsoleCommand(),
 		newAddNodeCommand(),
 		newRemoveNodeCommand(),
 		newPauseNodeCommand(),
@@ -80,6 +102,13 @@ func NewCommand() *cobra.Command {
 		newLoadSnapshotCommand(),
 		newRemoveSnapshotCommand(),
 		newGetSnapshotNamesCommand(),
+		newSetNodeGroupCommand(),
+		newRemoveNodeGroupCommand(),
+		newGetNodeGroupsCommand(),
+		newGroupOperationCommand(),
+		newAddPrimaryValidatorCommand(),
+		newStartFromTemplateCommand(),
+		newGCCommand(),
 	)
 
 	return cmd
@@ -91,6 +120,8 @@ var (
 	pluginDir           string
 	globalNodeConfig    string
 	addNodeConfig       string
+	addNodeRole         string
+	addNodeDBType       string
 	blockchainSpecsStr  string
 	customNodeConfigs   string
 	rootDataDir         string
@@ -99,6 +130,12 @@ var (
 	subnetConfigs       string
 	reassignPortsIfUsed bool
 	dynamicPorts        bool
+	singleNode          bool
+	presetName          string
+	presetOverrides     string
+	networkTTL          time.Duration
+	networkTTLSnapshot  bool
+	networkLabels       string
 )
 
 func setLogs() error {
@@ -158,6 +195,45 @@ func RPCVersionFunc(*cobra.Command, []string) error {
 	return nil
 }
 
+var discoverTimeout time.Duration
+
+func newDiscoverCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "discover [options]",
+		Short: "Lists the servers advertised over mDNS on this machine or LAN.",
+		RunE:  discoverFunc,
+		Args:  cobra.ExactArgs(0),
+	}
+	cmd.PersistentFlags().DurationVar(
+		&discoverTimeout,
+		"discover-timeout",
+		defaultDiscoverTimeout,
+		"time spent browsing for servers",
+	)
+	return cmd
+}
+
+func discoverFunc(*cobra.Command, []string) error {
+	if err := setLogs(); err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), discoverTimeout)
+	servers, err := client.Discover(ctx)
+	cancel()
+	if err != nil {
+		return err
+	}
+	if len(servers) == 0 {
+		ux.Print(log, logging.Yellow.Wrap("no servers found"))
+		return nil
+	}
+	for _, server := range servers {
+		ux.Print(log, logging.Green.Wrap("%s: endpoint %s, gateway %q, rpc version %d, tenancy %t"),
+			server.Instance, server.Endpoint, server.GatewayEndpoint, server.RPCVersion, server.Tenancy)
+	}
+	return nil
+}
+
 func newStartCommand() *cobra.Command {
 	cmd := &cobra.Command{
 		Use:   "start [options]",
@@ -243,12 +319,60 @@ func newStartCommand() *cobra.Command {
 		false,
 		"true to assign dynamic ports",
 	)
+	cmd.PersistentFlags().BoolVar(
+		&singleNode,
+		"single-node",
+		false,
+		"true to start a single node network, its node validating alone with sybil protection disabled, for an API endpoint without consensus realism (overrides --number-of-nodes)",
+	)
+	cmd.PersistentFlags().StringVar(
+		&presetName,
+		"preset",
+		"",
+		fmt.Sprintf("[optional] network preset, one of %v (overrides --number-of-nodes)", presets.Names()),
+	)
+	cmd.PersistentFlags().StringVar(
+		&presetOverrides,
+		"preset-overrides",
+		"",
+		"[optional] JSON string of the preset fields to override (e.g. '{\"numNodes\":7,\"latency\":100000000}')",
+	)
+	cmd.PersistentFlags().StringVar(
+		&networkLabels,
+		"labels",
+		"",
+		"[optional] JSON string of map of network labels, added to the labels of every node (e.g. '{\"owner\":\"alice\"}')",
+	)
+	addNetworkTTLFlags(cmd)
 	if err := cmd.MarkPersistentFlagRequired("node-path"); err != nil {
 		panic(err)
 	}
 	return cmd
 }
 
+// Returns [globalNodeConfig] with [flags] added,
+// the ones given in it taking precedence
+func withGlobalFlags(flags map[string]interface{}, globalNodeConfig string) (string, error) {
+	if len(flags) == 0 {
+		return globalNodeConfig, nil
+	}
+	merged := maps.Clone(flags)
+	if globalNodeConfig != "" {
+		givenFlags := map[string]interface{}{}
+		if err := json.Unmarshal([]byte(globalNodeConfig), &givenFlags); err != nil {
+			return "", fmt.Errorf("failed to validate JSON for provided config file: %w", err)
+		}
+		for k, v := range givenFlags {
+			merged[k] = v
+		}
+	}
+	b, err := json.Marshal(merged)
+	if err != nil {
+		return "", err
+	}
+	return string(b), nil
+}
+
 func startFunc(*cobra.Command, []string) error {
 	cli, err := newClient()
 	if err != nil {
@@ -256,6 +380,59 @@ func startFunc(*cobra.Command, []string) error {
 	}
 	defer cli.Close()
 
+	if singleNode {
+		if customNodeConfigs != "" {
+			return errors.New("single node networks can't be given custom node configs")
+		}
+		numNodes = 1
+		globalNodeConfig, err = withGlobalFlags(local.SingleNodeFlags(), globalNodeConfig)
+		if err != nil {
+			return err
+		}
+	}
+
+	var (
+		presetNodeConfigs map[string]string
+		presetSubnetSpecs []*rpcpb.SubnetSpec
+	)
+	if presetName != "" {
+		if singleNode || customNodeConfigs != "" {
+			return errors.New("preset networks can't be single node or given custom node configs")
+		}
+		p, err := presets.Get(presetName)
+		if err != nil {
+			return err
+		}
+		if presetOverrides != "" {
+			if err := json.Unmarshal([]byte(presetOverrides), &p); err != nil {
+				return fmt.Errorf("invalid preset overrides: %w", err)
+			}
+		}
+		cfg, err := p.Config(luxdBinPath)
+		if err != nil {
+			return err
+		}
+		numNodes = uint32(len(cfg.NodeConfigs))
+		presetFlags := maps.Clone(p.Flags)
+		if cfg.Topology != nil {
+			if presetFlags == nil {
+				presetFlags = map[string]interface{}{}
+			}
+			presetFlags[network.TopologyConfigKey] = cfg.Topology
+		}
+		globalNodeConfig, err = withGlobalFlags(presetFlags, globalNodeConfig)
+		if err != nil {
+			return err
+		}
+		presetNodeConfigs, err = nodeConfigKeys(cfg)
+		if err != nil {
+			return err
+		}
+		for _, spec := range p.SubnetSpecs() {
+			presetSubnetSpecs = append(presetSubnetSpecs, &rpcpb.SubnetSpec{Participants: spec.Participants})
+		}
+	}
+
 	opts := []client.OpOption{
 		client.WithNumNodes(numNodes),
 		client.WithPluginDir(pluginDir),
@@ -263,6 +440,7 @@ func startFunc(*cobra.Command, []string) error {
 		client.WithRootDataDir(rootDataDir),
 		client.WithReassignPortsIfUsed(reassignPortsIfUsed),
 		client.WithDynamicPorts(dynamicPorts),
+		client.WithNetworkTTL(networkTTL, networkTTLSnapshot),
 	}
 
 	if globalNodeConfig != "" {
@@ -282,6 +460,9 @@ func startFunc(*cobra.Command, []string) error {
 		}
 		opts = append(opts, client.WithCustomNodeConfigs(nodeConfigs))
 	}
+	if presetNodeConfigs != nil {
+		opts = append(opts, client.WithCustomNodeConfigs(presetNodeConfigs))
+	}
 
 	if blockchainSpecsStr != "" {
 		blockchainSpecs := []*rpcpb.BlockchainSpec{}
@@ -312,6 +493,13 @@ func startFunc(*cobra.Command, []string) error {
 		}
 		opts = append(opts, client.WithSubnetConfigs(subnetConfigsMap))
 	}
+	if networkLabels != "" {
+		labels := make(map[string]string)
+		if err := json.Unmarshal([]byte(networkLabels), &labels); err != nil {
+			return err
+		}
+		opts = append(opts, client.WithLabels(labels))
+	}
 
 	ctx := getAsyncContext()
 
@@ -325,9 +513,45 @@ func startFunc(*cobra.Command, []string) error {
 	}
 
 	ux.Print(log, logging.Green.Wrap("start response: %+v"), info)
+
+	if len(presetSubnetSpecs) > 0 {
+		subnetsInfo, err := cli.CreateSubnets(ctx, presetSubnetSpecs)
+		if err != nil {
+			return err
+		}
+		ux.Print(log, logging.Green.Wrap("create-subnets response: %+v"), subnetsInfo)
+	}
 	return nil
 }
 
+// Returns the node config JSONs setting the role, public IP and latency
+// of the nodes of [cfg], by node name. There's one for each node, as the
+// server starts as many nodes as custom node configs.
+func nodeConfigKeys(cfg network.Config) (map[string]string, error) {
+	nodeConfigs := map[string]string{}
+	for _, nodeConfig := range cfg.NodeConfigs {
+		keys := map[string]interface{}{}
+		if nodeConfig.Role != "" {
+			keys[node.RoleConfigKey] = nodeConfig.Role
+		}
+		if nodeConfig.PublicIP != "" {
+			keys[node.PublicIPConfigKey] = nodeConfig.PublicIP
+		}
+		if nodeConfig.Latency > 0 {
+			keys[node.LatencyConfigKey] = nodeConfig.Latency.String()
+		}
+		if nodeConfig.Region != "" {
+			keys[node.RegionConfigKey] = nodeConfig.Region
+		}
+		b, err := json.Marshal(keys)
+		if err != nil {
+			return nil, err
+		}
+		nodeConfigs[nodeConfig.Name] = string(b)
+	}
+	return nodeConfigs, nil
+}
+
 func newCreateBlockchainsCommand() *cobra.Command {
 	cmd := &cobra.Command{
 		Use:   "create-blockchains blockchain-specs [options]",
@@ -683,6 +907,314 @@ func streamStatusFunc(*cobra.Command, []string) error {
 	return nil
 }
 
+var (
+	logName    string
+	followLogs bool
+	logsGroup  string
+)
+
+func newStreamLogsCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "stream-logs [node-name] [options]",
+		Short: "Prints the log of a node, or of the nodes of a group.",
+		RunE:  streamLogsFunc,
+		Args:  cobra.MaximumNArgs(1),
+	}
+	cmd.PersistentFlags().StringVar(
+		&logName,
+		"log-name",
+		"main",
+		"name of the log file without extension (e.g. main, C, P, X or a blockchain ID)",
+	)
+	cmd.PersistentFlags().BoolVar(
+		&followLogs,
+		"follow",
+		false,
+		"true to keep printing the lines written to the log, until interrupted",
+	)
+	cmd.PersistentFlags().StringVar(
+		&logsGroup,
+		"group",
+		"",
+		"[optional] group whose nodes logs are printed, prefixed by the node name, instead of a node",
+	)
+	return cmd
+}
+
+func streamLogsFunc(_ *cobra.Command, args []string) error {
+	if (len(args) == 1) == (logsGroup != "") {
+		return errors.New("either a node name or --group must be given")
+	}
+	cli, err := newClient()
+	if err != nil {
+		return err
+	}
+	defer cli.Close()
+
+	sigc := make(chan os.Signal, 1)
+	signal.Notify(sigc, syscall.SIGINT, syscall.SIGTERM)
+
+	var (
+		ctx    context.Context
+		cancel context.CancelFunc
+	)
+	if followLogs {
+		ctx, cancel = context.WithCancel(context.Background())
+	} else {
+		ctx, cancel = context.WithTimeout(context.Background(), requestTimeout)
+	}
+	donec := make(chan struct{})
+	go func() {
+		select {
+		case sig := <-sigc:
+			log.Warn("received signal", zap.String("signal", sig.String()))
+		case <-ctx.Done():
+		}
+		cancel()
+		close(donec)
+	}()
+
+	var ch <-chan string
+	if logsGroup != "" {
+		ch, err = streamGroupLogs(ctx, cli, logsGroup)
+	} else {
+		ch, err = cli.StreamLogs(ctx, args[0], logName, followLogs)
+	}
+	if err != nil {
+		cancel()
+		return err
+	}
+	for line := range ch {
+		fmt.Println(line)
+	}
+	cancel() // receiver channel is closed, so cancel goroutine
+	<-donec
+	return nil
+}
+
+// Returns the lines of the logs of the nodes of [groupName], prefixed by
+// their node name. The channel is closed once all the logs are streamed.
+func streamGroupLogs(ctx context.Context, cli client.Client, groupName string) (<-chan string, error) {
+	groups, err := cli.GetNodeGroups(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var nodeNames []string
+	found := false
+	for _, group := range groups {
+		if group.Name == groupName {
+			nodeNames, found = group.NodeNames, true
+		}
+	}
+	if !found {
+		return nil, fmt.Errorf("node group %q not found", groupName)
+	}
+	merged := make(chan string)
+	wg := sync.WaitGroup{}
+	for _, nodeName := range nodeNames {
+		ch, err := cli.StreamLogs(ctx, nodeName, logName, followLogs)
+		if err != nil {
+			return nil, err
+		}
+		nodeName := nodeName
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for line := range ch {
+				merged <- fmt.Sprintf("[%s] %s", nodeName, line)
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(merged)
+	}()
+	return merged, nil
+}
+
+var fileMode uint32
+
+func newPutFileCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "put-file node-name local-path remote-path [options]",
+		Short: "Copies a local file to a path relative to the root dir of a node.",
+		RunE:  putFileFunc,
+		Args:  cobra.ExactArgs(3),
+	}
+	cmd.PersistentFlags().Uint32Var(
+		&fileMode,
+		"mode",
+		0,
+		"[optional] permission bits of the node file, in octal (e.g. 0755 for a plugin binary); defaults to the ones of the local file",
+	)
+	return cmd
+}
+
+func putFileFunc(_ *cobra.Command, args []string) error {
+	nodeName, localPath, remotePath := args[0], args[1], args[2]
+	f, err := os.Open(localPath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	mode := os.FileMode(fileMode)
+	if mode == 0 {
+		info, err := f.Stat()
+		if err != nil {
+			return err
+		}
+		mode = info.Mode().Perm()
+	}
+	cli, err := newClient()
+	if err != nil {
+		return err
+	}
+	defer cli.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), requestTimeout)
+	resp, err := cli.PutFile(ctx, nodeName, remotePath, f, mode)
+	cancel()
+	if err != nil {
+		return err
+	}
+
+	ux.Print(log, logging.Green.Wrap("put file response: %+v"), resp)
+	return nil
+}
+
+func newGetFileCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "get-file node-name remote-path local-path [options]",
+		Short: "Copies a file, given by its path relative to the root dir of a node, to a local path.",
+		RunE:  getFileFunc,
+		Args:  cobra.ExactArgs(3),
+	}
+	return cmd
+}
+
+func getFileFunc(_ *cobra.Command, args []string) error {
+	nodeName, remotePath, localPath := args[0], args[1], args[2]
+	cli, err := newClient()
+	if err != nil {
+		return err
+	}
+	defer cli.Close()
+
+	// written next to the local path, and renamed once checked
+	f, err := os.CreateTemp(filepath.Dir(localPath), "."+filepath.Base(localPath)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	ctx, cancel := context.WithTimeout(context.Background(), requestTimeout)
+	resp, err := cli.GetFile(ctx, nodeName, remotePath, f)
+	cancel()
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	if err := os.Rename(f.Name(), localPath); err != nil {
+		return err
+	}
+
+	ux.Print(log, logging.Green.Wrap("get file response: size %d, sha256 %s"), resp.Size, resp.Sha256)
+	return nil
+}
+
+func newAttachConsoleCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "attach-console node-name",
+		Short: "Attaches to the console of a node.",
+		Long: `Attaches to the console of a node: prints the lines written by the node
+to its stdout and stderr, across its restarts, and runs the actions typed
+on stdin, one per line:
+  signal <name>   sends a signal to the node (e.g. signal USR1)
+  dump            sends a SIGQUIT, so that the node prints the stacks of its goroutines and exits
+  restart         restarts the node
+  detach          detaches from the console, as Ctrl-D or Ctrl-C do`,
+		RunE: attachConsoleFunc,
+		Args: cobra.ExactArgs(1),
+	}
+	return cmd
+}
+
+func attachConsoleFunc(_ *cobra.Command, args []string) error {
+	cli, err := newClient()
+	if err != nil {
+		return err
+	}
+	defer cli.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	// the node is in its own process group, so it doesn't get the Ctrl-C
+	sigc := make(chan os.Signal, 1)
+	signal.Notify(sigc, syscall.SIGINT, syscall.SIGTERM)
+	go func() {
+		select {
+		case sig := <-sigc:
+			log.Warn("received signal", zap.String("signal", sig.String()))
+		case <-ctx.Done():
+		}
+		cancel()
+	}()
+
+	stream, err := cli.AttachConsole(ctx, args[0])
+	if err != nil {
+		return err
+	}
+	go func() {
+		defer cancel()
+		scanner := bufio.NewScanner(os.Stdin)
+		for scanner.Scan() {
+			fields := strings.Fields(scanner.Text())
+			if len(fields) == 0 {
+				continue
+			}
+			if fields[0] == "detach" {
+				return
+			}
+			req := &rpcpb.AttachConsoleRequest{Action: fields[0]}
+			if len(fields) > 1 {
+				req.Signal = fields[1]
+			}
+			if err := stream.Send(req); err != nil {
+				return
+			}
+		}
+	}()
+
+	for {
+		resp, err := stream.Recv()
+		if err != nil {
+			if ctx.Err() != nil || errors.Is(err, io.EOF) {
+				return nil
+			}
+			return err
+		}
+		switch {
+		case resp.Action == "" && resp.Stream == network.ConsoleStderr:
+			fmt.Fprintln(os.Stderr, resp.Line)
+		case resp.Action == "":
+			fmt.Println(resp.Line)
+		case resp.Error != "":
+			ux.Print(log, logging.Red.Wrap("%s failed: %s"), resp.Action, resp.Error)
+		default:
+			ux.Print(log, logging.Green.Wrap("%s done"), resp.Action)
+		}
+	}
+}
+
+var (
+	drainNode              bool
+	drainTimeout           time.Duration
+	removeSubnetValidators bool
+	drainGracePeriod       time.Duration
+	awaitPeerUpdate        bool
+)
+
 func newRemoveNodeCommand() *cobra.Command {
 	cmd := &cobra.Command{
 		Use:   "remove-node node-name [options]",
@@ -690,6 +1222,36 @@ func newRemoveNodeCommand() *cobra.Command {
 		RunE:  removeNodeFunc,
 		Args:  cobra.ExactArgs(1),
 	}
+	cmd.PersistentFlags().BoolVar(
+		&drainNode,
+		"drain",
+		false,
+		"true to stop the node once its API connections are closed",
+	)
+	cmd.PersistentFlags().DurationVar(
+		&drainTimeout,
+		"drain-timeout",
+		0,
+		"[optional] max time to wait for the API connections to be closed (defaults to 30s)",
+	)
+	cmd.PersistentFlags().BoolVar(
+		&removeSubnetValidators,
+		"remove-subnet-validators",
+		false,
+		"true to remove the drained node as a validator of its subnets first",
+	)
+	cmd.PersistentFlags().DurationVar(
+		&drainGracePeriod,
+		"grace-period",
+		0,
+		"[optional] max time the drained node is given to exit once sent a SIGTERM, before being killed (30s if 0)",
+	)
+	cmd.PersistentFlags().BoolVar(
+		&awaitPeerUpdate,
+		"await-peer-update",
+		false,
+		"true to wait for the other nodes to drop the drained node from their peers",
+	)
 	return cmd
 }
 
@@ -702,8 +1264,15 @@ func removeNodeFunc(_ *cobra.Command, args []string) error {
 	}
 	defer cli.Close()
 
+	opts := []client.OpOption{}
+	if drainNode {
+		opts = append(opts,
+			client.WithDrain(drainTimeout, removeSubnetValidators),
+			client.WithDrainGracePeriod(drainGracePeriod, awaitPeerUpdate),
+		)
+	}
 	ctx, cancel := context.WithTimeout(context.Background(), requestTimeout)
-	info, err := cli.RemoveNode(ctx, nodeName)
+	info, err := cli.RemoveNode(ctx, nodeName, opts...)
 	cancel()
 	if err != nil {
 		return err
@@ -816,6 +1385,18 @@ func newAddNodeCommand() *cobra.Command {
 		"",
 		"[optional] JSON string of map from subnet id to its config file contents",
 	)
+	cmd.PersistentFlags().StringVar(
+		&addNodeRole,
+		"role",
+		"",
+		"[optional] node role: 'api' for a non validating API node with state sync enabled, 'archival' for a non pruning node with the indexer enabled",
+	)
+	cmd.PersistentFlags().StringVar(
+		&addNodeDBType,
+		"db-type",
+		"",
+		"[optional] node database backend: 'leveldb' (default), 'pebbledb' or 'memdb'",
+	)
 	return cmd
 }
 
@@ -830,6 +1411,8 @@ func addNodeFunc(_ *cobra.Command, args []string) error {
 
 	opts := []client.OpOption{
 		client.WithPluginDir(pluginDir),
+		client.WithNodeRole(addNodeRole),
+		client.WithNodeDBType(addNodeDBType),
 	}
 
 	if addNodeConfig != "" {
@@ -1188,9 +1771,26 @@ func newLoadSnapshotCommand() *cobra.Command {
 		false,
 		"true to reassign snapshot ports if already taken",
 	)
+	addNetworkTTLFlags(cmd)
 	return cmd
 }
 
+// Adds the flags of the lifetime of the network created by [cmd]
+func addNetworkTTLFlags(cmd *cobra.Command) {
+	cmd.PersistentFlags().DurationVar(
+		&networkTTL,
+		"ttl",
+		0,
+		"[optional] lifetime of the network, which the server stops once it elapses (defaults to the one of the server)",
+	)
+	cmd.PersistentFlags().BoolVar(
+		&networkTTLSnapshot,
+		"ttl-snapshot",
+		false,
+		"true to save a snapshot of the network when its TTL expires, before stopping it",
+	)
+}
+
 func loadSnapshotFunc(_ *cobra.Command, args []string) error {
 	cli, err := newClient()
 	if err != nil {
@@ -1203,6 +1803,7 @@ func loadSnapshotFunc(_ *cobra.Command, args []string) error {
 		client.WithPluginDir(pluginDir),
 		client.WithRootDataDir(rootDataDir),
 		client.WithReassignPortsIfUsed(reassignPortsIfUsed),
+		client.WithNetworkTTL(networkTTL, networkTTLSnapshot),
 	}
 
 	if chainConfigs != "" {
@@ -1304,13 +1905,182 @@ func getSnapshotNamesFunc(*cobra.Command, []string) error {
 	return nil
 }
 
+func newSetNodeGroupCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "set-node-group group-name node-name... [options]",
+		Short: "Creates or replaces a named group of nodes.",
+		RunE:  setNodeGroupFunc,
+		Args:  cobra.MinimumNArgs(1),
+	}
+}
+
+func setNodeGroupFunc(_ *cobra.Command, args []string) error {
+	cli, err := newClient()
+	if err != nil {
+		return err
+	}
+	defer cli.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), requestTimeout)
+	resp, err := cli.SetNodeGroup(ctx, args[0], args[1:])
+	cancel()
+	if err != nil {
+		return err
+	}
+
+	ux.Print(log, logging.Green.Wrap("node group %s: %s"), resp.Group.Name, resp.Group.NodeNames)
+	return nil
+}
+
+func newRemoveNodeGroupCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "remove-node-group group-name [options]",
+		Short: "Removes a group of nodes, leaving its nodes untouched.",
+		RunE:  removeNodeGroupFunc,
+		Args:  cobra.ExactArgs(1),
+	}
+}
+
+func removeNodeGroupFunc(_ *cobra.Command, args []string) error {
+	cli, err := newClient()
+	if err != nil {
+		return err
+	}
+	defer cli.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), requestTimeout)
+	_, err = cli.RemoveNodeGroup(ctx, args[0])
+	cancel()
+	if err != nil {
+		return err
+	}
+
+	ux.Print(log, logging.Green.Wrap("removed node group %s"), args[0])
+	return nil
+}
+
+func newGetNodeGroupsCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "get-node-groups [options]",
+		Short: "Lists the groups of nodes.",
+		RunE:  getNodeGroupsFunc,
+		Args:  cobra.ExactArgs(0),
+	}
+}
+
+func getNodeGroupsFunc(*cobra.Command, []string) error {
+	cli, err := newClient()
+	if err != nil {
+		return err
+	}
+	defer cli.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), requestTimeout)
+	groups, err := cli.GetNodeGroups(ctx)
+	cancel()
+	if err != nil {
+		return err
+	}
+
+	for _, group := range groups {
+		ux.Print(log, logging.Green.Wrap("node group %s: %s"), group.Name, group.NodeNames)
+	}
+	return nil
+}
+
+func newGroupOperationCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "group-operation group-name operation [options]",
+		Short: "Applies an operation (restart, pause, resume, freeze, unfreeze or remove) to the nodes of a group.",
+		RunE:  groupOperationFunc,
+		Args:  cobra.ExactArgs(2),
+	}
+}
+
+func groupOperationFunc(_ *cobra.Command, args []string) error {
+	cli, err := newClient()
+	if err != nil {
+		return err
+	}
+	defer cli.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), requestTimeout)
+	resp, err := cli.GroupOperation(ctx, args[0], args[1])
+	cancel()
+	if err != nil {
+		return err
+	}
+
+	ux.Print(log, logging.Green.Wrap("%s applied to nodes %s"), args[1], resp.NodeNames)
+	return nil
+}
+
+var (
+	validatorStake    uint64
+	validatorDuration time.Duration
+)
+
+func newAddPrimaryValidatorCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "add-primary-validator node-name [options]",
+		Short: "Registers a running node as a primary network validator.",
+		RunE:  addPrimaryValidatorFunc,
+		Args:  cobra.ExactArgs(1),
+	}
+	cmd.PersistentFlags().Uint64Var(
+		&validatorStake,
+		"stake",
+		0,
+		"[optional] staked amount, in nLUX (defaults to the min validator stake)",
+	)
+	cmd.PersistentFlags().DurationVar(
+		&validatorDuration,
+		"duration",
+		0,
+		"[optional] validation duration (defaults to the max stake duration)",
+	)
+	return cmd
+}
+
+func addPrimaryValidatorFunc(_ *cobra.Command, args []string) error {
+	cli, err := newClient()
+	if err != nil {
+		return err
+	}
+	defer cli.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), requestTimeout)
+	info, err := cli.AddPrimaryValidator(ctx, args[0], client.WithPrimaryValidator(validatorStake, validatorDuration))
+	cancel()
+	if err != nil {
+		return err
+	}
+
+	ux.Print(log, logging.Green.Wrap("add primary validator response: %+v"), info)
+	return nil
+}
+
 func newClient() (client.Client, error) {
 	if err := setLogs(); err != nil {
 		return nil, err
 	}
+	if endpoint == autoEndpoint {
+		ctx, cancel := context.WithTimeout(context.Background(), defaultDiscoverTimeout)
+		servers, err := client.Discover(ctx)
+		cancel()
+		if err != nil {
+			return nil, err
+		}
+		if len(servers) == 0 {
+			return nil, errors.New("no server discovered over mDNS")
+		}
+		endpoint = servers[0].Endpoint
+		ux.Print(log, logging.Green.Wrap("using discovered server %s at %s"), servers[0].Instance, endpoint)
+	}
 	return client.New(client.Config{
 		Endpoint:    endpoint,
 		DialTimeout: dialTimeout,
+		AuthToken:   authToken,
 	}, log)
 }
 
@@ -1324,3 +2094,68 @@ func getAsyncContext() context.Context {
 	_ = cancel
 	return ctx
 }
+
+func newStartFromTemplateCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "start-from-template template-name [options]",
+		Short: "Starts a network from a template registered on the server.",
+		RunE:  startFromTemplateFunc,
+		Args:  cobra.ExactArgs(1),
+	}
+	addNetworkTTLFlags(cmd)
+	return cmd
+}
+
+func startFromTemplateFunc(_ *cobra.Command, args []string) error {
+	cli, err := newClient()
+	if err != nil {
+		return err
+	}
+	defer cli.Close()
+
+	ctx := getAsyncContext()
+
+	resp, err := cli.StartFromTemplate(ctx, args[0], client.WithNetworkTTL(networkTTL, networkTTLSnapshot))
+	if err != nil {
+		return err
+	}
+
+	ux.Print(log, logging.Green.Wrap("start-from-template response: %+v"), resp)
+	return nil
+}
+
+var gcConfirm bool
+
+func newGCCommand() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "gc [options]",
+		Short: "Reports the orphaned network root dirs, and deletes them if confirmed.",
+		RunE:  gcFunc,
+		Args:  cobra.ExactArgs(0),
+	}
+	cmd.PersistentFlags().BoolVar(
+		&gcConfirm,
+		"confirm",
+		false,
+		"true to delete the orphaned root dirs",
+	)
+	return cmd
+}
+
+func gcFunc(*cobra.Command, []string) error {
+	cli, err := newClient()
+	if err != nil {
+		return err
+	}
+	defer cli.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), requestTimeout)
+	resp, err := cli.GC(ctx, gcConfirm)
+	cancel()
+	if err != nil {
+		return err
+	}
+
+	ux.Print(log, logging.Green.Wrap("gc response: %+v"), resp)
+	return nil
+}
@@ -14,6 +14,7 @@ import (
 	"github.com/luxdefi/netrunner/server"
 	"github.com/luxdefi/netrunner/utils"
 	"github.com/luxdefi/netrunner/utils/constants"
+	"github.com/luxdefi/netrunner/utils/failpoint"
 	"github.com/luxdefi/node/utils/logging"
 	"github.com/spf13/cobra"
 	"go.uber.org/zap"
@@ -34,6 +35,34 @@ var (
 	dialTimeout        time.Duration
 	disableNodesOutput bool
 	snapshotsDir       string
+	maxNodes           uint32
+	maxDiskBytes       uint64
+	maxMemoryBytes     uint64
+	tenantTokens       map[string]string
+	adminTenants       []string
+	templatesDir       string
+	registryFile       string
+	gcRetention        time.Duration
+	apiProxyPort       string
+	apiProxyTrace      bool
+	mdnsEnabled        bool
+	dbRootDir          string
+	logsRootDir        string
+	keysRootDir        string
+	portMapHook        string
+	shutdownMode       string
+	networkTTL         time.Duration
+	networkTTLSnapshot bool
+	idleTimeout        time.Duration
+	idleAction         string
+	idleMaxCPUPercent  float64
+	gitSyncRepo        string
+	gitSyncBranch      string
+	gitSyncFile        string
+	gitSyncInterval    time.Duration
+	metricsInterval    time.Duration
+	maxFileSize        int64
+	failpoints         string
 )
 
 func NewCommand() *cobra.Command {
@@ -52,11 +81,42 @@ func NewCommand() *cobra.Command {
 	cmd.PersistentFlags().DurationVar(&dialTimeout, "dial-timeout", 10*time.Second, "server dial timeout")
 	cmd.PersistentFlags().BoolVar(&disableNodesOutput, "disable-nodes-output", false, "true to disable nodes stdout/stderr")
 	cmd.PersistentFlags().StringVar(&snapshotsDir, "snapshots-dir", "", "directory for snapshots")
+	cmd.PersistentFlags().Uint32Var(&maxNodes, "max-nodes", 0, "max number of nodes in the network (0 for no limit)")
+	cmd.PersistentFlags().Uint64Var(&maxDiskBytes, "max-disk-bytes", 0, "max disk usage of the network root data dir when adding nodes (0 for no limit)")
+	cmd.PersistentFlags().Uint64Var(&maxMemoryBytes, "max-memory-bytes", 0, "max estimated memory usage of the network nodes (0 for no limit)")
+	cmd.PersistentFlags().StringVar(&templatesDir, "templates-dir", "", "directory where network templates are persisted (in memory if empty)")
+	cmd.PersistentFlags().StringVar(&registryFile, "registry-file", "", "file where the networks of the server are persisted, so that a restarted server lists them, adopts the ones still running as detached, and drops the dead ones, removing their root dirs (in memory if empty)")
+	cmd.PersistentFlags().DurationVar(&gcRetention, "gc-retention", 0, "if not zero, periodically delete orphaned network root dirs older than this")
+	cmd.PersistentFlags().BoolVar(&mdnsEnabled, "mdns", false, "true to advertise the server over mDNS, so clients on the same machine or LAN can discover it")
+	cmd.PersistentFlags().StringVar(&apiProxyPort, "api-proxy-port", "", "if not empty, port of a reverse proxy serving every node API under /<node name>/ (e.g. :8082)")
+	cmd.PersistentFlags().BoolVar(&apiProxyTrace, "api-proxy-trace", false, "true to log the method, params size, latency and status of every request served by the API proxy, and keep the last ones for /v1/control/apitrace")
+	cmd.PersistentFlags().StringVar(&dbRootDir, "db-root-dir", "", "if not empty, base dir of the node dbs, instead of the network root dir (e.g. on a faster disk)")
+	cmd.PersistentFlags().StringVar(&logsRootDir, "logs-root-dir", "", "if not empty, base dir of the node logs, instead of the network root dir")
+	cmd.PersistentFlags().StringVar(&keysRootDir, "keys-root-dir", "", "if not empty, base dir of the node staking key/cert files, instead of the network root dir")
+	cmd.PersistentFlags().StringVar(&portMapHook, "port-map-hook", "", "if not empty, executable run with the path of the network port map file (node name, API, P2P and metrics ports) whenever the node ports change")
+	cmd.PersistentFlags().StringVar(&shutdownMode, "shutdown-mode", string(server.ShutdownStop), "what to do with the running network on SIGINT/SIGTERM: stop, snapshot (save a snapshot then stop) or detach (leave the nodes running); a second signal kills the nodes")
+	cmd.PersistentFlags().DurationVar(&networkTTL, "network-ttl", 0, "if not zero, lifetime of the created networks, which are stopped once it elapses, unless a client gives another one")
+	cmd.PersistentFlags().BoolVar(&networkTTLSnapshot, "network-ttl-snapshot", false, "true to save a snapshot of the networks whose TTL expires before stopping them")
+	cmd.PersistentFlags().DurationVar(&idleTimeout, "idle-timeout", 0, "if not zero, the network is suspended once it had no control call, API proxy traffic or node CPU load for this duration, and resumed on the next control call")
+	cmd.PersistentFlags().StringVar(&idleAction, "idle-action", string(server.IdleFreeze), "how the idle network is suspended: freeze (SIGSTOP the nodes) or snapshot (save a snapshot then stop)")
+	cmd.PersistentFlags().Float64Var(&idleMaxCPUPercent, "idle-max-cpu", 5, "node CPU usage in percent, over all the nodes, above which the network is not idle")
+	cmd.PersistentFlags().StringVar(&gitSyncRepo, "git-sync-repo", "", "if not empty, git repo whose network spec file the network is kept in sync with")
+	cmd.PersistentFlags().StringVar(&gitSyncBranch, "git-sync-branch", "main", "branch of the git sync repo")
+	cmd.PersistentFlags().StringVar(&gitSyncFile, "git-sync-file", "network.json", "path of the network spec file in the git sync repo")
+	cmd.PersistentFlags().DurationVar(&gitSyncInterval, "git-sync-interval", 30*time.Second, "how often the git sync repo is fetched")
+	cmd.PersistentFlags().DurationVar(&metricsInterval, "metrics-scrape-interval", 0, "if not zero, the node metrics are scraped at this interval and served aggregated, labeled by node name, on the gRPC gateway port at /metrics")
+	cmd.PersistentFlags().Int64Var(&maxFileSize, "max-file-size", 512*1024*1024, "max size in bytes of the files transferred to and from the node dirs with put-file and get-file")
+	cmd.PersistentFlags().StringVar(&failpoints, "failpoints", os.Getenv(failpoint.EnvVar), "for recovery tests, comma-separated points where the server crashes or stalls, as <point>=crash or <point>=delay:<duration>; points: after-write-files, before-health-wait, before-stop")
+	cmd.PersistentFlags().StringToStringVar(&tenantTokens, "tenant-tokens", nil, "map from tenant token to tenant ID; enables tenancy (e.g. token1=team-a,token2=team-b)")
+	cmd.PersistentFlags().StringSliceVar(&adminTenants, "admin-tenants", nil, "tenants allowed to garbage collect the network root dirs of every tenant (comma-separated)")
 
 	return cmd
 }
 
 func serverFunc(*cobra.Command, []string) (err error) {
+	if err := failpoint.Enable(failpoints); err != nil {
+		return err
+	}
 	if logDir == "" {
 		anrRootDir := filepath.Join(os.TempDir(), constants.RootDirPrefix)
 		err = os.MkdirAll(anrRootDir, os.ModePerm)
@@ -95,6 +155,35 @@ func serverFunc(*cobra.Command, []string) (err error) {
 		RedirectNodesOutput: !disableNodesOutput,
 		SnapshotsDir:        snapshotsDir,
 		LogLevel:            logLevel,
+		Quota: server.Quota{
+			MaxNodes:       maxNodes,
+			MaxDiskBytes:   maxDiskBytes,
+			MaxMemoryBytes: maxMemoryBytes,
+		},
+		TenantTokens:          tenantTokens,
+		AdminTenants:          adminTenants,
+		TemplatesDir:          templatesDir,
+		RegistryFile:          registryFile,
+		GCRetention:           gcRetention,
+		APIProxyPort:          apiProxyPort,
+		APIProxyTrace:         apiProxyTrace,
+		MDNSEnabled:           mdnsEnabled,
+		DBRootDir:             dbRootDir,
+		LogsRootDir:           logsRootDir,
+		KeysRootDir:           keysRootDir,
+		PortMapHook:           portMapHook,
+		ShutdownMode:          server.ShutdownMode(shutdownMode),
+		NetworkTTL:            networkTTL,
+		NetworkTTLSnapshot:    networkTTLSnapshot,
+		IdleTimeout:           idleTimeout,
+		IdleAction:            server.IdleAction(idleAction),
+		IdleMaxCPUPercent:     idleMaxCPUPercent,
+		GitSyncRepo:           gitSyncRepo,
+		GitSyncBranch:         gitSyncBranch,
+		GitSyncFile:           gitSyncFile,
+		GitSyncInterval:       gitSyncInterval,
+		MetricsScrapeInterval: metricsInterval,
+		MaxFileSize:           maxFileSize,
 	}, log)
 	if err != nil {
 		return err
@@ -116,8 +205,16 @@ func serverFunc(*cobra.Command, []string) (err error) {
 		// Got a SIGINT or SIGTERM; stop the server and wait for it to finish.
 		log.Warn("signal received: closing server", zap.String("signal", sig.String()))
 		cancel()
-		waitForServerStop := <-errChan
-		log.Warn("closed server", zap.Error(waitForServerStop))
+		select {
+		case sig := <-sigChan:
+			// Got a second signal; kill the nodes instead of waiting for them.
+			log.Warn("signal received again: killing nodes", zap.String("signal", sig.String()))
+			s.Kill()
+			waitForServerStop := <-errChan
+			log.Warn("closed server", zap.Error(waitForServerStop))
+		case waitForServerStop := <-errChan:
+			log.Warn("closed server", zap.Error(waitForServerStop))
+		}
 	case serverClosed := <-errChan:
 		// The server stopped.
 		log.Warn("server closed", zap.Error(serverClosed))
@@ -0,0 +1,149 @@
+// Copyright (C) 2021-2024, Lux Partners Limited. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+// Package devmode runs single node networks aimed at fast iteration on
+// EVM chains (e.g. dapp development) rather than realistic consensus. The
+// node is the only one sampled by consensus, so a block is produced as
+// soon as a transaction is issued (as with automining), and blocks can be
+// produced on demand, or at an interval, without transactions of the user.
+// Block timestamps follow the wall clock.
+package devmode
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"fmt"
+	"math/big"
+	"sync"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/crypto"
+	"github.com/luxdefi/coreth/core/types"
+	"github.com/luxdefi/netrunner/api"
+	"github.com/luxdefi/netrunner/local"
+	"github.com/luxdefi/netrunner/network"
+	"github.com/luxdefi/netrunner/network/node"
+)
+
+const (
+	// key of the C-Chain address prefunded by the default genesis
+	// (0x8db97C7cEcE249c2b98bDC0226Cc4C2A57BF52FC)
+	prefundedKeyHex = "56289e99c94b6912bfc12adc093c9b51124f0dc54ac7a766b2bc5ccf558d8027"
+	// gas of the transfers producing blocks
+	transferGas = 21000
+	// how often a transfer receipt is checked while waiting for its block
+	receiptCheckFreq = 100 * time.Millisecond
+)
+
+// NewConfig returns the config of a dev network, a single node network
+// running [binaryPath]. See local.NewSingleNodeConfig.
+func NewConfig(binaryPath string) network.Config {
+	return local.NewSingleNodeConfig(binaryPath)
+}
+
+// Miner produces blocks on demand on an EVM chain of a node, each with a
+// zero value transfer of the prefunded address to itself. It is safe for
+// concurrent use.
+type Miner struct {
+	client api.EthClient
+	key    *ecdsa.PrivateKey
+	addr   common.Address
+
+	// serializes the transfers, so that their nonces don't collide
+	lock sync.Mutex
+}
+
+// NewMiner returns a miner of the EVM chain with [chainID]
+// (e.g. "C" or a blockchain ID) on [n]
+func NewMiner(n node.Node, chainID string) (*Miner, error) {
+	key, err := crypto.HexToECDSA(prefundedKeyHex)
+	if err != nil {
+		return nil, err
+	}
+	return &Miner{
+		client: api.NewEthClientWithChainID(n.GetURL(), uint(n.GetAPIPort()), chainID),
+		key:    key,
+		addr:   crypto.PubkeyToAddress(key.PublicKey),
+	}, nil
+}
+
+// Close closes the connection of the miner to the chain
+func (m *Miner) Close() {
+	m.client.Close()
+}
+
+// MineBlocks produces [numBlocks] blocks, one at a time,
+// and returns the height of the last one
+func (m *Miner) MineBlocks(ctx context.Context, numBlocks uint64) (uint64, error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+
+	var height uint64
+	for i := uint64(0); i < numBlocks; i++ {
+		var err error
+		height, err = m.mineBlock(ctx)
+		if err != nil {
+			return 0, fmt.Errorf("couldn't mine block %d of %d: %w", i+1, numBlocks, err)
+		}
+	}
+	return height, nil
+}
+
+// Automine produces a block every [interval] until [ctx] is done,
+// so that the chain advances without transactions of the user.
+// Returns the error of the first block that can't be produced.
+func (m *Miner) Automine(ctx context.Context, interval time.Duration) error {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+		if _, err := m.MineBlocks(ctx, 1); err != nil && ctx.Err() == nil {
+			return err
+		}
+	}
+}
+
+// Issues a transfer and returns the height of the block it is accepted in
+func (m *Miner) mineBlock(ctx context.Context) (uint64, error) {
+	chainID, err := m.client.ChainID(ctx)
+	if err != nil {
+		return 0, err
+	}
+	nonce, err := m.client.NonceAt(ctx, m.addr, nil)
+	if err != nil {
+		return 0, err
+	}
+	gasPrice, err := m.client.SuggestGasPrice(ctx)
+	if err != nil {
+		return 0, err
+	}
+	tx, err := types.SignTx(
+		types.NewTransaction(nonce, m.addr, big.NewInt(0), transferGas, gasPrice, nil),
+		types.NewEIP155Signer(chainID),
+		m.key,
+	)
+	if err != nil {
+		return 0, err
+	}
+	if err := m.client.SendTransaction(ctx, tx); err != nil {
+		return 0, err
+	}
+	ticker := time.NewTicker(receiptCheckFreq)
+	defer ticker.Stop()
+	for {
+		receipt, err := m.client.TransactionReceipt(ctx, tx.Hash())
+		if err == nil {
+			return receipt.BlockNumber.Uint64(), nil
+		}
+		select {
+		case <-ctx.Done():
+			return 0, fmt.Errorf("transfer %s not accepted: %w", tx.Hash(), ctx.Err())
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,272 @@
+// Copyright (C) 2021-2024, Lux Partners Limited. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+// Package difftest sends identical read requests to every node of a
+// network and diffs their responses, flagging divergence between nodes,
+// a cheap way to catch nondeterminism bugs in custom VMs.
+package difftest
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/luxdefi/netrunner/network"
+)
+
+const (
+	// default number of blocks compared by EVMCalls
+	defaultNumBlocks = 10
+	// max size of a response
+	maxResponseSize = 64 * 1024 * 1024
+)
+
+var (
+	ErrNoRunningNode = errors.New("no running node")
+	errRPC           = errors.New("rpc error")
+)
+
+// Call is a JSON-RPC read request mirrored to every node
+type Call struct {
+	// Name the call is reported with. Defaults to the method.
+	Name string `json:"name,omitempty"`
+	// Path of the API on the nodes (e.g. "/ext/bc/C/rpc", "/ext/bc/P")
+	Endpoint string        `json:"endpoint"`
+	Method   string        `json:"method"`
+	Params   []interface{} `json:"params,omitempty"`
+}
+
+// CallResult holds the responses of the nodes to a call
+type CallResult struct {
+	Name string `json:"name"`
+	// Node name --> response, as canonical JSON or as "error: ..."
+	Responses map[string]string `json:"responses"`
+	// Names of the nodes by identical response, the most common response first
+	Groups    [][]string `json:"groups"`
+	Divergent bool       `json:"divergent"`
+}
+
+// Report of a differential test
+type Report struct {
+	// Running nodes the calls were sent to, by name
+	Nodes       []string     `json:"nodes"`
+	Results     []CallResult `json:"results"`
+	Divergences int          `json:"divergences"`
+}
+
+// Mirror sends each of [calls] to every running node of [nw] and groups
+// the nodes by response. A call is divergent if the nodes don't all give
+// the same response. Nodes failing to answer are part of the diff.
+func Mirror(ctx context.Context, nw network.Network, calls []Call) (*Report, error) {
+	uris, err := nodeURIs(nw)
+	if err != nil {
+		return nil, err
+	}
+	report := &Report{
+		Results: make([]CallResult, 0, len(calls)),
+	}
+	for nodeName := range uris {
+		report.Nodes = append(report.Nodes, nodeName)
+	}
+	sort.Strings(report.Nodes)
+	for _, call := range calls {
+		responses := make(map[string]string, len(uris))
+		for nodeName, uri := range uris {
+			result, err := doCall(ctx, uri, call)
+			if err != nil {
+				responses[nodeName] = "error: " + err.Error()
+				continue
+			}
+			responses[nodeName] = string(result)
+		}
+		name := call.Name
+		if name == "" {
+			name = call.Method
+		}
+		result := compareResponses(name, responses)
+		if result.Divergent {
+			report.Divergences++
+		}
+		report.Results = append(report.Results, result)
+	}
+	return report, nil
+}
+
+// EVMCalls returns the calls comparing, on every node, the last
+// [numBlocks] blocks (10 if 0) and the balances of [addresses] of the EVM
+// chain served at [endpoint] (e.g. "/ext/bc/C/rpc"), up to the lowest
+// height of the running nodes so all of them have the compared blocks.
+func EVMCalls(ctx context.Context, nw network.Network, endpoint string, addresses []string, numBlocks uint64) ([]Call, error) {
+	if numBlocks == 0 {
+		numBlocks = defaultNumBlocks
+	}
+	uris, err := nodeURIs(nw)
+	if err != nil {
+		return nil, err
+	}
+	var minHeight *big.Int
+	for nodeName, uri := range uris {
+		result, err := doCall(ctx, uri, Call{Endpoint: endpoint, Method: "eth_blockNumber"})
+		if err != nil {
+			return nil, fmt.Errorf("couldn't get height of node %q: %w", nodeName, err)
+		}
+		height, err := parseHexQuantity(result)
+		if err != nil {
+			return nil, fmt.Errorf("couldn't parse height of node %q: %w", nodeName, err)
+		}
+		if minHeight == nil || height.Cmp(minHeight) < 0 {
+			minHeight = height
+		}
+	}
+	blockNumber := fmt.Sprintf("0x%x", minHeight)
+	calls := []Call{}
+	for i := uint64(0); i < numBlocks && minHeight.Cmp(new(big.Int).SetUint64(i)) >= 0; i++ {
+		height := new(big.Int).Sub(minHeight, new(big.Int).SetUint64(i))
+		calls = append(calls, Call{
+			Name:     fmt.Sprintf("block %s", height),
+			Endpoint: endpoint,
+			Method:   "eth_getBlockByNumber",
+			Params:   []interface{}{fmt.Sprintf("0x%x", height), true},
+		})
+	}
+	for _, address := range addresses {
+		calls = append(calls, Call{
+			Name:     fmt.Sprintf("balance %s at %s", address, minHeight),
+			Endpoint: endpoint,
+			Method:   "eth_getBalance",
+			Params:   []interface{}{address, blockNumber},
+		})
+	}
+	return calls, nil
+}
+
+// Groups the nodes of [responses] by identical response
+func compareResponses(name string, responses map[string]string) CallResult {
+	byResponse := map[string][]string{}
+	for nodeName, response := range responses {
+		byResponse[response] = append(byResponse[response], nodeName)
+	}
+	groups := make([][]string, 0, len(byResponse))
+	for _, nodeNames := range byResponse {
+		sort.Strings(nodeNames)
+		groups = append(groups, nodeNames)
+	}
+	sort.Slice(groups, func(i, j int) bool {
+		if len(groups[i]) != len(groups[j]) {
+			return len(groups[i]) > len(groups[j])
+		}
+		return groups[i][0] < groups[j][0]
+	})
+	return CallResult{
+		Name:      name,
+		Responses: responses,
+		Groups:    groups,
+		Divergent: len(groups) > 1,
+	}
+}
+
+// Returns the API base URI of each running node of [nw]
+func nodeURIs(nw network.Network) (map[string]string, error) {
+	nodes, err := nw.GetAllNodes()
+	if err != nil {
+		return nil, err
+	}
+	uris := map[string]string{}
+	for nodeName, n := range nodes {
+		if !n.GetPaused() {
+			uris[nodeName] = n.GetAPIBaseURI()
+		}
+	}
+	if len(uris) == 0 {
+		return nil, ErrNoRunningNode
+	}
+	return uris, nil
+}
+
+type rpcRequest struct {
+	JSONRPC string        `json:"jsonrpc"`
+	ID      int           `json:"id"`
+	Method  string        `json:"method"`
+	Params  []interface{} `json:"params"`
+}
+
+type rpcResponse struct {
+	Result json.RawMessage `json:"result"`
+	Error  *struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+// Sends [call] to the node at [uri], returning its result as canonical JSON
+func doCall(ctx context.Context, uri string, call Call) ([]byte, error) {
+	params := call.Params
+	if params == nil {
+		params = []interface{}{}
+	}
+	body, err := json.Marshal(rpcRequest{
+		JSONRPC: "2.0",
+		ID:      1,
+		Method:  call.Method,
+		Params:  params,
+	})
+	if err != nil {
+		return nil, err
+	}
+	endpoint := "/" + strings.TrimPrefix(call.Endpoint, "/")
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, uri+endpoint, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	respBody, err := io.ReadAll(io.LimitReader(resp.Body, maxResponseSize))
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	rpcResp := rpcResponse{}
+	if err := json.Unmarshal(respBody, &rpcResp); err != nil {
+		return nil, err
+	}
+	if rpcResp.Error != nil {
+		return nil, fmt.Errorf("%w %d: %s", errRPC, rpcResp.Error.Code, rpcResp.Error.Message)
+	}
+	return canonicalJSON(rpcResp.Result)
+}
+
+// Returns [b] re-encoded with sorted object keys and no whitespace
+func canonicalJSON(b []byte) ([]byte, error) {
+	var v interface{}
+	decoder := json.NewDecoder(bytes.NewReader(b))
+	decoder.UseNumber()
+	if err := decoder.Decode(&v); err != nil {
+		return nil, err
+	}
+	return json.Marshal(v)
+}
+
+func parseHexQuantity(b []byte) (*big.Int, error) {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return nil, err
+	}
+	n, ok := new(big.Int).SetString(strings.TrimPrefix(s, "0x"), 16)
+	if !ok {
+		return nil, fmt.Errorf("invalid hex quantity %q", s)
+	}
+	return n, nil
+}
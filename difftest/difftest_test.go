@@ -0,0 +1,40 @@
+// Copyright (C) 2021-2024, Lux Partners Limited. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package difftest
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCompareResponses(t *testing.T) {
+	require := require.New(t)
+
+	result := compareResponses("block 5", map[string]string{
+		"node1": `{"hash":"0x1"}`,
+		"node2": `{"hash":"0x2"}`,
+		"node3": `{"hash":"0x1"}`,
+		"node4": "error: timeout",
+	})
+	require.True(result.Divergent)
+	require.Equal([][]string{{"node1", "node3"}, {"node2"}, {"node4"}}, result.Groups)
+
+	result = compareResponses("balance", map[string]string{
+		"node1": `"0x10"`,
+		"node2": `"0x10"`,
+	})
+	require.False(result.Divergent)
+	require.Equal([][]string{{"node1", "node2"}}, result.Groups)
+}
+
+func TestCanonicalJSON(t *testing.T) {
+	require := require.New(t)
+
+	a, err := canonicalJSON([]byte(`{"b": 1, "a": {"d": 12345678901234567890, "c": [1, 2]}}`))
+	require.NoError(err)
+	b, err := canonicalJSON([]byte(`{"a":{"c":[1,2],"d":12345678901234567890},"b":1}`))
+	require.NoError(err)
+	require.Equal(string(a), string(b))
+}
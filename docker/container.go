@@ -0,0 +1,198 @@
+// Copyright (C) 2021-2024, Lux Partners Limited. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package docker
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"sync"
+
+	"github.com/luxdefi/netrunner/local"
+	"github.com/luxdefi/netrunner/network/node/status"
+	"github.com/luxdefi/netrunner/utils"
+	"github.com/luxdefi/node/utils/logging"
+	"go.uber.org/zap"
+)
+
+var _ local.FreezableNodeProcess = (*container)(nil)
+
+// container is the process of a node running in a container, as
+// the docker client attached to it, which exits with the node
+type container struct {
+	name string
+	log  logging.Logger
+	lock sync.RWMutex
+	// runs the docker client attached to the container
+	cmd *exec.Cmd
+	// Process status
+	state status.Status
+	// true if the container is paused
+	frozen bool
+	// Closed when the container exits.
+	closedOnStop chan struct{}
+}
+
+func newContainer(nodeName string, name string, log logging.Logger, cmd *exec.Cmd) (*container, error) {
+	c := &container{
+		name:         name,
+		log:          utils.WithFields(log, utils.NodeField(nodeName), zap.String("container", name)),
+		cmd:          cmd,
+		closedOnStop: make(chan struct{}),
+	}
+	return c, c.start()
+}
+
+// Start the container.
+// Must only be called once.
+func (c *container) start() error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.state = status.Running
+	if err := c.cmd.Start(); err != nil {
+		c.state = status.Stopped
+		close(c.closedOnStop)
+		return fmt.Errorf("couldn't start container: %w", err)
+	}
+
+	go c.awaitExit()
+	return nil
+}
+
+// Wait for the container to exit.
+// When it does, update the state and close [c.closedOnStop]
+func (c *container) awaitExit() {
+	if err := c.cmd.Wait(); err != nil {
+		c.log.Debug("node container returned error on wait", zap.Error(err))
+	}
+
+	c.log.Debug("node container finished")
+
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.state = status.Stopped
+	close(c.closedOnStop)
+}
+
+// Stop sends a SIGINT to the node in the container and returns its exit
+// code. If [ctx] is cancelled, the container is killed.
+func (c *container) Stop(ctx context.Context) int {
+	c.lock.Lock()
+
+	// The container is already stopped.
+	if c.state == status.Stopped {
+		exitCode := c.cmd.ProcessState.ExitCode()
+		c.lock.Unlock()
+		return exitCode
+	}
+
+	// There's another call to Stop executing right now.
+	// Wait for it to finish.
+	if c.state == status.Stopping {
+		c.lock.Unlock()
+		<-c.closedOnStop
+		c.lock.RLock()
+		defer c.lock.RUnlock()
+
+		return c.cmd.ProcessState.ExitCode()
+	}
+
+	c.state = status.Stopping
+	proc := c.cmd.Process
+	frozen := c.frozen
+	c.frozen = false
+	// We have to unlock here so that [c.awaitExit] can grab the lock
+	// and close [c.closedOnStop].
+	c.lock.Unlock()
+
+	if frozen {
+		// a paused container doesn't get signals
+		if err := runDocker("unpause", c.name); err != nil {
+			c.log.Warn("unpausing container errored", zap.Error(err))
+		}
+	}
+
+	if err := runDocker("kill", "--signal", "SIGINT", c.name); err != nil {
+		// the container may not be created yet, the docker
+		// client forwards the signal to it once it is
+		c.log.Warn("sending SIGINT to container errored", zap.Error(err))
+		if err := proc.Signal(os.Interrupt); err != nil {
+			c.log.Warn("sending SIGINT errored", zap.Error(err))
+		}
+	}
+
+	select {
+	case <-ctx.Done():
+		c.log.Warn("context cancelled while waiting for node container to stop")
+		if err := runDocker("kill", c.name); err != nil {
+			c.log.Warn("killing container errored", zap.Error(err))
+			if err := proc.Signal(os.Kill); err != nil {
+				c.log.Warn("sending SIGKILL errored", zap.Error(err))
+			}
+		}
+	case <-c.closedOnStop:
+	}
+
+	<-c.closedOnStop
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+
+	return c.cmd.ProcessState.ExitCode()
+}
+
+// Freeze pauses the container, freezing its processes
+func (c *container) Freeze() error {
+	return c.setPaused(true)
+}
+
+// Unfreeze unpauses the container
+func (c *container) Unfreeze() error {
+	return c.setPaused(false)
+}
+
+func (c *container) setPaused(paused bool) error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	if c.state != status.Running {
+		return fmt.Errorf("node container is %s", c.state)
+	}
+	cmd := "unpause"
+	if paused {
+		cmd = "pause"
+	}
+	if err := runDocker(cmd, c.name); err != nil {
+		return err
+	}
+	c.frozen = paused
+	return nil
+}
+
+func (c *container) Status() status.Status {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+
+	return c.state
+}
+
+// PID returns the ID of the docker client process attached to the container
+func (c *container) PID() int {
+	if c.cmd.Process == nil {
+		return 0
+	}
+	return c.cmd.Process.Pid
+}
+
+func (c *container) ExitCode() int {
+	c.lock.RLock()
+	defer c.lock.RUnlock()
+
+	if c.state != status.Stopped {
+		return -1
+	}
+	return c.cmd.ProcessState.ExitCode()
+}
@@ -0,0 +1,305 @@
+// Copyright (C) 2021-2024, Lux Partners Limited. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+// Package docker runs the nodes of a network as docker containers instead
+// of local processes. Its networks are local networks whose node processes
+// are containers, so they implement network.Network and node.Node the same
+// way, with the node dirs mounted into the containers at the same paths.
+package docker
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/luxdefi/netrunner/local"
+	"github.com/luxdefi/netrunner/network"
+	"github.com/luxdefi/netrunner/network/node"
+	"github.com/luxdefi/netrunner/utils"
+	"github.com/luxdefi/node/config"
+	"github.com/luxdefi/node/utils/logging"
+)
+
+const (
+	// Docker network sharing the network stack of the host, so that the
+	// nodes reach each other, and are reached by the runner, as processes
+	dockerNetworkHost = "host"
+
+	dockerBinary = "docker"
+	defaultTag   = "latest"
+	// prefix of the names of the node containers
+	containerPrefix = "netrunner-"
+)
+
+var (
+	_ local.NodeProcessCreator = (*containerCreator)(nil)
+
+	ErrNoImage = errors.New("no image given")
+)
+
+// Options of the node containers
+type Options struct {
+	// Image of the nodes without one in their config (e.g. "luxdefi/node")
+	Image string
+	// Tag of the image. Defaults to "latest".
+	ImageTag string
+	// Docker network of the containers. Defaults to the host network.
+	// On other networks, the API and P2P ports of the nodes are published
+	// on the host at the same port numbers, and the nodes must be given a
+	// public IP their peers reach the host at.
+	DockerNetwork string
+}
+
+// NewNetwork returns a new network running its nodes as containers.
+// See local.NewNetwork.
+func NewNetwork(
+	log logging.Logger,
+	networkConfig network.Config,
+	rootDir string,
+	snapshotsDir string,
+	reassignPortsIfUsed bool,
+	opts Options,
+) (network.Network, error) {
+	if _, err := exec.LookPath(dockerBinary); err != nil {
+		return nil, fmt.Errorf("couldn't find docker: %w", err)
+	}
+	if opts.ImageTag != "" && opts.Image == "" {
+		return nil, errors.New("image tag given without an image")
+	}
+	if opts.DockerNetwork == "" {
+		opts.DockerNetwork = dockerNetworkHost
+	}
+	return local.NewNetworkWithNodeProcessCreator(
+		log,
+		networkConfig,
+		rootDir,
+		snapshotsDir,
+		reassignPortsIfUsed,
+		&containerCreator{
+			log:         log,
+			opts:        opts,
+			colorPicker: utils.NewColorPicker(),
+			stdout:      os.Stdout,
+			stderr:      os.Stderr,
+		},
+	)
+}
+
+type containerCreator struct {
+	log  logging.Logger
+	opts Options
+	// If this node's stdout or stderr are redirected, [colorPicker] determines
+	// the color of logs printed to stdout and/or stderr
+	colorPicker utils.ColorPicker
+	stdout      io.Writer
+	stderr      io.Writer
+}
+
+// GetNodeVersion gets the version of the node of the image as per --version flag
+func (cc *containerCreator) GetNodeVersion(nodeConfig node.Config) (string, error) {
+	image, err := cc.image(nodeConfig)
+	if err != nil {
+		return "", err
+	}
+	out, err := exec.Command(dockerBinary, "run", "--rm", image, "--version").Output() //nolint
+	if err != nil {
+		return "", fmt.Errorf("couldn't run image %q: %w", image, err)
+	}
+	return string(out), nil
+}
+
+// NewNodeProcess starts a container of the node image with [args].
+// If the config has redirection set to `true` for either StdErr or StdOut,
+// the output of the container will be redirected and colored
+func (cc *containerCreator) NewNodeProcess(nodeConfig node.Config, args ...string) (local.NodeProcess, error) {
+	image, err := cc.image(nodeConfig)
+	if err != nil {
+		return nil, err
+	}
+	if nodeConfig.Umask != "" {
+		cc.log.Warn("umask is not applied to node containers", utils.NodeField(nodeConfig.Name))
+	}
+	containerName, err := newContainerName(nodeConfig.Name)
+	if err != nil {
+		return nil, err
+	}
+	mounts, err := mountDirs(nodeConfig.WorkDir, args)
+	if err != nil {
+		return nil, err
+	}
+	cmd := exec.Command(dockerBinary, runArgs(nodeConfig, containerName, image, cc.opts.DockerNetwork, mounts, args)...) //nolint
+	color := cc.colorPicker.NextColor()
+	if nodeConfig.RedirectStdout {
+		stdout, err := cmd.StdoutPipe()
+		if err != nil {
+			return nil, fmt.Errorf("couldn't create stdout pipe: %w", err)
+		}
+		utils.ColorAndPrepend(stdout, cc.stdout, nodeConfig.Name, color)
+	}
+	if nodeConfig.RedirectStderr {
+		stderr, err := cmd.StderrPipe()
+		if err != nil {
+			return nil, fmt.Errorf("couldn't create stderr pipe: %w", err)
+		}
+		utils.ColorAndPrepend(stderr, cc.stderr, nodeConfig.Name, color)
+	}
+	return newContainer(nodeConfig.Name, containerName, cc.log, cmd)
+}
+
+// Returns the image of the node, with its tag
+func (cc *containerCreator) image(nodeConfig node.Config) (string, error) {
+	image, tag := nodeConfig.Image, nodeConfig.ImageTag
+	if image == "" {
+		image, tag = cc.opts.Image, cc.opts.ImageTag
+	}
+	if image == "" {
+		return "", fmt.Errorf("%w for node %q", ErrNoImage, nodeConfig.Name)
+	}
+	if tag == "" {
+		tag = defaultTag
+	}
+	return image + ":" + tag, nil
+}
+
+// Returns a container name unique across networks
+// with nodes of the same name
+func newContainerName(nodeName string) (string, error) {
+	suffix := make([]byte, 4)
+	if _, err := rand.Read(suffix); err != nil {
+		return "", err
+	}
+	return containerPrefix + nodeName + "-" + hex.EncodeToString(suffix), nil
+}
+
+// Returns the docker run args of a container of [image] running the node
+// with [args]. The container runs as the current user, so that the files
+// it writes in the [mounts] are the runner's.
+func runArgs(
+	nodeConfig node.Config,
+	containerName string,
+	image string,
+	dockerNetwork string,
+	mounts []string,
+	args []string,
+) []string {
+	runArgs := []string{
+		"run",
+		"--rm",
+		"--name", containerName,
+		"--network", dockerNetwork,
+		"--user", fmt.Sprintf("%d:%d", os.Getuid(), os.Getgid()),
+	}
+	if limits := nodeConfig.Resources; limits != nil {
+		if limits.CPUs > 0 {
+			runArgs = append(runArgs, "--cpus", strconv.FormatFloat(limits.CPUs, 'f', -1, 64))
+		}
+		if memory, err := limits.MemoryBytes(); err == nil && memory > 0 {
+			runArgs = append(runArgs, "--memory", strconv.FormatUint(memory, 10))
+		}
+		if limits.MaxOpenFiles > 0 {
+			runArgs = append(runArgs, "--ulimit", fmt.Sprintf("nofile=%d:%d", limits.MaxOpenFiles, limits.MaxOpenFiles))
+		}
+	}
+	if nodeConfig.WorkDir != "" {
+		runArgs = append(runArgs, "--workdir", nodeConfig.WorkDir)
+	}
+	for _, dir := range mounts {
+		runArgs = append(runArgs, "--volume", dir+":"+dir)
+	}
+	nodeArgs := args
+	if dockerNetwork != dockerNetworkHost {
+		// the node listens on all the container interfaces,
+		// its ports being published on the bind IP
+		nodeArgs = []string{}
+		for _, arg := range args {
+			flagName, value, _ := strings.Cut(strings.TrimPrefix(arg, "--"), "=")
+			switch flagName {
+			case config.HTTPPortKey, config.StakingPortKey:
+				publish := value + ":" + value
+				if nodeConfig.BindIP != "" {
+					publish = nodeConfig.BindIP + ":" + publish
+				}
+				runArgs = append(runArgs, "--publish", publish)
+			case config.HTTPHostKey, config.StakingHostKey:
+				continue
+			}
+			nodeArgs = append(nodeArgs, arg)
+		}
+		nodeArgs = append(nodeArgs,
+			fmt.Sprintf("--%s=0.0.0.0", config.HTTPHostKey),
+			fmt.Sprintf("--%s=0.0.0.0", config.StakingHostKey),
+		)
+	}
+	runArgs = append(runArgs, image)
+	return append(runArgs, nodeArgs...)
+}
+
+// Returns the dirs to mount into the container of a node running in
+// [workDir] with [args]: the dirs of the absolute paths given as flag
+// values, skipping the ones under another. Missing dirs given to
+// dir flags are created, so that docker doesn't create them as root.
+func mountDirs(workDir string, args []string) ([]string, error) {
+	dirs := map[string]struct{}{}
+	if workDir != "" {
+		dirs[filepath.Clean(workDir)] = struct{}{}
+	}
+	for _, arg := range args {
+		flagName, value, _ := strings.Cut(strings.TrimPrefix(arg, "--"), "=")
+		if !filepath.IsAbs(value) {
+			continue
+		}
+		value = filepath.Clean(value)
+		info, err := os.Stat(value)
+		switch {
+		case err == nil && info.IsDir():
+			dirs[value] = struct{}{}
+		case err == nil:
+			dirs[filepath.Dir(value)] = struct{}{}
+		case errors.Is(err, os.ErrNotExist) && strings.HasSuffix(flagName, "-dir"):
+			if err := os.MkdirAll(value, 0o750); err != nil {
+				return nil, fmt.Errorf("couldn't create dir of flag %q: %w", flagName, err)
+			}
+			dirs[value] = struct{}{}
+		}
+	}
+	sorted := make([]string, 0, len(dirs))
+	for dir := range dirs {
+		sorted = append(sorted, dir)
+	}
+	sort.Strings(sorted)
+	mounts := []string{}
+	for _, dir := range sorted {
+		if !underAny(dir, mounts) {
+			mounts = append(mounts, dir)
+		}
+	}
+	return mounts, nil
+}
+
+// Returns true if [dir] is or is under any of [dirs]
+func underAny(dir string, dirs []string) bool {
+	for _, d := range dirs {
+		rel, err := filepath.Rel(d, dir)
+		if err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return true
+		}
+	}
+	return false
+}
+
+// Returns the error of running docker with [args], with its output
+func runDocker(args ...string) error {
+	out, err := exec.Command(dockerBinary, args...).CombinedOutput() //nolint
+	if err != nil {
+		return fmt.Errorf("%w: %s", err, strings.TrimSpace(string(out)))
+	}
+	return nil
+}
@@ -0,0 +1,86 @@
+// Copyright (C) 2021-2024, Lux Partners Limited. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package docker
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/luxdefi/netrunner/network/node"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMountDirs(t *testing.T) {
+	require := require.New(t)
+
+	root := t.TempDir()
+	dataDir := filepath.Join(root, "node1")
+	require.NoError(os.MkdirAll(dataDir, 0o750))
+	keyFile := filepath.Join(dataDir, "staking.key")
+	require.NoError(os.WriteFile(keyFile, nil, 0o600))
+	pluginDir := filepath.Join(root, "node1-plugins")
+	dbDir := filepath.Join(root, "db", "node1")
+
+	mounts, err := mountDirs(dataDir, []string{
+		"--data-dir=" + dataDir,
+		"--staking-tls-key-file=" + keyFile,
+		"--plugin-dir=" + pluginDir,
+		"--db-dir=" + dbDir,
+		"--genesis-file=" + filepath.Join(root, "missing.json"),
+		"--http-port=9650",
+	})
+	require.NoError(err)
+	require.Equal([]string{dbDir, dataDir, pluginDir}, mounts)
+	require.DirExists(dbDir)
+	require.DirExists(pluginDir)
+}
+
+func TestRunArgs(t *testing.T) {
+	require := require.New(t)
+
+	user := fmt.Sprintf("%d:%d", os.Getuid(), os.Getgid())
+	args := []string{"--http-port=9650", "--staking-port=9651", "--http-host=127.0.0.1", "--data-dir=/data"}
+	nodeConfig := node.Config{WorkDir: "/data"}
+
+	require.Equal([]string{
+		"run", "--rm", "--name", "netrunner-node1", "--network", "host", "--user", user,
+		"--workdir", "/data", "--volume", "/data:/data",
+		"luxdefi/node:latest", "--http-port=9650", "--staking-port=9651", "--http-host=127.0.0.1", "--data-dir=/data",
+	}, runArgs(nodeConfig, "netrunner-node1", "luxdefi/node:latest", dockerNetworkHost, []string{"/data"}, args))
+
+	nodeConfig.BindIP = "127.0.0.2"
+	require.Equal([]string{
+		"run", "--rm", "--name", "netrunner-node1", "--network", "bridge", "--user", user,
+		"--workdir", "/data", "--volume", "/data:/data",
+		"--publish", "127.0.0.2:9650:9650", "--publish", "127.0.0.2:9651:9651",
+		"luxdefi/node:latest", "--http-port=9650", "--staking-port=9651", "--data-dir=/data",
+		"--http-host=0.0.0.0", "--staking-host=0.0.0.0",
+	}, runArgs(nodeConfig, "netrunner-node1", "luxdefi/node:latest", "bridge", []string{"/data"}, args))
+
+	nodeConfig.Resources = &node.ResourceLimits{CPUs: 1.5, Memory: "2GiB", MaxOpenFiles: 4096}
+	require.Equal([]string{
+		"run", "--rm", "--name", "netrunner-node1", "--network", "host", "--user", user,
+		"--cpus", "1.5", "--memory", "2147483648", "--ulimit", "nofile=4096:4096",
+		"--workdir", "/data", "--volume", "/data:/data",
+		"luxdefi/node:latest", "--http-port=9650", "--staking-port=9651", "--http-host=127.0.0.1", "--data-dir=/data",
+	}, runArgs(nodeConfig, "netrunner-node1", "luxdefi/node:latest", dockerNetworkHost, []string{"/data"}, args))
+}
+
+func TestImage(t *testing.T) {
+	require := require.New(t)
+
+	cc := &containerCreator{opts: Options{Image: "luxdefi/node", ImageTag: "v1.10.0"}}
+	image, err := cc.image(node.Config{})
+	require.NoError(err)
+	require.Equal("luxdefi/node:v1.10.0", image)
+	image, err = cc.image(node.Config{Image: "custom/node"})
+	require.NoError(err)
+	require.Equal("custom/node:latest", image)
+
+	cc = &containerCreator{}
+	_, err = cc.image(node.Config{Name: "node1"})
+	require.ErrorIs(err, ErrNoImage)
+}
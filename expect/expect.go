@@ -0,0 +1,260 @@
+// Copyright (C) 2021-2024, Lux Partners Limited. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+// Package expect evaluates assertions on a network over time windows
+// started by the events of a run (e.g. "within 60s of partition-heal,
+// the C-Chain heights of all nodes converge within 2 blocks"), recording
+// whether they pass as steps of the run report.
+package expect
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/luxdefi/netrunner/network"
+	"github.com/luxdefi/netrunner/report"
+	"gopkg.in/yaml.v3"
+)
+
+// Conditions of an assertion
+const (
+	// The heights of the chain on all running nodes differ by at most the tolerance
+	ConditionHeightsConverge = "heights-converge"
+	// The network is healthy
+	ConditionHealthy = "healthy"
+)
+
+// how often a condition is checked within the assertion window
+const checkFreq = time.Second
+
+var ErrInvalidAssertion = errors.New("invalid assertion")
+
+// Assertion is a condition that must hold on the network within a time window
+type Assertion struct {
+	Name string `yaml:"name" json:"name"`
+	// Kind of the event starting the window each time it happens.
+	// If empty, the window starts when the assertion is added.
+	After  string        `yaml:"after" json:"after,omitempty"`
+	Within time.Duration `yaml:"within" json:"within"`
+	// One of the Condition constants
+	Condition string `yaml:"condition" json:"condition"`
+	// Chain alias of ConditionHeightsConverge, "P" or "C". Defaults to "C".
+	Chain string `yaml:"chain" json:"chain,omitempty"`
+	// Max height difference between nodes of ConditionHeightsConverge
+	Tolerance uint64 `yaml:"tolerance" json:"tolerance,omitempty"`
+}
+
+// Validate returns an error if the assertion is invalid
+func (a *Assertion) Validate() error {
+	switch {
+	case a.Name == "":
+		return fmt.Errorf("%w: missing name", ErrInvalidAssertion)
+	case a.Within <= 0:
+		return fmt.Errorf("%w %q: window must be positive", ErrInvalidAssertion, a.Name)
+	}
+	switch a.Condition {
+	case ConditionHeightsConverge:
+		if a.Chain != "" && a.Chain != "P" && a.Chain != "C" {
+			return fmt.Errorf("%w %q: unknown chain %q", ErrInvalidAssertion, a.Name, a.Chain)
+		}
+	case ConditionHealthy:
+	default:
+		return fmt.Errorf("%w %q: unknown condition %q", ErrInvalidAssertion, a.Name, a.Condition)
+	}
+	return nil
+}
+
+// ParseYAML returns the assertions of a YAML document of the form
+//
+//	assertions:
+//	  - name: heights converge after heal
+//	    after: partition-heal
+//	    within: 60s
+//	    condition: heights-converge
+//	    tolerance: 2
+func ParseYAML(b []byte) ([]Assertion, error) {
+	doc := struct {
+		Assertions []Assertion `yaml:"assertions"`
+	}{}
+	if err := yaml.Unmarshal(b, &doc); err != nil {
+		return nil, err
+	}
+	for i := range doc.Assertions {
+		if err := doc.Assertions[i].Validate(); err != nil {
+			return nil, err
+		}
+	}
+	return doc.Assertions, nil
+}
+
+// Engine evaluates assertions on the events of a run, recording the
+// result of each evaluation as a step of its report, failed if the
+// condition didn't hold within the window. It is safe for concurrent use.
+type Engine struct {
+	report *report.Report
+
+	lock sync.Mutex
+	// event kind --> assertions whose window it starts
+	armed map[string][]Assertion
+	// evaluations in progress
+	evaluations sync.WaitGroup
+}
+
+// NewEngine returns an engine recording into [r]
+func NewEngine(r *report.Report) *Engine {
+	return &Engine{
+		report: r,
+		armed:  map[string][]Assertion{},
+	}
+}
+
+// Report returns the run report of the engine
+func (e *Engine) Report() *report.Report {
+	return e.report
+}
+
+// Add registers [assertions]. The ones without an event
+// are evaluated on [nw] right away.
+func (e *Engine) Add(nw network.Network, assertions ...Assertion) error {
+	for i := range assertions {
+		if err := assertions[i].Validate(); err != nil {
+			return err
+		}
+	}
+
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	for _, a := range assertions {
+		if a.After == "" {
+			e.evaluate(nw, a)
+			continue
+		}
+		e.armed[a.After] = append(e.armed[a.After], a)
+	}
+	return nil
+}
+
+// Event records an event of [kind] for [nodeName] (which may be empty)
+// in the report, and evaluates on [nw] the assertions it starts
+func (e *Engine) Event(nw network.Network, nodeName string, kind string, msg string) {
+	e.report.AddEvent(nodeName, kind, msg)
+
+	e.lock.Lock()
+	defer e.lock.Unlock()
+
+	for _, a := range e.armed[kind] {
+		e.evaluate(nw, a)
+	}
+}
+
+// Wait returns once the evaluations in progress are done
+func (e *Engine) Wait() {
+	e.evaluations.Wait()
+}
+
+// Starts the evaluation of [a] on [nw], as a report step
+func (e *Engine) evaluate(nw network.Network, a Assertion) {
+	e.evaluations.Add(1)
+	go func() {
+		defer e.evaluations.Done()
+
+		_ = e.report.RunStep(a.Name, func() error {
+			ctx, cancel := context.WithTimeout(context.Background(), a.Within)
+			defer cancel()
+			return await(ctx, nw, a)
+		})
+	}()
+}
+
+// Returns nil once the condition of [a] holds, or the
+// reason it doesn't if it still doesn't when [ctx] is done
+func await(ctx context.Context, nw network.Network, a Assertion) error {
+	for {
+		err := check(ctx, nw, a)
+		if err == nil {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("not met within %s: %w", a.Within, err)
+		case <-time.After(checkFreq):
+		}
+	}
+}
+
+func check(ctx context.Context, nw network.Network, a Assertion) error {
+	switch a.Condition {
+	case ConditionHealthy:
+		return nw.Healthy(ctx)
+	case ConditionHeightsConverge:
+		heights, err := getHeights(ctx, nw, a.Chain)
+		if err != nil {
+			return err
+		}
+		return checkHeights(heights, a.Tolerance)
+	default:
+		return fmt.Errorf("%w: unknown condition %q", ErrInvalidAssertion, a.Condition)
+	}
+}
+
+// Returns the height of [chain] on each running node of [nw]
+func getHeights(ctx context.Context, nw network.Network, chain string) (map[string]uint64, error) {
+	nodes, err := nw.GetAllNodes()
+	if err != nil {
+		return nil, err
+	}
+	heights := map[string]uint64{}
+	for nodeName, n := range nodes {
+		if n.GetPaused() {
+			continue
+		}
+		var height uint64
+		if chain == "P" {
+			height, err = n.GetAPIClient().PChainAPI().GetHeight(ctx)
+		} else {
+			height, err = n.GetAPIClient().CChainEthAPI().BlockNumber(ctx)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("couldn't get height of node %q: %w", nodeName, err)
+		}
+		heights[nodeName] = height
+	}
+	return heights, nil
+}
+
+// Returns an error if [heights] differ by more than [tolerance]
+func checkHeights(heights map[string]uint64, tolerance uint64) error {
+	if len(heights) == 0 {
+		return errors.New("no running node")
+	}
+	first := true
+	var minHeight, maxHeight uint64
+	for _, height := range heights {
+		if first || height < minHeight {
+			minHeight = height
+		}
+		if first || height > maxHeight {
+			maxHeight = height
+		}
+		first = false
+	}
+	if maxHeight-minHeight <= tolerance {
+		return nil
+	}
+	nodeNames := make([]string, 0, len(heights))
+	for nodeName := range heights {
+		nodeNames = append(nodeNames, nodeName)
+	}
+	sort.Strings(nodeNames)
+	s := make([]string, len(nodeNames))
+	for i, nodeName := range nodeNames {
+		s[i] = fmt.Sprintf("%s=%d", nodeName, heights[nodeName])
+	}
+	return fmt.Errorf("heights differ by %d, over %d: %s", maxHeight-minHeight, tolerance, strings.Join(s, " "))
+}
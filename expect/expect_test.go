@@ -0,0 +1,56 @@
+// Copyright (C) 2021-2024, Lux Partners Limited. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package expect
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseYAML(t *testing.T) {
+	require := require.New(t)
+
+	assertions, err := ParseYAML([]byte(`
+assertions:
+  - name: heights converge after heal
+    after: partition-heal
+    within: 60s
+    condition: heights-converge
+    tolerance: 2
+  - name: healthy
+    within: 5m
+    condition: healthy
+`))
+	require.NoError(err)
+	require.Equal([]Assertion{
+		{
+			Name:      "heights converge after heal",
+			After:     "partition-heal",
+			Within:    time.Minute,
+			Condition: ConditionHeightsConverge,
+			Tolerance: 2,
+		},
+		{
+			Name:      "healthy",
+			Within:    5 * time.Minute,
+			Condition: ConditionHealthy,
+		},
+	}, assertions)
+
+	_, err = ParseYAML([]byte("assertions:\n  - name: x\n    within: 1s\n    condition: unknown\n"))
+	require.ErrorIs(err, ErrInvalidAssertion)
+	_, err = ParseYAML([]byte("assertions:\n  - name: x\n    condition: healthy\n"))
+	require.ErrorIs(err, ErrInvalidAssertion)
+}
+
+func TestCheckHeights(t *testing.T) {
+	require := require.New(t)
+
+	require.NoError(checkHeights(map[string]uint64{"node1": 10, "node2": 12, "node3": 11}, 2))
+	err := checkHeights(map[string]uint64{"node1": 10, "node2": 13}, 2)
+	require.ErrorContains(err, "node1=10 node2=13")
+	require.Error(checkHeights(map[string]uint64{}, 2))
+}
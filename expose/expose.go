@@ -0,0 +1,194 @@
+// Copyright (C) 2021-2024, Lux Partners Limited. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+// Package expose generates reverse proxy configurations that publish
+// selected node RPC endpoints on a stable hostname, so a long-running
+// network can be shared with a team.
+package expose
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+	"text/template"
+
+	"github.com/luxdefi/netrunner/network"
+)
+
+var (
+	ErrNoHostname = errors.New("no hostname given")
+	ErrNoRoutes   = errors.New("no endpoints selected to be exposed")
+)
+
+// Config defines how endpoints are exposed
+type Config struct {
+	// Public hostname the proxy answers to (e.g. devnet.example.com)
+	Hostname string
+	// TLS cert and key paths. Required by nginx.
+	// If empty for caddy, automatic HTTPS is used.
+	TLSCertFile string
+	TLSKeyFile  string
+	// Port the proxy listens on. Defaults to 443.
+	ListenPort uint16
+	// If non-nil, only the endpoints for which it returns true are exposed
+	Filter func(network.Endpoint) bool
+}
+
+// Route maps a public path to a node endpoint
+type Route struct {
+	// Public path, of the form /<node name>/<endpoint path>
+	Path string
+	// Upstream URL the path is proxied to
+	Upstream string
+	// True if the route carries websocket connections
+	Websocket bool
+}
+
+// Routes returns the public routes for the endpoints in [catalog]
+// accepted by [filter]. Routes are sorted by path.
+func Routes(catalog network.EndpointCatalog, filter func(network.Endpoint) bool) ([]Route, error) {
+	routes := []Route{}
+	for _, endpoint := range catalog.Endpoints {
+		if filter != nil && !filter(endpoint) {
+			continue
+		}
+		u, err := url.Parse(endpoint.URL)
+		if err != nil {
+			return nil, fmt.Errorf("invalid endpoint url %q: %w", endpoint.URL, err)
+		}
+		upstream := *u
+		// websocket upstreams are given to the proxy as http(s) urls
+		switch upstream.Scheme {
+		case "ws":
+			upstream.Scheme = "http"
+		case "wss":
+			upstream.Scheme = "https"
+		}
+		routes = append(routes, Route{
+			Path:      "/" + endpoint.NodeName + u.Path,
+			Upstream:  upstream.String(),
+			Websocket: endpoint.Protocol == "ws",
+		})
+	}
+	sort.Slice(routes, func(i, j int) bool {
+		return routes[i].Path < routes[j].Path
+	})
+	return routes, nil
+}
+
+type templateData struct {
+	Config
+	Routes []Route
+}
+
+var nginxTemplate = template.Must(template.New("nginx").Parse(`# generated by netrunner
+server {
+    listen {{ .ListenPort }} ssl;
+    server_name {{ .Hostname }};
+
+    ssl_certificate {{ .TLSCertFile }};
+    ssl_certificate_key {{ .TLSKeyFile }};
+{{ range .Routes }}
+    location = {{ .Path }} {
+        proxy_pass {{ .Upstream }};
+        proxy_set_header Host $host;
+        proxy_set_header X-Forwarded-For $proxy_add_x_forwarded_for;
+{{- if .Websocket }}
+        proxy_http_version 1.1;
+        proxy_set_header Upgrade $http_upgrade;
+        proxy_set_header Connection "upgrade";
+{{- end }}
+    }
+{{ end -}}
+}
+`))
+
+var caddyTemplate = template.Must(template.New("caddy").Funcs(template.FuncMap{
+	"upstreamHost": upstreamHost,
+	"upstreamPath": upstreamPath,
+}).Parse(`# generated by netrunner
+{{ .Hostname }}:{{ .ListenPort }} {
+{{- if .TLSCertFile }}
+    tls {{ .TLSCertFile }} {{ .TLSKeyFile }}
+{{- end }}
+{{ range .Routes }}
+    handle {{ .Path }} {
+        rewrite * {{ upstreamPath .Upstream }}
+        reverse_proxy {{ upstreamHost .Upstream }}
+    }
+{{- end }}
+}
+`))
+
+// NginxConfig returns an nginx server block exposing [catalog] as given by [cfg]
+func NginxConfig(catalog network.EndpointCatalog, cfg Config) (string, error) {
+	if cfg.TLSCertFile == "" || cfg.TLSKeyFile == "" {
+		return "", errors.New("nginx config requires TLS cert and key files")
+	}
+	return render(nginxTemplate, catalog, cfg)
+}
+
+// CaddyConfig returns a Caddyfile site block exposing [catalog] as given by [cfg]
+func CaddyConfig(catalog network.EndpointCatalog, cfg Config) (string, error) {
+	return render(caddyTemplate, catalog, cfg)
+}
+
+func render(tmpl *template.Template, catalog network.EndpointCatalog, cfg Config) (string, error) {
+	if cfg.Hostname == "" {
+		return "", ErrNoHostname
+	}
+	if cfg.ListenPort == 0 {
+		cfg.ListenPort = 443
+	}
+	routes, err := Routes(catalog, cfg.Filter)
+	if err != nil {
+		return "", err
+	}
+	if len(routes) == 0 {
+		return "", ErrNoRoutes
+	}
+	buf := bytes.Buffer{}
+	if err := tmpl.Execute(&buf, templateData{Config: cfg, Routes: routes}); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+func upstreamHost(upstream string) string {
+	u, err := url.Parse(upstream)
+	if err != nil {
+		return upstream
+	}
+	return u.Scheme + "://" + u.Host
+}
+
+func upstreamPath(upstream string) string {
+	u, err := url.Parse(upstream)
+	if err != nil || u.Path == "" {
+		return "/"
+	}
+	return u.Path
+}
+
+// FilterByNodes returns a filter accepting endpoints of the given nodes
+// and, if [chains] is not empty, only for the given chains
+func FilterByNodes(nodeNames []string, chains []string) func(network.Endpoint) bool {
+	return func(e network.Endpoint) bool {
+		if len(nodeNames) > 0 && !contains(nodeNames, e.NodeName) {
+			return false
+		}
+		return len(chains) == 0 || contains(chains, e.Chain)
+	}
+}
+
+func contains(l []string, s string) bool {
+	for _, e := range l {
+		if strings.EqualFold(e, s) {
+			return true
+		}
+	}
+	return false
+}
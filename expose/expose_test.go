@@ -0,0 +1,112 @@
+// Copyright (C) 2021-2024, Lux Partners Limited. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package expose
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/luxdefi/netrunner/network"
+	"github.com/stretchr/testify/require"
+)
+
+var testCatalog = network.EndpointCatalog{
+	Endpoints: []network.Endpoint{
+		{NodeName: "node2", Chain: "C", URL: "http://127.0.0.1:9652/ext/bc/C/rpc", Protocol: "http"},
+		{NodeName: "node1", Chain: "C", URL: "ws://127.0.0.1:9650/ext/bc/C/ws", Protocol: "ws"},
+		{NodeName: "node1", Chain: "C", URL: "http://127.0.0.1:9650/ext/bc/C/rpc", Protocol: "http"},
+		{NodeName: "node1", Chain: "info", URL: "http://127.0.0.1:9650/ext/info", Protocol: "http"},
+	},
+}
+
+func TestRoutes(t *testing.T) {
+	tests := []struct {
+		name           string
+		filter         func(network.Endpoint) bool
+		expectedRoutes []Route
+	}{
+		{
+			name: "all endpoints",
+			expectedRoutes: []Route{
+				{Path: "/node1/ext/bc/C/rpc", Upstream: "http://127.0.0.1:9650/ext/bc/C/rpc"},
+				{Path: "/node1/ext/bc/C/ws", Upstream: "http://127.0.0.1:9650/ext/bc/C/ws", Websocket: true},
+				{Path: "/node1/ext/info", Upstream: "http://127.0.0.1:9650/ext/info"},
+				{Path: "/node2/ext/bc/C/rpc", Upstream: "http://127.0.0.1:9652/ext/bc/C/rpc"},
+			},
+		},
+		{
+			name:   "filtered by node",
+			filter: FilterByNodes([]string{"node2"}, nil),
+			expectedRoutes: []Route{
+				{Path: "/node2/ext/bc/C/rpc", Upstream: "http://127.0.0.1:9652/ext/bc/C/rpc"},
+			},
+		},
+		{
+			name:   "filtered by chain, case insensitive",
+			filter: FilterByNodes(nil, []string{"INFO"}),
+			expectedRoutes: []Route{
+				{Path: "/node1/ext/info", Upstream: "http://127.0.0.1:9650/ext/info"},
+			},
+		},
+		{
+			name:           "filtered out",
+			filter:         FilterByNodes([]string{"node3"}, nil),
+			expectedRoutes: []Route{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require := require.New(t)
+			routes, err := Routes(testCatalog, tt.filter)
+			require.NoError(err)
+			require.Equal(tt.expectedRoutes, routes)
+		})
+	}
+}
+
+func TestNginxConfig(t *testing.T) {
+	require := require.New(t)
+
+	cfg := Config{
+		Hostname:    "devnet.example.com",
+		TLSCertFile: "/etc/cert.pem",
+		TLSKeyFile:  "/etc/key.pem",
+		Filter:      FilterByNodes([]string{"node1"}, []string{"C"}),
+	}
+	config, err := NginxConfig(testCatalog, cfg)
+	require.NoError(err)
+	require.Contains(config, "listen 443 ssl;")
+	require.Contains(config, "server_name devnet.example.com;")
+	require.Contains(config, "ssl_certificate /etc/cert.pem;")
+	require.Contains(config, "location = /node1/ext/bc/C/rpc {\n        proxy_pass http://127.0.0.1:9650/ext/bc/C/rpc;")
+	require.Contains(config, "location = /node1/ext/bc/C/ws {\n        proxy_pass http://127.0.0.1:9650/ext/bc/C/ws;")
+	require.Equal(1, strings.Count(config, "proxy_set_header Upgrade $http_upgrade;"))
+	require.NotContains(config, "/node2/")
+
+	cfg.TLSKeyFile = ""
+	_, err = NginxConfig(testCatalog, cfg)
+	require.Error(err)
+}
+
+func TestCaddyConfig(t *testing.T) {
+	require := require.New(t)
+
+	config, err := CaddyConfig(testCatalog, Config{
+		Hostname:   "devnet.example.com",
+		ListenPort: 8443,
+		Filter:     FilterByNodes([]string{"node2"}, nil),
+	})
+	require.NoError(err)
+	require.Contains(config, "devnet.example.com:8443 {")
+	require.NotContains(config, "tls ")
+	require.Contains(config, "handle /node2/ext/bc/C/rpc {\n        rewrite * /ext/bc/C/rpc\n        reverse_proxy http://127.0.0.1:9652\n    }")
+
+	_, err = CaddyConfig(testCatalog, Config{})
+	require.ErrorIs(err, ErrNoHostname)
+	_, err = CaddyConfig(testCatalog, Config{
+		Hostname: "devnet.example.com",
+		Filter:   FilterByNodes([]string{"node3"}, nil),
+	})
+	require.ErrorIs(err, ErrNoRoutes)
+}
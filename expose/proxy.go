@@ -0,0 +1,127 @@
+// Copyright (C) 2021-2024, Lux Partners Limited. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package expose
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/luxdefi/netrunner/network"
+	"github.com/luxdefi/netrunner/utils"
+	"github.com/luxdefi/node/utils/logging"
+	"go.uber.org/zap"
+)
+
+var errNodeNotFound = errors.New("node not found")
+
+// Proxy is a reverse proxy serving the API of every node of a network
+// under a single port, routing /<node name>/<path> to <node API>/<path>
+// (e.g. /node1/ext/bc/C/rpc). Websocket upgrades are proxied as well.
+type Proxy struct {
+	// Returns the endpoints of the network nodes
+	getCatalog func() (network.EndpointCatalog, error)
+	log        logging.Logger
+	// If not nil, traces the requests
+	tracer *Tracer
+}
+
+// NewProxy returns a proxy for the nodes listed by [getCatalog],
+// which is called on every request so added nodes are served.
+// Failed requests to the nodes are logged to [log].
+// If [tracer] is not nil, every request but websocket upgrades
+// is logged to [log] and traced in it.
+func NewProxy(log logging.Logger, getCatalog func() (network.EndpointCatalog, error), tracer *Tracer) *Proxy {
+	return &Proxy{
+		getCatalog: getCatalog,
+		log:        log,
+		tracer:     tracer,
+	}
+}
+
+func (p *Proxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	nodeName, path, _ := strings.Cut(strings.TrimPrefix(r.URL.Path, "/"), "/")
+	if nodeName == "" {
+		http.Error(w, "expected path of the form /<node name>/<path>", http.StatusNotFound)
+		return
+	}
+	catalog, err := p.getCatalog()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+	upstream, err := nodeUpstream(catalog, nodeName)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	r.URL.Path = "/" + path
+	r.URL.RawPath = ""
+	r.Host = upstream.Host
+	reverseProxy := httputil.NewSingleHostReverseProxy(upstream)
+	reverseProxy.ErrorHandler = func(w http.ResponseWriter, r *http.Request, err error) {
+		p.log.Debug("node API request failed",
+			utils.NodeField(nodeName),
+			zap.String("path", r.URL.Path),
+			zap.Error(err),
+		)
+		w.WriteHeader(http.StatusBadGateway)
+	}
+	if p.tracer == nil || r.Header.Get("Upgrade") != "" {
+		reverseProxy.ServeHTTP(w, r)
+		return
+	}
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	r.Body = io.NopCloser(bytes.NewReader(body))
+	recorder := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+	start := time.Now()
+	reverseProxy.ServeHTTP(recorder, r)
+	trace := Trace{
+		Time:       start,
+		NodeName:   nodeName,
+		Path:       r.URL.Path,
+		Method:     requestMethod(r.Method, body),
+		ParamsSize: len(body),
+		Latency:    time.Since(start),
+		Status:     recorder.status,
+	}
+	p.log.Info("node API request",
+		utils.NodeField(nodeName),
+		zap.String("path", trace.Path),
+		zap.String("method", trace.Method),
+		zap.Int("params-size", trace.ParamsSize),
+		zap.Duration("latency", trace.Latency),
+		zap.Int("status", trace.Status),
+	)
+	p.tracer.add(trace)
+}
+
+// Returns the base URL of the API of [nodeName] in [catalog]
+func nodeUpstream(catalog network.EndpointCatalog, nodeName string) (*url.URL, error) {
+	for _, endpoint := range catalog.Endpoints {
+		if endpoint.NodeName != nodeName {
+			continue
+		}
+		u, err := url.Parse(endpoint.URL)
+		if err != nil {
+			return nil, fmt.Errorf("invalid endpoint url %q: %w", endpoint.URL, err)
+		}
+		scheme := "http"
+		if endpoint.TLS {
+			scheme = "https"
+		}
+		return &url.URL{Scheme: scheme, Host: u.Host}, nil
+	}
+	return nil, fmt.Errorf("%w: %q", errNodeNotFound, nodeName)
+}
@@ -0,0 +1,113 @@
+// Copyright (C) 2021-2024, Lux Partners Limited. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package expose
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/luxdefi/netrunner/network"
+	"github.com/luxdefi/node/utils/logging"
+	"github.com/stretchr/testify/require"
+)
+
+// Returns a node API answering with its [name] and the request path
+func newTestNodeAPI(t *testing.T, name string) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.WriteString(w, name+" "+r.URL.Path)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestProxyRouting(t *testing.T) {
+	node1 := newTestNodeAPI(t, "node1")
+	node2 := newTestNodeAPI(t, "node2")
+	down := newTestNodeAPI(t, "down")
+	down.Close()
+	catalog := network.EndpointCatalog{
+		Endpoints: []network.Endpoint{
+			{NodeName: "node1", Chain: "C", URL: node1.URL + "/ext/bc/C/rpc", Protocol: "http"},
+			{NodeName: "node1", Chain: "info", URL: node1.URL + "/ext/info", Protocol: "http"},
+			{NodeName: "node2", Chain: "C", URL: "ws" + node2.URL[len("http"):] + "/ext/bc/C/ws", Protocol: "ws"},
+			{NodeName: "down", Chain: "info", URL: down.URL + "/ext/info", Protocol: "http"},
+		},
+	}
+	proxy := NewProxy(logging.NoLog{}, func() (network.EndpointCatalog, error) {
+		return catalog, nil
+	}, nil)
+
+	tests := []struct {
+		name           string
+		path           string
+		expectedStatus int
+		expectedBody   string
+	}{
+		{
+			name:           "chain endpoint",
+			path:           "/node1/ext/bc/C/rpc",
+			expectedStatus: http.StatusOK,
+			expectedBody:   "node1 /ext/bc/C/rpc",
+		},
+		{
+			name:           "path not in the catalog",
+			path:           "/node1/ext/health",
+			expectedStatus: http.StatusOK,
+			expectedBody:   "node1 /ext/health",
+		},
+		{
+			name:           "node with websocket endpoints only",
+			path:           "/node2/ext/info",
+			expectedStatus: http.StatusOK,
+			expectedBody:   "node2 /ext/info",
+		},
+		{
+			name:           "node root",
+			path:           "/node2",
+			expectedStatus: http.StatusOK,
+			expectedBody:   "node2 /",
+		},
+		{
+			name:           "unknown node",
+			path:           "/node3/ext/info",
+			expectedStatus: http.StatusNotFound,
+		},
+		{
+			name:           "no node",
+			path:           "/",
+			expectedStatus: http.StatusNotFound,
+		},
+		{
+			name:           "node down",
+			path:           "/down/ext/info",
+			expectedStatus: http.StatusBadGateway,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require := require.New(t)
+			w := httptest.NewRecorder()
+			proxy.ServeHTTP(w, httptest.NewRequest(http.MethodPost, tt.path, nil))
+			require.Equal(tt.expectedStatus, w.Code)
+			if tt.expectedBody != "" {
+				require.Equal(tt.expectedBody, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestProxyCatalogError(t *testing.T) {
+	require := require.New(t)
+
+	proxy := NewProxy(logging.NoLog{}, func() (network.EndpointCatalog, error) {
+		return network.EndpointCatalog{}, errors.New("network stopped")
+	}, nil)
+	w := httptest.NewRecorder()
+	proxy.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/node1/ext/info", nil))
+	require.Equal(http.StatusServiceUnavailable, w.Code)
+	require.Contains(w.Body.String(), "network stopped")
+}
@@ -0,0 +1,115 @@
+// Copyright (C) 2021-2024, Lux Partners Limited. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package expose
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Trace is the record of a request served by the proxy to a node API
+type Trace struct {
+	Time     time.Time `json:"time"`
+	NodeName string    `json:"nodeName"`
+	Path     string    `json:"path"`
+	// JSON-RPC method of the request (comma separated for batches),
+	// or else its HTTP method
+	Method string `json:"method"`
+	// Size of the request body
+	ParamsSize int           `json:"paramsSize"`
+	Latency    time.Duration `json:"latency"`
+	// HTTP status of the response, 502 if the node couldn't be reached
+	Status int `json:"status"`
+}
+
+// Tracer keeps the traces of the last requests served by a proxy.
+// It is safe for concurrent use.
+type Tracer struct {
+	lock sync.Mutex
+	// ring of the last traces, [next] being the oldest once full
+	traces []Trace
+	next   int
+	full   bool
+}
+
+// NewTracer returns a tracer keeping the last [size] traces
+func NewTracer(size int) *Tracer {
+	return &Tracer{
+		traces: make([]Trace, size),
+	}
+}
+
+func (t *Tracer) add(trace Trace) {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	if len(t.traces) == 0 {
+		return
+	}
+	t.traces[t.next] = trace
+	t.next = (t.next + 1) % len(t.traces)
+	if t.next == 0 {
+		t.full = true
+	}
+}
+
+// Traces returns the kept traces of the requests to [nodeName],
+// or to every node if empty, oldest first
+func (t *Tracer) Traces(nodeName string) []Trace {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	ordered := t.traces[:t.next]
+	if t.full {
+		ordered = append(append([]Trace{}, t.traces[t.next:]...), t.traces[:t.next]...)
+	}
+	traces := []Trace{}
+	for _, trace := range ordered {
+		if nodeName == "" || trace.NodeName == nodeName {
+			traces = append(traces, trace)
+		}
+	}
+	return traces
+}
+
+// Returns the JSON-RPC method of the request with [body], or else [httpMethod]
+func requestMethod(httpMethod string, body []byte) string {
+	request := struct {
+		Method string `json:"method"`
+	}{}
+	if err := json.Unmarshal(body, &request); err == nil && request.Method != "" {
+		return request.Method
+	}
+	batch := []struct {
+		Method string `json:"method"`
+	}{}
+	if err := json.Unmarshal(body, &batch); err == nil && len(batch) > 0 {
+		methods := make([]string, len(batch))
+		for i := range batch {
+			methods[i] = batch[i].Method
+		}
+		return strings.Join(methods, ",")
+	}
+	return httpMethod
+}
+
+// statusRecorder records the status of the response it writes
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *statusRecorder) Flush() {
+	if flusher, ok := r.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
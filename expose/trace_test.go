@@ -0,0 +1,149 @@
+// Copyright (C) 2021-2024, Lux Partners Limited. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package expose
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/luxdefi/netrunner/network"
+	"github.com/luxdefi/node/utils/logging"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTracer(t *testing.T) {
+	require := require.New(t)
+
+	tracer := NewTracer(3)
+	require.Empty(tracer.Traces(""))
+	for i, nodeName := range []string{"node1", "node2", "node1", "node2", "node1"} {
+		tracer.add(Trace{NodeName: nodeName, ParamsSize: i})
+	}
+	// the oldest traces are dropped
+	require.Equal([]Trace{
+		{NodeName: "node1", ParamsSize: 2},
+		{NodeName: "node2", ParamsSize: 3},
+		{NodeName: "node1", ParamsSize: 4},
+	}, tracer.Traces(""))
+	require.Equal([]Trace{
+		{NodeName: "node1", ParamsSize: 2},
+		{NodeName: "node1", ParamsSize: 4},
+	}, tracer.Traces("node1"))
+	require.Empty(tracer.Traces("node3"))
+
+	// a tracer of no traces keeps none
+	tracer = NewTracer(0)
+	tracer.add(Trace{NodeName: "node1"})
+	require.Empty(tracer.Traces(""))
+}
+
+func TestRequestMethod(t *testing.T) {
+	tests := []struct {
+		name           string
+		body           string
+		expectedMethod string
+	}{
+		{
+			name:           "JSON-RPC request",
+			body:           `{"jsonrpc":"2.0","id":1,"method":"eth_blockNumber","params":[]}`,
+			expectedMethod: "eth_blockNumber",
+		},
+		{
+			name:           "JSON-RPC batch",
+			body:           `[{"method":"eth_chainId"},{"method":"eth_getBalance"}]`,
+			expectedMethod: "eth_chainId,eth_getBalance",
+		},
+		{
+			name:           "empty batch",
+			body:           `[]`,
+			expectedMethod: http.MethodPost,
+		},
+		{
+			name:           "no method",
+			body:           `{"jsonrpc":"2.0","id":1}`,
+			expectedMethod: http.MethodPost,
+		},
+		{
+			name:           "not JSON",
+			body:           `hello`,
+			expectedMethod: http.MethodPost,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.Equal(t, tt.expectedMethod, requestMethod(http.MethodPost, []byte(tt.body)))
+		})
+	}
+}
+
+func TestProxyTracing(t *testing.T) {
+	require := require.New(t)
+
+	// echoes the request body, as a node API answering it would
+	node1 := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/ext/missing" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		_, _ = io.Copy(w, r.Body)
+	}))
+	defer node1.Close()
+	down := newTestNodeAPI(t, "down")
+	down.Close()
+	catalog := network.EndpointCatalog{
+		Endpoints: []network.Endpoint{
+			{NodeName: "node1", Chain: "C", URL: node1.URL + "/ext/bc/C/rpc", Protocol: "http"},
+			{NodeName: "down", Chain: "info", URL: down.URL + "/ext/info", Protocol: "http"},
+		},
+	}
+	tracer := NewTracer(10)
+	proxy := NewProxy(logging.NoLog{}, func() (network.EndpointCatalog, error) {
+		return catalog, nil
+	}, tracer)
+
+	serve := func(method string, path string, body string, header http.Header) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(method, path, strings.NewReader(body))
+		for k, v := range header {
+			r.Header[k] = v
+		}
+		w := httptest.NewRecorder()
+		proxy.ServeHTTP(w, r)
+		return w
+	}
+	request := `{"jsonrpc":"2.0","id":1,"method":"eth_blockNumber","params":[]}`
+	w := serve(http.MethodPost, "/node1/ext/bc/C/rpc", request, nil)
+	require.Equal(http.StatusOK, w.Code)
+	// the body read for the trace is forwarded
+	require.Equal(request, w.Body.String())
+	require.Equal(http.StatusNotFound, serve(http.MethodGet, "/node1/ext/missing", "", nil).Code)
+	require.Equal(http.StatusBadGateway, serve(http.MethodGet, "/down/ext/info", "", nil).Code)
+	// websocket upgrades aren't traced
+	serve(http.MethodGet, "/node1/ext/bc/C/ws", "", http.Header{"Upgrade": {"websocket"}, "Connection": {"Upgrade"}})
+	// nor requests not routed to a node
+	require.Equal(http.StatusNotFound, serve(http.MethodGet, "/node3/ext/info", "", nil).Code)
+
+	traces := tracer.Traces("")
+	require.Len(traces, 3)
+	for _, trace := range traces {
+		require.False(trace.Time.IsZero())
+		require.Positive(trace.Latency)
+	}
+	require.Equal("node1", traces[0].NodeName)
+	require.Equal("/ext/bc/C/rpc", traces[0].Path)
+	require.Equal("eth_blockNumber", traces[0].Method)
+	require.Equal(len(request), traces[0].ParamsSize)
+	require.Equal(http.StatusOK, traces[0].Status)
+
+	require.Equal("/ext/missing", traces[1].Path)
+	require.Equal(http.MethodGet, traces[1].Method)
+	require.Zero(traces[1].ParamsSize)
+	require.Equal(http.StatusNotFound, traces[1].Status)
+
+	require.Equal("down", traces[2].NodeName)
+	require.Equal(http.StatusBadGateway, traces[2].Status)
+	require.Equal(traces[2:], tracer.Traces("down"))
+}
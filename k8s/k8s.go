@@ -0,0 +1,235 @@
+// Copyright (C) 2021-2024, Lux Partners Limited. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+// Package k8s runs the nodes of a network as pods of a kubernetes cluster,
+// through kubectl. Its networks are local networks whose node processes
+// are pods, so they implement network.Network the same way from the same
+// config files. The API and P2P ports of the pods are forwarded to the
+// runner, which reaches the nodes as local processes, while the nodes
+// reach each other at their pod IPs.
+//
+// The node data, db and logs are kept in the pods, so the operations on
+// the node dirs of the runner (e.g. db inspection, log tailing) don't see
+// them. Use Network.NodeLogs to get the logs of a node.
+package k8s
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/luxdefi/netrunner/local"
+	"github.com/luxdefi/netrunner/network"
+	"github.com/luxdefi/netrunner/network/node"
+	"github.com/luxdefi/node/utils/logging"
+	"go.uber.org/zap"
+)
+
+const (
+	defaultTag = "latest"
+	// prefix of the names of the node pods and config maps
+	namePrefix = "netrunner-"
+	// max time for a pod to be running, pulling its image included
+	podStartTimeout = 5 * time.Minute
+	// timeout of the kubectl calls
+	kubectlTimeout = time.Minute
+)
+
+var (
+	_ local.NodeProcessCreator = (*podCreator)(nil)
+
+	ErrNoImage = errors.New("no image given")
+)
+
+// Options of the node pods
+type Options struct {
+	// Namespace of the pods. Defaults to the one of the kubectl context.
+	Namespace string
+	// kubectl context of the cluster. Defaults to the current one.
+	Context string
+	// Image of the nodes without one in their config (e.g. "luxdefi/node")
+	Image string
+	// Tag of the image. Defaults to "latest".
+	ImageTag string
+}
+
+// Network is a network running its nodes as pods
+type Network struct {
+	network.Network
+	creator *podCreator
+}
+
+// NewNetwork returns a new network running its nodes as pods.
+// See local.NewNetwork.
+func NewNetwork(
+	log logging.Logger,
+	networkConfig network.Config,
+	rootDir string,
+	snapshotsDir string,
+	reassignPortsIfUsed bool,
+	opts Options,
+) (*Network, error) {
+	if _, err := exec.LookPath(kubectlBinary); err != nil {
+		return nil, fmt.Errorf("couldn't find kubectl: %w", err)
+	}
+	if opts.ImageTag != "" && opts.Image == "" {
+		return nil, errors.New("image tag given without an image")
+	}
+	creator := &podCreator{
+		log: log,
+		kubectl: kubectl{
+			namespace: opts.Namespace,
+			context:   opts.Context,
+		},
+		opts:        opts,
+		pods:        map[string]*pod{},
+		stakingPods: map[string]*pod{},
+	}
+	nw, err := local.NewNetworkWithNodeProcessCreator(log, networkConfig, rootDir, snapshotsDir, reassignPortsIfUsed, creator)
+	if err != nil {
+		return nil, err
+	}
+	return &Network{
+		Network: nw,
+		creator: creator,
+	}, nil
+}
+
+// NodeLogs returns the last [tailLines] lines of the logs of the pod of
+// [nodeName], or all of them if [tailLines] is negative. The logs of a
+// node are gone once it is stopped, as its pod is deleted.
+func (n *Network) NodeLogs(ctx context.Context, nodeName string, tailLines int) (string, error) {
+	n.creator.lock.Lock()
+	p, ok := n.creator.pods[nodeName]
+	n.creator.lock.Unlock()
+	if !ok {
+		return "", fmt.Errorf("%w: %q", network.ErrNodeNotFound, nodeName)
+	}
+	out, err := n.creator.kubectl.run(ctx, nil, "logs", "pod/"+p.name, "--tail", strconv.Itoa(tailLines))
+	if err != nil {
+		return "", err
+	}
+	return string(out), nil
+}
+
+type podCreator struct {
+	log     logging.Logger
+	kubectl kubectl
+	opts    Options
+
+	lock sync.Mutex
+	// node name --> its last pod
+	pods map[string]*pod
+	// staking port --> pod of the node listening on it
+	stakingPods map[string]*pod
+}
+
+// GetNodeVersion gets the version of the node of the image as per
+// --version flag, from a pod run to completion
+func (pc *podCreator) GetNodeVersion(nodeConfig node.Config) (string, error) {
+	image, err := pc.image(nodeConfig)
+	if err != nil {
+		return "", err
+	}
+	suffix, err := randomSuffix()
+	if err != nil {
+		return "", err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), podStartTimeout)
+	defer cancel()
+	out, err := pc.kubectl.run(ctx, nil,
+		"run", podName(nodeConfig.Name+"-version", suffix),
+		"--image", image,
+		"--restart", "Never",
+		"--rm", "--attach", "--quiet",
+		"--", "--version",
+	)
+	if err != nil {
+		return "", fmt.Errorf("couldn't run image %q: %w", image, err)
+	}
+	return string(out), nil
+}
+
+// NewNodeProcess creates a pod of the node image with [args]. Its
+// bootstrap nodes are waited for to be running, so their IPs are known.
+func (pc *podCreator) NewNodeProcess(nodeConfig node.Config, args ...string) (local.NodeProcess, error) {
+	image, err := pc.image(nodeConfig)
+	if err != nil {
+		return nil, err
+	}
+	suffix, err := randomSuffix()
+	if err != nil {
+		return nil, err
+	}
+	name := podName(nodeConfig.Name, suffix)
+	spec, err := buildPodSpec(args, pc.awaitPodIP)
+	if err != nil {
+		return nil, err
+	}
+	manifest, err := json.Marshal(podManifest(name, nodeConfig.Name, image, spec))
+	if err != nil {
+		return nil, err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), kubectlTimeout)
+	defer cancel()
+	if _, err := pc.kubectl.run(ctx, manifest, "apply", "-f", "-"); err != nil {
+		return nil, fmt.Errorf("couldn't create pod of node %q: %w", nodeConfig.Name, err)
+	}
+	p := newPod(nodeConfig.Name, name, pc.log, pc.kubectl, nodeConfig.BindIP, spec)
+
+	pc.lock.Lock()
+	defer pc.lock.Unlock()
+
+	pc.pods[nodeConfig.Name] = p
+	pc.stakingPods[strconv.Itoa(spec.stakingPort)] = p
+	return p, nil
+}
+
+// Returns the IP of the pod listening on staking [port], once it is
+// running, if the port is the one of a pod
+func (pc *podCreator) awaitPodIP(port string) (string, bool) {
+	pc.lock.Lock()
+	p, ok := pc.stakingPods[port]
+	pc.lock.Unlock()
+	if !ok {
+		return "", false
+	}
+	ip, err := p.awaitIP(podStartTimeout)
+	if err != nil {
+		pc.log.Warn("couldn't get bootstrap pod IP", zap.String("pod", p.name), zap.Error(err))
+		return "", false
+	}
+	return ip, true
+}
+
+// Returns the image of the node, with its tag
+func (pc *podCreator) image(nodeConfig node.Config) (string, error) {
+	image, tag := nodeConfig.Image, nodeConfig.ImageTag
+	if image == "" {
+		image, tag = pc.opts.Image, pc.opts.ImageTag
+	}
+	if image == "" {
+		return "", fmt.Errorf("%w for node %q", ErrNoImage, nodeConfig.Name)
+	}
+	if tag == "" {
+		tag = defaultTag
+	}
+	return image + ":" + tag, nil
+}
+
+// Returns a random suffix making the pod names unique
+// across networks with nodes of the same name
+func randomSuffix() (string, error) {
+	b := make([]byte, 4)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,56 @@
+// Copyright (C) 2021-2024, Lux Partners Limited. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package k8s
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+const kubectlBinary = "kubectl"
+
+// kubectl runs kubectl commands on the namespace of a cluster
+type kubectl struct {
+	// If empty, the ones of the current kubectl context
+	namespace string
+	context   string
+}
+
+// Returns [args] prefixed with the cluster and namespace args
+func (k kubectl) args(args ...string) []string {
+	globalArgs := []string{}
+	if k.context != "" {
+		globalArgs = append(globalArgs, "--context", k.context)
+	}
+	if k.namespace != "" {
+		globalArgs = append(globalArgs, "--namespace", k.namespace)
+	}
+	return append(globalArgs, args...)
+}
+
+// Runs kubectl with [args] and [stdin], if not nil, and returns its output
+func (k kubectl) run(ctx context.Context, stdin []byte, args ...string) ([]byte, error) {
+	cmd := exec.CommandContext(ctx, kubectlBinary, k.args(args...)...) //nolint
+	if stdin != nil {
+		cmd.Stdin = bytes.NewReader(stdin)
+	}
+	out, err := cmd.Output()
+	if err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) && len(exitErr.Stderr) > 0 {
+			return nil, fmt.Errorf("kubectl %s: %w: %s", args[0], err, strings.TrimSpace(string(exitErr.Stderr)))
+		}
+		return nil, fmt.Errorf("kubectl %s: %w", args[0], err)
+	}
+	return out, nil
+}
+
+// Returns the command running kubectl with [args], not started
+func (k kubectl) command(args ...string) *exec.Cmd {
+	return exec.Command(kubectlBinary, k.args(args...)...) //nolint
+}
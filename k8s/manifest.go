@@ -0,0 +1,254 @@
+// Copyright (C) 2021-2024, Lux Partners Limited. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package k8s
+
+import (
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/luxdefi/node/config"
+)
+
+const (
+	// dir of the pod the node files are mounted under, at their runner paths
+	filesMountDir = "/netrunner/files"
+	// dir of the pod volume holding the node data, db and logs
+	dataMountDir = "/netrunner/data"
+	// env var of the pod IP, which the node advertises to its peers
+	podIPEnv = "POD_IP"
+	// API path of the readiness probe of the pods
+	healthPath = "/ext/health"
+	// seconds given to a deleted pod for the node to stop
+	gracePeriodSeconds = 30
+	// max size of the node files shipped in the config map of a pod
+	maxFilesSize = 1024 * 1024
+)
+
+var invalidNameChars = regexp.MustCompile(`[^a-z0-9-]+`)
+
+// podSpec is the node part of a pod manifest, built from the node args
+type podSpec struct {
+	// node args, with the paths of the pod
+	args []string
+	// path of the runner --> contents, of the node files
+	// shipped to the pod in a config map
+	files       map[string][]byte
+	apiPort     int
+	stakingPort int
+}
+
+// Returns the pod spec of a node run with [args]. The node files are
+// shipped to the pod, its data, db and logs are kept in a pod volume, and
+// the bootstrap IPs are replaced by the ones [podIP] returns for their
+// ports, if any. The node advertises the pod IP to its peers, and uses the
+// plugins of the image.
+func buildPodSpec(args []string, podIP func(port string) (string, bool)) (podSpec, error) {
+	spec := podSpec{
+		files: map[string][]byte{},
+	}
+	filesSize := 0
+	for _, arg := range args {
+		flagName, value, _ := strings.Cut(strings.TrimPrefix(arg, "--"), "=")
+		switch flagName {
+		case config.HTTPPortKey, config.StakingPortKey:
+			port, err := strconv.Atoi(value)
+			if err != nil {
+				return podSpec{}, fmt.Errorf("invalid %s %q: %w", flagName, value, err)
+			}
+			if flagName == config.HTTPPortKey {
+				spec.apiPort = port
+			} else {
+				spec.stakingPort = port
+			}
+		case config.HTTPHostKey, config.StakingHostKey, config.PublicIPKey, config.PluginDirKey:
+			continue
+		case config.DataDirKey:
+			value = dataMountDir
+		case config.DBPathKey:
+			value = filepath.Join(dataMountDir, "db")
+		case config.LogsDirKey:
+			value = filepath.Join(dataMountDir, "logs")
+		case config.BootstrapIPsKey:
+			value = replaceBootstrapIPs(value, podIP)
+		default:
+			if !filepath.IsAbs(value) {
+				break
+			}
+			size, err := addFiles(spec.files, filepath.Clean(value))
+			if err != nil {
+				return podSpec{}, fmt.Errorf("couldn't read files of flag %q: %w", flagName, err)
+			}
+			filesSize += size
+			value = filepath.Join(filesMountDir, value)
+		}
+		spec.args = append(spec.args, fmt.Sprintf("--%s=%s", flagName, value))
+	}
+	if spec.apiPort == 0 || spec.stakingPort == 0 {
+		return podSpec{}, fmt.Errorf("missing %s or %s", config.HTTPPortKey, config.StakingPortKey)
+	}
+	if filesSize > maxFilesSize {
+		return podSpec{}, fmt.Errorf("node files of %d bytes exceed the config map limit of %d bytes", filesSize, maxFilesSize)
+	}
+	spec.args = append(spec.args,
+		fmt.Sprintf("--%s=0.0.0.0", config.HTTPHostKey),
+		fmt.Sprintf("--%s=$(%s)", config.PublicIPKey, podIPEnv),
+	)
+	sort.Strings(spec.args)
+	return spec, nil
+}
+
+// Adds to [files] the file at [path], or the files under it if it is a dir,
+// and returns their size. Missing paths are skipped.
+func addFiles(files map[string][]byte, path string) (int, error) {
+	size := 0
+	err := filepath.WalkDir(path, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		b, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		files[path] = b
+		size += len(b)
+		return nil
+	})
+	if os.IsNotExist(err) {
+		return 0, nil
+	}
+	return size, err
+}
+
+// Returns the comma separated [ips] with the ip of each ip:port replaced
+// by the one [podIP] returns for the port, if any
+func replaceBootstrapIPs(ips string, podIP func(port string) (string, bool)) string {
+	if ips == "" {
+		return ips
+	}
+	replaced := strings.Split(ips, ",")
+	for i, ipPort := range replaced {
+		_, port, found := strings.Cut(ipPort, ":")
+		if !found {
+			continue
+		}
+		if ip, ok := podIP(port); ok {
+			replaced[i] = ip + ":" + port
+		}
+	}
+	return strings.Join(replaced, ",")
+}
+
+// Returns a lowercase DNS-1123 name for the pod of [nodeName]
+// with [suffix], as kubernetes requires for object names
+func podName(nodeName string, suffix string) string {
+	name := strings.Trim(invalidNameChars.ReplaceAllString(strings.ToLower(nodeName), "-"), "-")
+	// leave room for the prefix and suffix within the 63 chars of a label
+	if maxLen := 63 - len(namePrefix) - len(suffix) - 1; len(name) > maxLen {
+		name = name[:maxLen]
+	}
+	return namePrefix + name + "-" + suffix
+}
+
+// Returns the manifest of a list with the config map holding the node
+// files and the pod [name] running the node of [image] as per [spec]
+func podManifest(name string, nodeName string, image string, spec podSpec) map[string]interface{} {
+	labels := map[string]interface{}{
+		"app":           "netrunner",
+		"netrunner/pod": name,
+	}
+	paths := make([]string, 0, len(spec.files))
+	for path := range spec.files {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	binaryData := map[string]interface{}{}
+	items := []interface{}{}
+	for i, path := range paths {
+		key := fmt.Sprintf("file%d", i)
+		binaryData[key] = spec.files[path]
+		items = append(items, map[string]interface{}{
+			"key":  key,
+			"path": strings.TrimPrefix(path, "/"),
+		})
+	}
+	configMap := map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       "ConfigMap",
+		"metadata": map[string]interface{}{
+			"name":   name,
+			"labels": labels,
+		},
+		"binaryData": binaryData,
+	}
+	pod := map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       "Pod",
+		"metadata": map[string]interface{}{
+			"name":   name,
+			"labels": labels,
+			"annotations": map[string]interface{}{
+				"netrunner/node": nodeName,
+			},
+		},
+		"spec": map[string]interface{}{
+			"restartPolicy":                 "Never",
+			"terminationGracePeriodSeconds": gracePeriodSeconds,
+			"containers": []interface{}{
+				map[string]interface{}{
+					"name":       "node",
+					"image":      image,
+					"args":       spec.args,
+					"workingDir": dataMountDir,
+					"env": []interface{}{
+						map[string]interface{}{
+							"name": podIPEnv,
+							"valueFrom": map[string]interface{}{
+								"fieldRef": map[string]interface{}{"fieldPath": "status.podIP"},
+							},
+						},
+					},
+					"ports": []interface{}{
+						map[string]interface{}{"name": "http", "containerPort": spec.apiPort},
+						map[string]interface{}{"name": "staking", "containerPort": spec.stakingPort},
+					},
+					"readinessProbe": map[string]interface{}{
+						"httpGet": map[string]interface{}{
+							"path": healthPath,
+							"port": spec.apiPort,
+						},
+						"periodSeconds": 5,
+					},
+					"volumeMounts": []interface{}{
+						map[string]interface{}{"name": "files", "mountPath": filesMountDir, "readOnly": true},
+						map[string]interface{}{"name": "data", "mountPath": dataMountDir},
+					},
+				},
+			},
+			"volumes": []interface{}{
+				map[string]interface{}{
+					"name": "files",
+					"configMap": map[string]interface{}{
+						"name":  name,
+						"items": items,
+					},
+				},
+				map[string]interface{}{
+					"name":     "data",
+					"emptyDir": map[string]interface{}{},
+				},
+			},
+		},
+	}
+	return map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       "List",
+		"items":      []interface{}{configMap, pod},
+	}
+}
@@ -0,0 +1,67 @@
+// Copyright (C) 2021-2024, Lux Partners Limited. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package k8s
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestBuildPodSpec(t *testing.T) {
+	require := require.New(t)
+
+	root := t.TempDir()
+	keyFile := filepath.Join(root, "staking.key")
+	require.NoError(os.WriteFile(keyFile, []byte("key"), 0o600))
+	chainConfigDir := filepath.Join(root, "chainConfigs")
+	require.NoError(os.MkdirAll(filepath.Join(chainConfigDir, "C"), 0o750))
+	require.NoError(os.WriteFile(filepath.Join(chainConfigDir, "C", "config.json"), []byte("{}"), 0o600))
+
+	podIPs := map[string]string{"9651": "10.0.0.1"}
+	spec, err := buildPodSpec([]string{
+		"--http-port=9652",
+		"--staking-port=9653",
+		"--http-host=127.0.0.1",
+		"--data-dir=" + root,
+		"--plugin-dir=" + filepath.Join(root, "plugins"),
+		"--staking-tls-key-file=" + keyFile,
+		"--chain-config-dir=" + chainConfigDir,
+		"--bootstrap-ips=127.0.0.1:9651,127.0.0.1:9655",
+	}, func(port string) (string, bool) {
+		ip, ok := podIPs[port]
+		return ip, ok
+	})
+	require.NoError(err)
+	require.Equal(9652, spec.apiPort)
+	require.Equal(9653, spec.stakingPort)
+	require.Equal(map[string][]byte{
+		keyFile: []byte("key"),
+		filepath.Join(chainConfigDir, "C", "config.json"): []byte("{}"),
+	}, spec.files)
+	require.Equal([]string{
+		"--bootstrap-ips=10.0.0.1:9651,127.0.0.1:9655",
+		"--chain-config-dir=" + filepath.Join(filesMountDir, chainConfigDir),
+		"--data-dir=" + dataMountDir,
+		"--http-host=0.0.0.0",
+		"--http-port=9652",
+		"--public-ip=$(POD_IP)",
+		"--staking-port=9653",
+		"--staking-tls-key-file=" + filepath.Join(filesMountDir, keyFile),
+	}, spec.args)
+
+	_, err = buildPodSpec([]string{"--http-port=9652"}, nil)
+	require.Error(err)
+}
+
+func TestPodName(t *testing.T) {
+	require := require.New(t)
+
+	require.Equal("netrunner-node1-abcd", podName("node1", "abcd"))
+	require.Equal("netrunner-my-node-abcd", podName("My_Node.", "abcd"))
+	require.Len(podName(strings.Repeat("a", 100), "abcdabcd"), 63)
+}
@@ -0,0 +1,291 @@
+// Copyright (C) 2021-2024, Lux Partners Limited. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package k8s
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/luxdefi/netrunner/local"
+	"github.com/luxdefi/netrunner/network/node/status"
+	"github.com/luxdefi/netrunner/utils"
+	"github.com/luxdefi/node/utils/logging"
+	"go.uber.org/zap"
+)
+
+// how often the pod status is checked
+const podCheckFreq = 2 * time.Second
+
+var _ local.NodeProcess = (*pod)(nil)
+
+// pod is the process of a node running in a pod, watched from the runner.
+// Once the pod is running, its API and P2P ports are forwarded to the
+// bind IP of the node.
+type pod struct {
+	name    string
+	log     logging.Logger
+	kubectl kubectl
+	// address the ports are forwarded on
+	forwardIP   string
+	apiPort     int
+	stakingPort int
+
+	lock sync.RWMutex
+	// Process status
+	state status.Status
+	// pod IP, once running
+	ip string
+	// Closed once the pod is running
+	runningCh chan struct{}
+	// forwards the ports of the pod once it is running
+	portForward *exec.Cmd
+	// exit code of the node container, -1 until it is known
+	exitCode int
+	// Closed when the pod is gone.
+	closedOnStop chan struct{}
+}
+
+func newPod(nodeName string, name string, log logging.Logger, k kubectl, bindIP string, spec podSpec) *pod {
+	forwardIP := bindIP
+	if forwardIP == "" {
+		forwardIP = "127.0.0.1"
+	}
+	p := &pod{
+		name:         name,
+		log:          utils.WithFields(log, utils.NodeField(nodeName), zap.String("pod", name)),
+		kubectl:      k,
+		forwardIP:    forwardIP,
+		apiPort:      spec.apiPort,
+		stakingPort:  spec.stakingPort,
+		state:        status.Running,
+		runningCh:    make(chan struct{}),
+		exitCode:     -1,
+		closedOnStop: make(chan struct{}),
+	}
+	go p.watch()
+	return p
+}
+
+// status of a pod as seen by the cluster
+type podStatus struct {
+	// true if the pod doesn't exist (anymore)
+	gone  bool
+	phase string
+	ip    string
+	// exit code of the node container, if it terminated
+	exitCode *int
+}
+
+// Returns the status of the pod
+func (p *pod) getStatus() (podStatus, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), kubectlTimeout)
+	defer cancel()
+	out, err := p.kubectl.run(ctx, nil,
+		"get", "pod", p.name, "--ignore-not-found",
+		"-o", "jsonpath={.status.phase} {.status.podIP} {.status.containerStatuses[0].state.terminated.exitCode}",
+	)
+	if err != nil {
+		return podStatus{}, err
+	}
+	fields := strings.Fields(string(out))
+	if len(fields) == 0 {
+		return podStatus{gone: true}, nil
+	}
+	st := podStatus{phase: fields[0]}
+	if len(fields) > 1 {
+		st.ip = fields[1]
+	}
+	if len(fields) > 2 {
+		if exitCode, err := strconv.Atoi(fields[2]); err == nil {
+			st.exitCode = &exitCode
+		}
+	}
+	return st, nil
+}
+
+// Checks the pod until it is gone, forwarding its ports once it is
+// running and deleting it once the node exits. Then updates the state
+// and closes [p.closedOnStop].
+func (p *pod) watch() {
+	createdAt := time.Now()
+	for {
+		st, err := p.getStatus()
+		switch {
+		case err != nil:
+			p.log.Debug("couldn't get pod status", zap.Error(err))
+		case st.gone:
+			p.exited()
+			return
+		default:
+			p.update(st)
+			switch st.phase {
+			case "Running":
+				p.forwardPorts()
+			case "Succeeded", "Failed":
+				p.delete(false)
+			case "Pending":
+				if time.Since(createdAt) > podStartTimeout {
+					p.log.Warn("pod not running in time", zap.Duration("timeout", podStartTimeout))
+					p.delete(true)
+				}
+			}
+		}
+		time.Sleep(podCheckFreq)
+	}
+}
+
+// Records the IP and exit code of [st]
+func (p *pod) update(st podStatus) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	if st.exitCode != nil {
+		p.exitCode = *st.exitCode
+	}
+	if p.ip == "" && st.ip != "" && st.phase == "Running" {
+		p.ip = st.ip
+		close(p.runningCh)
+	}
+}
+
+// Starts forwarding the pod ports, if not forwarded yet
+func (p *pod) forwardPorts() {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	if p.portForward != nil || p.state != status.Running {
+		return
+	}
+	cmd := p.kubectl.command(
+		"port-forward", "pod/"+p.name,
+		"--address", p.forwardIP,
+		fmt.Sprintf("%d:%d", p.apiPort, p.apiPort),
+		fmt.Sprintf("%d:%d", p.stakingPort, p.stakingPort),
+	)
+	if err := cmd.Start(); err != nil {
+		p.log.Warn("couldn't forward pod ports", zap.Error(err))
+		return
+	}
+	p.portForward = cmd
+	go func() {
+		if err := cmd.Wait(); err != nil {
+			p.log.Debug("pod port forwarding finished", zap.Error(err))
+		}
+	}()
+}
+
+// Deletes the pod, right away if [force]
+func (p *pod) delete(force bool) {
+	args := []string{"delete", "pod", p.name, "--ignore-not-found", "--wait=false"}
+	if force {
+		args = append(args, "--grace-period=0", "--force")
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), kubectlTimeout)
+	defer cancel()
+	if _, err := p.kubectl.run(ctx, nil, args...); err != nil {
+		p.log.Warn("couldn't delete pod", zap.Error(err))
+	}
+}
+
+// Cleans up after the pod is gone and marks it stopped
+func (p *pod) exited() {
+	ctx, cancel := context.WithTimeout(context.Background(), kubectlTimeout)
+	defer cancel()
+	if _, err := p.kubectl.run(ctx, nil, "delete", "configmap", p.name, "--ignore-not-found"); err != nil {
+		p.log.Warn("couldn't delete pod config map", zap.Error(err))
+	}
+
+	p.log.Debug("node pod finished")
+
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	if p.portForward != nil && p.portForward.Process != nil {
+		_ = p.portForward.Process.Kill()
+	}
+	p.state = status.Stopped
+	close(p.closedOnStop)
+}
+
+// Returns the IP of the pod once it is running, or an
+// error if it isn't within [timeout] or is gone before
+func (p *pod) awaitIP(timeout time.Duration) (string, error) {
+	select {
+	case <-p.runningCh:
+	case <-p.closedOnStop:
+		return "", errors.New("pod is gone")
+	case <-time.After(timeout):
+		return "", fmt.Errorf("pod not running within %s", timeout)
+	}
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+
+	return p.ip, nil
+}
+
+// Stop deletes the pod, which gives the node some time to stop, and
+// returns its exit code. If [ctx] is cancelled, the pod is force deleted.
+func (p *pod) Stop(ctx context.Context) int {
+	p.lock.Lock()
+
+	// The pod is already gone.
+	if p.state == status.Stopped {
+		exitCode := p.exitCode
+		p.lock.Unlock()
+		return exitCode
+	}
+
+	// There's another call to Stop executing right now.
+	// Wait for it to finish.
+	if p.state == status.Stopping {
+		p.lock.Unlock()
+		<-p.closedOnStop
+		return p.ExitCode()
+	}
+
+	p.state = status.Stopping
+	// We have to unlock here so that [p.exited] can grab the lock
+	// and close [p.closedOnStop].
+	p.lock.Unlock()
+
+	p.delete(false)
+
+	select {
+	case <-ctx.Done():
+		p.log.Warn("context cancelled while waiting for node pod to stop")
+		p.delete(true)
+	case <-p.closedOnStop:
+	}
+
+	<-p.closedOnStop
+	return p.ExitCode()
+}
+
+func (p *pod) Status() status.Status {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+
+	return p.state
+}
+
+// PID returns 0, as the node doesn't run as a process of the runner host
+func (*pod) PID() int {
+	return 0
+}
+
+func (p *pod) ExitCode() int {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+
+	if p.state != status.Stopped {
+		return -1
+	}
+	return p.exitCode
+}
@@ -0,0 +1,122 @@
+// Copyright (C) 2021-2024, Lux Partners Limited. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package local
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"time"
+
+	"github.com/luxdefi/netrunner/network/node"
+)
+
+const (
+	defaultBindIP     = "127.0.0.1"
+	defaultIPv6BindIP = "::1"
+	// last octet of the first loopback alias given to nodes
+	firstLoopbackAlias = 2
+)
+
+var errNoLoopbackAlias = errors.New("no free loopback alias left")
+
+// Validates the bind and public IPs of [nodeConfig]. If the bind IP is
+// empty, assigns it the IPv6 loopback if [ln.ipv6] is set, a free loopback
+// alias if [ln.loopbackAliases] is set, or the loopback address if a public
+// IP is given, as the node must not listen on the public IP.
+// Assumes [ln.lock] is held.
+func (ln *localNetwork) setBindIP(nodeConfig *node.Config) error {
+	if nodeConfig.PublicIP != "" && net.ParseIP(nodeConfig.PublicIP) == nil {
+		return fmt.Errorf("invalid public IP %q", nodeConfig.PublicIP)
+	}
+	if nodeConfig.BlockInbound && nodeConfig.PublicIP == "" {
+		return errors.New("blocking inbound connections requires a public IP")
+	}
+	if nodeConfig.BindIP != "" {
+		if net.ParseIP(nodeConfig.BindIP) == nil {
+			return fmt.Errorf("invalid bind IP %q", nodeConfig.BindIP)
+		}
+		return nil
+	}
+	if nodeConfig.PublicIP != "" && !ln.loopbackAliases {
+		nodeConfig.BindIP = defaultBindIP
+		if ln.ipv6 {
+			nodeConfig.BindIP = defaultIPv6BindIP
+		}
+		return nil
+	}
+	if ln.ipv6 {
+		nodeConfig.BindIP = defaultIPv6BindIP
+		return nil
+	}
+	if !ln.loopbackAliases {
+		return nil
+	}
+	used := map[string]struct{}{}
+	for _, node := range ln.nodes {
+		used[node.bindIP] = struct{}{}
+	}
+	for i := firstLoopbackAlias; i < 255; i++ {
+		ip := fmt.Sprintf("127.0.0.%d", i)
+		if _, ok := used[ip]; !ok {
+			nodeConfig.BindIP = ip
+			return nil
+		}
+	}
+	return errNoLoopbackAlias
+}
+
+// Returns the IP other nodes use to reach a beacon advertising [ip]
+func beaconIP(ip string) net.IP {
+	if ip == "" {
+		return net.IPv6loopback
+	}
+	return net.ParseIP(ip)
+}
+
+// Starts forwarding the P2P port of [node] from its public IP to its
+// bind IP, unless it has no public IP or blocks inbound connections.
+// Assumes [ln.lock] is held.
+func (ln *localNetwork) startPortForwarder(node *localNode) error {
+	nodeConfig := node.config
+	if nodeConfig.PublicIP == "" || nodeConfig.PublicIP == nodeConfig.BindIP || nodeConfig.BlockInbound {
+		return nil
+	}
+	port := fmt.Sprintf("%d", node.p2pPort)
+	var peerLatency func(net.Addr) (time.Duration, bool)
+	if ln.regionLinks != nil && nodeConfig.Region != "" {
+		regionLinks, region := ln.regionLinks, nodeConfig.Region
+		peerLatency = func(remote net.Addr) (time.Duration, bool) {
+			return regionLinks.latency(region, remote)
+		}
+	}
+	partition, nodeName := ln.partition, node.name
+	allowPeer := func(remote net.Addr) bool {
+		return partition.allows(nodeName, remote)
+	}
+	forwarder, err := newPortForwarder(
+		ln.log,
+		net.JoinHostPort(nodeConfig.PublicIP, port),
+		net.JoinHostPort(nodeConfig.BindIP, port),
+		nodeConfig.Latency,
+		peerLatency,
+		allowPeer,
+	)
+	if err != nil {
+		return fmt.Errorf("couldn't forward P2P port of node %q from public IP %s: %w", node.name, nodeConfig.PublicIP, err)
+	}
+	node.forwarder = forwarder
+	if nodeConfig.LinkConditions != nil {
+		forwarder.setConditions(nodeConfig.LinkConditions)
+	}
+	return nil
+}
+
+// Stops the port forwarder of [node], if any
+func stopPortForwarder(node *localNode) {
+	if node.forwarder != nil {
+		_ = node.forwarder.Close()
+		node.forwarder = nil
+	}
+}
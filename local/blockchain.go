@@ -5,7 +5,6 @@ package local
 
 import (
 	"context"
-	"encoding/base64"
 	"errors"
 	"fmt"
 	"os"
@@ -23,13 +22,13 @@ import (
 
 	"github.com/luxdefi/netrunner/network"
 	"github.com/luxdefi/netrunner/network/node"
+	"github.com/luxdefi/netrunner/nodeconfig"
 	"github.com/luxdefi/netrunner/utils"
 	"github.com/luxdefi/node/api/admin"
 	"github.com/luxdefi/node/config"
 	"github.com/luxdefi/node/genesis"
 	"github.com/luxdefi/node/ids"
 	"github.com/luxdefi/node/utils/constants"
-	"github.com/luxdefi/node/utils/crypto/bls"
 	"github.com/luxdefi/node/utils/logging"
 	"github.com/luxdefi/node/utils/set"
 	"github.com/luxdefi/node/vms/platformvm"
@@ -58,6 +57,8 @@ const (
 	defaultTimeout                 = time.Minute
 	stakingMinimumLeadTime         = 25 * time.Second
 	minStakeDuration               = 24 * 14 * time.Hour
+	// 10% fee percent, times 10000 to make it as shares
+	defaultDelegationFee = 10 * 10000
 )
 
 var (
@@ -91,9 +92,9 @@ func (ln *localNetwork) getNode() node.Node {
 // get node client URI for an arbitrary node in the network
 func (ln *localNetwork) getClientURI() (string, error) { //nolint
 	node := ln.getNode()
-	clientURI := fmt.Sprintf("http://%s:%d", node.GetURL(), node.GetAPIPort())
+	clientURI := node.GetAPIBaseURI()
 	ln.log.Info("getClientURI",
-		zap.String("nodeName", node.GetName()),
+		utils.NodeField(node.GetName()),
 		zap.String("uri", clientURI))
 	return clientURI, nil
 }
@@ -101,7 +102,9 @@ func (ln *localNetwork) getClientURI() (string, error) { //nolint
 func (ln *localNetwork) CreateBlockchains(
 	ctx context.Context,
 	chainSpecs []network.BlockchainSpec, // VM name + genesis bytes
-) ([]ids.ID, error) {
+) (_ []ids.ID, err error) {
+	defer utils.StartOperation(ln.log, "create-blockchains")(&err)
+
 	ln.lock.Lock()
 	defer ln.lock.Unlock()
 
@@ -188,7 +191,9 @@ func (ln *localNetwork) TransformSubnet(
 func (ln *localNetwork) CreateSubnets(
 	ctx context.Context,
 	subnetSpecs []network.SubnetSpec,
-) ([]ids.ID, error) {
+) (_ []ids.ID, err error) {
+	defer utils.StartOperation(ln.log, "create-subnets")(&err)
+
 	ln.lock.Lock()
 	defer ln.lock.Unlock()
 
@@ -204,6 +209,19 @@ func (ln *localNetwork) installCustomChains(
 	fmt.Println()
 	ln.log.Info(logging.Blue.Wrap(logging.Bold.Wrap("create and install custom chains")))
 
+	// fail before issuing any tx if the nodes would run different VM binaries
+	vmIDs := make([]ids.ID, 0, len(chainSpecs))
+	for _, chainSpec := range chainSpecs {
+		vmID, err := utils.VMID(chainSpec.VMName)
+		if err != nil {
+			return nil, err
+		}
+		vmIDs = append(vmIDs, vmID)
+	}
+	if err := ln.checkPlugins(vmIDs); err != nil {
+		return nil, err
+	}
+
 	clientURI, err := ln.getClientURI()
 	if err != nil {
 		return nil, err
@@ -494,7 +512,7 @@ func (ln *localNetwork) waitForCustomChainsReady(
 			if node.paused {
 				continue
 			}
-			ln.log.Info("inspecting node log directory for custom chain logs", zap.String("log-dir", node.GetLogsDir()), zap.String("node-name", nodeName))
+			ln.log.Info("inspecting node log directory for custom chain logs", zap.String("log-dir", node.GetLogsDir()), utils.NodeField(nodeName))
 			p := filepath.Join(node.GetLogsDir(), chainInfo.blockchainID.String()+".log")
 			ln.log.Info("checking log",
 				zap.String("vm-ID", chainInfo.vmID.String()),
@@ -716,47 +734,19 @@ func (ln *localNetwork) addPrimaryValidators(
 			continue
 		}
 
-		// Prepare node BLS PoP
-		// It is important to note that this will ONLY register BLS signers for
-		// nodes registered AFTER genesis.
-		blsKeyBytes, err := base64.StdEncoding.DecodeString(node.GetConfig().StakingSigningKey)
-		if err != nil {
-			return err
-		}
-		blsSk, err := bls.SecretKeyFromBytes(blsKeyBytes)
-		if err != nil {
-			return err
-		}
-		proofOfPossession := signer.NewProofOfPossession(blsSk)
-		cctx, cancel = createDefaultCtx(ctx)
-		txID, err := w.pWallet.IssueAddPermissionlessValidatorTx(
-			&txs.SubnetValidator{
-				Validator: txs.Validator{
-					NodeID: nodeID,
-					Start:  uint64(time.Now().Add(validationStartOffset).Unix()),
-					End:    uint64(time.Now().Add(validationDuration).Unix()),
-					Wght:   genesis.LocalParams.MinValidatorStake,
-				},
-				Subnet: ids.Empty,
-			},
-			proofOfPossession,
-			w.pWallet.LUXAssetID(),
-			&secp256k1fx.OutputOwners{
-				Threshold: 1,
-				Addrs:     []ids.ShortID{w.addr},
-			},
-			&secp256k1fx.OutputOwners{
-				Threshold: 1,
-				Addrs:     []ids.ShortID{w.addr},
-			},
-			10*10000, // 10% fee percent, times 10000 to make it as shares
-			common.WithContext(cctx),
+		txID, err := issuePrimaryValidatorTx(
+			ctx,
+			w,
+			node,
+			genesis.LocalParams.MinValidatorStake,
+			time.Now().Add(validationStartOffset),
+			time.Now().Add(validationDuration),
+			defaultDelegationFee,
 		)
-		cancel()
 		if err != nil {
-			return fmt.Errorf("P-Wallet Tx Error %s %w, node ID %s", "IssueAddPermissionlessValidatorTx", err, nodeID.String())
+			return err
 		}
-		ln.log.Info("added node as primary subnet validator", zap.String("node-name", nodeName), zap.String("node-ID", nodeID.String()), zap.String("tx-ID", txID.String()))
+		ln.log.Info("added node as primary subnet validator", utils.NodeField(nodeName), zap.String("node-ID", nodeID.String()), zap.String("tx-ID", txID.String()))
 	}
 	return nil
 }
@@ -886,7 +876,7 @@ func (ln *localNetwork) removeSubnetValidators(
 				return fmt.Errorf("P-Wallet Tx Error %s %w, node ID %s, subnetID %s", "IssueRemoveSubnetValidatorTx", err, nodeID.String(), subnetID.String())
 			}
 			ln.log.Info("removed node as subnet validator",
-				zap.String("node-name", nodeName),
+				utils.NodeField(nodeName),
 				zap.String("node-ID", nodeID.String()),
 				zap.String("subnet-ID", subnetID.String()),
 				zap.String("tx-ID", txID.String()),
@@ -1191,7 +1181,7 @@ func (ln *localNetwork) addSubnetValidators(
 				return fmt.Errorf("P-Wallet Tx Error %s %w, node ID %s, subnetID %s", "IssueAddSubnetValidatorTx", err, nodeID.String(), subnetID.String())
 			}
 			ln.log.Info("added node as a subnet validator to subnet",
-				zap.String("node-name", nodeName),
+				utils.NodeField(nodeName),
 				zap.String("node-ID", nodeID.String()),
 				zap.String("subnet-ID", subnetID.String()),
 				zap.String("tx-ID", txID.String()),
@@ -1294,7 +1284,7 @@ func (ln *localNetwork) reloadVMPlugins(ctx context.Context) error {
 		if node.paused {
 			continue
 		}
-		uri := fmt.Sprintf("http://%s:%d", node.GetURL(), node.GetAPIPort())
+		uri := node.GetAPIBaseURI()
 		adminCli := admin.NewClient(uri)
 		cctx, cancel := createDefaultCtx(ctx)
 		_, failedVMs, err := adminCli.LoadVMs(cctx)
@@ -1406,6 +1396,9 @@ func (ln *localNetwork) setBlockchainConfigFiles(
 				if cfg, ok := chainSpec.PerNodeChainConfig[nodeName]; ok {
 					chainConfig = cfg
 				}
+				if err := nodeconfig.ValidateVMChainConfig(chainSpec.VMName, chainConfig); err != nil {
+					return nil, err
+				}
 				ln.nodes[nodeName].config.ChainConfigFiles[chainAlias] = string(chainConfig)
 				nodesToRestart.Add(nodeName)
 			}
@@ -0,0 +1,111 @@
+// Copyright (C) 2021-2024, Lux Partners Limited. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package local
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/luxdefi/netrunner/network"
+	"github.com/luxdefi/netrunner/nodeconfig"
+	"github.com/luxdefi/node/utils/beacon"
+	"github.com/luxdefi/node/utils/ips"
+	"go.uber.org/zap"
+)
+
+var (
+	ErrIncompatibleNetworks = errors.New("networks have different genesis")
+	errNotLocalNetwork      = errors.New("network was not created by the local package")
+)
+
+// ConnectNetworks connects two separately created networks that share
+// the same genesis, so that they behave as a single network.
+// The beacons of each network are added to the bootstrappers of the
+// other one, and the running nodes of both networks are restarted so
+// they connect to them.
+// Nodes added afterwards to any of the networks also bootstrap from
+// both sets of beacons. This allows to simulate partitions that are
+// later merged, or to attach a network of non validating API nodes
+// (e.g. created empty with the same genesis) to a validator network.
+func ConnectNetworks(ctx context.Context, nw1 network.Network, nw2 network.Network) error {
+	ln1, ok := nw1.(*localNetwork)
+	if !ok {
+		return errNotLocalNetwork
+	}
+	ln2, ok := nw2.(*localNetwork)
+	if !ok {
+		return errNotLocalNetwork
+	}
+	if ln1 == ln2 {
+		return errors.New("can't connect a network to itself")
+	}
+	genesis1, beacons1, err := ln1.getGenesisAndBeacons()
+	if err != nil {
+		return err
+	}
+	genesis2, beacons2, err := ln2.getGenesisAndBeacons()
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(genesis1, genesis2) {
+		return ErrIncompatibleNetworks
+	}
+	if err := ln1.addBootstrappers(ctx, beacons2); err != nil {
+		return err
+	}
+	return ln2.addBootstrappers(ctx, beacons1)
+}
+
+// Returns the genesis of the network and its running beacons
+func (ln *localNetwork) getGenesisAndBeacons() ([]byte, []beacon.Beacon, error) {
+	ln.lock.RLock()
+	defer ln.lock.RUnlock()
+
+	if ln.stopCalled() {
+		return nil, nil, network.ErrStopped
+	}
+	beacons := []beacon.Beacon{}
+	for _, node := range ln.nodes {
+		if node.paused || !node.config.IsBeacon {
+			continue
+		}
+		beacons = append(beacons, beacon.New(node.nodeID, ips.IPPort{
+			IP:   beaconIP(nodeconfig.AdvertisedIP(node.config)),
+			Port: node.p2pPort,
+		}))
+	}
+	return ln.genesis, beacons, nil
+}
+
+// Adds [beacons] to the network bootstrappers, and restarts
+// the running nodes so they connect to them
+func (ln *localNetwork) addBootstrappers(ctx context.Context, beacons []beacon.Beacon) error {
+	ln.lock.Lock()
+	defer ln.lock.Unlock()
+
+	if ln.stopCalled() {
+		return network.ErrStopped
+	}
+	for _, b := range beacons {
+		if err := ln.bootstraps.Add(b); err != nil {
+			// already a bootstrapper
+			ln.log.Debug("couldn't add bootstrapper", zap.Stringer("node-id", b.ID()), zap.Error(err))
+		}
+	}
+	// restarting a node replaces its entry in [ln.nodes]
+	nodeNames := []string{}
+	for nodeName, node := range ln.nodes {
+		if !node.paused {
+			nodeNames = append(nodeNames, nodeName)
+		}
+	}
+	for _, nodeName := range nodeNames {
+		if err := ln.restartNode(ctx, nodeName, "", "", "", nil, nil, nil); err != nil {
+			return fmt.Errorf("couldn't restart node %q: %w", nodeName, err)
+		}
+	}
+	return nil
+}
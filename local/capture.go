@@ -0,0 +1,142 @@
+// Copyright (C) 2021-2024, Lux Partners Limited. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package local
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"syscall"
+	"time"
+
+	"github.com/luxdefi/netrunner/network"
+	"github.com/luxdefi/netrunner/utils"
+	"go.uber.org/zap"
+)
+
+const (
+	captureBinary  = "tcpdump"
+	capturesSubdir = "captures"
+	// time given to the capture process to flush its file on stop
+	captureStopTimeout = 5 * time.Second
+)
+
+var (
+	ErrCaptureRunning    = errors.New("traffic capture already running")
+	ErrCaptureNotRunning = errors.New("no traffic capture running")
+)
+
+// trafficCapture is a running packet capture of a node ports
+type trafficCapture struct {
+	cmd  *exec.Cmd
+	path string
+	// closed when [cmd] exits
+	done chan struct{}
+}
+
+// See network.Network
+// Captures with tcpdump the packets of the node API and P2P ports
+// into a pcap file under <node data dir>/captures.
+func (ln *localNetwork) StartCapture(_ context.Context, nodeName string) (string, error) {
+	ln.lock.Lock()
+	defer ln.lock.Unlock()
+
+	if ln.stopCalled() {
+		return "", network.ErrStopped
+	}
+	node, ok := ln.nodes[nodeName]
+	if !ok {
+		return "", fmt.Errorf("%w: %q", network.ErrNodeNotFound, nodeName)
+	}
+	if node.paused {
+		return "", fmt.Errorf("node %q is paused", nodeName)
+	}
+	if node.capture != nil {
+		return "", fmt.Errorf("%w on node %q", ErrCaptureRunning, nodeName)
+	}
+	binaryPath, err := exec.LookPath(captureBinary)
+	if err != nil {
+		return "", fmt.Errorf("couldn't find %s: %w", captureBinary, err)
+	}
+	capturesDir := filepath.Join(node.GetDataDir(), capturesSubdir)
+	if err := os.MkdirAll(capturesDir, os.ModePerm); err != nil {
+		return "", err
+	}
+	path := filepath.Join(capturesDir, fmt.Sprintf("%s-%s.pcap", nodeName, time.Now().Format("20060102_150405")))
+	cmd := exec.Command( //nolint
+		binaryPath,
+		"-i", captureInterface(),
+		// write packets as they arrive, so the file is usable while capturing
+		"-U",
+		"-w", path,
+		fmt.Sprintf("tcp port %d or tcp port %d", node.GetP2PPort(), node.GetAPIPort()),
+	)
+	if err := cmd.Start(); err != nil {
+		return "", fmt.Errorf("couldn't start %s: %w", captureBinary, err)
+	}
+	capture := &trafficCapture{
+		cmd:  cmd,
+		path: path,
+		done: make(chan struct{}),
+	}
+	go func() {
+		if err := cmd.Wait(); err != nil {
+			ln.log.Debug("traffic capture exited", utils.NodeField(nodeName), zap.Error(err))
+		}
+		close(capture.done)
+	}()
+	node.capture = capture
+	ln.log.Info("started traffic capture", utils.NodeField(nodeName), zap.String("path", path))
+	return path, nil
+}
+
+// See network.Network
+func (ln *localNetwork) StopCapture(_ context.Context, nodeName string) (string, error) {
+	ln.lock.Lock()
+	defer ln.lock.Unlock()
+
+	if ln.stopCalled() {
+		return "", network.ErrStopped
+	}
+	node, ok := ln.nodes[nodeName]
+	if !ok {
+		return "", fmt.Errorf("%w: %q", network.ErrNodeNotFound, nodeName)
+	}
+	if node.capture == nil {
+		return "", fmt.Errorf("%w on node %q", ErrCaptureNotRunning, nodeName)
+	}
+	path := node.capture.path
+	stopCapture(node)
+	ln.log.Info("stopped traffic capture", utils.NodeField(nodeName), zap.String("path", path))
+	return path, nil
+}
+
+// Stops the traffic capture of [node], if any, letting tcpdump flush its file
+func stopCapture(node *localNode) {
+	capture := node.capture
+	if capture == nil {
+		return
+	}
+	node.capture = nil
+	_ = capture.cmd.Process.Signal(syscall.SIGINT)
+	select {
+	case <-capture.done:
+	case <-time.After(captureStopTimeout):
+		_ = capture.cmd.Process.Kill()
+		<-capture.done
+	}
+}
+
+// Returns the interface local node traffic goes through
+func captureInterface() string {
+	if runtime.GOOS == "darwin" {
+		return "lo0"
+	}
+	// also covers nodes bound to non loopback addresses
+	return "any"
+}
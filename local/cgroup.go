@@ -0,0 +1,141 @@
+// Copyright (C) 2021-2024, Lux Partners Limited. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package local
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+const (
+	// mount point of the cgroup v2 hierarchy
+	cgroupRoot = "/sys/fs/cgroup"
+	// prefix of the cgroup dirs of the node processes
+	nodeCgroupPrefix = "netrunner-"
+	// period of the CPU quota of the node cgroups, in microseconds,
+	// the kernel default
+	cgroupCPUPeriod = 100_000
+)
+
+var ErrNoCgroups = errors.New("cgroup v2 not available")
+
+// Returns the cgroup dir the node cgroups are created in: the parent of
+// the cgroup of the runner, as a cgroup with processes, the runner's,
+// can't have child cgroups with controllers, unless it's the root one.
+// Returns an error if the node processes, started in the cgroup of the
+// runner, can't be moved from there to a child of the parent.
+func nodeCgroupsParent() (string, error) {
+	parent, err := runnerCgroupParent()
+	if err != nil {
+		return "", err
+	}
+	if err := checkCgroupMigration(parent); err != nil {
+		return "", err
+	}
+	return parent, nil
+}
+
+// Returns the parent of the cgroup of the runner, or the root cgroup
+func runnerCgroupParent() (string, error) {
+	if runtime.GOOS != "linux" {
+		return "", fmt.Errorf("%w on %s", ErrNoCgroups, runtime.GOOS)
+	}
+	// only there on a cgroup v2 (unified) hierarchy
+	if _, err := os.Stat(filepath.Join(cgroupRoot, "cgroup.controllers")); err != nil {
+		return "", fmt.Errorf("%w: %s", ErrNoCgroups, err)
+	}
+	b, err := os.ReadFile("/proc/self/cgroup")
+	if err != nil {
+		return "", err
+	}
+	for _, line := range strings.Split(string(b), "\n") {
+		// the cgroup v2 entry, e.g. 0::/user.slice/user-1000.slice/session-2.scope
+		if !strings.HasPrefix(line, "0::") {
+			continue
+		}
+		cgroup := strings.TrimPrefix(line, "0::")
+		if cgroup == "/" {
+			return cgroupRoot, nil
+		}
+		return filepath.Join(cgroupRoot, filepath.Dir(cgroup)), nil
+	}
+	return "", fmt.Errorf("%w: no cgroup v2 entry in /proc/self/cgroup", ErrNoCgroups)
+}
+
+// Returns an error if the processes of the cgroups under [ancestor] can't
+// be moved among them: moving a process needs write access to the
+// cgroup.procs file of the common ancestor of its source and destination
+func checkCgroupMigration(ancestor string) error {
+	f, err := os.OpenFile(filepath.Join(ancestor, "cgroup.procs"), os.O_WRONLY, 0)
+	if err != nil {
+		return fmt.Errorf("%w: can't move processes under cgroup %s: %s", ErrNoCgroups, ancestor, err)
+	}
+	return f.Close()
+}
+
+// Creates the cgroup of a process of [nodeName] in [parent], limited
+// to [cpus] CPUs and [memory] bytes, unlimited if 0, and returns its dir
+func createNodeCgroup(parent string, nodeName string, cpus float64, memory uint64) (string, error) {
+	controllers := []string{}
+	if cpus > 0 {
+		controllers = append(controllers, "cpu")
+	}
+	if memory > 0 {
+		controllers = append(controllers, "memory")
+	}
+	if err := enableCgroupControllers(parent, controllers); err != nil {
+		return "", err
+	}
+	dir, err := os.MkdirTemp(parent, nodeCgroupPrefix+nodeName+"-")
+	if err != nil {
+		return "", fmt.Errorf("couldn't create cgroup: %w", err)
+	}
+	if cpus > 0 {
+		quota := int64(cpus * cgroupCPUPeriod)
+		if err := writeCgroupFile(dir, "cpu.max", fmt.Sprintf("%d %d", quota, cgroupCPUPeriod)); err != nil {
+			_ = os.Remove(dir)
+			return "", err
+		}
+	}
+	if memory > 0 {
+		if err := writeCgroupFile(dir, "memory.max", strconv.FormatUint(memory, 10)); err != nil {
+			_ = os.Remove(dir)
+			return "", err
+		}
+	}
+	return dir, nil
+}
+
+// Enables [controllers] in the child cgroups of [parent], if not already
+func enableCgroupControllers(parent string, controllers []string) error {
+	b, err := os.ReadFile(filepath.Join(parent, "cgroup.subtree_control"))
+	if err != nil {
+		return err
+	}
+	enabled := map[string]struct{}{}
+	for _, controller := range strings.Fields(string(b)) {
+		enabled[controller] = struct{}{}
+	}
+	for _, controller := range controllers {
+		if _, ok := enabled[controller]; ok {
+			continue
+		}
+		if err := writeCgroupFile(parent, "cgroup.subtree_control", "+"+controller); err != nil {
+			return fmt.Errorf("couldn't enable the %s controller: %w", controller, err)
+		}
+	}
+	return nil
+}
+
+func writeCgroupFile(dir string, name string, value string) error {
+	if err := os.WriteFile(filepath.Join(dir, name), []byte(value), 0o644); err != nil { //nolint:gosec
+		return fmt.Errorf("couldn't write %s of cgroup %s: %w", name, dir, err)
+	}
+	return nil
+}
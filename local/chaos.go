@@ -0,0 +1,205 @@
+// Copyright (C) 2021-2024, Lux Partners Limited. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package local
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+
+	"github.com/luxdefi/netrunner/network"
+	"github.com/luxdefi/netrunner/network/node"
+	"github.com/luxdefi/netrunner/utils"
+	"go.uber.org/zap"
+	"golang.org/x/exp/slices"
+)
+
+var ErrNoPortForwarder = errors.New("node has neither a port forwarder nor blocks inbound connections")
+
+// partition cuts the P2P connections between two sides of the network at
+// the port forwarders of the nodes, which close the connections from the
+// processes of the nodes of the other side, found as in [regionLinks]
+type partition struct {
+	lock sync.RWMutex
+	// PID of a node process --> name of the node
+	nodes map[int32]string
+	// node name --> side of the partition, 1 or 2.
+	// Empty if the network isn't partitioned.
+	sides map[string]int
+}
+
+func newPartition() *partition {
+	return &partition{
+		nodes: map[int32]string{},
+		sides: map[string]int{},
+	}
+}
+
+// Records that the process [pid] is the node [nodeName]
+func (p *partition) addNode(pid int, nodeName string) {
+	if pid == 0 {
+		return
+	}
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	p.nodes[int32(pid)] = nodeName
+}
+
+func (p *partition) removeNode(pid int) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	delete(p.nodes, int32(pid))
+}
+
+// Splits the network into the sides [sideA] and [sideB], or heals it if both are empty
+func (p *partition) set(sideA []string, sideB []string) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	p.sides = map[string]int{}
+	for _, nodeName := range sideA {
+		p.sides[nodeName] = 1
+	}
+	for _, nodeName := range sideB {
+		p.sides[nodeName] = 2
+	}
+}
+
+// Returns false if [remote] belongs to a node on the other side
+// of the partition than [nodeName]
+func (p *partition) allows(nodeName string, remote net.Addr) bool {
+	p.lock.RLock()
+	side, ok := p.sides[nodeName]
+	if !ok {
+		p.lock.RUnlock()
+		return true
+	}
+	// processes of the nodes on the other side
+	others := map[int32]string{}
+	for pid, otherName := range p.nodes {
+		if otherSide, ok := p.sides[otherName]; ok && otherSide != side {
+			others[pid] = otherName
+		}
+	}
+	p.lock.RUnlock()
+
+	_, isOther := connOwner(remote, others)
+	return !isOther
+}
+
+// See network.Network
+func (ln *localNetwork) Partition(_ context.Context, groupA []string, groupB []string) (err error) {
+	defer utils.StartOperation(ln.log, "partition")(&err)
+
+	ln.lock.Lock()
+	defer ln.lock.Unlock()
+
+	if ln.stopCalled() {
+		return network.ErrStopped
+	}
+	if len(groupA) == 0 || len(groupB) == 0 {
+		return errors.New("both sides of a partition must have nodes")
+	}
+	for _, nodeName := range append(slices.Clone(groupA), groupB...) {
+		node, ok := ln.nodes[nodeName]
+		if !ok {
+			return fmt.Errorf("%w: %q", network.ErrNodeNotFound, nodeName)
+		}
+		// the nodes dial the public IP of the nodes with a port forwarder, so the
+		// connections between two nodes pass through at least one forwarder
+		if !partitionable(node) {
+			return fmt.Errorf("%w: %q, which needs a public IP", ErrNoPortForwarder, nodeName)
+		}
+		if slices.Contains(groupA, nodeName) && slices.Contains(groupB, nodeName) {
+			return fmt.Errorf("node %q is on both sides of the partition", nodeName)
+		}
+	}
+	ln.partition.set(groupA, groupB)
+	for _, node := range ln.nodes {
+		if node.forwarder != nil {
+			node.forwarder.closeRejected()
+		}
+	}
+	ln.log.Info("partitioned network", zap.Strings("side-a", groupA), zap.Strings("side-b", groupB))
+	return nil
+}
+
+// See network.Network
+func (ln *localNetwork) Heal() (err error) {
+	defer utils.StartOperation(ln.log, "heal")(&err)
+
+	ln.lock.Lock()
+	defer ln.lock.Unlock()
+
+	if ln.stopCalled() {
+		return network.ErrStopped
+	}
+	ln.partition.set(nil, nil)
+	return nil
+}
+
+// See network.Network
+func (ln *localNetwork) SetLinkConditions(nodeName string, conditions node.LinkConditions) (err error) {
+	defer utils.StartOperation(ln.log, "set-link-conditions", zap.String("node", nodeName))(&err)
+
+	if err := conditions.Validate(); err != nil {
+		return err
+	}
+
+	ln.lock.Lock()
+	defer ln.lock.Unlock()
+
+	if ln.stopCalled() {
+		return network.ErrStopped
+	}
+	localNode, ok := ln.nodes[nodeName]
+	if !ok {
+		return fmt.Errorf("%w: %q", network.ErrNodeNotFound, nodeName)
+	}
+	if localNode.forwarder == nil {
+		return fmt.Errorf("%w: %q, which needs a public IP accepting inbound connections", ErrNoPortForwarder, nodeName)
+	}
+	var newConditions *node.LinkConditions
+	if conditions != (node.LinkConditions{}) {
+		newConditions = &conditions
+	}
+	// kept in the config so that the conditions are restored on restart
+	localNode.config.LinkConditions = newConditions
+	localNode.forwarder.setConditions(newConditions)
+	ln.log.Info("set link conditions",
+		zap.String("node", nodeName),
+		zap.Uint32("latency-ms", conditions.LatencyMs),
+		zap.Uint32("jitter-ms", conditions.JitterMs),
+		zap.Float64("loss-pct", conditions.LossPct),
+	)
+	return nil
+}
+
+// Returns true if all the P2P connections of [node] pass through a port
+// forwarder: its own one, or the one of the peer it dials if it blocks
+// inbound connections
+func partitionable(node *localNode) bool {
+	nodeConfig := node.config
+	return nodeConfig.BlockInbound || (nodeConfig.PublicIP != "" && nodeConfig.PublicIP != nodeConfig.BindIP)
+}
+
+// Starts tracking the process of [node], if it may be partitioned,
+// so that its connections are cut when the network is partitioned.
+// Assumes [ln.lock] is held.
+func (ln *localNetwork) trackPartition(node *localNode) {
+	if partitionable(node) {
+		ln.partition.addNode(node.process.PID(), node.name)
+	}
+}
+
+// Assumes [ln.lock] is held.
+func (ln *localNetwork) untrackPartition(node *localNode) {
+	if partitionable(node) {
+		ln.partition.removeNode(node.process.PID())
+	}
+}
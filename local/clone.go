@@ -0,0 +1,243 @@
+// Copyright (C) 2021-2024, Lux Partners Limited. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package local
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/luxdefi/netrunner/network"
+	"github.com/luxdefi/netrunner/network/node"
+	"github.com/luxdefi/netrunner/utils"
+	"github.com/luxdefi/node/config"
+	"github.com/luxdefi/node/ids"
+	"github.com/luxdefi/node/utils/constants"
+	"go.uber.org/zap"
+	"golang.org/x/exp/maps"
+)
+
+const (
+	cloneSnapshotPrefix = "clone-"
+	// node flag, not exported by the node config package
+	sybilProtectionEnabledKey = "sybil-protection-enabled"
+)
+
+// See network.Network
+// The network is snapshotted into a temporary snapshot, restarted with its
+// previous dirs and ports, and the clone is loaded from the snapshot with new
+// root dir, ports and staking keys. As the validator sets in the copied chain
+// state refer to the original node IDs, the clone runs with sybil protection
+// disabled, and its nodes are registered as primary network validators.
+// If the original nodes can't be restarted, the snapshot is kept, so the
+// network can be restored with LoadSnapshot.
+func (ln *localNetwork) CloneNetwork(ctx context.Context, cloneName string) (_ network.Network, err error) {
+	defer utils.StartOperation(ln.log, "clone-network", zap.String("clone-name", cloneName))(&err)
+
+	clone, err := ln.cloneNetwork(ctx, cloneName)
+	if err != nil {
+		return nil, err
+	}
+	// the original network isn't held while the clone validators are registered
+	if err := clone.registerCloneValidators(ctx); err != nil {
+		if err := clone.Stop(ctx); err != nil {
+			ln.log.Debug("error stopping clone", zap.Error(err))
+		}
+		return nil, fmt.Errorf("couldn't register clone validators: %w", err)
+	}
+	return clone, nil
+}
+
+// Starts the clone of the network, as [ln.CloneNetwork] does,
+// with its nodes not registered as validators yet
+func (ln *localNetwork) cloneNetwork(ctx context.Context, cloneName string) (*localNetwork, error) {
+	ln.lock.Lock()
+	defer ln.lock.Unlock()
+
+	if ln.stopCalled() {
+		return nil, network.ErrStopped
+	}
+	if cloneName != "" && cloneName == ln.name {
+		return nil, fmt.Errorf("%w: %q", ErrNetworkNameInUse, cloneName)
+	}
+
+	// keep the node configs needed to restart the network as it was
+	nodeConfigs := make([]node.Config, 0, len(ln.nodes))
+	for _, node := range ln.nodes {
+		if node.paused {
+			return nil, fmt.Errorf("can't clone network with paused node %q", node.name)
+		}
+		nodeConfig := node.GetConfig()
+		nodeConfig.Flags = maps.Clone(nodeConfig.Flags)
+		nodeConfig.Flags[config.DataDirKey] = node.GetDataDir()
+		nodeConfig.Flags[config.DBPathKey] = node.GetDbDir()
+		nodeConfig.Flags[config.LogsDirKey] = node.GetLogsDir()
+		nodeConfig.Flags[config.HTTPPortKey] = int(node.GetAPIPort())
+		nodeConfig.Flags[config.StakingPortKey] = int(node.GetP2PPort())
+		nodeConfigs = append(nodeConfigs, nodeConfig)
+	}
+
+	snapshotName := fmt.Sprintf("%s%d", cloneSnapshotPrefix, time.Now().UnixNano())
+	snapshotDir, err := ln.saveSnapshot(ctx, snapshotName)
+	if err != nil {
+		return nil, err
+	}
+	// the snapshot holds the only copy of the network state
+	// until the original nodes are restarted
+	keepSnapshot := true
+	defer func() {
+		if keepSnapshot {
+			return
+		}
+		if err := ln.RemoveSnapshot(snapshotName); err != nil {
+			ln.log.Warn("couldn't remove clone snapshot", zap.String("snapshot-dir", snapshotDir), zap.Error(err))
+		}
+	}()
+
+	if err := ln.restartAfterSnapshot(ctx, nodeConfigs); err != nil {
+		return nil, fmt.Errorf("%w; the network is left with no nodes, and its state is kept in snapshot %q", err, snapshotName)
+	}
+	keepSnapshot = false
+
+	if err := setCloneConfig(snapshotDir, cloneName); err != nil {
+		return nil, err
+	}
+	// the clone gets its root dir and snapshots dir as NewNetwork gives them
+	rootDir, snapshotsDir, err := namedNetworkDirs(cloneName, "", ln.baseSnapshotsDir())
+	if err != nil {
+		return nil, err
+	}
+	// the snapshot is loaded from the snapshots dir of the original
+	clone, err := newNetwork(ln.log, ln.newAPIClientF, ln.nodeProcessCreator, rootDir, ln.snapshotsDir, true)
+	if err != nil {
+		return nil, err
+	}
+	if err := clone.loadSnapshot(ctx, snapshotName, "", "", nil, nil, nil, nil); err != nil {
+		return nil, fmt.Errorf("couldn't load clone: %w", err)
+	}
+	if err := os.MkdirAll(snapshotsDir, os.ModePerm); err != nil {
+		if err := clone.Stop(ctx); err != nil {
+			ln.log.Debug("error stopping clone", zap.Error(err))
+		}
+		return nil, err
+	}
+	clone.lock.Lock()
+	clone.snapshotsDir = snapshotsDir
+	clone.lock.Unlock()
+	return clone, nil
+}
+
+// Restarts the nodes of [nodeConfigs], beacons first, with the dirs and
+// ports they had before the network was stopped to save a snapshot.
+// If a node fails to restart, the ones restarted are stopped, so the
+// network is left as the snapshot left it.
+// Assumes [ln.lock] is held.
+func (ln *localNetwork) restartAfterSnapshot(ctx context.Context, nodeConfigs []node.Config) error {
+	if err := ln.acquireRootDir(); err != nil {
+		return err
+	}
+	for _, isBeacon := range []bool{true, false} {
+		for _, nodeConfig := range nodeConfigs {
+			if nodeConfig.IsBeacon != isBeacon {
+				continue
+			}
+			if _, err := ln.addNode(nodeConfig); err != nil {
+				if err := ln.stop(ctx); err != nil {
+					ln.log.Debug("error stopping network", zap.Error(err))
+				}
+				return fmt.Errorf("couldn't restart node %q after snapshot: %w", nodeConfig.Name, err)
+			}
+		}
+	}
+	return nil
+}
+
+// Registers the nodes of the clone, whose node IDs are new, as primary
+// network validators, and waits until they are validating
+func (ln *localNetwork) registerCloneValidators(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, primaryValidatorTimeout)
+	defer cancel()
+
+	ln.lock.Lock()
+	defer ln.lock.Unlock()
+
+	if err := ln.healthy(ctx); err != nil {
+		return err
+	}
+	nodeIDs := make([]ids.NodeID, 0, len(ln.nodes))
+	for _, node := range ln.nodes {
+		nodeIDs = append(nodeIDs, node.nodeID)
+	}
+	platformCli := ln.getNode().GetAPIClient().PChainAPI()
+	cctx, cancel := createDefaultCtx(ctx)
+	vdrs, err := platformCli.GetCurrentValidators(cctx, constants.PrimaryNetworkID, nodeIDs)
+	cancel()
+	if err != nil {
+		return err
+	}
+	if len(vdrs) == len(nodeIDs) {
+		return nil
+	}
+	clientURI, err := ln.getClientURI()
+	if err != nil {
+		return err
+	}
+	w, err := newWallet(ctx, clientURI, nil)
+	if err != nil {
+		return err
+	}
+	if err := ln.addPrimaryValidators(ctx, platformCli, w); err != nil {
+		return err
+	}
+	return ln.waitPrimaryValidators(ctx, platformCli)
+}
+
+// Returns the snapshots dir given to the network, before
+// NewNetwork set apart the one of a named network
+func (ln *localNetwork) baseSnapshotsDir() string {
+	if ln.name != "" && filepath.Base(ln.snapshotsDir) == ln.name {
+		return filepath.Dir(ln.snapshotsDir)
+	}
+	return ln.snapshotsDir
+}
+
+// Sets the network name of the snapshot at [snapshotDir] to [cloneName],
+// disables sybil protection, and removes the node ports, staking keys and
+// the db and logs root dirs, so the clone gets new ones
+func setCloneConfig(snapshotDir string, cloneName string) error {
+	networkConfigPath := filepath.Join(snapshotDir, "network.json")
+	networkConfigJSON, err := os.ReadFile(networkConfigPath)
+	if err != nil {
+		return fmt.Errorf("failure reading network config file from snapshot: %w", err)
+	}
+	networkConfig := network.Config{}
+	if err := json.Unmarshal(networkConfigJSON, &networkConfig); err != nil {
+		return fmt.Errorf("failure unmarshaling network config from snapshot: %w", err)
+	}
+	networkConfig.Name = cloneName
+	networkConfig.DBRootDir = ""
+	networkConfig.LogsRootDir = ""
+	if networkConfig.Flags == nil {
+		networkConfig.Flags = map[string]interface{}{}
+	}
+	// the nodes of the copied validator sets don't run in the clone
+	networkConfig.Flags[sybilProtectionEnabledKey] = false
+	for i := range networkConfig.NodeConfigs {
+		nodeConfig := &networkConfig.NodeConfigs[i]
+		delete(nodeConfig.Flags, config.HTTPPortKey)
+		delete(nodeConfig.Flags, config.StakingPortKey)
+		delete(nodeConfig.Flags, sybilProtectionEnabledKey)
+		nodeConfig.StakingKey = ""
+		nodeConfig.StakingCert = ""
+		nodeConfig.StakingSigningKey = ""
+	}
+	networkConfigJSON, err = json.MarshalIndent(networkConfig, "", "    ")
+	if err != nil {
+		return err
+	}
+	return createFileAndWrite(networkConfigPath, networkConfigJSON)
+}
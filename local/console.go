@@ -0,0 +1,258 @@
+// Copyright (C) 2021-2024, Lux Partners Limited. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package local
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/luxdefi/netrunner/network"
+	"github.com/luxdefi/netrunner/utils"
+	"github.com/luxdefi/node/utils/logging"
+)
+
+// console lines buffered per subscriber, beyond which its lines are dropped
+const consoleBufferSize = 1024
+
+var (
+	ErrNoConsole     = errors.New("node processes have no console")
+	ErrNotSignalable = errors.New("node process can't be signaled")
+)
+
+// consoleHub delivers the lines written by the node processes
+// to the subscribers to their consoles
+type consoleHub struct {
+	lock sync.Mutex
+	// node name --> subscriptions to its console --> channel
+	// closed once the subscription is closed
+	subs map[string]map[chan network.ConsoleLine]chan struct{}
+	// closed once the network is stopped, closing the subscriptions
+	closed chan struct{}
+}
+
+func newConsoleHub() *consoleHub {
+	return &consoleHub{
+		subs:   map[string]map[chan network.ConsoleLine]chan struct{}{},
+		closed: make(chan struct{}),
+	}
+}
+
+// Returns a subscription to the console of [nodeName], removed once
+// [ctx] is done, the node is removed or the hub is closed
+func (h *consoleHub) subscribe(ctx context.Context, nodeName string) <-chan network.ConsoleLine {
+	ch := make(chan network.ConsoleLine, consoleBufferSize)
+	removed := make(chan struct{})
+	h.lock.Lock()
+	if h.subs[nodeName] == nil {
+		h.subs[nodeName] = map[chan network.ConsoleLine]chan struct{}{}
+	}
+	h.subs[nodeName][ch] = removed
+	h.lock.Unlock()
+
+	go func() {
+		select {
+		case <-ctx.Done():
+		case <-h.closed:
+		case <-removed:
+		}
+		h.lock.Lock()
+		defer h.lock.Unlock()
+
+		if removed, ok := h.subs[nodeName][ch]; ok {
+			delete(h.subs[nodeName], ch)
+			if len(h.subs[nodeName]) == 0 {
+				delete(h.subs, nodeName)
+			}
+			close(ch)
+			close(removed)
+		}
+	}()
+	return ch
+}
+
+// Closes the subscriptions to the console of [nodeName], once the node
+// is removed. Their buffered lines are still received.
+// Does nothing on a nil hub.
+func (h *consoleHub) closeNode(nodeName string) {
+	if h == nil {
+		return
+	}
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	for ch, removed := range h.subs[nodeName] {
+		close(ch)
+		close(removed)
+	}
+	delete(h.subs, nodeName)
+}
+
+// Delivers [line] to the subscribers to its node console, without
+// blocking. Lines are dropped silently for a slow subscriber, as
+// logging each of them would only add to the load.
+func (h *consoleHub) publish(line network.ConsoleLine) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	for ch := range h.subs[line.NodeName] {
+		select {
+		case ch <- line:
+		default:
+		}
+	}
+}
+
+// Closes the subscriptions. Their buffered lines are still received.
+// Does nothing on a nil hub.
+func (h *consoleHub) close() {
+	if h == nil {
+		return
+	}
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	select {
+	case <-h.closed:
+		return
+	default:
+	}
+	close(h.closed)
+	for nodeName, subs := range h.subs {
+		for ch, removed := range subs {
+			close(ch)
+			close(removed)
+		}
+		delete(h.subs, nodeName)
+	}
+}
+
+// Sets the stdout and stderr of [cmd] to pipes whose lines are published to
+// the console of [nodeName], and written on to [stdout] and [stderr], if not
+// nil, prefixed with the node name and colored with [color].
+// The returned function closes the write ends of the pipes kept by the
+// runner, and must be called once [cmd] is started, or failed to.
+func (h *consoleHub) capture(
+	cmd *exec.Cmd,
+	nodeName string,
+	color logging.Color,
+	stdout io.Writer,
+	stderr io.Writer,
+) (func(), error) {
+	// the node writes to the pipes itself, rather than through a copy by
+	// the exec package, so that its exit is noticed even if plugin processes
+	// left behind keep the pipes open
+	stdoutR, stdoutW, err := os.Pipe()
+	if err != nil {
+		return nil, fmt.Errorf("couldn't create stdout pipe: %w", err)
+	}
+	stderrR, stderrW, err := os.Pipe()
+	if err != nil {
+		_ = stdoutR.Close()
+		_ = stdoutW.Close()
+		return nil, fmt.Errorf("couldn't create stderr pipe: %w", err)
+	}
+	cmd.Stdout = stdoutW
+	cmd.Stderr = stderrW
+	go h.readLines(stdoutR, nodeName, network.ConsoleStdout, color, stdout)
+	go h.readLines(stderrR, nodeName, network.ConsoleStderr, color, stderr)
+	return func() {
+		_ = stdoutW.Close()
+		_ = stderrW.Close()
+	}, nil
+}
+
+// Publishes the lines read from [r] until its write ends are closed,
+// writing them on to [w] if not nil
+func (h *consoleHub) readLines(
+	r io.ReadCloser,
+	nodeName string,
+	stream string,
+	color logging.Color,
+	w io.Writer,
+) {
+	defer r.Close()
+
+	// unlike a scanner, a reader takes lines of any length,
+	// e.g. those of a goroutine dump
+	br := bufio.NewReader(r)
+	for {
+		s, err := br.ReadString('\n')
+		if s != "" {
+			line := strings.TrimSuffix(strings.TrimSuffix(s, "\n"), "\r")
+			h.publish(network.ConsoleLine{
+				NodeName: nodeName,
+				Stream:   stream,
+				Line:     line,
+				Time:     time.Now(),
+			})
+			if w != nil {
+				_, _ = w.Write([]byte(color.Wrap(fmt.Sprintf("[%s] %s\n", nodeName, line))))
+			}
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+// See network.Network
+func (ln *localNetwork) AttachConsole(ctx context.Context, nodeName string) (<-chan network.ConsoleLine, error) {
+	ln.lock.RLock()
+	defer ln.lock.RUnlock()
+
+	if ln.stopCalled() {
+		return nil, network.ErrStopped
+	}
+	if _, ok := ln.nodes[nodeName]; !ok {
+		return nil, fmt.Errorf("%w: %q", network.ErrNodeNotFound, nodeName)
+	}
+	if ln.consoles == nil {
+		return nil, ErrNoConsole
+	}
+	return ln.consoles.subscribe(ctx, nodeName), nil
+}
+
+// See network.Network
+func (ln *localNetwork) SignalNode(_ context.Context, nodeName string, sig syscall.Signal) (err error) {
+	defer utils.StartOperation(ln.log, "signal-node", utils.NodeField(nodeName))(&err)
+
+	ln.lock.Lock()
+	defer ln.lock.Unlock()
+
+	if ln.stopCalled() {
+		return network.ErrStopped
+	}
+	node, ok := ln.nodes[nodeName]
+	if !ok {
+		return fmt.Errorf("%w: %q", network.ErrNodeNotFound, nodeName)
+	}
+	if node.paused {
+		return fmt.Errorf("node %q is paused", nodeName)
+	}
+	process, ok := node.process.(SignalableNodeProcess)
+	if !ok {
+		return fmt.Errorf("%w: %q", ErrNotSignalable, nodeName)
+	}
+	if err := process.Signal(sig); err != nil {
+		return fmt.Errorf("couldn't signal node %q: %w", nodeName, err)
+	}
+	return nil
+}
+
+// Returns the console hub of the processes created by [npc], if any
+func consolesOf(npc NodeProcessCreator) *consoleHub {
+	if npc, ok := npc.(*nodeProcessCreator); ok {
+		return npc.consoles
+	}
+	return nil
+}
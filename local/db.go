@@ -0,0 +1,64 @@
+// Copyright (C) 2021-2024, Lux Partners Limited. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package local
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/luxdefi/netrunner/network/node"
+	"github.com/luxdefi/netrunner/nodeconfig"
+	"github.com/luxdefi/node/config"
+)
+
+var ErrIncompatibleDBType = errors.New("incompatible database type")
+
+// Sets [nodeConfig.DBType], if not given, from the node flags or
+// [configFile], defaulting to leveldb, and the db type flag from it.
+// [nodeConfig.Flags] must not be nil.
+func setDBType(nodeConfig *node.Config, configFile map[string]interface{}) error {
+	if nodeConfig.DBType == "" {
+		dbType, err := nodeconfig.ConfigEntry(nodeConfig.Flags, configFile, config.DBTypeKey, node.DefaultDBType)
+		if err != nil {
+			return err
+		}
+		nodeConfig.DBType = dbType
+	}
+	if err := node.ValidateDBType(nodeConfig.DBType); err != nil {
+		return err
+	}
+	nodeConfig.Flags[config.DBTypeKey] = nodeConfig.DBType
+	return nil
+}
+
+// Returns false if the db of the node with [nodeConfig] is not kept on disk
+func hasPersistentDB(nodeConfig node.Config) bool {
+	return nodeConfig.DBType != node.DBTypeMemDB
+}
+
+// Returns true if the node with [nodeConfig] uses leveldb
+func isLevelDB(nodeConfig node.Config) bool {
+	return nodeConfig.DBType == "" || nodeConfig.DBType == node.DBTypeLevelDB
+}
+
+// Returns an error if the db type given in [flags] can't open
+// the db of the snapshotted node with [nodeConfig]
+func checkSnapshotDBType(nodeConfig node.Config, flags map[string]interface{}) error {
+	snapshotDBType := nodeConfig.DBType
+	if snapshotDBType == "" {
+		// snapshot saved before db types were recorded
+		snapshotDBType = node.DefaultDBType
+		if dbType, ok := nodeConfig.Flags[config.DBTypeKey].(string); ok {
+			snapshotDBType = dbType
+		}
+	}
+	dbType, ok := flags[config.DBTypeKey]
+	if !ok {
+		return nil
+	}
+	if fmt.Sprintf("%v", dbType) != snapshotDBType {
+		return fmt.Errorf("%w: node %q db was saved with %s, can't restore it onto %v", ErrIncompatibleDBType, nodeConfig.Name, snapshotDBType, dbType)
+	}
+	return nil
+}
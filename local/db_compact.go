@@ -0,0 +1,167 @@
+// Copyright (C) 2021-2024, Lux Partners Limited. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package local
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"time"
+
+	"github.com/luxdefi/netrunner/network"
+	"github.com/luxdefi/netrunner/network/node/status"
+	"github.com/luxdefi/netrunner/utils"
+	"github.com/luxdefi/node/config"
+	"github.com/syndtr/goleveldb/leveldb"
+	"github.com/syndtr/goleveldb/leveldb/util"
+	"go.uber.org/zap"
+	"golang.org/x/exp/maps"
+)
+
+const (
+	// C-chain config keys of coreth offline pruning, run on vm startup
+	offlinePruningEnabledKey = "offline-pruning-enabled"
+	offlinePruningDataDirKey = "offline-pruning-data-directory"
+	offlinePruningDirName    = "offline-pruning"
+)
+
+// See network.Network
+// Pruning starts the node once with coreth offline pruning enabled, waiting
+// until it is healthy, as pruning is done by the node itself on startup.
+// Compaction is done on the leveldb files.
+func (ln *localNetwork) CompactNodeDB(ctx context.Context, nodeName string, prune bool) (_ network.DBCompaction, err error) {
+	defer utils.StartOperation(ln.log, "compact-node-db", utils.NodeField(nodeName))(&err)
+
+	ln.lock.Lock()
+	defer ln.lock.Unlock()
+
+	if ln.stopCalled() {
+		return network.DBCompaction{}, network.ErrStopped
+	}
+	node, ok := ln.nodes[nodeName]
+	if !ok {
+		return network.DBCompaction{}, fmt.Errorf("%w: %q", network.ErrNodeNotFound, nodeName)
+	}
+	if !node.paused {
+		return network.DBCompaction{}, fmt.Errorf("node %q must be paused before compacting its db", nodeName)
+	}
+	if !isLevelDB(node.config) {
+		return network.DBCompaction{}, fmt.Errorf("can't compact %s db of node %q: only leveldb is supported", node.config.DBType, nodeName)
+	}
+	dbDir := node.GetDbDir()
+	sizeBefore, err := utils.DirSize(dbDir)
+	if err != nil {
+		return network.DBCompaction{}, err
+	}
+	if prune {
+		if err := ln.pruneNodeDB(ctx, nodeName); err != nil {
+			return network.DBCompaction{}, fmt.Errorf("couldn't prune db of node %q: %w", nodeName, err)
+		}
+	}
+	dbPath, err := findDBPath(dbDir)
+	if err != nil {
+		return network.DBCompaction{}, err
+	}
+	if err := compactDB(dbPath); err != nil {
+		return network.DBCompaction{}, fmt.Errorf("couldn't compact db of node %q: %w", nodeName, err)
+	}
+	sizeAfter, err := utils.DirSize(dbDir)
+	if err != nil {
+		return network.DBCompaction{}, err
+	}
+	compaction := network.DBCompaction{
+		SizeBefore: sizeBefore,
+		SizeAfter:  sizeAfter,
+		Pruned:     prune,
+	}
+	if sizeAfter < sizeBefore {
+		compaction.Reclaimed = sizeBefore - sizeAfter
+	}
+	ln.log.Info("compacted node db",
+		utils.NodeField(nodeName),
+		zap.Bool("pruned", prune),
+		zap.Uint64("reclaimed", compaction.Reclaimed),
+	)
+	return compaction, nil
+}
+
+// Runs coreth offline pruning on the paused [nodeName] by resuming it
+// with pruning enabled in its C-chain config, then pausing it again.
+// The node keeps its original config.
+// Assumes [ln.lock] is held.
+func (ln *localNetwork) pruneNodeDB(ctx context.Context, nodeName string) error {
+	node := ln.nodes[nodeName]
+	originalChainConfigFiles := node.config.ChainConfigFiles
+
+	nodeConfig := node.GetConfig()
+	nodeConfig.ChainConfigFiles = maps.Clone(nodeConfig.ChainConfigFiles)
+	if nodeConfig.ChainConfigFiles == nil {
+		nodeConfig.ChainConfigFiles = map[string]string{}
+	}
+	chainConfig := map[string]interface{}{}
+	if chainConfigFile := nodeConfig.ChainConfigFiles["C"]; chainConfigFile != "" {
+		if err := json.Unmarshal([]byte(chainConfigFile), &chainConfig); err != nil {
+			return fmt.Errorf("couldn't unmarshal C chain config: %w", err)
+		}
+	}
+	chainConfig[offlinePruningEnabledKey] = true
+	chainConfig[offlinePruningDataDirKey] = filepath.Join(node.GetDataDir(), offlinePruningDirName)
+	chainConfigBytes, err := json.Marshal(chainConfig)
+	if err != nil {
+		return err
+	}
+	nodeConfig.ChainConfigFiles["C"] = string(chainConfigBytes)
+
+	nodeConfig.Flags[config.DataDirKey] = node.GetDataDir()
+	nodeConfig.Flags[config.DBPathKey] = node.GetDbDir()
+	nodeConfig.Flags[config.LogsDirKey] = node.GetLogsDir()
+	nodeConfig.Flags[config.HTTPPortKey] = int(node.GetAPIPort())
+	nodeConfig.Flags[config.StakingPortKey] = int(node.GetP2PPort())
+	if _, err := ln.addNode(nodeConfig); err != nil {
+		return err
+	}
+	healthErr := ln.waitNodeHealthy(ctx, nodeName)
+	if err := ln.pauseNode(ctx, nodeName); err != nil {
+		return err
+	}
+	// the next resume must not prune again
+	ln.nodes[nodeName].config.ChainConfigFiles = originalChainConfigFiles
+	return healthErr
+}
+
+// Waits until [nodeName] is healthy, or [ctx] is done.
+// Assumes [ln.lock] is held.
+func (ln *localNetwork) waitNodeHealthy(ctx context.Context, nodeName string) error {
+	node := ln.nodes[nodeName]
+	for {
+		if node.Status() != status.Running {
+			return fmt.Errorf("node %q stopped unexpectedly", nodeName)
+		}
+		health, err := node.client.HealthAPI().Health(ctx, nil)
+		if err == nil && health.Healthy {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("node %q failed to become healthy within timeout: %w", nodeName, ctx.Err())
+		case <-ln.onStopCh:
+			return network.ErrStopped
+		case <-time.After(healthCheckFreq):
+		}
+	}
+}
+
+// Compacts the whole key range of the leveldb db at [dbPath]
+func compactDB(dbPath string) error {
+	db, err := leveldb.OpenFile(dbPath, nil)
+	if err != nil {
+		return err
+	}
+	if err := db.CompactRange(util.Range{}); err != nil {
+		_ = db.Close()
+		return err
+	}
+	return db.Close()
+}
@@ -0,0 +1,169 @@
+// Copyright (C) 2021-2024, Lux Partners Limited. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package local
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/luxdefi/netrunner/network"
+	"github.com/luxdefi/netrunner/utils"
+	"go.uber.org/zap"
+)
+
+var errNoDBFileMatched = errors.New("no db file matched")
+
+// See network.Network
+func (ln *localNetwork) CorruptNodeDB(_ context.Context, nodeName string, spec network.DBCorruptionSpec) ([]string, error) {
+	ln.lock.Lock()
+	defer ln.lock.Unlock()
+
+	if ln.stopCalled() {
+		return nil, network.ErrStopped
+	}
+	node, ok := ln.nodes[nodeName]
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", network.ErrNodeNotFound, nodeName)
+	}
+	if !node.paused {
+		return nil, fmt.Errorf("node %q must be paused before corrupting its db", nodeName)
+	}
+	if !hasPersistentDB(node.config) {
+		return nil, fmt.Errorf("node %q uses an in memory db", nodeName)
+	}
+	files, err := corruptDBFiles(node.GetDbDir(), spec)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't corrupt db of node %q: %w", nodeName, err)
+	}
+	ln.log.Info("corrupted node db",
+		utils.NodeField(nodeName),
+		zap.String("mode", spec.Mode),
+		zap.Strings("files", files),
+	)
+	return files, nil
+}
+
+// Damages the files under [dbDir] selected by [spec], in path order
+// so that the same seed damages the same bytes.
+// Returns the damaged files, relative to [dbDir].
+func corruptDBFiles(dbDir string, spec network.DBCorruptionSpec) ([]string, error) {
+	switch spec.Mode {
+	case network.DBCorruptionTruncate, network.DBCorruptionFlipBytes, network.DBCorruptionDelete:
+	default:
+		return nil, fmt.Errorf("unknown corruption mode %q", spec.Mode)
+	}
+	if spec.NumBytes < 0 {
+		return nil, fmt.Errorf("invalid number of bytes %d", spec.NumBytes)
+	}
+	files, err := matchDBFiles(dbDir, spec.Files)
+	if err != nil {
+		return nil, err
+	}
+	if len(files) == 0 {
+		return nil, fmt.Errorf("%w %v in %s", errNoDBFileMatched, spec.Files, dbDir)
+	}
+	rng := rand.New(rand.NewSource(spec.Seed)) //nolint:gosec
+	for _, file := range files {
+		path := filepath.Join(dbDir, file)
+		switch spec.Mode {
+		case network.DBCorruptionTruncate:
+			err = truncateFile(path, spec.NumBytes)
+		case network.DBCorruptionFlipBytes:
+			err = flipFileBytes(path, spec.NumBytes, rng)
+		case network.DBCorruptionDelete:
+			err = os.Remove(path)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	return files, nil
+}
+
+// Returns the sorted paths, relative to [dbDir], of the regular
+// files whose name or relative path match any of [patterns]
+func matchDBFiles(dbDir string, patterns []string) ([]string, error) {
+	for _, pattern := range patterns {
+		if _, err := filepath.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("invalid file pattern %q: %w", pattern, err)
+		}
+	}
+	files := []string{}
+	err := filepath.WalkDir(dbDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		relPath, err := filepath.Rel(dbDir, path)
+		if err != nil {
+			return err
+		}
+		for _, pattern := range patterns {
+			nameMatch, _ := filepath.Match(pattern, d.Name())
+			pathMatch, _ := filepath.Match(pattern, relPath)
+			if nameMatch || pathMatch {
+				files = append(files, relPath)
+				return nil
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(files)
+	return files, nil
+}
+
+// Removes the last [numBytes] bytes of the file at [path], all if 0
+func truncateFile(path string, numBytes int64) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	size := int64(0)
+	if numBytes > 0 && numBytes < info.Size() {
+		size = info.Size() - numBytes
+	}
+	return os.Truncate(path, size)
+}
+
+// Flips [numBytes] bytes, 1 if 0, at offsets of the file at [path] given by [rng]
+func flipFileBytes(path string, numBytes int64, rng *rand.Rand) error {
+	f, err := os.OpenFile(path, os.O_RDWR, 0)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	if info.Size() == 0 {
+		return nil
+	}
+	if numBytes == 0 {
+		numBytes = 1
+	}
+	b := make([]byte, 1)
+	for i := int64(0); i < numBytes; i++ {
+		offset := rng.Int63n(info.Size())
+		if _, err := f.ReadAt(b, offset); err != nil {
+			return err
+		}
+		b[0] = ^b[0]
+		if _, err := f.WriteAt(b, offset); err != nil {
+			return err
+		}
+	}
+	return f.Sync()
+}
@@ -0,0 +1,344 @@
+// Copyright (C) 2021-2024, Lux Partners Limited. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package local
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+	"sort"
+
+	"github.com/luxdefi/netrunner/network"
+	"github.com/luxdefi/netrunner/utils"
+	"github.com/luxdefi/node/genesis"
+	"github.com/luxdefi/node/ids"
+	"github.com/luxdefi/node/utils/constants"
+	"github.com/luxdefi/node/utils/hashing"
+	"github.com/luxdefi/node/vms/platformvm/status"
+	"github.com/luxdefi/node/vms/platformvm/txs"
+	"github.com/syndtr/goleveldb/leveldb"
+	"github.com/syndtr/goleveldb/leveldb/opt"
+	"github.com/syndtr/goleveldb/leveldb/util"
+	"go.uber.org/zap"
+)
+
+// Layout of the node db. Every db is a prefixdb of its parent: a prefixdb
+// of a prefixdb hashes the joined prefixes, any other prefixdb prepends
+// the hash of its prefix to the keys of its parent.
+var (
+	vmDBPrefix            = []byte("vm")
+	bootstrappingDBPrefix = []byte("bs")
+
+	// platformvm state
+	platformSingletonPrefix  = []byte("singleton")
+	platformLastAcceptedKey  = []byte("last accepted")
+	platformValidatorsPrefix = []byte("validators")
+	platformCurrentPrefix    = []byte("current")
+	platformValidatorPrefix  = []byte("validator")
+	platformTxPrefix         = []byte("tx")
+
+	// avm state
+	avmSingletonPrefix = []byte("singleton")
+	avmLastAcceptedKey = []byte{0x02}
+
+	// coreth and subnet-evm
+	evmAcceptedPrefix    = []byte("snowman_accepted")
+	evmLastAcceptedKey   = []byte("last_accepted_key")
+	evmEthDBPrefix       = []byte("ethdb")
+	evmHeaderNumberTable = []byte("H")
+
+	errNoDBFound = errors.New("no db found")
+)
+
+// Layout of the chain state of a VM
+type vmKind int
+
+const (
+	platformVM vmKind = iota
+	avmVM
+	evmVM
+)
+
+type dbChain struct {
+	alias string
+	id    ids.ID
+	kind  vmKind
+}
+
+// txBytesAndStatus is the platformvm state encoding of a tx
+type txBytesAndStatus struct {
+	Tx     []byte        `serialize:"true"`
+	Status status.Status `serialize:"true"`
+}
+
+// See network.Network
+func (ln *localNetwork) InspectNodeDB(_ context.Context, nodeName string) (network.DBInfo, error) {
+	ln.lock.RLock()
+	defer ln.lock.RUnlock()
+
+	if ln.stopCalled() {
+		return network.DBInfo{}, network.ErrStopped
+	}
+	node, ok := ln.nodes[nodeName]
+	if !ok {
+		return network.DBInfo{}, fmt.Errorf("%w: %q", network.ErrNodeNotFound, nodeName)
+	}
+	if !node.paused {
+		return network.DBInfo{}, fmt.Errorf("node %q must be paused before inspecting its db", nodeName)
+	}
+	if !isLevelDB(node.config) {
+		return network.DBInfo{}, fmt.Errorf("can't inspect %s db of node %q: only leveldb is supported", node.config.DBType, nodeName)
+	}
+	chains, err := primaryChains(ln.genesis)
+	if err != nil {
+		return network.DBInfo{}, err
+	}
+	// chain configs are keyed by alias or blockchain ID, the latter
+	// being the only place custom chains are known from
+	for chain := range node.config.ChainConfigFiles {
+		chainID, err := ids.FromString(chain)
+		if err != nil {
+			continue
+		}
+		chains = append(chains, dbChain{alias: chain, id: chainID, kind: evmVM})
+	}
+	info, err := inspectDB(node.GetDbDir(), chains)
+	if err != nil {
+		return network.DBInfo{}, fmt.Errorf("couldn't inspect db of node %q: %w", nodeName, err)
+	}
+	ln.log.Debug("inspected node db", utils.NodeField(nodeName), zap.String("path", info.Path))
+	return info, nil
+}
+
+// Returns the P, X and C chains of the network with [genesisJSON]
+func primaryChains(genesisJSON []byte) ([]dbChain, error) {
+	genesisConfig, err := genesis.GetConfigContent(base64.StdEncoding.EncodeToString(genesisJSON))
+	if err != nil {
+		return nil, fmt.Errorf("couldn't parse genesis: %w", err)
+	}
+	genesisBytes, _, err := genesis.FromConfig(genesisConfig)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't build genesis: %w", err)
+	}
+	chains := []dbChain{{alias: "P", id: constants.PlatformChainID, kind: platformVM}}
+	for _, chain := range []struct {
+		alias string
+		vmID  ids.ID
+		kind  vmKind
+	}{
+		{alias: "X", vmID: constants.AVMID, kind: avmVM},
+		{alias: "C", vmID: constants.EVMID, kind: evmVM},
+	} {
+		tx, err := genesis.VMGenesis(genesisBytes, chain.vmID)
+		if err != nil {
+			return nil, err
+		}
+		chains = append(chains, dbChain{alias: chain.alias, id: tx.ID(), kind: chain.kind})
+	}
+	return chains, nil
+}
+
+// Opens read only the leveldb db under [dbDir] and summarizes the state of [chains]
+func inspectDB(dbDir string, chains []dbChain) (network.DBInfo, error) {
+	dbPath, err := findDBPath(dbDir)
+	if err != nil {
+		return network.DBInfo{}, err
+	}
+	db, err := leveldb.OpenFile(dbPath, &opt.Options{ReadOnly: true})
+	if err != nil {
+		return network.DBInfo{}, err
+	}
+	defer db.Close()
+
+	info := network.DBInfo{
+		Path:        dbPath,
+		Chains:      []network.ChainDBInfo{},
+		Validators:  []network.DBValidator{},
+		PrefixSizes: map[string]int64{},
+	}
+	info.DiskSize, err = utils.DirSize(dbPath)
+	if err != nil {
+		return network.DBInfo{}, err
+	}
+	// owners of the 32 bytes key prefixes
+	owners := map[string]string{}
+	for _, chain := range chains {
+		chainPrefix := hashing.ComputeHash256(chain.id[:])
+		owners[string(joinPrefixes(chainPrefix, vmDBPrefix))] = chain.alias + "/vm"
+		owners[string(joinPrefixes(chainPrefix, bootstrappingDBPrefix))] = chain.alias + "/bootstrap"
+
+		chainInfo := network.ChainDBInfo{
+			Chain:   chain.alias,
+			ChainID: chain.id.String(),
+		}
+		vmPrefix := joinPrefixes(chainPrefix, vmDBPrefix)
+		if err := inspectLastAccepted(db, vmPrefix, chain.kind, &chainInfo); err != nil {
+			return network.DBInfo{}, fmt.Errorf("chain %s: %w", chain.alias, err)
+		}
+		info.Chains = append(info.Chains, chainInfo)
+		if chain.kind == platformVM {
+			info.Validators, err = inspectValidators(db, vmPrefix)
+			if err != nil {
+				return network.DBInfo{}, err
+			}
+		}
+	}
+	iter := db.NewIterator(nil, nil)
+	defer iter.Release()
+	for iter.Next() {
+		key := iter.Key()
+		owner := "other"
+		if len(key) >= hashing.HashLen {
+			if o, ok := owners[string(key[:hashing.HashLen])]; ok {
+				owner = o
+			}
+		}
+		info.PrefixSizes[owner] += int64(len(key) + len(iter.Value()))
+	}
+	return info, iter.Error()
+}
+
+// Sets the last accepted block of the chain with the [kind] state
+// layout and vm db [vmPrefix] into [chainInfo], if found
+func inspectLastAccepted(db *leveldb.DB, vmPrefix []byte, kind vmKind, chainInfo *network.ChainDBInfo) error {
+	var key []byte
+	switch kind {
+	case platformVM:
+		key = nestedKey(vmPrefix, platformSingletonPrefix, platformLastAcceptedKey)
+	case avmVM:
+		key = nestedKey(vmPrefix, avmSingletonPrefix, avmLastAcceptedKey)
+	case evmVM:
+		key = nestedKey(vmPrefix, evmAcceptedPrefix, evmLastAcceptedKey)
+	}
+	blkIDBytes, err := db.Get(key, nil)
+	if errors.Is(err, leveldb.ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	blkID, err := ids.ToID(blkIDBytes)
+	if err != nil {
+		return err
+	}
+	chainInfo.LastAcceptedBlockID = blkID.String()
+	if kind != evmVM {
+		return nil
+	}
+	// the eth db maps block hashes to heights
+	numberKey := append(append([]byte{}, evmHeaderNumberTable...), blkIDBytes...)
+	heightBytes, err := db.Get(nestedKey(vmPrefix, evmEthDBPrefix, numberKey), nil)
+	if errors.Is(err, leveldb.ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if len(heightBytes) == 8 {
+		height := binary.BigEndian.Uint64(heightBytes)
+		chainInfo.LastAcceptedHeight = &height
+	}
+	return nil
+}
+
+// Returns the current primary network validators in
+// the platformvm state under the vm db [vmPrefix]
+func inspectValidators(db *leveldb.DB, vmPrefix []byte) ([]network.DBValidator, error) {
+	validatorsPrefix := append(
+		append([]byte{}, vmPrefix...),
+		joinPrefixes(
+			joinPrefixes(hashing.ComputeHash256(platformValidatorsPrefix), platformCurrentPrefix),
+			platformValidatorPrefix,
+		)...,
+	)
+	validators := []network.DBValidator{}
+	iter := db.NewIterator(util.BytesPrefix(validatorsPrefix), nil)
+	defer iter.Release()
+	for iter.Next() {
+		// the validators are in a linked db, whose nodes are keyed by
+		// a zero byte followed by the validator tx ID
+		key := iter.Key()[len(validatorsPrefix):]
+		if len(key) != 1+ids.IDLen || key[0] != 0 {
+			continue
+		}
+		txID, err := ids.ToID(key[1:])
+		if err != nil {
+			return nil, err
+		}
+		txBytes, err := db.Get(nestedKey(vmPrefix, platformTxPrefix, txID[:]), nil)
+		if err != nil {
+			return nil, fmt.Errorf("couldn't get validator tx %s: %w", txID, err)
+		}
+		stx := txBytesAndStatus{}
+		if _, err := txs.GenesisCodec.Unmarshal(txBytes, &stx); err != nil {
+			return nil, err
+		}
+		tx, err := txs.Parse(txs.GenesisCodec, stx.Tx)
+		if err != nil {
+			return nil, err
+		}
+		staker, ok := tx.Unsigned.(interface {
+			NodeID() ids.NodeID
+			Weight() uint64
+		})
+		if !ok {
+			continue
+		}
+		validators = append(validators, network.DBValidator{
+			NodeID: staker.NodeID().String(),
+			TxID:   txID.String(),
+			Weight: staker.Weight(),
+		})
+	}
+	if err := iter.Error(); err != nil {
+		return nil, err
+	}
+	sort.Slice(validators, func(i, j int) bool {
+		return validators[i].NodeID < validators[j].NodeID
+	})
+	return validators, nil
+}
+
+// Returns the key of [key] in a db with [prefix] nested into the db with [parentPrefix]
+func nestedKey(parentPrefix []byte, prefix []byte, key []byte) []byte {
+	nested := make([]byte, 0, len(parentPrefix)+hashing.HashLen+len(key))
+	nested = append(nested, parentPrefix...)
+	nested = append(nested, hashing.ComputeHash256(prefix)...)
+	return append(nested, key...)
+}
+
+// Returns the prefix of a db with [prefix] joined to the db with [parentPrefix]
+func joinPrefixes(parentPrefix []byte, prefix []byte) []byte {
+	joined := make([]byte, 0, len(parentPrefix)+len(prefix))
+	joined = append(joined, parentPrefix...)
+	joined = append(joined, prefix...)
+	return hashing.ComputeHash256(joined)
+}
+
+// Returns the dir under [dbDir] holding the db files, that is
+// <dbDir>/<network name>/<db version>. Picks the latest version.
+func findDBPath(dbDir string) (string, error) {
+	dbPaths := []string{}
+	err := filepath.WalkDir(dbDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() && d.Name() == "CURRENT" {
+			dbPaths = append(dbPaths, filepath.Dir(path))
+		}
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	if len(dbPaths) == 0 {
+		return "", fmt.Errorf("%w in %s", errNoDBFound, dbDir)
+	}
+	sort.Strings(dbPaths)
+	return dbPaths[len(dbPaths)-1], nil
+}
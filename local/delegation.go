@@ -0,0 +1,147 @@
+// Copyright (C) 2021-2024, Lux Partners Limited. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package local
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/luxdefi/netrunner/network"
+	"github.com/luxdefi/netrunner/utils"
+	"github.com/luxdefi/node/genesis"
+	"github.com/luxdefi/node/ids"
+	"github.com/luxdefi/node/vms/platformvm"
+	"github.com/luxdefi/node/vms/platformvm/txs"
+	"github.com/luxdefi/node/vms/secp256k1fx"
+	"github.com/luxdefi/node/wallet/subnet/primary/common"
+	"go.uber.org/zap"
+)
+
+var ErrNotValidator = errors.New("node is not a current validator")
+
+// See network.Network
+func (ln *localNetwork) AddDelegators(ctx context.Context, specs []network.DelegatorSpec) (_ []ids.ID, err error) {
+	defer utils.StartOperation(ln.log, "add-delegators", zap.Int("num-of-delegators", len(specs)))(&err)
+
+	ln.lock.Lock()
+	defer ln.lock.Unlock()
+
+	if ln.stopCalled() {
+		return nil, network.ErrStopped
+	}
+	return ln.addDelegators(ctx, specs)
+}
+
+// Assumes [ln.lock] is held and [ln.Stop] hasn't been called.
+func (ln *localNetwork) addDelegators(ctx context.Context, specs []network.DelegatorSpec) ([]ids.ID, error) {
+	subnetIDs := make([]ids.ID, len(specs))
+	assetIDs := make([]ids.ID, len(specs))
+	preloadTXs := []ids.ID{}
+	for i, spec := range specs {
+		if _, ok := ln.nodes[spec.NodeName]; !ok {
+			return nil, fmt.Errorf("%w: %q", network.ErrNodeNotFound, spec.NodeName)
+		}
+		if spec.SubnetID == "" {
+			continue
+		}
+		var err error
+		subnetIDs[i], err = ids.FromString(spec.SubnetID)
+		if err != nil {
+			return nil, err
+		}
+		assetIDs[i], err = ids.FromString(spec.AssetID)
+		if err != nil {
+			return nil, fmt.Errorf("invalid asset ID of elastic subnet %s: %w", spec.SubnetID, err)
+		}
+		// wallet needs the txs of the subnets
+		preloadTXs = append(preloadTXs, subnetIDs[i])
+	}
+	clientURI, err := ln.getClientURI()
+	if err != nil {
+		return nil, err
+	}
+	platformCli := platformvm.NewClient(clientURI)
+	w, err := newWallet(ctx, clientURI, preloadTXs)
+	if err != nil {
+		return nil, err
+	}
+	rewardsOwner := &secp256k1fx.OutputOwners{
+		Threshold: 1,
+		Addrs:     []ids.ShortID{w.addr},
+	}
+
+	txIDs := make([]ids.ID, 0, len(specs))
+	for i, spec := range specs {
+		nodeID := ln.nodes[spec.NodeName].GetNodeID()
+		validationEnd, err := getValidationEnd(ctx, platformCli, subnetIDs[i], nodeID)
+		if err != nil {
+			return nil, fmt.Errorf("couldn't delegate to node %q: %w", spec.NodeName, err)
+		}
+		startTime := spec.StartTime
+		if startTime.IsZero() {
+			startTime = time.Now().Add(validationStartOffset)
+		}
+		endTime := validationEnd
+		if spec.StakeDuration != 0 {
+			endTime = startTime.Add(spec.StakeDuration)
+		}
+		amount := spec.StakedAmount
+		if amount == 0 {
+			amount = genesis.LocalParams.MinDelegatorStake
+		}
+		assetID := w.pWallet.LUXAssetID()
+		if subnetIDs[i] != ids.Empty {
+			assetID = assetIDs[i]
+		}
+
+		cctx, cancel := createDefaultCtx(ctx)
+		txID, err := w.pWallet.IssueAddPermissionlessDelegatorTx(
+			&txs.SubnetValidator{
+				Validator: txs.Validator{
+					NodeID: nodeID,
+					Start:  uint64(startTime.Unix()),
+					End:    uint64(endTime.Unix()),
+					Wght:   amount,
+				},
+				Subnet: subnetIDs[i],
+			},
+			assetID,
+			rewardsOwner,
+			common.WithContext(cctx),
+			defaultPoll,
+		)
+		cancel()
+		if err != nil {
+			return nil, fmt.Errorf("couldn't delegate to node %q: %w", spec.NodeName, err)
+		}
+		ln.log.Info("added delegator",
+			utils.NodeField(spec.NodeName),
+			zap.Stringer("subnet-ID", subnetIDs[i]),
+			zap.Stringer("tx-ID", txID),
+			zap.Uint64("amount", amount),
+			zap.Time("start", startTime),
+			zap.Time("end", endTime),
+		)
+		txIDs = append(txIDs, txID)
+	}
+	return txIDs, nil
+}
+
+// Returns the end of the current validation of [nodeID] on [subnetID]
+func getValidationEnd(ctx context.Context, platformCli platformvm.Client, subnetID ids.ID, nodeID ids.NodeID) (time.Time, error) {
+	cctx, cancel := createDefaultCtx(ctx)
+	defer cancel()
+	vdrs, err := platformCli.GetCurrentValidators(cctx, subnetID, []ids.NodeID{nodeID})
+	if err != nil {
+		return time.Time{}, err
+	}
+	for _, vdr := range vdrs {
+		if vdr.NodeID == nodeID {
+			return time.Unix(int64(vdr.EndTime), 0), nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("%w of subnet %s", ErrNotValidator, subnetID)
+}
@@ -0,0 +1,146 @@
+// Copyright (C) 2021-2024, Lux Partners Limited. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package local
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/luxdefi/netrunner/network"
+	"github.com/luxdefi/netrunner/utils"
+	"go.uber.org/zap"
+)
+
+const diskUsageCheckFreq = 30 * time.Second
+
+var ErrDiskBudgetExceeded = errors.New("network disk budget exceeded")
+
+// Measures the disk usage of the network every [diskUsageCheckFreq]
+// until the network is stopped
+func (ln *localNetwork) monitorDiskUsage() {
+	for {
+		ln.updateDiskUsage()
+		select {
+		case <-ln.onStopCh:
+			return
+		case <-time.After(diskUsageCheckFreq):
+		}
+	}
+}
+
+// Measures the disk usage of the network, logging an error
+// when it goes over the budget
+func (ln *localNetwork) updateDiskUsage() {
+	ln.lock.RLock()
+	rootDir := ln.rootDir
+	nodeDirs := make(map[string][]string, len(ln.nodes))
+	for nodeName, node := range ln.nodes {
+		nodeDirs[nodeName] = []string{node.GetDataDir(), node.GetDbDir(), node.GetLogsDir()}
+	}
+	ln.lock.RUnlock()
+
+	usage, err := measureDiskUsage(rootDir, nodeDirs)
+	if err != nil {
+		ln.log.Debug("couldn't measure disk usage", zap.Error(err))
+		return
+	}
+	usage.Budget = ln.diskBudget
+
+	ln.diskUsageLock.Lock()
+	wasOverBudget := isOverBudget(ln.diskUsage)
+	ln.diskUsage = usage
+	ln.diskUsageLock.Unlock()
+
+	if isOverBudget(usage) && !wasOverBudget {
+		ln.log.Error("network disk budget exceeded",
+			zap.Uint64("usage", usage.Total),
+			zap.Uint64("budget", usage.Budget),
+			zap.Any("nodes", usage.Nodes),
+		)
+	}
+}
+
+// Returns ErrDiskBudgetExceeded if the last measured usage is over the budget
+func (ln *localNetwork) checkDiskBudget() error {
+	ln.diskUsageLock.RLock()
+	defer ln.diskUsageLock.RUnlock()
+
+	if isOverBudget(ln.diskUsage) {
+		return fmt.Errorf("%w: using %d bytes of %d", ErrDiskBudgetExceeded, ln.diskUsage.Total, ln.diskUsage.Budget)
+	}
+	return nil
+}
+
+// See network.Network
+func (ln *localNetwork) GetDiskUsage() (network.DiskUsage, error) {
+	if ln.stopCalled() {
+		return network.DiskUsage{}, network.ErrStopped
+	}
+
+	ln.diskUsageLock.RLock()
+	defer ln.diskUsageLock.RUnlock()
+
+	return ln.diskUsage, nil
+}
+
+func isOverBudget(usage network.DiskUsage) bool {
+	return usage.Budget > 0 && usage.Total > usage.Budget
+}
+
+// Returns the usage of [rootDir] and of the dirs of each node in [nodeDirs].
+// Dirs nested into another measured dir are only counted once.
+func measureDiskUsage(rootDir string, nodeDirs map[string][]string) (network.DiskUsage, error) {
+	usage := network.DiskUsage{
+		Nodes:     make(map[string]uint64, len(nodeDirs)),
+		UpdatedAt: time.Now(),
+	}
+	var err error
+	usage.Total, err = utils.DirSize(rootDir)
+	if err != nil {
+		return network.DiskUsage{}, err
+	}
+	for nodeName, dirs := range nodeDirs {
+		for _, dir := range outermostDirs(dirs) {
+			size, err := utils.DirSize(dir)
+			if err != nil {
+				return network.DiskUsage{}, err
+			}
+			usage.Nodes[nodeName] += size
+			if !isSubDir(rootDir, dir) {
+				usage.Total += size
+			}
+		}
+	}
+	return usage, nil
+}
+
+// Returns the non empty [dirs] not nested into another one of [dirs]
+func outermostDirs(dirs []string) []string {
+	outermost := []string{}
+	for i, dir := range dirs {
+		if dir == "" {
+			continue
+		}
+		nested := false
+		for j, other := range dirs {
+			if i != j && other != "" && isSubDir(other, dir) && (other != dir || j < i) {
+				nested = true
+				break
+			}
+		}
+		if !nested {
+			outermost = append(outermost, dir)
+		}
+	}
+	return outermost
+}
+
+// Returns true if [dir] is [parent] or is under it
+func isSubDir(parent string, dir string) bool {
+	rel, err := filepath.Rel(parent, dir)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
@@ -0,0 +1,264 @@
+// Copyright (C) 2021-2024, Lux Partners Limited. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package local
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/luxdefi/netrunner/network"
+	"github.com/luxdefi/netrunner/utils"
+	"github.com/luxdefi/node/config"
+	"github.com/luxdefi/node/ids"
+	"github.com/luxdefi/node/utils/set"
+	"github.com/luxdefi/node/vms/platformvm"
+	"github.com/luxdefi/node/wallet/subnet/primary/common"
+	psnet "github.com/shirou/gopsutil/net"
+	"go.uber.org/zap"
+)
+
+const (
+	// max time to wait for the API connections of a drained node to be closed
+	defaultDrainTimeout = 30 * time.Second
+	// how often the API connections of a drained node are counted
+	drainCheckInterval = 250 * time.Millisecond
+	// max time a drained node is given to exit once sent a SIGTERM,
+	// if not given, as the removal holds the network lock meanwhile
+	defaultDrainGracePeriod = 30 * time.Second
+)
+
+// See network.Network
+func (ln *localNetwork) DrainNode(ctx context.Context, nodeName string, opts network.DrainOptions) (err error) {
+	defer utils.StartOperation(ln.log, "drain-node", utils.NodeField(nodeName))(&err)
+
+	ln.lock.Lock()
+	defer ln.lock.Unlock()
+
+	if ln.stopCalled() {
+		return network.ErrStopped
+	}
+	node, ok := ln.nodes[nodeName]
+	if !ok {
+		return fmt.Errorf("%w: %q", network.ErrNodeNotFound, nodeName)
+	}
+	timeout := opts.Timeout
+	if timeout == 0 {
+		timeout = defaultDrainTimeout
+	}
+	// connected peers of the other nodes before the node is stopped
+	var peers map[string]int
+	// a paused node has neither connections nor a running validator to remove
+	if !node.paused {
+		if opts.RemoveSubnetValidators {
+			if err := ln.removeFromSubnets(ctx, node); err != nil {
+				return err
+			}
+		}
+		// the websocket of the node client is not an in-flight call
+		node.client.CChainEthAPI().Close()
+		ln.drainAPIConnections(ctx, node, timeout)
+		if opts.AwaitPeerUpdate {
+			// the nodes started from now on don't dial it
+			_ = ln.bootstraps.RemoveByID(node.nodeID)
+			peers = ln.connectedPeers(ctx, nodeName)
+		}
+		terminateNode(ctx, node, opts.GracePeriod)
+	}
+	delete(ln.backups, nodeName)
+	ln.leaveNodeGroups(nodeName)
+	// the node has exited, so only its exit code is checked
+	err = ln.removeNode(ctx, nodeName)
+	if len(peers) != 0 {
+		ln.awaitPeerUpdate(ctx, nodeName, peers, timeout)
+	}
+	return err
+}
+
+// Sends a SIGTERM to [node], and kills it if it's still running after
+// [gracePeriod], or [defaultDrainGracePeriod] if zero, or once [ctx] is done
+func terminateNode(ctx context.Context, node *localNode, gracePeriod time.Duration) {
+	if gracePeriod == 0 {
+		gracePeriod = defaultDrainGracePeriod
+	}
+	ctx, cancel := context.WithTimeout(ctx, gracePeriod)
+	defer cancel()
+	if process, ok := node.process.(TerminableNodeProcess); ok {
+		process.Terminate(ctx)
+		return
+	}
+	node.process.Stop(ctx)
+}
+
+// Issues the txs removing [node] as a validator of the subnets it
+// tracks and validates. The node isn't restarted, as it is removed next.
+// Assumes [ln.lock] is held.
+func (ln *localNetwork) removeFromSubnets(ctx context.Context, node *localNode) error {
+	trackedSubnets, _ := node.GetConfig().Flags[config.TrackSubnetsKey].(string)
+	if trackedSubnets == "" {
+		return nil
+	}
+	clientURI, err := ln.getClientURI()
+	if err != nil {
+		return err
+	}
+	platformCli := platformvm.NewClient(clientURI)
+	subnetIDs := []ids.ID{}
+	for _, s := range strings.Split(trackedSubnets, ",") {
+		subnetID, err := ids.FromString(s)
+		if err != nil {
+			return err
+		}
+		cctx, cancel := createDefaultCtx(ctx)
+		vdrs, err := platformCli.GetCurrentValidators(cctx, subnetID, []ids.NodeID{node.nodeID})
+		cancel()
+		if err != nil {
+			return err
+		}
+		if len(vdrs) > 0 {
+			subnetIDs = append(subnetIDs, subnetID)
+		}
+	}
+	if len(subnetIDs) == 0 {
+		return nil
+	}
+	w, err := newWallet(ctx, clientURI, subnetIDs)
+	if err != nil {
+		return err
+	}
+	for _, subnetID := range subnetIDs {
+		cctx, cancel := createDefaultCtx(ctx)
+		txID, err := w.pWallet.IssueRemoveSubnetValidatorTx(
+			node.nodeID,
+			subnetID,
+			common.WithContext(cctx),
+			defaultPoll,
+		)
+		cancel()
+		if err != nil {
+			return fmt.Errorf("couldn't remove node %q as validator of subnet %s: %w", node.name, subnetID, err)
+		}
+		ln.log.Info("removed node as subnet validator",
+			utils.NodeField(node.name),
+			zap.String("subnet-ID", subnetID.String()),
+			zap.String("tx-ID", txID.String()),
+		)
+	}
+	return nil
+}
+
+// Waits up to [timeout] for the API connections to [node] to be closed.
+// The node is removed anyway once it passes, so it is only logged.
+// Assumes [ln.lock] is held.
+func (ln *localNetwork) drainAPIConnections(ctx context.Context, node *localNode, timeout time.Duration) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	for {
+		conns, err := apiConnections(node.process.PID(), node.apiPort)
+		if err != nil {
+			ln.log.Warn("couldn't count API connections of node", utils.NodeField(node.name), zap.Error(err))
+			return
+		}
+		if conns == 0 {
+			ln.log.Debug("drained node", utils.NodeField(node.name))
+			return
+		}
+		select {
+		case <-ctx.Done():
+			ln.log.Warn("node still has API connections after drain timeout",
+				utils.NodeField(node.name),
+				zap.Int("connections", conns),
+				zap.Duration("timeout", timeout),
+			)
+			return
+		case <-time.After(drainCheckInterval):
+		}
+	}
+}
+
+// Returns the connected peers of the running nodes but [nodeName], as
+// reported by their health checks, by node name. The nodes not reporting
+// them are left out.
+// Assumes [ln.lock] is held.
+func (ln *localNetwork) connectedPeers(ctx context.Context, nodeName string) map[string]int {
+	peers := map[string]int{}
+	for name, node := range ln.nodes {
+		if name == nodeName || node.paused {
+			continue
+		}
+		cctx, cancel := createDefaultCtx(ctx)
+		reply, err := node.client.HealthAPI().Health(cctx, nil)
+		cancel()
+		if progress := newHealthProgress(name, reply, err); progress.ConnectedPeers >= 0 {
+			peers[name] = progress.ConnectedPeers
+		}
+	}
+	return peers
+}
+
+// Waits up to [timeout] for the nodes of [peers] to report fewer connected
+// peers than in [peers], once the node [nodeName] is stopped. It is removed
+// anyway once it passes, so it is only logged.
+// Assumes [ln.lock] is held.
+func (ln *localNetwork) awaitPeerUpdate(ctx context.Context, nodeName string, peers map[string]int, timeout time.Duration) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	for {
+		current := ln.connectedPeers(ctx, nodeName)
+		pending := []string{}
+		for name, before := range peers {
+			if n, ok := current[name]; ok && n >= before {
+				pending = append(pending, name)
+			}
+		}
+		if len(pending) == 0 {
+			ln.log.Debug("peers dropped drained node", utils.NodeField(nodeName))
+			return
+		}
+		select {
+		case <-ctx.Done():
+			sort.Strings(pending)
+			ln.log.Warn("nodes still count drained node as peer after drain timeout",
+				utils.NodeField(nodeName),
+				zap.Strings("nodes", pending),
+				zap.Duration("timeout", timeout),
+			)
+			return
+		case <-time.After(drainCheckInterval):
+		}
+	}
+}
+
+// Returns the number of connections established to the API port [apiPort]
+// of the node process [pid], other than the ones of this process (e.g. the
+// idle connections kept alive by the clients of the network).
+func apiConnections(pid int, apiPort uint16) (int, error) {
+	conns, err := psnet.ConnectionsPid("tcp", int32(pid))
+	if err != nil {
+		return 0, err
+	}
+	ownConns, err := psnet.ConnectionsPid("tcp", int32(os.Getpid()))
+	if err != nil {
+		return 0, err
+	}
+	// local addresses of the connections of this process
+	own := set.Set[string]{}
+	for _, conn := range ownConns {
+		own.Add(net.JoinHostPort(conn.Laddr.IP, fmt.Sprint(conn.Laddr.Port)))
+	}
+	n := 0
+	for _, conn := range conns {
+		if conn.Status != "ESTABLISHED" || conn.Laddr.Port != uint32(apiPort) {
+			continue
+		}
+		if !own.Contains(net.JoinHostPort(conn.Raddr.IP, fmt.Sprint(conn.Raddr.Port))) {
+			n++
+		}
+	}
+	return n, nil
+}
@@ -0,0 +1,133 @@
+// Copyright (C) 2021-2024, Lux Partners Limited. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package local
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/luxdefi/netrunner/network"
+	"github.com/luxdefi/node/utils/logging"
+	"go.uber.org/zap"
+)
+
+// events buffered per subscriber, beyond which its events are dropped
+const eventBufferSize = 256
+
+// eventSubscription receives the events of its kinds
+type eventSubscription struct {
+	// kinds of the events received, all if empty
+	kinds map[network.EventKind]struct{}
+	ch    chan network.Event
+}
+
+// eventBus delivers the lifecycle events of a network to its subscribers
+type eventBus struct {
+	log  logging.Logger
+	lock sync.Mutex
+	subs map[*eventSubscription]struct{}
+	// closed once the network is stopped, closing the subscriptions
+	closed chan struct{}
+}
+
+func newEventBus(log logging.Logger) *eventBus {
+	return &eventBus{
+		log:    log,
+		subs:   map[*eventSubscription]struct{}{},
+		closed: make(chan struct{}),
+	}
+}
+
+// Returns a subscription to the events of [kinds], or all if empty,
+// removed once [ctx] is done or the bus is closed
+func (b *eventBus) subscribe(ctx context.Context, kinds []network.EventKind) <-chan network.Event {
+	sub := &eventSubscription{
+		kinds: make(map[network.EventKind]struct{}, len(kinds)),
+		ch:    make(chan network.Event, eventBufferSize),
+	}
+	for _, kind := range kinds {
+		sub.kinds[kind] = struct{}{}
+	}
+	b.lock.Lock()
+	b.subs[sub] = struct{}{}
+	b.lock.Unlock()
+
+	go func() {
+		select {
+		case <-ctx.Done():
+		case <-b.closed:
+		}
+		b.lock.Lock()
+		defer b.lock.Unlock()
+
+		if _, ok := b.subs[sub]; ok {
+			delete(b.subs, sub)
+			close(sub.ch)
+		}
+	}()
+	return sub.ch
+}
+
+// Delivers the event of [kind] about [nodeName], if not empty,
+// to the subscribers of [kind], without blocking. Does nothing
+// on a nil bus, so that networks built bare need none.
+func (b *eventBus) publish(kind network.EventKind, nodeName string, err error) {
+	if b == nil {
+		return
+	}
+	event := network.Event{
+		Kind:     kind,
+		NodeName: nodeName,
+		Time:     time.Now(),
+		Err:      err,
+	}
+
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	for sub := range b.subs {
+		if len(sub.kinds) != 0 {
+			if _, ok := sub.kinds[kind]; !ok {
+				continue
+			}
+		}
+		select {
+		case sub.ch <- event:
+		default:
+			b.log.Debug("dropped event for slow subscriber", zap.Stringer("event", event))
+		}
+	}
+}
+
+// Closes the subscriptions. Their buffered events are still received.
+func (b *eventBus) close() {
+	if b == nil {
+		return
+	}
+	b.lock.Lock()
+	defer b.lock.Unlock()
+
+	select {
+	case <-b.closed:
+		return
+	default:
+	}
+	close(b.closed)
+	for sub := range b.subs {
+		delete(b.subs, sub)
+		close(sub.ch)
+	}
+}
+
+// See network.Network
+func (ln *localNetwork) SubscribeEvents(ctx context.Context, kinds ...network.EventKind) (<-chan network.Event, error) {
+	ln.lock.RLock()
+	defer ln.lock.RUnlock()
+
+	if ln.stopCalled() {
+		return nil, network.ErrStopped
+	}
+	return ln.events.subscribe(ctx, kinds), nil
+}
@@ -0,0 +1,82 @@
+// Copyright (C) 2021-2024, Lux Partners Limited. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package local
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/luxdefi/netrunner/network"
+	"github.com/luxdefi/netrunner/utils"
+)
+
+var ErrNotFreezable = errors.New("node process can't be frozen")
+
+// FreezeNode sends a SIGSTOP to the process of [nodeName] and its plugin
+// processes. The node keeps its memory and db state, but doesn't answer
+// its peers nor API calls, as an unresponsive validator would, until
+// UnfreezeNode. Frozen nodes are skipped by the network health checks.
+// Stopping a frozen node unfreezes it first.
+func (ln *localNetwork) FreezeNode(_ context.Context, nodeName string) (err error) {
+	defer utils.StartOperation(ln.log, "freeze-node", utils.NodeField(nodeName))(&err)
+
+	ln.lock.Lock()
+	defer ln.lock.Unlock()
+
+	node, process, err := ln.getFreezableNode(nodeName)
+	if err != nil {
+		return err
+	}
+	if node.frozen {
+		return fmt.Errorf("node %q is frozen already", nodeName)
+	}
+	if err := process.Freeze(); err != nil {
+		return fmt.Errorf("couldn't freeze node %q: %w", nodeName, err)
+	}
+	node.frozen = true
+	return nil
+}
+
+// UnfreezeNode sends a SIGCONT to the frozen
+// [nodeName], which runs again where it was
+func (ln *localNetwork) UnfreezeNode(_ context.Context, nodeName string) (err error) {
+	defer utils.StartOperation(ln.log, "unfreeze-node", utils.NodeField(nodeName))(&err)
+
+	ln.lock.Lock()
+	defer ln.lock.Unlock()
+
+	node, process, err := ln.getFreezableNode(nodeName)
+	if err != nil {
+		return err
+	}
+	if !node.frozen {
+		return fmt.Errorf("node %q is not frozen", nodeName)
+	}
+	if err := process.Unfreeze(); err != nil {
+		return fmt.Errorf("couldn't unfreeze node %q: %w", nodeName, err)
+	}
+	node.frozen = false
+	return nil
+}
+
+// Returns the running [nodeName] and its process.
+// Assumes [ln.lock] is held.
+func (ln *localNetwork) getFreezableNode(nodeName string) (*localNode, FreezableNodeProcess, error) {
+	if ln.stopCalled() {
+		return nil, nil, network.ErrStopped
+	}
+	node, ok := ln.nodes[nodeName]
+	if !ok {
+		return nil, nil, fmt.Errorf("%w: %q", network.ErrNodeNotFound, nodeName)
+	}
+	if node.paused {
+		return nil, nil, fmt.Errorf("node %q is paused", nodeName)
+	}
+	process, ok := node.process.(FreezableNodeProcess)
+	if !ok {
+		return nil, nil, fmt.Errorf("%w: %q", ErrNotFreezable, nodeName)
+	}
+	return node, process, nil
+}
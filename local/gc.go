@@ -0,0 +1,151 @@
+// Copyright (C) 2021-2024, Lux Partners Limited. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package local
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/luxdefi/netrunner/utils"
+)
+
+// file of a network root dir describing the network
+const rootDirMetadataFileName = "metadata.json"
+
+// NetworkRootDir describes a network root dir found on disk
+type NetworkRootDir struct {
+	Path    string    `json:"path"`
+	Size    uint64    `json:"size"`
+	ModTime time.Time `json:"modTime"`
+	// Labels of the network, if its root dir has metadata
+	Labels map[string]string `json:"labels,omitempty"`
+}
+
+// RootDirMetadata describes the network of a root dir,
+// so it can be identified after the network is gone
+type RootDirMetadata struct {
+	// Empty for unnamed networks
+	Name      string            `json:"name,omitempty"`
+	Labels    map[string]string `json:"labels,omitempty"`
+	CreatedAt time.Time         `json:"createdAt"`
+}
+
+// Writes the metadata of the network with [name] and [labels] into [rootDir]
+func writeRootDirMetadata(rootDir string, name string, labels map[string]string) error {
+	metadataJSON, err := json.MarshalIndent(RootDirMetadata{
+		Name:      name,
+		Labels:    labels,
+		CreatedAt: time.Now(),
+	}, "", "    ")
+	if err != nil {
+		return err
+	}
+	return createFileAndWrite(filepath.Join(rootDir, rootDirMetadataFileName), metadataJSON)
+}
+
+// ReadRootDirMetadata returns the metadata of the network root dir [rootDir]
+func ReadRootDirMetadata(rootDir string) (RootDirMetadata, error) {
+	metadataJSON, err := os.ReadFile(filepath.Join(rootDir, rootDirMetadataFileName))
+	if err != nil {
+		return RootDirMetadata{}, err
+	}
+	metadata := RootDirMetadata{}
+	if err := json.Unmarshal(metadataJSON, &metadata); err != nil {
+		return RootDirMetadata{}, fmt.Errorf("couldn't parse metadata of %q: %w", rootDir, err)
+	}
+	return metadata, nil
+}
+
+// FindOrphanedRootDirs returns the network root dirs directly under
+// [parentDirs] that belong to networks that no longer exist:
+// dirs that are not in [inUse], whose lock file (if any) is not held by
+// a live process, and that are not referenced by any running process
+// (e.g. a node using them as data dir).
+func FindOrphanedRootDirs(parentDirs []string, inUse []string) ([]NetworkRootDir, error) {
+	inUseSet := map[string]struct{}{}
+	for _, dir := range inUse {
+		inUseSet[filepath.Clean(dir)] = struct{}{}
+	}
+	cmdLines := processCmdLines()
+	orphans := []NetworkRootDir{}
+	for _, parentDir := range parentDirs {
+		matches, err := filepath.Glob(filepath.Join(parentDir, networkRootDirPrefix+"*"))
+		if err != nil {
+			return nil, err
+		}
+		for _, match := range matches {
+			match = filepath.Clean(match)
+			info, err := os.Stat(match)
+			if err != nil || !info.IsDir() {
+				continue
+			}
+			if _, ok := inUseSet[match]; ok {
+				continue
+			}
+			if pid, err := readLockPID(filepath.Join(match, networkLockFileName)); err == nil && isProcessAlive(pid) {
+				continue
+			}
+			if isReferenced(match, cmdLines) {
+				continue
+			}
+			size, err := utils.DirSize(match)
+			if err != nil {
+				return nil, fmt.Errorf("couldn't compute size of %q: %w", match, err)
+			}
+			// root dirs of older runners have no metadata
+			metadata, _ := ReadRootDirMetadata(match)
+			orphans = append(orphans, NetworkRootDir{
+				Path:    match,
+				Size:    size,
+				ModTime: info.ModTime(),
+				Labels:  metadata.Labels,
+			})
+		}
+	}
+	return orphans, nil
+}
+
+// RemoveRootDirs deletes the given network root dirs
+func RemoveRootDirs(dirs []NetworkRootDir) error {
+	for _, dir := range dirs {
+		if err := os.RemoveAll(dir.Path); err != nil {
+			return fmt.Errorf("couldn't remove %q: %w", dir.Path, err)
+		}
+	}
+	return nil
+}
+
+// Returns the command lines of the running processes.
+// Only supported on systems with procfs; returns nil otherwise.
+func processCmdLines() []string {
+	matches, err := filepath.Glob("/proc/[0-9]*/cmdline")
+	if err != nil {
+		return nil
+	}
+	cmdLines := make([]string, 0, len(matches))
+	for _, match := range matches {
+		b, err := os.ReadFile(match)
+		if err != nil {
+			// process exited
+			continue
+		}
+		cmdLines = append(cmdLines, strings.ReplaceAll(string(b), "\x00", " "))
+	}
+	return cmdLines
+}
+
+// Returns true if any of [cmdLines] references a path under [dir]
+func isReferenced(dir string, cmdLines []string) bool {
+	for _, cmdLine := range cmdLines {
+		cmdLine += " "
+		if strings.Contains(cmdLine, dir+string(filepath.Separator)) || strings.Contains(cmdLine, dir+" ") {
+			return true
+		}
+	}
+	return false
+}
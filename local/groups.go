@@ -0,0 +1,121 @@
+// Copyright (C) 2021-2024, Lux Partners Limited. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package local
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/luxdefi/netrunner/network"
+	"github.com/luxdefi/netrunner/utils"
+	"go.uber.org/zap"
+	"golang.org/x/exp/slices"
+)
+
+// See network.Network
+func (ln *localNetwork) SetNodeGroup(groupName string, nodeNames []string) error {
+	ln.lock.Lock()
+	defer ln.lock.Unlock()
+
+	if ln.stopCalled() {
+		return network.ErrStopped
+	}
+	if err := network.ValidateNodeGroupName(groupName); err != nil {
+		return err
+	}
+	members := []string{}
+	for _, nodeName := range nodeNames {
+		if _, ok := ln.nodes[nodeName]; !ok {
+			return fmt.Errorf("%w: %q", network.ErrNodeNotFound, nodeName)
+		}
+		if !slices.Contains(members, nodeName) {
+			members = append(members, nodeName)
+		}
+	}
+	sort.Strings(members)
+	ln.groups[groupName] = members
+	ln.log.Info("set node group", zap.String("group", groupName), zap.Strings("nodes", members))
+	return nil
+}
+
+// See network.Network
+func (ln *localNetwork) RemoveNodeGroup(groupName string) error {
+	ln.lock.Lock()
+	defer ln.lock.Unlock()
+
+	if ln.stopCalled() {
+		return network.ErrStopped
+	}
+	if _, ok := ln.groups[groupName]; !ok {
+		return fmt.Errorf("%w: %q", network.ErrNodeGroupNotFound, groupName)
+	}
+	delete(ln.groups, groupName)
+	return nil
+}
+
+// See network.Network
+func (ln *localNetwork) GetNodeGroup(groupName string) ([]string, error) {
+	ln.lock.RLock()
+	defer ln.lock.RUnlock()
+
+	if ln.stopCalled() {
+		return nil, network.ErrStopped
+	}
+	return ln.getNodeGroup(groupName)
+}
+
+// See network.Network
+func (ln *localNetwork) GetNodeGroups() (map[string][]string, error) {
+	ln.lock.RLock()
+	defer ln.lock.RUnlock()
+
+	if ln.stopCalled() {
+		return nil, network.ErrStopped
+	}
+	groups := map[string][]string{
+		network.BeaconsNodeGroup: ln.beaconNodeNames(),
+	}
+	for groupName, members := range ln.groups {
+		groups[groupName] = slices.Clone(members)
+	}
+	return groups, nil
+}
+
+// Returns the names of the nodes of [groupName], sorted.
+// Assumes [ln.lock] is held.
+func (ln *localNetwork) getNodeGroup(groupName string) ([]string, error) {
+	if groupName == network.BeaconsNodeGroup {
+		return ln.beaconNodeNames(), nil
+	}
+	members, ok := ln.groups[groupName]
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", network.ErrNodeGroupNotFound, groupName)
+	}
+	return slices.Clone(members), nil
+}
+
+// Returns the names of the beacon nodes, sorted.
+// Assumes [ln.lock] is held.
+func (ln *localNetwork) beaconNodeNames() []string {
+	nodeNames := []string{}
+	for nodeName, node := range ln.nodes {
+		if node.config.IsBeacon {
+			nodeNames = append(nodeNames, nodeName)
+		}
+	}
+	sort.Strings(nodeNames)
+	return nodeNames
+}
+
+// Removes [nodeName] from the groups it belongs to. The groups
+// left empty are kept, as nodes may be added to them again.
+// Assumes [ln.lock] is held.
+func (ln *localNetwork) leaveNodeGroups(nodeName string) {
+	for groupName, members := range ln.groups {
+		if i := slices.Index(members, nodeName); i >= 0 {
+			ln.groups[groupName] = slices.Delete(members, i, i+1)
+			ln.log.Debug("node left group", utils.NodeField(nodeName), zap.String("group", groupName))
+		}
+	}
+}
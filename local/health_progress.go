@@ -0,0 +1,84 @@
+// Copyright (C) 2021-2024, Lux Partners Limited. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package local
+
+import (
+	"sync"
+
+	"github.com/luxdefi/netrunner/network"
+	"github.com/luxdefi/node/api/health"
+)
+
+const (
+	// health check of the P2P network of a node
+	networkHealthCheck = "network"
+	// key of the number of connected peers in its details
+	connectedPeersKey = "connectedPeers"
+)
+
+// aliases of the primary network chains, whose health
+// checks pass once they are bootstrapped
+var primaryChainAliases = []string{"P", "X", "C"}
+
+// Returns the startup progress of [nodeName] given by the reply
+// [reply] of its health call, or by its error [err]
+func newHealthProgress(nodeName string, reply *health.APIReply, err error) network.NodeHealthProgress {
+	progress := network.NodeHealthProgress{
+		Node:           nodeName,
+		ConnectedPeers: -1,
+		Err:            err,
+	}
+	if err != nil {
+		return progress
+	}
+	progress.Healthy = reply.Healthy
+	for _, alias := range primaryChainAliases {
+		// the check of a chain is registered once the chain is created
+		if result, ok := reply.Checks[alias]; ok && result.Error == nil {
+			progress.Bootstrapped = append(progress.Bootstrapped, alias)
+		} else {
+			progress.Bootstrapping = append(progress.Bootstrapping, alias)
+		}
+	}
+	// the details are decoded from json
+	if details, ok := reply.Checks[networkHealthCheck].Details.(map[string]interface{}); ok {
+		if peers, ok := details[connectedPeersKey].(float64); ok {
+			progress.ConnectedPeers = int(peers)
+		}
+	}
+	return progress
+}
+
+// healthProgressReporter calls [onProgress], one call at a time, with the
+// progress of a node when it differs from the last one of the node
+type healthProgressReporter struct {
+	onProgress func(network.NodeHealthProgress)
+
+	lock sync.Mutex
+	// node name --> last progress reported
+	last map[string]string
+}
+
+// Returns a reporter calling [onProgress], which may be nil
+func newHealthProgressReporter(onProgress func(network.NodeHealthProgress)) *healthProgressReporter {
+	return &healthProgressReporter{
+		onProgress: onProgress,
+		last:       map[string]string{},
+	}
+}
+
+func (r *healthProgressReporter) report(progress network.NodeHealthProgress) {
+	if r.onProgress == nil {
+		return
+	}
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	s := progress.String()
+	if last, ok := r.last[progress.Node]; ok && last == s {
+		return
+	}
+	r.last[progress.Node] = s
+	r.onProgress(progress)
+}
@@ -2,7 +2,6 @@ package local
 
 import (
 	"context"
-	"encoding/base64"
 	"fmt"
 	"math"
 	"math/rand"
@@ -11,17 +10,10 @@ import (
 	"path/filepath"
 	"time"
 
-	"github.com/luxdefi/netrunner/network/node"
-	"github.com/luxdefi/node/config"
-	"github.com/luxdefi/node/utils/constants"
 	"github.com/luxdefi/node/utils/logging"
 	"go.uber.org/zap"
 )
 
-func init() {
-	rand.Seed(time.Now().UnixNano())
-}
-
 const (
 	MaxPort          = math.MaxUint16
 	minPort          = 10000
@@ -45,7 +37,7 @@ func isFreePort(port uint16) error {
 // verifies it is free. If it is, returns that port, otherwise retries.
 // Returns an error if no free port is found within [netListenTimeout].
 // Note that it is possible for [getFreePort] to return the same port twice.
-func getFreePort() (uint16, error) {
+func getFreePort(rng *rand.Rand) (uint16, error) {
 	ctx, cancel := context.WithTimeout(context.Background(), netListenTimeout)
 	defer cancel()
 	for {
@@ -54,9 +46,9 @@ func getFreePort() (uint16, error) {
 			return 0, ctx.Err()
 		default:
 			// Generate random port in [minPort, maxPort]
-			port := uint16(rand.Intn(MaxPort-minPort+1) + minPort) //nolint
-			if isFreePort(port) != nil {
-				// Not free. Try another.
+			port := uint16(rng.Intn(MaxPort-minPort+1) + minPort)
+			if isFreePort(port) != nil || reservedPorts.isReserved(port) {
+				// Not free, or taken by a named network. Try another.
 				continue
 			}
 			return port, nil
@@ -64,124 +56,10 @@ func getFreePort() (uint16, error) {
 	}
 }
 
-// writeFiles writes the files a node needs on startup.
-// It returns flags used to point to those files.
-func writeFiles(networkID uint32, genesis []byte, nodeRootDir string, nodeConfig *node.Config) (map[string]string, error) {
-	type file struct {
-		pathKey   string
-		flagValue string
-		path      string
-		contents  []byte
-	}
-	decodedStakingSigningKey, err := base64.StdEncoding.DecodeString(nodeConfig.StakingSigningKey)
-	if err != nil {
-		return nil, err
-	}
-	files := []file{
-		{
-			flagValue: filepath.Join(nodeRootDir, stakingKeyFileName),
-			path:      filepath.Join(nodeRootDir, stakingKeyFileName),
-			pathKey:   config.StakingTLSKeyPathKey,
-			contents:  []byte(nodeConfig.StakingKey),
-		},
-		{
-			flagValue: filepath.Join(nodeRootDir, stakingCertFileName),
-			path:      filepath.Join(nodeRootDir, stakingCertFileName),
-			pathKey:   config.StakingCertPathKey,
-			contents:  []byte(nodeConfig.StakingCert),
-		},
-		{
-			flagValue: filepath.Join(nodeRootDir, stakingSigningKeyFileName),
-			path:      filepath.Join(nodeRootDir, stakingSigningKeyFileName),
-			pathKey:   config.StakingSignerKeyPathKey,
-			contents:  decodedStakingSigningKey,
-		},
-	}
-	if networkID != constants.LocalID {
-		files = append(files, file{
-			flagValue: filepath.Join(nodeRootDir, genesisFileName),
-			path:      filepath.Join(nodeRootDir, genesisFileName),
-			pathKey:   config.GenesisConfigFileKey,
-			contents:  genesis,
-		})
-	}
-	if len(nodeConfig.ConfigFile) != 0 {
-		files = append(files, file{
-			flagValue: filepath.Join(nodeRootDir, configFileName),
-			path:      filepath.Join(nodeRootDir, configFileName),
-			pathKey:   config.ConfigFileKey,
-			contents:  []byte(nodeConfig.ConfigFile),
-		})
-	}
-	flags := map[string]string{}
-	for _, f := range files {
-		flags[f.pathKey] = f.flagValue
-		if err := createFileAndWrite(f.path, f.contents); err != nil {
-			return nil, fmt.Errorf("couldn't write file at %q: %w", f.path, err)
-		}
-	}
-	// chain configs dir
-	chainConfigDir := filepath.Join(nodeRootDir, chainConfigSubDir)
-	if err := os.MkdirAll(chainConfigDir, 0o750); err != nil {
-		return nil, err
-	}
-	flags[config.ChainConfigDirKey] = chainConfigDir
-	// subnet configs dir
-	subnetConfigDir := filepath.Join(nodeRootDir, subnetConfigSubDir)
-	if err := os.MkdirAll(subnetConfigDir, 0o750); err != nil {
-		return nil, err
-	}
-	flags[config.SubnetConfigDirKey] = subnetConfigDir
-	// chain configs
-	for chainAlias, chainConfigFile := range nodeConfig.ChainConfigFiles {
-		chainConfigPath := filepath.Join(chainConfigDir, chainAlias, configFileName)
-		if err := createFileAndWrite(chainConfigPath, []byte(chainConfigFile)); err != nil {
-			return nil, fmt.Errorf("couldn't write file at %q: %w", chainConfigPath, err)
-		}
-	}
-	// network upgrades
-	for chainAlias, chainUpgradeFile := range nodeConfig.UpgradeConfigFiles {
-		chainUpgradePath := filepath.Join(chainConfigDir, chainAlias, upgradeConfigFileName)
-		if err := createFileAndWrite(chainUpgradePath, []byte(chainUpgradeFile)); err != nil {
-			return nil, fmt.Errorf("couldn't write file at %q: %w", chainUpgradePath, err)
-		}
-	}
-	// subnet configs
-	for subnetID, subnetConfigFile := range nodeConfig.SubnetConfigFiles {
-		subnetConfigPath := filepath.Join(subnetConfigDir, subnetID+".json")
-		if err := createFileAndWrite(subnetConfigPath, []byte(subnetConfigFile)); err != nil {
-			return nil, fmt.Errorf("couldn't write file at %q: %w", subnetConfigPath, err)
-		}
-	}
-	return flags, nil
-}
-
-// getConfigEntry returns an entry in the config file if it is found, otherwise returns the default value
-func getConfigEntry(
-	nodeConfigFlags map[string]interface{},
-	configFile map[string]interface{},
-	flag string,
-	defaultVal string,
-) (string, error) {
-	var entry string
-	if val, ok := nodeConfigFlags[flag]; ok {
-		if entry, ok := val.(string); ok {
-			return entry, nil
-		}
-		return "", fmt.Errorf("expected node config flag %q to be string but got %T", flag, entry)
-	}
-	if val, ok := configFile[flag]; ok {
-		if entry, ok := val.(string); ok {
-			return entry, nil
-		}
-		return "", fmt.Errorf("expected config file flag %q to be string but got %T", flag, entry)
-	}
-	return defaultVal, nil
-}
-
 // getPort looks up the port config in the config file, if there is none, it tries to get a random free port from the OS
 // if [reassingIfUsed] is true, and the port from config is not free, also tries to get a random free port
 func getPort(
+	rng *rand.Rand,
 	flags map[string]interface{},
 	configFile map[string]interface{},
 	portKey string,
@@ -205,13 +83,13 @@ func getPort(
 	} else {
 		// Use a random free port.
 		// Note: it is possible but unlikely for getFreePort to return the same port multiple times.
-		port, err = getFreePort()
+		port, err = getFreePort(rng)
 		if err != nil {
 			return 0, fmt.Errorf("couldn't get free port: %w", err)
 		}
 	}
 	if reassignIfUsed && isFreePort(port) != nil {
-		port, err = getFreePort()
+		port, err = getFreePort(rng)
 		if err != nil {
 			return 0, fmt.Errorf("couldn't get free port: %w", err)
 		}
@@ -223,6 +101,14 @@ func getPort(
 	return port, nil
 }
 
+// Returns the work dir [workDir] of a node, relative to [nodeDataDir]
+func nodeWorkDir(workDir string, nodeDataDir string) string {
+	if filepath.IsAbs(workDir) {
+		return workDir
+	}
+	return filepath.Join(nodeDataDir, workDir)
+}
+
 func makeNodeDir(log logging.Logger, rootDir, nodeName string) (string, error) {
 	if rootDir == "" {
 		log.Warn("no network root directory defined; will create this node's runtime directory in working directory")
@@ -251,13 +137,26 @@ func createFileAndWrite(path string, contents []byte) error {
 	if err := os.MkdirAll(filepath.Dir(path), 0o750); err != nil {
 		return err
 	}
-	file, err := os.Create(path)
+	// write to a temp file renamed over [path], so that a failed
+	// write doesn't leave a truncated file behind
+	file, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".tmp")
 	if err != nil {
 		return err
 	}
-	defer file.Close()
-	_, err = file.Write(contents)
-	return err
+	if _, err := file.Write(contents); err != nil {
+		_ = file.Close()
+		_ = os.Remove(file.Name())
+		return err
+	}
+	if err := file.Close(); err != nil {
+		_ = os.Remove(file.Name())
+		return err
+	}
+	if err := os.Chmod(file.Name(), 0o644); err != nil {
+		_ = os.Remove(file.Name())
+		return err
+	}
+	return os.Rename(file.Name(), path)
 }
 
 // addNetworkFlags adds the flags in [networkFlags] to [nodeConfig.Flags].
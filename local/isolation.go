@@ -0,0 +1,164 @@
+// Copyright (C) 2021-2024, Lux Partners Limited. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package local
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+)
+
+const networkLockFileName = "network.lock"
+
+var (
+	ErrNetworkNameInUse = errors.New("network name already in use")
+	ErrRootDirInUse     = errors.New("network root dir already in use")
+
+	// names of the named networks running in this process
+	runningNetworks = namedNetworks{names: map[string]struct{}{}}
+	// ports used by the nodes of named networks running in this process
+	reservedPorts = portRegistry{owners: map[uint16]string{}}
+)
+
+type namedNetworks struct {
+	lock  sync.Mutex
+	names map[string]struct{}
+}
+
+// portRegistry keeps track of the network that owns each port, so
+// that two named networks never try to use the same one, even when
+// the port is free at the OS level at the time it is checked
+type portRegistry struct {
+	lock   sync.Mutex
+	owners map[uint16]string
+}
+
+// reserve assigns [port] to [owner].
+// Reserving a port already owned by [owner] is a no-op.
+func (r *portRegistry) reserve(port uint16, owner string) error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if currentOwner, ok := r.owners[port]; ok && currentOwner != owner {
+		return fmt.Errorf("port %d is already used by network %q", port, currentOwner)
+	}
+	r.owners[port] = owner
+	return nil
+}
+
+// release frees the [ports] owned by [owner]
+func (r *portRegistry) release(owner string, ports ...uint16) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	for _, port := range ports {
+		if r.owners[port] == owner {
+			delete(r.owners, port)
+		}
+	}
+}
+
+func (r *portRegistry) isReserved(port uint16) bool {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	_, ok := r.owners[port]
+	return ok
+}
+
+// Takes the lock file on [ln.rootDir], so that other processes can't use
+// the root dir concurrently, nor garbage collect it while the network is
+// running, even with all its nodes paused. For named networks, also
+// registers [ln.name] as running, so that no other network in this
+// process uses the same name.
+func (ln *localNetwork) acquireRootDir() error {
+	runningNetworks.lock.Lock()
+	defer runningNetworks.lock.Unlock()
+
+	if ln.name != "" {
+		if _, ok := runningNetworks.names[ln.name]; ok {
+			return fmt.Errorf("%w: %q", ErrNetworkNameInUse, ln.name)
+		}
+	}
+	lockPath := filepath.Join(ln.rootDir, networkLockFileName)
+	if pid, err := readLockPID(lockPath); err == nil && pid != os.Getpid() && isProcessAlive(pid) {
+		if ln.name == "" {
+			return fmt.Errorf("%w: %q is locked by process %d", ErrRootDirInUse, ln.rootDir, pid)
+		}
+		return fmt.Errorf("%w: %q root dir %q is locked by process %d", ErrNetworkNameInUse, ln.name, ln.rootDir, pid)
+	}
+	if err := createFileAndWrite(lockPath, []byte(strconv.Itoa(os.Getpid()))); err != nil {
+		return fmt.Errorf("couldn't write network lock file: %w", err)
+	}
+	if ln.name != "" {
+		runningNetworks.names[ln.name] = struct{}{}
+	}
+	ln.rootDirLocked = true
+	return nil
+}
+
+// Undoes [acquireRootDir]. Safe to call more than once.
+func (ln *localNetwork) releaseRootDir() {
+	runningNetworks.lock.Lock()
+	defer runningNetworks.lock.Unlock()
+
+	if !ln.rootDirLocked {
+		return
+	}
+	ln.rootDirLocked = false
+	delete(runningNetworks.names, ln.name)
+	_ = os.Remove(filepath.Join(ln.rootDir, networkLockFileName))
+}
+
+// Reserves [port] for this network, if it is a named one.
+// If the port is owned by another named network and [ln.reassignPortsIfUsed]
+// is true, a new free port is reserved and returned instead.
+func (ln *localNetwork) reservePort(port uint16) (uint16, error) {
+	if ln.name == "" {
+		return port, nil
+	}
+	err := reservedPorts.reserve(port, ln.name)
+	if err == nil || !ln.reassignPortsIfUsed {
+		return port, err
+	}
+	for {
+		port, err = getFreePort(ln.rng)
+		if err != nil {
+			return 0, fmt.Errorf("couldn't get free port: %w", err)
+		}
+		if err := reservedPorts.reserve(port, ln.name); err == nil {
+			return port, nil
+		}
+	}
+}
+
+// Releases the ports reserved for [ports], if this is a named network
+func (ln *localNetwork) releasePorts(ports ...uint16) {
+	if ln.name == "" {
+		return
+	}
+	reservedPorts.release(ln.name, ports...)
+}
+
+func readLockPID(lockPath string) (int, error) {
+	b, err := os.ReadFile(lockPath)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.Atoi(strings.TrimSpace(string(b)))
+}
+
+// Returns true if a process with [pid] exists
+func isProcessAlive(pid int) bool {
+	if pid <= 0 {
+		return false
+	}
+	err := syscall.Kill(pid, 0)
+	return err == nil || errors.Is(err, syscall.EPERM)
+}
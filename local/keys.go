@@ -0,0 +1,168 @@
+// Copyright (C) 2021-2024, Lux Partners Limited. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package local
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"math/big"
+	"time"
+
+	"github.com/luxdefi/netrunner/network/node"
+	"github.com/luxdefi/node/staking"
+	"github.com/luxdefi/node/utils/crypto/bls"
+)
+
+// Sets the staking key/cert of [nodeConfig], unless both are given, and
+// its signing key, unless given. The keys are drawn from [ln.keyRNG] if the
+// network is seeded, so that the nodes get the same IDs on every run.
+// Assumes [ln.lock] is held.
+func (ln *localNetwork) setNodeKeys(nodeConfig *node.Config) error {
+	// it shouldn't happen that just one is empty, most probably both,
+	// but in any case if just one is empty it's unusable so we just assign a new one.
+	if nodeConfig.StakingCert == "" || nodeConfig.StakingKey == "" {
+		var (
+			certBytes []byte
+			keyBytes  []byte
+			err       error
+		)
+		if ln.keyRNG != nil {
+			certBytes, keyBytes, err = newSeededCertAndKeyBytes(ln.keyRNG)
+		} else {
+			certBytes, keyBytes, err = staking.NewCertAndKeyBytes()
+		}
+		if err != nil {
+			return fmt.Errorf("couldn't generate staking Cert/Key: %w", err)
+		}
+		nodeConfig.StakingCert = string(certBytes)
+		nodeConfig.StakingKey = string(keyBytes)
+	}
+	if nodeConfig.StakingSigningKey == "" {
+		var (
+			key *bls.SecretKey
+			err error
+		)
+		if ln.keyRNG != nil {
+			key, err = newSeededSecretKey(ln.keyRNG)
+		} else {
+			key, err = bls.NewSecretKey()
+		}
+		if err != nil {
+			return fmt.Errorf("couldn't generate new signing key: %w", err)
+		}
+		nodeConfig.StakingSigningKey = base64.StdEncoding.EncodeToString(bls.SecretKeyToBytes(key))
+	}
+	return nil
+}
+
+// Returns a staking cert and key, as PEM, drawn from [rng]. The key is an
+// ECDSA P-256 one, as the generation of RSA keys isn't reproducible, and
+// the cert is signed with a nonce derived from the key and the cert, so that
+// the cert, hence the node ID, only depends on [rng].
+func newSeededCertAndKeyBytes(rng io.Reader) ([]byte, []byte, error) {
+	curve := elliptic.P256()
+	size := (curve.Params().BitSize + 7) / 8
+	// the extra bytes make the bias of the modular reduction negligible
+	b := make([]byte, size+8)
+	if _, err := io.ReadFull(rng, b); err != nil {
+		return nil, nil, err
+	}
+	nMinus1 := new(big.Int).Sub(curve.Params().N, big.NewInt(1))
+	d := new(big.Int).SetBytes(b)
+	d.Mod(d, nMinus1)
+	d.Add(d, big.NewInt(1))
+	key := &ecdsa.PrivateKey{
+		PublicKey: ecdsa.PublicKey{Curve: curve},
+		D:         d,
+	}
+	key.PublicKey.X, key.PublicKey.Y = curve.ScalarBaseMult(d.FillBytes(make([]byte, size))) //nolint:staticcheck
+
+	// same template as the node uses for its staking certs
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(0),
+		NotBefore:             time.Date(2000, time.January, 0, 0, 0, 0, 0, time.UTC),
+		NotAfter:              time.Date(2100, time.January, 0, 0, 0, 0, 0, time.UTC),
+		KeyUsage:              x509.KeyUsageKeyEncipherment | x509.KeyUsageDigitalSignature,
+		BasicConstraintsValid: true,
+	}
+	certDER, err := x509.CreateCertificate(nil, template, template, &key.PublicKey, deterministicSigner{key})
+	if err != nil {
+		return nil, nil, fmt.Errorf("couldn't create certificate: %w", err)
+	}
+	keyDER, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return nil, nil, fmt.Errorf("couldn't marshal private key: %w", err)
+	}
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: certDER})
+	keyPEM := pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER})
+	return certPEM, keyPEM, nil
+}
+
+// deterministicSigner signs with an ECDSA key and a nonce derived from the
+// key and the digest, instead of a random one, as in RFC 6979
+type deterministicSigner struct {
+	key *ecdsa.PrivateKey
+}
+
+func (s deterministicSigner) Public() crypto.PublicKey {
+	return &s.key.PublicKey
+}
+
+func (s deterministicSigner) Sign(_ io.Reader, digest []byte, _ crypto.SignerOpts) ([]byte, error) {
+	params := s.key.Curve.Params()
+	n := params.N
+	e := new(big.Int).SetBytes(digest)
+	if excess := len(digest)*8 - params.BitSize; excess > 0 {
+		e.Rsh(e, uint(excess))
+	}
+	size := (params.BitSize + 7) / 8
+	keyBytes := s.key.D.FillBytes(make([]byte, size))
+	for counter := uint32(0); ; counter++ {
+		mac := hmac.New(sha256.New, keyBytes)
+		_, _ = mac.Write(digest)
+		_ = binary.Write(mac, binary.BigEndian, counter)
+		k := new(big.Int).SetBytes(mac.Sum(nil))
+		if k.Sign() == 0 || k.Cmp(n) >= 0 {
+			continue
+		}
+		x, _ := s.key.Curve.ScalarBaseMult(k.FillBytes(make([]byte, size))) //nolint:staticcheck
+		r := new(big.Int).Mod(x, n)
+		if r.Sign() == 0 {
+			continue
+		}
+		// s = k^-1 * (e + r * d) mod n
+		sig := new(big.Int).Mul(r, s.key.D)
+		sig.Add(sig, e)
+		sig.Mul(sig, new(big.Int).ModInverse(k, n))
+		sig.Mod(sig, n)
+		if sig.Sign() == 0 {
+			continue
+		}
+		return asn1.Marshal(struct{ R, S *big.Int }{r, sig})
+	}
+}
+
+// Returns a BLS secret key drawn from [rng]
+func newSeededSecretKey(rng io.Reader) (*bls.SecretKey, error) {
+	b := make([]byte, bls.SecretKeyLen)
+	for {
+		if _, err := io.ReadFull(rng, b); err != nil {
+			return nil, err
+		}
+		// about half of the byte strings are not below the curve order
+		if key, err := bls.SecretKeyFromBytes(b); err == nil {
+			return key, nil
+		}
+	}
+}
@@ -0,0 +1,194 @@
+// Copyright (C) 2021-2024, Lux Partners Limited. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+// Package localtest starts local networks bound to the lifetime of a Go test.
+package localtest
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/luxdefi/netrunner/local"
+	"github.com/luxdefi/netrunner/network"
+	"github.com/luxdefi/node/utils/logging"
+)
+
+const (
+	defaultHealthyTimeout = 5 * time.Minute
+	defaultBuildTimeout   = 30 * time.Minute
+	stopTimeout           = 2 * time.Minute
+	rootDirPattern        = "netrunner-test-*"
+	snapshotsSubdir       = "snapshots"
+)
+
+// Options of a test network
+type Options struct {
+	// Network to start. Defaults to local.NewDefaultConfig([BinaryPath]).
+	Config *network.Config
+	// Node binary used if [Config] is nil
+	BinaryPath string
+	// Defaults to not logging
+	Log logging.Logger
+	// If true, returns as soon as the nodes are started,
+	// without waiting for the network to be healthy
+	SkipHealthy bool
+	// Max time to wait for the network to be healthy. Defaults to 5 minutes.
+	HealthyTimeout time.Duration
+	// Dir of the snapshots of EnsureNetworkFromSnapshot, kept across test
+	// runs. Defaults to the default snapshots dir of the local package.
+	SnapshotsDir string
+	// Max time to build a network in EnsureNetworkFromSnapshot.
+	// Defaults to 30 minutes.
+	BuildTimeout time.Duration
+}
+
+// Builder sets up a fixture network, e.g. creating its subnets and chains
+type Builder func(ctx context.Context, nw network.Network) error
+
+// StartNetwork starts a network under a new temporary root dir and waits
+// for it to be healthy. The network is stopped when the test and its
+// subtests complete. The root dir, with the node logs and dbs, is removed
+// if the test passed, and kept for inspection if it failed.
+func StartNetwork(t testing.TB, opts Options) network.Network {
+	t.Helper()
+
+	opts = withDefaults(t, opts)
+	return startNetwork(t, opts, func(rootDir string) (network.Network, error) {
+		return local.NewNetwork(opts.Log, *opts.Config, rootDir, filepath.Join(rootDir, snapshotsSubdir), true)
+	})
+}
+
+// EnsureNetworkFromSnapshot starts the network of the snapshot
+// [snapshotName] as StartNetwork does. If there is no such snapshot, the
+// network of [opts] is started, set up with [build] and saved as
+// [snapshotName] first, so that expensive setups are built once and
+// reused by later test runs. Remove the snapshot to rebuild it.
+func EnsureNetworkFromSnapshot(t testing.TB, snapshotName string, build Builder, opts Options) network.Network {
+	t.Helper()
+
+	opts = withDefaults(t, opts)
+	// named networks keep their snapshots in a dir of their own
+	snapshotsDir := local.NetworkSnapshotsDir(opts.SnapshotsDir, opts.Config.Name)
+	if !local.SnapshotExists(snapshotsDir, snapshotName) {
+		buildSnapshot(t, snapshotName, build, opts)
+	}
+	return startNetwork(t, opts, func(rootDir string) (network.Network, error) {
+		return local.NewNetworkFromSnapshot(opts.Log, snapshotName, rootDir, snapshotsDir, "", "", nil, nil, nil, nil, true)
+	})
+}
+
+// Starts the network of [opts], builds it with [build] and saves it as [snapshotName]
+func buildSnapshot(t testing.TB, snapshotName string, build Builder, opts Options) {
+	t.Helper()
+
+	rootDir := newRootDir(t)
+	defer removeRootDir(t, rootDir)
+	nw, err := local.NewNetwork(opts.Log, *opts.Config, rootDir, opts.SnapshotsDir, true)
+	if err != nil {
+		t.Fatalf("localtest: couldn't start network to build snapshot %q: %s", snapshotName, err)
+	}
+	// no-op if saving the snapshot stopped it
+	defer stopNetwork(t, nw)
+	awaitHealthy(t, nw, opts.HealthyTimeout)
+
+	ctx, cancel := context.WithTimeout(context.Background(), opts.BuildTimeout)
+	defer cancel()
+	if err := build(ctx, nw); err != nil {
+		t.Fatalf("localtest: couldn't build snapshot %q: %s", snapshotName, err)
+	}
+	if _, err := nw.SaveSnapshot(ctx, snapshotName); err != nil {
+		// another test run may have saved it concurrently
+		if !local.SnapshotExists(local.NetworkSnapshotsDir(opts.SnapshotsDir, opts.Config.Name), snapshotName) {
+			t.Fatalf("localtest: couldn't save snapshot %q: %s", snapshotName, err)
+		}
+	}
+}
+
+// Returns [opts] with the defaults set, using [t] to fail if invalid
+func withDefaults(t testing.TB, opts Options) Options {
+	t.Helper()
+
+	if opts.Log == nil {
+		opts.Log = logging.NoLog{}
+	}
+	if opts.HealthyTimeout == 0 {
+		opts.HealthyTimeout = defaultHealthyTimeout
+	}
+	if opts.BuildTimeout == 0 {
+		opts.BuildTimeout = defaultBuildTimeout
+	}
+	switch {
+	case opts.Config != nil:
+	case opts.BinaryPath != "":
+		networkConfig := local.NewDefaultConfig(opts.BinaryPath)
+		opts.Config = &networkConfig
+	default:
+		t.Fatal("localtest: either a network config or a binary path must be given")
+	}
+	return opts
+}
+
+// Starts a network with [start] under a new temporary root dir, registering its cleanup
+func startNetwork(t testing.TB, opts Options, start func(rootDir string) (network.Network, error)) network.Network {
+	t.Helper()
+
+	rootDir := newRootDir(t)
+	nw, err := start(rootDir)
+	if err != nil {
+		t.Cleanup(func() {
+			removeRootDir(t, rootDir)
+		})
+		t.Fatalf("localtest: couldn't start network: %s", err)
+	}
+	t.Cleanup(func() {
+		stopNetwork(t, nw)
+		removeRootDir(t, rootDir)
+	})
+	if !opts.SkipHealthy {
+		awaitHealthy(t, nw, opts.HealthyTimeout)
+	}
+	return nw
+}
+
+func newRootDir(t testing.TB) string {
+	t.Helper()
+
+	rootDir, err := os.MkdirTemp("", rootDirPattern)
+	if err != nil {
+		t.Fatalf("localtest: couldn't create network root dir: %s", err)
+	}
+	return rootDir
+}
+
+func awaitHealthy(t testing.TB, nw network.Network, timeout time.Duration) {
+	t.Helper()
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	if err := nw.Healthy(ctx); err != nil {
+		t.Fatalf("localtest: network didn't become healthy: %s", err)
+	}
+}
+
+func stopNetwork(t testing.TB, nw network.Network) {
+	ctx, cancel := context.WithTimeout(context.Background(), stopTimeout)
+	defer cancel()
+	if err := nw.Stop(ctx); err != nil && !errors.Is(err, network.ErrStopped) {
+		t.Logf("localtest: couldn't stop network: %s", err)
+	}
+}
+
+// Removes [rootDir] if the test passed
+func removeRootDir(t testing.TB, rootDir string) {
+	if t.Failed() {
+		t.Logf("localtest: test failed, network files kept at %s", rootDir)
+		return
+	}
+	if err := os.RemoveAll(rootDir); err != nil {
+		t.Logf("localtest: couldn't remove network root dir: %s", err)
+	}
+}
@@ -0,0 +1,238 @@
+// Copyright (C) 2021-2024, Lux Partners Limited. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package localtest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/luxdefi/netrunner/network"
+	"github.com/luxdefi/node/utils/logging"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeTB records the failures, logs and cleanups of a test.
+// The methods not overridden panic.
+type fakeTB struct {
+	testing.TB
+	failed   bool
+	fatals   []string
+	logs     []string
+	cleanups []func()
+}
+
+func (*fakeTB) Helper() {}
+
+func (tb *fakeTB) Fatal(args ...interface{}) {
+	tb.failed = true
+	tb.fatals = append(tb.fatals, fmt.Sprint(args...))
+	runtime.Goexit()
+}
+
+func (tb *fakeTB) Fatalf(format string, args ...interface{}) {
+	tb.Fatal(fmt.Sprintf(format, args...))
+}
+
+func (tb *fakeTB) Failed() bool {
+	return tb.failed
+}
+
+func (tb *fakeTB) Logf(format string, args ...interface{}) {
+	tb.logs = append(tb.logs, fmt.Sprintf(format, args...))
+}
+
+func (tb *fakeTB) Cleanup(f func()) {
+	tb.cleanups = append(tb.cleanups, f)
+}
+
+// Runs [f] as a test would, returning once it returns or fails
+func (tb *fakeTB) run(f func()) {
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		f()
+	}()
+	<-done
+}
+
+// Runs the cleanups as the end of a test does, the last registered first
+func (tb *fakeTB) complete() {
+	for i := len(tb.cleanups) - 1; i >= 0; i-- {
+		tb.cleanups[i]()
+	}
+}
+
+// fakeNetwork records its health checks and stops.
+// The methods not overridden panic.
+type fakeNetwork struct {
+	network.Network
+	healthyErr error
+	healthy    int
+	stopped    int
+}
+
+func (nw *fakeNetwork) Healthy(context.Context) error {
+	nw.healthy++
+	return nw.healthyErr
+}
+
+func (nw *fakeNetwork) Stop(context.Context) error {
+	nw.stopped++
+	if nw.stopped > 1 {
+		return network.ErrStopped
+	}
+	return nil
+}
+
+func TestWithDefaults(t *testing.T) {
+	require := require.New(t)
+
+	tb := &fakeTB{}
+	var opts Options
+	tb.run(func() {
+		opts = withDefaults(tb, Options{BinaryPath: "luxd"})
+	})
+	require.Empty(tb.fatals)
+	require.Equal(logging.NoLog{}, opts.Log)
+	require.Equal(defaultHealthyTimeout, opts.HealthyTimeout)
+	require.Equal(defaultBuildTimeout, opts.BuildTimeout)
+	require.NotNil(opts.Config)
+	require.NotEmpty(opts.Config.NodeConfigs)
+	require.Equal("luxd", opts.Config.NodeConfigs[0].BinaryPath)
+
+	// the given options are kept
+	config := &network.Config{Name: "fixture"}
+	tb.run(func() {
+		opts = withDefaults(tb, Options{Config: config, BinaryPath: "luxd", HealthyTimeout: time.Minute})
+	})
+	require.Empty(tb.fatals)
+	require.Equal(config, opts.Config)
+	require.Equal(time.Minute, opts.HealthyTimeout)
+
+	tb.run(func() {
+		withDefaults(tb, Options{})
+	})
+	require.Equal([]string{"localtest: either a network config or a binary path must be given"}, tb.fatals)
+}
+
+func TestStartNetwork(t *testing.T) {
+	tests := []struct {
+		name             string
+		opts             Options
+		startErr         error
+		healthyErr       error
+		failTest         bool
+		expectedFatal    string
+		expectedHealthy  int
+		expectedStopped  int
+		expectedRootDirs int
+	}{
+		{
+			name:            "passed",
+			expectedHealthy: 1,
+			expectedStopped: 1,
+		},
+		{
+			name:            "healthy skipped",
+			opts:            Options{SkipHealthy: true},
+			expectedStopped: 1,
+		},
+		{
+			name:             "test failed, files kept",
+			failTest:         true,
+			expectedHealthy:  1,
+			expectedStopped:  1,
+			expectedRootDirs: 1,
+		},
+		{
+			name:          "start failure",
+			startErr:      errors.New("no binary"),
+			expectedFatal: "localtest: couldn't start network: no binary",
+			// the fatal failure keeps the files of the test
+			expectedRootDirs: 1,
+		},
+		{
+			name:             "not healthy",
+			healthyErr:       context.DeadlineExceeded,
+			expectedFatal:    "localtest: network didn't become healthy: context deadline exceeded",
+			expectedHealthy:  1,
+			expectedStopped:  1,
+			expectedRootDirs: 1,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require := require.New(t)
+			tmpDir := t.TempDir()
+			t.Setenv("TMPDIR", tmpDir)
+
+			nw := &fakeNetwork{healthyErr: tt.healthyErr}
+			tb := &fakeTB{}
+			var (
+				started network.Network
+				rootDir string
+			)
+			opts := tt.opts
+			opts.HealthyTimeout = time.Second
+			tb.run(func() {
+				started = startNetwork(tb, opts, func(dir string) (network.Network, error) {
+					rootDir = dir
+					if tt.startErr != nil {
+						return nil, tt.startErr
+					}
+					return nw, nil
+				})
+			})
+			require.DirExists(rootDir)
+			require.Equal(tmpDir, filepath.Dir(rootDir))
+			if tt.expectedFatal == "" {
+				require.Empty(tb.fatals)
+				require.Equal(nw, started)
+			} else {
+				require.Equal([]string{tt.expectedFatal}, tb.fatals)
+			}
+			require.Equal(tt.expectedHealthy, nw.healthy)
+			require.Zero(nw.stopped)
+
+			tb.failed = tb.failed || tt.failTest
+			tb.complete()
+			require.Equal(tt.expectedStopped, nw.stopped)
+			rootDirs, err := filepath.Glob(filepath.Join(tmpDir, rootDirPattern))
+			require.NoError(err)
+			require.Len(rootDirs, tt.expectedRootDirs)
+			if tt.expectedRootDirs != 0 {
+				require.Contains(tb.logs, "localtest: test failed, network files kept at "+rootDir)
+			}
+		})
+	}
+}
+
+func TestStopNetwork(t *testing.T) {
+	require := require.New(t)
+
+	tb := &fakeTB{}
+	nw := &fakeNetwork{}
+	stopNetwork(tb, nw)
+	// stopping an already stopped network isn't reported
+	stopNetwork(tb, nw)
+	require.Equal(2, nw.stopped)
+	require.Empty(tb.logs)
+}
+
+func TestRemoveRootDir(t *testing.T) {
+	require := require.New(t)
+
+	rootDir := filepath.Join(t.TempDir(), "netrunner-test-1")
+	require.NoError(os.MkdirAll(filepath.Join(rootDir, "node1"), os.ModePerm))
+	removeRootDir(&fakeTB{failed: true}, rootDir)
+	require.DirExists(rootDir)
+	removeRootDir(&fakeTB{}, rootDir)
+	require.NoDirExists(rootDir)
+}
@@ -0,0 +1,317 @@
+// Copyright (C) 2021-2024, Lux Partners Limited. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package local
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/luxdefi/netrunner/network"
+	"github.com/luxdefi/netrunner/utils"
+	"go.uber.org/zap"
+	"golang.org/x/exp/slices"
+)
+
+const (
+	// how often the collector reads the new lines of the node logs
+	logCollectInterval = time.Second
+	// number of entries collected but not received yet
+	// past which the collection waits for the receiver
+	logCollectorBufferSize = 1024
+	// time layout of the lines of the plain log format, without year
+	plainLogTimeLayout = "01-02|15:04:05.000"
+)
+
+var (
+	// names of the current node log files; the rotated files have
+	// other names (e.g. main.1.log or main-<timestamp>.log)
+	logFileNameRegex = regexp.MustCompile(`^([A-Za-z0-9_]+)\.log$`)
+	// [01-02|15:04:05.000] INFO <C Chain> evm/vm.go:123 message {"key": "value"}
+	plainLogLineRegex = regexp.MustCompile(`^\[(\d{2}-\d{2}\|\d{2}:\d{2}:\d{2}\.\d{3})\] (\w+) (?:<([^>]+)> )?(\S+:\d+) (.*)$`)
+)
+
+// logTail reads the lines appended to a node log file
+type logTail struct {
+	node    string
+	logName string
+	path    string
+	file    *os.File
+	// end of the file not yet terminated by a new line
+	partial []byte
+}
+
+// Returns the new complete lines of the log, continuing
+// with the new file if the node rotated it
+func (t *logTail) readLines() ([]string, error) {
+	lines := []string{}
+	for {
+		b, err := io.ReadAll(t.file)
+		if err != nil {
+			return lines, err
+		}
+		t.partial = append(t.partial, b...)
+		for {
+			i := bytes.IndexByte(t.partial, '\n')
+			if i < 0 {
+				break
+			}
+			lines = append(lines, string(t.partial[:i]))
+			t.partial = t.partial[i+1:]
+		}
+		rotated, err := reopenIfRotated(t.path, t.file)
+		if err != nil || rotated == nil {
+			return lines, err
+		}
+		_ = t.file.Close()
+		t.file = rotated
+		t.partial = nil
+	}
+}
+
+// See network.Network
+// The entries are ordered by time within each read of the logs, every
+// [logCollectInterval], so the lines of different nodes written within
+// that interval are ordered, and are otherwise in collection order.
+func (ln *localNetwork) CollectLogs(ctx context.Context, opts network.LogCollectorOptions) (<-chan network.LogEntry, error) {
+	ln.lock.RLock()
+	defer ln.lock.RUnlock()
+
+	if ln.stopCalled() {
+		return nil, network.ErrStopped
+	}
+	if opts.Group != "" {
+		if _, err := ln.getNodeGroup(opts.Group); err != nil {
+			return nil, err
+		}
+	}
+	var combined *os.File
+	if opts.CombinedFile != "" {
+		if err := os.MkdirAll(filepath.Dir(opts.CombinedFile), os.ModePerm); err != nil {
+			return nil, err
+		}
+		f, err := os.OpenFile(opts.CombinedFile, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+		if err != nil {
+			return nil, err
+		}
+		combined = f
+	}
+	ch := make(chan network.LogEntry, logCollectorBufferSize)
+	tails := map[string]*logTail{}
+	// the lines already in the logs are skipped unless [opts.FromStart],
+	// while the logs found later are read from their start
+	ln.addLogTails(tails, opts, !opts.FromStart)
+	go ln.collectLogs(ctx, opts, tails, combined, ch)
+	return ch, nil
+}
+
+// Reads the logs of [tails], and of the logs found later, into [ch]
+// until [ctx] is done or the network is stopped
+func (ln *localNetwork) collectLogs(
+	ctx context.Context,
+	opts network.LogCollectorOptions,
+	tails map[string]*logTail,
+	combined *os.File,
+	ch chan<- network.LogEntry,
+) {
+	defer func() {
+		for _, tail := range tails {
+			_ = tail.file.Close()
+		}
+		if combined != nil {
+			_ = combined.Close()
+		}
+		close(ch)
+	}()
+	for {
+		entries := ln.readLogTails(tails)
+		ln.lock.RLock()
+		ln.addLogTails(tails, opts, false)
+		ln.lock.RUnlock()
+		for _, entry := range entries {
+			if combined != nil {
+				if b, err := json.Marshal(entry); err == nil {
+					_, _ = combined.Write(append(b, '\n'))
+				}
+			}
+			select {
+			case ch <- entry:
+			case <-ctx.Done():
+				return
+			case <-ln.onStopCh:
+				return
+			}
+		}
+		select {
+		case <-ctx.Done():
+			return
+		case <-ln.onStopCh:
+			return
+		case <-time.After(logCollectInterval):
+		}
+	}
+}
+
+// Opens the logs named [opts.LogNames], or all of them if empty, of the
+// nodes of [opts.Group], or all of them if empty, that are not in [tails]
+// yet. If [atEnd], they are read from their end.
+// Assumes [ln.lock] is held.
+func (ln *localNetwork) addLogTails(tails map[string]*logTail, opts network.LogCollectorOptions, atEnd bool) {
+	var members []string
+	if opts.Group != "" {
+		// the group may have been removed since
+		members, _ = ln.getNodeGroup(opts.Group)
+	}
+	for nodeName, node := range ln.nodes {
+		if opts.Group != "" && !slices.Contains(members, nodeName) {
+			continue
+		}
+		matches, err := filepath.Glob(filepath.Join(node.GetLogsDir(), "*.log"))
+		if err != nil {
+			continue
+		}
+		for _, path := range matches {
+			if _, ok := tails[path]; ok {
+				continue
+			}
+			match := logFileNameRegex.FindStringSubmatch(filepath.Base(path))
+			if match == nil || (len(opts.LogNames) > 0 && !slices.Contains(opts.LogNames, match[1])) {
+				continue
+			}
+			f, err := os.Open(path)
+			if err != nil {
+				continue
+			}
+			if atEnd {
+				if _, err := f.Seek(0, io.SeekEnd); err != nil {
+					_ = f.Close()
+					continue
+				}
+			}
+			tails[path] = &logTail{
+				node:    nodeName,
+				logName: match[1],
+				path:    path,
+				file:    f,
+			}
+		}
+	}
+}
+
+// Returns the entries of the new lines of [tails], ordered by time.
+// The logs of the nodes removed from the network are closed once read.
+func (ln *localNetwork) readLogTails(tails map[string]*logTail) []network.LogEntry {
+	ln.lock.RLock()
+	// node name --> logs dir
+	logsDirs := make(map[string]string, len(ln.nodes))
+	for nodeName, node := range ln.nodes {
+		logsDirs[nodeName] = filepath.Clean(node.GetLogsDir())
+	}
+	ln.lock.RUnlock()
+
+	now := time.Now()
+	entries := []network.LogEntry{}
+	for path, tail := range tails {
+		lines, err := tail.readLines()
+		if err != nil {
+			ln.log.Debug("couldn't read node log", utils.NodeField(tail.node), zap.String("path", path), zap.Error(err))
+		}
+		for _, line := range lines {
+			if strings.TrimSpace(line) == "" {
+				continue
+			}
+			entry := parseLogLine(line, now)
+			entry.Node = tail.node
+			entry.Log = tail.logName
+			entries = append(entries, entry)
+		}
+		if logsDirs[tail.node] != filepath.Dir(path) {
+			_ = tail.file.Close()
+			delete(tails, path)
+		}
+	}
+	sort.SliceStable(entries, func(i, j int) bool {
+		return entries[i].Time.Before(entries[j].Time)
+	})
+	return entries
+}
+
+// Parses [line] of the JSON or plain node log format. The lines that
+// are neither (e.g. stack traces) are kept whole as the message.
+// [now] is the time of the lines without one.
+func parseLogLine(line string, now time.Time) network.LogEntry {
+	if strings.HasPrefix(line, "{") {
+		if entry, err := parseJSONLogLine(line, now); err == nil {
+			return entry
+		}
+	}
+	match := plainLogLineRegex.FindStringSubmatch(line)
+	if match == nil {
+		return network.LogEntry{Time: now, Message: line}
+	}
+	entry := network.LogEntry{
+		Time:    now,
+		Level:   strings.ToLower(match[2]),
+		Logger:  match[3],
+		Caller:  match[4],
+		Message: match[5],
+	}
+	// the year isn't logged
+	if t, err := time.ParseInLocation(plainLogTimeLayout, match[1], time.Local); err == nil {
+		entry.Time = t.AddDate(now.Year()-t.Year(), 0, 0)
+	}
+	// the fields follow the message as a JSON object
+	if i := strings.Index(entry.Message, " {"); i >= 0 && strings.HasSuffix(entry.Message, "}") {
+		fields := map[string]interface{}{}
+		if err := json.Unmarshal([]byte(entry.Message[i+1:]), &fields); err == nil {
+			entry.Message = entry.Message[:i]
+			entry.Fields = fields
+		}
+	}
+	return entry
+}
+
+func parseJSONLogLine(line string, now time.Time) (network.LogEntry, error) {
+	fields := map[string]interface{}{}
+	if err := json.Unmarshal([]byte(line), &fields); err != nil {
+		return network.LogEntry{}, err
+	}
+	msg, ok := fields["msg"].(string)
+	if !ok {
+		return network.LogEntry{}, errors.New("no message")
+	}
+	entry := network.LogEntry{
+		Time:    now,
+		Message: msg,
+	}
+	entry.Level, _ = fields["level"].(string)
+	entry.Logger, _ = fields["logger"].(string)
+	entry.Caller, _ = fields["caller"].(string)
+	switch ts := fields["timestamp"].(type) {
+	case string:
+		for _, layout := range []string{time.RFC3339Nano, "2006-01-02T15:04:05.000Z0700"} {
+			if t, err := time.Parse(layout, ts); err == nil {
+				entry.Time = t
+				break
+			}
+		}
+	case float64:
+		entry.Time = time.Unix(0, int64(ts*float64(time.Second)))
+	}
+	for _, k := range []string{"msg", "level", "logger", "caller", "timestamp"} {
+		delete(fields, k)
+	}
+	if len(fields) > 0 {
+		entry.Fields = fields
+	}
+	return entry, nil
+}
@@ -0,0 +1,104 @@
+// Copyright (C) 2021-2024, Lux Partners Limited. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package local
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const (
+	// log streamed when no log name is given
+	defaultLogName = "main"
+	// how often a followed log is checked for new lines
+	logFollowInterval = 250 * time.Millisecond
+)
+
+var errInvalidLogName = errors.New("invalid log name")
+
+// See node.Node
+func (node *localNode) StreamLogs(ctx context.Context, logName string, follow bool) (io.ReadCloser, error) {
+	if logName == "" {
+		logName = defaultLogName
+	}
+	if strings.ContainsAny(logName, `/\`) || strings.HasPrefix(logName, ".") {
+		return nil, fmt.Errorf("%w %q", errInvalidLogName, logName)
+	}
+	path := filepath.Join(node.GetLogsDir(), logName+".log")
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't open log %q of node %q: %w", logName, node.name, err)
+	}
+	return &logReader{
+		ctx:    ctx,
+		path:   path,
+		follow: follow,
+		file:   f,
+	}, nil
+}
+
+// logReader reads a node log file. If [follow], reading past its end waits
+// for new lines until [ctx] is done, and continues with the new file when
+// the node rotates the log.
+type logReader struct {
+	ctx    context.Context
+	path   string
+	follow bool
+	file   *os.File
+}
+
+func (r *logReader) Read(p []byte) (int, error) {
+	for {
+		n, err := r.file.Read(p)
+		if n > 0 || !errors.Is(err, io.EOF) || !r.follow {
+			return n, err
+		}
+		rotated, err := reopenIfRotated(r.path, r.file)
+		if err != nil {
+			return 0, err
+		}
+		if rotated != nil {
+			_ = r.file.Close()
+			r.file = rotated
+			continue
+		}
+		select {
+		case <-r.ctx.Done():
+			return 0, io.EOF
+		case <-time.After(logFollowInterval):
+		}
+	}
+}
+
+// Returns the file at [path], opened, if it is not [f] anymore as the
+// node rotated the log, or else nil
+func reopenIfRotated(path string, f *os.File) (*os.File, error) {
+	pathInfo, err := os.Stat(path)
+	if err != nil {
+		// the new file isn't created yet
+		return nil, nil
+	}
+	fileInfo, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	if os.SameFile(pathInfo, fileInfo) {
+		return nil, nil
+	}
+	rotated, err := os.Open(path)
+	if err != nil {
+		return nil, nil
+	}
+	return rotated, nil
+}
+
+func (r *logReader) Close() error {
+	return r.file.Close()
+}
@@ -17,6 +17,34 @@ type NodeProcess struct {
 	mock.Mock
 }
 
+// ExitCode provides a mock function with given fields:
+func (_m *NodeProcess) ExitCode() int {
+	ret := _m.Called()
+
+	var r0 int
+	if rf, ok := ret.Get(0).(func() int); ok {
+		r0 = rf()
+	} else {
+		r0 = ret.Get(0).(int)
+	}
+
+	return r0
+}
+
+// PID provides a mock function with given fields:
+func (_m *NodeProcess) PID() int {
+	ret := _m.Called()
+
+	var r0 int
+	if rf, ok := ret.Get(0).(func() int); ok {
+		r0 = rf()
+	} else {
+		r0 = ret.Get(0).(int)
+	}
+
+	return r0
+}
+
 // Status provides a mock function with given fields:
 func (_m *NodeProcess) Status() status.Status {
 	ret := _m.Called()
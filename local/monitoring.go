@@ -0,0 +1,390 @@
+// Copyright (C) 2021-2024, Lux Partners Limited. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package local
+
+import (
+	"context"
+	"embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/luxdefi/netrunner/network"
+	"github.com/luxdefi/netrunner/network/node"
+	"github.com/luxdefi/netrunner/utils"
+	"go.uber.org/zap"
+)
+
+const (
+	monitoringSubdir        = "monitoring"
+	prometheusConfigFile    = "prometheus.yml"
+	prometheusTargetsFile   = "targets.json"
+	defaultPrometheusPort   = 9090
+	defaultGrafanaPort      = 3000
+	defaultPrometheusBinary = "prometheus"
+	defaultGrafanaBinary    = "grafana-server"
+	prometheusImage         = "prom/prometheus"
+	grafanaImage            = "grafana/grafana"
+	defaultScrapeInterval   = 15 * time.Second
+	// time given to prometheus and grafana to exit on stop
+	monitoringStopTimeout = 10 * time.Second
+)
+
+var (
+	ErrMonitoringRunning = errors.New("monitoring already started")
+
+	//go:embed dashboards
+	embeddedDashboards embed.FS
+)
+
+// monitoringStack is the monitoring of a network: the configs
+// of prometheus and grafana, and their processes if launched
+type monitoringStack struct {
+	info network.Monitoring
+	// file of the prometheus targets, rewritten as nodes are added and removed
+	targetsPath string
+	// stop prometheus and grafana, if launched
+	stops []func()
+}
+
+// See network.Network
+func (ln *localNetwork) StartMonitoring(ctx context.Context, opts network.MonitoringOptions) (_ network.Monitoring, err error) {
+	defer utils.StartOperation(ln.log, "start-monitoring", zap.String("launch", opts.Launch))(&err)
+
+	ln.lock.Lock()
+	defer ln.lock.Unlock()
+
+	if ln.stopCalled() {
+		return network.Monitoring{}, network.ErrStopped
+	}
+	if ln.monitoring != nil {
+		return network.Monitoring{}, ErrMonitoringRunning
+	}
+	switch opts.Launch {
+	case "", network.MonitoringLaunchDocker, network.MonitoringLaunchLocal:
+	default:
+		return network.Monitoring{}, fmt.Errorf("unknown monitoring launch %q", opts.Launch)
+	}
+	if opts.PrometheusPort == 0 {
+		opts.PrometheusPort = defaultPrometheusPort
+	}
+	if opts.GrafanaPort == 0 {
+		opts.GrafanaPort = defaultGrafanaPort
+	}
+	if opts.ScrapeInterval == 0 {
+		opts.ScrapeInterval = defaultScrapeInterval
+	}
+
+	dir := filepath.Join(ln.rootDir, monitoringSubdir)
+	stack := &monitoringStack{
+		info: network.Monitoring{
+			Dir:                    dir,
+			PrometheusConfig:       filepath.Join(dir, prometheusConfigFile),
+			GrafanaProvisioningDir: filepath.Join(dir, "grafana", "provisioning"),
+		},
+		targetsPath: filepath.Join(dir, prometheusTargetsFile),
+	}
+	prometheusURL := fmt.Sprintf("http://127.0.0.1:%d", opts.PrometheusPort)
+	if err := writeMonitoringConfigs(stack, prometheusURL, opts.ScrapeInterval); err != nil {
+		return network.Monitoring{}, err
+	}
+	if err := ln.writeMonitoringTargets(stack); err != nil {
+		return network.Monitoring{}, err
+	}
+
+	switch opts.Launch {
+	case network.MonitoringLaunchDocker:
+		err = ln.launchMonitoringContainers(stack, opts)
+	case network.MonitoringLaunchLocal:
+		err = ln.launchMonitoringProcesses(ctx, stack, opts)
+	}
+	if err != nil {
+		stopMonitoring(stack)
+		return network.Monitoring{}, err
+	}
+	if opts.Launch != "" {
+		stack.info.PrometheusURL = prometheusURL
+		stack.info.GrafanaURL = fmt.Sprintf("http://127.0.0.1:%d", opts.GrafanaPort)
+	}
+	ln.monitoring = stack
+	ln.log.Info("monitoring started",
+		zap.String("prometheus-config", stack.info.PrometheusConfig),
+		zap.String("prometheus-url", stack.info.PrometheusURL),
+		zap.String("grafana-url", stack.info.GrafanaURL),
+	)
+	return stack.info, nil
+}
+
+// Writes the prometheus config of [stack], scraping the nodes listed
+// in its targets file, and the grafana provisioning of the prometheus
+// at [prometheusURL] and of the default dashboards
+func writeMonitoringConfigs(stack *monitoringStack, prometheusURL string, scrapeInterval time.Duration) error {
+	dashboardsDir := filepath.Join(stack.info.Dir, "grafana", "dashboards")
+	dirs := []string{
+		filepath.Join(stack.info.GrafanaProvisioningDir, "datasources"),
+		filepath.Join(stack.info.GrafanaProvisioningDir, "dashboards"),
+		dashboardsDir,
+	}
+	for _, dir := range dirs {
+		if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+			return err
+		}
+	}
+	prometheusConfig := fmt.Sprintf(`global:
+  scrape_interval: %s
+  evaluation_interval: %s
+scrape_configs:
+  - job_name: node
+    metrics_path: /ext/metrics
+    file_sd_configs:
+      - files:
+          - %s
+        refresh_interval: 5s
+`, scrapeInterval, scrapeInterval, stack.targetsPath)
+	datasource := fmt.Sprintf(`apiVersion: 1
+datasources:
+  - name: Prometheus
+    uid: netrunner-prometheus
+    type: prometheus
+    access: proxy
+    url: %s
+    isDefault: true
+`, prometheusURL)
+	dashboardProvider := fmt.Sprintf(`apiVersion: 1
+providers:
+  - name: netrunner
+    folder: netrunner
+    type: file
+    options:
+      path: %s
+`, dashboardsDir)
+	files := map[string]string{
+		stack.info.PrometheusConfig: prometheusConfig,
+		filepath.Join(stack.info.GrafanaProvisioningDir, "datasources", "prometheus.yml"): datasource,
+		filepath.Join(stack.info.GrafanaProvisioningDir, "dashboards", "netrunner.yml"):   dashboardProvider,
+	}
+	for path, content := range files {
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			return err
+		}
+	}
+	dashboards, err := fs.Sub(embeddedDashboards, "dashboards")
+	if err != nil {
+		return err
+	}
+	entries, err := fs.ReadDir(dashboards, ".")
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		b, err := fs.ReadFile(dashboards, entry.Name())
+		if err != nil {
+			return err
+		}
+		if err := os.WriteFile(filepath.Join(dashboardsDir, entry.Name()), b, 0o644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// prometheus file based service discovery target group
+type prometheusTargetGroup struct {
+	Targets []string          `json:"targets"`
+	Labels  map[string]string `json:"labels"`
+}
+
+// Writes the targets file of [stack], listing the nodes of the network,
+// labeled with their name, labels and the network name
+// Assumes [ln.lock] is held.
+func (ln *localNetwork) writeMonitoringTargets(stack *monitoringStack) error {
+	nodeNames := make([]string, 0, len(ln.nodes))
+	for nodeName := range ln.nodes {
+		nodeNames = append(nodeNames, nodeName)
+	}
+	sort.Strings(nodeNames)
+	groups := []prometheusTargetGroup{}
+	for _, nodeName := range nodeNames {
+		n := ln.nodes[nodeName]
+		u, err := url.Parse(n.GetAPIBaseURI())
+		if err != nil {
+			return err
+		}
+		labels := node.MergeLabels(n.config.Labels, map[string]string{"node": nodeName, "__scheme__": u.Scheme})
+		if ln.name != "" {
+			labels["network"] = ln.name
+		}
+		groups = append(groups, prometheusTargetGroup{
+			Targets: []string{u.Host},
+			Labels:  labels,
+		})
+	}
+	b, err := json.MarshalIndent(groups, "", "  ")
+	if err != nil {
+		return err
+	}
+	// written apart then moved, so that prometheus never reads a partial file
+	tmpPath := stack.targetsPath + ".tmp"
+	if err := os.WriteFile(tmpPath, b, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmpPath, stack.targetsPath)
+}
+
+// Updates the monitoring targets, if monitoring is started, once nodes
+// are added or removed
+// Assumes [ln.lock] is held.
+func (ln *localNetwork) updateMonitoringTargets() {
+	if ln.monitoring == nil {
+		return
+	}
+	if err := ln.writeMonitoringTargets(ln.monitoring); err != nil {
+		ln.log.Warn("couldn't update monitoring targets", zap.Error(err))
+	}
+}
+
+// Runs prometheus and grafana as containers of the host network,
+// mounting the monitoring dir at the same path
+func (ln *localNetwork) launchMonitoringContainers(stack *monitoringStack, opts network.MonitoringOptions) error {
+	if _, err := exec.LookPath("docker"); err != nil {
+		return fmt.Errorf("couldn't find docker: %w", err)
+	}
+	suffix := filepath.Base(ln.rootDir)
+	containers := []struct {
+		name string
+		args []string
+	}{
+		{
+			name: "netrunner-prometheus-" + suffix,
+			args: []string{
+				prometheusImage,
+				"--config.file=" + stack.info.PrometheusConfig,
+				"--storage.tsdb.path=/prometheus",
+				fmt.Sprintf("--web.listen-address=:%d", opts.PrometheusPort),
+			},
+		},
+		{
+			name: "netrunner-grafana-" + suffix,
+			args: append(grafanaEnvArgs(stack, opts), grafanaImage),
+		},
+	}
+	for _, c := range containers {
+		args := append([]string{
+			"run", "--detach", "--rm",
+			"--name", c.name,
+			"--network", "host",
+			"--volume", stack.info.Dir + ":" + stack.info.Dir,
+		}, c.args...)
+		if out, err := exec.Command("docker", args...).CombinedOutput(); err != nil { //nolint
+			return fmt.Errorf("couldn't run %s: %w: %s", c.name, err, strings.TrimSpace(string(out)))
+		}
+		name := c.name
+		stack.stops = append(stack.stops, func() {
+			if out, err := exec.Command("docker", "rm", "--force", name).CombinedOutput(); err != nil { //nolint
+				ln.log.Warn("couldn't remove monitoring container", zap.String("container", name), zap.Error(err), zap.String("output", string(out)))
+			}
+		})
+	}
+	return nil
+}
+
+// Returns the --env args configuring the grafana container of [stack]
+func grafanaEnvArgs(stack *monitoringStack, opts network.MonitoringOptions) []string {
+	args := []string{}
+	for _, env := range grafanaEnv(stack, opts) {
+		args = append(args, "--env", env)
+	}
+	return args
+}
+
+// Returns the environment of grafana, serving the dashboards of
+// [stack] on its port with anonymous access
+func grafanaEnv(stack *monitoringStack, opts network.MonitoringOptions) []string {
+	return []string{
+		fmt.Sprintf("GF_SERVER_HTTP_PORT=%d", opts.GrafanaPort),
+		"GF_PATHS_PROVISIONING=" + stack.info.GrafanaProvisioningDir,
+		"GF_AUTH_ANONYMOUS_ENABLED=true",
+		"GF_AUTH_ANONYMOUS_ORG_ROLE=Admin",
+	}
+}
+
+// Runs prometheus and grafana as local processes, with their data
+// under the monitoring dir
+func (ln *localNetwork) launchMonitoringProcesses(ctx context.Context, stack *monitoringStack, opts network.MonitoringOptions) error {
+	prometheusBinary := opts.PrometheusBinary
+	if prometheusBinary == "" {
+		prometheusBinary = defaultPrometheusBinary
+	}
+	grafanaBinary := opts.GrafanaBinary
+	if grafanaBinary == "" {
+		grafanaBinary = defaultGrafanaBinary
+	}
+	prometheusCmd := exec.Command( //nolint
+		prometheusBinary,
+		"--config.file="+stack.info.PrometheusConfig,
+		"--storage.tsdb.path="+filepath.Join(stack.info.Dir, "prometheus-data"),
+		fmt.Sprintf("--web.listen-address=:%d", opts.PrometheusPort),
+	)
+	grafanaArgs := []string{}
+	if opts.GrafanaHomePath != "" {
+		grafanaArgs = append(grafanaArgs, "--homepath", opts.GrafanaHomePath)
+	}
+	grafanaCmd := exec.Command(grafanaBinary, grafanaArgs...) //nolint
+	grafanaCmd.Env = append(os.Environ(), grafanaEnv(stack, opts)...)
+	grafanaCmd.Env = append(grafanaCmd.Env, "GF_PATHS_DATA="+filepath.Join(stack.info.Dir, "grafana-data"))
+
+	for _, cmd := range []*exec.Cmd{prometheusCmd, grafanaCmd} {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		name := filepath.Base(cmd.Path)
+		logFile, err := os.Create(filepath.Join(stack.info.Dir, name+".log"))
+		if err != nil {
+			return err
+		}
+		cmd.Stdout = logFile
+		cmd.Stderr = logFile
+		if err := cmd.Start(); err != nil {
+			_ = logFile.Close()
+			return fmt.Errorf("couldn't start %s: %w", name, err)
+		}
+		done := make(chan struct{})
+		go func(cmd *exec.Cmd) {
+			_ = cmd.Wait()
+			_ = logFile.Close()
+			close(done)
+		}(cmd)
+		cmd := cmd
+		stack.stops = append(stack.stops, func() {
+			_ = cmd.Process.Signal(syscall.SIGTERM)
+			select {
+			case <-done:
+			case <-time.After(monitoringStopTimeout):
+				_ = cmd.Process.Kill()
+				<-done
+			}
+		})
+	}
+	return nil
+}
+
+// Stops prometheus and grafana, if launched
+func stopMonitoring(stack *monitoringStack) {
+	if stack == nil {
+		return
+	}
+	for _, stop := range stack.stops {
+		stop()
+	}
+	stack.stops = nil
+}
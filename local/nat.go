@@ -0,0 +1,264 @@
+// Copyright (C) 2021-2024, Lux Partners Limited. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package local
+
+import (
+	"errors"
+	"math/rand"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/luxdefi/netrunner/network/node"
+	"github.com/luxdefi/node/utils/constants"
+	"github.com/luxdefi/node/utils/logging"
+	"go.uber.org/zap"
+)
+
+const (
+	// max number of reads of a connection delayed at once
+	maxDelayedReads = 1024
+	// delay of the data lost as per the link conditions, which
+	// TCP retransmits after about its min retransmission timeout
+	lossRetransmitDelay = 200 * time.Millisecond
+)
+
+// portForwarder simulates the port forwarding of a NAT: it accepts
+// TCP connections on a node's public address and proxies them to
+// the address the node is bound to, delaying the traffic of each
+// direction by [latency], if any, or by the latency [peerLatency]
+// returns for the remote address of the connection, if any, plus the
+// delay of the link conditions, if set. The connections from the
+// addresses [allowPeer] rejects, if given, are closed.
+type portForwarder struct {
+	log         logging.Logger
+	listener    net.Listener
+	targetAddr  string
+	latency     time.Duration
+	peerLatency func(remote net.Addr) (time.Duration, bool)
+	allowPeer   func(remote net.Addr) bool
+	lock        sync.Mutex
+	conditions  *node.LinkConditions
+	conns       map[net.Conn]struct{}
+	closed      bool
+	wg          sync.WaitGroup
+}
+
+// Starts forwarding connections from [listenAddr] to [targetAddr]
+func newPortForwarder(
+	log logging.Logger,
+	listenAddr string,
+	targetAddr string,
+	latency time.Duration,
+	peerLatency func(remote net.Addr) (time.Duration, bool),
+	allowPeer func(remote net.Addr) bool,
+) (*portForwarder, error) {
+	listener, err := net.Listen(constants.NetworkType, listenAddr)
+	if err != nil {
+		return nil, err
+	}
+	f := &portForwarder{
+		log:         log,
+		listener:    listener,
+		targetAddr:  targetAddr,
+		latency:     latency,
+		peerLatency: peerLatency,
+		allowPeer:   allowPeer,
+		conns:       map[net.Conn]struct{}{},
+	}
+	f.wg.Add(1)
+	go f.acceptLoop()
+	return f, nil
+}
+
+func (f *portForwarder) acceptLoop() {
+	defer f.wg.Done()
+	for {
+		conn, err := f.listener.Accept()
+		if err != nil {
+			if !errors.Is(err, net.ErrClosed) {
+				f.log.Debug("port forwarder stopped accepting", zap.Error(err))
+			}
+			return
+		}
+		f.wg.Add(1)
+		go f.forward(conn)
+	}
+}
+
+func (f *portForwarder) forward(conn net.Conn) {
+	defer f.wg.Done()
+	if f.allowPeer != nil && !f.allowPeer(conn.RemoteAddr()) {
+		f.log.Debug("port forwarder rejected peer", zap.Stringer("remote", conn.RemoteAddr()))
+		_ = conn.Close()
+		return
+	}
+	target, err := net.Dial(constants.NetworkType, f.targetAddr)
+	if err != nil {
+		f.log.Debug("port forwarder couldn't reach target", zap.String("target", f.targetAddr), zap.Error(err))
+		_ = conn.Close()
+		return
+	}
+	if !f.track(conn, target) {
+		_ = conn.Close()
+		_ = target.Close()
+		return
+	}
+	defer f.untrack(conn, target)
+
+	latency := f.latency
+	if f.peerLatency != nil {
+		if peerLatency, ok := f.peerLatency(conn.RemoteAddr()); ok {
+			latency = peerLatency
+		}
+	}
+	// the link conditions are read on every read of the connection,
+	// so that changing them applies to the connections already made
+	delay := func() time.Duration {
+		return latency + f.conditionsDelay()
+	}
+	done := make(chan struct{}, 2)
+	pipe := func(dst net.Conn, src net.Conn) {
+		delayedCopy(dst, src, delay)
+		done <- struct{}{}
+	}
+	go pipe(target, conn)
+	go pipe(conn, target)
+	// when either side is done, tear down both
+	<-done
+	_ = conn.Close()
+	_ = target.Close()
+	<-done
+}
+
+// Returns false if the forwarder is closed
+func (f *portForwarder) track(conns ...net.Conn) bool {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	if f.closed {
+		return false
+	}
+	for _, conn := range conns {
+		f.conns[conn] = struct{}{}
+	}
+	return true
+}
+
+func (f *portForwarder) untrack(conns ...net.Conn) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	for _, conn := range conns {
+		delete(f.conns, conn)
+	}
+}
+
+// Sets the link conditions of the forwarded traffic, or removes them if [conditions] is nil
+func (f *portForwarder) setConditions(conditions *node.LinkConditions) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+
+	f.conditions = conditions
+}
+
+// Returns the delay of data read now due to the link conditions: their
+// latency, a random jitter, and the retransmission delay if the data is lost
+func (f *portForwarder) conditionsDelay() time.Duration {
+	f.lock.Lock()
+	conditions := f.conditions
+	f.lock.Unlock()
+
+	if conditions == nil {
+		return 0
+	}
+	delay := time.Duration(conditions.LatencyMs) * time.Millisecond
+	if conditions.JitterMs > 0 {
+		delay += time.Duration(rand.Int63n(int64(conditions.JitterMs)*int64(time.Millisecond) + 1)) //nolint:gosec
+	}
+	if conditions.LossPct > 0 && rand.Float64()*100 < conditions.LossPct { //nolint:gosec
+		delay += lossRetransmitDelay
+	}
+	return delay
+}
+
+// Closes the forwarded connections from the addresses [allowPeer] rejects
+func (f *portForwarder) closeRejected() {
+	if f.allowPeer == nil {
+		return
+	}
+	f.lock.Lock()
+	conns := make([]net.Conn, 0, len(f.conns))
+	for conn := range f.conns {
+		conns = append(conns, conn)
+	}
+	f.lock.Unlock()
+
+	for _, conn := range conns {
+		// the remote end of the connections to the target is the
+		// node of this forwarder, so only accepted ones are rejected
+		if !f.allowPeer(conn.RemoteAddr()) {
+			_ = conn.Close()
+		}
+	}
+}
+
+// Close stops accepting connections, closes the forwarded ones
+// and waits for the forwarding goroutines to finish
+func (f *portForwarder) Close() error {
+	f.lock.Lock()
+	f.closed = true
+	err := f.listener.Close()
+	for conn := range f.conns {
+		_ = conn.Close()
+	}
+	f.lock.Unlock()
+	f.wg.Wait()
+	return err
+}
+
+// data read from a connection, to be written at a given time
+type delayedRead struct {
+	data    []byte
+	writeAt time.Time
+}
+
+// Copies from [src] to [dst] until either fails, writing the data the
+// duration [delay] returns after it's read. The data read meanwhile isn't
+// held back, so the throughput isn't limited by the delay. As a stream,
+// the data is never written before the data read earlier.
+func delayedCopy(dst net.Conn, src net.Conn, delay func() time.Duration) {
+	reads := make(chan delayedRead, maxDelayedReads)
+	writeFailed := make(chan struct{})
+	go func() {
+		defer close(reads)
+		lastWriteAt := time.Time{}
+		for {
+			buf := make([]byte, 32*1024)
+			n, err := src.Read(buf)
+			if n > 0 {
+				writeAt := time.Now().Add(delay())
+				if writeAt.Before(lastWriteAt) {
+					writeAt = lastWriteAt
+				}
+				lastWriteAt = writeAt
+				select {
+				case reads <- delayedRead{data: buf[:n], writeAt: writeAt}:
+				case <-writeFailed:
+					return
+				}
+			}
+			if err != nil {
+				return
+			}
+		}
+	}()
+	for read := range reads {
+		time.Sleep(time.Until(read.writeAt))
+		if _, err := dst.Write(read.data); err != nil {
+			close(writeFailed)
+			return
+		}
+	}
+}
@@ -8,6 +8,7 @@ import (
 	"errors"
 	"fmt"
 	"io/fs"
+	"math/rand"
 	"net"
 	"os"
 	"os/user"
@@ -19,20 +20,21 @@ import (
 	"time"
 
 	"github.com/luxdefi/netrunner/api"
+	"github.com/luxdefi/netrunner/binaries"
 	"github.com/luxdefi/netrunner/network"
 	"github.com/luxdefi/netrunner/network/node"
 	"github.com/luxdefi/netrunner/network/node/status"
+	"github.com/luxdefi/netrunner/nodeconfig"
 	"github.com/luxdefi/netrunner/utils"
 	"github.com/luxdefi/netrunner/utils/constants"
+	"github.com/luxdefi/netrunner/utils/failpoint"
 	"github.com/luxdefi/node/config"
 	"github.com/luxdefi/node/ids"
 	"github.com/luxdefi/node/network/peer"
 	"github.com/luxdefi/node/staking"
 	"github.com/luxdefi/node/utils/beacon"
-	"github.com/luxdefi/node/utils/crypto/bls"
 	"github.com/luxdefi/node/utils/ips"
 	"github.com/luxdefi/node/utils/logging"
-	"github.com/luxdefi/node/utils/set"
 	"github.com/luxdefi/node/utils/wrappers"
 	"go.uber.org/zap"
 	"golang.org/x/exp/maps"
@@ -41,20 +43,19 @@ import (
 )
 
 const (
-	defaultNodeNamePrefix     = "node"
-	configFileName            = "config.json"
-	upgradeConfigFileName     = "upgrade.json"
-	stakingKeyFileName        = "staking.key"
-	stakingCertFileName       = "staking.crt"
-	stakingSigningKeyFileName = "signer.key"
-	genesisFileName           = "genesis.json"
+	configFileName            = nodeconfig.ConfigFileName
+	upgradeConfigFileName     = nodeconfig.UpgradeConfigFileName
+	stakingKeyFileName        = nodeconfig.StakingKeyFileName
+	stakingCertFileName       = nodeconfig.StakingCertFileName
+	stakingSigningKeyFileName = nodeconfig.StakingSigningKeyFileName
+	genesisFileName           = nodeconfig.GenesisFileName
 	stopTimeout               = 30 * time.Second
 	healthCheckFreq           = 3 * time.Second
+	binaryDownloadTimeout     = 5 * time.Minute
 	DefaultNumNodes           = 5
 	snapshotPrefix            = "anr-snapshot-"
 	networkRootDirPrefix      = "network"
-	defaultDBSubdir           = "db"
-	defaultLogsSubdir         = "logs"
+	defaultDBSubdir           = nodeconfig.DefaultDBSubdir
 	// difference between unlock schedule locktime and startime in original genesis
 	genesisLocktimeStartimeDelta = 2836800
 )
@@ -69,8 +70,8 @@ var (
 		config.BootstrapIPsKey: {},
 		config.BootstrapIDsKey: {},
 	}
-	chainConfigSubDir  = "chainConfigs"
-	subnetConfigSubDir = "subnetConfigs"
+	chainConfigSubDir  = nodeconfig.ChainConfigSubDir
+	subnetConfigSubDir = nodeconfig.SubnetConfigSubDir
 
 	snapshotsRelPath = filepath.Join(".netrunner", "snapshots")
 
@@ -81,6 +82,11 @@ var (
 type localNetwork struct {
 	lock sync.RWMutex
 	log  logging.Logger
+	// Optional network name. See network.Config.
+	name string
+	// True if the lock file on [rootDir] is held.
+	// Guarded by [runningNetworks.lock].
+	rootDirLocked bool
 	// This network's ID.
 	networkID uint32
 	// This network's genesis file.
@@ -94,7 +100,10 @@ type localNetwork struct {
 	// Closed when Stop begins.
 	onStopCh chan struct{}
 	// For node name generation
+	nodeNaming     network.NodeNaming
 	nextNodeSuffix uint64
+	// Names of the nodes prepared by [addNodes] and not yet added
+	preparedNodeNames map[string]struct{}
 	// Node Name --> Node
 	nodes map[string]*localNode
 	// Set of nodes that new nodes will bootstrap from.
@@ -116,23 +125,52 @@ type localNetwork struct {
 	subnetConfigFiles map[string]string
 	// if true, for ports given in conf that are already taken, assign new random ones
 	reassignPortsIfUsed bool
+	// if true, nodes without bind IP get their own loopback alias
+	loopbackAliases bool
+	// if true, nodes without bind IP are bound to the IPv6 loopback
+	ipv6 bool
 	// map from subnet id to elastic subnet tx id
 	subnetID2ElasticSubnetID map[ids.ID]ids.ID
-}
-
-type deprecatedFlagEsp struct {
-	Version  string `json:"version"`
-	OldName  string `json:"old_name"`
-	NewName  string `json:"new_name"`
-	ValueMap string `json:"value_map"`
+	// max bytes used on disk, 0 if unlimited
+	diskBudget uint64
+	// latencies between the regions of the nodes, if any
+	regionLinks *regionLinks
+	// last measured disk usage
+	diskUsage     network.DiskUsage
+	diskUsageLock sync.RWMutex
+	// if not empty, base dirs of the node dbs, logs and staking files
+	dbRootDir   string
+	logsRootDir string
+	keysRootDir string
+	// explicit beacon set of the network config, if given
+	beacons []network.Beacon
+	// probes that must pass for the network to be healthy
+	readinessProbes []network.ReadinessProbe
+	// labels added to the labels of every node
+	labels map[string]string
+	// Node name --> config before the last restart of the node
+	backups map[string]*nodeBackup
+	// Node group name --> sorted names of its nodes
+	groups map[string][]string
+	// current partition of the network, if any
+	partition *partition
+	// auth token and headers of the API calls to the nodes, if any
+	apiClientOptions *api.ClientOptions
+	// source of the random choices of the network, e.g. node ports
+	rng *rand.Rand
+	// source of the keys of the nodes given none, if the network is seeded
+	keyRNG *rand.Rand
+	// prometheus and grafana of the network, if started
+	monitoring *monitoringStack
+	// lifecycle events of the network
+	events *eventBus
+	// consoles of the nodes, if their processes have some
+	consoles *consoleHub
 }
 
 var (
 	//go:embed default
 	embeddedDefaultNetworkConfigDir embed.FS
-	//go:embed deprecatedFlagsSupport.json
-	deprecatedFlagsSupportBytes []byte
-	deprecatedFlagsSupport      []deprecatedFlagEsp
 	// Pre-defined network configuration.
 	// [defaultNetworkConfig] should not be modified.
 	// TODO add method Copy() to network.Config to prevent
@@ -149,10 +187,6 @@ func init() {
 	if err != nil {
 		panic(err)
 	}
-	// load deprecated luxd flags support information
-	if err = json.Unmarshal(deprecatedFlagsSupportBytes, &deprecatedFlagsSupport); err != nil {
-		panic(err)
-	}
 
 	startTime := time.Now().Unix()
 	lockTime := startTime + genesisLocktimeStartimeDelta
@@ -267,15 +301,40 @@ func NewNetwork(
 	snapshotsDir string,
 	reassignPortsIfUsed bool,
 ) (network.Network, error) {
-	net, err := newNetwork(
+	return NewNetworkWithNodeProcessCreator(
 		log,
-		api.NewAPIClient,
+		networkConfig,
+		rootDir,
+		snapshotsDir,
+		reassignPortsIfUsed,
 		&nodeProcessCreator{
 			colorPicker: utils.NewColorPicker(),
 			log:         log,
 			stdout:      os.Stdout,
 			stderr:      os.Stderr,
+			consoles:    newConsoleHub(),
 		},
+	)
+}
+
+// NewNetworkWithNodeProcessCreator is NewNetwork launching the node
+// processes with [nodeProcessCreator] (e.g. as containers).
+func NewNetworkWithNodeProcessCreator(
+	log logging.Logger,
+	networkConfig network.Config,
+	rootDir string,
+	snapshotsDir string,
+	reassignPortsIfUsed bool,
+	nodeProcessCreator NodeProcessCreator,
+) (network.Network, error) {
+	rootDir, snapshotsDir, err := namedNetworkDirs(networkConfig.Name, rootDir, snapshotsDir)
+	if err != nil {
+		return nil, err
+	}
+	net, err := newNetwork(
+		log,
+		api.NewAPIClient,
+		nodeProcessCreator,
 		rootDir,
 		snapshotsDir,
 		reassignPortsIfUsed,
@@ -286,6 +345,23 @@ func NewNetwork(
 	return net, net.loadConfig(context.Background(), networkConfig)
 }
 
+// Returns the root dir and snapshots dir of the network [networkName], given
+// the ones requested, which may be empty to take the defaults.
+// Named networks get a stable root dir, so that concurrent networks with
+// the same name are detected, and keep their snapshots apart.
+func namedNetworkDirs(networkName string, rootDir string, snapshotsDir string) (string, string, error) {
+	if networkName == "" {
+		return rootDir, snapshotsDir, nil
+	}
+	if rootDir == "" {
+		rootDir = filepath.Join(os.TempDir(), constants.RootDirPrefix, networkRootDirPrefix+"-"+networkName)
+		if err := os.MkdirAll(rootDir, os.ModePerm); err != nil {
+			return "", "", err
+		}
+	}
+	return rootDir, NetworkSnapshotsDir(snapshotsDir, networkName), nil
+}
+
 // See NewNetwork.
 // [newAPIClientF] is used to create new API clients.
 // [nodeProcessCreator] is used to launch new node processes.
@@ -331,6 +407,12 @@ func newNetwork(
 		snapshotsDir:             snapshotsDir,
 		reassignPortsIfUsed:      reassignPortsIfUsed,
 		subnetID2ElasticSubnetID: map[ids.ID]ids.ID{},
+		backups:                  map[string]*nodeBackup{},
+		groups:                   map[string][]string{},
+		partition:                newPartition(),
+		events:                   newEventBus(log),
+		consoles:                 consolesOf(nodeProcessCreator),
+		rng:                      rand.New(rand.NewSource(time.Now().UnixNano())), //nolint:gosec
 	}
 	return net, nil
 }
@@ -423,23 +505,122 @@ func NewDefaultConfigNNodes(binaryPath string, numNodes uint32) (network.Config,
 	return netConfig, nil
 }
 
+// SingleNodeFlags returns the flags of a single node network, so that its
+// node validates alone, with sybil protection disabled, and is healthy
+// without peers. Sybil protection can't be disabled on public networks.
+func SingleNodeFlags() map[string]interface{} {
+	return map[string]interface{}{
+		"sybil-protection-enabled":      false,
+		"snow-sample-size":              1,
+		"snow-quorum-size":              1,
+		"network-health-min-conn-peers": 0,
+	}
+}
+
+// NewSingleNodeConfig creates a new network config with a single node, the
+// first one of the default network, for users who need an API endpoint
+// rather than consensus realism
+func NewSingleNodeConfig(binaryPath string) network.Config {
+	config := NewDefaultConfig(binaryPath)
+	config.NodeConfigs = config.NodeConfigs[:1]
+	for k, v := range SingleNodeFlags() {
+		config.Flags[k] = v
+	}
+	return config
+}
+
+// NewMixedVersionConfig creates a new default network config with
+// [numNodes] nodes, the node i running nodeBinaries[i % len(nodeBinaries)].
+// Each binary is either a luxd release version (e.g. v1.10.3), downloaded
+// once the network starts, or the path to a binary.
+func NewMixedVersionConfig(nodeBinaries []string, numNodes uint32) (network.Config, error) {
+	if len(nodeBinaries) == 0 {
+		return network.Config{}, errors.New("no node binaries given")
+	}
+	if int(numNodes) < len(nodeBinaries) {
+		return network.Config{}, fmt.Errorf("%d nodes can't run %d binaries", numNodes, len(nodeBinaries))
+	}
+	netConfig, err := NewDefaultConfigNNodes("", numNodes)
+	if err != nil {
+		return netConfig, err
+	}
+	for i := range netConfig.NodeConfigs {
+		setNodeBinary(&netConfig.NodeConfigs[i], nodeBinaries[i%len(nodeBinaries)])
+	}
+	return netConfig, nil
+}
+
+// NewUpgradeCompatibilityConfig creates a new network config with [numNodes]
+// nodes alternating between the [previous] (N-1) and [current] (N) binaries,
+// to check that both versions keep a network healthy together, as during a
+// rolling upgrade. See NewMixedVersionConfig.
+func NewUpgradeCompatibilityConfig(previous string, current string, numNodes uint32) (network.Config, error) {
+	return NewMixedVersionConfig([]string{previous, current}, numNodes)
+}
+
+// Sets the binary of [nodeConfig] to [binary], a luxd release version or a path
+func setNodeBinary(nodeConfig *node.Config, binary string) {
+	if semver.IsValid(binary) && !strings.ContainsRune(binary, filepath.Separator) {
+		nodeConfig.BinaryPath = ""
+		nodeConfig.BinaryVersion = binary
+		return
+	}
+	nodeConfig.BinaryPath = binary
+	nodeConfig.BinaryVersion = ""
+}
+
+// NewSingleNodeNetwork returns a new network of a single node.
+// See NewSingleNodeConfig.
+func NewSingleNodeNetwork(
+	log logging.Logger,
+	binaryPath string,
+	reassignPortsIfUsed bool,
+) (network.Network, error) {
+	config := NewSingleNodeConfig(binaryPath)
+	return NewNetwork(log, config, "", "", reassignPortsIfUsed)
+}
+
 func (ln *localNetwork) loadConfig(ctx context.Context, networkConfig network.Config) error {
 	if err := networkConfig.Validate(); err != nil {
 		return fmt.Errorf("config failed validation: %w", err)
 	}
+	// the genesis validations start now, as shortened by the staking config
+	if err := networkConfig.ApplyStaking(time.Now()); err != nil {
+		return err
+	}
+
+	if networkConfig.Seed != 0 {
+		ln.rng = rand.New(rand.NewSource(networkConfig.Seed)) //nolint:gosec
+		// apart from [ln.rng], so that the keys don't depend on the ports in use
+		ln.keyRNG = rand.New(rand.NewSource(networkConfig.Seed)) //nolint:gosec
+	}
+
+	binaryPath, err := resolveBinaryPath(ctx, networkConfig.BinaryPath, networkConfig.BinaryVersion)
+	if err != nil {
+		return err
+	}
+
+	if networkConfig.Name != "" {
+		ln.name = networkConfig.Name
+		ln.log = utils.WithFields(ln.log, utils.NetworkField(ln.name))
+	}
+	if err := ln.acquireRootDir(); err != nil {
+		return err
+	}
+
 	ln.log.Info("creating network", zap.Int("node-num", len(networkConfig.NodeConfigs)))
 
 	ln.genesis = []byte(networkConfig.Genesis)
 
-	var err error
 	ln.networkID, err = utils.NetworkIDFromGenesis([]byte(networkConfig.Genesis))
 	if err != nil {
+		ln.releaseRootDir()
 		return fmt.Errorf("couldn't get network ID from genesis: %w", err)
 	}
 
 	// save node defaults
 	ln.flags = networkConfig.Flags
-	ln.binaryPath = networkConfig.BinaryPath
+	ln.binaryPath = binaryPath
 	ln.chainConfigFiles = networkConfig.ChainConfigFiles
 	if ln.chainConfigFiles == nil {
 		ln.chainConfigFiles = map[string]string{}
@@ -452,6 +633,40 @@ func (ln *localNetwork) loadConfig(ctx context.Context, networkConfig network.Co
 	if ln.subnetConfigFiles == nil {
 		ln.subnetConfigFiles = map[string]string{}
 	}
+	ln.loopbackAliases = networkConfig.LoopbackAliases
+	ln.ipv6 = networkConfig.IPv6
+	ln.diskBudget = networkConfig.DiskBudget
+	ln.regionLinks = newRegionLinks(networkConfig.Topology)
+	ln.nodeNaming = networkConfig.NodeNaming
+	ln.dbRootDir = networkConfig.DBRootDir
+	ln.logsRootDir = networkConfig.LogsRootDir
+	ln.keysRootDir = networkConfig.KeysRootDir
+	ln.beacons = networkConfig.Beacons
+	ln.readinessProbes = networkConfig.ReadinessProbes
+	ln.labels = networkConfig.Labels
+	ln.apiClientOptions = networkConfig.APIClientOptions
+	if ln.apiClientOptions != nil {
+		// the clients of the nodes, including the ones of the health checks
+		ln.newAPIClientF = api.NewAPIClientFWithOptions(*ln.apiClientOptions)
+	}
+	if err := writeRootDirMetadata(ln.rootDir, ln.name, ln.labels); err != nil {
+		ln.releaseRootDir()
+		return err
+	}
+
+	// an explicit beacon set overrides the beacons of the node configs
+	networkConfig.ApplyBeacons()
+	externalBeacons, err := networkConfig.ExternalBeacons()
+	if err != nil {
+		ln.releaseRootDir()
+		return err
+	}
+	for _, b := range externalBeacons {
+		if err := ln.bootstraps.Add(b); err != nil {
+			ln.releaseRootDir()
+			return fmt.Errorf("couldn't add beacon %s: %w", b.ID(), err)
+		}
+	}
 
 	// Sort node configs so beacons start first
 	var nodeConfigs []node.Config
@@ -466,33 +681,73 @@ func (ln *localNetwork) loadConfig(ctx context.Context, networkConfig network.Co
 		}
 	}
 
-	for _, nodeConfig := range nodeConfigs {
-		if _, err := ln.addNode(nodeConfig); err != nil {
-			if err := ln.stop(ctx); err != nil {
-				// Clean up nodes already created
-				ln.log.Debug("error stopping network", zap.Error(err))
-			}
-			return fmt.Errorf("error adding node %s: %w", nodeConfig.Name, err)
+	if err := ln.addNodes(nodeConfigs); err != nil {
+		if err := ln.stop(ctx); err != nil {
+			// Clean up nodes already created
+			ln.log.Debug("error stopping network", zap.Error(err))
 		}
+		return err
 	}
 
+	go ln.monitorDiskUsage()
+
 	return nil
 }
 
 // See network.Network
-func (ln *localNetwork) AddNode(nodeConfig node.Config) (node.Node, error) {
+func (ln *localNetwork) AddNode(nodeConfig node.Config) (_ node.Node, err error) {
+	defer utils.StartOperation(ln.log, "add-node", utils.NodeField(nodeConfig.Name))(&err)
+
+	if nodeConfig.PrimaryValidator != nil {
+		if err := nodeConfig.PrimaryValidator.Validate(); err != nil {
+			return nil, err
+		}
+	}
+
+	node, err := ln.lockedAddNode(nodeConfig)
+	if err != nil {
+		return nil, err
+	}
+	if nodeConfig.PrimaryValidator != nil {
+		// the registration waits without [ln.lock], which
+		// would block every other operation meanwhile
+		ctx, cancel := context.WithTimeout(context.Background(), primaryValidatorTimeout)
+		defer cancel()
+		if err := ln.registerPrimaryValidator(ctx, node.GetName(), *nodeConfig.PrimaryValidator); err != nil {
+			return node, fmt.Errorf("couldn't register node %q as primary network validator: %w", node.GetName(), err)
+		}
+	}
+	return node, nil
+}
+
+// Like addNode, taking [ln.lock]
+func (ln *localNetwork) lockedAddNode(nodeConfig node.Config) (node.Node, error) {
 	ln.lock.Lock()
 	defer ln.lock.Unlock()
 
 	if ln.stopCalled() {
 		return nil, network.ErrStopped
 	}
-
 	return ln.addNode(nodeConfig)
 }
 
 // Assumes [ln.lock] is held and [ln.Stop] hasn't been called.
 func (ln *localNetwork) addNode(nodeConfig node.Config) (node.Node, error) {
+	launch, err := ln.prepareNode(nodeConfig)
+	if err != nil {
+		return nil, err
+	}
+	if err := ln.launchNode(launch); err != nil {
+		ln.abortLaunch(launch)
+		return nil, err
+	}
+	return ln.registerNode(launch)
+}
+
+// Completes [nodeConfig] with the network defaults, and reserves the ports,
+// dirs and beacon entry of the node, leaving it ready to be launched.
+// Assumes [ln.lock] is held and [ln.Stop] hasn't been called.
+func (ln *localNetwork) prepareNode(nodeConfig node.Config) (*nodeLaunch, error) {
 	if nodeConfig.Flags == nil {
 		nodeConfig.Flags = map[string]interface{}{}
 	}
@@ -507,6 +762,15 @@ func (ln *localNetwork) addNode(nodeConfig node.Config) (node.Node, error) {
 	}
 
 	// load node defaults
+	if nodeConfig.BinaryPath == "" && nodeConfig.BinaryVersion != "" {
+		ctx, cancel := context.WithTimeout(context.Background(), binaryDownloadTimeout)
+		binaryPath, err := resolveBinaryPath(ctx, "", nodeConfig.BinaryVersion)
+		cancel()
+		if err != nil {
+			return nil, err
+		}
+		nodeConfig.BinaryPath = binaryPath
+	}
 	if nodeConfig.BinaryPath == "" {
 		nodeConfig.BinaryPath = ln.binaryPath
 	}
@@ -529,25 +793,20 @@ func (ln *localNetwork) addNode(nodeConfig node.Config) (node.Node, error) {
 		}
 	}
 	addNetworkFlags(ln.flags, nodeConfig.Flags)
-
-	// it shouldn't happen that just one is empty, most probably both,
-	// but in any case if just one is empty it's unusable so we just assign a new one.
-	if nodeConfig.StakingCert == "" || nodeConfig.StakingKey == "" {
-		stakingCert, stakingKey, err := staking.NewCertAndKeyBytes()
-		if err != nil {
-			return nil, fmt.Errorf("couldn't generate staking Cert/Key: %w", err)
-		}
-		nodeConfig.StakingCert = string(stakingCert)
-		nodeConfig.StakingKey = string(stakingKey)
+	nodeConfig.Labels = node.MergeLabels(ln.labels, nodeConfig.Labels)
+	if err := nodeConfig.ApplyRolePreset(); err != nil {
+		return nil, err
 	}
-	if nodeConfig.StakingSigningKey == "" {
-		key, err := bls.NewSecretKey()
-		if err != nil {
-			return nil, fmt.Errorf("couldn't generate new signing key: %w", err)
-		}
-		keyBytes := bls.SecretKeyToBytes(key)
-		encodedKey := base64.StdEncoding.EncodeToString(keyBytes)
-		nodeConfig.StakingSigningKey = encodedKey
+	// convert the durations and sizes given with units
+	if err := node.NormalizeFlags(nodeConfig.Flags); err != nil {
+		return nil, err
+	}
+	if err := ln.setBindIP(&nodeConfig); err != nil {
+		return nil, err
+	}
+
+	if err := ln.setNodeKeys(&nodeConfig); err != nil {
+		return nil, err
 	}
 
 	if err := ln.setNodeName(&nodeConfig); err != nil {
@@ -568,12 +827,18 @@ func (ln *localNetwork) addNode(nodeConfig node.Config) (node.Node, error) {
 			return nil, fmt.Errorf("couldn't unmarshal config file: %w", err)
 		}
 	}
+	if err := setDBType(&nodeConfig, configFile); err != nil {
+		return nil, err
+	}
 
 	// Get node version
 	nodeSemVer, err := ln.getNodeSemVer(nodeConfig)
 	if err != nil {
 		return nil, err
 	}
+	if err := nodeConfig.ApplyFeatures(nodeSemVer); err != nil {
+		return nil, fmt.Errorf("node %q: %w", nodeConfig.Name, err)
+	}
 
 	nodeData, err := ln.buildArgs(nodeSemVer, configFile, nodeDir, &nodeConfig)
 	if err != nil {
@@ -586,18 +851,53 @@ func (ln *localNetwork) addNode(nodeConfig node.Config) (node.Node, error) {
 		return nil, fmt.Errorf("couldn't get node ID: %w", err)
 	}
 
+	// Run the node in its work dir, which defaults to its data dir
+	processConfig := nodeConfig
+	processConfig.WorkDir = nodeWorkDir(nodeConfig.WorkDir, nodeData.dataDir)
+	if err := os.MkdirAll(processConfig.WorkDir, 0o750); err != nil {
+		return nil, fmt.Errorf("couldn't create node work dir: %w", err)
+	}
+
+	// If this node is a beacon, add its IP/ID to the beacon lists.
+	// Note that we do this *after* we set this node's bootstrap IPs/IDs
+	// so this node won't try to use itself as a beacon.
+	isNewBeacon := !isPausedNode && nodeConfig.IsBeacon
+	if isNewBeacon {
+		if err := ln.bootstraps.Add(beacon.New(nodeID, ips.IPPort{
+			IP:   beaconIP(nodeconfig.AdvertisedIP(nodeConfig)),
+			Port: nodeData.p2pPort,
+		})); err != nil {
+			return nil, err
+		}
+	}
+	return &nodeLaunch{
+		config:        nodeConfig,
+		processConfig: processConfig,
+		data:          nodeData,
+		nodeID:        nodeID,
+		isNewBeacon:   isNewBeacon,
+	}, nil
+}
+
+// Starts the process of the node prepared as [launch].
+// Doesn't access the state of [ln], so it may be called concurrently.
+func (ln *localNetwork) launchNode(launch *nodeLaunch) error {
+	nodeConfig, nodeData := launch.config, launch.data
 	// Start the Lux node and pass it the flags defined above
-	nodeProcess, err := ln.nodeProcessCreator.NewNodeProcess(nodeConfig, nodeData.args...)
+	launch.startedAt = time.Now()
+	nodeProcess, err := ln.nodeProcessCreator.NewNodeProcess(launch.processConfig, nodeData.args...)
 	if err != nil {
-		return nil, fmt.Errorf(
+		return fmt.Errorf(
 			"couldn't create new node process with binary %q and args %v: %w",
 			nodeConfig.BinaryPath, nodeData.args, err,
 		)
 	}
+	launch.process = nodeProcess
+	launch.processSpawn = time.Since(launch.startedAt)
 
 	ln.log.Info(
 		"adding node",
-		zap.String("node-name", nodeConfig.Name),
+		utils.NodeField(nodeConfig.Name),
 		zap.String("node-dir", nodeData.dataDir),
 		zap.String("log-dir", nodeData.logsDir),
 		zap.String("db-dir", nodeData.dbDir),
@@ -607,18 +907,32 @@ func (ln *localNetwork) addNode(nodeConfig node.Config) (node.Node, error) {
 
 	ln.log.Debug(
 		"starting node",
-		zap.String("name", nodeConfig.Name),
+		utils.NodeField(nodeConfig.Name),
 		zap.String("binaryPath", nodeConfig.BinaryPath),
 		zap.Strings("args", nodeData.args),
 	)
+	return nil
+}
 
+// Undoes the preparation of [launch], whose process couldn't be started.
+// Assumes [ln.lock] is held.
+func (ln *localNetwork) abortLaunch(launch *nodeLaunch) {
+	if launch.isNewBeacon {
+		_ = ln.bootstraps.RemoveByID(launch.nodeID)
+	}
+}
+
+// Adds the node started as [launch] to the network.
+// Assumes [ln.lock] is held.
+func (ln *localNetwork) registerNode(launch *nodeLaunch) (node.Node, error) {
+	nodeConfig, nodeData := launch.config, launch.data
 	// Create a wrapper for this node so we can reference it later
 	node := &localNode{
 		name:          nodeConfig.Name,
-		nodeID:        nodeID,
+		nodeID:        launch.nodeID,
 		networkID:     ln.networkID,
-		client:        ln.newAPIClientF("localhost", nodeData.apiPort),
-		process:       nodeProcess,
+		client:        ln.newAPIClientF(nodeData.apiHost, nodeData.apiPort),
+		process:       launch.process,
 		apiPort:       nodeData.apiPort,
 		p2pPort:       nodeData.p2pPort,
 		getConnFunc:   defaultGetConnFunc,
@@ -628,36 +942,75 @@ func (ln *localNetwork) addNode(nodeConfig node.Config) (node.Node, error) {
 		config:        nodeConfig,
 		pluginDir:     nodeData.pluginDir,
 		httpHost:      nodeData.httpHost,
+		apiHost:       nodeData.apiHost,
+		flags:         nodeData.flags,
+		bindIP:        nodeConfig.BindIP,
 		attachedPeers: map[string]peer.Peer{},
+		timings: node.Timings{
+			StartedAt:    launch.startedAt,
+			ProcessSpawn: launch.processSpawn,
+		},
 	}
-	ln.nodes[node.name] = node
-	// If this node is a beacon, add its IP/ID to the beacon lists.
-	// Note that we do this *after* we set this node's bootstrap IPs/IDs
-	// so this node won't try to use itself as a beacon.
-	if !isPausedNode && nodeConfig.IsBeacon {
-		err = ln.bootstraps.Add(beacon.New(nodeID, ips.IPPort{
-			IP:   net.IPv6loopback,
-			Port: nodeData.p2pPort,
-		}))
+	if err := ln.startPortForwarder(node); err != nil {
+		_ = launch.process.Stop(context.Background())
+		ln.abortLaunch(launch)
+		ln.releasePorts(nodeData.apiPort, nodeData.p2pPort)
+		return nil, err
 	}
-	return node, err
+	ln.nodes[node.name] = node
+	ln.trackRegion(node)
+	ln.trackPartition(node)
+	ln.updateMonitoringTargets()
+	ln.events.publish(network.EventNodeStarted, node.name, nil)
+	return node, nil
 }
 
 // See network.Network
+// Nodes that stopped because one of their ports was taken after being
+// chosen are restarted with the next free port, up to [maxPortCollisionRetries] times.
 func (ln *localNetwork) Healthy(ctx context.Context) error {
-	ln.lock.RLock()
-	defer ln.lock.RUnlock()
+	return ln.HealthyWithProgress(ctx, nil)
+}
 
-	return ln.healthy(ctx)
+// See network.Network
+func (ln *localNetwork) HealthyWithProgress(ctx context.Context, onProgress func(network.NodeHealthProgress)) (err error) {
+	defer utils.StartOperation(ln.log, "healthy")(&err)
+
+	reporter := newHealthProgressReporter(onProgress)
+	for retry := 0; ; retry++ {
+		ln.lock.RLock()
+		err := ln.healthyWithProgress(ctx, reporter)
+		ln.lock.RUnlock()
+
+		var collision *portCollisionError
+		if retry == maxPortCollisionRetries || !errors.As(err, &collision) {
+			if err != nil {
+				return err
+			}
+			return ln.awaitReadiness(ctx)
+		}
+		if err := ln.retryPortCollision(ctx, collision); err != nil {
+			return err
+		}
+	}
 }
 
 func (ln *localNetwork) healthy(ctx context.Context) error {
+	return ln.healthyWithProgress(ctx, newHealthProgressReporter(nil))
+}
+
+// Returns nil once the nodes not paused nor frozen are healthy,
+// reporting their progress to [reporter] meanwhile
+func (ln *localNetwork) healthyWithProgress(ctx context.Context, reporter *healthProgressReporter) error {
 	ln.log.Info("checking local network healthiness", zap.Int("num-of-nodes", len(ln.nodes)))
 
 	// Return unhealthy if the network is stopped
 	if ln.stopCalled() {
 		return network.ErrStopped
 	}
+	if err := ln.checkDiskBudget(); err != nil {
+		return err
+	}
 
 	// Derive a new context that's cancelled when Stop is called,
 	// so that calls to Healthy() below immediately return.
@@ -675,8 +1028,8 @@ func (ln *localNetwork) healthy(ctx context.Context) error {
 
 	errGr, ctx := errgroup.WithContext(ctx)
 	for _, node := range ln.nodes {
-		if node.paused {
-			// no health check for paused nodes
+		if node.paused || node.frozen {
+			// no health check for paused or frozen nodes
 			continue
 		}
 		node := node
@@ -684,27 +1037,47 @@ func (ln *localNetwork) healthy(ctx context.Context) error {
 		errGr.Go(func() error {
 			// Every [healthCheckFreq], query node for health status.
 			// Do this until ctx timeout or network closed.
+			var progress network.NodeHealthProgress
 			for {
 				if node.Status() != status.Running {
 					// If we had stopped this node ourselves, it wouldn't be in [ln.nodes].
 					// Since it is, it means the node stopped unexpectedly.
-					return fmt.Errorf("node %q stopped unexpectedly", nodeName)
+					if port, ok := findBindErrorPort(node.GetLogsDir()); ok {
+						return &portCollisionError{nodeName: nodeName, port: port}
+					}
+					err := fmt.Errorf("node %q stopped unexpectedly", nodeName)
+					ln.events.publish(network.EventNodeUnhealthy, nodeName, err)
+					return err
 				}
 				health, err := node.client.HealthAPI().Health(ctx, nil)
+				if err == nil {
+					node.observeHealth(health.Healthy)
+				}
+				// the call interrupted at the end of the wait tells nothing
+				if ctx.Err() == nil || progress.Node == "" {
+					progress = newHealthProgress(nodeName, health, err)
+					reporter.report(progress)
+				}
 				if err == nil && health.Healthy {
-					ln.log.Debug("node became healthy", zap.String("name", nodeName))
+					ln.log.Debug("node became healthy", utils.NodeField(nodeName), zap.Duration("time-to-healthy", node.GetTimings().Healthy))
 					return nil
 				}
 				select {
 				case <-ctx.Done():
-					return fmt.Errorf("node %q failed to become healthy within timeout, or network stopped", nodeName)
+					err := fmt.Errorf("node %q failed to become healthy within timeout, or network stopped (%s)", nodeName, progress)
+					ln.events.publish(network.EventNodeUnhealthy, nodeName, err)
+					return err
 				case <-time.After(healthCheckFreq):
 				}
 			}
 		})
 	}
 	// Wait until all nodes are ready or timeout
-	return errGr.Wait()
+	if err := errGr.Wait(); err != nil {
+		return err
+	}
+	ln.events.publish(network.EventNetworkHealthy, "", nil)
+	return nil
 }
 
 // See network.Network
@@ -751,8 +1124,10 @@ func (ln *localNetwork) GetAllNodes() (map[string]node.Node, error) {
 	return nodesCopy, nil
 }
 
-func (ln *localNetwork) Stop(ctx context.Context) error {
-	err := network.ErrStopped
+func (ln *localNetwork) Stop(ctx context.Context) (err error) {
+	defer utils.StartOperation(ln.log, "stop")(&err)
+
+	err = network.ErrStopped
 	ln.stopOnce.Do(
 		func() {
 			close(ln.onStopCh)
@@ -761,6 +1136,8 @@ func (ln *localNetwork) Stop(ctx context.Context) error {
 			defer ln.lock.Unlock()
 
 			err = ln.stop(ctx)
+			ln.events.close()
+			ln.consoles.close()
 		},
 	)
 	return err
@@ -772,45 +1149,62 @@ func (ln *localNetwork) stop(ctx context.Context) error {
 	for nodeName := range ln.nodes {
 		stopCtx, stopCtxCancel := context.WithTimeout(ctx, stopTimeout)
 		if err := ln.removeNode(stopCtx, nodeName); err != nil {
-			ln.log.Error("error stopping node", zap.String("name", nodeName), zap.Error(err))
+			ln.log.Error("error stopping node", utils.NodeField(nodeName), zap.Error(err))
 			errs.Add(err)
 		}
 		stopCtxCancel()
 	}
+	stopMonitoring(ln.monitoring)
+	ln.monitoring = nil
+	ln.releaseRootDir()
 	ln.log.Info("done stopping network")
 	return errs.Err
 }
 
 // Sends a SIGTERM to the given node and removes it from this network.
-func (ln *localNetwork) RemoveNode(ctx context.Context, nodeName string) error {
+func (ln *localNetwork) RemoveNode(ctx context.Context, nodeName string) (err error) {
+	defer utils.StartOperation(ln.log, "remove-node", utils.NodeField(nodeName))(&err)
+
 	ln.lock.Lock()
 	defer ln.lock.Unlock()
 
 	if ln.stopCalled() {
 		return network.ErrStopped
 	}
+	delete(ln.backups, nodeName)
+	ln.leaveNodeGroups(nodeName)
 	return ln.removeNode(ctx, nodeName)
 }
 
 // Assumes [ln.lock] is held.
 func (ln *localNetwork) removeNode(ctx context.Context, nodeName string) error {
-	ln.log.Debug("removing node", zap.String("name", nodeName))
+	ln.log.Debug("removing node", utils.NodeField(nodeName))
 	node, ok := ln.nodes[nodeName]
 	if !ok {
 		return fmt.Errorf("node %q not found", nodeName)
 	}
+	// once the process is stopped, so its last lines are published
+	defer ln.consoles.closeNode(nodeName)
 
 	paused := node.paused
 
 	// If the node wasn't a beacon, we don't care
 	_ = ln.bootstraps.RemoveByID(node.nodeID)
 	delete(ln.nodes, nodeName)
+	ln.releasePorts(node.apiPort, node.p2pPort)
+	stopPortForwarder(node)
+	stopCapture(node)
+	ln.untrackRegion(node)
+	ln.untrackPartition(node)
+	ln.updateMonitoringTargets()
 
 	if !paused {
 		// cchain eth api uses a websocket connection and must be closed before stopping the node,
 		// to avoid errors logs at client
 		node.client.CChainEthAPI().Close()
-		if exitCode := node.process.Stop(ctx); exitCode != 0 {
+		exitCode := node.process.Stop(ctx)
+		ln.events.publish(network.EventNodeStopped, nodeName, nil)
+		if exitCode != 0 {
 			return fmt.Errorf("node %q exited with exit code: %d", nodeName, exitCode)
 		}
 	}
@@ -818,7 +1212,9 @@ func (ln *localNetwork) removeNode(ctx context.Context, nodeName string) error {
 }
 
 // Sends a SIGTERM to the given node and keeps it in the network with paused state
-func (ln *localNetwork) PauseNode(ctx context.Context, nodeName string) error {
+func (ln *localNetwork) PauseNode(ctx context.Context, nodeName string) (err error) {
+	defer utils.StartOperation(ln.log, "pause-node", utils.NodeField(nodeName))(&err)
+
 	ln.lock.Lock()
 	defer ln.lock.Unlock()
 	if ln.stopCalled() {
@@ -829,7 +1225,7 @@ func (ln *localNetwork) PauseNode(ctx context.Context, nodeName string) error {
 
 // Assumes [ln.lock] is held.
 func (ln *localNetwork) pauseNode(ctx context.Context, nodeName string) error {
-	ln.log.Debug("pausing node", zap.String("name", nodeName))
+	ln.log.Debug("pausing node", utils.NodeField(nodeName))
 	node, ok := ln.nodes[nodeName]
 	if !ok {
 		return fmt.Errorf("node %q not found", nodeName)
@@ -837,10 +1233,16 @@ func (ln *localNetwork) pauseNode(ctx context.Context, nodeName string) error {
 	if node.paused {
 		return fmt.Errorf("node has been paused already")
 	}
+	stopPortForwarder(node)
+	stopCapture(node)
+	ln.untrackRegion(node)
+	ln.untrackPartition(node)
 	// cchain eth api uses a websocket connection and must be closed before stopping the node,
 	// to avoid errors logs at client
 	node.client.CChainEthAPI().Close()
-	if exitCode := node.process.Stop(ctx); exitCode != 0 {
+	exitCode := node.process.Stop(ctx)
+	ln.events.publish(network.EventNodeStopped, nodeName, nil)
+	if exitCode != 0 {
 		return fmt.Errorf("node %q exited with exit code: %d", nodeName, exitCode)
 	}
 	syscall.Sync()
@@ -852,7 +1254,9 @@ func (ln *localNetwork) pauseNode(ctx context.Context, nodeName string) error {
 func (ln *localNetwork) ResumeNode(
 	ctx context.Context,
 	nodeName string,
-) error {
+) (err error) {
+	defer utils.StartOperation(ln.log, "resume-node", utils.NodeField(nodeName))(&err)
+
 	ln.lock.Lock()
 	defer ln.lock.Unlock()
 
@@ -883,6 +1287,7 @@ func (ln *localNetwork) resumeNode(
 	if _, err := ln.addNode(nodeConfig); err != nil {
 		return err
 	}
+	ln.nodes[nodeName].inheritRuntime(node)
 	return nil
 }
 
@@ -897,7 +1302,9 @@ func (ln *localNetwork) RestartNode(
 	chainConfigs map[string]string,
 	upgradeConfigs map[string]string,
 	subnetConfigs map[string]string,
-) error {
+) (err error) {
+	defer utils.StartOperation(ln.log, "restart-node", utils.NodeField(nodeName))(&err)
+
 	ln.lock.Lock()
 	defer ln.lock.Unlock()
 
@@ -928,6 +1335,11 @@ func (ln *localNetwork) restartNode(
 		return fmt.Errorf("node %q not found", nodeName)
 	}
 
+	// keep the current config, as it is modified below, to support rollback
+	if err := ln.backupNode(nodeName); err != nil {
+		return err
+	}
+
 	nodeConfig := node.GetConfig()
 
 	if binaryPath != "" {
@@ -970,10 +1382,26 @@ func (ln *localNetwork) restartNode(
 	if _, err := ln.addNode(nodeConfig); err != nil {
 		return err
 	}
+	ln.nodes[nodeName].inheritRuntime(node)
+	ln.events.publish(network.EventNodeRestarted, nodeName, nil)
 
 	return nil
 }
 
+// Returns [binaryPath] if given, or else the path to the luxd release of
+// [binaryVersion], downloading it unless cached. Returns an empty path if
+// neither is given.
+func resolveBinaryPath(ctx context.Context, binaryPath string, binaryVersion string) (string, error) {
+	if binaryPath != "" || binaryVersion == "" {
+		return binaryPath, nil
+	}
+	path, err := binaries.Get(ctx, binaryVersion)
+	if err != nil {
+		return "", fmt.Errorf("couldn't get luxd %s: %w", binaryVersion, err)
+	}
+	return path, nil
+}
+
 // Returns whether Stop has been called.
 func (ln *localNetwork) stopCalled() bool {
 	select {
@@ -996,19 +1424,26 @@ func (ln *localNetwork) setNodeName(nodeConfig *node.Config) error {
 	// If no name was given, use default name pattern
 	if len(nodeConfig.Name) == 0 {
 		for {
-			nodeConfig.Name = fmt.Sprintf("%s%d", defaultNodeNamePrefix, ln.nextNodeSuffix)
+			nodeConfig.Name = ln.nodeNaming.Name(ln.nextNodeSuffix)
 			_, ok := ln.nodes[nodeConfig.Name]
-			if !ok {
+			_, prepared := ln.preparedNodeNames[nodeConfig.Name]
+			if !ok && !prepared {
 				break
 			}
 			ln.nextNodeSuffix++
 		}
 	}
+	if err := node.ValidateName(nodeConfig.Name); err != nil {
+		return err
+	}
 	// Enforce name uniqueness
 	// Only paused nodes are enabled to be started with repeated name
 	if node, ok := ln.nodes[nodeConfig.Name]; ok && !node.paused {
 		return fmt.Errorf("repeated node name %q", nodeConfig.Name)
 	}
+	if _, ok := ln.preparedNodeNames[nodeConfig.Name]; ok {
+		return fmt.Errorf("repeated node name %q", nodeConfig.Name)
+	}
 	return nil
 }
 
@@ -1021,6 +1456,11 @@ type buildArgsReturn struct {
 	logsDir   string
 	pluginDir string
 	httpHost  string
+	// host used by the runner to reach the node API
+	apiHost string
+	// flags the node is started with, keeping the
+	// type they were given with in node config
+	flags map[string]interface{}
 }
 
 // buildArgs returns the:
@@ -1036,108 +1476,78 @@ func (ln *localNetwork) buildArgs(
 	nodeDir string,
 	nodeConfig *node.Config,
 ) (buildArgsReturn, error) {
-	// httpHost from all configs for node
-	httpHost, err := getConfigEntry(nodeConfig.Flags, configFile, config.HTTPHostKey, "")
-	if err != nil {
-		return buildArgsReturn{}, err
-	}
-
-	// Tell the node to put all node related data in [nodeDir] unless given in config file
-	dataDir, err := getConfigEntry(nodeConfig.Flags, configFile, config.DataDirKey, nodeDir)
-	if err != nil {
-		return buildArgsReturn{}, err
-	}
-
-	// pluginDir from all configs for node
-	pluginDir, err := getConfigEntry(nodeConfig.Flags, configFile, config.PluginDirKey, "")
+	// Use random free API port unless given in config file
+	apiPort, err := getPort(ln.rng, nodeConfig.Flags, configFile, config.HTTPPortKey, ln.reassignPortsIfUsed)
 	if err != nil {
 		return buildArgsReturn{}, err
 	}
 
-	// Tell the node to put the database in [dataDir/db] unless given in config file
-	dbDir, err := getConfigEntry(nodeConfig.Flags, configFile, config.DBPathKey, filepath.Join(dataDir, defaultDBSubdir))
+	// Use a random free P2P (staking) port unless given in config file
+	p2pPort, err := getPort(ln.rng, nodeConfig.Flags, configFile, config.StakingPortKey, ln.reassignPortsIfUsed)
 	if err != nil {
 		return buildArgsReturn{}, err
 	}
 
-	// Tell the node to put the log directory in [dataDir/logs] unless given in config file
-	logsDir, err := getConfigEntry(nodeConfig.Flags, configFile, config.LogsDirKey, filepath.Join(dataDir, defaultLogsSubdir))
+	// Avoid collisions with ports used by other named networks
+	apiPort, err = ln.reservePort(apiPort)
 	if err != nil {
 		return buildArgsReturn{}, err
 	}
-
-	// Use random free API port unless given in config file
-	apiPort, err := getPort(nodeConfig.Flags, configFile, config.HTTPPortKey, ln.reassignPortsIfUsed)
+	p2pPort, err = ln.reservePort(p2pPort)
 	if err != nil {
+		ln.releasePorts(apiPort)
 		return buildArgsReturn{}, err
 	}
 
-	// Use a random free P2P (staking) port unless given in config file
-	// Use random free API port unless given in config file
-	p2pPort, err := getPort(nodeConfig.Flags, configFile, config.StakingPortKey, ln.reassignPortsIfUsed)
-	if err != nil {
-		return buildArgsReturn{}, err
-	}
-
-	// Flags for Lux
-	flags := map[string]string{
-		config.NetworkNameKey:  fmt.Sprintf("%d", ln.networkID),
-		config.DataDirKey:      dataDir,
-		config.DBPathKey:       dbDir,
-		config.LogsDirKey:      logsDir,
-		config.HTTPPortKey:     fmt.Sprintf("%d", apiPort),
-		config.StakingPortKey:  fmt.Sprintf("%d", p2pPort),
-		config.BootstrapIPsKey: ln.bootstraps.IPsArg(),
-		config.BootstrapIDsKey: ln.bootstraps.IDsArg(),
+	for flagName := range nodeConfig.Flags {
+		if _, ok := warnFlags[flagName]; ok {
+			ln.log.Warn("A provided flag can create conflicts with the runner. The suggestion is to remove this flag", zap.String("flag-name", flagName))
+		}
 	}
 
 	// Write staking key/cert etc. to disk so the new node can use them,
-	// and get flag that point the node to those files
-	fileFlags, err := writeFiles(ln.networkID, ln.genesis, dataDir, nodeConfig)
+	// and get the flags of the node
+	layout, err := nodeconfig.Render(nodeConfig, nodeconfig.Options{
+		NetworkID:        ln.networkID,
+		Genesis:          ln.genesis,
+		NodeDir:          nodeDir,
+		DBRootDir:        ln.dbRootDir,
+		LogsRootDir:      ln.logsRootDir,
+		KeysRootDir:      ln.keysRootDir,
+		APIPort:          apiPort,
+		P2PPort:          p2pPort,
+		BootstrapIPs:     ln.bootstraps.IPsArg(),
+		BootstrapIDs:     ln.bootstraps.IDsArg(),
+		DefaultPluginDir: discoverPluginDir(nodeConfig.BinaryPath),
+		NodeVersion:      nodeSemVer,
+	})
 	if err != nil {
+		ln.releasePorts(apiPort, p2pPort)
 		return buildArgsReturn{}, err
 	}
-	for k := range fileFlags {
-		flags[k] = fileFlags[k]
-	}
+	failpoint.Inject(failpoint.AfterWriteFiles)
 
-	// avoid given these again, as apiPort/p2pPort can be dynamic even if given in nodeConfig
-	portFlags := set.Set[string]{
-		config.HTTPPortKey:    {},
-		config.StakingPortKey: {},
+	startFlags := make(map[string]interface{}, len(layout.Flags))
+	for flagName, flagVal := range layout.Flags {
+		startFlags[flagName] = flagVal
 	}
-
-	// Add flags given in node config.
-	// Note these will overwrite existing flags if the same flag is given twice.
 	for flagName, flagVal := range nodeConfig.Flags {
-		if _, ok := warnFlags[flagName]; ok {
-			ln.log.Warn("A provided flag can create conflicts with the runner. The suggestion is to remove this flag", zap.String("flag-name", flagName))
-		}
-		if portFlags.Contains(flagName) {
-			continue
+		if layout.Flags[flagName] == fmt.Sprintf("%v", flagVal) {
+			startFlags[flagName] = flagVal
 		}
-		flags[flagName] = fmt.Sprintf("%v", flagVal)
-	}
-
-	// map input flags to the corresponding luxd version, making sure that latest flags don't break
-	// old luxd versions
-	flagsForLuxdVersion := getFlagsForLuxdVersion(nodeSemVer, flags)
-
-	// create args
-	args := []string{}
-	for k, v := range flagsForLuxdVersion {
-		args = append(args, fmt.Sprintf("--%s=%s", k, v))
 	}
 
 	return buildArgsReturn{
-		args:      args,
+		args:      layout.Args,
 		apiPort:   apiPort,
 		p2pPort:   p2pPort,
-		dataDir:   dataDir,
-		dbDir:     dbDir,
-		logsDir:   logsDir,
-		pluginDir: pluginDir,
-		httpHost:  httpHost,
+		dataDir:   layout.DataDir,
+		dbDir:     layout.DBDir,
+		logsDir:   layout.LogsDir,
+		pluginDir: layout.PluginDir,
+		httpHost:  layout.HTTPHost,
+		apiHost:   layout.APIHost,
+		flags:     startFlags,
 	}, nil
 }
 
@@ -1161,22 +1571,3 @@ func (ln *localNetwork) getNodeSemVer(nodeConfig node.Config) (string, error) {
 	nodeSemVer := "v" + matchs[1]
 	return nodeSemVer, nil
 }
-
-// ensure flags are compatible with the running node version
-func getFlagsForLuxdVersion(luxdVersion string, givenFlags map[string]string) map[string]string {
-	flags := maps.Clone(givenFlags)
-	for _, deprecatedFlagInfo := range deprecatedFlagsSupport {
-		if semver.Compare(luxdVersion, deprecatedFlagInfo.Version) < 0 {
-			if v, ok := flags[deprecatedFlagInfo.NewName]; ok {
-				if v != "" {
-					if deprecatedFlagInfo.ValueMap == "parent-dir" {
-						v = filepath.Dir(strings.TrimSuffix(v, "/"))
-					}
-					flags[deprecatedFlagInfo.OldName] = v
-				}
-				delete(flags, deprecatedFlagInfo.NewName)
-			}
-		}
-	}
-	return flags
-}
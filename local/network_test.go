@@ -3,13 +3,20 @@ package local
 import (
 	"bytes"
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"math/big"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
 	"testing"
 	"time"
 
@@ -21,15 +28,20 @@ import (
 	"github.com/luxdefi/netrunner/network/node"
 	"github.com/luxdefi/netrunner/network/node/status"
 	"github.com/luxdefi/netrunner/utils"
+	"github.com/luxdefi/netrunner/utils/constants"
 	"github.com/luxdefi/node/api/health"
 	"github.com/luxdefi/node/config"
 	"github.com/luxdefi/node/ids"
 	"github.com/luxdefi/node/message"
 	"github.com/luxdefi/node/snow/networking/router"
+	nodeconstants "github.com/luxdefi/node/utils/constants"
+	"github.com/luxdefi/node/utils/hashing"
 	"github.com/luxdefi/node/utils/logging"
 	"github.com/luxdefi/node/utils/rpc"
+	"github.com/luxdefi/node/vms/platformvm"
 	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/require"
+	"github.com/syndtr/goleveldb/leveldb"
 )
 
 const (
@@ -57,6 +69,27 @@ func (*localTestSuccessfulNodeProcessCreator) GetNodeVersion(_ node.Config) (str
 	return nodeVersion, nil
 }
 
+// records the binary paths of the node processes it creates
+type localTestUpgradeNodeProcessCreator struct {
+	lock        sync.Mutex
+	binaryPaths []string
+}
+
+func (pc *localTestUpgradeNodeProcessCreator) NewNodeProcess(config node.Config, _ ...string) (NodeProcess, error) {
+	pc.lock.Lock()
+	pc.binaryPaths = append(pc.binaryPaths, config.BinaryPath)
+	pc.lock.Unlock()
+	process := &mocks.NodeProcess{}
+	process.On("Stop", mock.Anything).Return(0)
+	process.On("Status").Return(status.Running)
+	process.On("PID").Return(1)
+	return process, nil
+}
+
+func (*localTestUpgradeNodeProcessCreator) GetNodeVersion(_ node.Config) (string, error) {
+	return nodeVersion, nil
+}
+
 type localTestFailedStartProcessCreator struct{}
 
 func (*localTestFailedStartProcessCreator) NewNodeProcess(node.Config, ...string) (NodeProcess, error) {
@@ -110,13 +143,17 @@ func newMockAPISuccessful(string, uint16) api.Client {
 	return client
 }
 
-// Returns an API client where the Health API's Health method always returns unhealthy
+// Returns an API client where the Health API's Health method always returns unhealthy,
+// and the CChainEthAPI's Close method may be called
 func newMockAPIUnhealthy(string, uint16) api.Client {
 	healthReply := &health.APIReply{Healthy: false}
 	healthClient := &healthmocks.Client{}
 	healthClient.On("Health", mock.Anything, mock.Anything).Return(healthReply, nil)
+	ethClient := &apimocks.EthClient{}
+	ethClient.On("Close").Return()
 	client := &apimocks.Client{}
 	client.On("HealthAPI").Return(healthClient)
+	client.On("CChainEthAPI").Return(ethClient)
 	return client
 }
 
@@ -247,6 +284,74 @@ func TestNewNetworkFailToStartNode(t *testing.T) {
 	require.Error(err)
 }
 
+// starts the node processes after [delay], counting the ones being started at
+// the same time, and fails to start the nodes with names in [failing]
+type localTestParallelStartProcessCreator struct {
+	delay   time.Duration
+	failing []string
+
+	lock        sync.Mutex
+	starting    int
+	maxStarting int
+}
+
+func (pc *localTestParallelStartProcessCreator) NewNodeProcess(config node.Config, flags ...string) (NodeProcess, error) {
+	pc.lock.Lock()
+	pc.starting++
+	if pc.starting > pc.maxStarting {
+		pc.maxStarting = pc.starting
+	}
+	pc.lock.Unlock()
+	time.Sleep(pc.delay)
+	pc.lock.Lock()
+	pc.starting--
+	pc.lock.Unlock()
+	for _, name := range pc.failing {
+		if name == config.Name {
+			return nil, errors.New("error on purpose for test")
+		}
+	}
+	return newMockProcessSuccessful(config, flags...)
+}
+
+func (*localTestParallelStartProcessCreator) GetNodeVersion(_ node.Config) (string, error) {
+	return nodeVersion, nil
+}
+
+// Test that the nodes are started concurrently, up to
+// [maxParallelNodeStarts] at a time, and that the errors
+// of all the nodes failing to start are returned
+func TestNewNetworkParallelStart(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+	numNodes := 2 * maxParallelNodeStarts
+	networkConfig, err := NewDefaultConfigNNodes("pepito", uint32(numNodes))
+	require.NoError(err)
+	for i := range networkConfig.NodeConfigs {
+		networkConfig.NodeConfigs[i].Name = fmt.Sprintf("node%d", i)
+		delete(networkConfig.NodeConfigs[i].Flags, config.HTTPPortKey)
+		delete(networkConfig.NodeConfigs[i].Flags, config.StakingPortKey)
+	}
+
+	creator := &localTestParallelStartProcessCreator{delay: 100 * time.Millisecond}
+	net, err := newNetwork(logging.NoLog{}, newMockAPISuccessful, creator, "", "", false)
+	require.NoError(err)
+	require.NoError(net.loadConfig(context.Background(), networkConfig))
+	names, err := net.GetNodeNames()
+	require.NoError(err)
+	require.Len(names, numNodes)
+	require.Greater(creator.maxStarting, 1)
+	require.LessOrEqual(creator.maxStarting, maxParallelNodeStarts)
+
+	creator = &localTestParallelStartProcessCreator{failing: []string{"node1", "node3"}}
+	net, err = newNetwork(logging.NoLog{}, newMockAPISuccessful, creator, "", "", false)
+	require.NoError(err)
+	err = net.loadConfig(context.Background(), networkConfig)
+	require.ErrorContains(err, "error adding node node1")
+	require.ErrorContains(err, "error adding node node3")
+	require.Empty(net.nodes)
+}
+
 // Check configs that are expected to be invalid at network creation time
 func TestWrongNetworkConfigs(t *testing.T) {
 	t.Parallel()
@@ -498,6 +603,55 @@ func TestUnhealthyNetwork(t *testing.T) {
 	require.Error(awaitNetworkHealthy(net, defaultHealthyTimeout))
 }
 
+// Assert that HealthyWithProgress reports the progress of each node
+// once while it doesn't change, and that its error has the last one
+func TestHealthyWithProgress(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+	networkConfig := testNetworkConfig(t)
+	net, err := newNetwork(logging.NoLog{}, newMockAPIUnhealthy, &localTestSuccessfulNodeProcessCreator{}, "", "", false)
+	require.NoError(err)
+	err = net.loadConfig(context.Background(), networkConfig)
+	require.NoError(err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), healthCheckFreq+time.Second)
+	defer cancel()
+	reported := []network.NodeHealthProgress{}
+	err = net.HealthyWithProgress(ctx, func(progress network.NodeHealthProgress) {
+		reported = append(reported, progress)
+	})
+	require.ErrorContains(err, "bootstrapping [P X C]")
+	require.Len(reported, len(networkConfig.NodeConfigs))
+	nodeNames := map[string]bool{}
+	for _, progress := range reported {
+		require.False(progress.Healthy)
+		require.Equal([]string{"P", "X", "C"}, progress.Bootstrapping)
+		nodeNames[progress.Node] = true
+	}
+	require.Len(nodeNames, len(networkConfig.NodeConfigs))
+}
+
+func TestNewHealthProgress(t *testing.T) {
+	require := require.New(t)
+
+	checkErr := "not bootstrapped"
+	progress := newHealthProgress("node1", &health.APIReply{
+		Checks: map[string]health.Result{
+			"P":                {},
+			"X":                {Error: &checkErr},
+			networkHealthCheck: {Details: map[string]interface{}{connectedPeersKey: float64(4)}},
+		},
+	}, nil)
+	require.Equal([]string{"P"}, progress.Bootstrapped)
+	require.Equal([]string{"X", "C"}, progress.Bootstrapping)
+	require.Equal(4, progress.ConnectedPeers)
+	require.Equal("bootstrapped [P], bootstrapping [X C], 4 peers", progress.String())
+
+	progress = newHealthProgress("node1", nil, errors.New("connection refused"))
+	require.Equal(-1, progress.ConnectedPeers)
+	require.Equal("health check failed: connection refused", progress.String())
+}
+
 // Create a network without giving names to nodes.
 // Checks that the generated names are the correct number and unique.
 func TestGeneratedNodesNames(t *testing.T) {
@@ -519,6 +673,34 @@ func TestGeneratedNodesNames(t *testing.T) {
 	require.EqualValues(len(nodeNameMap), len(networkConfig.NodeConfigs))
 }
 
+// Create a network mixing named and unnamed nodes, and one with
+// a repeated name. Checks that the names are reserved across the batch.
+func TestBatchNodesNames(t *testing.T) {
+	require := require.New(t)
+	networkConfig := testNetworkConfig(t)
+	networkConfig.NodeConfigs[0].Name = ""
+	networkConfig.NodeConfigs[1].Name = "node2"
+	networkConfig.NodeConfigs[2].Name = ""
+	net, err := newNetwork(logging.NoLog{}, newMockAPISuccessful, &localTestSuccessfulNodeProcessCreator{}, "", "", false)
+	require.NoError(err)
+	require.NoError(net.loadConfig(context.Background(), networkConfig))
+	nodeNames, err := net.GetNodeNames()
+	require.NoError(err)
+	require.ElementsMatch([]string{"node1", "node2", "node3"}, nodeNames)
+	for _, nodeName := range nodeNames {
+		node, err := net.GetNode(nodeName)
+		require.NoError(err)
+		require.Equal(nodeName, filepath.Base(node.GetDataDir()))
+	}
+
+	networkConfig = testNetworkConfig(t)
+	networkConfig.NodeConfigs[1].Name = networkConfig.NodeConfigs[0].Name
+	net, err = newNetwork(logging.NoLog{}, newMockAPISuccessful, &localTestSuccessfulNodeProcessCreator{}, "", "", false)
+	require.NoError(err)
+	err = net.loadConfig(context.Background(), networkConfig)
+	require.ErrorContains(err, fmt.Sprintf("repeated node name %q", networkConfig.NodeConfigs[0].Name))
+}
+
 // TestGenerateDefaultNetwork create a default network with config from NewDefaultConfig and
 // check expected number of nodes, node names, and node node ids
 func TestGenerateDefaultNetwork(t *testing.T) {
@@ -664,6 +846,178 @@ func TestNodeNotFound(t *testing.T) {
 	require.Error(err)
 }
 
+func TestNodeGroups(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+	networkConfig := testNetworkConfig(t)
+	net, err := newNetwork(logging.NoLog{}, newMockAPISuccessful, &localTestUpgradeNodeProcessCreator{}, "", "", false)
+	require.NoError(err)
+	err = net.loadConfig(context.Background(), networkConfig)
+	require.NoError(err)
+
+	beacons := []string{}
+	for _, nodeConfig := range networkConfig.NodeConfigs {
+		if nodeConfig.IsBeacon {
+			beacons = append(beacons, nodeConfig.Name)
+		}
+	}
+	groups, err := net.GetNodeGroups()
+	require.NoError(err)
+	require.Equal(map[string][]string{network.BeaconsNodeGroup: beacons}, groups)
+
+	require.ErrorIs(net.SetNodeGroup(network.BeaconsNodeGroup, nil), network.ErrInvalidNodeGroup)
+	require.ErrorIs(net.SetNodeGroup("a/b", nil), network.ErrInvalidNodeGroup)
+	require.ErrorIs(net.SetNodeGroup("group", []string{"node0", "unknown"}), network.ErrNodeNotFound)
+	require.NoError(net.SetNodeGroup("group", []string{"node2", "node0", "node2"}))
+	members, err := net.GetNodeGroup("group")
+	require.NoError(err)
+	require.Equal([]string{"node0", "node2"}, members)
+
+	// removed nodes leave their groups, restarted ones don't
+	require.NoError(net.RestartNode(context.Background(), "node0", "", "", "", nil, nil, nil))
+	require.NoError(net.RemoveNode(context.Background(), "node2"))
+	members, err = net.GetNodeGroup("group")
+	require.NoError(err)
+	require.Equal([]string{"node0"}, members)
+
+	require.NoError(net.RemoveNodeGroup("group"))
+	_, err = net.GetNodeGroup("group")
+	require.ErrorIs(err, network.ErrNodeGroupNotFound)
+	require.ErrorIs(net.RemoveNodeGroup("group"), network.ErrNodeGroupNotFound)
+}
+
+func TestTrackSubnet(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+	networkConfig := testNetworkConfig(t)
+	net, err := newNetwork(logging.NoLog{}, newMockAPISuccessful, &localTestUpgradeNodeProcessCreator{}, "", "", false)
+	require.NoError(err)
+	err = net.loadConfig(context.Background(), networkConfig)
+	require.NoError(err)
+
+	subnetA, subnetB := ids.GenerateTestID(), ids.GenerateTestID()
+	require.ErrorIs(net.TrackSubnet(context.Background(), []string{"node0", "unknown"}, subnetA), network.ErrNodeNotFound)
+	require.NoError(net.TrackSubnet(context.Background(), []string{"node0", "node1"}, subnetA))
+	require.NoError(net.TrackSubnet(context.Background(), []string{"node0"}, subnetB))
+	// already tracked
+	require.NoError(net.TrackSubnet(context.Background(), []string{"node0"}, subnetA))
+
+	node0, err := net.GetNode("node0")
+	require.NoError(err)
+	tracked, err := node0.GetTrackedSubnets()
+	require.NoError(err)
+	require.ElementsMatch([]ids.ID{subnetA, subnetB}, tracked)
+	flag, err := node0.GetFlag(config.TrackSubnetsKey)
+	require.NoError(err)
+	require.Equal(joinSubnetIDs([]ids.ID{subnetA, subnetB}), flag.String())
+
+	node1, err := net.GetNode("node1")
+	require.NoError(err)
+	tracked, err = node1.GetTrackedSubnets()
+	require.NoError(err)
+	require.Equal([]ids.ID{subnetA}, tracked)
+
+	node2, err := net.GetNode("node2")
+	require.NoError(err)
+	tracked, err = node2.GetTrackedSubnets()
+	require.NoError(err)
+	require.Empty(tracked)
+}
+
+func TestSubscribeEvents(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+	networkConfig := testNetworkConfig(t)
+	net, err := newNetwork(logging.NoLog{}, newMockAPISuccessful, &localTestSuccessfulNodeProcessCreator{}, "", "", false)
+	require.NoError(err)
+	err = net.loadConfig(context.Background(), networkConfig)
+	require.NoError(err)
+
+	all, err := net.SubscribeEvents(context.Background())
+	require.NoError(err)
+	stopped, err := net.SubscribeEvents(context.Background(), network.EventNodeStopped)
+	require.NoError(err)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancelled, err := net.SubscribeEvents(ctx)
+	require.NoError(err)
+	cancel()
+	_, ok := <-cancelled
+	require.False(ok)
+
+	require.NoError(net.RemoveNode(context.Background(), "node0"))
+	require.NoError(net.RestartNode(context.Background(), "node1", "", "", "", nil, nil, nil))
+	require.NoError(net.Healthy(context.Background()))
+	require.NoError(net.Stop(context.Background()))
+	_, err = net.SubscribeEvents(context.Background())
+	require.ErrorIs(err, network.ErrStopped)
+
+	events := []string{}
+	for event := range all {
+		require.False(event.Time.IsZero())
+		if event.NodeName == "node0" || event.NodeName == "node1" || event.NodeName == "" {
+			events = append(events, event.String())
+		}
+	}
+	require.Equal([]string{
+		"node-stopped node0",
+		"node-stopped node1",
+		"node-started node1",
+		"node-restarted node1",
+		"network-healthy",
+		// on stop
+		"node-stopped node1",
+	}, events)
+	numStopped := 0
+	for event := range stopped {
+		require.Equal(network.EventNodeStopped, event.Kind)
+		numStopped++
+	}
+	// node0 removed, node1 restarted, then node1 and node2 on stop
+	require.Equal(4, numStopped)
+}
+
+func TestStartMonitoring(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+	networkConfig := testNetworkConfig(t)
+	net, err := newNetwork(logging.NoLog{}, newMockAPISuccessful, &localTestSuccessfulNodeProcessCreator{}, "", "", false)
+	require.NoError(err)
+	err = net.loadConfig(context.Background(), networkConfig)
+	require.NoError(err)
+
+	_, err = net.StartMonitoring(context.Background(), network.MonitoringOptions{Launch: "unknown"})
+	require.Error(err)
+	monitoring, err := net.StartMonitoring(context.Background(), network.MonitoringOptions{})
+	require.NoError(err)
+	require.Empty(monitoring.PrometheusURL)
+	_, err = net.StartMonitoring(context.Background(), network.MonitoringOptions{})
+	require.ErrorIs(err, ErrMonitoringRunning)
+
+	prometheusConfig, err := os.ReadFile(monitoring.PrometheusConfig)
+	require.NoError(err)
+	require.Contains(string(prometheusConfig), filepath.Join(monitoring.Dir, prometheusTargetsFile))
+	for _, dashboard := range []string{"consensus.json", "networking.json", "c-chain.json"} {
+		_, err := os.Stat(filepath.Join(monitoring.Dir, "grafana", "dashboards", dashboard))
+		require.NoError(err)
+	}
+	readTargets := func() []prometheusTargetGroup {
+		b, err := os.ReadFile(filepath.Join(monitoring.Dir, prometheusTargetsFile))
+		require.NoError(err)
+		groups := []prometheusTargetGroup{}
+		require.NoError(json.Unmarshal(b, &groups))
+		return groups
+	}
+	groups := readTargets()
+	require.Len(groups, len(networkConfig.NodeConfigs))
+	require.Equal("node0", groups[0].Labels["node"])
+
+	// updated as nodes are removed
+	require.NoError(net.RemoveNode(context.Background(), "node0"))
+	groups = readTargets()
+	require.Len(groups, len(networkConfig.NodeConfigs)-1)
+	require.Equal("node1", groups[0].Labels["node"])
+}
+
 // TestStoppedNetwork checks that operations fail for an already stopped network
 func TestStoppedNetwork(t *testing.T) {
 	t.Parallel()
@@ -695,81 +1049,697 @@ func TestStoppedNetwork(t *testing.T) {
 	require.EqualValues(network.ErrStopped, err)
 	// RemoveNode failure
 	require.EqualValues(network.ErrStopped, net.RemoveNode(context.Background(), networkConfig.NodeConfigs[0].Name))
+	// AddPrimaryValidator failure
+	require.EqualValues(network.ErrStopped, net.AddPrimaryValidator(context.Background(), networkConfig.NodeConfigs[0].Name, node.PrimaryValidatorConfig{}))
 	// Healthy failure
 	require.EqualValues(awaitNetworkHealthy(net, defaultHealthyTimeout), network.ErrStopped)
 	_, err = net.GetAllNodes()
 	require.EqualValues(err, network.ErrStopped)
 }
 
-func TestGetAllNodes(t *testing.T) {
+// TestAddNodePrimaryValidator checks that AddNode doesn't hold the network
+// lock while registering the node as a primary network validator, and
+// returns the node along with the error when the registration fails
+func TestAddNodePrimaryValidator(t *testing.T) {
+	t.Parallel()
 	require := require.New(t)
-	networkConfig := testNetworkConfig(t)
-	net, err := newNetwork(logging.NoLog{}, newMockAPISuccessful, &localTestSuccessfulNodeProcessCreator{}, "", "", false)
+	emptyNetworkConfig, err := emptyNetworkConfig()
 	require.NoError(err)
-	err = net.loadConfig(context.Background(), networkConfig)
+	networkConfig := testNetworkConfig(t)
+	// the nodes never become healthy, so the registration waits
+	net, err := newNetwork(logging.NoLog{}, newMockAPIUnhealthy, &localTestSuccessfulNodeProcessCreator{}, "", "", false)
 	require.NoError(err)
+	require.NoError(net.loadConfig(context.Background(), emptyNetworkConfig))
 
-	nodes, err := net.GetAllNodes()
+	validatorConfig := networkConfig.NodeConfigs[0]
+	validatorConfig.PrimaryValidator = &node.PrimaryValidatorConfig{Duration: -time.Second}
+	_, err = net.AddNode(validatorConfig)
+	require.ErrorContains(err, "negative primary validator duration")
+
+	validatorConfig.PrimaryValidator = &node.PrimaryValidatorConfig{}
+	type addNodeResult struct {
+		node node.Node
+		err  error
+	}
+	resultCh := make(chan addNodeResult, 1)
+	go func() {
+		node, err := net.AddNode(validatorConfig)
+		resultCh <- addNodeResult{node: node, err: err}
+	}()
+	require.Eventually(func() bool {
+		names, err := net.GetNodeNames()
+		return err == nil && len(names) == 1
+	}, defaultHealthyTimeout, 10*time.Millisecond)
+
+	// operations needing the lock go on while the registration waits
+	_, err = net.AddNode(networkConfig.NodeConfigs[1])
 	require.NoError(err)
-	require.Len(nodes, len(net.nodes))
-	for name, node := range net.nodes {
-		require.EqualValues(node, nodes[name])
+	require.NoError(net.RemoveNode(context.Background(), networkConfig.NodeConfigs[1].Name))
+	select {
+	case <-resultCh:
+		require.FailNow("AddNode returned before the node was healthy")
+	default:
 	}
+
+	require.NoError(net.Stop(context.Background()))
+	result := <-resultCh
+	require.Error(result.err)
+	require.NotNil(result.node)
+	require.Equal(validatorConfig.Name, result.node.GetName())
 }
 
-// TestFlags tests that we can pass flags through the network.Config
-// but also via node.Config and that the latter overrides the former
-// if same keys exist.
-func TestFlags(t *testing.T) {
+// TestAddPrimaryValidatorErrors checks the errors of AddPrimaryValidator
+// returned before any tx is issued
+func TestAddPrimaryValidatorErrors(t *testing.T) {
 	t.Parallel()
 	require := require.New(t)
 	networkConfig := testNetworkConfig(t)
+	net, err := newNetwork(logging.NoLog{}, newMockAPIUnhealthy, &localTestSuccessfulNodeProcessCreator{}, "", "", false)
+	require.NoError(err)
+	require.NoError(net.loadConfig(context.Background(), networkConfig))
+	nodeName := networkConfig.NodeConfigs[0].Name
+
+	canceledCtx, cancel := context.WithCancel(context.Background())
+	cancel()
+	tests := []struct {
+		name        string
+		ctx         context.Context
+		nodeName    string
+		cfg         node.PrimaryValidatorConfig
+		expectedErr error
+	}{
+		{
+			name:        "unknown node",
+			ctx:         context.Background(),
+			nodeName:    "node99",
+			expectedErr: network.ErrNodeNotFound,
+		},
+		{
+			name:     "invalid config",
+			ctx:      context.Background(),
+			nodeName: nodeName,
+			cfg:      node.PrimaryValidatorConfig{DelegationFee: 1_000_001},
+		},
+		{
+			name:     "canceled",
+			ctx:      canceledCtx,
+			nodeName: nodeName,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require := require.New(t)
+			err := net.AddPrimaryValidator(tt.ctx, tt.nodeName, tt.cfg)
+			require.Error(err)
+			if tt.expectedErr != nil {
+				require.ErrorIs(err, tt.expectedErr)
+			}
+		})
+	}
 
-	// submit both network.Config flags and node.Config
-	networkConfig.Flags = map[string]interface{}{
-		"test-network-config-flag": "something",
-		"common-config-flag":       "should not be added",
+	// the node isn't healthy, so a registration given a timeout ends with it
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	require.Error(net.AddPrimaryValidator(ctx, nodeName, node.PrimaryValidatorConfig{}))
+	// and the network stays usable
+	_, err = net.GetNode(nodeName)
+	require.NoError(err)
+}
+
+// creates the first [n] node processes, and fails to create the next ones
+type localTestFailAfterNodeProcessCreator struct {
+	lock sync.Mutex
+	n    int
+}
+
+func (pc *localTestFailAfterNodeProcessCreator) NewNodeProcess(config node.Config, flags ...string) (NodeProcess, error) {
+	pc.lock.Lock()
+	defer pc.lock.Unlock()
+
+	if pc.n == 0 {
+		return nil, errors.New("error on purpose for test")
 	}
-	for i := range networkConfig.NodeConfigs {
-		v := &networkConfig.NodeConfigs[i]
-		v.Flags = map[string]interface{}{
-			"test-node-config-flag":  "node",
-			"test2-node-config-flag": "config",
-			"common-config-flag":     "this should be added",
-		}
+	pc.n--
+	return newMockProcessSuccessful(config, flags...)
+}
+
+func (*localTestFailAfterNodeProcessCreator) GetNodeVersion(node.Config) (string, error) {
+	return nodeVersion, nil
+}
+
+// Returns [networkConfig] loaded into a new network with the node
+// processes given by [nodeProcessCreator], and the db dirs a snapshot copies
+func newSnapshottableNetwork(
+	t *testing.T,
+	networkConfig network.Config,
+	nodeProcessCreator NodeProcessCreator,
+) *localNetwork {
+	require := require.New(t)
+	net, err := newNetwork(logging.NoLog{}, newMockAPISuccessful, nodeProcessCreator, t.TempDir(), t.TempDir(), false)
+	require.NoError(err)
+	require.NoError(net.loadConfig(context.Background(), networkConfig))
+	for _, node := range net.nodes {
+		require.NoError(os.MkdirAll(filepath.Join(node.GetDbDir(), nodeconstants.NetworkName(net.networkID)), os.ModePerm))
 	}
-	expectedFlags := map[string]interface{}{
-		"test-network-config-flag": "something",
-		"common-config-flag":       "this should be added",
-		"test-node-config-flag":    "node",
-		"test2-node-config-flag":   "config",
+	return net
+}
+
+// validatorsPChain lists the node IDs it's asked for as primary network
+// validators. The methods not overridden panic.
+type validatorsPChain struct {
+	platformvm.Client
+	lock    sync.Mutex
+	queried []ids.NodeID
+}
+
+func (c *validatorsPChain) GetCurrentValidators(_ context.Context, _ ids.ID, nodeIDs []ids.NodeID, _ ...rpc.Option) ([]platformvm.ClientPermissionlessValidator, error) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+
+	c.queried = append(c.queried, nodeIDs...)
+	vdrs := make([]platformvm.ClientPermissionlessValidator, 0, len(nodeIDs))
+	for _, nodeID := range nodeIDs {
+		vdrs = append(vdrs, platformvm.ClientPermissionlessValidator{
+			ClientStaker: platformvm.ClientStaker{NodeID: nodeID},
+		})
 	}
+	return vdrs, nil
+}
 
-	nw, err := newNetwork(
-		logging.NoLog{},
-		newMockAPISuccessful,
-		&localTestFlagCheckProcessCreator{
-			// after creating the network, one flag should have been overridden by the node configs
-			expectedFlags: expectedFlags,
-			require:       require,
-		},
-		"",
-		"",
-		false,
-	)
+// TestCloneNetwork checks that the clone gets the root and snapshots dirs
+// of its name, and new node IDs registered as validators, while the
+// original is restarted
+func TestCloneNetwork(t *testing.T) {
+	require := require.New(t)
+	tmpDir := t.TempDir()
+	t.Setenv("TMPDIR", tmpDir)
+	net := newSnapshottableNetwork(t, testNetworkConfig(t), &localTestSuccessfulNodeProcessCreator{})
+	originalNodes, err := net.GetAllNodes()
+	require.NoError(err)
+	pChain := &validatorsPChain{}
+	net.newAPIClientF = func(ip string, port uint16) api.Client {
+		client := newMockAPISuccessful(ip, port).(*apimocks.Client)
+		client.On("PChainAPI").Return(pChain)
+		return client
+	}
+
+	nw, err := net.CloneNetwork(context.Background(), "clone")
 	require.NoError(err)
-	err = nw.loadConfig(context.Background(), networkConfig)
+	clone := nw.(*localNetwork)
+	defer func() {
+		require.NoError(clone.Stop(context.Background()))
+	}()
+
+	require.Equal(filepath.Join(tmpDir, constants.RootDirPrefix, networkRootDirPrefix+"-clone"), clone.rootDir)
+	require.Equal(filepath.Join(net.snapshotsDir, "clone"), clone.snapshotsDir)
+	require.DirExists(clone.snapshotsDir)
+	cloneNodes, err := clone.GetAllNodes()
+	require.NoError(err)
+	require.Len(cloneNodes, len(originalNodes))
+	cloneNodeIDs := []ids.NodeID{}
+	for name, originalNode := range originalNodes {
+		cloneNode, ok := cloneNodes[name]
+		require.True(ok)
+		require.NotEqual(originalNode.GetNodeID(), cloneNode.GetNodeID())
+		require.NotEqual(originalNode.GetDbDir(), cloneNode.GetDbDir())
+		require.Equal(false, cloneNode.GetConfig().Flags[sybilProtectionEnabledKey])
+		cloneNodeIDs = append(cloneNodeIDs, cloneNode.GetNodeID())
+	}
+	require.ElementsMatch(cloneNodeIDs, pChain.queried)
+
+	// the original is running again, with the same nodes
+	restartedNodes, err := net.GetAllNodes()
 	require.NoError(err)
-	err = nw.Stop(context.Background())
+	require.Len(restartedNodes, len(originalNodes))
+	for name, originalNode := range originalNodes {
+		require.Equal(originalNode.GetDbDir(), restartedNodes[name].GetDbDir())
+	}
+	// and the temporary snapshot is removed
+	snapshotNames, err := net.GetSnapshotNames()
 	require.NoError(err)
+	require.Empty(snapshotNames)
+}
 
-	// submit only node.Config flags
-	networkConfig.Flags = nil
-	flags := map[string]interface{}{
-		"test-node-config-flag":  "node",
-		"test2-node-config-flag": "config",
-		"common-config-flag":     "this should be added",
-	}
+// TestCloneNetworkRestartFailure checks that when the original network
+// can't be restarted, the nodes restarted are stopped and the snapshot kept
+func TestCloneNetworkRestartFailure(t *testing.T) {
+	require := require.New(t)
+	t.Setenv("TMPDIR", t.TempDir())
+	networkConfig := testNetworkConfig(t)
+	// the first node restarts and the second doesn't
+	net := newSnapshottableNetwork(t, networkConfig, &localTestFailAfterNodeProcessCreator{n: len(networkConfig.NodeConfigs) + 1})
+
+	_, cloneErr := net.CloneNetwork(context.Background(), "clone")
+	require.ErrorContains(cloneErr, "couldn't restart node")
+
+	names, err := net.GetNodeNames()
+	require.NoError(err)
+	require.Empty(names)
+	snapshotNames, err := net.GetSnapshotNames()
+	require.NoError(err)
+	require.Len(snapshotNames, 1)
+	require.True(strings.HasPrefix(snapshotNames[0], cloneSnapshotPrefix))
+	require.ErrorContains(cloneErr, snapshotNames[0])
+}
+
+// terminableMockProcess records the deadline of the
+// context it's terminated, or stopped, with
+type terminableMockProcess struct {
+	*mocks.NodeProcess
+	deadline time.Time
+}
+
+func (p *terminableMockProcess) Terminate(ctx context.Context) int {
+	p.deadline, _ = ctx.Deadline()
+	return 0
+}
+
+// TestTerminateNode checks the time a drained node is given
+// to exit once sent a SIGTERM, before being killed
+func TestTerminateNode(t *testing.T) {
+	tests := []struct {
+		name        string
+		ctxTimeout  time.Duration
+		gracePeriod time.Duration
+		expected    time.Duration
+	}{
+		{
+			name:     "default grace period",
+			expected: defaultDrainGracePeriod,
+		},
+		{
+			name:        "grace period",
+			gracePeriod: 2 * time.Second,
+			expected:    2 * time.Second,
+		},
+		{
+			name:       "context deadline before the default grace period",
+			ctxTimeout: time.Second,
+			expected:   time.Second,
+		},
+		{
+			name:        "context deadline after the grace period",
+			ctxTimeout:  time.Minute,
+			gracePeriod: 2 * time.Second,
+			expected:    2 * time.Second,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require := require.New(t)
+			ctx := context.Background()
+			if tt.ctxTimeout != 0 {
+				var cancel context.CancelFunc
+				ctx, cancel = context.WithTimeout(ctx, tt.ctxTimeout)
+				defer cancel()
+			}
+			process := &terminableMockProcess{NodeProcess: &mocks.NodeProcess{}}
+			start := time.Now()
+			terminateNode(ctx, &localNode{name: "node1", process: process}, tt.gracePeriod)
+			require.False(process.deadline.IsZero())
+			require.WithinDuration(start.Add(tt.expected), process.deadline, time.Second)
+		})
+	}
+
+	// a node that can't be sent a SIGTERM is stopped within the grace period
+	require := require.New(t)
+	process := &mocks.NodeProcess{}
+	var deadline time.Time
+	process.On("Stop", mock.Anything).Run(func(args mock.Arguments) {
+		deadline, _ = args.Get(0).(context.Context).Deadline()
+	}).Return(0)
+	start := time.Now()
+	terminateNode(context.Background(), &localNode{name: "node1", process: process}, 0)
+	require.WithinDuration(start.Add(defaultDrainGracePeriod), deadline, time.Second)
+}
+
+// Returns an API client creator whose nodes report [peers] connected
+// peers, less one once [drained] is set
+func newMockAPIConnectedPeers(peers int, drained *atomic.Bool) api.NewAPIClientF {
+	return func(string, uint16) api.Client {
+		healthClient := &healthmocks.Client{}
+		healthClient.On("Health", mock.Anything, mock.Anything).Return(
+			func(context.Context, []string, ...rpc.Option) (*health.APIReply, error) {
+				n := peers
+				if drained.Load() {
+					n--
+				}
+				return &health.APIReply{
+					Healthy: true,
+					Checks: map[string]health.Result{
+						networkHealthCheck: {Details: map[string]interface{}{connectedPeersKey: float64(n)}},
+					},
+				}, nil
+			},
+			nil,
+		)
+		client := &apimocks.Client{}
+		client.On("HealthAPI").Return(healthClient)
+		return client
+	}
+}
+
+// TestAwaitPeerUpdate checks that the removal of a drained node waits
+// for the other nodes to drop it from their peers, up to the timeout
+func TestAwaitPeerUpdate(t *testing.T) {
+	tests := []struct {
+		name    string
+		drained bool
+		timeout time.Duration
+	}{
+		{
+			name:    "peers dropped",
+			drained: true,
+			timeout: defaultHealthyTimeout,
+		},
+		{
+			name:    "peers kept",
+			timeout: 500 * time.Millisecond,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require := require.New(t)
+			drained := &atomic.Bool{}
+			networkConfig := testNetworkConfig(t)
+			net, err := newNetwork(logging.NoLog{}, newMockAPIConnectedPeers(len(networkConfig.NodeConfigs)-1, drained), &localTestSuccessfulNodeProcessCreator{}, "", "", false)
+			require.NoError(err)
+			require.NoError(net.loadConfig(context.Background(), networkConfig))
+
+			peers := net.connectedPeers(context.Background(), "node0")
+			require.Equal(map[string]int{"node1": 2, "node2": 2}, peers)
+			drained.Store(tt.drained)
+			start := time.Now()
+			net.awaitPeerUpdate(context.Background(), "node0", peers, tt.timeout)
+			elapsed := time.Since(start)
+			if tt.drained {
+				require.Less(elapsed, tt.timeout)
+			} else {
+				require.GreaterOrEqual(elapsed, tt.timeout)
+			}
+		})
+	}
+}
+
+// TestNamedNetworks checks that two networks with the same name can't run
+// concurrently, and that the name is released on stop
+func TestNamedNetworks(t *testing.T) {
+	require := require.New(t)
+	networkConfig, err := emptyNetworkConfig()
+	require.NoError(err)
+	networkConfig.Name = "named-net"
+	net1, err := newNetwork(logging.NoLog{}, newMockAPISuccessful, &localTestSuccessfulNodeProcessCreator{}, t.TempDir(), "", false)
+	require.NoError(err)
+	require.NoError(net1.loadConfig(context.Background(), networkConfig))
+	net2, err := newNetwork(logging.NoLog{}, newMockAPISuccessful, &localTestSuccessfulNodeProcessCreator{}, t.TempDir(), "", false)
+	require.NoError(err)
+	require.ErrorIs(net2.loadConfig(context.Background(), networkConfig), ErrNetworkNameInUse)
+	require.NoError(net1.Stop(context.Background()))
+	net3, err := newNetwork(logging.NoLog{}, newMockAPISuccessful, &localTestSuccessfulNodeProcessCreator{}, t.TempDir(), "", false)
+	require.NoError(err)
+	require.NoError(net3.loadConfig(context.Background(), networkConfig))
+	require.NoError(net3.Stop(context.Background()))
+	// invalid name
+	networkConfig.Name = "../escape"
+	net4, err := newNetwork(logging.NoLog{}, newMockAPISuccessful, &localTestSuccessfulNodeProcessCreator{}, t.TempDir(), "", false)
+	require.NoError(err)
+	require.Error(net4.loadConfig(context.Background(), networkConfig))
+}
+
+func TestPortRegistry(t *testing.T) {
+	require := require.New(t)
+	r := portRegistry{owners: map[uint16]string{}}
+	require.NoError(r.reserve(10013, "net1"))
+	require.NoError(r.reserve(10013, "net1"))
+	require.Error(r.reserve(10013, "net2"))
+	require.True(r.isReserved(10013))
+	// only the owner releases the port
+	r.release("net2", 10013)
+	require.True(r.isReserved(10013))
+	r.release("net1", 10013)
+	require.False(r.isReserved(10013))
+	require.NoError(r.reserve(10013, "net2"))
+}
+
+func TestAddAPINode(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+	networkConfig := testNetworkConfig(t)
+	net, err := newNetwork(logging.NoLog{}, newMockAPISuccessful, &localTestSuccessfulNodeProcessCreator{}, "", "", false)
+	require.NoError(err)
+	require.NoError(net.loadConfig(context.Background(), networkConfig))
+
+	apiNode, err := net.AddNode(node.Config{
+		Name:             "api1",
+		Role:             node.RoleAPI,
+		ChainConfigFiles: map[string]string{"C": `{"log-level":"info"}`},
+	})
+	require.NoError(err)
+	require.Equal(node.RoleAPI, apiNode.GetConfig().Role)
+	require.JSONEq(`{"log-level":"info","state-sync-enabled":true}`, apiNode.GetConfig().ChainConfigFiles["C"])
+
+	// user given chain config keys take precedence
+	apiNode, err = net.AddNode(node.Config{
+		Name:             "api2",
+		Role:             node.RoleAPI,
+		ChainConfigFiles: map[string]string{"C": `{"state-sync-enabled":false}`},
+	})
+	require.NoError(err)
+	require.JSONEq(`{"state-sync-enabled":false}`, apiNode.GetConfig().ChainConfigFiles["C"])
+
+	archivalNode, err := net.AddNode(node.Config{Name: "archival1", Role: node.RoleArchival})
+	require.NoError(err)
+	require.Equal(true, archivalNode.GetConfig().Flags["index-enabled"])
+	require.JSONEq(`{"pruning-enabled":false,"state-sync-enabled":false}`, archivalNode.GetConfig().ChainConfigFiles["C"])
+
+	_, err = net.AddNode(node.Config{Name: "api3", Role: node.RoleAPI, IsBeacon: true})
+	require.Error(err)
+	_, err = net.AddNode(node.Config{Name: "other", Role: "other"})
+	require.ErrorIs(err, node.ErrUnknownRole)
+}
+
+func TestNodeFeatures(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+	networkConfig := testNetworkConfig(t)
+	net, err := newNetwork(logging.NoLog{}, newMockAPISuccessful, &localTestSuccessfulNodeProcessCreator{}, "", "", false)
+	require.NoError(err)
+	require.NoError(net.loadConfig(context.Background(), networkConfig))
+
+	featuresNode, err := net.AddNode(node.Config{
+		Name: "features",
+		Features: map[string]bool{
+			node.FeatureIndexing:  true,
+			node.FeatureAdminAPI:  false,
+			node.FeatureIPCsAPI:   true,
+			node.FeatureStateSync: true,
+		},
+		ChainConfigFiles: map[string]string{"C": `{"log-level":"info"}`},
+	})
+	require.NoError(err)
+	require.Equal(true, featuresNode.GetConfig().Flags["index-enabled"])
+	require.Equal(false, featuresNode.GetConfig().Flags["api-admin-enabled"])
+	require.Equal(true, featuresNode.GetConfig().Flags["api-ipcs-enabled"])
+	require.JSONEq(`{"log-level":"info","state-sync-enabled":true}`, featuresNode.GetConfig().ChainConfigFiles["C"])
+
+	// a flag agreeing with the feature is fine, one contradicting it isn't
+	_, err = net.AddNode(node.Config{
+		Name:     "agreeing",
+		Features: map[string]bool{node.FeatureIndexing: true},
+		Flags:    map[string]interface{}{"index-enabled": "true"},
+	})
+	require.NoError(err)
+	_, err = net.AddNode(node.Config{
+		Name:     "conflicting",
+		Features: map[string]bool{node.FeatureIndexing: false},
+		Flags:    map[string]interface{}{"index-enabled": true},
+	})
+	require.ErrorIs(err, node.ErrFeatureConflict)
+	_, err = net.AddNode(node.Config{Name: "unknown", Features: map[string]bool{"other": true}})
+	require.ErrorIs(err, node.ErrUnknownFeature)
+
+	// the nodes run v1.9.5
+	c := node.Config{
+		Flags:            map[string]interface{}{},
+		ChainConfigFiles: map[string]string{},
+		Features:         map[string]bool{node.FeatureStateSync: true},
+	}
+	require.ErrorIs(c.ApplyFeatures("v1.7.0"), node.ErrUnsupportedFeature)
+	c.Features = map[string]bool{node.FeatureIPCsAPI: true}
+	require.ErrorIs(c.ApplyFeatures("v1.11.0"), node.ErrUnsupportedFeature)
+	c.Features = map[string]bool{node.FeatureIPCsAPI: false}
+	require.NoError(c.ApplyFeatures("v1.11.0"))
+	require.NotContains(c.Flags, "api-ipcs-enabled")
+}
+
+func TestNodeDBType(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+	networkConfig := testNetworkConfig(t)
+	net, err := newNetwork(logging.NoLog{}, newMockAPISuccessful, &localTestSuccessfulNodeProcessCreator{}, "", "", false)
+	require.NoError(err)
+	require.NoError(net.loadConfig(context.Background(), networkConfig))
+
+	for _, n := range net.nodes {
+		require.Equal(node.DefaultDBType, n.GetConfig().DBType)
+		require.Equal(node.DefaultDBType, n.GetConfig().Flags[config.DBTypeKey])
+	}
+	pebbleNode, err := net.AddNode(node.Config{Name: "pebble", DBType: node.DBTypePebbleDB})
+	require.NoError(err)
+	require.Equal(node.DBTypePebbleDB, pebbleNode.GetConfig().Flags[config.DBTypeKey])
+	// the db type flag is taken when the field is not given
+	memNode, err := net.AddNode(node.Config{
+		Name:  "mem",
+		Flags: map[string]interface{}{config.DBTypeKey: node.DBTypeMemDB},
+	})
+	require.NoError(err)
+	require.Equal(node.DBTypeMemDB, memNode.GetConfig().DBType)
+	_, err = net.AddNode(node.Config{Name: "other", DBType: "other"})
+	require.ErrorIs(err, node.ErrUnknownDBType)
+
+	saved := node.Config{Name: "node1", DBType: node.DBTypePebbleDB}
+	require.NoError(checkSnapshotDBType(saved, map[string]interface{}{}))
+	require.NoError(checkSnapshotDBType(saved, map[string]interface{}{config.DBTypeKey: node.DBTypePebbleDB}))
+	err = checkSnapshotDBType(saved, map[string]interface{}{config.DBTypeKey: node.DBTypeLevelDB})
+	require.ErrorIs(err, ErrIncompatibleDBType)
+	// snapshots saved without a db type hold leveldb dbs
+	err = checkSnapshotDBType(node.Config{Name: "node1"}, map[string]interface{}{config.DBTypeKey: node.DBTypePebbleDB})
+	require.ErrorIs(err, ErrIncompatibleDBType)
+}
+
+func TestVolumeRootDirs(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+	networkConfig := testNetworkConfig(t)
+	networkConfig.DBRootDir = t.TempDir()
+	networkConfig.LogsRootDir = t.TempDir()
+	networkConfig.KeysRootDir = t.TempDir()
+	net, err := newNetwork(logging.NoLog{}, newMockAPISuccessful, &localTestSuccessfulNodeProcessCreator{}, t.TempDir(), "", false)
+	require.NoError(err)
+	require.NoError(net.loadConfig(context.Background(), networkConfig))
+
+	for nodeName, node := range net.nodes {
+		require.Equal(filepath.Join(networkConfig.DBRootDir, nodeName), node.GetDbDir())
+		require.Equal(filepath.Join(networkConfig.LogsRootDir, nodeName), node.GetLogsDir())
+		require.FileExists(filepath.Join(networkConfig.KeysRootDir, nodeName, stakingKeyFileName))
+		require.NoFileExists(filepath.Join(node.GetDataDir(), stakingKeyFileName))
+	}
+}
+
+func TestLoopbackAliases(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+	networkConfig := testNetworkConfig(t)
+	networkConfig.LoopbackAliases = true
+	networkConfig.NodeConfigs[0].BindIP = "127.0.0.100"
+	net, err := newNetwork(logging.NoLog{}, newMockAPISuccessful, &localTestSuccessfulNodeProcessCreator{}, "", "", false)
+	require.NoError(err)
+	require.NoError(net.loadConfig(context.Background(), networkConfig))
+
+	ips := map[string]struct{}{}
+	for _, node := range net.nodes {
+		require.NotEqual(defaultBindIP, node.GetURL())
+		require.Equal(node.GetConfig().BindIP, node.GetURL())
+		ips[node.GetURL()] = struct{}{}
+	}
+	require.Len(ips, len(net.nodes))
+	require.Equal("127.0.0.100", net.nodes[networkConfig.NodeConfigs[0].Name].GetURL())
+
+	_, err = net.AddNode(node.Config{Name: "invalid", BindIP: "127.0.0"})
+	require.Error(err)
+}
+
+func TestIPv6Network(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+	networkConfig := testNetworkConfig(t)
+	networkConfig.IPv6 = true
+	net, err := newNetwork(logging.NoLog{}, newMockAPISuccessful, &localTestSuccessfulNodeProcessCreator{}, "", "", false)
+	require.NoError(err)
+	require.NoError(net.loadConfig(context.Background(), networkConfig))
+
+	nodes := map[string]node.Node{}
+	for name, node := range net.nodes {
+		require.Equal(defaultIPv6BindIP, node.GetURL())
+		require.Equal(defaultIPv6BindIP, node.GetConfig().BindIP)
+		nodes[name] = node
+	}
+	catalog := network.NewEndpointCatalog(nodes, nil)
+	require.NotEmpty(catalog.Endpoints)
+	for _, endpoint := range catalog.Endpoints {
+		require.Contains(endpoint.URL, "://[::1]:")
+	}
+}
+
+func TestGetAllNodes(t *testing.T) {
+	require := require.New(t)
+	networkConfig := testNetworkConfig(t)
+	net, err := newNetwork(logging.NoLog{}, newMockAPISuccessful, &localTestSuccessfulNodeProcessCreator{}, "", "", false)
+	require.NoError(err)
+	err = net.loadConfig(context.Background(), networkConfig)
+	require.NoError(err)
+
+	nodes, err := net.GetAllNodes()
+	require.NoError(err)
+	require.Len(nodes, len(net.nodes))
+	for name, node := range net.nodes {
+		require.EqualValues(node, nodes[name])
+	}
+}
+
+// TestFlags tests that we can pass flags through the network.Config
+// but also via node.Config and that the latter overrides the former
+// if same keys exist.
+func TestFlags(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+	networkConfig := testNetworkConfig(t)
+
+	// submit both network.Config flags and node.Config
+	networkConfig.Flags = map[string]interface{}{
+		"test-network-config-flag": "something",
+		"common-config-flag":       "should not be added",
+	}
+	for i := range networkConfig.NodeConfigs {
+		v := &networkConfig.NodeConfigs[i]
+		v.Flags = map[string]interface{}{
+			"test-node-config-flag":  "node",
+			"test2-node-config-flag": "config",
+			"common-config-flag":     "this should be added",
+		}
+	}
+	expectedFlags := map[string]interface{}{
+		"test-network-config-flag": "something",
+		"common-config-flag":       "this should be added",
+		"test-node-config-flag":    "node",
+		"test2-node-config-flag":   "config",
+	}
+
+	nw, err := newNetwork(
+		logging.NoLog{},
+		newMockAPISuccessful,
+		&localTestFlagCheckProcessCreator{
+			// after creating the network, one flag should have been overridden by the node configs
+			expectedFlags: expectedFlags,
+			require:       require,
+		},
+		"",
+		"",
+		false,
+	)
+	require.NoError(err)
+	err = nw.loadConfig(context.Background(), networkConfig)
+	require.NoError(err)
+	err = nw.Stop(context.Background())
+	require.NoError(err)
+
+	// submit only node.Config flags
+	networkConfig.Flags = nil
+	flags := map[string]interface{}{
+		"test-node-config-flag":  "node",
+		"test2-node-config-flag": "config",
+		"common-config-flag":     "this should be added",
+	}
 	for i := range networkConfig.NodeConfigs {
 		v := &networkConfig.NodeConfigs[i]
 		v.Flags = flags
@@ -901,6 +1871,334 @@ func TestChildCmdRedirection(t *testing.T) {
 	}
 }
 
+func TestNodeCommand(t *testing.T) {
+	require := require.New(t)
+	dataDir, err := filepath.EvalSymlinks(t.TempDir())
+	require.NoError(err)
+	workDir := nodeWorkDir("plugins-cwd", dataDir)
+	require.Equal(filepath.Join(dataDir, "plugins-cwd"), workDir)
+	require.NoError(os.MkdirAll(workDir, 0o750))
+
+	cmd := nodeCommand(node.Config{BinaryPath: "sh", WorkDir: workDir, Umask: "027"}, "", []string{"-c", "pwd -P && umask"})
+	out, err := cmd.Output()
+	require.NoError(err)
+	require.Equal([]string{workDir, "0027"}, strings.Fields(string(out)))
+}
+
+func TestNodeCommandResourceLimits(t *testing.T) {
+	require := require.New(t)
+
+	limits := &node.ResourceLimits{Memory: "1GiB", MaxOpenFiles: 256}
+	require.NoError(limits.Validate())
+	cmd := nodeCommand(node.Config{BinaryPath: "sh", Resources: limits}, "", []string{"-c", "ulimit -n"})
+	out, err := cmd.Output()
+	require.NoError(err)
+	require.Equal([]string{"256"}, strings.Fields(string(out)))
+
+	// the memory is limited by the cgroup, not as an rlimit
+	cgroupDir := t.TempDir()
+	cmd = nodeCommand(node.Config{BinaryPath: "sh", Resources: limits}, cgroupDir, []string{"-c", "echo $$ && ulimit -v"})
+	out, err = cmd.Output()
+	require.NoError(err)
+	fields := strings.Fields(string(out))
+	require.Len(fields, 2)
+	require.NotEqual("1048576", fields[1])
+	procs, err := os.ReadFile(filepath.Join(cgroupDir, "cgroup.procs"))
+	require.NoError(err)
+	require.Equal(fields[0], strings.TrimSpace(string(procs)))
+
+	require.Error((&node.ResourceLimits{Memory: "1.5B"}).Validate())
+	require.Error((&node.ResourceLimits{CPUs: -1}).Validate())
+}
+
+func TestNodeCgroup(t *testing.T) {
+	require := require.New(t)
+
+	// no cgroup is needed for the open files limit
+	dir, err := nodeCgroup(node.Config{Name: "node1", Resources: &node.ResourceLimits{MaxOpenFiles: 256}})
+	require.NoError(err)
+	require.Empty(dir)
+
+	// processes can't be moved under a cgroup without write access to it
+	require.ErrorIs(checkCgroupMigration(t.TempDir()), ErrNoCgroups)
+
+	// CPU and memory limits that can't be applied fail the node
+	if _, err := nodeCgroupsParent(); err == nil {
+		t.Skip("cgroups can be created by this process")
+	}
+	for _, limits := range []*node.ResourceLimits{{CPUs: 1}, {Memory: "1GiB"}} {
+		_, err := nodeCgroup(node.Config{Name: "node1", Resources: limits})
+		require.ErrorContains(err, `couldn't limit the CPU and memory of node "node1"`)
+	}
+}
+
+func TestNodeProcessFreeze(t *testing.T) {
+	require := require.New(t)
+
+	np, err := newNodeProcess("node1", logging.NoLog{}, nodeCommand(node.Config{BinaryPath: "sleep"}, "", []string{"30"}))
+	require.NoError(err)
+	require.NoError(np.Freeze())
+	require.NoError(np.Unfreeze())
+	require.NoError(np.Freeze())
+
+	// a frozen node is unfrozen to handle the SIGINT, so it doesn't need the kill
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	np.Stop(ctx)
+	require.NoError(ctx.Err())
+	require.Error(np.Freeze())
+}
+
+func TestNodeConsole(t *testing.T) {
+	require := require.New(t)
+
+	consoles := newConsoleHub()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	lines := consoles.subscribe(ctx, "node1")
+	otherLines := consoles.subscribe(ctx, "node2")
+	npc := &nodeProcessCreator{
+		log:         logging.NoLog{},
+		colorPicker: utils.NewColorPicker(),
+		consoles:    consoles,
+	}
+	proc, err := npc.NewNodeProcess(node.Config{BinaryPath: "sh", Name: "node1"}, "-c", "echo out && echo err >&2 && exec sleep 30")
+	require.NoError(err)
+
+	got := map[string]string{}
+	for len(got) < 2 {
+		select {
+		case line := <-lines:
+			require.Equal("node1", line.NodeName)
+			got[line.Stream] = line.Line
+		case <-time.After(10 * time.Second):
+			t.Fatal("timed out waiting for console lines")
+		}
+	}
+	require.Equal(map[string]string{network.ConsoleStdout: "out", network.ConsoleStderr: "err"}, got)
+	require.Empty(otherLines)
+
+	require.NoError(proc.(SignalableNodeProcess).Signal(syscall.SIGTERM))
+	<-proc.(*nodeProcess).closedOnStop
+	require.Error(proc.(SignalableNodeProcess).Signal(syscall.SIGTERM))
+
+	consoles.close()
+	_, ok := <-lines
+	require.False(ok)
+}
+
+// TestRemoveNodeClosesConsole checks that the console subscriptions
+// of a removed node are closed, and the ones of the others kept
+func TestRemoveNodeClosesConsole(t *testing.T) {
+	require := require.New(t)
+	networkConfig := testNetworkConfig(t)
+	net, err := newNetwork(logging.NoLog{}, newMockAPISuccessful, &localTestSuccessfulNodeProcessCreator{}, "", "", false)
+	require.NoError(err)
+	net.consoles = newConsoleHub()
+	require.NoError(net.loadConfig(context.Background(), networkConfig))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	lines, err := net.AttachConsole(ctx, "node0")
+	require.NoError(err)
+	otherLines, err := net.AttachConsole(ctx, "node1")
+	require.NoError(err)
+
+	require.NoError(net.RemoveNode(context.Background(), "node0"))
+	select {
+	case _, ok := <-lines:
+		require.False(ok)
+	case <-time.After(5 * time.Second):
+		require.FailNow("console of removed node not closed")
+	}
+	select {
+	case <-otherLines:
+		require.FailNow("console of running node closed")
+	default:
+	}
+	net.consoles.publish(network.ConsoleLine{NodeName: "node1", Line: "still attached"})
+	line := <-otherLines
+	require.Equal("still attached", line.Line)
+}
+
+// Returns a channel receiving the function releasing the snapshots
+// lock of [snapshotsDir] once taken, or nil if it failed
+func lockSnapshotsDirAsync(snapshotsDir string, exclusive bool) <-chan func() {
+	locked := make(chan func(), 1)
+	go func() {
+		unlock, err := lockSnapshotsDir(snapshotsDir, exclusive)
+		if err != nil {
+			unlock = nil
+		}
+		locked <- unlock
+	}()
+	return locked
+}
+
+func TestLockSnapshotsDir(t *testing.T) {
+	require := require.New(t)
+	snapshotsDir := filepath.Join(t.TempDir(), "snapshots")
+
+	// readers share the lock
+	unlockRead1, err := lockSnapshotsDir(snapshotsDir, false)
+	require.NoError(err)
+	require.FileExists(filepath.Join(snapshotsDir, snapshotsLockFileName))
+	unlockRead2, err := lockSnapshotsDir(snapshotsDir, false)
+	require.NoError(err)
+
+	// a writer waits for every reader
+	locked := lockSnapshotsDirAsync(snapshotsDir, true)
+	unlockRead1()
+	select {
+	case <-locked:
+		require.FailNow("snapshots dir locked while read")
+	case <-time.After(100 * time.Millisecond):
+	}
+	unlockRead2()
+	var unlockWrite func()
+	select {
+	case unlockWrite = <-locked:
+	case <-time.After(5 * time.Second):
+		require.FailNow("snapshots dir not locked once read")
+	}
+	require.NotNil(unlockWrite)
+
+	// a reader waits for the writer
+	locked = lockSnapshotsDirAsync(snapshotsDir, false)
+	select {
+	case <-locked:
+		require.FailNow("snapshots dir read while locked")
+	case <-time.After(100 * time.Millisecond):
+	}
+	unlockWrite()
+	select {
+	case unlockRead := <-locked:
+		require.NotNil(unlockRead)
+		unlockRead()
+	case <-time.After(5 * time.Second):
+		require.FailNow("snapshots dir not read once unlocked")
+	}
+}
+
+func TestNetworkSnapshotsDir(t *testing.T) {
+	require := require.New(t)
+
+	require.Equal("/snapshots", NetworkSnapshotsDir("/snapshots", ""))
+	require.Equal("/snapshots/devnet", NetworkSnapshotsDir("/snapshots", "devnet"))
+	require.Equal(filepath.Join(defaultSnapshotsDir, "devnet"), NetworkSnapshotsDir("", "devnet"))
+}
+
+// Returns the names of the entries of [dir]
+func dirEntryNames(t *testing.T, dir string) []string {
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	names := []string{}
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	return names
+}
+
+// TestSaveSnapshotTempDir checks that a snapshot is moved to its
+// final dir once saved, and that a failed save leaves nothing behind
+func TestSaveSnapshotTempDir(t *testing.T) {
+	require := require.New(t)
+
+	net := newSnapshottableNetwork(t, testNetworkConfig(t), &localTestSuccessfulNodeProcessCreator{})
+	snapshotDir, err := net.SaveSnapshot(context.Background(), "snapshot")
+	require.NoError(err)
+	require.Equal(filepath.Join(net.snapshotsDir, snapshotPrefix+"snapshot"), snapshotDir)
+	require.FileExists(filepath.Join(snapshotDir, "network.json"))
+	require.FileExists(filepath.Join(snapshotDir, "state.json"))
+	require.DirExists(filepath.Join(snapshotDir, defaultDBSubdir, "node0"))
+	require.ElementsMatch([]string{snapshotsLockFileName, snapshotPrefix + "snapshot"}, dirEntryNames(t, net.snapshotsDir))
+
+	// an existing snapshot isn't overwritten
+	networkConfig := testNetworkConfig(t)
+	networkConfig.NodeConfigs[0].DBType = node.DBTypeMemDB
+	net = newSnapshottableNetwork(t, networkConfig, &localTestSuccessfulNodeProcessCreator{})
+	existingDir := filepath.Join(net.snapshotsDir, snapshotPrefix+"existing")
+	require.NoError(os.MkdirAll(existingDir, os.ModePerm))
+	_, err = net.SaveSnapshot(context.Background(), "existing")
+	require.ErrorContains(err, "already exists")
+
+	// a save failing once the temp dir is created removes it
+	_, err = net.SaveSnapshot(context.Background(), "snapshot")
+	require.ErrorContains(err, "in memory db")
+	require.ElementsMatch([]string{snapshotsLockFileName, snapshotPrefix + "existing"}, dirEntryNames(t, net.snapshotsDir))
+	// the network isn't stopped
+	require.False(net.stopCalled())
+	require.NoError(net.Stop(context.Background()))
+}
+
+// TestCapture checks the tcpdump command capturing the node traffic,
+// run as a script writing its args to the capture file until interrupted
+func TestCapture(t *testing.T) {
+	require := require.New(t)
+	binDir := t.TempDir()
+	require.NoError(os.WriteFile(filepath.Join(binDir, captureBinary), []byte(`#!/bin/sh
+echo "$@" > "$5"
+trap 'exit 0' INT
+while :; do sleep 0.1; done
+`), 0o700))
+	t.Setenv("PATH", binDir+string(os.PathListSeparator)+os.Getenv("PATH"))
+	net := newSnapshottableNetwork(t, testNetworkConfig(t), &localTestSuccessfulNodeProcessCreator{})
+	ctx := context.Background()
+
+	_, err := net.StartCapture(ctx, "node3")
+	require.ErrorIs(err, network.ErrNodeNotFound)
+	_, err = net.StopCapture(ctx, "node0")
+	require.ErrorIs(err, ErrCaptureNotRunning)
+
+	path, err := net.StartCapture(ctx, "node0")
+	require.NoError(err)
+	node := net.nodes["node0"]
+	require.Equal(filepath.Join(node.GetDataDir(), capturesSubdir), filepath.Dir(path))
+	_, err = net.StartCapture(ctx, "node0")
+	require.ErrorIs(err, ErrCaptureRunning)
+
+	stoppedPath, err := net.StopCapture(ctx, "node0")
+	require.NoError(err)
+	require.Equal(path, stoppedPath)
+	args, err := os.ReadFile(path)
+	require.NoError(err)
+	require.Equal(
+		fmt.Sprintf("-i %s -U -w %s tcp port %d or tcp port %d\n", captureInterface(), path, node.GetP2PPort(), node.GetAPIPort()),
+		string(args),
+	)
+	_, err = net.StopCapture(ctx, "node0")
+	require.ErrorIs(err, ErrCaptureNotRunning)
+
+	t.Setenv("PATH", t.TempDir())
+	_, err = net.StartCapture(ctx, "node0")
+	require.ErrorContains(err, "couldn't find "+captureBinary)
+}
+
+func TestUpgradeNodes(t *testing.T) {
+	require := require.New(t)
+	ctx := context.Background()
+
+	creator := &localTestUpgradeNodeProcessCreator{}
+	net, err := newNetwork(logging.NoLog{}, newMockAPISuccessful, creator, "", "", false)
+	require.NoError(err)
+	require.NoError(net.loadConfig(ctx, testNetworkConfig(t)))
+	creator.binaryPaths = nil
+
+	binaryPath := filepath.Join(t.TempDir(), "luxd")
+	require.NoError(os.WriteFile(binaryPath, nil, 0o700))
+	require.NoError(net.UpgradeNodes(ctx, binaryPath, network.UpgradeOptions{NodeNames: []string{"node2", "node0"}}))
+	require.Equal([]string{binaryPath, binaryPath}, creator.binaryPaths)
+	require.Equal(binaryPath, net.nodes["node0"].GetConfig().BinaryPath)
+	require.Equal(binaryPath, net.nodes["node2"].GetConfig().BinaryPath)
+	require.NotEqual(binaryPath, net.nodes["node1"].GetConfig().BinaryPath)
+	require.EqualValues(1, net.nodes["node2"].GetRuntimeInfo().Restarts)
+
+	err = net.UpgradeNodes(ctx, binaryPath, network.UpgradeOptions{NodeNames: []string{"node9"}})
+	require.ErrorIs(err, network.ErrNodeNotFound)
+	err = net.UpgradeNodes(ctx, filepath.Join(t.TempDir(), "missing"), network.UpgradeOptions{})
+	require.ErrorIs(err, utils.ErrNotExists)
+}
+
 // checkNetwork receives a network, a set of running nodes (started and not removed yet), and
 // a set of removed nodes, checking:
 // - GetNodeNames retrieves the correct number of running nodes
@@ -1047,46 +2345,59 @@ func TestSetNodeName(t *testing.T) {
 	require.Error(err)
 }
 
-func TestGetConfigEntry(t *testing.T) {
-	t.Parallel()
+func TestNewSingleNodeConfig(t *testing.T) {
 	require := require.New(t)
 
-	// case: key not present
-	val, err := getConfigEntry(
-		map[string]interface{}{},
-		map[string]interface{}{"2": "2"},
-		"1",
-		"1",
-	)
-	require.NoError(err)
-	require.Equal("1", val)
+	config := NewSingleNodeConfig("luxd")
+	require.Len(config.NodeConfigs, 1)
+	require.True(config.NodeConfigs[0].IsBeacon)
+	require.Equal(false, config.Flags["sybil-protection-enabled"])
+	require.Equal(1, config.Flags["snow-sample-size"])
+	require.NoError(config.Validate())
+
+	// the default config is left as is
+	defaultConfig := NewDefaultConfig("luxd")
+	require.Len(defaultConfig.NodeConfigs, DefaultNumNodes)
+	require.NotContains(defaultConfig.Flags, "sybil-protection-enabled")
+}
 
-	// case: key present
-	val, err = getConfigEntry(
-		map[string]interface{}{},
-		map[string]interface{}{"1": "hi", "2": "2"},
-		"1",
-		"1",
-	)
+func TestNewMixedVersionConfig(t *testing.T) {
+	require := require.New(t)
+
+	config, err := NewUpgradeCompatibilityConfig("v1.10.2", "./build/luxd", 5)
+	require.NoError(err)
+	require.Len(config.NodeConfigs, 5)
+	require.Empty(config.BinaryPath)
+	for i, nodeConfig := range config.NodeConfigs {
+		if i%2 == 0 {
+			require.Equal("v1.10.2", nodeConfig.BinaryVersion)
+			require.Empty(nodeConfig.BinaryPath)
+		} else {
+			require.Equal("./build/luxd", nodeConfig.BinaryPath)
+			require.Empty(nodeConfig.BinaryVersion)
+		}
+	}
+	require.NoError(config.Validate())
+
+	config, err = NewMixedVersionConfig([]string{"v1.10.1", "v1.10.2", "v1.10.3"}, 7)
 	require.NoError(err)
-	require.Equal("hi", val)
+	require.Len(config.NodeConfigs, 7)
+	require.Equal("v1.10.1", config.NodeConfigs[6].BinaryVersion)
 
-	// case: key present wrong type
-	_, err = getConfigEntry(
-		map[string]interface{}{},
-		map[string]interface{}{"1": 1, "2": "2"},
-		"1",
-		"1",
-	)
+	_, err = NewMixedVersionConfig(nil, 5)
+	require.Error(err)
+	_, err = NewMixedVersionConfig([]string{"v1.10.1", "v1.10.2", "v1.10.3"}, 2)
 	require.Error(err)
 }
 
 func TestGetPort(t *testing.T) {
 	t.Parallel()
 	require := require.New(t)
+	rng := rand.New(rand.NewSource(1)) //nolint:gosec
 
 	// Case: port key present in config file
 	port, err := getPort(
+		rng,
 		map[string]interface{}{},
 		map[string]interface{}{"flag": float64(10013)},
 		"flag",
@@ -1097,6 +2408,7 @@ func TestGetPort(t *testing.T) {
 
 	// Case: port key present in flags
 	port, err = getPort(
+		rng,
 		map[string]interface{}{"flag": 10013},
 		map[string]interface{}{},
 		"flag",
@@ -1107,6 +2419,7 @@ func TestGetPort(t *testing.T) {
 
 	// Case: port key present in config file and flags
 	port, err = getPort(
+		rng,
 		map[string]interface{}{"flag": 10013},
 		map[string]interface{}{"flag": float64(14)},
 		"flag",
@@ -1117,6 +2430,7 @@ func TestGetPort(t *testing.T) {
 
 	// Case: port key not present
 	_, err = getPort(
+		rng,
 		map[string]interface{}{},
 		map[string]interface{}{},
 		"flag",
@@ -1125,6 +2439,203 @@ func TestGetPort(t *testing.T) {
 	require.NoError(err)
 }
 
+func TestFindBindErrorPort(t *testing.T) {
+	require := require.New(t)
+	logsDir := t.TempDir()
+	_, ok := findBindErrorPort(logsDir)
+	require.False(ok)
+
+	log := "[01-02|15:04:05.000] INFO node/node.go:1 initializing node\n" +
+		"[01-02|15:04:05.000] FATAL node/node.go:2 failed to listen {\"error\": \"listen tcp 127.0.0.1:14321: bind: address already in use\"}\n"
+	require.NoError(os.WriteFile(filepath.Join(logsDir, nodeMainLogFileName), []byte(log), 0o600))
+	port, ok := findBindErrorPort(logsDir)
+	require.True(ok)
+	require.Equal(uint16(14321), port)
+}
+
+func TestCorruptDBFiles(t *testing.T) {
+	require := require.New(t)
+	newDBDir := func() string {
+		dbDir := t.TempDir()
+		require.NoError(os.MkdirAll(filepath.Join(dbDir, "v1.4.5"), os.ModePerm))
+		for _, name := range []string{"000001.ldb", "000002.ldb", "MANIFEST-000003", "CURRENT"} {
+			require.NoError(os.WriteFile(filepath.Join(dbDir, "v1.4.5", name), []byte("0123456789"), 0o600))
+		}
+		return dbDir
+	}
+
+	dbDir := newDBDir()
+	files, err := corruptDBFiles(dbDir, network.DBCorruptionSpec{
+		Files:    []string{"*.ldb"},
+		Mode:     network.DBCorruptionTruncate,
+		NumBytes: 4,
+	})
+	require.NoError(err)
+	require.Equal([]string{filepath.Join("v1.4.5", "000001.ldb"), filepath.Join("v1.4.5", "000002.ldb")}, files)
+	content, err := os.ReadFile(filepath.Join(dbDir, "v1.4.5", "000001.ldb"))
+	require.NoError(err)
+	require.Equal("012345", string(content))
+	content, err = os.ReadFile(filepath.Join(dbDir, "v1.4.5", "CURRENT"))
+	require.NoError(err)
+	require.Equal("0123456789", string(content))
+
+	// the same seed damages the same bytes
+	flip := network.DBCorruptionSpec{
+		Files:    []string{"v1.4.5/MANIFEST-*"},
+		Mode:     network.DBCorruptionFlipBytes,
+		NumBytes: 3,
+		Seed:     7,
+	}
+	contents := [][]byte{}
+	for i := 0; i < 2; i++ {
+		dbDir := newDBDir()
+		_, err := corruptDBFiles(dbDir, flip)
+		require.NoError(err)
+		content, err := os.ReadFile(filepath.Join(dbDir, "v1.4.5", "MANIFEST-000003"))
+		require.NoError(err)
+		require.NotEqual("0123456789", string(content))
+		contents = append(contents, content)
+	}
+	require.Equal(contents[0], contents[1])
+
+	_, err = corruptDBFiles(dbDir, network.DBCorruptionSpec{Files: []string{"CURRENT"}, Mode: network.DBCorruptionDelete})
+	require.NoError(err)
+	require.NoFileExists(filepath.Join(dbDir, "v1.4.5", "CURRENT"))
+
+	_, err = corruptDBFiles(dbDir, network.DBCorruptionSpec{Files: []string{"*.log"}, Mode: network.DBCorruptionDelete})
+	require.ErrorIs(err, errNoDBFileMatched)
+	_, err = corruptDBFiles(dbDir, network.DBCorruptionSpec{Files: []string{"*.ldb"}, Mode: "other"})
+	require.Error(err)
+}
+
+func TestInspectDB(t *testing.T) {
+	require := require.New(t)
+	dbDir := t.TempDir()
+	db, err := leveldb.OpenFile(filepath.Join(dbDir, "network-1337", "v1.4.5"), nil)
+	require.NoError(err)
+	chain := dbChain{alias: "C", id: ids.GenerateTestID(), kind: evmVM}
+	vmPrefix := joinPrefixes(hashing.ComputeHash256(chain.id[:]), vmDBPrefix)
+	blkID := ids.GenerateTestID()
+	require.NoError(db.Put(nestedKey(vmPrefix, evmAcceptedPrefix, evmLastAcceptedKey), blkID[:], nil))
+	numberKey := append(append([]byte{}, evmHeaderNumberTable...), blkID[:]...)
+	require.NoError(db.Put(nestedKey(vmPrefix, evmEthDBPrefix, numberKey), []byte{0, 0, 0, 0, 0, 0, 0, 42}, nil))
+	require.NoError(db.Put([]byte("genesis"), []byte("value"), nil))
+	require.NoError(db.Close())
+
+	info, err := inspectDB(dbDir, []dbChain{chain})
+	require.NoError(err)
+	require.Equal(filepath.Join(dbDir, "network-1337", "v1.4.5"), info.Path)
+	require.Len(info.Chains, 1)
+	require.Equal(blkID.String(), info.Chains[0].LastAcceptedBlockID)
+	require.NotNil(info.Chains[0].LastAcceptedHeight)
+	require.Equal(uint64(42), *info.Chains[0].LastAcceptedHeight)
+	require.Equal(int64(len("genesis")+len("value")), info.PrefixSizes["other"])
+	require.Positive(info.PrefixSizes["C/vm"])
+	require.Positive(info.DiskSize)
+
+	_, err = inspectDB(t.TempDir(), []dbChain{chain})
+	require.ErrorIs(err, errNoDBFound)
+}
+
+func TestMeasureDiskUsage(t *testing.T) {
+	require := require.New(t)
+	rootDir := t.TempDir()
+	externalDBDir := t.TempDir()
+	nodeDir := filepath.Join(rootDir, "node1")
+	require.NoError(os.MkdirAll(filepath.Join(nodeDir, "logs"), os.ModePerm))
+	require.NoError(os.WriteFile(filepath.Join(nodeDir, "config.json"), make([]byte, 10), 0o600))
+	require.NoError(os.WriteFile(filepath.Join(nodeDir, "logs", "main.log"), make([]byte, 20), 0o600))
+	require.NoError(os.WriteFile(filepath.Join(externalDBDir, "000001.ldb"), make([]byte, 40), 0o600))
+
+	usage, err := measureDiskUsage(rootDir, map[string][]string{
+		"node1": {nodeDir, externalDBDir, filepath.Join(nodeDir, "logs")},
+	})
+	require.NoError(err)
+	// the logs dir is under the node dir, so it is only counted once
+	require.Equal(uint64(70), usage.Nodes["node1"])
+	require.Equal(uint64(70), usage.Total)
+
+	ln := &localNetwork{diskUsage: network.DiskUsage{Total: 70, Budget: 100}}
+	require.NoError(ln.checkDiskBudget())
+	ln.diskUsage.Budget = 50
+	require.ErrorIs(ln.checkDiskBudget(), ErrDiskBudgetExceeded)
+	ln.diskUsage.Budget = 0
+	require.NoError(ln.checkDiskBudget())
+}
+
+func TestCompactDB(t *testing.T) {
+	require := require.New(t)
+	dbPath := filepath.Join(t.TempDir(), "v1.4.5")
+	db, err := leveldb.OpenFile(dbPath, nil)
+	require.NoError(err)
+	for i := 0; i < 1000; i++ {
+		require.NoError(db.Put([]byte(fmt.Sprintf("key%d", i)), make([]byte, 1024), nil))
+	}
+	for i := 1; i < 1000; i++ {
+		require.NoError(db.Delete([]byte(fmt.Sprintf("key%d", i)), nil))
+	}
+	require.NoError(db.Close())
+
+	require.NoError(compactDB(dbPath))
+
+	db, err = leveldb.OpenFile(dbPath, nil)
+	require.NoError(err)
+	value, err := db.Get([]byte("key0"), nil)
+	require.NoError(err)
+	require.Len(value, 1024)
+	_, err = db.Get([]byte("key1"), nil)
+	require.ErrorIs(err, leveldb.ErrNotFound)
+	require.NoError(db.Close())
+}
+
+func TestNextFreePort(t *testing.T) {
+	require := require.New(t)
+	port, err := nextFreePort(minPort)
+	require.NoError(err)
+	require.Greater(port, uint16(minPort))
+	// wraps around at the end of the range
+	port, err = nextFreePort(MaxPort)
+	require.NoError(err)
+	require.GreaterOrEqual(port, uint16(minPort))
+}
+
+func TestGetFreePortSeeded(t *testing.T) {
+	require := require.New(t)
+	// the same seed gives the same free port
+	port1, err := getFreePort(rand.New(rand.NewSource(1))) //nolint:gosec
+	require.NoError(err)
+	port2, err := getFreePort(rand.New(rand.NewSource(1))) //nolint:gosec
+	require.NoError(err)
+	require.Equal(port1, port2)
+}
+
+func TestSetNodeKeysSeeded(t *testing.T) {
+	require := require.New(t)
+
+	nodeKeys := func(seed int64) (ids.NodeID, string) {
+		ln := &localNetwork{keyRNG: rand.New(rand.NewSource(seed))} //nolint:gosec
+		nodeConfig := node.Config{}
+		require.NoError(ln.setNodeKeys(&nodeConfig))
+		nodeID, err := utils.ToNodeID([]byte(nodeConfig.StakingKey), []byte(nodeConfig.StakingCert))
+		require.NoError(err)
+		return nodeID, nodeConfig.StakingSigningKey
+	}
+	// the same seed gives the same node
+	nodeID1, signingKey1 := nodeKeys(1)
+	nodeID2, signingKey2 := nodeKeys(1)
+	require.Equal(nodeID1, nodeID2)
+	require.Equal(signingKey1, signingKey2)
+	nodeID3, signingKey3 := nodeKeys(2)
+	require.NotEqual(nodeID1, nodeID3)
+	require.NotEqual(signingKey1, signingKey3)
+
+	// the keys given are kept
+	ln := &localNetwork{keyRNG: rand.New(rand.NewSource(1))} //nolint:gosec
+	nodeConfig := node.Config{StakingKey: "key", StakingCert: "cert", StakingSigningKey: "signingKey"}
+	require.NoError(ln.setNodeKeys(&nodeConfig))
+	require.Equal(node.Config{StakingKey: "key", StakingCert: "cert", StakingSigningKey: "signingKey"}, nodeConfig)
+}
+
 func TestCreateFileAndWrite(t *testing.T) {
 	t.Parallel()
 	require := require.New(t)
@@ -1139,131 +2650,109 @@ func TestCreateFileAndWrite(t *testing.T) {
 	require.Equal(contents, gotBytes)
 }
 
-func TestWriteFiles(t *testing.T) {
-	t.Parallel()
-	stakingKey := "stakingKey"
-	stakingCert := "stakingCert"
-	genesis := []byte("genesis")
-	configFile := "config file"
-	chainConfigFiles := map[string]string{
-		"C": "c-chain config file",
-	}
-	tmpDir, err := os.MkdirTemp("", "netrunner-tests-*")
-	if err != nil {
-		t.Fatal(err)
-	}
-	stakingKeyPath := filepath.Join(tmpDir, stakingKeyFileName)
-	stakingCertPath := filepath.Join(tmpDir, stakingCertFileName)
-	stakingSigningKeyPath := filepath.Join(tmpDir, stakingSigningKeyFileName)
-	genesisPath := filepath.Join(tmpDir, genesisFileName)
-	configFilePath := filepath.Join(tmpDir, configFileName)
-	chainConfigDir := filepath.Join(tmpDir, chainConfigSubDir)
-	subnetConfigDir := filepath.Join(tmpDir, subnetConfigSubDir)
-	cChainConfigPath := filepath.Join(tmpDir, chainConfigSubDir, "C", configFileName)
+func TestRestoreConfigFiles(t *testing.T) {
+	require := require.New(t)
+	dataDir := t.TempDir()
+	backupDir := t.TempDir()
+	configPath := filepath.Join(dataDir, configFileName)
+	cChainConfigPath := filepath.Join(dataDir, chainConfigSubDir, "C", configFileName)
+	require.NoError(createFileAndWrite(configPath, []byte("old config")))
+	require.NoError(createFileAndWrite(cChainConfigPath, []byte("old chain config")))
+	// not a config dir, so not backed up
+	require.NoError(createFileAndWrite(filepath.Join(dataDir, "db", "file"), []byte("db")))
+	require.NoError(copyConfigFiles(dataDir, backupDir))
+	require.NoDirExists(filepath.Join(backupDir, "db"))
+
+	require.NoError(createFileAndWrite(configPath, []byte("new config")))
+	require.NoError(os.Remove(cChainConfigPath))
+	xChainConfigPath := filepath.Join(dataDir, chainConfigSubDir, "X", configFileName)
+	require.NoError(createFileAndWrite(xChainConfigPath, []byte("new chain config")))
+
+	require.NoError(restoreConfigFiles(backupDir, dataDir))
+	gotBytes, err := os.ReadFile(configPath)
+	require.NoError(err)
+	require.Equal([]byte("old config"), gotBytes)
+	gotBytes, err = os.ReadFile(cChainConfigPath)
+	require.NoError(err)
+	require.Equal([]byte("old chain config"), gotBytes)
+	require.NoFileExists(xChainConfigPath)
+	require.FileExists(filepath.Join(dataDir, "db", "file"))
+}
 
-	type test struct {
-		name          string
-		shouldErr     bool
-		genesis       []byte
-		nodeConfig    node.Config
-		expectedFlags map[string]string
-	}
+func TestDiscoverPluginDir(t *testing.T) {
+	require := require.New(t)
+	binDir := t.TempDir()
+	binaryPath := filepath.Join(binDir, "luxd")
+	require.NoError(createFileAndWrite(binaryPath, []byte("binary")))
+	require.NoError(os.Chmod(binaryPath, 0o700))
+	require.Empty(discoverPluginDir(binaryPath))
+
+	pluginDir := filepath.Join(binDir, defaultPluginSubdir)
+	require.NoError(os.Mkdir(pluginDir, 0o750))
+	require.Equal(pluginDir, discoverPluginDir(binaryPath))
+	require.Empty(discoverPluginDir(filepath.Join(binDir, "missing")))
+}
 
-	tests := []test{
-		{
-			name:      "no config files given",
-			shouldErr: false,
-			genesis:   genesis,
-			nodeConfig: node.Config{
-				StakingKey:  stakingKey,
-				StakingCert: stakingCert,
-			},
-			expectedFlags: map[string]string{
-				config.StakingTLSKeyPathKey:    stakingKeyPath,
-				config.StakingCertPathKey:      stakingCertPath,
-				config.StakingSignerKeyPathKey: stakingSigningKeyPath,
-				config.GenesisConfigFileKey:    genesisPath,
-				config.ChainConfigDirKey:       chainConfigDir,
-				config.SubnetConfigDirKey:      subnetConfigDir,
-			},
-		},
-		{
-			name:      "config file given but not c-chain config file",
-			shouldErr: false,
-			genesis:   genesis,
-			nodeConfig: node.Config{
-				StakingKey:  stakingKey,
-				StakingCert: stakingCert,
-				ConfigFile:  configFile,
-			},
-			expectedFlags: map[string]string{
-				config.StakingTLSKeyPathKey:    stakingKeyPath,
-				config.StakingCertPathKey:      stakingCertPath,
-				config.StakingSignerKeyPathKey: stakingSigningKeyPath,
-				config.GenesisConfigFileKey:    genesisPath,
-				config.ChainConfigDirKey:       chainConfigDir,
-				config.SubnetConfigDirKey:      subnetConfigDir,
-				config.ConfigFileKey:           configFilePath,
-			},
-		},
-		{
-			name:      "config file and c-chain config file given",
-			shouldErr: false,
-			genesis:   genesis,
-			nodeConfig: node.Config{
-				StakingKey:       stakingKey,
-				StakingCert:      stakingCert,
-				ConfigFile:       configFile,
-				ChainConfigFiles: chainConfigFiles,
-			},
-			expectedFlags: map[string]string{
-				config.StakingTLSKeyPathKey:    stakingKeyPath,
-				config.StakingCertPathKey:      stakingCertPath,
-				config.StakingSignerKeyPathKey: stakingSigningKeyPath,
-				config.GenesisConfigFileKey:    genesisPath,
-				config.ChainConfigDirKey:       chainConfigDir,
-				config.SubnetConfigDirKey:      subnetConfigDir,
-				config.ConfigFileKey:           configFilePath,
-			},
+func TestCheckPlugins(t *testing.T) {
+	require := require.New(t)
+	vmID := ids.GenerateTestID()
+	pluginDir1 := t.TempDir()
+	pluginDir2 := t.TempDir()
+	ln := &localNetwork{
+		nodes: map[string]*localNode{
+			"node1": {pluginDir: pluginDir1},
+			"node2": {pluginDir: pluginDir1},
+			"node3": {pluginDir: pluginDir2},
+			// unknown plugin dir, not checked
+			"node4": {},
 		},
 	}
+	require.NoError(createFileAndWrite(filepath.Join(pluginDir1, vmID.String()), []byte("vm v1")))
+	err := ln.checkPlugins([]ids.ID{vmID})
+	require.ErrorIs(err, ErrPluginMismatch)
+	require.Contains(err.Error(), "node3=missing")
+
+	require.NoError(createFileAndWrite(filepath.Join(pluginDir2, vmID.String()), []byte("vm v2")))
+	err = ln.checkPlugins([]ids.ID{vmID})
+	require.ErrorIs(err, ErrPluginMismatch)
+	require.NotContains(err.Error(), "missing")
+
+	require.NoError(createFileAndWrite(filepath.Join(pluginDir2, vmID.String()), []byte("vm v1")))
+	require.NoError(ln.checkPlugins([]ids.ID{vmID, vmID}))
+}
 
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			require := require.New(t)
-			flags, err := writeFiles(0, tt.genesis, tmpDir, &tt.nodeConfig)
-			if tt.shouldErr {
-				require.Error(err)
-				return
-			}
-			require.NoError(err)
-			// Make sure returned flags are right
-			require.Len(tt.expectedFlags, len(flags))
-			for k := range flags {
-				require.Equal(tt.expectedFlags[k], flags[k])
-			}
-			// Assert files created correctly
-			gotStakingKey, err := os.ReadFile(stakingKeyPath)
-			require.NoError(err)
-			require.Equal([]byte(tt.nodeConfig.StakingKey), gotStakingKey)
-			gotStakingCert, err := os.ReadFile(stakingCertPath)
-			require.NoError(err)
-			require.Equal([]byte(tt.nodeConfig.StakingCert), gotStakingCert)
-			gotGenesis, err := os.ReadFile(genesisPath)
-			require.NoError(err)
-			require.Equal(tt.genesis, gotGenesis)
-			if len(tt.nodeConfig.ConfigFile) > 0 {
-				gotConfigFile, err := os.ReadFile(configFilePath)
-				require.NoError(err)
-				require.Equal([]byte(configFile), gotConfigFile)
-			}
-			if tt.nodeConfig.ChainConfigFiles != nil {
-				gotCChainConfigFile, err := os.ReadFile(cChainConfigPath)
-				require.NoError(err)
-				require.Equal([]byte(chainConfigFiles["C"]), gotCChainConfigFile)
-			}
-		})
+func TestRunReadinessProbe(t *testing.T) {
+	require := require.New(t)
+	var ready int32
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		if atomic.LoadInt32(&ready) == 0 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		_, _ = w.Write([]byte(`{"status":"ok"}`))
+	}))
+	defer srv.Close()
+
+	ln := &localNetwork{}
+	probe := network.ReadinessProbe{Name: "app", URL: srv.URL, ExpectBody: `"ok"`}
+	require.NoError(probe.Validate())
+	require.Error(ln.runProbe(context.Background(), probe))
+	atomic.StoreInt32(&ready, 1)
+	require.NoError(ln.runProbe(context.Background(), probe))
+	probe.ExpectBody = "ready"
+	require.Error(ln.runProbe(context.Background(), probe))
+
+	errNotReady := errors.New("not ready")
+	probe = network.ReadinessProbe{
+		Name: "callback",
+		Check: func(context.Context, network.Network) error {
+			return errNotReady
+		},
 	}
+	require.ErrorIs(ln.runProbe(context.Background(), probe), errNotReady)
+
+	require.Error(network.ReadinessProbe{Name: "both", URL: "/ready", Check: probe.Check}.Validate())
+	require.Error(network.ReadinessProbe{Name: "scheme", URL: "ftp://host/ready"}.Validate())
 }
 
 func TestRemoveBeacon(t *testing.T) {
@@ -1346,3 +2835,168 @@ func TestHealthyDuringNetworkStop(t *testing.T) {
 		require.Fail("Healthy should've returned immediately because network closed")
 	}
 }
+
+func TestRootDirMetadata(t *testing.T) {
+	require := require.New(t)
+	rootDir := t.TempDir()
+	labels := map[string]string{"owner": "team-a"}
+	require.NoError(writeRootDirMetadata(rootDir, "nightly", labels))
+	metadata, err := ReadRootDirMetadata(rootDir)
+	require.NoError(err)
+	require.Equal("nightly", metadata.Name)
+	require.Equal(labels, metadata.Labels)
+	require.WithinDuration(time.Now(), metadata.CreatedAt, time.Minute)
+}
+
+// The beacons given to connected networks are reached at their advertised IP
+func TestGetGenesisAndBeacons(t *testing.T) {
+	require := require.New(t)
+	net, err := newNetwork(logging.NoLog{}, newMockAPISuccessful, &localTestSuccessfulNodeProcessCreator{}, "", "", false)
+	require.NoError(err)
+	require.NoError(net.loadConfig(context.Background(), testNetworkConfig(t)))
+	defer func() {
+		require.NoError(net.Stop(context.Background()))
+	}()
+
+	net.nodes["node0"].config.PublicIP = "127.0.1.5"
+	net.nodes["node1"].config.PublicIP = ""
+	net.nodes["node1"].config.BindIP = "127.0.0.3"
+	net.nodes["node2"].config.PublicIP = ""
+	net.nodes["node2"].config.BindIP = ""
+	expectedIPs := map[string]string{
+		"node0": "127.0.1.5",
+		"node1": "127.0.0.3",
+		"node2": "::1",
+	}
+
+	genesis, beacons, err := net.getGenesisAndBeacons()
+	require.NoError(err)
+	require.Equal(net.genesis, genesis)
+	require.Len(beacons, len(expectedIPs))
+	for _, b := range beacons {
+		for name, node := range net.nodes {
+			if node.nodeID != b.ID() {
+				continue
+			}
+			require.Equal(expectedIPs[name], b.IP().IP.String())
+			require.Equal(node.p2pPort, b.IP().Port)
+		}
+	}
+}
+
+func TestFindOrphanedRootDirs(t *testing.T) {
+	require := require.New(t)
+	parentDir := t.TempDir()
+	newRootDir := func(name string, lockPID int) string {
+		rootDir := filepath.Join(parentDir, name)
+		require.NoError(os.Mkdir(rootDir, os.ModePerm))
+		if lockPID >= 0 {
+			lockPath := filepath.Join(rootDir, networkLockFileName)
+			require.NoError(os.WriteFile(lockPath, []byte(fmt.Sprint(lockPID)), 0o600))
+		}
+		return rootDir
+	}
+	newRootDir("network-locked", os.Getpid())
+	staleLock := newRootDir("network-stale-lock", 0)
+	inUse := newRootDir("network-in-use", -1)
+	noLock := newRootDir("network-no-lock", -1)
+	labels := map[string]string{"owner": "team-a"}
+	require.NoError(writeRootDirMetadata(noLock, "", labels))
+	newRootDir("other", -1)
+
+	orphans, err := FindOrphanedRootDirs([]string{parentDir}, []string{inUse})
+	require.NoError(err)
+	require.Len(orphans, 2)
+	require.Equal(noLock, orphans[0].Path)
+	require.Equal(labels, orphans[0].Labels)
+	require.Positive(orphans[0].Size)
+	require.Equal(staleLock, orphans[1].Path)
+
+	require.NoError(RemoveRootDirs(orphans))
+	require.NoDirExists(noLock)
+	require.NoDirExists(staleLock)
+	require.DirExists(inUse)
+}
+
+// Unnamed networks also lock their root dir, so that
+// it's not garbage collected while they run
+func TestUnnamedNetworkRootDirLock(t *testing.T) {
+	require := require.New(t)
+	net, err := newNetwork(logging.NoLog{}, newMockAPISuccessful, &localTestSuccessfulNodeProcessCreator{}, "", "", false)
+	require.NoError(err)
+	require.NoError(net.loadConfig(context.Background(), testNetworkConfig(t)))
+	require.Empty(net.name)
+
+	lockPath := filepath.Join(net.rootDir, networkLockFileName)
+	pid, err := readLockPID(lockPath)
+	require.NoError(err)
+	require.Equal(os.Getpid(), pid)
+	orphans, err := FindOrphanedRootDirs([]string{filepath.Dir(net.rootDir)}, nil)
+	require.NoError(err)
+	for _, orphan := range orphans {
+		require.NotEqual(net.rootDir, orphan.Path)
+	}
+
+	require.NoError(net.Stop(context.Background()))
+	require.NoFileExists(lockPath)
+}
+
+func TestCollectLogs(t *testing.T) {
+	require := require.New(t)
+
+	logsDir1, logsDir2 := t.TempDir(), t.TempDir()
+	ln := &localNetwork{
+		log: logging.NoLog{},
+		nodes: map[string]*localNode{
+			"node1": {name: "node1", logsDir: logsDir1},
+			"node2": {name: "node2", logsDir: logsDir2},
+		},
+	}
+	now := time.Now()
+	plainLine := fmt.Sprintf("[%s] INFO <C Chain> evm/vm.go:123 chain started {\"height\": 5}\n", now.Format(plainLogTimeLayout))
+	jsonLine := fmt.Sprintf("{\"timestamp\":%q,\"level\":\"warn\",\"logger\":\"P Chain\",\"msg\":\"peer dropped\",\"nodeID\":\"NodeID-1\"}\n", now.Add(-time.Second).Format(time.RFC3339Nano))
+	require.NoError(os.WriteFile(filepath.Join(logsDir1, "main.log"), []byte(plainLine), 0o600))
+	require.NoError(os.WriteFile(filepath.Join(logsDir2, "P.log"), []byte(jsonLine), 0o600))
+	// rotated logs are not collected
+	require.NoError(os.WriteFile(filepath.Join(logsDir2, "main.1.log"), []byte(plainLine), 0o600))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	combinedFile := filepath.Join(t.TempDir(), "combined.log")
+	ch, err := ln.CollectLogs(ctx, network.LogCollectorOptions{FromStart: true, CombinedFile: combinedFile})
+	require.NoError(err)
+
+	// ordered by time
+	entry := <-ch
+	require.Equal("node2", entry.Node)
+	require.Equal("P", entry.Log)
+	require.Equal("warn", entry.Level)
+	require.Equal("P Chain", entry.Logger)
+	require.Equal("peer dropped", entry.Message)
+	require.Equal(map[string]interface{}{"nodeID": "NodeID-1"}, entry.Fields)
+	entry = <-ch
+	require.Equal("node1", entry.Node)
+	require.Equal("main", entry.Log)
+	require.Equal("info", entry.Level)
+	require.Equal("C Chain", entry.Logger)
+	require.Equal("evm/vm.go:123", entry.Caller)
+	require.Equal("chain started", entry.Message)
+	require.Equal(map[string]interface{}{"height": float64(5)}, entry.Fields)
+	require.WithinDuration(now, entry.Time, time.Millisecond)
+
+	// lines written later, and unparsable ones, are collected as well
+	f, err := os.OpenFile(filepath.Join(logsDir1, "main.log"), os.O_APPEND|os.O_WRONLY, 0o600)
+	require.NoError(err)
+	_, err = f.WriteString("goroutine 1 [running]:\n")
+	require.NoError(err)
+	require.NoError(f.Close())
+	entry = <-ch
+	require.Equal("node1", entry.Node)
+	require.Equal("goroutine 1 [running]:", entry.Message)
+
+	cancel()
+	for range ch {
+	}
+	combined, err := os.ReadFile(combinedFile)
+	require.NoError(err)
+	require.Len(strings.Split(strings.TrimSpace(string(combined)), "\n"), 3)
+}
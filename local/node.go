@@ -6,11 +6,15 @@ import (
 	"encoding/json"
 	"fmt"
 	"net"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/luxdefi/netrunner/api"
 	"github.com/luxdefi/netrunner/network/node"
 	"github.com/luxdefi/netrunner/network/node/status"
+	"github.com/luxdefi/node/config"
 	"github.com/luxdefi/node/ids"
 	"github.com/luxdefi/node/message"
 	"github.com/luxdefi/node/network/peer"
@@ -73,11 +77,30 @@ type localNode struct {
 	config node.Config
 	// The node httpHost
 	httpHost string
+	// The host the runner reaches the node API at
+	apiHost string
+	// The flags the node process is started with
+	flags map[string]interface{}
+	// The IP the node binds to. If empty, the loopback address.
+	bindIP string
+	// Forwards the P2P port from the node public IP, if any
+	forwarder *portForwarder
+	// Running packet capture of the node ports, if any
+	capture *trafficCapture
 	// maps from peer ID to peer object
 	attachedPeers map[string]peer.Peer
 	// signals that the process is stopped but the information is valid
 	// and can be resumed
 	paused bool
+	// signals that the process is frozen with SIGSTOP, see FreezeNode
+	frozen bool
+	// bring-up timings, updated by concurrent health checks
+	timings     node.Timings
+	timingsLock sync.Mutex
+	// times the node was resumed, restarted or rolled back
+	restarts uint32
+	// exit code of the last process of the node that exited, if any
+	lastExitCode *int
 }
 
 func defaultGetConnFunc(ctx context.Context, node node.Node) (net.Conn, error) {
@@ -200,7 +223,44 @@ func (node *localNode) GetURL() string {
 	if node.httpHost == "0.0.0.0" || node.httpHost == "." {
 		return "0.0.0.0"
 	}
-	return "127.0.0.1"
+	if node.bindIP != "" {
+		return node.bindIP
+	}
+	return defaultBindIP
+}
+
+// See node.Node
+func (node *localNode) GetAPIBaseURI() string {
+	scheme := "http"
+	if node.tlsEnabled() {
+		scheme = "https"
+	}
+	return fmt.Sprintf("%s://%s", scheme, net.JoinHostPort(node.apiHost, strconv.Itoa(int(node.apiPort))))
+}
+
+// See node.Node
+func (node *localNode) GetChainURI(alias string) string {
+	uri := node.GetAPIBaseURI() + "/ext/bc/" + alias
+	if alias == "P" || alias == "X" {
+		return uri
+	}
+	return uri + "/rpc"
+}
+
+// See node.Node
+func (node *localNode) GetWSURI(alias string) string {
+	// same host and port as the http API, over ws(s)
+	return "ws" + strings.TrimPrefix(node.GetAPIBaseURI(), "http") + "/ext/bc/" + alias + "/ws"
+}
+
+// Returns true if the node serves its API over TLS
+func (node *localNode) tlsEnabled() bool {
+	v, err := node.GetFlag(config.HTTPSEnabledKey)
+	if err != nil {
+		return false
+	}
+	enabled, err := v.Bool()
+	return err == nil && enabled
 }
 
 // See node.Node
@@ -254,33 +314,78 @@ func (node *localNode) GetConfig() node.Config {
 }
 
 // See node.Node
-func (node *localNode) GetFlag(k string) (string, error) {
-	var v string
-	if node.config.ConfigFile != "" {
-		var configFileMap map[string]interface{}
-		if err := json.Unmarshal([]byte(node.config.ConfigFile), &configFileMap); err != nil {
-			return "", err
-		}
-		vIntf, ok := configFileMap[k]
-		if ok {
-			v, ok = vIntf.(string)
-			if !ok {
-				return "", fmt.Errorf("unexpected type for %q expected string got %T", k, vIntf)
-			}
+func (node *localNode) GetFlag(k string) (node.FlagValue, error) {
+	return getFlag(node.flags, node.config, k)
+}
+
+// Returns the value of flag [k] of a node started with [startFlags] and
+// [nodeConfig]. The command line flags of the node process, made of the
+// node config flags over the runner ones, take precedence over the config file.
+func getFlag(startFlags map[string]interface{}, nodeConfig node.Config, k string) (node.FlagValue, error) {
+	if v, ok := startFlags[k]; ok {
+		return node.NewFlagValue(v), nil
+	}
+	// nodes not started by the runner only know about their config
+	if v, ok := nodeConfig.Flags[k]; ok && startFlags == nil {
+		return node.NewFlagValue(v), nil
+	}
+	if nodeConfig.ConfigFile != "" {
+		var configFile map[string]interface{}
+		if err := json.Unmarshal([]byte(nodeConfig.ConfigFile), &configFile); err != nil {
+			return node.FlagValue{}, fmt.Errorf("couldn't unmarshal config file: %w", err)
 		}
-	} else if node.config.Flags != nil {
-		vIntf, ok := node.config.Flags[k]
-		if ok {
-			v, ok = vIntf.(string)
-			if !ok {
-				return "", fmt.Errorf("unexpected type for %q expected string got %T", k, vIntf)
-			}
+		if v, ok := configFile[k]; ok {
+			return node.NewFlagValue(v), nil
 		}
 	}
-	return v, nil
+	return node.FlagValue{}, nil
 }
 
 // See node.Node
 func (node *localNode) GetPaused() bool {
 	return node.paused
 }
+
+// See node.Node
+func (node *localNode) GetTimings() node.Timings {
+	node.timingsLock.Lock()
+	defer node.timingsLock.Unlock()
+
+	return node.timings
+}
+
+// See node.Node
+func (node *localNode) GetRuntimeInfo() (info node.RuntimeInfo) {
+	info.StartedAt = node.GetTimings().StartedAt
+	info.Restarts = node.restarts
+	info.LastExitCode = node.lastExitCode
+	if node.paused || node.process.Status() == status.Stopped {
+		exitCode := node.process.ExitCode()
+		info.LastExitCode = &exitCode
+	} else {
+		info.PID = node.process.PID()
+	}
+	return info
+}
+
+// Carries over the runtime history of [prev], the
+// node replaced by [node] when restarting it
+func (node *localNode) inheritRuntime(prev *localNode) {
+	node.restarts = prev.restarts + 1
+	node.lastExitCode = prev.GetRuntimeInfo().LastExitCode
+}
+
+// Records the first time the node API answered a health
+// check and, if [healthy], the first time it was healthy
+func (node *localNode) observeHealth(healthy bool) {
+	node.timingsLock.Lock()
+	defer node.timingsLock.Unlock()
+
+	elapsed := time.Since(node.timings.StartedAt)
+	if node.timings.APIUp == 0 {
+		node.timings.APIUp = elapsed
+	}
+	if healthy && node.timings.Healthy == 0 {
+		node.timings.Healthy = elapsed
+	}
+}
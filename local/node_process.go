@@ -6,7 +6,10 @@ import (
 	"io"
 	"os"
 	"os/exec"
+	"path/filepath"
+	"strings"
 	"sync"
+	"syscall"
 
 	"github.com/luxdefi/netrunner/network/node"
 	"github.com/luxdefi/netrunner/network/node/status"
@@ -16,7 +19,11 @@ import (
 	"go.uber.org/zap"
 )
 
-var _ NodeProcess = (*nodeProcess)(nil)
+var (
+	_ FreezableNodeProcess  = (*nodeProcess)(nil)
+	_ TerminableNodeProcess = (*nodeProcess)(nil)
+	_ SignalableNodeProcess = (*nodeProcess)(nil)
+)
 
 // NodeProcess as an interface so we can mock running
 // Lux binaries in tests
@@ -29,6 +36,34 @@ type NodeProcess interface {
 	Stop(ctx context.Context) int
 	// Returns the status of the process.
 	Status() status.Status
+	// Returns the process ID.
+	PID() int
+	// Returns the exit code of the process, or -1 if it
+	// hasn't exited yet or was killed by a signal.
+	ExitCode() int
+}
+
+// FreezableNodeProcess is a NodeProcess that can be frozen: it stays in
+// memory, with its state, but doesn't run until it is unfrozen
+type FreezableNodeProcess interface {
+	NodeProcess
+	Freeze() error
+	Unfreeze() error
+}
+
+// TerminableNodeProcess is a NodeProcess that can be stopped with a
+// SIGTERM, as by a service manager, rather than with a SIGINT
+type TerminableNodeProcess interface {
+	NodeProcess
+	// As Stop, sending a SIGTERM instead of a SIGINT
+	Terminate(ctx context.Context) int
+}
+
+// SignalableNodeProcess is a NodeProcess that can be sent any signal,
+// e.g. a SIGQUIT to get the stacks of its goroutines
+type SignalableNodeProcess interface {
+	NodeProcess
+	Signal(sig syscall.Signal) error
 }
 
 // NodeProcessCreator is an interface for new node process creation
@@ -48,16 +83,38 @@ type nodeProcessCreator struct {
 	// If this node's stderr is redirected, it will be to here.
 	// In practice this is usually os.Stderr, but for testing can be replaced.
 	stderr io.Writer
+	// If not nil, the lines written by the nodes to their stdout and
+	// stderr are published to their consoles here, and redirected
+	// from there if set so
+	consoles *consoleHub
 }
 
 // NewNodeProcess creates a new process of the passed binary
 // If the config has redirection set to `true` for either StdErr or StdOut,
 // the output will be redirected and colored
 func (npc *nodeProcessCreator) NewNodeProcess(config node.Config, args ...string) (NodeProcess, error) {
-	// Start the Lux node and pass it the flags defined above
-	cmd := exec.Command(config.BinaryPath, args...) //nolint
+	cgroupDir, err := nodeCgroup(config)
+	if err != nil {
+		return nil, err
+	}
+	cmd := nodeCommand(config, cgroupDir, args)
 	// assign a new color to this process (might not be used if the config isn't set for it)
 	color := npc.colorPicker.NextColor()
+	if npc.consoles != nil {
+		var stdout, stderr io.Writer
+		if config.RedirectStdout {
+			stdout = npc.stdout
+		}
+		if config.RedirectStderr {
+			stderr = npc.stderr
+		}
+		closePipes, err := npc.consoles.capture(cmd, config.Name, color, stdout, stderr)
+		if err != nil {
+			return nil, err
+		}
+		defer closePipes()
+		return npc.startNodeProcess(config.Name, cmd, cgroupDir)
+	}
 	// Optionally redirect stdout and stderr
 	if config.RedirectStdout {
 		stdout, err := cmd.StdoutPipe()
@@ -75,7 +132,79 @@ func (npc *nodeProcessCreator) NewNodeProcess(config node.Config, args ...string
 		// redirect stderr and assign a color to the text
 		utils.ColorAndPrepend(stderr, npc.stderr, config.Name, color)
 	}
-	return newNodeProcess(config.Name, npc.log, cmd)
+	return npc.startNodeProcess(config.Name, cmd, cgroupDir)
+}
+
+// Starts the process of [cmd], removing its cgroup dir, if any, once it exits
+func (npc *nodeProcessCreator) startNodeProcess(name string, cmd *exec.Cmd, cgroupDir string) (NodeProcess, error) {
+	np, err := newNodeProcess(name, npc.log, cmd)
+	if cgroupDir != "" {
+		go func() {
+			<-np.closedOnStop
+			// fails if plugin processes of the node are left in it
+			if err := os.Remove(cgroupDir); err != nil {
+				npc.log.Warn("couldn't remove node cgroup", utils.NodeField(name), zap.Error(err))
+			}
+		}()
+	}
+	return np, err
+}
+
+// Returns the cgroup dir of a new process of the node of [config], with
+// its CPU and memory limits, or "" if it has none. Returns an error if the
+// limits can't be applied with a cgroup (e.g. without cgroup v2, or write
+// access to it), rather than starting the node with fewer limits than asked.
+func nodeCgroup(config node.Config) (string, error) {
+	limits := config.Resources
+	if limits == nil || (limits.CPUs == 0 && limits.Memory == "") {
+		return "", nil
+	}
+	// validated with the config
+	memory, _ := limits.MemoryBytes()
+	parent, err := nodeCgroupsParent()
+	if err != nil {
+		return "", fmt.Errorf("couldn't limit the CPU and memory of node %q: %w", config.Name, err)
+	}
+	dir, err := createNodeCgroup(parent, config.Name, limits.CPUs, memory)
+	if err != nil {
+		return "", fmt.Errorf("couldn't limit the CPU and memory of node %q: %w", config.Name, err)
+	}
+	return dir, nil
+}
+
+// Returns the command running the node binary with [args] in the work dir
+// of [config] and, if given, with the umask and open files limit of
+// [config] and in the cgroup [cgroupDir], which limits its CPU and memory.
+func nodeCommand(config node.Config, cgroupDir string, args []string) *exec.Cmd {
+	prelude := []string{}
+	if config.Umask != "" {
+		prelude = append(prelude, "umask "+config.Umask)
+	}
+	if limits := config.Resources; limits != nil && limits.MaxOpenFiles != 0 {
+		prelude = append(prelude, fmt.Sprintf("ulimit -n %d", limits.MaxOpenFiles))
+	}
+	if cgroupDir != "" {
+		prelude = append(prelude, "echo $$ > "+shellQuote(filepath.Join(cgroupDir, "cgroup.procs")))
+	}
+	cmd := exec.Command(config.BinaryPath, args...) //nolint
+	if len(prelude) != 0 {
+		// the node binary replaces a shell setting the umask, rlimits and
+		// cgroup, so the process keeps the pid and gets them, and the
+		// plugin processes it starts get them too
+		script := strings.Join(prelude, " && ") + ` && exec "$0" "$@"`
+		shArgs := append([]string{"-c", script, config.BinaryPath}, args...)
+		cmd = exec.Command("/bin/sh", shArgs...) //nolint
+	}
+	cmd.Dir = config.WorkDir
+	// in its own process group, the node doesn't get the terminal interrupts
+	// sent to the runner, which stops it in order or leaves it running
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	return cmd
+}
+
+// Returns [s] quoted as a single shell word
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
 }
 
 type nodeProcess struct {
@@ -85,6 +214,8 @@ type nodeProcess struct {
 	cmd  *exec.Cmd
 	// Process status
 	state status.Status
+	// true if the process group is stopped with SIGSTOP
+	frozen bool
 	// Closed when the process exits.
 	closedOnStop chan struct{}
 }
@@ -92,7 +223,7 @@ type nodeProcess struct {
 func newNodeProcess(name string, log logging.Logger, cmd *exec.Cmd) (*nodeProcess, error) {
 	np := &nodeProcess{
 		name:         name,
-		log:          log,
+		log:          utils.WithFields(log, utils.NodeField(name)),
 		cmd:          cmd,
 		closedOnStop: make(chan struct{}),
 	}
@@ -120,10 +251,10 @@ func (p *nodeProcess) start() error {
 // When it does, update the state and close [p.closedOnStop]
 func (p *nodeProcess) awaitExit() {
 	if err := p.cmd.Wait(); err != nil {
-		p.log.Debug("node returned error on wait", zap.String("node", p.name), zap.Error(err))
+		p.log.Debug("node returned error on wait", zap.Error(err))
 	}
 
-	p.log.Debug("node process finished", zap.String("node", p.name))
+	p.log.Debug("node process finished")
 
 	p.lock.Lock()
 	defer p.lock.Unlock()
@@ -133,6 +264,16 @@ func (p *nodeProcess) awaitExit() {
 }
 
 func (p *nodeProcess) Stop(ctx context.Context) int {
+	return p.stop(ctx, os.Interrupt)
+}
+
+func (p *nodeProcess) Terminate(ctx context.Context) int {
+	return p.stop(ctx, syscall.SIGTERM)
+}
+
+// Sends [sig] to the process and waits for it to exit,
+// or kills it once [ctx] is done. See NodeProcess.Stop.
+func (p *nodeProcess) stop(ctx context.Context, sig os.Signal) int {
 	p.lock.Lock()
 
 	// The process is already stopped.
@@ -155,17 +296,25 @@ func (p *nodeProcess) Stop(ctx context.Context) int {
 
 	p.state = status.Stopping
 	proc := p.cmd.Process
+	frozen := p.frozen
+	p.frozen = false
 	// We have to unlock here so that [p.awaitExit] can grab the lock
 	// and close [p.closedOnStop].
 	p.lock.Unlock()
 
-	if err := proc.Signal(os.Interrupt); err != nil {
-		p.log.Warn("sending SIGINT errored", zap.Error(err))
+	if err := proc.Signal(sig); err != nil {
+		p.log.Warn("sending stop signal errored", zap.Stringer("signal", sig), zap.Error(err))
+	}
+	if frozen {
+		// the signal is handled once the process runs again
+		if err := syscall.Kill(-proc.Pid, syscall.SIGCONT); err != nil {
+			p.log.Warn("sending SIGCONT errored", zap.Error(err))
+		}
 	}
 
 	select {
 	case <-ctx.Done():
-		p.log.Warn("context cancelled while waiting for node to stop", zap.String("node", p.name))
+		p.log.Warn("context cancelled while waiting for node to stop")
 		killDescendants(int32(proc.Pid), p.log)
 		if err := proc.Signal(os.Kill); err != nil {
 			p.log.Warn("sending SIGKILL errored", zap.Error(err))
@@ -180,6 +329,48 @@ func (p *nodeProcess) Stop(ctx context.Context) int {
 	return p.cmd.ProcessState.ExitCode()
 }
 
+// Freeze sends a SIGSTOP to the process group of the node,
+// so that its plugin processes are frozen too
+func (p *nodeProcess) Freeze() error {
+	return p.signalGroup(syscall.SIGSTOP, true)
+}
+
+// Unfreeze sends a SIGCONT to the process group of the node
+func (p *nodeProcess) Unfreeze() error {
+	return p.signalGroup(syscall.SIGCONT, false)
+}
+
+// Sends [sig] to the process group of the running node and records
+// whether it is [frozen]
+func (p *nodeProcess) signalGroup(sig syscall.Signal, frozen bool) error {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	if p.state != status.Running {
+		return fmt.Errorf("node process is %s", p.state)
+	}
+	if err := syscall.Kill(-p.cmd.Process.Pid, sig); err != nil {
+		return fmt.Errorf("couldn't send %s: %w", sig, err)
+	}
+	p.frozen = frozen
+	return nil
+}
+
+// Signal sends [sig] to the running node process only,
+// not to its plugin processes
+func (p *nodeProcess) Signal(sig syscall.Signal) error {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+
+	if p.state != status.Running {
+		return fmt.Errorf("node process is %s", p.state)
+	}
+	if err := p.cmd.Process.Signal(sig); err != nil {
+		return fmt.Errorf("couldn't send %s: %w", sig, err)
+	}
+	return nil
+}
+
 func (p *nodeProcess) Status() status.Status {
 	p.lock.RLock()
 	defer p.lock.RUnlock()
@@ -187,6 +378,23 @@ func (p *nodeProcess) Status() status.Status {
 	return p.state
 }
 
+func (p *nodeProcess) PID() int {
+	if p.cmd.Process == nil {
+		return 0
+	}
+	return p.cmd.Process.Pid
+}
+
+func (p *nodeProcess) ExitCode() int {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+
+	if p.state != status.Stopped {
+		return -1
+	}
+	return p.cmd.ProcessState.ExitCode()
+}
+
 func killDescendants(pid int32, log logging.Logger) {
 	procs, err := process.Processes()
 	if err != nil {
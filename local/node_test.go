@@ -1,17 +1,25 @@
 package local
 
 import (
+	"bufio"
 	"bytes"
 	"context"
 	"crypto"
 	"crypto/tls"
 	"encoding/binary"
+	"fmt"
 	"io"
 	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
 	"testing"
 	"time"
 
+	"github.com/luxdefi/netrunner/local/mocks"
+	"github.com/luxdefi/netrunner/network"
 	"github.com/luxdefi/netrunner/network/node"
+	"github.com/luxdefi/netrunner/network/node/status"
 	"github.com/luxdefi/node/ids"
 	"github.com/luxdefi/node/message"
 	"github.com/luxdefi/node/network/peer"
@@ -242,3 +250,459 @@ func TestAttachPeer(t *testing.T) {
 	// also ensures that [require] calls will be reflected in test results if failed
 	require.NoError(<-errCh)
 }
+
+func TestPortForwarder(t *testing.T) {
+	require := require.New(t)
+
+	// echo server standing for the node P2P port
+	target, err := net.Listen(constants.NetworkType, "127.0.0.1:0")
+	require.NoError(err)
+	defer target.Close()
+	go func() {
+		for {
+			conn, err := target.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				_, _ = io.Copy(conn, conn)
+				_ = conn.Close()
+			}()
+		}
+	}()
+
+	forwarder, err := newPortForwarder(logging.NoLog{}, "127.0.0.1:0", target.Addr().String(), 0, nil, nil)
+	require.NoError(err)
+
+	conn, err := net.Dial(constants.NetworkType, forwarder.listener.Addr().String())
+	require.NoError(err)
+	msg := []byte("hello")
+	_, err = conn.Write(msg)
+	require.NoError(err)
+	got := make([]byte, len(msg))
+	_, err = io.ReadFull(conn, got)
+	require.NoError(err)
+	require.Equal(msg, got)
+
+	// closing the forwarder drops the forwarded connections
+	require.NoError(forwarder.Close())
+	require.NoError(conn.SetReadDeadline(time.Now().Add(5 * time.Second)))
+	_, err = conn.Read(got)
+	require.Error(err)
+	_ = conn.Close()
+
+	_, err = net.Dial(constants.NetworkType, forwarder.listener.Addr().String())
+	require.Error(err)
+}
+
+func TestPortForwarderLatency(t *testing.T) {
+	require := require.New(t)
+
+	target, err := net.Listen(constants.NetworkType, "127.0.0.1:0")
+	require.NoError(err)
+	defer target.Close()
+	go func() {
+		conn, err := target.Accept()
+		if err != nil {
+			return
+		}
+		_, _ = io.Copy(conn, conn)
+		_ = conn.Close()
+	}()
+
+	latency := 100 * time.Millisecond
+	forwarder, err := newPortForwarder(logging.NoLog{}, "127.0.0.1:0", target.Addr().String(), latency, nil, nil)
+	require.NoError(err)
+	defer forwarder.Close()
+
+	conn, err := net.Dial(constants.NetworkType, forwarder.listener.Addr().String())
+	require.NoError(err)
+	defer conn.Close()
+	msg := []byte("hello")
+	start := time.Now()
+	_, err = conn.Write(msg)
+	require.NoError(err)
+	got := make([]byte, len(msg))
+	_, err = io.ReadFull(conn, got)
+	require.NoError(err)
+	require.Equal(msg, got)
+	// delayed on the way to the target and back
+	require.GreaterOrEqual(time.Since(start), 2*latency)
+}
+
+func TestPortForwarderLinkConditions(t *testing.T) {
+	require := require.New(t)
+
+	target, err := net.Listen(constants.NetworkType, "127.0.0.1:0")
+	require.NoError(err)
+	defer target.Close()
+	go func() {
+		conn, err := target.Accept()
+		if err != nil {
+			return
+		}
+		_, _ = io.Copy(conn, conn)
+		_ = conn.Close()
+	}()
+
+	forwarder, err := newPortForwarder(logging.NoLog{}, "127.0.0.1:0", target.Addr().String(), 0, nil, nil)
+	require.NoError(err)
+	defer forwarder.Close()
+
+	conn, err := net.Dial(constants.NetworkType, forwarder.listener.Addr().String())
+	require.NoError(err)
+	defer conn.Close()
+	echo := func() time.Duration {
+		msg := []byte("hello")
+		start := time.Now()
+		_, err := conn.Write(msg)
+		require.NoError(err)
+		got := make([]byte, len(msg))
+		_, err = io.ReadFull(conn, got)
+		require.NoError(err)
+		require.Equal(msg, got)
+		return time.Since(start)
+	}
+	require.Less(echo(), 100*time.Millisecond)
+
+	// applies to the connection already made
+	forwarder.setConditions(&node.LinkConditions{LatencyMs: 50, JitterMs: 10})
+	elapsed := echo()
+	require.GreaterOrEqual(elapsed, 100*time.Millisecond)
+	require.Less(elapsed, 2*time.Second)
+
+	// all the data is lost once, and retransmitted
+	forwarder.setConditions(&node.LinkConditions{LossPct: 100})
+	require.GreaterOrEqual(echo(), 2*lossRetransmitDelay)
+
+	forwarder.setConditions(nil)
+	require.Less(echo(), 100*time.Millisecond)
+}
+
+func TestRegionLinks(t *testing.T) {
+	require := require.New(t)
+
+	require.Nil(newRegionLinks(nil))
+	require.Nil((*regionLinks)(nil).getTopology())
+
+	links := newRegionLinks(&network.Topology{
+		Latencies: map[string]map[string]time.Duration{
+			"a": {"b": 50 * time.Millisecond},
+		},
+	})
+	// this process stands for a node of region a
+	links.addNode(os.Getpid(), "a")
+
+	listener, err := net.Listen(constants.NetworkType, "127.0.0.1:0")
+	require.NoError(err)
+	defer listener.Close()
+	conn, err := net.Dial(constants.NetworkType, listener.Addr().String())
+	require.NoError(err)
+	defer conn.Close()
+	accepted, err := listener.Accept()
+	require.NoError(err)
+	defer accepted.Close()
+
+	region, ok := links.regionOf(accepted.RemoteAddr())
+	require.True(ok)
+	require.Equal("a", region)
+	latency, ok := links.latency("b", accepted.RemoteAddr())
+	require.True(ok)
+	require.Equal(50*time.Millisecond, latency)
+	// no latency within a region unless given
+	_, ok = links.latency("a", accepted.RemoteAddr())
+	require.False(ok)
+
+	links.removeNode(os.Getpid())
+	_, ok = links.regionOf(accepted.RemoteAddr())
+	require.False(ok)
+}
+
+func TestPartition(t *testing.T) {
+	require := require.New(t)
+
+	// echo server standing for the P2P port of node b
+	target, err := net.Listen(constants.NetworkType, "127.0.0.1:0")
+	require.NoError(err)
+	defer target.Close()
+	go func() {
+		for {
+			conn, err := target.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				_, _ = io.Copy(conn, conn)
+				_ = conn.Close()
+			}()
+		}
+	}()
+
+	p := newPartition()
+	// this process stands for node a, dialing node b through its forwarder
+	p.addNode(os.Getpid(), "a")
+	forwarder, err := newPortForwarder(logging.NoLog{}, "127.0.0.1:0", target.Addr().String(), 0, nil, func(remote net.Addr) bool {
+		return p.allows("b", remote)
+	})
+	require.NoError(err)
+	defer forwarder.Close()
+
+	echoes := func(conn net.Conn) bool {
+		require.NoError(conn.SetDeadline(time.Now().Add(5 * time.Second)))
+		msg := []byte("hello")
+		if _, err := conn.Write(msg); err != nil {
+			return false
+		}
+		got := make([]byte, len(msg))
+		_, err := io.ReadFull(conn, got)
+		return err == nil && bytes.Equal(msg, got)
+	}
+
+	conn, err := net.Dial(constants.NetworkType, forwarder.listener.Addr().String())
+	require.NoError(err)
+	defer conn.Close()
+	require.True(echoes(conn))
+
+	// the connection from a is cut, and new ones are rejected
+	p.set([]string{"a"}, []string{"b"})
+	forwarder.closeRejected()
+	require.False(echoes(conn))
+	conn, err = net.Dial(constants.NetworkType, forwarder.listener.Addr().String())
+	require.NoError(err)
+	defer conn.Close()
+	require.False(echoes(conn))
+
+	// a node on the same side, or out of the partition, is allowed
+	p.set([]string{"a", "b"}, []string{"c"})
+	conn, err = net.Dial(constants.NetworkType, forwarder.listener.Addr().String())
+	require.NoError(err)
+	defer conn.Close()
+	require.True(echoes(conn))
+
+	p.set(nil, nil)
+	conn, err = net.Dial(constants.NetworkType, forwarder.listener.Addr().String())
+	require.NoError(err)
+	defer conn.Close()
+	require.True(echoes(conn))
+}
+
+func TestAPIConnections(t *testing.T) {
+	require := require.New(t)
+
+	// this process stands for a node with its API at the listener port
+	listener, err := net.Listen(constants.NetworkType, "127.0.0.1:0")
+	require.NoError(err)
+	defer listener.Close()
+	port := listener.Addr().(*net.TCPAddr).Port
+
+	// the connections of this process aren't in-flight calls
+	conn, err := net.Dial(constants.NetworkType, listener.Addr().String())
+	require.NoError(err)
+	defer conn.Close()
+	accepted, err := listener.Accept()
+	require.NoError(err)
+	defer accepted.Close()
+	conns, err := apiConnections(os.Getpid(), uint16(port))
+	require.NoError(err)
+	require.Zero(conns)
+
+	// the ones of other processes are
+	client := exec.Command("bash", "-c", fmt.Sprintf("exec 3<>/dev/tcp/127.0.0.1/%d; sleep 10", port))
+	require.NoError(client.Start())
+	defer func() {
+		_ = client.Process.Kill()
+		_ = client.Wait()
+	}()
+	accepted, err = listener.Accept()
+	require.NoError(err)
+	defer accepted.Close()
+	conns, err = apiConnections(os.Getpid(), uint16(port))
+	require.NoError(err)
+	require.Equal(1, conns)
+}
+
+func TestGetFlag(t *testing.T) {
+	require := require.New(t)
+	nodeConfig := node.Config{
+		ConfigFile: `{"log-level":"debug","index-enabled":true,"api-admin-enabled":false,"throttler-inbound-at-large-alloc-size":4096,"health-check-frequency":"2s"}`,
+		Flags: map[string]interface{}{
+			"api-admin-enabled": true,
+			"snow-sample-size":  3,
+		},
+	}
+	startFlags := map[string]interface{}{
+		"api-admin-enabled": true,
+		"snow-sample-size":  3,
+		"http-port":         "9650",
+	}
+
+	// node config flags take precedence over the config file
+	v, err := getFlag(startFlags, nodeConfig, "api-admin-enabled")
+	require.NoError(err)
+	b, err := v.Bool()
+	require.NoError(err)
+	require.True(b)
+	// flags set by the runner are strings
+	v, err = getFlag(startFlags, nodeConfig, "http-port")
+	require.NoError(err)
+	i, err := v.Int()
+	require.NoError(err)
+	require.Equal(int64(9650), i)
+	v, err = getFlag(startFlags, nodeConfig, "snow-sample-size")
+	require.NoError(err)
+	require.Equal("3", v.String())
+
+	// config file values keep their JSON types
+	v, err = getFlag(startFlags, nodeConfig, "index-enabled")
+	require.NoError(err)
+	b, err = v.Bool()
+	require.NoError(err)
+	require.True(b)
+	v, err = getFlag(startFlags, nodeConfig, "throttler-inbound-at-large-alloc-size")
+	require.NoError(err)
+	i, err = v.Int()
+	require.NoError(err)
+	require.Equal(int64(4096), i)
+	v, err = getFlag(startFlags, nodeConfig, "health-check-frequency")
+	require.NoError(err)
+	d, err := v.Duration()
+	require.NoError(err)
+	require.Equal(2*time.Second, d)
+	v, err = getFlag(startFlags, nodeConfig, "log-level")
+	require.NoError(err)
+	_, err = v.Bool()
+	require.ErrorIs(err, node.ErrFlagType)
+
+	v, err = getFlag(startFlags, nodeConfig, "track-subnets")
+	require.NoError(err)
+	require.False(v.IsSet())
+	require.Equal("", v.String())
+
+	nodeConfig.ConfigFile = "{"
+	_, err = getFlag(startFlags, nodeConfig, "track-subnets")
+	require.Error(err)
+}
+
+func TestNodeURIs(t *testing.T) {
+	require := require.New(t)
+	n := &localNode{
+		apiHost: "127.0.0.2",
+		apiPort: 9650,
+		config:  node.Config{Flags: map[string]interface{}{}},
+	}
+	require.Equal("http://127.0.0.2:9650", n.GetAPIBaseURI())
+	require.Equal("http://127.0.0.2:9650/ext/bc/C/rpc", n.GetChainURI("C"))
+	require.Equal("http://127.0.0.2:9650/ext/bc/P", n.GetChainURI("P"))
+	require.Equal("ws://127.0.0.2:9650/ext/bc/C/ws", n.GetWSURI("C"))
+
+	n.apiHost = "::1"
+	n.config.ConfigFile = `{"http-tls-enabled":true}`
+	require.Equal("https://[::1]:9650", n.GetAPIBaseURI())
+	require.Equal("https://[::1]:9650/ext/bc/mychain/rpc", n.GetChainURI("mychain"))
+	require.Equal("wss://[::1]:9650/ext/bc/mychain/ws", n.GetWSURI("mychain"))
+}
+
+func TestNodeTimings(t *testing.T) {
+	require := require.New(t)
+	n := &localNode{
+		timings: node.Timings{
+			StartedAt:    time.Now().Add(-time.Second),
+			ProcessSpawn: time.Millisecond,
+		},
+	}
+	n.observeHealth(false)
+	timings := n.GetTimings()
+	require.GreaterOrEqual(timings.APIUp, time.Second)
+	require.Zero(timings.Healthy)
+
+	n.observeHealth(true)
+	timings = n.GetTimings()
+	require.GreaterOrEqual(timings.Healthy, timings.APIUp)
+	// only the first observations are kept
+	n.observeHealth(true)
+	require.Equal(timings, n.GetTimings())
+}
+
+func TestNodeRuntimeInfo(t *testing.T) {
+	require := require.New(t)
+	running := &mocks.NodeProcess{}
+	running.On("Status").Return(status.Running)
+	running.On("PID").Return(1234)
+	startedAt := time.Now()
+	n := &localNode{
+		process: running,
+		timings: node.Timings{StartedAt: startedAt},
+	}
+	require.Equal(node.RuntimeInfo{PID: 1234, StartedAt: startedAt}, n.GetRuntimeInfo())
+
+	exited := &mocks.NodeProcess{}
+	exited.On("Status").Return(status.Stopped)
+	exited.On("ExitCode").Return(1)
+	n.process = exited
+	info := n.GetRuntimeInfo()
+	require.Zero(info.PID)
+	require.NotNil(info.LastExitCode)
+	require.Equal(1, *info.LastExitCode)
+
+	// the exit code of the replaced process is kept on restart
+	restarted := &localNode{process: running}
+	restarted.inheritRuntime(n)
+	info = restarted.GetRuntimeInfo()
+	require.Equal(1234, info.PID)
+	require.Equal(uint32(1), info.Restarts)
+	require.NotNil(info.LastExitCode)
+	require.Equal(1, *info.LastExitCode)
+}
+
+func TestStreamLogs(t *testing.T) {
+	require := require.New(t)
+
+	logsDir := t.TempDir()
+	logPath := filepath.Join(logsDir, "main.log")
+	require.NoError(os.WriteFile(logPath, []byte("line1\nline2\n"), 0o600))
+	n := &localNode{name: "node1", logsDir: logsDir}
+
+	logs, err := n.StreamLogs(context.Background(), "", false)
+	require.NoError(err)
+	b, err := io.ReadAll(logs)
+	require.NoError(err)
+	require.Equal("line1\nline2\n", string(b))
+	require.NoError(logs.Close())
+
+	_, err = n.StreamLogs(context.Background(), "../main", false)
+	require.ErrorIs(err, errInvalidLogName)
+	_, err = n.StreamLogs(context.Background(), "C", false)
+	require.ErrorIs(err, os.ErrNotExist)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	logs, err = n.StreamLogs(ctx, "main", true)
+	require.NoError(err)
+	defer logs.Close()
+	lines := bufio.NewReader(logs)
+	for _, expected := range []string{"line1\n", "line2\n"} {
+		line, err := lines.ReadString('\n')
+		require.NoError(err)
+		require.Equal(expected, line)
+	}
+
+	// lines written after the end are read as they come
+	f, err := os.OpenFile(logPath, os.O_APPEND|os.O_WRONLY, 0o600)
+	require.NoError(err)
+	_, err = f.WriteString("line3\n")
+	require.NoError(err)
+	require.NoError(f.Close())
+	line, err := lines.ReadString('\n')
+	require.NoError(err)
+	require.Equal("line3\n", line)
+
+	// and from the new file once the log is rotated
+	require.NoError(os.Rename(logPath, filepath.Join(logsDir, "main.1.log")))
+	require.NoError(os.WriteFile(logPath, []byte("line4\n"), 0o600))
+	line, err = lines.ReadString('\n')
+	require.NoError(err)
+	require.Equal("line4\n", line)
+
+	cancel()
+	_, err = lines.ReadString('\n')
+	require.ErrorIs(err, io.EOF)
+}
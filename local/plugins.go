@@ -0,0 +1,121 @@
+// Copyright (C) 2021-2024, Lux Partners Limited. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package local
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/luxdefi/node/ids"
+)
+
+// dir next to the node binary where the node looks for plugins per default
+const defaultPluginSubdir = "plugins"
+
+var ErrPluginMismatch = errors.New("nodes don't agree on the required plugins")
+
+// Returns the plugins dir next to [binaryPath], or
+// the empty string if there is none
+func discoverPluginDir(binaryPath string) string {
+	binaryPath, err := exec.LookPath(binaryPath)
+	if err != nil {
+		return ""
+	}
+	binaryPath, err = filepath.Abs(binaryPath)
+	if err != nil {
+		return ""
+	}
+	pluginDir := filepath.Join(filepath.Dir(binaryPath), defaultPluginSubdir)
+	if info, err := os.Stat(pluginDir); err != nil || !info.IsDir() {
+		return ""
+	}
+	return pluginDir
+}
+
+// Checks that the running nodes with a known plugin dir have the plugins
+// of [vmIDs], and that they are the same binary on every node.
+// Returns ErrPluginMismatch with a report of the plugin hashes
+// of each node for the plugins that diverge.
+// Assumes [ln.lock] is held.
+func (ln *localNetwork) checkPlugins(vmIDs []ids.ID) error {
+	// nodes usually share a plugin dir, so each plugin is hashed once
+	pathHashes := map[string]string{}
+	checked := map[ids.ID]struct{}{}
+	reports := []string{}
+	for _, vmID := range vmIDs {
+		if _, ok := checked[vmID]; ok {
+			continue
+		}
+		checked[vmID] = struct{}{}
+		nodeHashes := map[string]string{}
+		hashes := map[string]struct{}{}
+		for nodeName, node := range ln.nodes {
+			if node.paused || node.pluginDir == "" {
+				continue
+			}
+			path := filepath.Join(node.pluginDir, vmID.String())
+			hash, ok := pathHashes[path]
+			if !ok {
+				var err error
+				hash, err = hashFile(path)
+				if err != nil && !errors.Is(err, fs.ErrNotExist) {
+					return fmt.Errorf("couldn't hash plugin of node %q: %w", nodeName, err)
+				}
+				pathHashes[path] = hash
+			}
+			nodeHashes[nodeName] = hash
+			hashes[hash] = struct{}{}
+		}
+		_, missing := hashes[""]
+		if missing || len(hashes) > 1 {
+			reports = append(reports, pluginReport(vmID, nodeHashes))
+		}
+	}
+	if len(reports) > 0 {
+		return fmt.Errorf("%w:\n%s", ErrPluginMismatch, strings.Join(reports, "\n"))
+	}
+	return nil
+}
+
+// Returns a line with the plugin hash of [vmID] on each node of
+// [nodeHashes], sorted by node name
+func pluginReport(vmID ids.ID, nodeHashes map[string]string) string {
+	nodeNames := make([]string, 0, len(nodeHashes))
+	for nodeName := range nodeHashes {
+		nodeNames = append(nodeNames, nodeName)
+	}
+	sort.Strings(nodeNames)
+	entries := make([]string, 0, len(nodeNames))
+	for _, nodeName := range nodeNames {
+		hash := nodeHashes[nodeName]
+		if hash == "" {
+			hash = "missing"
+		}
+		entries = append(entries, fmt.Sprintf("%s=%s", nodeName, hash))
+	}
+	return fmt.Sprintf("  vm %s: %s", vmID, strings.Join(entries, " "))
+}
+
+// Returns the hex encoded sha256 of the file at [path]
+func hashFile(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
@@ -0,0 +1,139 @@
+// Copyright (C) 2021-2024, Lux Partners Limited. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package local
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+
+	"github.com/luxdefi/netrunner/network"
+	"github.com/luxdefi/netrunner/utils"
+	"github.com/luxdefi/node/config"
+	"go.uber.org/zap"
+	"golang.org/x/exp/maps"
+)
+
+const (
+	// max number of times a network health check restarts
+	// nodes that failed to bind to their ports
+	maxPortCollisionRetries = 3
+	// node log file where bind errors are looked for
+	nodeMainLogFileName = "main.log"
+	// only the end of the log is scanned for bind errors
+	bindErrorLogTailSize = 64 * 1024
+)
+
+var (
+	bindErrorRegex = regexp.MustCompile(`:(\d+): bind: address already in use`)
+
+	errNoFreePortLeft = errors.New("no free port left in range")
+)
+
+// portCollisionError is returned by the health check when a node
+// stopped because one of its ports was taken after it was chosen
+type portCollisionError struct {
+	nodeName string
+	port     uint16
+}
+
+func (e *portCollisionError) Error() string {
+	return fmt.Sprintf("node %q stopped unexpectedly: port %d already in use", e.nodeName, e.port)
+}
+
+// Returns the port the node failed to bind to, if the end of
+// its main log at [logsDir] shows a bind error
+func findBindErrorPort(logsDir string) (uint16, bool) {
+	f, err := os.Open(filepath.Join(logsDir, nodeMainLogFileName))
+	if err != nil {
+		return 0, false
+	}
+	defer f.Close()
+	if info, err := f.Stat(); err == nil && info.Size() > bindErrorLogTailSize {
+		if _, err := f.Seek(-bindErrorLogTailSize, io.SeekEnd); err != nil {
+			return 0, false
+		}
+	}
+	tail, err := io.ReadAll(f)
+	if err != nil {
+		return 0, false
+	}
+	matches := bindErrorRegex.FindAllSubmatch(tail, -1)
+	if len(matches) == 0 {
+		return 0, false
+	}
+	port, err := strconv.ParseUint(string(matches[len(matches)-1][1]), 10, 16)
+	if err != nil {
+		return 0, false
+	}
+	return uint16(port), true
+}
+
+// Returns the first port after [port] that is free and not reserved
+// by a named network, wrapping around to [minPort] after [MaxPort]
+func nextFreePort(port uint16) (uint16, error) {
+	candidate := int(port)
+	for i := 0; i <= MaxPort-minPort; i++ {
+		candidate++
+		if candidate > MaxPort || candidate < minPort {
+			candidate = minPort
+		}
+		p := uint16(candidate)
+		if isFreePort(p) == nil && !reservedPorts.isReserved(p) {
+			return p, nil
+		}
+	}
+	return 0, errNoFreePortLeft
+}
+
+// Restarts the node of [collision] with its colliding port replaced by
+// the next free one. As the node is re-added, the bootstrap info of the
+// network gets its new P2P port.
+func (ln *localNetwork) retryPortCollision(ctx context.Context, collision *portCollisionError) error {
+	ln.lock.Lock()
+	defer ln.lock.Unlock()
+
+	if ln.stopCalled() {
+		return network.ErrStopped
+	}
+	node, ok := ln.nodes[collision.nodeName]
+	if !ok {
+		return collision
+	}
+	newPort, err := nextFreePort(collision.port)
+	if err != nil {
+		return fmt.Errorf("%s: %w", collision, err)
+	}
+	nodeConfig := node.GetConfig()
+	nodeConfig.Flags = maps.Clone(nodeConfig.Flags)
+	nodeConfig.Flags[config.DataDirKey] = node.GetDataDir()
+	nodeConfig.Flags[config.DBPathKey] = node.GetDbDir()
+	nodeConfig.Flags[config.LogsDirKey] = node.GetLogsDir()
+	nodeConfig.Flags[config.HTTPPortKey] = int(node.GetAPIPort())
+	nodeConfig.Flags[config.StakingPortKey] = int(node.GetP2PPort())
+	switch collision.port {
+	case node.GetAPIPort():
+		nodeConfig.Flags[config.HTTPPortKey] = int(newPort)
+	case node.GetP2PPort():
+		nodeConfig.Flags[config.StakingPortKey] = int(newPort)
+	default:
+		return collision
+	}
+	ln.log.Info("restarting node with new port after port collision",
+		utils.NodeField(collision.nodeName),
+		zap.Uint16("old-port", collision.port),
+		zap.Uint16("new-port", newPort),
+	)
+	// the process already exited, so the exit code is not checked
+	_ = ln.removeNode(ctx, collision.nodeName)
+	if _, err := ln.addNode(nodeConfig); err != nil {
+		return fmt.Errorf("couldn't restart node %q after port collision: %w", collision.nodeName, err)
+	}
+	return nil
+}
@@ -0,0 +1,138 @@
+// Copyright (C) 2021-2024, Lux Partners Limited. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package local
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/luxdefi/netrunner/network"
+	"go.uber.org/zap"
+	"golang.org/x/sync/errgroup"
+)
+
+// max bytes of a probe response read to look for the expected body
+const maxProbeBodySize = 1 << 20
+
+// See network.Network
+func (ln *localNetwork) AddReadinessProbe(probe network.ReadinessProbe) error {
+	if err := probe.Validate(); err != nil {
+		return err
+	}
+
+	ln.lock.Lock()
+	defer ln.lock.Unlock()
+
+	if ln.stopCalled() {
+		return network.ErrStopped
+	}
+	for _, p := range ln.readinessProbes {
+		if p.Name == probe.Name {
+			return fmt.Errorf("readiness probe %q already registered", probe.Name)
+		}
+	}
+	ln.readinessProbes = append(ln.readinessProbes, probe)
+	return nil
+}
+
+// Runs the readiness probes every [healthCheckFreq] until
+// all of them passed, [ctx] is done or the network is stopped
+func (ln *localNetwork) awaitReadiness(ctx context.Context) error {
+	ln.lock.RLock()
+	probes := append([]network.ReadinessProbe{}, ln.readinessProbes...)
+	ln.lock.RUnlock()
+
+	errGr, ctx := errgroup.WithContext(ctx)
+	for _, probe := range probes {
+		probe := probe
+		errGr.Go(func() error {
+			for {
+				err := ln.runProbe(ctx, probe)
+				if err == nil {
+					ln.log.Debug("readiness probe passed", zap.String("probe", probe.Name))
+					return nil
+				}
+				select {
+				case <-ln.onStopCh:
+					return network.ErrStopped
+				case <-ctx.Done():
+					return fmt.Errorf("readiness probe %q didn't pass within timeout: %w", probe.Name, err)
+				case <-time.After(healthCheckFreq):
+				}
+			}
+		})
+	}
+	return errGr.Wait()
+}
+
+// Runs [probe] once
+func (ln *localNetwork) runProbe(ctx context.Context, probe network.ReadinessProbe) error {
+	if probe.Check != nil {
+		return probe.Check(ctx, ln)
+	}
+	urls := []string{probe.URL}
+	if probe.IsPath() {
+		urls = ln.nodeProbeURLs(probe.URL)
+	}
+	for _, url := range urls {
+		if err := probeURL(ctx, url, probe.ExpectBody); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Returns [path] on the API of every running node
+func (ln *localNetwork) nodeProbeURLs(path string) []string {
+	ln.lock.RLock()
+	defer ln.lock.RUnlock()
+
+	urls := []string{}
+	for _, node := range ln.nodes {
+		if !node.paused {
+			urls = append(urls, node.GetAPIBaseURI()+path)
+		}
+	}
+	return urls
+}
+
+// Returns nil if a GET of [url] returns a 2xx status
+// and a body containing [expectBody]
+func probeURL(ctx context.Context, url string, expectBody string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxProbeBodySize))
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("%s returned status %d", url, resp.StatusCode)
+	}
+	if !strings.Contains(string(body), expectBody) {
+		return fmt.Errorf("%s response doesn't contain %q", url, expectBody)
+	}
+	return nil
+}
+
+// Returns the probes of [probes] that can be saved in a snapshot
+func snapshotProbes(probes []network.ReadinessProbe) []network.ReadinessProbe {
+	saved := []network.ReadinessProbe{}
+	for _, probe := range probes {
+		if probe.Check == nil {
+			saved = append(saved, probe)
+		}
+	}
+	return saved
+}
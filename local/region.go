@@ -0,0 +1,124 @@
+// Copyright (C) 2021-2024, Lux Partners Limited. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package local
+
+import (
+	"net"
+	"sync"
+	"time"
+
+	"github.com/luxdefi/netrunner/network"
+	psnet "github.com/shirou/gopsutil/net"
+)
+
+// regionLinks delays the P2P traffic between the regions of the network
+// topology. The region a connection comes from is the one of the node
+// process owning its remote end, found among the connections of the
+// node processes, as the nodes dial their peers from any local address.
+type regionLinks struct {
+	topology network.Topology
+
+	lock sync.RWMutex
+	// PID of a node process --> region of the node
+	regions map[int32]string
+}
+
+// Returns the links of [topology], or nil if it is nil
+func newRegionLinks(topology *network.Topology) *regionLinks {
+	if topology == nil {
+		return nil
+	}
+	return &regionLinks{
+		topology: *topology,
+		regions:  map[int32]string{},
+	}
+}
+
+// Returns the topology of the links, or nil if there are none
+func (r *regionLinks) getTopology() *network.Topology {
+	if r == nil {
+		return nil
+	}
+	topology := r.topology
+	return &topology
+}
+
+// Records that the process [pid] is a node of [region]
+func (r *regionLinks) addNode(pid int, region string) {
+	if pid == 0 {
+		return
+	}
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	r.regions[int32(pid)] = region
+}
+
+func (r *regionLinks) removeNode(pid int) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	delete(r.regions, int32(pid))
+}
+
+// Returns the latency of a connection from [remote] to a node of
+// [region], if [remote] belongs to a node of a region linked to it
+func (r *regionLinks) latency(region string, remote net.Addr) (time.Duration, bool) {
+	from, ok := r.regionOf(remote)
+	if !ok {
+		return 0, false
+	}
+	return r.topology.Latency(from, region)
+}
+
+// Returns the region of the node process with a connection from [addr]
+func (r *regionLinks) regionOf(addr net.Addr) (string, bool) {
+	r.lock.RLock()
+	regions := make(map[int32]string, len(r.regions))
+	for pid, region := range r.regions {
+		regions[pid] = region
+	}
+	r.lock.RUnlock()
+
+	return connOwner(addr, regions)
+}
+
+// Returns the value in [owners] of the process, among its keys, with a
+// connection from [addr], that is the process owning the remote end of
+// a connection accepted from [addr]
+func connOwner(addr net.Addr, owners map[int32]string) (string, bool) {
+	tcpAddr, ok := addr.(*net.TCPAddr)
+	if !ok {
+		return "", false
+	}
+	for pid, owner := range owners {
+		conns, err := psnet.ConnectionsPid("tcp", pid)
+		if err != nil {
+			continue
+		}
+		for _, conn := range conns {
+			if conn.Laddr.Port == uint32(tcpAddr.Port) && net.ParseIP(conn.Laddr.IP).Equal(tcpAddr.IP) {
+				return owner, true
+			}
+		}
+	}
+	return "", false
+}
+
+// Starts tracking the region of the process of [node], if any,
+// so that its connections to other regions are delayed.
+// Assumes [ln.lock] is held.
+func (ln *localNetwork) trackRegion(node *localNode) {
+	if ln.regionLinks != nil && node.config.Region != "" {
+		ln.regionLinks.addNode(node.process.PID(), node.config.Region)
+	}
+}
+
+// Stops tracking the region of the process of [node], if any.
+// Assumes [ln.lock] is held.
+func (ln *localNetwork) untrackRegion(node *localNode) {
+	if ln.regionLinks != nil && node.config.Region != "" {
+		ln.regionLinks.removeNode(node.process.PID())
+	}
+}
@@ -0,0 +1,154 @@
+// Copyright (C) 2021-2024, Lux Partners Limited. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package local
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+
+	"github.com/luxdefi/netrunner/network"
+	"github.com/luxdefi/netrunner/network/node"
+	"github.com/luxdefi/netrunner/utils"
+	"github.com/luxdefi/node/config"
+	"golang.org/x/exp/maps"
+)
+
+// dir under the network root dir where node config backups are kept
+const backupsDirName = "backups"
+
+var ErrNoBackup = errors.New("no backup to roll back to")
+
+// nodeBackup is the config of a node before its last restart
+type nodeBackup struct {
+	config node.Config
+	// copy of the config files of the node data dir
+	filesDir string
+}
+
+// Keeps the config and config files of [nodeName] before it is restarted,
+// so the restart can be rolled back. Db and logs are not backed up.
+// Assumes [ln.lock] is held.
+func (ln *localNetwork) backupNode(nodeName string) error {
+	node := ln.nodes[nodeName]
+	nodeConfig := node.GetConfig()
+	nodeConfig.Flags = maps.Clone(nodeConfig.Flags)
+	nodeConfig.ChainConfigFiles = maps.Clone(nodeConfig.ChainConfigFiles)
+	nodeConfig.UpgradeConfigFiles = maps.Clone(nodeConfig.UpgradeConfigFiles)
+	nodeConfig.SubnetConfigFiles = maps.Clone(nodeConfig.SubnetConfigFiles)
+
+	filesDir := filepath.Join(ln.rootDir, backupsDirName, nodeName)
+	if err := os.RemoveAll(filesDir); err != nil {
+		return err
+	}
+	if err := copyConfigFiles(node.GetDataDir(), filesDir); err != nil {
+		return fmt.Errorf("couldn't back up config files of node %q: %w", nodeName, err)
+	}
+	ln.backups[nodeName] = &nodeBackup{
+		config:   nodeConfig,
+		filesDir: filesDir,
+	}
+	return nil
+}
+
+// See network.Network
+func (ln *localNetwork) RollbackNode(ctx context.Context, nodeName string) (err error) {
+	defer utils.StartOperation(ln.log, "rollback-node", utils.NodeField(nodeName))(&err)
+
+	ln.lock.Lock()
+	defer ln.lock.Unlock()
+
+	if ln.stopCalled() {
+		return network.ErrStopped
+	}
+	node, ok := ln.nodes[nodeName]
+	if !ok {
+		return fmt.Errorf("%w: %q", network.ErrNodeNotFound, nodeName)
+	}
+	backup, ok := ln.backups[nodeName]
+	if !ok {
+		return fmt.Errorf("%w for node %q", ErrNoBackup, nodeName)
+	}
+	if !node.paused {
+		// the node may have already exited, so the exit code is not checked
+		_ = ln.removeNode(ctx, nodeName)
+	}
+	if err := restoreConfigFiles(backup.filesDir, node.GetDataDir()); err != nil {
+		return fmt.Errorf("couldn't restore config files of node %q: %w", nodeName, err)
+	}
+	nodeConfig := backup.config
+	nodeConfig.Flags[config.DataDirKey] = node.GetDataDir()
+	nodeConfig.Flags[config.DBPathKey] = node.GetDbDir()
+	nodeConfig.Flags[config.LogsDirKey] = node.GetLogsDir()
+	nodeConfig.Flags[config.HTTPPortKey] = int(node.GetAPIPort())
+	nodeConfig.Flags[config.StakingPortKey] = int(node.GetP2PPort())
+	if _, err := ln.addNode(nodeConfig); err != nil {
+		return fmt.Errorf("couldn't restart node %q with its previous config: %w", nodeName, err)
+	}
+	ln.nodes[nodeName].inheritRuntime(node)
+	delete(ln.backups, nodeName)
+	ln.log.Info("rolled back node config", utils.NodeField(nodeName))
+	return nil
+}
+
+// Copies the files of [dataDir] the node is started with: the top level
+// files and the chain and subnet config dirs
+func copyConfigFiles(dataDir string, dstDir string) error {
+	entries, err := os.ReadDir(dataDir)
+	if err != nil {
+		return err
+	}
+	for _, entry := range entries {
+		src := filepath.Join(dataDir, entry.Name())
+		dst := filepath.Join(dstDir, entry.Name())
+		switch {
+		case entry.Type().IsRegular():
+			if err := copyFile(src, dst); err != nil {
+				return err
+			}
+		case entry.IsDir() && (entry.Name() == chainConfigSubDir || entry.Name() == subnetConfigSubDir):
+			if err := copyDir(src, dst); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Replaces the config files of [dataDir] with the ones backed up at [backupDir]
+func restoreConfigFiles(backupDir string, dataDir string) error {
+	for _, subdir := range []string{chainConfigSubDir, subnetConfigSubDir} {
+		if err := os.RemoveAll(filepath.Join(dataDir, subdir)); err != nil {
+			return err
+		}
+	}
+	return copyConfigFiles(backupDir, dataDir)
+}
+
+func copyDir(srcDir string, dstDir string) error {
+	return filepath.WalkDir(srcDir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		relPath, err := filepath.Rel(srcDir, path)
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return os.MkdirAll(filepath.Join(dstDir, relPath), 0o750)
+		}
+		return copyFile(path, filepath.Join(dstDir, relPath))
+	})
+}
+
+func copyFile(src string, dst string) error {
+	contents, err := os.ReadFile(src)
+	if err != nil {
+		return err
+	}
+	return createFileAndWrite(dst, contents)
+}
@@ -13,12 +13,14 @@ import (
 	"github.com/luxdefi/netrunner/api"
 	"github.com/luxdefi/netrunner/network"
 	"github.com/luxdefi/netrunner/network/node"
+	"github.com/luxdefi/netrunner/nodeconfig"
 	"github.com/luxdefi/netrunner/utils"
 	"github.com/luxdefi/node/config"
 	"github.com/luxdefi/node/ids"
 	"github.com/luxdefi/node/utils/constants"
 	"github.com/luxdefi/node/utils/logging"
 	dircopy "github.com/otiai10/copy"
+	"go.uber.org/zap"
 	"golang.org/x/exp/maps"
 )
 
@@ -80,6 +82,7 @@ func NewNetworkFromSnapshot(
 			log:         log,
 			stdout:      os.Stdout,
 			stderr:      os.Stderr,
+			consoles:    newConsoleHub(),
 		},
 		rootDir,
 		snapshotsDir,
@@ -103,26 +106,48 @@ func NewNetworkFromSnapshot(
 
 // Save network snapshot
 // Network is stopped in order to do a safe preservation
-func (ln *localNetwork) SaveSnapshot(ctx context.Context, snapshotName string) (string, error) {
+func (ln *localNetwork) SaveSnapshot(ctx context.Context, snapshotName string) (_ string, err error) {
+	defer utils.StartOperation(ln.log, "save-snapshot", zap.String("snapshot-name", snapshotName))(&err)
+
 	ln.lock.Lock()
 	defer ln.lock.Unlock()
 
 	if ln.stopCalled() {
 		return "", network.ErrStopped
 	}
+	return ln.saveSnapshot(ctx, snapshotName)
+}
+
+// Assumes [ln.lock] is held and [ln.Stop] hasn't been called.
+func (ln *localNetwork) saveSnapshot(ctx context.Context, snapshotName string) (string, error) {
 	if len(snapshotName) == 0 {
 		return "", fmt.Errorf("invalid snapshotName %q", snapshotName)
 	}
+	unlock, err := lockSnapshotsDir(ln.snapshotsDir, true)
+	if err != nil {
+		return "", err
+	}
+	defer unlock()
 	// check if snapshot already exists
-	snapshotDir := filepath.Join(ln.snapshotsDir, snapshotPrefix+snapshotName)
-	if _, err := os.Stat(snapshotDir); err == nil {
+	finalSnapshotDir := filepath.Join(ln.snapshotsDir, snapshotPrefix+snapshotName)
+	if _, err := os.Stat(finalSnapshotDir); err == nil {
 		return "", fmt.Errorf("snapshot %q already exists", snapshotName)
 	}
+	// write the snapshot into a temporary dir, moved to its final location
+	// when complete, so that a failed save never leaves a partial snapshot
+	snapshotDir, err := os.MkdirTemp(ln.snapshotsDir, tmpSnapshotPrefix+snapshotName+"-")
+	if err != nil {
+		return "", err
+	}
+	defer os.RemoveAll(snapshotDir)
 	// keep copy of node info that will be removed by stop
 	nodesConfig := map[string]node.Config{}
 	nodesDBDir := map[string]string{}
 	for nodeName, node := range ln.nodes {
 		nodeConfig := node.config
+		if !hasPersistentDB(nodeConfig) {
+			return "", fmt.Errorf("node %q uses an in memory db, which can't be snapshotted", nodeName)
+		}
 		// depending on how the user generated the config, different nodes config flags
 		// may point to the same map, so we made a copy to avoid always modifying the same value
 		nodeConfig.Flags = maps.Clone(nodeConfig.Flags)
@@ -176,6 +201,7 @@ func (ln *localNetwork) SaveSnapshot(ctx context.Context, snapshotName string) (
 	}
 	// save network conf
 	networkConfig := network.Config{
+		Name:               ln.name,
 		Genesis:            string(ln.genesis),
 		Flags:              networkConfigFlags,
 		NodeConfigs:        []node.Config{},
@@ -183,6 +209,18 @@ func (ln *localNetwork) SaveSnapshot(ctx context.Context, snapshotName string) (
 		ChainConfigFiles:   ln.chainConfigFiles,
 		UpgradeConfigFiles: ln.upgradeConfigFiles,
 		SubnetConfigFiles:  ln.subnetConfigFiles,
+		LoopbackAliases:    ln.loopbackAliases,
+		IPv6:               ln.ipv6,
+		DiskBudget:         ln.diskBudget,
+		Topology:           ln.regionLinks.getTopology(),
+		NodeNaming:         ln.nodeNaming,
+		DBRootDir:          ln.dbRootDir,
+		LogsRootDir:        ln.logsRootDir,
+		KeysRootDir:        ln.keysRootDir,
+		Beacons:            ln.beacons,
+		ReadinessProbes:    snapshotProbes(ln.readinessProbes),
+		Labels:             ln.labels,
+		APIClientOptions:   ln.apiClientOptions,
 	}
 
 	// no need to save this, will be generated automatically on snapshot load
@@ -209,7 +247,10 @@ func (ln *localNetwork) SaveSnapshot(ctx context.Context, snapshotName string) (
 	if err := createFileAndWrite(filepath.Join(snapshotDir, "state.json"), networkStateJSON); err != nil {
 		return "", err
 	}
-	return snapshotDir, nil
+	if err := os.Rename(snapshotDir, finalSnapshotDir); err != nil {
+		return "", fmt.Errorf("failure moving snapshot to %q: %w", finalSnapshotDir, err)
+	}
+	return finalSnapshotDir, nil
 }
 
 // start network from snapshot
@@ -226,9 +267,15 @@ func (ln *localNetwork) loadSnapshot(
 	ln.lock.Lock()
 	defer ln.lock.Unlock()
 
+	unlock, err := lockSnapshotsDir(ln.snapshotsDir, false)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
 	snapshotDir := filepath.Join(ln.snapshotsDir, snapshotPrefix+snapshotName)
 	snapshotDBDir := filepath.Join(snapshotDir, defaultDBSubdir)
-	_, err := os.Stat(snapshotDir)
+	_, err = os.Stat(snapshotDir)
 	if err != nil {
 		if errors.Is(err, os.ErrNotExist) {
 			return ErrSnapshotNotFound
@@ -256,6 +303,9 @@ func (ln *localNetwork) loadSnapshot(
 	}
 	// add flags
 	for i := range networkConfig.NodeConfigs {
+		if err := checkSnapshotDBType(networkConfig.NodeConfigs[i], flags); err != nil {
+			return err
+		}
 		for k, v := range flags {
 			networkConfig.NodeConfigs[i].Flags[k] = v
 		}
@@ -263,7 +313,7 @@ func (ln *localNetwork) loadSnapshot(
 	// load db
 	for _, nodeConfig := range networkConfig.NodeConfigs {
 		sourceDBDir := filepath.Join(snapshotDBDir, nodeConfig.Name)
-		targetDBDir := filepath.Join(filepath.Join(ln.rootDir, nodeConfig.Name), defaultDBSubdir)
+		targetDBDir := nodeconfig.VolumeDir(networkConfig.DBRootDir, nodeConfig.Name, filepath.Join(ln.rootDir, nodeConfig.Name), defaultDBSubdir)
 		if err := dircopy.Copy(sourceDBDir, targetDBDir); err != nil {
 			return fmt.Errorf("failure loading node %q db dir: %w", nodeConfig.Name, err)
 		}
@@ -332,8 +382,14 @@ func (ln *localNetwork) loadSnapshot(
 
 // Remove network snapshot
 func (ln *localNetwork) RemoveSnapshot(snapshotName string) error {
+	unlock, err := lockSnapshotsDir(ln.snapshotsDir, true)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
 	snapshotDir := filepath.Join(ln.snapshotsDir, snapshotPrefix+snapshotName)
-	_, err := os.Stat(snapshotDir)
+	_, err = os.Stat(snapshotDir)
 	if err != nil {
 		if errors.Is(err, os.ErrNotExist) {
 			return ErrSnapshotNotFound
@@ -347,6 +403,16 @@ func (ln *localNetwork) RemoveSnapshot(snapshotName string) error {
 	return nil
 }
 
+// SnapshotExists returns true if [snapshotsDir], or the
+// default snapshots dir if empty, holds the snapshot [snapshotName]
+func SnapshotExists(snapshotsDir string, snapshotName string) bool {
+	if snapshotsDir == "" {
+		snapshotsDir = defaultSnapshotsDir
+	}
+	info, err := os.Stat(filepath.Join(snapshotsDir, snapshotPrefix+snapshotName))
+	return err == nil && info.IsDir()
+}
+
 // Get network snapshots
 func (ln *localNetwork) GetSnapshotNames() ([]string, error) {
 	_, err := os.Stat(ln.snapshotsDir)
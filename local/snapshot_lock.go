@@ -0,0 +1,57 @@
+// Copyright (C) 2021-2024, Lux Partners Limited. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package local
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"syscall"
+)
+
+const (
+	snapshotsLockFileName = ".lock"
+	// prefix of the dirs where snapshots are written before
+	// being moved to their final location
+	tmpSnapshotPrefix = ".tmp-" + snapshotPrefix
+)
+
+// NetworkSnapshotsDir returns the dir under [snapshotsDir] where the
+// snapshots of the network named [networkName] are kept
+func NetworkSnapshotsDir(snapshotsDir string, networkName string) string {
+	if snapshotsDir == "" {
+		snapshotsDir = defaultSnapshotsDir
+	}
+	if networkName == "" {
+		return snapshotsDir
+	}
+	return filepath.Join(snapshotsDir, networkName)
+}
+
+// lockSnapshotsDir takes an advisory file lock on [snapshotsDir], shared
+// among readers if [exclusive] is false, so that different processes
+// and networks don't read a snapshot that is being written or removed.
+// Blocks until the lock is taken. Returns the function that releases it.
+func lockSnapshotsDir(snapshotsDir string, exclusive bool) (func(), error) {
+	if err := os.MkdirAll(snapshotsDir, os.ModePerm); err != nil {
+		return nil, err
+	}
+	lockPath := filepath.Join(snapshotsDir, snapshotsLockFileName)
+	f, err := os.OpenFile(lockPath, os.O_CREATE|os.O_RDWR, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't open snapshots lock file: %w", err)
+	}
+	how := syscall.LOCK_SH
+	if exclusive {
+		how = syscall.LOCK_EX
+	}
+	if err := syscall.Flock(int(f.Fd()), how); err != nil {
+		_ = f.Close()
+		return nil, fmt.Errorf("couldn't lock snapshots dir %q: %w", snapshotsDir, err)
+	}
+	return func() {
+		_ = syscall.Flock(int(f.Fd()), syscall.LOCK_UN)
+		_ = f.Close()
+	}, nil
+}
@@ -0,0 +1,109 @@
+// Copyright (C) 2021-2024, Lux Partners Limited. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package local
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/luxdefi/netrunner/network/node"
+	"github.com/luxdefi/node/ids"
+)
+
+// max number of node processes started at the same time on network creation
+const maxParallelNodeStarts = 8
+
+// nodeLaunch is a node prepared by [ln.prepareNode], whose process
+// is started by [ln.launchNode] before being added to the network
+type nodeLaunch struct {
+	config        node.Config
+	processConfig node.Config
+	data          buildArgsReturn
+	nodeID        ids.NodeID
+	// true if the node was added to the beacons on preparation
+	isNewBeacon bool
+
+	// set on launch
+	process      NodeProcess
+	startedAt    time.Time
+	processSpawn time.Duration
+}
+
+// Adds the nodes of [nodeConfigs], starting up to [maxParallelNodeStarts]
+// node processes at a time. The nodes are prepared one at a time and in
+// order, as the beacons must be known by the nodes bootstrapping from them.
+// If some nodes fail, the errors of all of them are returned, and the nodes
+// started are added anyway so that they are stopped along the network.
+// Assumes [ln.lock] is held and [ln.Stop] hasn't been called.
+func (ln *localNetwork) addNodes(nodeConfigs []node.Config) error {
+	// reserve the names of the batch, as no node is registered until
+	// all of them are launched
+	ln.preparedNodeNames = make(map[string]struct{}, len(nodeConfigs))
+	defer func() {
+		ln.preparedNodeNames = nil
+	}()
+	launches := make([]*nodeLaunch, 0, len(nodeConfigs))
+	for _, nodeConfig := range nodeConfigs {
+		launch, err := ln.prepareNode(nodeConfig)
+		if err != nil {
+			for _, launch := range launches {
+				ln.abortLaunch(launch)
+				ln.releasePorts(launch.data.apiPort, launch.data.p2pPort)
+			}
+			return fmt.Errorf("error adding node %s: %w", nodeConfig.Name, err)
+		}
+		ln.preparedNodeNames[launch.config.Name] = struct{}{}
+		launches = append(launches, launch)
+	}
+
+	launchErrs := make([]error, len(launches))
+	sem := make(chan struct{}, maxParallelNodeStarts)
+	wg := sync.WaitGroup{}
+	for i, launch := range launches {
+		i, launch := i, launch
+		sem <- struct{}{}
+		wg.Add(1)
+		go func() {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			launchErrs[i] = ln.launchNode(launch)
+		}()
+	}
+	wg.Wait()
+
+	errs := []error{}
+	for i, launch := range launches {
+		err := launchErrs[i]
+		if err == nil {
+			_, err = ln.registerNode(launch)
+		} else {
+			ln.abortLaunch(launch)
+			ln.releasePorts(launch.data.apiPort, launch.data.p2pPort)
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("error adding node %s: %w", launch.config.Name, err))
+		}
+	}
+	return joinStartErrors(errs)
+}
+
+// Returns an error wrapping the first of [errs] and
+// describing the others, or nil if there are none
+func joinStartErrors(errs []error) error {
+	switch len(errs) {
+	case 0:
+		return nil
+	case 1:
+		return errs[0]
+	}
+	others := make([]string, 0, len(errs)-1)
+	for _, err := range errs[1:] {
+		others = append(others, err.Error())
+	}
+	return fmt.Errorf("%w; %d more nodes failed: %s", errs[0], len(others), strings.Join(others, "; "))
+}
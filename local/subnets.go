@@ -0,0 +1,115 @@
+// Copyright (C) 2021-2024, Lux Partners Limited. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package local
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/luxdefi/netrunner/network"
+	"github.com/luxdefi/netrunner/utils"
+	"github.com/luxdefi/node/config"
+	"github.com/luxdefi/node/ids"
+	"go.uber.org/zap"
+	"golang.org/x/exp/slices"
+)
+
+// See network.Network
+func (ln *localNetwork) TrackSubnet(ctx context.Context, nodeNames []string, subnetID ids.ID) (err error) {
+	defer utils.StartOperation(ln.log, "track-subnet", zap.Stringer("subnet-id", subnetID))(&err)
+
+	ln.lock.Lock()
+	defer ln.lock.Unlock()
+
+	if ln.stopCalled() {
+		return network.ErrStopped
+	}
+	for _, nodeName := range nodeNames {
+		if _, ok := ln.nodes[nodeName]; !ok {
+			return fmt.Errorf("%w: %q", network.ErrNodeNotFound, nodeName)
+		}
+	}
+	restarted := false
+	for _, nodeName := range nodeNames {
+		node := ln.nodes[nodeName]
+		nodeConfig := node.GetConfig()
+		// the flag of a paused node may have changed since it was started
+		v, ok := nodeConfig.Flags[config.TrackSubnetsKey]
+		if !ok {
+			flag, err := node.GetFlag(config.TrackSubnetsKey)
+			if err != nil {
+				return err
+			}
+			v = flag.Value()
+		}
+		trackedSubnets, err := parseTrackedSubnets(v)
+		if err != nil {
+			return fmt.Errorf("node %q: %w", nodeName, err)
+		}
+		if slices.Contains(trackedSubnets, subnetID) {
+			continue
+		}
+		tracked := joinSubnetIDs(append(trackedSubnets, subnetID))
+		if node.paused {
+			// tracked once resumed
+			nodeConfig.Flags[config.TrackSubnetsKey] = tracked
+			continue
+		}
+		ln.log.Info("restarting node to track subnets", utils.NodeField(nodeName), zap.String("track-subnets", tracked))
+		if err := ln.restartNode(ctx, nodeName, "", "", tracked, nil, nil, nil); err != nil {
+			return err
+		}
+		restarted = true
+	}
+	if !restarted {
+		return nil
+	}
+	return ln.healthy(ctx)
+}
+
+// See node.Node
+func (node *localNode) GetTrackedSubnets() ([]ids.ID, error) {
+	flag, err := node.GetFlag(config.TrackSubnetsKey)
+	if err != nil {
+		return nil, err
+	}
+	return parseTrackedSubnets(flag.Value())
+}
+
+// Returns the subnet IDs of the track-subnets flag value [v],
+// a comma-separated list, in its order
+func parseTrackedSubnets(v interface{}) ([]ids.ID, error) {
+	if v == nil {
+		return nil, nil
+	}
+	s, ok := v.(string)
+	if !ok {
+		return nil, fmt.Errorf("expected flag %q to be string but got %T", config.TrackSubnetsKey, v)
+	}
+	subnetIDs := []ids.ID{}
+	for _, subnetIDStr := range strings.Split(s, ",") {
+		subnetIDStr = strings.TrimSpace(subnetIDStr)
+		if subnetIDStr == "" {
+			continue
+		}
+		subnetID, err := ids.FromString(subnetIDStr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid subnet ID %q in flag %q: %w", subnetIDStr, config.TrackSubnetsKey, err)
+		}
+		subnetIDs = append(subnetIDs, subnetID)
+	}
+	return subnetIDs, nil
+}
+
+// Returns [subnetIDs] as a track-subnets flag value, sorted
+func joinSubnetIDs(subnetIDs []ids.ID) string {
+	strs := make([]string, 0, len(subnetIDs))
+	for _, subnetID := range subnetIDs {
+		strs = append(strs, subnetID.String())
+	}
+	sort.Strings(strs)
+	return strings.Join(strs, ",")
+}
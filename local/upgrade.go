@@ -0,0 +1,120 @@
+// Copyright (C) 2021-2024, Lux Partners Limited. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package local
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/luxdefi/netrunner/network"
+	"github.com/luxdefi/netrunner/utils"
+	"go.uber.org/zap"
+)
+
+// default max time for an upgraded node to become healthy
+const defaultUpgradeNodeTimeout = 5 * time.Minute
+
+// See network.Network
+// The health of a node includes the bootstrapping of its chains, so an
+// upgraded node is healthy once it re-bootstrapped. The network is only
+// locked while a node is upgraded, so it can be observed in between.
+func (ln *localNetwork) UpgradeNodes(ctx context.Context, binaryPath string, opts network.UpgradeOptions) (err error) {
+	defer utils.StartOperation(ln.log, "upgrade-nodes", zap.String("binary-path", binaryPath))(&err)
+
+	if err := utils.CheckExecPath(binaryPath); err != nil {
+		return err
+	}
+	nodeNames, err := ln.upgradeOrder(opts.NodeNames)
+	if err != nil {
+		return err
+	}
+	nodeTimeout := opts.NodeTimeout
+	if nodeTimeout == 0 {
+		nodeTimeout = defaultUpgradeNodeTimeout
+	}
+	for i, nodeName := range nodeNames {
+		if i > 0 && opts.Interval > 0 {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(opts.Interval):
+			}
+		}
+		if err := ln.upgradeNode(ctx, nodeName, binaryPath, opts.PluginDir, nodeTimeout); err != nil {
+			if opts.RollbackOnFailure {
+				rollbackCtx, cancel := context.WithTimeout(context.Background(), nodeTimeout)
+				if rollbackErr := ln.RollbackNode(rollbackCtx, nodeName); rollbackErr != nil {
+					ln.log.Warn("couldn't roll back node", utils.NodeField(nodeName), zap.Error(rollbackErr))
+				}
+				cancel()
+			}
+			return fmt.Errorf("upgrade stopped at node %q, after upgrading %v: %w", nodeName, nodeNames[:i], err)
+		}
+		ln.log.Info("upgraded node",
+			utils.NodeField(nodeName),
+			zap.Int("upgraded", i+1),
+			zap.Int("total", len(nodeNames)),
+		)
+	}
+	return nil
+}
+
+// Returns [nodeNames] if they are all running nodes,
+// or else all the nodes not paused if [nodeNames] is empty
+func (ln *localNetwork) upgradeOrder(nodeNames []string) ([]string, error) {
+	ln.lock.RLock()
+	defer ln.lock.RUnlock()
+
+	if ln.stopCalled() {
+		return nil, network.ErrStopped
+	}
+	if len(nodeNames) == 0 {
+		for name, node := range ln.nodes {
+			if !node.paused {
+				nodeNames = append(nodeNames, name)
+			}
+		}
+		sort.Strings(nodeNames)
+		return nodeNames, nil
+	}
+	for _, name := range nodeNames {
+		node, ok := ln.nodes[name]
+		if !ok {
+			return nil, fmt.Errorf("%w: %q", network.ErrNodeNotFound, name)
+		}
+		if node.paused {
+			return nil, fmt.Errorf("node %q is paused", name)
+		}
+	}
+	return nodeNames, nil
+}
+
+// Restarts [nodeName] with [binaryPath] and waits until it is healthy,
+// for up to [timeout]
+func (ln *localNetwork) upgradeNode(
+	ctx context.Context,
+	nodeName string,
+	binaryPath string,
+	pluginDir string,
+	timeout time.Duration,
+) error {
+	ln.lock.Lock()
+	defer ln.lock.Unlock()
+
+	if ln.stopCalled() {
+		return network.ErrStopped
+	}
+	if _, ok := ln.nodes[nodeName]; !ok {
+		return fmt.Errorf("%w: %q", network.ErrNodeNotFound, nodeName)
+	}
+	ln.log.Info("upgrading node", utils.NodeField(nodeName))
+	if err := ln.restartNode(ctx, nodeName, binaryPath, pluginDir, "", nil, nil, nil); err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	return ln.waitNodeHealthy(ctx, nodeName)
+}
@@ -0,0 +1,212 @@
+// Copyright (C) 2021-2024, Lux Partners Limited. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package local
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"time"
+
+	"github.com/luxdefi/netrunner/network"
+	"github.com/luxdefi/netrunner/network/node"
+	"github.com/luxdefi/netrunner/utils"
+	"github.com/luxdefi/node/genesis"
+	"github.com/luxdefi/node/ids"
+	"github.com/luxdefi/node/utils/constants"
+	"github.com/luxdefi/node/utils/crypto/bls"
+	"github.com/luxdefi/node/vms/platformvm"
+	"github.com/luxdefi/node/vms/platformvm/signer"
+	"github.com/luxdefi/node/vms/platformvm/txs"
+	"github.com/luxdefi/node/vms/secp256k1fx"
+	"github.com/luxdefi/node/wallet/subnet/primary/common"
+	"go.uber.org/zap"
+)
+
+// max time AddNode and AddPrimaryValidator wait for a node to become a primary network validator
+const primaryValidatorTimeout = 5 * time.Minute
+
+// See network.Network
+func (ln *localNetwork) AddPrimaryValidator(ctx context.Context, nodeName string, cfg node.PrimaryValidatorConfig) (err error) {
+	defer utils.StartOperation(ln.log, "add-primary-validator", utils.NodeField(nodeName))(&err)
+
+	if err := cfg.Validate(); err != nil {
+		return err
+	}
+	if err := ln.checkRunningNode(nodeName); err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(ctx, primaryValidatorTimeout)
+	defer cancel()
+	return ln.registerPrimaryValidator(ctx, nodeName, cfg)
+}
+
+// Returns an error if [ln.Stop] was called, or if the node [nodeName]
+// doesn't exist or is paused or frozen
+func (ln *localNetwork) checkRunningNode(nodeName string) error {
+	ln.lock.RLock()
+	defer ln.lock.RUnlock()
+
+	if ln.stopCalled() {
+		return network.ErrStopped
+	}
+	node, ok := ln.nodes[nodeName]
+	if !ok {
+		return fmt.Errorf("%w: %q", network.ErrNodeNotFound, nodeName)
+	}
+	if node.paused || node.frozen {
+		return fmt.Errorf("node %q is not running", nodeName)
+	}
+	return nil
+}
+
+// Registers the node [nodeName] as a primary network validator,
+// as given by [cfg], and waits until it is validating.
+// Assumes [ln.lock] is not held, as the wait can take minutes: the lock
+// is only taken to read the network state.
+func (ln *localNetwork) registerPrimaryValidator(ctx context.Context, nodeName string, cfg node.PrimaryValidatorConfig) error {
+	// the node must be up so its validation can start
+	if err := ln.Healthy(ctx); err != nil {
+		return err
+	}
+	ln.lock.RLock()
+	if ln.stopCalled() {
+		ln.lock.RUnlock()
+		return network.ErrStopped
+	}
+	node, ok := ln.nodes[nodeName]
+	if !ok {
+		ln.lock.RUnlock()
+		return fmt.Errorf("%w: %q", network.ErrNodeNotFound, nodeName)
+	}
+	nodeID := node.GetNodeID()
+	clientURI, err := ln.getClientURI()
+	ln.lock.RUnlock()
+	if err != nil {
+		return err
+	}
+	platformCli := platformvm.NewClient(clientURI)
+	isValidator, err := isPrimaryValidator(ctx, platformCli, nodeID)
+	if err != nil {
+		return err
+	}
+	if isValidator {
+		return nil
+	}
+
+	stake := cfg.Stake
+	if stake == 0 {
+		stake = genesis.LocalParams.MinValidatorStake
+	}
+	start := time.Now().Add(validationStartOffset)
+	end := time.Now().Add(validationDuration)
+	if cfg.Duration != 0 {
+		end = start.Add(cfg.Duration)
+	}
+	delegationFee := cfg.DelegationFee
+	if delegationFee == 0 {
+		delegationFee = defaultDelegationFee
+	}
+	w, err := newWallet(ctx, clientURI, nil)
+	if err != nil {
+		return err
+	}
+	txID, err := issuePrimaryValidatorTx(ctx, w, node, stake, start, end, delegationFee)
+	if err != nil {
+		return err
+	}
+	ln.log.Info("added node as primary subnet validator",
+		utils.NodeField(nodeName),
+		zap.Stringer("node-ID", nodeID),
+		zap.Stringer("tx-ID", txID),
+		zap.Uint64("stake", stake),
+		zap.Time("end", end),
+	)
+
+	// the validation starts after [validationStartOffset]
+	for {
+		isValidator, err := isPrimaryValidator(ctx, platformCli, nodeID)
+		if err != nil {
+			return err
+		}
+		if isValidator {
+			return nil
+		}
+		select {
+		case <-ln.onStopCh:
+			return errAborted
+		case <-ctx.Done():
+			return fmt.Errorf("node %q didn't become a primary network validator: %w", nodeName, ctx.Err())
+		case <-time.After(waitForValidatorsPullFrequency):
+		}
+	}
+}
+
+// Issues the tx adding [node] as a primary network validator staking
+// [stake] from [start] to [end], signing its BLS proof of possession
+func issuePrimaryValidatorTx(
+	ctx context.Context,
+	w *wallet,
+	node node.Node,
+	stake uint64,
+	start time.Time,
+	end time.Time,
+	delegationFee uint32,
+) (ids.ID, error) {
+	nodeID := node.GetNodeID()
+	// It is important to note that this will ONLY register BLS signers for
+	// nodes registered AFTER genesis.
+	blsKeyBytes, err := base64.StdEncoding.DecodeString(node.GetConfig().StakingSigningKey)
+	if err != nil {
+		return ids.Empty, err
+	}
+	blsSk, err := bls.SecretKeyFromBytes(blsKeyBytes)
+	if err != nil {
+		return ids.Empty, err
+	}
+	proofOfPossession := signer.NewProofOfPossession(blsSk)
+	owner := &secp256k1fx.OutputOwners{
+		Threshold: 1,
+		Addrs:     []ids.ShortID{w.addr},
+	}
+	cctx, cancel := createDefaultCtx(ctx)
+	defer cancel()
+	txID, err := w.pWallet.IssueAddPermissionlessValidatorTx(
+		&txs.SubnetValidator{
+			Validator: txs.Validator{
+				NodeID: nodeID,
+				Start:  uint64(start.Unix()),
+				End:    uint64(end.Unix()),
+				Wght:   stake,
+			},
+			Subnet: ids.Empty,
+		},
+		proofOfPossession,
+		w.pWallet.LUXAssetID(),
+		owner,
+		owner,
+		delegationFee,
+		common.WithContext(cctx),
+	)
+	if err != nil {
+		return ids.Empty, fmt.Errorf("P-Wallet Tx Error %s %w, node ID %s", "IssueAddPermissionlessValidatorTx", err, nodeID.String())
+	}
+	return txID, nil
+}
+
+// Returns true if [nodeID] is a current primary network validator
+func isPrimaryValidator(ctx context.Context, platformCli platformvm.Client, nodeID ids.NodeID) (bool, error) {
+	cctx, cancel := createDefaultCtx(ctx)
+	defer cancel()
+	vdrs, err := platformCli.GetCurrentValidators(cctx, constants.PrimaryNetworkID, []ids.NodeID{nodeID})
+	if err != nil {
+		return false, err
+	}
+	for _, vdr := range vdrs {
+		if vdr.NodeID == nodeID {
+			return true, nil
+		}
+	}
+	return false, nil
+}
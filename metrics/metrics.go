@@ -0,0 +1,406 @@
+// Copyright (C) 2021-2024, Lux Partners Limited. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+// Package metrics scrapes the prometheus metrics of the network nodes and
+// serves them aggregated on a single endpoint, each sample labeled with
+// the name of its node, so that one datasource covers the whole network.
+package metrics
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/luxdefi/node/utils/logging"
+	"go.uber.org/zap"
+)
+
+const (
+	// Label holding the name of the node a sample was scraped from
+	NodeLabel = "node"
+	// Prefix of the scraped labels clashing with the target ones,
+	// as renamed by prometheus when not honoring the scraped labels
+	exportedLabelPrefix = "exported_"
+
+	// max size of a scraped metrics page
+	maxScrapeSize = 32 * 1024 * 1024
+
+	upMetric             = "netrunner_scrape_up"
+	scrapeDurationMetric = "netrunner_scrape_duration_seconds"
+)
+
+// Target is the metrics endpoint of a node
+type Target struct {
+	// Name of the node, set as the [NodeLabel] label of its samples
+	Node string
+	// URL of the metrics endpoint (e.g. http://127.0.0.1:9650/ext/metrics)
+	URL string
+	// Optional labels added to the samples of the node
+	Labels map[string]string
+}
+
+// metric family, as given by the HELP and TYPE lines of its samples
+type family struct {
+	name string
+	help string
+	typ  string
+	// sample lines, relabeled
+	samples []string
+}
+
+// result of the last scrape of a target
+type scrape struct {
+	families []*family
+	duration time.Duration
+	err      error
+}
+
+// Scraper scrapes the metrics endpoints of the network nodes,
+// and serves the samples of their last scrape, aggregated
+type Scraper struct {
+	// Returns the targets to scrape, called on every scrape
+	// so that added nodes are scraped
+	getTargets func() ([]Target, error)
+	log        logging.Logger
+	client     *http.Client
+
+	lock sync.RWMutex
+	// node name --> its last scrape
+	scrapes map[string]*scrape
+}
+
+// NewScraper returns a scraper of the targets listed by [getTargets].
+// Failed scrapes are logged to [log].
+func NewScraper(log logging.Logger, getTargets func() ([]Target, error)) *Scraper {
+	return &Scraper{
+		getTargets: getTargets,
+		log:        log,
+		client:     &http.Client{},
+		scrapes:    map[string]*scrape{},
+	}
+}
+
+// Run scrapes the targets every [interval] until [ctx] is done.
+// A scrape times out after [interval].
+func (s *Scraper) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		scrapeCtx, cancel := context.WithTimeout(ctx, interval)
+		s.Scrape(scrapeCtx)
+		cancel()
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Scrape scrapes all the targets concurrently, replacing the last scrapes.
+// The nodes that are no longer targets are dropped.
+func (s *Scraper) Scrape(ctx context.Context) {
+	targets, err := s.getTargets()
+	if err != nil {
+		s.log.Debug("couldn't get metrics targets", zap.Error(err))
+		targets = nil
+	}
+	scrapes := make(map[string]*scrape, len(targets))
+	scrapesLock := sync.Mutex{}
+	wg := sync.WaitGroup{}
+	for _, target := range targets {
+		target := target
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			start := time.Now()
+			families, err := s.scrapeTarget(ctx, target)
+			if err != nil {
+				s.log.Debug("couldn't scrape node metrics", zap.String("node", target.Node), zap.Error(err))
+			}
+			scrapesLock.Lock()
+			scrapes[target.Node] = &scrape{
+				families: families,
+				duration: time.Since(start),
+				err:      err,
+			}
+			scrapesLock.Unlock()
+		}()
+	}
+	wg.Wait()
+
+	s.lock.Lock()
+	s.scrapes = scrapes
+	s.lock.Unlock()
+}
+
+// Returns the metric families of [target], with the target labels
+func (s *Scraper) scrapeTarget(ctx context.Context, target Target) ([]*family, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target.URL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "text/plain;version=0.0.4")
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status %q for %s", resp.Status, target.URL)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxScrapeSize))
+	if err != nil {
+		return nil, err
+	}
+	labels := make(map[string]string, len(target.Labels)+1)
+	for k, v := range target.Labels {
+		labels[k] = v
+	}
+	labels[NodeLabel] = target.Node
+	return parse(body, labels)
+}
+
+// ServeHTTP serves the samples of the last scrapes in the prometheus
+// text format, along with the scrape status of every node
+func (s *Scraper) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	_ = s.Write(w)
+}
+
+// Write writes the samples of the last scrapes to [w] in the prometheus
+// text format. The samples of a metric family are grouped, across the
+// nodes, and the families are sorted by name.
+func (s *Scraper) Write(w io.Writer) error {
+	s.lock.RLock()
+	defer s.lock.RUnlock()
+
+	nodes := make([]string, 0, len(s.scrapes))
+	for node := range s.scrapes {
+		nodes = append(nodes, node)
+	}
+	sort.Strings(nodes)
+
+	up := &family{name: upMetric, help: "1 if the last scrape of the node metrics succeeded, 0 otherwise.", typ: "gauge"}
+	duration := &family{name: scrapeDurationMetric, help: "Duration of the last scrape of the node metrics.", typ: "gauge"}
+	families := map[string]*family{up.name: up, duration.name: duration}
+	for _, node := range nodes {
+		sc := s.scrapes[node]
+		nodeLabel := map[string]string{NodeLabel: node}
+		upValue := 1
+		if sc.err != nil {
+			upValue = 0
+		}
+		up.samples = append(up.samples, upMetric+formatLabels(nodeLabel)+fmt.Sprintf(" %d", upValue))
+		duration.samples = append(duration.samples, scrapeDurationMetric+formatLabels(nodeLabel)+fmt.Sprintf(" %g", sc.duration.Seconds()))
+		for _, f := range sc.families {
+			merged, ok := families[f.name]
+			if !ok {
+				merged = &family{name: f.name, help: f.help, typ: f.typ}
+				families[f.name] = merged
+			}
+			merged.samples = append(merged.samples, f.samples...)
+		}
+	}
+
+	names := make([]string, 0, len(families))
+	for name := range families {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	bw := bufio.NewWriter(w)
+	for _, name := range names {
+		f := families[name]
+		if f.help != "" {
+			fmt.Fprintf(bw, "# HELP %s %s\n", f.name, f.help)
+		}
+		if f.typ != "" {
+			fmt.Fprintf(bw, "# TYPE %s %s\n", f.name, f.typ)
+		}
+		for _, sample := range f.samples {
+			bw.WriteString(sample)
+			bw.WriteByte('\n')
+		}
+	}
+	return bw.Flush()
+}
+
+// Parses the metric families of [body], in the prometheus text format,
+// adding [labels] to their samples. The scraped labels clashing with
+// [labels] are renamed with the exported_ prefix.
+func parse(body []byte, labels map[string]string) ([]*family, error) {
+	families := []*family{}
+	byName := map[string]*family{}
+	getFamily := func(name string) *family {
+		f, ok := byName[name]
+		if !ok {
+			f = &family{name: name}
+			byName[name] = f
+			families = append(families, f)
+		}
+		return f
+	}
+	// family of the last HELP or TYPE line
+	var current *family
+	for lineNum, line := range strings.Split(string(body), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		if strings.HasPrefix(line, "#") {
+			fields := strings.SplitN(strings.TrimSpace(strings.TrimPrefix(line, "#")), " ", 3)
+			if len(fields) < 3 || (fields[0] != "HELP" && fields[0] != "TYPE") {
+				// other comment
+				continue
+			}
+			current = getFamily(fields[1])
+			if fields[0] == "HELP" {
+				current.help = fields[2]
+			} else {
+				current.typ = fields[2]
+			}
+			continue
+		}
+		name, sample, err := relabel(line, labels)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %w", lineNum+1, err)
+		}
+		f := current
+		if f == nil || !belongsTo(name, f.name) {
+			f = getFamily(name)
+		}
+		f.samples = append(f.samples, sample)
+	}
+	return families, nil
+}
+
+// Returns true if the sample [name] is of the family [familyName],
+// including the suffixed samples of histograms, summaries and counters
+func belongsTo(name string, familyName string) bool {
+	if name == familyName {
+		return true
+	}
+	suffix := strings.TrimPrefix(name, familyName)
+	if suffix == name {
+		return false
+	}
+	switch suffix {
+	case "_bucket", "_sum", "_count", "_total", "_created", "_info":
+		return true
+	}
+	return false
+}
+
+// Returns the metric name of the sample [line], and the line with
+// [labels] added to the sample labels
+func relabel(line string, labels map[string]string) (string, string, error) {
+	nameEnd := strings.IndexAny(line, "{ \t")
+	if nameEnd <= 0 {
+		return "", "", fmt.Errorf("invalid sample %q", line)
+	}
+	name := line[:nameEnd]
+	rest := line[nameEnd:]
+	sampleLabels := map[string]string{}
+	if strings.HasPrefix(rest, "{") {
+		var err error
+		sampleLabels, rest, err = parseLabels(rest)
+		if err != nil {
+			return "", "", fmt.Errorf("invalid sample %q: %w", line, err)
+		}
+	}
+	for k, v := range labels {
+		if scraped, ok := sampleLabels[k]; ok {
+			sampleLabels[exportedLabelPrefix+k] = scraped
+		}
+		sampleLabels[k] = v
+	}
+	return name, name + formatLabels(sampleLabels) + rest, nil
+}
+
+// Parses the labels at the start of [s], as {name="value",...}, and
+// returns them along with the rest of [s]
+func parseLabels(s string) (map[string]string, string, error) {
+	labels := map[string]string{}
+	i := 1
+	for {
+		for i < len(s) && (s[i] == ' ' || s[i] == ',') {
+			i++
+		}
+		if i >= len(s) {
+			return nil, "", fmt.Errorf("unterminated labels")
+		}
+		if s[i] == '}' {
+			return labels, s[i+1:], nil
+		}
+		eq := strings.IndexByte(s[i:], '=')
+		if eq <= 0 {
+			return nil, "", fmt.Errorf("invalid label at %d", i)
+		}
+		key := strings.TrimSpace(s[i : i+eq])
+		i += eq + 1
+		if i >= len(s) || s[i] != '"' {
+			return nil, "", fmt.Errorf("unquoted value of label %q", key)
+		}
+		i++
+		value := strings.Builder{}
+		for {
+			if i >= len(s) {
+				return nil, "", fmt.Errorf("unterminated value of label %q", key)
+			}
+			c := s[i]
+			i++
+			if c == '"' {
+				break
+			}
+			if c == '\\' && i < len(s) {
+				switch s[i] {
+				case 'n':
+					c = '\n'
+				default:
+					c = s[i]
+				}
+				i++
+			}
+			value.WriteByte(c)
+		}
+		labels[key] = value.String()
+	}
+}
+
+// Returns [labels] as {name="value",...}, sorted by name
+func formatLabels(labels map[string]string) string {
+	if len(labels) == 0 {
+		return ""
+	}
+	keys := make([]string, 0, len(labels))
+	for k := range labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	buf := bytes.Buffer{}
+	buf.WriteByte('{')
+	for i, k := range keys {
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+		buf.WriteString(k)
+		buf.WriteString(`="`)
+		buf.WriteString(escapeLabelValue(labels[k]))
+		buf.WriteByte('"')
+	}
+	buf.WriteByte('}')
+	return buf.String()
+}
+
+var labelValueReplacer = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+
+func escapeLabelValue(v string) string {
+	return labelValueReplacer.Replace(v)
+}
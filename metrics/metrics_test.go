@@ -0,0 +1,132 @@
+// Copyright (C) 2021-2024, Lux Partners Limited. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package metrics
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/luxdefi/node/utils/logging"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRelabel(t *testing.T) {
+	require := require.New(t)
+
+	labels := map[string]string{NodeLabel: "node1", "owner": "team"}
+	tests := []struct {
+		line     string
+		name     string
+		expected string
+	}{
+		{
+			line:     "up 1",
+			name:     "up",
+			expected: `up{node="node1",owner="team"} 1`,
+		},
+		{
+			line:     `req_total{code="200",path="/a,b"} 3 1700000000`,
+			name:     "req_total",
+			expected: `req_total{code="200",node="node1",owner="team",path="/a,b"} 3 1700000000`,
+		},
+		{
+			line:     `req_total{} 3`,
+			name:     "req_total",
+			expected: `req_total{node="node1",owner="team"} 3`,
+		},
+		{
+			line:     `info{node="other",msg="a \"quoted\" \\ value"} 1`,
+			name:     "info",
+			expected: `info{exported_node="other",msg="a \"quoted\" \\ value",node="node1",owner="team"} 1`,
+		},
+	}
+	for _, test := range tests {
+		name, sample, err := relabel(test.line, labels)
+		require.NoError(err, test.line)
+		require.Equal(test.name, name)
+		require.Equal(test.expected, sample)
+	}
+
+	for _, line := range []string{"{a=\"b\"} 1", `x{a="b" 1`, `x{a=b} 1`} {
+		_, _, err := relabel(line, labels)
+		require.Error(err, line)
+	}
+}
+
+func TestParse(t *testing.T) {
+	require := require.New(t)
+
+	body := []byte(`# HELP lat Latency.
+# TYPE lat histogram
+lat_bucket{le="1"} 2
+lat_bucket{le="+Inf"} 3
+lat_sum 4
+lat_count 3
+# a comment
+untyped 5
+`)
+	families, err := parse(body, map[string]string{NodeLabel: "node1"})
+	require.NoError(err)
+	require.Len(families, 2)
+	require.Equal("lat", families[0].name)
+	require.Equal("Latency.", families[0].help)
+	require.Equal("histogram", families[0].typ)
+	require.Len(families[0].samples, 4)
+	require.Equal(`lat_bucket{le="1",node="node1"} 2`, families[0].samples[0])
+	require.Equal("untyped", families[1].name)
+	require.Equal([]string{`untyped{node="node1"} 5`}, families[1].samples)
+}
+
+func TestScraper(t *testing.T) {
+	require := require.New(t)
+
+	newNode := func(body string) *httptest.Server {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			_, _ = w.Write([]byte(body))
+		}))
+		t.Cleanup(server.Close)
+		return server
+	}
+	node1 := newNode("# HELP height Height.\n# TYPE height gauge\nheight 10\n")
+	node2 := newNode("# HELP height Height.\n# TYPE height gauge\nheight 12\n")
+	targets := []Target{
+		{Node: "node1", URL: node1.URL},
+		{Node: "node2", URL: node2.URL, Labels: map[string]string{"network": "devnet"}},
+		{Node: "node3", URL: "http://127.0.0.1:1/ext/metrics"},
+	}
+	s := NewScraper(logging.NoLog{}, func() ([]Target, error) {
+		return targets, nil
+	})
+	s.Scrape(context.Background())
+
+	buf := &bytes.Buffer{}
+	require.NoError(s.Write(buf))
+	out := buf.String()
+	require.Contains(out, "# HELP height Height.\n# TYPE height gauge\n"+
+		`height{node="node1"} 10`+"\n"+
+		`height{network="devnet",node="node2"} 12`+"\n")
+	require.Contains(out, `netrunner_scrape_up{node="node1"} 1`)
+	require.Contains(out, `netrunner_scrape_up{node="node3"} 0`)
+	require.Equal(1, bytes.Count(buf.Bytes(), []byte("# TYPE height ")))
+
+	// removed nodes are dropped
+	targets = targets[:1]
+	s.Scrape(context.Background())
+	buf.Reset()
+	require.NoError(s.Write(buf))
+	require.NotContains(buf.String(), "node2")
+
+	// no network
+	s = NewScraper(logging.NoLog{}, func() ([]Target, error) {
+		return nil, errors.New("not bootstrapped")
+	})
+	s.Scrape(context.Background())
+	buf.Reset()
+	require.NoError(s.Write(buf))
+	require.NotContains(buf.String(), "node=")
+}
@@ -5,23 +5,35 @@ import (
 	"errors"
 	"fmt"
 	"math/big"
+	"math/rand"
+	"regexp"
 	"strconv"
 	"time"
 
+	"github.com/luxdefi/netrunner/api"
 	"github.com/luxdefi/netrunner/network/node"
 	"github.com/luxdefi/netrunner/utils"
 	"github.com/luxdefi/node/genesis"
 	"github.com/luxdefi/node/ids"
+	"github.com/luxdefi/node/utils/beacon"
 	"github.com/luxdefi/node/utils/constants"
 	"github.com/luxdefi/node/utils/formatting/address"
+	"github.com/luxdefi/node/utils/ips"
 	"github.com/luxdefi/node/utils/units"
 	"golang.org/x/exp/maps"
 )
 
-var cChainConfig map[string]interface{}
+var (
+	cChainConfig map[string]interface{}
+
+	// network names are used as directory names and log/metrics labels
+	networkNameRegex = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9_.-]*$`)
+)
 
 const (
 	validatorStake = units.MegaLux
+
+	DefaultNodeNamePrefix = "node"
 )
 
 func init() {
@@ -49,6 +61,10 @@ type AddrAndBalance struct {
 
 // Config that defines a network when it is created.
 type Config struct {
+	// Optional network name. If given, it is used for the network root dir,
+	// as a log prefix and as a metrics label, and no other named network
+	// with the same name may run concurrently on the same host.
+	Name string `json:"name,omitempty"`
 	// Must not be empty
 	Genesis string `json:"genesis"`
 	// May have length 0
@@ -69,27 +85,240 @@ type Config struct {
 	Flags map[string]interface{} `json:"flags"`
 	// Binary path to use per default, if not specified in node config
 	BinaryPath string `json:"binaryPath"`
+	// If BinaryPath is empty, luxd version (e.g. v1.10.3) to use per default,
+	// if not specified in node config. Its release is downloaded and cached
+	// by the binaries package.
+	BinaryVersion string `json:"binaryVersion,omitempty"`
 	// Chain config files to use per default, if not specified in node config
 	ChainConfigFiles map[string]string `json:"chainConfigFiles"`
 	// Upgrade config files to use per default, if not specified in node config
 	UpgradeConfigFiles map[string]string `json:"upgradeConfigFiles"`
 	// Subnet config files to use per default, if not specified in node config
 	SubnetConfigFiles map[string]string `json:"subnetConfigFiles"`
+	// If true, each node not given a bind IP in its node config is bound
+	// to its own loopback alias (127.0.0.2, 127.0.0.3, ...).
+	// Linux routes all of 127.0.0.0/8 to the loopback interface; other
+	// systems may need the aliases to be added first.
+	LoopbackAliases bool `json:"loopbackAliases,omitempty"`
+	// If true, nodes not given a bind IP in their node config are bound
+	// to the IPv6 loopback address (::1) instead of 127.0.0.1.
+	// For dual-stack APIs, set the "http-host" flag to "::".
+	IPv6 bool `json:"ipv6,omitempty"`
+	// Optional max number of bytes used on disk by the network root dir
+	// and the node dirs outside it. When exceeded, an error is logged and
+	// the network is reported unhealthy. 0 means no budget.
+	DiskBudget uint64 `json:"diskBudget,omitempty"`
+	// Optional base dirs, possibly on other disks, under which each node
+	// gets a <node name> dir for its db, logs and staking key/cert files,
+	// instead of the node dir under the network root dir.
+	// Node dirs given in flags or config files take precedence.
+	DBRootDir   string `json:"dbRootDir,omitempty"`
+	LogsRootDir string `json:"logsRootDir,omitempty"`
+	KeysRootDir string `json:"keysRootDir,omitempty"`
+	// Optional seed of the random choices of the network (the node ports,
+	// and the staking and signing keys, hence the IDs, of the nodes given
+	// none), so that tests of the runner can be reproduced. If 0, a time
+	// based seed is used, and the keys are generated as usual.
+	Seed int64 `json:"seed,omitempty"`
+	// Naming of the nodes not given a name in their node config
+	NodeNaming NodeNaming `json:"nodeNaming,omitempty"`
+	// Optional explicit set of beacons the nodes bootstrap from, instead of
+	// the nodes with IsBeacon set. If given, only the nodes of the network
+	// named here are beacons, and the external beacons are bootstrappers
+	// of every node.
+	Beacons []Beacon `json:"beacons,omitempty"`
+	// Optional app specific probes that must pass, after
+	// the nodes are healthy, for the network to be healthy
+	ReadinessProbes []ReadinessProbe `json:"readinessProbes,omitempty"`
+	// Optional key/value labels of the network (e.g. owner, purpose,
+	// ticket ID), added to the labels of every node. Kept in the network
+	// root dir metadata, for bookkeeping on shared hosts.
+	Labels map[string]string `json:"labels,omitempty"`
+	// Optional primary network staking parameters, e.g. to shorten the
+	// staking periods. Not supported on mainnet and testnet network IDs.
+	Staking *StakingConfig `json:"staking,omitempty"`
+	// Optional latencies between the regions of the nodes
+	Topology *Topology `json:"topology,omitempty"`
+	// Optional auth token and headers of the API calls to the nodes, e.g.
+	// if the nodes are started with api-auth-required enabled
+	APIClientOptions *api.ClientOptions `json:"apiClientOptions,omitempty"`
+}
+
+// Beacon is a node the network nodes bootstrap from: either a node of the
+// network, given by name, or a node not managed by the runner, given by ID and IP.
+type Beacon struct {
+	// Name of a node of the network. Must be the name given in its node config.
+	NodeName string `json:"nodeName,omitempty"`
+	// ID of an external node (e.g. NodeID-...)
+	NodeID string `json:"nodeID,omitempty"`
+	// IP and staking port of an external node (e.g. 10.0.0.5:9651)
+	IP string `json:"ip,omitempty"`
+}
+
+// Returns an error if [b] is not a node of [nodeNames] nor a valid external node
+func (b Beacon) validate(nodeNames map[string]struct{}) error {
+	if b.NodeName != "" {
+		if b.NodeID != "" || b.IP != "" {
+			return fmt.Errorf("beacon %q: node ID and IP can't be given with a node name", b.NodeName)
+		}
+		if _, ok := nodeNames[b.NodeName]; !ok {
+			return fmt.Errorf("beacon %q is not a node of the network", b.NodeName)
+		}
+		return nil
+	}
+	_, err := b.external()
+	return err
+}
+
+// Returns the external beacon [b]
+func (b Beacon) external() (beacon.Beacon, error) {
+	nodeID, err := ids.NodeIDFromString(b.NodeID)
+	if err != nil {
+		return nil, fmt.Errorf("invalid beacon node ID %q: %w", b.NodeID, err)
+	}
+	ipPort, err := ips.ToIPPort(b.IP)
+	if err != nil {
+		return nil, fmt.Errorf("invalid beacon IP %q: %w", b.IP, err)
+	}
+	return beacon.New(nodeID, ipPort), nil
+}
+
+// ApplyBeacons sets the IsBeacon field of the node configs from [c.Beacons],
+// if given. The node configs are copied, not modified in place.
+func (c *Config) ApplyBeacons() {
+	if len(c.Beacons) == 0 {
+		return
+	}
+	nodeConfigs := make([]node.Config, len(c.NodeConfigs))
+	copy(nodeConfigs, c.NodeConfigs)
+	for i := range nodeConfigs {
+		nodeConfigs[i].IsBeacon = c.isBeaconNode(nodeConfigs[i].Name)
+	}
+	c.NodeConfigs = nodeConfigs
+}
+
+// ExternalBeacons returns the beacons of [c.Beacons] not managed by the runner
+func (c *Config) ExternalBeacons() ([]beacon.Beacon, error) {
+	beacons := []beacon.Beacon{}
+	for _, b := range c.Beacons {
+		if b.NodeName != "" {
+			continue
+		}
+		external, err := b.external()
+		if err != nil {
+			return nil, err
+		}
+		beacons = append(beacons, external)
+	}
+	return beacons, nil
+}
+
+// Returns true if [nodeName] is a node beacon of [c.Beacons]
+func (c *Config) isBeaconNode(nodeName string) bool {
+	for _, b := range c.Beacons {
+		if nodeName != "" && b.NodeName == nodeName {
+			return true
+		}
+	}
+	return false
+}
+
+// NodeNaming generates the names of the nodes not given one, from the
+// index of the node in the network, starting at 1 (e.g. node1, node2, ...).
+// Names already taken are skipped.
+type NodeNaming struct {
+	// Defaults to "node"
+	Prefix string `json:"prefix,omitempty"`
+	// If > 0, the index is zero padded to this number of digits (e.g. node001)
+	IndexDigits int `json:"indexDigits,omitempty"`
+	// If set, returns the name of the node with [index],
+	// instead of the prefix followed by the index
+	Generator func(index uint64) string `json:"-"`
+}
+
+// Name returns the name of the node with [index]
+func (n NodeNaming) Name(index uint64) string {
+	if n.Generator != nil {
+		return n.Generator(index)
+	}
+	prefix := n.Prefix
+	if prefix == "" {
+		prefix = DefaultNodeNamePrefix
+	}
+	return fmt.Sprintf("%s%0*d", prefix, n.IndexDigits, index)
 }
 
 // Validate returns an error if this config is invalid
 func (c *Config) Validate() error {
+	if c.Name != "" && !networkNameRegex.MatchString(c.Name) {
+		return fmt.Errorf("invalid network name %q: must start with a letter or digit and contain only letters, digits, '_', '.' or '-'", c.Name)
+	}
+
 	if len(c.Genesis) == 0 {
 		return errors.New("no genesis given")
 	}
 
+	if c.NodeNaming.Prefix != "" {
+		if err := node.ValidateName(c.NodeNaming.Prefix); err != nil {
+			return fmt.Errorf("invalid node name prefix: %w", err)
+		}
+	}
+	if c.NodeNaming.IndexDigits < 0 {
+		return fmt.Errorf("invalid node name index digits %d", c.NodeNaming.IndexDigits)
+	}
+
+	if err := node.ValidateLabels(c.Labels); err != nil {
+		return err
+	}
+
+	probeNames := map[string]struct{}{}
+	for _, probe := range c.ReadinessProbes {
+		if err := probe.Validate(); err != nil {
+			return err
+		}
+		if _, ok := probeNames[probe.Name]; ok {
+			return fmt.Errorf("duplicated readiness probe %q", probe.Name)
+		}
+		probeNames[probe.Name] = struct{}{}
+	}
+
+	if c.IPv6 && c.LoopbackAliases {
+		return errors.New("loopback aliases are not supported on IPv6 networks")
+	}
+
+	if c.Topology != nil {
+		if err := c.Topology.Validate(); err != nil {
+			return err
+		}
+	}
+
 	networkID, err := utils.NetworkIDFromGenesis([]byte(c.Genesis))
 	if err != nil {
 		return fmt.Errorf("couldn't get network ID from genesis: %w", err)
 	}
 
+	nodeNames := make(map[string]struct{}, len(c.NodeConfigs))
+	for _, nodeConfig := range c.NodeConfigs {
+		if nodeConfig.Name != "" {
+			nodeNames[nodeConfig.Name] = struct{}{}
+		}
+	}
+	for _, b := range c.Beacons {
+		if err := b.validate(nodeNames); err != nil {
+			return err
+		}
+	}
+
 	var someNodeIsBeacon bool
 	for i, nodeConfig := range c.NodeConfigs {
+		if len(c.Beacons) > 0 {
+			nodeConfig.IsBeacon = c.isBeaconNode(nodeConfig.Name)
+		}
+		if nodeConfig.Name != "" {
+			if err := node.ValidateName(nodeConfig.Name); err != nil {
+				return err
+			}
+		}
 		if err := nodeConfig.Validate(networkID); err != nil {
 			var nodeName string
 			if len(nodeConfig.Name) > 0 {
@@ -103,7 +332,15 @@ func (c *Config) Validate() error {
 			someNodeIsBeacon = true
 		}
 	}
-	if len(c.NodeConfigs) > 0 && !someNodeIsBeacon {
+	if err := node.ValidateFlags(c.Flags); err != nil {
+		return err
+	}
+	if c.Staking != nil {
+		if err := c.validateStaking(networkID); err != nil {
+			return err
+		}
+	}
+	if len(c.NodeConfigs) > 0 && !someNodeIsBeacon && len(c.Beacons) == 0 {
 		return errors.New("beacon nodes not given")
 	}
 	return nil
@@ -120,6 +357,37 @@ func NewLuxGenesis(
 	xChainBalances []AddrAndBalance,
 	cChainBalances []AddrAndBalance,
 	genesisVdrs []ids.NodeID,
+) ([]byte, error) {
+	return newLuxGenesis(networkID, xChainBalances, cChainBalances, genesisVdrs, ids.GenerateTestShortID)
+}
+
+// NewLuxGenesisFromSeed is like NewLuxGenesis, but the addresses it
+// generates are drawn from [seed], so that the same seed gives the
+// same allocations. See Config.Seed.
+func NewLuxGenesisFromSeed(
+	seed int64,
+	networkID uint32,
+	xChainBalances []AddrAndBalance,
+	cChainBalances []AddrAndBalance,
+	genesisVdrs []ids.NodeID,
+) ([]byte, error) {
+	rng := rand.New(rand.NewSource(seed)) //nolint:gosec
+	newAddr := func() ids.ShortID {
+		addr := ids.ShortID{}
+		_, _ = rng.Read(addr[:])
+		return addr
+	}
+	return newLuxGenesis(networkID, xChainBalances, cChainBalances, genesisVdrs, newAddr)
+}
+
+// Returns the genesis of NewLuxGenesis, with the stake owner
+// and reward addresses given by [newAddr]
+func newLuxGenesis(
+	networkID uint32,
+	xChainBalances []AddrAndBalance,
+	cChainBalances []AddrAndBalance,
+	genesisVdrs []ids.NodeID,
+	newAddr func() ids.ShortID,
 ) ([]byte, error) {
 	switch networkID {
 	case constants.TestnetID, constants.MainnetID, constants.LocalID:
@@ -136,7 +404,7 @@ func NewLuxGenesis(
 	genesisVdrStakeAddr, _ := address.Format(
 		"X",
 		constants.GetHRP(networkID),
-		ids.GenerateTestShortID().Bytes(),
+		newAddr().Bytes(),
 	)
 	config := genesis.UnparsedConfig{
 		NetworkID: networkID,
@@ -194,7 +462,7 @@ func NewLuxGenesis(
 
 	// Set initial validators.
 	// Give staking rewards to random address.
-	rewardAddr, _ := address.Format("X", constants.GetHRP(networkID), ids.GenerateTestShortID().Bytes())
+	rewardAddr, _ := address.Format("X", constants.GetHRP(networkID), newAddr().Bytes())
 	for _, genesisVdr := range genesisVdrs {
 		config.InitialStakers = append(
 			config.InitialStakers,
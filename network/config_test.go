@@ -2,10 +2,13 @@ package network_test
 
 import (
 	"encoding/json"
+	"math/big"
 	"testing"
+	"time"
 
 	"github.com/luxdefi/netrunner/network"
 	"github.com/luxdefi/netrunner/network/node"
+	"github.com/luxdefi/node/ids"
 	"github.com/stretchr/testify/require"
 )
 
@@ -13,6 +16,7 @@ func TestConfigMarshalJSON(t *testing.T) {
 	jsonNetcfg := "{\"genesis\":\"in the beginning there was a token\",\"nodeConfigs\":[{\"binaryPath\":\"/tmp/some/file/path\",\"name\":\"node1\",\"isBeacon\":true,\"stakingKey\":\"key123\",\"stakingCert\":\"cert123\",\"configFile\":\"config-file-blablabla1\",\"chainConfigFiles\":{\"C\": \"cchain-config-file-blablabla1\"},\"flags\":{\"flag-one\":\"val-one\",\"flag-two\":2}},{\"binaryPath\":\"/tmp/some/other/path\",\"name\":\"node2\",\"isBeacon\":false,\"stakingKey\":\"key789\",\"stakingCert\":\"cert789\",\"configFile\":\"config-file-blablabla3\",\"chainConfigFiles\":{\"C\": \"cchain-config-file-blablabla3\"},\"flags\":{\"flag-one\":\"val-one\",\"flag-two\":2}}],\"logLevel\":\"DEBUG\",\"name\":\"abcxyz\",\"flags\":{\"flag-three\":\"val-three\"}}"
 
 	control := network.Config{
+		Name:    "abcxyz",
 		Genesis: "in the beginning there was a token",
 		NodeConfigs: []node.Config{
 			{
@@ -58,3 +62,211 @@ func TestConfigMarshalJSON(t *testing.T) {
 
 	require.EqualValues(t, control, netcfg)
 }
+
+func TestNodeNaming(t *testing.T) {
+	require := require.New(t)
+
+	require.Equal("node1", network.NodeNaming{}.Name(1))
+	require.Equal("validator007", network.NodeNaming{Prefix: "validator", IndexDigits: 3}.Name(7))
+	require.Equal("node1234", network.NodeNaming{IndexDigits: 3}.Name(1234))
+	naming := network.NodeNaming{
+		Prefix: "ignored",
+		Generator: func(index uint64) string {
+			return []string{"alice", "bob"}[index-1]
+		},
+	}
+	require.Equal("bob", naming.Name(2))
+}
+
+func TestValidateNodeName(t *testing.T) {
+	require := require.New(t)
+
+	for _, name := range []string{"node1", "api-3", "Archival_1", "1node"} {
+		require.NoError(node.ValidateName(name))
+	}
+	for _, name := range []string{"", "../node1", "node/1", "node 1", ".node", "-node", "nodé", string(make([]byte, node.MaxNameLen+1))} {
+		require.ErrorIs(node.ValidateName(name), node.ErrInvalidName)
+	}
+}
+
+func TestBeacons(t *testing.T) {
+	require := require.New(t)
+
+	nodeID := ids.GenerateTestNodeID()
+	cfg := network.Config{
+		NodeConfigs: []node.Config{
+			{Name: "node1", IsBeacon: true},
+			{Name: "node2"},
+		},
+		Beacons: []network.Beacon{
+			{NodeName: "node2"},
+			{NodeID: nodeID.String(), IP: "10.0.0.5:9651"},
+		},
+	}
+	nodeConfigs := cfg.NodeConfigs
+	cfg.ApplyBeacons()
+	require.False(cfg.NodeConfigs[0].IsBeacon)
+	require.True(cfg.NodeConfigs[1].IsBeacon)
+	// the given node configs are not modified
+	require.True(nodeConfigs[0].IsBeacon)
+
+	beacons, err := cfg.ExternalBeacons()
+	require.NoError(err)
+	require.Len(beacons, 1)
+	require.Equal(nodeID, beacons[0].ID())
+	require.Equal("10.0.0.5:9651", beacons[0].IP().String())
+
+	cfg.Beacons = []network.Beacon{{NodeID: nodeID.String(), IP: "10.0.0.5"}}
+	_, err = cfg.ExternalBeacons()
+	require.Error(err)
+}
+
+func TestNormalizeFlags(t *testing.T) {
+	require := require.New(t)
+
+	flags := map[string]interface{}{
+		"network-max-reconnect-delay":               "1m30s",
+		"benchlist-duration":                        "1d12h",
+		"http-read-timeout":                         float64(time.Second),
+		"throttler-inbound-at-large-alloc-size":     "2GiB",
+		"throttler-inbound-node-max-at-large-bytes": "1.5MB",
+		"network-peer-read-buffer-size":             float64(8192),
+		"log-level":                                 "info",
+	}
+	require.NoError(node.NormalizeFlags(flags))
+	require.Equal(map[string]interface{}{
+		"network-max-reconnect-delay":               "1m30s",
+		"benchlist-duration":                        "36h0m0s",
+		"http-read-timeout":                         "1s",
+		"throttler-inbound-at-large-alloc-size":     uint64(2 << 30),
+		"throttler-inbound-node-max-at-large-bytes": uint64(1_500_000),
+		"network-peer-read-buffer-size":             uint64(8192),
+		"log-level":                                 "info",
+	}, flags)
+
+	for _, flags := range []map[string]interface{}{
+		{"network-max-reconnect-delay": "30"},
+		{"network-max-reconnect-delay": true},
+		{"throttler-inbound-at-large-alloc-size": "2GB/s"},
+		{"throttler-inbound-at-large-alloc-size": "0.5B"},
+		{"throttler-inbound-at-large-alloc-size": float64(-1)},
+	} {
+		require.Error(node.ValidateFlags(flags))
+	}
+}
+
+func TestLabels(t *testing.T) {
+	require := require.New(t)
+
+	require.NoError(node.ValidateLabels(map[string]string{"owner": "team-a", "ticket_id": "NR-12", "_purpose": ""}))
+	for _, key := range []string{"", "1owner", "ticket-id", "owner.name"} {
+		require.ErrorIs(node.ValidateLabels(map[string]string{key: "value"}), node.ErrInvalidLabel)
+	}
+
+	networkLabels := map[string]string{"owner": "team-a", "purpose": "load-test"}
+	nodeLabels := map[string]string{"purpose": "archival"}
+	require.Equal(
+		map[string]string{"owner": "team-a", "purpose": "archival"},
+		node.MergeLabels(networkLabels, nodeLabels),
+	)
+	// the given labels are not modified
+	require.Equal("load-test", networkLabels["purpose"])
+	require.Len(nodeLabels, 1)
+	require.Nil(node.MergeLabels(nil, nil))
+}
+
+func TestStakingConfig(t *testing.T) {
+	require := require.New(t)
+
+	staking := network.StakingConfig{
+		MinStakeDuration:     time.Minute,
+		MaxStakeDuration:     time.Hour,
+		GenesisStakeDuration: 10 * time.Minute,
+	}
+	require.NoError(staking.Validate(5, time.Minute))
+	// the offsets of 11 genesis validators don't fit in 10 minutes
+	require.ErrorIs(staking.Validate(11, time.Minute), network.ErrInvalidStakingConfig)
+	for _, invalid := range []network.StakingConfig{
+		{MinStakeDuration: time.Hour, MaxStakeDuration: time.Minute},
+		{MinStakeDuration: time.Minute, MaxStakeDuration: time.Hour, MintingPeriod: time.Minute},
+		{MinStakeDuration: time.Minute, MaxStakeDuration: time.Hour, GenesisStakeDuration: 2 * time.Hour},
+	} {
+		require.ErrorIs(invalid.Validate(1, 0), network.ErrInvalidStakingConfig)
+	}
+
+	cfg := network.Config{
+		Genesis: `{"networkID":1337,"allocations":[{"initialAmount":300000000000000001}],"startTime":1630987200,"initialStakeDuration":31536000}`,
+		Flags:   map[string]interface{}{"log-level": "info"},
+		Staking: &staking,
+	}
+	now := time.Unix(1700000000, 0)
+	require.NoError(cfg.ApplyStaking(now))
+	require.Contains(cfg.Genesis, `"initialAmount":300000000000000001`)
+	require.Contains(cfg.Genesis, `"startTime":1700000000`)
+	require.Contains(cfg.Genesis, `"initialStakeDuration":600`)
+	require.Equal("1m0s", cfg.Flags["min-stake-duration"])
+	// the minting period defaults to the max stake duration
+	require.Equal("1h0m0s", cfg.Flags["stake-minting-period"])
+	require.Equal("info", cfg.Flags["log-level"])
+}
+
+func TestTopology(t *testing.T) {
+	require := require.New(t)
+
+	topology := network.Topology{
+		Latencies: map[string]map[string]time.Duration{
+			"us": {"eu": 40 * time.Millisecond, "us": time.Millisecond},
+			"ap": {"eu": 80 * time.Millisecond},
+		},
+	}
+	require.NoError(topology.Validate())
+	require.Equal([]string{"ap", "eu", "us"}, topology.Regions())
+	latency, ok := topology.Latency("eu", "us")
+	require.True(ok)
+	require.Equal(40*time.Millisecond, latency)
+	latency, ok = topology.Latency("us", "us")
+	require.True(ok)
+	require.Equal(time.Millisecond, latency)
+	_, ok = topology.Latency("ap", "us")
+	require.False(ok)
+
+	topology.Latencies["eu"] = map[string]time.Duration{"us": time.Second}
+	require.Error(topology.Validate())
+
+	flags := map[string]interface{}{
+		"log-level": "debug",
+		network.TopologyConfigKey: map[string]interface{}{
+			"latencies": map[string]interface{}{"us": map[string]interface{}{"eu": 40000000}},
+		},
+	}
+	taken, err := network.TakeTopologyConfigKey(flags)
+	require.NoError(err)
+	require.Equal(map[string]interface{}{"log-level": "debug"}, flags)
+	latency, ok = taken.Latency("eu", "us")
+	require.True(ok)
+	require.Equal(40*time.Millisecond, latency)
+
+	taken, err = network.TakeTopologyConfigKey(flags)
+	require.NoError(err)
+	require.Nil(taken)
+}
+
+func TestNewLuxGenesisFromSeed(t *testing.T) {
+	require := require.New(t)
+
+	allocations := func(seed int64) []interface{} {
+		genesis, err := network.NewLuxGenesisFromSeed(
+			seed,
+			1337,
+			[]network.AddrAndBalance{{Addr: ids.ShortID{1}, Balance: big.NewInt(1)}},
+			nil,
+			[]ids.NodeID{{1}},
+		)
+		require.NoError(err)
+		genesisMap := map[string]interface{}{}
+		require.NoError(json.Unmarshal(genesis, &genesisMap))
+		return []interface{}{genesisMap["initialStakedFunds"], genesisMap["initialStakers"]}
+	}
+	require.Equal(allocations(1), allocations(1))
+	require.NotEqual(allocations(1), allocations(2))
+}
@@ -0,0 +1,22 @@
+// Copyright (C) 2021-2024, Lux Partners Limited. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package network
+
+import "time"
+
+// Output streams of a node process, as of ConsoleLine.Stream
+const (
+	ConsoleStdout = "stdout"
+	ConsoleStderr = "stderr"
+)
+
+// ConsoleLine is a line written by a node process to its stdout or stderr
+type ConsoleLine struct {
+	NodeName string `json:"nodeName"`
+	// ConsoleStdout or ConsoleStderr
+	Stream string `json:"stream"`
+	// Line, without its line break
+	Line string    `json:"line"`
+	Time time.Time `json:"time"`
+}
@@ -0,0 +1,129 @@
+// Copyright (C) 2021-2024, Lux Partners Limited. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package network
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/luxdefi/netrunner/network/node"
+)
+
+const (
+	apiAuthRequiredKey = "api-auth-required"
+	apiAdminEnabledKey = "api-admin-enabled"
+	apiIpcsEnabledKey  = "api-ipcs-enabled"
+	indexEnabledKey    = "index-enabled"
+)
+
+// Endpoint describes a single callable RPC endpoint exposed by a node
+type Endpoint struct {
+	NodeName string `json:"nodeName"`
+	NodeID   string `json:"nodeID"`
+	// Role of the node, empty for regular nodes
+	NodeRole string `json:"nodeRole,omitempty"`
+	// Chain alias, blockchain ID or API name (e.g. "C", "info")
+	Chain string `json:"chain"`
+	// Full URL of the endpoint
+	URL string `json:"url"`
+	// Either "http" or "ws"
+	Protocol string `json:"protocol"`
+	// True if the node serves its API over TLS
+	TLS bool `json:"tls"`
+	// True if the node requires an auth token on its API
+	AuthRequired bool `json:"authRequired"`
+}
+
+// EndpointCatalog lists every endpoint reachable through the network
+type EndpointCatalog struct {
+	Endpoints []Endpoint `json:"endpoints"`
+}
+
+type endpointPath struct {
+	chain    string
+	path     string
+	protocol string
+	// flag that must be enabled on the node for the endpoint to be served
+	requiredFlag string
+}
+
+var primaryNetworkEndpoints = []endpointPath{
+	{chain: "P", path: "/ext/bc/P", protocol: "http"},
+	{chain: "X", path: "/ext/bc/X", protocol: "http"},
+	{chain: "C", path: "/ext/bc/C/rpc", protocol: "http"},
+	{chain: "C", path: "/ext/bc/C/ws", protocol: "ws"},
+	{chain: "info", path: "/ext/info", protocol: "http"},
+	{chain: "health", path: "/ext/health", protocol: "http"},
+	{chain: "metrics", path: "/ext/metrics", protocol: "http"},
+	{chain: "keystore", path: "/ext/keystore", protocol: "http"},
+	{chain: "admin", path: "/ext/admin", protocol: "http", requiredFlag: apiAdminEnabledKey},
+	{chain: "ipcs", path: "/ext/ipcs", protocol: "http", requiredFlag: apiIpcsEnabledKey},
+	{chain: "index/P", path: "/ext/index/P/block", protocol: "http", requiredFlag: indexEnabledKey},
+	{chain: "index/X", path: "/ext/index/X/tx", protocol: "http", requiredFlag: indexEnabledKey},
+	{chain: "index/C", path: "/ext/index/C/block", protocol: "http", requiredFlag: indexEnabledKey},
+}
+
+// NewEndpointCatalog returns the catalog of endpoints served by [nodes].
+// [customChains] maps a custom chain name or alias to its blockchain ID,
+// and adds a "/ext/bc/<blockchainID>" endpoint for each one of them.
+// Paused nodes are not included.
+func NewEndpointCatalog(nodes map[string]node.Node, customChains map[string]string) EndpointCatalog {
+	catalog := EndpointCatalog{Endpoints: []Endpoint{}}
+	nodeNames := make([]string, 0, len(nodes))
+	for nodeName := range nodes {
+		nodeNames = append(nodeNames, nodeName)
+	}
+	sort.Strings(nodeNames)
+	chainNames := make([]string, 0, len(customChains))
+	for chainName := range customChains {
+		chainNames = append(chainNames, chainName)
+	}
+	sort.Strings(chainNames)
+	for _, nodeName := range nodeNames {
+		n := nodes[nodeName]
+		if n.GetPaused() {
+			continue
+		}
+		baseURI := n.GetAPIBaseURI()
+		tls := strings.HasPrefix(baseURI, "https://")
+		authRequired := isFlagEnabled(n, apiAuthRequiredKey)
+		newEndpoint := func(chain string, path string, protocol string) Endpoint {
+			url := baseURI + path
+			if protocol == "ws" {
+				url = "ws" + strings.TrimPrefix(url, "http")
+			}
+			return Endpoint{
+				NodeName:     nodeName,
+				NodeID:       n.GetNodeID().String(),
+				NodeRole:     n.GetConfig().Role,
+				Chain:        chain,
+				URL:          url,
+				Protocol:     protocol,
+				TLS:          tls,
+				AuthRequired: authRequired,
+			}
+		}
+		for _, e := range primaryNetworkEndpoints {
+			if e.requiredFlag != "" && !isFlagEnabled(n, e.requiredFlag) {
+				continue
+			}
+			catalog.Endpoints = append(catalog.Endpoints, newEndpoint(e.chain, e.path, e.protocol))
+		}
+		for _, chainName := range chainNames {
+			path := "/ext/bc/" + customChains[chainName]
+			catalog.Endpoints = append(catalog.Endpoints, newEndpoint(chainName, path, "http"))
+		}
+	}
+	return catalog
+}
+
+// Returns true if [flag] is set to true on the node config flags.
+func isFlagEnabled(n node.Node, flag string) bool {
+	v, ok := n.GetConfig().Flags[flag]
+	if !ok {
+		return false
+	}
+	return fmt.Sprintf("%v", v) == "true"
+}
@@ -0,0 +1,112 @@
+// Copyright (C) 2021-2024, Lux Partners Limited. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package network_test
+
+import (
+	"testing"
+
+	"github.com/luxdefi/netrunner/network"
+	"github.com/luxdefi/netrunner/network/node"
+	"github.com/luxdefi/node/ids"
+	"github.com/stretchr/testify/require"
+)
+
+// endpointsNode serves its API at the base URI set by the test.
+// The methods not overridden panic.
+type endpointsNode struct {
+	node.Node
+	nodeID  ids.NodeID
+	baseURI string
+	config  node.Config
+	paused  bool
+}
+
+func (n *endpointsNode) GetNodeID() ids.NodeID {
+	return n.nodeID
+}
+
+func (n *endpointsNode) GetAPIBaseURI() string {
+	return n.baseURI
+}
+
+func (n *endpointsNode) GetConfig() node.Config {
+	return n.config
+}
+
+func (n *endpointsNode) GetPaused() bool {
+	return n.paused
+}
+
+// Returns the chains of the endpoints of [nodeName] in [catalog]
+func endpointChains(catalog network.EndpointCatalog, nodeName string) []string {
+	chains := []string{}
+	for _, e := range catalog.Endpoints {
+		if e.NodeName == nodeName {
+			chains = append(chains, e.Chain)
+		}
+	}
+	return chains
+}
+
+func TestNewEndpointCatalog(t *testing.T) {
+	require := require.New(t)
+
+	node1 := &endpointsNode{nodeID: ids.NodeID{1}, baseURI: "http://127.0.0.1:9650"}
+	node2 := &endpointsNode{
+		nodeID:  ids.NodeID{2},
+		baseURI: "https://127.0.0.1:9652",
+		config: node.Config{
+			Role: node.RoleAPI,
+			Flags: map[string]interface{}{
+				"api-auth-required": true,
+				"api-admin-enabled": "true",
+				"index-enabled":     true,
+				"api-ipcs-enabled":  false,
+			},
+		},
+	}
+	paused := &endpointsNode{nodeID: ids.NodeID{3}, baseURI: "http://127.0.0.1:9654", paused: true}
+	catalog := network.NewEndpointCatalog(
+		map[string]node.Node{"node2": node2, "node1": node1, "node3": paused},
+		map[string]string{"subnetevm": "2Z36RnQuk1hvsnFeGWzfZUfXNr7w1SjzmDQ78YxfTVNAkDq3nZ"},
+	)
+
+	require.Equal(
+		[]string{"P", "X", "C", "C", "info", "health", "metrics", "keystore", "subnetevm"},
+		endpointChains(catalog, "node1"),
+	)
+	require.Equal(
+		[]string{"P", "X", "C", "C", "info", "health", "metrics", "keystore", "admin", "index/P", "index/X", "index/C", "subnetevm"},
+		endpointChains(catalog, "node2"),
+	)
+	require.Empty(endpointChains(catalog, "node3"))
+	// sorted by node name
+	require.Equal("node1", catalog.Endpoints[0].NodeName)
+	require.Equal("node2", catalog.Endpoints[len(catalog.Endpoints)-1].NodeName)
+
+	require.Equal(network.Endpoint{
+		NodeName: "node1",
+		NodeID:   node1.nodeID.String(),
+		Chain:    "C",
+		URL:      "ws://127.0.0.1:9650/ext/bc/C/ws",
+		Protocol: "ws",
+	}, catalog.Endpoints[3])
+	require.Equal(network.Endpoint{
+		NodeName:     "node2",
+		NodeID:       node2.nodeID.String(),
+		NodeRole:     node.RoleAPI,
+		Chain:        "subnetevm",
+		URL:          "https://127.0.0.1:9652/ext/bc/2Z36RnQuk1hvsnFeGWzfZUfXNr7w1SjzmDQ78YxfTVNAkDq3nZ",
+		Protocol:     "http",
+		TLS:          true,
+		AuthRequired: true,
+	}, catalog.Endpoints[len(catalog.Endpoints)-1])
+	for _, e := range catalog.Endpoints {
+		if e.NodeName == "node2" && e.Protocol == "ws" {
+			require.Equal("wss://127.0.0.1:9652/ext/bc/C/ws", e.URL)
+		}
+	}
+
+	require.Empty(network.NewEndpointCatalog(nil, nil).Endpoints)
+}
@@ -0,0 +1,48 @@
+// Copyright (C) 2021-2024, Lux Partners Limited. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package network
+
+import (
+	"fmt"
+	"time"
+)
+
+// EventKind is the kind of a lifecycle event of a network
+type EventKind string
+
+const (
+	// A node process was started, on creation, resume or restart
+	EventNodeStarted EventKind = "node-started"
+	// A node process was stopped, on removal, pause or restart
+	EventNodeStopped EventKind = "node-stopped"
+	// A node was restarted by RestartNode, after its
+	// EventNodeStopped and EventNodeStarted
+	EventNodeRestarted EventKind = "node-restarted"
+	// A health check of the network found a node stopped
+	// unexpectedly, or not healthy in time
+	EventNodeUnhealthy EventKind = "node-unhealthy"
+	// A health check of the network found all the nodes healthy
+	EventNetworkHealthy EventKind = "network-healthy"
+)
+
+// Event is a lifecycle event of a network
+type Event struct {
+	Kind EventKind `json:"kind"`
+	// Node of the event, empty for the network events
+	NodeName string    `json:"nodeName,omitempty"`
+	Time     time.Time `json:"time"`
+	// Why the node is unhealthy, for EventNodeUnhealthy
+	Err error `json:"-"`
+}
+
+func (e Event) String() string {
+	s := string(e.Kind)
+	if e.NodeName != "" {
+		s += " " + e.NodeName
+	}
+	if e.Err != nil {
+		s += fmt.Sprintf(": %s", e.Err)
+	}
+	return s
+}
@@ -0,0 +1,32 @@
+// Copyright (C) 2021-2024, Lux Partners Limited. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package network
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/luxdefi/netrunner/network/node"
+)
+
+// BeaconsNodeGroup is the node group maintained by the network with
+// the beacon nodes. It can't be set or removed.
+const BeaconsNodeGroup = "beacons"
+
+var (
+	ErrNodeGroupNotFound = errors.New("node group not found")
+	ErrInvalidNodeGroup  = errors.New("invalid node group")
+)
+
+// ValidateNodeGroupName returns an error if [name] can't be the name of a
+// group set by the user. Group names follow the rules of node names.
+func ValidateNodeGroupName(name string) error {
+	if name == BeaconsNodeGroup {
+		return fmt.Errorf("%w %q: maintained by the network", ErrInvalidNodeGroup, name)
+	}
+	if err := node.ValidateName(name); err != nil {
+		return fmt.Errorf("%w: %s", ErrInvalidNodeGroup, err)
+	}
+	return nil
+}
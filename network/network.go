@@ -3,6 +3,8 @@ package network
 import (
 	"context"
 	"errors"
+	"fmt"
+	"syscall"
 	"time"
 
 	"github.com/luxdefi/netrunner/network/node"
@@ -24,6 +26,22 @@ type PermissionlessValidatorSpec struct {
 	StakeDuration time.Duration
 }
 
+// DelegatorSpec of a stake delegation, from the test keychain, to a validator
+type DelegatorSpec struct {
+	// Validator node the stake is delegated to
+	NodeName string
+	// Elastic subnet of the validation. Defaults to the primary network.
+	SubnetID string
+	// Staked asset of the elastic subnet. Ignored for the primary network.
+	AssetID string
+	// Defaults to the min delegator stake of the primary network
+	StakedAmount uint64
+	// Defaults to as soon as possible
+	StartTime time.Time
+	// Defaults to the remaining validation duration
+	StakeDuration time.Duration
+}
+
 type ElasticSubnetSpec struct {
 	SubnetID                 *string
 	AssetName                string
@@ -63,27 +81,278 @@ type BlockchainSpec struct {
 	PerNodeChainConfig map[string][]byte
 }
 
+// Ways of damaging the db files of a node
+const (
+	// Removes the last NumBytes bytes of each file, or all of them if NumBytes is 0
+	DBCorruptionTruncate = "truncate"
+	// Flips NumBytes bytes, 1 if NumBytes is 0, at random offsets of each file
+	DBCorruptionFlipBytes = "flip-bytes"
+	// Deletes each file
+	DBCorruptionDelete = "delete"
+)
+
+type DBCorruptionSpec struct {
+	// Glob patterns of the files to damage, matched against both the file
+	// name and its path relative to the node db dir (e.g. "*.ldb", "MANIFEST-*")
+	Files []string
+	// One of DBCorruptionTruncate, DBCorruptionFlipBytes, DBCorruptionDelete
+	Mode     string
+	NumBytes int64
+	// Seed of the flipped byte offsets, so a corruption can be reproduced
+	Seed int64
+}
+
+// DBInfo summarizes the db of a node
+type DBInfo struct {
+	// Dir of the opened db
+	Path string `json:"path"`
+	// Size of the db files on disk
+	DiskSize uint64        `json:"diskSize"`
+	Chains   []ChainDBInfo `json:"chains"`
+	// Primary network validators in the P-chain state
+	Validators []DBValidator `json:"validators"`
+	// Size of the keys and values, by owner of their prefix
+	// (e.g. "C/vm", "P/bootstrap"). Keys of unknown owners are under "other".
+	PrefixSizes map[string]int64 `json:"prefixSizes"`
+}
+
+type ChainDBInfo struct {
+	// Chain alias, or blockchain ID if it has none
+	Chain   string `json:"chain"`
+	ChainID string `json:"chainID"`
+	// Empty if the chain has no block accepted or its state layout is unknown
+	LastAcceptedBlockID string `json:"lastAcceptedBlockID,omitempty"`
+	// Only known for EVM chains
+	LastAcceptedHeight *uint64 `json:"lastAcceptedHeight,omitempty"`
+}
+
+type DBValidator struct {
+	NodeID string `json:"nodeID"`
+	TxID   string `json:"txID"`
+	Weight uint64 `json:"weight"`
+}
+
+// DBCompaction reports the space reclaimed from a node db, in bytes
+type DBCompaction struct {
+	SizeBefore uint64 `json:"sizeBefore"`
+	SizeAfter  uint64 `json:"sizeAfter"`
+	Reclaimed  uint64 `json:"reclaimed"`
+	// True if the C-chain state was pruned before compacting
+	Pruned bool `json:"pruned"`
+}
+
+// DiskUsage is the disk space used by a network, in bytes
+type DiskUsage struct {
+	// Usage of the network root dir and the node dirs outside it
+	Total uint64 `json:"total"`
+	// 0 if the network has no budget
+	Budget uint64 `json:"budget,omitempty"`
+	// Node name --> usage of the node data, db and logs dirs
+	Nodes map[string]uint64 `json:"nodes"`
+	// Time of the measurement
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+// DrainOptions are the options of the removal of a node once drained
+type DrainOptions struct {
+	// Max time to wait for the API connections to the node to be closed.
+	// The node is removed anyway once it passes. Defaults to 30 seconds.
+	Timeout time.Duration
+	// If true, the node is first removed as a validator
+	// of the subnets it validates
+	RemoveSubnetValidators bool
+	// Max time the node is given to exit once sent a SIGTERM, after
+	// which it is killed. Defaults to 30 seconds, or to the deadline of
+	// the removal context if sooner.
+	GracePeriod time.Duration
+	// If true, the node is removed from the beacons before being stopped,
+	// and the removal waits up to [Timeout] for the other running nodes to
+	// drop it from their connected peers, so that their peer lists are
+	// settled on return. Nodes send no goodbye message: their peers learn
+	// of the departure from the connections closed on exit.
+	AwaitPeerUpdate bool
+}
+
+// UpgradeOptions are the options of a rolling upgrade of the nodes
+type UpgradeOptions struct {
+	// Names of the nodes to upgrade, in this order.
+	// Defaults to all the nodes not paused, by name.
+	NodeNames []string
+	// If not empty, plugin dir the nodes are restarted with
+	PluginDir string
+	// Max time for each upgraded node to become healthy. Defaults to 5 minutes.
+	NodeTimeout time.Duration
+	// Time to wait after a node is healthy before upgrading the next one
+	Interval time.Duration
+	// If true, a node that doesn't become healthy is rolled back to
+	// its config before the upgrade. See RollbackNode.
+	RollbackOnFailure bool
+}
+
+// LogEntry is a line of a node log, parsed from either
+// the JSON or the plain format of the node logs
+type LogEntry struct {
+	// Name of the node
+	Node string `json:"node"`
+	// Name of the log file without extension (e.g. "main", "C")
+	Log string `json:"log"`
+	// Time of the line, or when it was read if the line has none
+	Time  time.Time `json:"time"`
+	Level string    `json:"level,omitempty"`
+	// Logger of the line (e.g. "C Chain")
+	Logger  string `json:"logger,omitempty"`
+	Caller  string `json:"caller,omitempty"`
+	Message string `json:"message"`
+	// Structured fields of the line
+	Fields map[string]interface{} `json:"fields,omitempty"`
+}
+
+// LogCollectorOptions are the options of the collection of the node logs
+type LogCollectorOptions struct {
+	// Names of the logs to collect (e.g. "main", "C").
+	// Defaults to all the logs of the nodes.
+	LogNames []string
+	// If true, the lines already in the logs are collected,
+	// instead of only the lines written afterwards
+	FromStart bool
+	// If not empty, file the entries are also written to,
+	// as one JSON object per line
+	CombinedFile string
+	// If not empty, only the logs of the nodes of this group are
+	// collected, including the nodes that join it later
+	Group string
+}
+
+// Ways of running prometheus and grafana along a network
+const (
+	// As docker containers, from the prom/prometheus and grafana/grafana images
+	MonitoringLaunchDocker = "docker"
+	// As local processes of the prometheus and grafana-server binaries
+	MonitoringLaunchLocal = "local"
+)
+
+// MonitoringOptions are the options of the monitoring stack of a network
+type MonitoringOptions struct {
+	// How prometheus and grafana are run, MonitoringLaunchDocker or
+	// MonitoringLaunchLocal. If empty, only their configs are written.
+	Launch string
+	// Ports of prometheus and grafana. Default to 9090 and 3000.
+	PrometheusPort uint16
+	GrafanaPort    uint16
+	// Binaries run for MonitoringLaunchLocal. Default to
+	// prometheus and grafana-server, looked up in the PATH.
+	PrometheusBinary string
+	GrafanaBinary    string
+	// Optional grafana home dir, for grafana installs not finding theirs
+	GrafanaHomePath string
+	// Scrape interval of the nodes. Defaults to 15 seconds.
+	ScrapeInterval time.Duration
+}
+
+// Monitoring describes the monitoring stack of a network
+type Monitoring struct {
+	// Dir of the prometheus and grafana configs
+	Dir string `json:"dir"`
+	// Prometheus config scraping the nodes
+	PrometheusConfig string `json:"prometheusConfig"`
+	// Grafana provisioning dir, with the prometheus datasource
+	// and the default dashboards
+	GrafanaProvisioningDir string `json:"grafanaProvisioningDir"`
+	// Empty unless prometheus and grafana are launched
+	PrometheusURL string `json:"prometheusURL,omitempty"`
+	GrafanaURL    string `json:"grafanaURL,omitempty"`
+}
+
+// NodeHealthProgress is the startup progress of a node,
+// as reported by the health checks of its last health call
+type NodeHealthProgress struct {
+	Node    string
+	Healthy bool
+	// Aliases of the primary network chains whose health check passes,
+	// i.e. bootstrapped, and of the others
+	Bootstrapped  []string
+	Bootstrapping []string
+	// Number of peers the node is connected to, -1 if unknown
+	ConnectedPeers int
+	// Error of the health call, e.g. if the API of the node isn't up yet
+	Err error
+}
+
+func (p NodeHealthProgress) String() string {
+	if p.Healthy {
+		return "healthy"
+	}
+	if p.Err != nil {
+		return fmt.Sprintf("health check failed: %s", p.Err)
+	}
+	s := fmt.Sprintf("bootstrapped %v, bootstrapping %v", p.Bootstrapped, p.Bootstrapping)
+	if p.ConnectedPeers >= 0 {
+		s += fmt.Sprintf(", %d peers", p.ConnectedPeers)
+	}
+	return s
+}
+
 // Network is an abstraction of an Lux network
 type Network interface {
 	// Returns nil if all the nodes in the network are healthy.
 	// A stopped network is considered unhealthy.
 	// Timeout is given by the context parameter.
 	Healthy(context.Context) error
+	// Like Healthy, and calls [onProgress], one call at a time, each time
+	// the startup progress of a node changes, so that it shows where the
+	// startup stalls. The error of a node not healthy in time has its last progress.
+	HealthyWithProgress(ctx context.Context, onProgress func(NodeHealthProgress)) error
 	// Stop all the nodes.
 	// Returns ErrStopped if Stop() was previously called.
 	Stop(context.Context) error
-	// Start a new node with the given config.
+	// Start a new node with the given config. If the config has a
+	// PrimaryValidator, waits until the node is a primary network validator,
+	// for up to 5 minutes or until Stop is called. If the registration
+	// fails, the node is left running and returned along with the error.
+	// Use AddPrimaryValidator instead for a registration the caller can cancel.
 	// Returns ErrStopped if Stop() was previously called.
 	AddNode(node.Config) (node.Node, error)
+	// Cut the P2P connections between the nodes of [groupA] and the ones of
+	// [groupB], replacing the current partition if any. All the nodes of
+	// both groups must have a public IP, as the connections are cut at
+	// the port forwarders of the nodes.
+	// Returns ErrStopped if Stop() was previously called.
+	Partition(ctx context.Context, groupA []string, groupB []string) error
+	// Restore the P2P connectivity cut by Partition. The nodes reconnect on their own.
+	// Returns ErrStopped if Stop() was previously called.
+	Heal() error
+	// Set the latency, jitter and loss of the P2P traffic reaching the node
+	// with this name through its public IP, which it must have. Applies to
+	// the connections already made. Zero conditions remove them.
+	// Returns ErrStopped if Stop() was previously called.
+	SetLinkConditions(nodeName string, conditions node.LinkConditions) error
+	// Register the running node with this name as a primary network
+	// validator, as given by [cfg], and wait until it is validating.
+	// Does nothing if it already is a validator.
+	// Returns ErrStopped if Stop() was previously called.
+	AddPrimaryValidator(ctx context.Context, name string, cfg node.PrimaryValidatorConfig) error
 	// Stop the node with this name.
 	// Returns ErrStopped if Stop() was previously called.
 	RemoveNode(ctx context.Context, name string) error
+	// Stop the node with this name once the in-flight calls to its API are
+	// done, optionally removing it as a subnet validator first, so that
+	// the exit of a validator can be tested cleanly. The node is sent a
+	// SIGTERM, and killed if it's still running after the grace period.
+	// Returns ErrStopped if Stop() was previously called.
+	DrainNode(ctx context.Context, name string, opts DrainOptions) error
 	// Pause the node with this name.
 	// Returns ErrStopped if Stop() was previously called.
 	PauseNode(ctx context.Context, name string) error
 	// Resume the node with this name.
 	// Returns ErrStopped if Stop() was previously called.
 	ResumeNode(ctx context.Context, name string) error
+	// Freeze the process of the node with this name, keeping it in memory
+	// with its state, so it is unresponsive until unfrozen.
+	// Returns ErrStopped if Stop() was previously called.
+	FreezeNode(ctx context.Context, name string) error
+	// Unfreeze the node with this name.
+	// Returns ErrStopped if Stop() was previously called.
+	UnfreezeNode(ctx context.Context, name string) error
 	// Return the node with this name.
 	// Returns ErrStopped if Stop() was previously called.
 	GetNode(name string) (node.Node, error)
@@ -118,4 +387,98 @@ type Network interface {
 	RemoveSubnetValidators(context.Context, []RemoveSubnetValidatorSpec) error
 	// Get the elastic subnet tx id for the given subnet id
 	GetElasticSubnetID(context.Context, ids.ID) (ids.ID, error)
+	// Start an independent copy of the network, with the given name, identical chain
+	// state and configs, and new root dir, ports and node IDs. As the copied validator
+	// sets refer to the original node IDs, the copy runs with sybil protection disabled,
+	// and its nodes are registered as primary network validators.
+	// The network is briefly stopped in order to snapshot it. If it can't be
+	// restarted, it's left with no nodes and the snapshot is kept, as told by the error.
+	// Returns ErrStopped if Stop() was previously called.
+	CloneNetwork(context.Context, string) (Network, error)
+	// Start capturing the packets of the API and P2P ports of the node
+	// with this name. Returns the path of the capture file.
+	// Returns ErrStopped if Stop() was previously called.
+	StartCapture(ctx context.Context, name string) (string, error)
+	// Stop the packet capture of the node with this name.
+	// Returns the path of the capture file.
+	// Returns ErrStopped if Stop() was previously called.
+	StopCapture(ctx context.Context, name string) (string, error)
+	// Damage the db files of the paused node with this name, so its recovery
+	// on resume can be tested. Returns the damaged files, relative to the node db dir.
+	// Returns ErrStopped if Stop() was previously called.
+	CorruptNodeDB(ctx context.Context, name string, spec DBCorruptionSpec) ([]string, error)
+	// Open read only the db of the paused node with this name and summarize it.
+	// Returns ErrStopped if Stop() was previously called.
+	InspectNodeDB(ctx context.Context, name string) (DBInfo, error)
+	// Returns the last measured disk usage of the network.
+	// Returns ErrStopped if Stop() was previously called.
+	GetDiskUsage() (DiskUsage, error)
+	// Compact the db of the paused node with this name, optionally pruning
+	// the C-chain state first. The node is left paused.
+	// Returns ErrStopped if Stop() was previously called.
+	CompactNodeDB(ctx context.Context, name string, prune bool) (DBCompaction, error)
+	// Restart the node with this name with the config and config files
+	// it had before its last RestartNode, e.g. if it failed to become healthy.
+	// Returns ErrStopped if Stop() was previously called.
+	RollbackNode(ctx context.Context, name string) error
+	// Restart the nodes one at a time with the binary at this path, waiting
+	// for each to be healthy again before the next one, as operators roll
+	// out a new version. Stops at the first node that isn't healthy in time.
+	// Returns ErrStopped if Stop() was previously called.
+	UpgradeNodes(ctx context.Context, binaryPath string, opts UpgradeOptions) error
+	// Register a probe that must pass, after the nodes are healthy,
+	// for Healthy to return nil.
+	// Returns ErrStopped if Stop() was previously called.
+	AddReadinessProbe(ReadinessProbe) error
+	// Delegate stake from the test keychain to the validators of the specs,
+	// returning the IDs of the delegation txs.
+	// Returns ErrStopped if Stop() was previously called.
+	AddDelegators(ctx context.Context, specs []DelegatorSpec) ([]ids.ID, error)
+	// Collect the lines of the logs of all the nodes, including the nodes added
+	// later, annotated with their node, into one stream ordered by time. The
+	// channel is closed once the context is done or the network is stopped.
+	// Returns ErrStopped if Stop() was previously called.
+	CollectLogs(ctx context.Context, opts LogCollectorOptions) (<-chan LogEntry, error)
+	// Create or replace the node group with this name, targetable as a whole
+	// by bulk operations. The nodes removed from the network leave their groups.
+	// Returns ErrStopped if Stop() was previously called.
+	SetNodeGroup(name string, nodeNames []string) error
+	// Remove the node group with this name. Its nodes are left untouched.
+	// Returns ErrStopped if Stop() was previously called.
+	RemoveNodeGroup(name string) error
+	// Return the sorted names of the nodes of the group with this name.
+	// Returns ErrStopped if Stop() was previously called.
+	GetNodeGroup(name string) ([]string, error)
+	// Return all the node groups, including BeaconsNodeGroup.
+	// Group name --> sorted node names.
+	// Returns ErrStopped if Stop() was previously called.
+	GetNodeGroups() (map[string][]string, error)
+	// Add the subnet with this ID to the track-subnets flag of the nodes
+	// with these names, and restart the ones not tracking it yet. The
+	// paused nodes track it once resumed.
+	// Returns ErrStopped if Stop() was previously called.
+	TrackSubnet(ctx context.Context, nodeNames []string, subnetID ids.ID) error
+	// Write a prometheus config scraping the nodes, updated as nodes are
+	// added and removed, and a grafana provisioning of it along with the
+	// default consensus, networking and C-chain dashboards. Prometheus and
+	// grafana are launched if given a way to, and are stopped with the network.
+	// Returns ErrStopped if Stop() was previously called.
+	StartMonitoring(ctx context.Context, opts MonitoringOptions) (Monitoring, error)
+	// Subscribe to the lifecycle events of the given kinds, or of all kinds
+	// if none is given, instead of polling the network. The channel is closed
+	// once the context is done or the network is stopped. Events are
+	// dropped for a subscriber not keeping up.
+	// Returns ErrStopped if Stop() was previously called.
+	SubscribeEvents(ctx context.Context, kinds ...EventKind) (<-chan Event, error)
+	// Attach to the console of the node with this name: the lines written by
+	// its process to its stdout and stderr, across its restarts, until the
+	// context is done or the network is stopped. Lines are dropped for a
+	// subscriber not keeping up.
+	// Returns ErrStopped if Stop() was previously called.
+	AttachConsole(ctx context.Context, name string) (<-chan ConsoleLine, error)
+	// Send a signal to the process of the node with this name, e.g. SIGUSR1.
+	// On SIGQUIT, the node prints the stacks of its goroutines to its stderr
+	// and exits.
+	// Returns ErrStopped if Stop() was previously called.
+	SignalNode(ctx context.Context, name string, sig syscall.Signal) error
 }
@@ -0,0 +1,31 @@
+// Copyright (C) 2021-2024, Lux Partners Limited. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package node
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Database backends of a node
+const (
+	DBTypeLevelDB  = "leveldb"
+	DBTypePebbleDB = "pebbledb"
+	// In memory database. The node state is lost on stop.
+	DBTypeMemDB = "memdb"
+
+	DefaultDBType = DBTypeLevelDB
+)
+
+var ErrUnknownDBType = errors.New("unknown database type")
+
+// ValidateDBType returns an error if [dbType] is not a supported backend
+func ValidateDBType(dbType string) error {
+	switch dbType {
+	case DBTypeLevelDB, DBTypePebbleDB, DBTypeMemDB:
+		return nil
+	default:
+		return fmt.Errorf("%w %q: must be one of %s, %s, %s", ErrUnknownDBType, dbType, DBTypeLevelDB, DBTypePebbleDB, DBTypeMemDB)
+	}
+}
@@ -0,0 +1,167 @@
+// Copyright (C) 2021-2024, Lux Partners Limited. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package node
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+
+	"golang.org/x/mod/semver"
+)
+
+// Node features toggled with Config.Features. Proposervm activation is not
+// among them, as it's set by the network upgrade times, not per node.
+const (
+	// Indexer of the accepted containers (index-enabled)
+	FeatureIndexing = "indexing"
+	// Admin API (api-admin-enabled)
+	FeatureAdminAPI = "api-admin"
+	// IPCs API (api-ipcs-enabled), removed in v1.11.0
+	FeatureIPCsAPI = "api-ipcs"
+	// Metrics API (api-metrics-enabled)
+	FeatureMetricsAPI = "api-metrics"
+	// C-Chain state sync (state-sync-enabled of the C-Chain config), from v1.7.11
+	FeatureStateSync = "state-sync"
+)
+
+// Key of the node config JSON of a start or add node request that sets
+// the features of the node, as a map of feature name to enabled (e.g.
+// {"indexing":true}). It's not passed on to the node.
+const FeaturesConfigKey = "node-features"
+
+var (
+	ErrUnknownFeature     = errors.New("unknown node feature")
+	ErrUnsupportedFeature = errors.New("node feature not supported by node version")
+	ErrFeatureConflict    = errors.New("node feature conflicts with node config")
+)
+
+type featureSpec struct {
+	// node flag toggling the feature, if any
+	flag string
+	// chain alias and key of its chain config toggling the feature, if any
+	chainAlias     string
+	chainConfigKey string
+	// first node version with the feature, if not always there
+	minVersion string
+	// first node version without the feature, if removed
+	maxVersion string
+}
+
+var featureSpecs = map[string]featureSpec{
+	FeatureIndexing:   {flag: "index-enabled"},
+	FeatureAdminAPI:   {flag: "api-admin-enabled"},
+	FeatureIPCsAPI:    {flag: "api-ipcs-enabled", maxVersion: "v1.11.0"},
+	FeatureMetricsAPI: {flag: "api-metrics-enabled"},
+	FeatureStateSync:  {chainAlias: "C", chainConfigKey: "state-sync-enabled", minVersion: "v1.7.11"},
+}
+
+// Features returns the names of the node features, sorted
+func Features() []string {
+	features := make([]string, 0, len(featureSpecs))
+	for feature := range featureSpecs {
+		features = append(features, feature)
+	}
+	sort.Strings(features)
+	return features
+}
+
+func validateFeatures(features map[string]bool) error {
+	for feature := range features {
+		if _, ok := featureSpecs[feature]; !ok {
+			return fmt.Errorf("%w: %q", ErrUnknownFeature, feature)
+		}
+	}
+	return nil
+}
+
+// ParseFeatures returns the features given as the value [v]
+// of [FeaturesConfigKey] in a node config JSON
+func ParseFeatures(v interface{}) (map[string]bool, error) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	features := map[string]bool{}
+	if err := json.Unmarshal(b, &features); err != nil {
+		return nil, err
+	}
+	if err := validateFeatures(features); err != nil {
+		return nil, err
+	}
+	return features, nil
+}
+
+// ApplyFeatures sets the flags and chain configs of [c] toggling its
+// features, as named for the node version [nodeVersion] (e.g. v1.10.3).
+// Returns an error if a feature is unknown, if [nodeVersion] doesn't have
+// it, or if the flags or chain configs of [c] already set it otherwise.
+// The version is not checked if [nodeVersion] is empty.
+// [c.Flags] and [c.ChainConfigFiles] must not be nil.
+func (c *Config) ApplyFeatures(nodeVersion string) error {
+	if err := validateFeatures(c.Features); err != nil {
+		return err
+	}
+	features := make([]string, 0, len(c.Features))
+	for feature := range c.Features {
+		features = append(features, feature)
+	}
+	sort.Strings(features)
+	for _, feature := range features {
+		enabled := c.Features[feature]
+		spec := featureSpecs[feature]
+		if nodeVersion != "" && spec.minVersion != "" && semver.Compare(nodeVersion, spec.minVersion) < 0 {
+			return fmt.Errorf("%w: %q requires %s or later, node runs %s",
+				ErrUnsupportedFeature, feature, spec.minVersion, nodeVersion)
+		}
+		removed := nodeVersion != "" && spec.maxVersion != "" && semver.Compare(nodeVersion, spec.maxVersion) >= 0
+		if removed && enabled {
+			return fmt.Errorf("%w: %q was removed in %s, node runs %s",
+				ErrUnsupportedFeature, feature, spec.maxVersion, nodeVersion)
+		}
+		if spec.flag != "" {
+			if err := setFeatureKey(c.Flags, spec.flag, feature, enabled); err != nil {
+				return err
+			}
+			// the node doesn't accept the flag of a removed feature,
+			// which is disabled anyway
+			if removed {
+				delete(c.Flags, spec.flag)
+			}
+		}
+		if spec.chainConfigKey != "" {
+			chainConfig := map[string]interface{}{}
+			if chainConfigFile := c.ChainConfigFiles[spec.chainAlias]; chainConfigFile != "" {
+				if err := json.Unmarshal([]byte(chainConfigFile), &chainConfig); err != nil {
+					return fmt.Errorf("couldn't unmarshal %s chain config: %w", spec.chainAlias, err)
+				}
+			}
+			if err := setFeatureKey(chainConfig, spec.chainConfigKey, feature, enabled); err != nil {
+				return err
+			}
+			chainConfigBytes, err := json.Marshal(chainConfig)
+			if err != nil {
+				return err
+			}
+			c.ChainConfigFiles[spec.chainAlias] = string(chainConfigBytes)
+		}
+	}
+	return nil
+}
+
+// Sets [key] of [m] to [enabled] for [feature], unless
+// already set, in which case it must be set to [enabled]
+func setFeatureKey(m map[string]interface{}, key string, feature string, enabled bool) error {
+	v, ok := m[key]
+	if !ok {
+		m[key] = enabled
+		return nil
+	}
+	given, err := NewFlagValue(v).Bool()
+	if err != nil || given != enabled {
+		return fmt.Errorf("%w: %q enabled=%t but %s=%v", ErrFeatureConflict, feature, enabled, key, v)
+	}
+	return nil
+}
@@ -0,0 +1,150 @@
+// Copyright (C) 2021-2024, Lux Partners Limited. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package node
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+)
+
+var ErrFlagType = errors.New("unexpected flag type")
+
+// FlagValue is the value of a node flag. It holds a string if the flag
+// was set by the runner, a JSON type (string, bool, float64, []interface{}
+// or map[string]interface{}) if read from the node config file, or the
+// type it was given with in the node config flags.
+// The zero value is an unset flag.
+type FlagValue struct {
+	value interface{}
+}
+
+// NewFlagValue returns the flag value holding [value]
+func NewFlagValue(value interface{}) FlagValue {
+	return FlagValue{value: value}
+}
+
+// IsSet returns true if the flag was given or set by the runner
+func (v FlagValue) IsSet() bool {
+	return v.value != nil
+}
+
+// Value returns the underlying value, nil if unset
+func (v FlagValue) Value() interface{} {
+	return v.value
+}
+
+// String returns the value as it is passed to the node
+// on the command line, or the empty string if unset
+func (v FlagValue) String() string {
+	if v.value == nil {
+		return ""
+	}
+	if s, ok := v.value.(string); ok {
+		return s
+	}
+	return fmt.Sprintf("%v", v.value)
+}
+
+// Bool returns the value of a boolean flag, false if unset
+func (v FlagValue) Bool() (bool, error) {
+	switch value := v.value.(type) {
+	case nil:
+		return false, nil
+	case bool:
+		return value, nil
+	case string:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return false, fmt.Errorf("%w: %q is not a bool", ErrFlagType, value)
+		}
+		return b, nil
+	default:
+		return false, fmt.Errorf("%w: expected bool got %T", ErrFlagType, v.value)
+	}
+}
+
+// Int returns the value of an integer flag, 0 if unset
+func (v FlagValue) Int() (int64, error) {
+	switch value := v.value.(type) {
+	case nil:
+		return 0, nil
+	case int:
+		return int64(value), nil
+	case int64:
+		return value, nil
+	case int32:
+		return int64(value), nil
+	case uint:
+		return int64(value), nil
+	case uint64:
+		if value > math.MaxInt64 {
+			return 0, fmt.Errorf("%w: %d overflows int64", ErrFlagType, value)
+		}
+		return int64(value), nil
+	case uint32:
+		return int64(value), nil
+	case uint16:
+		return int64(value), nil
+	case float64:
+		// JSON numbers
+		if value != math.Trunc(value) || value > math.MaxInt64 || value < math.MinInt64 {
+			return 0, fmt.Errorf("%w: %v is not an integer", ErrFlagType, value)
+		}
+		return int64(value), nil
+	case string:
+		i, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("%w: %q is not an integer", ErrFlagType, value)
+		}
+		return i, nil
+	default:
+		return 0, fmt.Errorf("%w: expected integer got %T", ErrFlagType, v.value)
+	}
+}
+
+// Float returns the value of a numeric flag, 0 if unset
+func (v FlagValue) Float() (float64, error) {
+	switch value := v.value.(type) {
+	case float64:
+		return value, nil
+	case float32:
+		return float64(value), nil
+	case string:
+		f, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return 0, fmt.Errorf("%w: %q is not a number", ErrFlagType, value)
+		}
+		return f, nil
+	default:
+		i, err := v.Int()
+		if err != nil {
+			return 0, fmt.Errorf("%w: expected number got %T", ErrFlagType, v.value)
+		}
+		return float64(i), nil
+	}
+}
+
+// Duration returns the value of a duration flag, 0 if unset.
+// As the node does, numbers are taken as nanoseconds.
+func (v FlagValue) Duration() (time.Duration, error) {
+	switch value := v.value.(type) {
+	case time.Duration:
+		return value, nil
+	case string:
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return 0, fmt.Errorf("%w: %q is not a duration", ErrFlagType, value)
+		}
+		return d, nil
+	default:
+		i, err := v.Int()
+		if err != nil {
+			return 0, fmt.Errorf("%w: expected duration got %T", ErrFlagType, v.value)
+		}
+		return time.Duration(i), nil
+	}
+}
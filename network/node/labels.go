@@ -0,0 +1,43 @@
+// Copyright (C) 2021-2024, Lux Partners Limited. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package node
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+)
+
+var (
+	ErrInvalidLabel = errors.New("invalid label")
+
+	// labels are also metrics labels, so keys follow the prometheus label name rules
+	labelKeyRegex = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+)
+
+// ValidateLabels returns an error if a key of [labels] is not a valid label name
+func ValidateLabels(labels map[string]string) error {
+	for key := range labels {
+		if !labelKeyRegex.MatchString(key) {
+			return fmt.Errorf("%w %q: must start with a letter or '_' and contain only letters, digits or '_'", ErrInvalidLabel, key)
+		}
+	}
+	return nil
+}
+
+// MergeLabels returns the labels of [base] and [labels],
+// the latter taking precedence. The maps are not modified.
+func MergeLabels(base map[string]string, labels map[string]string) map[string]string {
+	if len(base) == 0 && len(labels) == 0 {
+		return nil
+	}
+	merged := make(map[string]string, len(base)+len(labels))
+	for k, v := range base {
+		merged[k] = v
+	}
+	for k, v := range labels {
+		merged[k] = v
+	}
+	return merged
+}
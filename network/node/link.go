@@ -0,0 +1,48 @@
+// Copyright (C) 2021-2024, Lux Partners Limited. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package node
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// Key of the node config JSON of a start or add node request that sets the
+// link conditions of the node. Its value is a LinkConditions object (e.g.
+// {"latencyMs": 50, "jitterMs": 10, "lossPct": 1}). It's not passed on to the node.
+const LinkConditionsConfigKey = "node-link-conditions"
+
+// LinkConditions of the P2P traffic reaching a node through its public IP,
+// applied by the proxy forwarding its P2P port, in each direction
+type LinkConditions struct {
+	// Delay added to [Config.Latency] or to the latency of the regions
+	LatencyMs uint32 `json:"latencyMs,omitempty"`
+	// Max random delay added to LatencyMs
+	JitterMs uint32 `json:"jitterMs,omitempty"`
+	// Percentage of the traffic lost. As the proxy forwards a TCP stream,
+	// the loss is simulated by the retransmission delay of the lost data.
+	LossPct float64 `json:"lossPct,omitempty"`
+}
+
+// Validate returns an error if these conditions are invalid
+func (c *LinkConditions) Validate() error {
+	if c.LossPct < 0 || c.LossPct > 100 {
+		return fmt.Errorf("invalid loss percentage %v: expected a value from 0 to 100", c.LossPct)
+	}
+	return nil
+}
+
+// ParseLinkConditions returns the conditions held by the value of
+// [LinkConditionsConfigKey] in a node config JSON
+func ParseLinkConditions(value interface{}) (*LinkConditions, error) {
+	b, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	c := &LinkConditions{}
+	if err := json.Unmarshal(b, c); err != nil {
+		return nil, err
+	}
+	return c, c.Validate()
+}
@@ -0,0 +1,32 @@
+// Copyright (C) 2021-2024, Lux Partners Limited. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package node
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+)
+
+// Max length of a node name
+const MaxNameLen = 64
+
+var (
+	ErrInvalidName = errors.New("invalid node name")
+
+	// node names are used as directory names, metrics labels
+	// and URL path segments of the API proxy
+	nameRegex = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9_-]*$`)
+)
+
+// ValidateName returns an error if [name] is not a valid node name
+func ValidateName(name string) error {
+	if len(name) > MaxNameLen {
+		return fmt.Errorf("%w %q: longer than %d characters", ErrInvalidName, name, MaxNameLen)
+	}
+	if !nameRegex.MatchString(name) {
+		return fmt.Errorf("%w %q: must start with a letter or digit and contain only letters, digits, '_' or '-'", ErrInvalidName, name)
+	}
+	return nil
+}
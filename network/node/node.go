@@ -5,6 +5,10 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"time"
 
 	"github.com/luxdefi/netrunner/api"
 	"github.com/luxdefi/netrunner/network/node/status"
@@ -24,7 +28,18 @@ type Node interface {
 	// Return a client that can be used to make API calls.
 	GetAPIClient() api.Client
 	// Return this node's IP (e.g. 127.0.0.1).
+	// Prefer GetAPIBaseURI, GetChainURI and GetWSURI to reach its API.
 	GetURL() string
+	// Return the base URI of this node's API, with the scheme the API is
+	// served with and the host the API is reachable at (e.g. http://127.0.0.1:9650).
+	GetAPIBaseURI() string
+	// Return the URI of the chain with [alias] or blockchain ID on this node's API.
+	// For EVM chains, that is the C-Chain and any chain but the P-Chain and
+	// X-Chain, the URI of the JSON-RPC endpoint (e.g. http://127.0.0.1:9650/ext/bc/C/rpc).
+	GetChainURI(alias string) string
+	// Return the websocket URI of the EVM chain with [alias] or
+	// blockchain ID (e.g. ws://127.0.0.1:9650/ext/bc/C/ws).
+	GetWSURI(alias string) string
 	// Return this node's P2P (staking) port.
 	GetP2PPort() uint16
 	// Return this node's HTTP API port.
@@ -53,12 +68,39 @@ type Node interface {
 	GetConfigFile() string
 	// Return this node's config
 	GetConfig() Config
-	// Return this node's flag value
-	GetFlag(string) (string, error)
+	// Return the value of a flag this node was started with. In order of precedence,
+	// the value is taken from:
+	// 1. The node config flags, network config flags included
+	// 2. The flags set by the runner (e.g. ports, dirs, bootstrappers)
+	// 3. The node config file
+	// The returned value is unset if the flag is given in none of them.
+	GetFlag(string) (FlagValue, error)
+	// Return the IDs of the subnets this node tracks, as given by
+	// its track-subnets flag. See Network.TrackSubnet.
+	GetTrackedSubnets() ([]ids.ID, error)
 	// Return this node's paused status
 	GetPaused() bool
+	// Return the timings of this node's bring-up. The API up and healthy
+	// times are observed by the network health checks.
+	GetTimings() Timings
+	// Return the runtime info of this node's process
+	GetRuntimeInfo() RuntimeInfo
+	// Return a reader of this node's log [logName] (e.g. "main" for main.log,
+	// the default, or "C" for the C-Chain log), from its start. If [follow],
+	// reading past its end waits for new lines, as tail -f, until [ctx] is done.
+	StreamLogs(ctx context.Context, logName string, follow bool) (io.ReadCloser, error)
 }
 
+// Keys of the node config JSON of a start or add node request that set
+// the public IP, latency and region of the node (e.g. "127.0.1.1",
+// "100ms" and "eu-west").
+// They're not passed on to the node.
+const (
+	PublicIPConfigKey = "node-public-ip"
+	LatencyConfigKey  = "node-latency"
+	RegionConfigKey   = "node-region"
+)
+
 // Config encapsulates an node configuration
 type Config struct {
 	// A node's name must be unique from all other nodes
@@ -91,10 +133,69 @@ type Config struct {
 	Flags map[string]interface{} `json:"flags"`
 	// What type of node this is
 	BinaryPath string `json:"binaryPath"`
+	// If BinaryPath is empty, luxd version (e.g. v1.10.3) of the node. Its
+	// release is downloaded and cached by the binaries package.
+	BinaryVersion string `json:"binaryVersion,omitempty"`
 	// If non-nil, direct this node's Stdout to os.Stdout
 	RedirectStdout bool `json:"redirectStdout"`
 	// If non-nil, direct this node's Stderr to os.Stderr
 	RedirectStderr bool `json:"redirectStderr"`
+	// Optional node role (e.g. RoleAPI). See ApplyRolePreset.
+	Role string `json:"role,omitempty"`
+	// Optional IP the node binds its API and P2P ports to, and advertises
+	// to its peers (e.g. a loopback alias 127.0.0.x or an interface address).
+	// Defaults to the loopback address.
+	BindIP string `json:"bindIP,omitempty"`
+	// Optional IP the node advertises to its peers, if different from its
+	// bind IP. The runner forwards the P2P port from the public IP to the
+	// bind IP, as a NAT with port forwarding would.
+	PublicIP string `json:"publicIP,omitempty"`
+	// If true, the P2P port is not forwarded from the public IP, so peers
+	// can't open connections to the node, as behind a NAT without port
+	// forwarding. Requires PublicIP.
+	BlockInbound bool `json:"blockInbound,omitempty"`
+	// Optional delay of the P2P traffic reaching the node through its
+	// public IP, in each direction, simulating the latency of a WAN link.
+	// Requires PublicIP.
+	Latency time.Duration `json:"latency,omitempty"`
+	// Optional region of the node. The P2P traffic reaching the node
+	// through its public IP from a node of another region is delayed by
+	// the latency between the regions, instead of [Latency], as per the
+	// network topology (see network.Topology). Requires PublicIP.
+	Region string `json:"region,omitempty"`
+	// Optional latency, jitter and loss of the P2P traffic reaching the
+	// node through its public IP, changed on a running network with
+	// Network.SetLinkConditions. Requires PublicIP.
+	LinkConditions *LinkConditions `json:"linkConditions,omitempty"`
+	// Optional database backend (e.g. DBTypePebbleDB).
+	// Defaults to the db type flag, or else to DefaultDBType.
+	DBType string `json:"dbType,omitempty"`
+	// Optional working dir of the node process, where VM plugins write
+	// their relative path files. Relative to the node data dir, which is
+	// the default.
+	WorkDir string `json:"workDir,omitempty"`
+	// Optional umask of the node process, in octal (e.g. "027").
+	// Defaults to the umask of the runner.
+	Umask string `json:"umask,omitempty"`
+	// Optional CPU, memory and open files limits of the node process.
+	// See ResourceLimits.
+	Resources *ResourceLimits `json:"resources,omitempty"`
+	// Optional key/value labels of the node (e.g. owner, purpose), shown in
+	// its status and metrics. The network labels are added to them.
+	Labels map[string]string `json:"labels,omitempty"`
+	// Optional feature toggles of the node, by feature name (e.g.
+	// FeatureIndexing), set as the flags and chain configs of the
+	// node version. See ApplyFeatures.
+	Features map[string]bool `json:"features,omitempty"`
+	// If given, the node is registered as a primary network validator when
+	// added to a running network with AddNode, which returns once the node
+	// is validating. Ignored for the nodes the network is created with.
+	PrimaryValidator *PrimaryValidatorConfig `json:"primaryValidator,omitempty"`
+	// Optional container image the node is run from instead of the binary,
+	// on networks running their nodes as containers (e.g. "luxdefi/node").
+	Image string `json:"image,omitempty"`
+	// Optional tag of the image. Defaults to "latest".
+	ImageTag string `json:"imageTag,omitempty"`
 }
 
 // Validate returns an error if this config is invalid
@@ -104,9 +205,109 @@ func (c *Config) Validate(expectedNetworkID uint32) error {
 		return errors.New("staking key not given")
 	case c.StakingCert == "":
 		return errors.New("staking cert not given")
-	default:
-		return validateConfigFile([]byte(c.ConfigFile), expectedNetworkID)
 	}
+	if err := validateRole(c.Role, c.IsBeacon); err != nil {
+		return err
+	}
+	if c.BindIP != "" && net.ParseIP(c.BindIP) == nil {
+		return fmt.Errorf("invalid bind IP %q", c.BindIP)
+	}
+	if c.PublicIP != "" && net.ParseIP(c.PublicIP) == nil {
+		return fmt.Errorf("invalid public IP %q", c.PublicIP)
+	}
+	if c.BlockInbound && c.PublicIP == "" {
+		return errors.New("blocking inbound connections requires a public IP")
+	}
+	if c.Latency < 0 {
+		return fmt.Errorf("negative latency %s", c.Latency)
+	}
+	if c.Latency > 0 && (c.PublicIP == "" || c.BlockInbound) {
+		return errors.New("latency requires a public IP accepting inbound connections")
+	}
+	if c.Region != "" && (c.PublicIP == "" || c.BlockInbound) {
+		return errors.New("region requires a public IP accepting inbound connections")
+	}
+	if c.LinkConditions != nil {
+		if c.PublicIP == "" || c.BlockInbound {
+			return errors.New("link conditions require a public IP accepting inbound connections")
+		}
+		if err := c.LinkConditions.Validate(); err != nil {
+			return err
+		}
+	}
+	if c.ImageTag != "" && c.Image == "" {
+		return errors.New("image tag given without an image")
+	}
+	if c.DBType != "" {
+		if err := ValidateDBType(c.DBType); err != nil {
+			return err
+		}
+	}
+	if err := ValidateFlags(c.Flags); err != nil {
+		return err
+	}
+	if err := ValidateLabels(c.Labels); err != nil {
+		return err
+	}
+	if err := validateFeatures(c.Features); err != nil {
+		return err
+	}
+	if c.Resources != nil {
+		if err := c.Resources.Validate(); err != nil {
+			return err
+		}
+	}
+	if c.PrimaryValidator != nil {
+		if err := c.PrimaryValidator.Validate(); err != nil {
+			return err
+		}
+	}
+	if c.Umask != "" {
+		if umask, err := strconv.ParseUint(c.Umask, 8, 32); err != nil || umask > 0o777 {
+			return fmt.Errorf("invalid umask %q: expected an octal value up to 777", c.Umask)
+		}
+	}
+	return validateConfigFile([]byte(c.ConfigFile), expectedNetworkID)
+}
+
+// TakeLinkConfigKeys sets the public IP, latency, region and link conditions
+// of [c] to the values of [PublicIPConfigKey], [LatencyConfigKey],
+// [RegionConfigKey] and [LinkConditionsConfigKey] in the node config JSON
+// [flags], if given, and removes them from [flags]
+func (c *Config) TakeLinkConfigKeys(flags map[string]interface{}) error {
+	if v, ok := flags[PublicIPConfigKey]; ok {
+		publicIP, ok := v.(string)
+		if !ok {
+			return fmt.Errorf("invalid %q value %v", PublicIPConfigKey, v)
+		}
+		c.PublicIP = publicIP
+		delete(flags, PublicIPConfigKey)
+	}
+	if v, ok := flags[LatencyConfigKey]; ok {
+		latency, err := NewFlagValue(v).Duration()
+		if err != nil {
+			return fmt.Errorf("invalid %q value %v: %w", LatencyConfigKey, v, err)
+		}
+		c.Latency = latency
+		delete(flags, LatencyConfigKey)
+	}
+	if v, ok := flags[RegionConfigKey]; ok {
+		region, ok := v.(string)
+		if !ok {
+			return fmt.Errorf("invalid %q value %v", RegionConfigKey, v)
+		}
+		c.Region = region
+		delete(flags, RegionConfigKey)
+	}
+	if v, ok := flags[LinkConditionsConfigKey]; ok {
+		conditions, err := ParseLinkConditions(v)
+		if err != nil {
+			return fmt.Errorf("invalid %q value %v: %w", LinkConditionsConfigKey, v, err)
+		}
+		c.LinkConditions = conditions
+		delete(flags, LinkConditionsConfigKey)
+	}
+	return nil
 }
 
 // Returns an error if config file [configFile] is invalid.
@@ -0,0 +1,66 @@
+// Copyright (C) 2021-2024, Lux Partners Limited. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package node
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// Key of the node config JSON of a start or add node request that sets the
+// resource limits of the node. Its value is a ResourceLimits object (e.g.
+// {"cpus": 1.5, "memory": "2GiB", "maxOpenFiles": 4096}). It's not passed
+// on to the node.
+const ResourcesConfigKey = "node-resources"
+
+// ResourceLimits of a node process, so that a node of a large network
+// doesn't starve the others running on the same host. The CPU and memory
+// limits are applied with a cgroup on Linux, along with the plugin
+// processes of the node. Without cgroups, the memory limit is applied as
+// the address space rlimit of each process instead, and the CPU limit is
+// not applied.
+type ResourceLimits struct {
+	// Max CPU time, in CPUs (e.g. 0.5 for half a core). No limit if 0.
+	CPUs float64 `json:"cpus,omitempty"`
+	// Max memory, as a number of bytes or a size string (e.g. "2GiB").
+	// No limit if empty. The node is killed, or fails, beyond it.
+	Memory string `json:"memory,omitempty"`
+	// Max number of open files (the nofile rlimit). The node needs one
+	// per peer connection, so large networks may need more than the
+	// default of the host. Inherited from the runner if 0.
+	MaxOpenFiles uint64 `json:"maxOpenFiles,omitempty"`
+}
+
+// Validate returns an error if these limits are invalid
+func (l *ResourceLimits) Validate() error {
+	if l.CPUs < 0 {
+		return fmt.Errorf("invalid CPU limit %v: expected a positive number of CPUs", l.CPUs)
+	}
+	if _, err := l.MemoryBytes(); err != nil {
+		return fmt.Errorf("invalid memory limit: %w", err)
+	}
+	return nil
+}
+
+// MemoryBytes returns the memory limit in bytes, 0 if there is none
+func (l *ResourceLimits) MemoryBytes() (uint64, error) {
+	if l.Memory == "" {
+		return 0, nil
+	}
+	return ParseSize(l.Memory)
+}
+
+// ParseResourceLimits returns the limits held by the value of
+// [ResourcesConfigKey] in a node config JSON
+func ParseResourceLimits(value interface{}) (*ResourceLimits, error) {
+	b, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	l := &ResourceLimits{}
+	if err := json.Unmarshal(b, l); err != nil {
+		return nil, err
+	}
+	return l, l.Validate()
+}
@@ -0,0 +1,105 @@
+// Copyright (C) 2021-2024, Lux Partners Limited. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package node
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// Node roles. A role is a preset of flags and chain configs
+// applied on node creation. The empty role is a regular node.
+const (
+	// Non validating node serving APIs, bootstrapped with state sync
+	RoleAPI = "api"
+	// Node keeping the full history, with the indexer enabled
+	RoleArchival = "archival"
+)
+
+// Key of the node config JSON of an add node request that
+// sets the node role. It's not passed on to the node.
+const RoleConfigKey = "node-role"
+
+var ErrUnknownRole = errors.New("unknown node role")
+
+type rolePreset struct {
+	// node flags
+	flags map[string]interface{}
+	// chain alias --> chain config keys
+	chainConfigs map[string]map[string]interface{}
+	// true if nodes with the role can't be beacons
+	nonBeacon bool
+}
+
+var rolePresets = map[string]rolePreset{
+	RoleAPI: {
+		chainConfigs: map[string]map[string]interface{}{
+			"C": {
+				"state-sync-enabled": true,
+			},
+		},
+		nonBeacon: true,
+	},
+	RoleArchival: {
+		flags: map[string]interface{}{
+			"index-enabled":          true,
+			"index-allow-incomplete": true,
+		},
+		chainConfigs: map[string]map[string]interface{}{
+			"C": {
+				"pruning-enabled":    false,
+				"state-sync-enabled": false,
+			},
+		},
+	},
+}
+
+func validateRole(role string, isBeacon bool) error {
+	if role == "" {
+		return nil
+	}
+	preset, ok := rolePresets[role]
+	if !ok {
+		return fmt.Errorf("%w: %q", ErrUnknownRole, role)
+	}
+	if preset.nonBeacon && isBeacon {
+		return fmt.Errorf("node with role %q can't be a beacon", role)
+	}
+	return nil
+}
+
+// ApplyRolePreset adds the flags and chain configs of the node role to [c].
+// Flags and chain config keys already given in [c] take precedence.
+// [c.Flags] and [c.ChainConfigFiles] must not be nil.
+func (c *Config) ApplyRolePreset() error {
+	if err := validateRole(c.Role, c.IsBeacon); err != nil {
+		return err
+	}
+	preset := rolePresets[c.Role]
+	for k, v := range preset.flags {
+		if _, ok := c.Flags[k]; !ok {
+			c.Flags[k] = v
+		}
+	}
+	for chainAlias, presetConfig := range preset.chainConfigs {
+		chainConfig := map[string]interface{}{}
+		if chainConfigFile := c.ChainConfigFiles[chainAlias]; chainConfigFile != "" {
+			if err := json.Unmarshal([]byte(chainConfigFile), &chainConfig); err != nil {
+				return fmt.Errorf("couldn't unmarshal %s chain config: %w", chainAlias, err)
+			}
+		}
+		for k, v := range presetConfig {
+			if _, ok := chainConfig[k]; !ok {
+				chainConfig[k] = v
+			}
+		}
+		chainConfigBytes, err := json.Marshal(chainConfig)
+		if err != nil {
+			return err
+		}
+		c.ChainConfigFiles[chainAlias] = string(chainConfigBytes)
+	}
+	return nil
+}
@@ -0,0 +1,19 @@
+// Copyright (C) 2021-2024, Lux Partners Limited. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package node
+
+import "time"
+
+// RuntimeInfo of the process of a node, to correlate it with OS tooling
+type RuntimeInfo struct {
+	// ID of the node process, 0 if it is not running
+	PID int `json:"pid"`
+	// When the node process was last started
+	StartedAt time.Time `json:"startedAt"`
+	// Number of times the node was resumed, restarted or rolled back
+	Restarts uint32 `json:"restarts"`
+	// Exit code of the last node process that exited, -1 if it was
+	// killed by a signal. Nil if no process of the node exited yet.
+	LastExitCode *int `json:"lastExitCode,omitempty"`
+}
@@ -0,0 +1,20 @@
+// Copyright (C) 2021-2024, Lux Partners Limited. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package node
+
+import "time"
+
+// Timings of the bring-up of a node, from its last start.
+// Durations are encoded in JSON as nanoseconds.
+type Timings struct {
+	// When the node process was started
+	StartedAt time.Time `json:"startedAt"`
+	// Time taken to spawn the node process
+	ProcessSpawn time.Duration `json:"processSpawn"`
+	// Time until the node API first answered a health check,
+	// 0 if not observed yet
+	APIUp time.Duration `json:"apiUp,omitempty"`
+	// Time until the node first reported healthy, 0 if not observed yet
+	Healthy time.Duration `json:"healthy,omitempty"`
+}
@@ -0,0 +1,209 @@
+// Copyright (C) 2021-2024, Lux Partners Limited. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package node
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// FlagKind is the kind of value of a node flag given with units
+type FlagKind int
+
+const (
+	// Duration flag. Given as a duration string (e.g. "30s", "1h30m", "7d")
+	// or nanoseconds, passed to the node as a Go duration string.
+	DurationFlag FlagKind = iota + 1
+	// Byte size flag. Given as a number of bytes or a size string (e.g. "512KiB",
+	// "2GiB", "1.5GB"), passed to the node as a number of bytes.
+	SizeFlag
+)
+
+var (
+	ErrInvalidDuration = errors.New("invalid duration")
+	ErrInvalidSize     = errors.New("invalid size")
+
+	sizeRegex = regexp.MustCompile(`^([0-9]+(?:\.[0-9]+)?)\s*([a-zA-Z]*)$`)
+	// size unit, lowercased --> bytes
+	sizeUnits = map[string]float64{
+		"":    1,
+		"b":   1,
+		"kb":  1e3,
+		"mb":  1e6,
+		"gb":  1e9,
+		"tb":  1e12,
+		"kib": 1 << 10,
+		"mib": 1 << 20,
+		"gib": 1 << 30,
+		"tib": 1 << 40,
+	}
+	// extra duration units, not supported by time.ParseDuration
+	dayRegex = regexp.MustCompile(`^([0-9]+)d(.*)$`)
+
+	// flag name --> kind of the node flags given with units
+	flagKinds = map[string]FlagKind{
+		"network-initial-timeout":                               DurationFlag,
+		"network-minimum-timeout":                               DurationFlag,
+		"network-maximum-timeout":                               DurationFlag,
+		"network-maximum-inbound-timeout":                       DurationFlag,
+		"network-timeout-halflife":                              DurationFlag,
+		"network-health-max-time-since-msg-sent":                DurationFlag,
+		"network-health-max-time-since-msg-received":            DurationFlag,
+		"network-ping-timeout":                                  DurationFlag,
+		"network-ping-frequency":                                DurationFlag,
+		"network-read-handshake-timeout":                        DurationFlag,
+		"network-peer-list-gossip-frequency":                    DurationFlag,
+		"network-initial-reconnect-delay":                       DurationFlag,
+		"network-max-reconnect-delay":                           DurationFlag,
+		"network-outbound-connection-timeout":                   DurationFlag,
+		"network-max-clock-difference":                          DurationFlag,
+		"benchlist-duration":                                    DurationFlag,
+		"benchlist-min-failing-duration":                        DurationFlag,
+		"health-check-frequency":                                DurationFlag,
+		"health-check-averager-halflife":                        DurationFlag,
+		"bootstrap-retry-warn-frequency":                        DurationFlag,
+		"bootstrap-max-time-get-ancestors":                      DurationFlag,
+		"consensus-shutdown-timeout":                            DurationFlag,
+		"uptime-metric-freq":                                    DurationFlag,
+		"system-tracker-frequency":                              DurationFlag,
+		"system-tracker-processing-halflife":                    DurationFlag,
+		"http-read-timeout":                                     DurationFlag,
+		"http-read-header-timeout":                              DurationFlag,
+		"http-write-timeout":                                    DurationFlag,
+		"http-idle-timeout":                                     DurationFlag,
+		"http-shutdown-timeout":                                 DurationFlag,
+		"http-shutdown-wait":                                    DurationFlag,
+		"min-stake-duration":                                    DurationFlag,
+		"max-stake-duration":                                    DurationFlag,
+		"stake-minting-period":                                  DurationFlag,
+		"network-peer-read-buffer-size":                         SizeFlag,
+		"network-peer-write-buffer-size":                        SizeFlag,
+		"throttler-inbound-at-large-alloc-size":                 SizeFlag,
+		"throttler-inbound-validator-alloc-size":                SizeFlag,
+		"throttler-inbound-node-max-at-large-bytes":             SizeFlag,
+		"throttler-inbound-bandwidth-refill-rate":               SizeFlag,
+		"throttler-inbound-bandwidth-max-burst-size":            SizeFlag,
+		"throttler-outbound-at-large-alloc-size":                SizeFlag,
+		"throttler-outbound-validator-alloc-size":               SizeFlag,
+		"throttler-outbound-node-max-at-large-bytes":            SizeFlag,
+		"system-tracker-disk-required-available-space":          SizeFlag,
+		"system-tracker-disk-warning-threshold-available-space": SizeFlag,
+	}
+)
+
+// RegisterFlagKind makes the values of the node flag [name] be
+// given with units, e.g. for flags of custom node builds
+func RegisterFlagKind(name string, kind FlagKind) {
+	flagKinds[name] = kind
+}
+
+// ParseDuration parses a Go duration string, also accepting
+// a leading number of days (e.g. "7d", "1d12h")
+func ParseDuration(s string) (time.Duration, error) {
+	var days time.Duration
+	rest := s
+	if matches := dayRegex.FindStringSubmatch(s); matches != nil {
+		n, err := strconv.ParseInt(matches[1], 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("%w %q", ErrInvalidDuration, s)
+		}
+		days = time.Duration(n) * 24 * time.Hour
+		if matches[2] == "" {
+			return days, nil
+		}
+		rest = matches[2]
+	}
+	d, err := time.ParseDuration(rest)
+	if err != nil {
+		return 0, fmt.Errorf("%w %q", ErrInvalidDuration, s)
+	}
+	return days + d, nil
+}
+
+// ParseSize parses a number of bytes with an optional decimal (kB, MB, GB, TB)
+// or binary (KiB, MiB, GiB, TiB) unit. Units are case insensitive.
+func ParseSize(s string) (uint64, error) {
+	matches := sizeRegex.FindStringSubmatch(strings.TrimSpace(s))
+	if matches == nil {
+		return 0, fmt.Errorf("%w %q", ErrInvalidSize, s)
+	}
+	unit, ok := sizeUnits[strings.ToLower(matches[2])]
+	if !ok {
+		return 0, fmt.Errorf("%w %q: unknown unit %q", ErrInvalidSize, s, matches[2])
+	}
+	n, err := strconv.ParseFloat(matches[1], 64)
+	if err != nil {
+		return 0, fmt.Errorf("%w %q", ErrInvalidSize, s)
+	}
+	size := n * unit
+	if size != math.Trunc(size) || size > math.MaxUint64 {
+		return 0, fmt.Errorf("%w %q: not a whole number of bytes", ErrInvalidSize, s)
+	}
+	return uint64(size), nil
+}
+
+// NormalizeFlags converts in place the values of the duration and size
+// flags of [flags] to the format the node expects.
+// Duration strings must have a unit. As the node does, duration
+// numbers (e.g. from JSON) are taken as nanoseconds.
+func NormalizeFlags(flags map[string]interface{}) error {
+	for name, value := range flags {
+		normalized, err := normalizeFlag(name, value)
+		if err != nil {
+			return err
+		}
+		flags[name] = normalized
+	}
+	return nil
+}
+
+// ValidateFlags returns an error if a duration or size flag of [flags]
+// can't be normalized
+func ValidateFlags(flags map[string]interface{}) error {
+	for name, value := range flags {
+		if _, err := normalizeFlag(name, value); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func normalizeFlag(name string, value interface{}) (interface{}, error) {
+	switch flagKinds[name] {
+	case DurationFlag:
+		s, ok := value.(string)
+		if !ok {
+			d, err := NewFlagValue(value).Duration()
+			if err != nil {
+				return nil, fmt.Errorf("flag %q: %w %v", name, ErrInvalidDuration, value)
+			}
+			return d.String(), nil
+		}
+		d, err := ParseDuration(s)
+		if err != nil {
+			return nil, fmt.Errorf("flag %q: %w: give a duration with a unit (e.g. \"30s\")", name, err)
+		}
+		return d.String(), nil
+	case SizeFlag:
+		if s, ok := value.(string); ok {
+			size, err := ParseSize(s)
+			if err != nil {
+				return nil, fmt.Errorf("flag %q: %w", name, err)
+			}
+			return size, nil
+		}
+		size, err := NewFlagValue(value).Int()
+		if err != nil || size < 0 {
+			return nil, fmt.Errorf("flag %q: %w %v", name, ErrInvalidSize, value)
+		}
+		return uint64(size), nil
+	default:
+		return value, nil
+	}
+}
@@ -0,0 +1,31 @@
+// Copyright (C) 2021-2024, Lux Partners Limited. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package node
+
+import (
+	"errors"
+	"time"
+)
+
+// PrimaryValidatorConfig of a node registered as a primary network
+// validator when added to a running network
+type PrimaryValidatorConfig struct {
+	// Staked amount, in nLUX. Defaults to the min validator stake.
+	Stake uint64 `json:"stake,omitempty"`
+	// Validation duration. Defaults to the max stake duration.
+	Duration time.Duration `json:"duration,omitempty"`
+	// Delegation fee, in millionths (e.g. 20000 for 2%). Defaults to 10%.
+	DelegationFee uint32 `json:"delegationFee,omitempty"`
+}
+
+// Validate returns an error if this config is invalid
+func (c *PrimaryValidatorConfig) Validate() error {
+	switch {
+	case c.Duration < 0:
+		return errors.New("negative primary validator duration")
+	case c.DelegationFee > 1_000_000:
+		return errors.New("primary validator delegation fee over 100%")
+	}
+	return nil
+}
@@ -0,0 +1,59 @@
+// Copyright (C) 2021-2024, Lux Partners Limited. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package network
+
+import (
+	"sort"
+
+	"github.com/luxdefi/netrunner/network/node"
+)
+
+// NodeStatus describes how a node of the network is set up
+type NodeStatus struct {
+	NodeName string `json:"nodeName"`
+	NodeID   string `json:"nodeID"`
+	// Role of the node, empty for regular nodes
+	Role string `json:"role,omitempty"`
+	// Database backend of the node
+	DBType string `json:"dbType"`
+	// IP the node listens on
+	BindIP string `json:"bindIP"`
+	Paused bool   `json:"paused"`
+	// Bytes used on disk, as last measured in [DiskUsage]
+	DiskUsage uint64 `json:"diskUsage"`
+	// Bring-up timings of the node
+	Timings node.Timings `json:"timings"`
+	// PID, restarts and last exit code of the node process
+	Runtime node.RuntimeInfo `json:"runtime"`
+	// Labels of the node, network labels included
+	Labels map[string]string `json:"labels,omitempty"`
+}
+
+// NewNodeStatuses returns the status of [nodes], sorted by node name
+func NewNodeStatuses(nodes map[string]node.Node, diskUsage DiskUsage) []NodeStatus {
+	statuses := make([]NodeStatus, 0, len(nodes))
+	for nodeName, n := range nodes {
+		nodeConfig := n.GetConfig()
+		dbType := nodeConfig.DBType
+		if dbType == "" {
+			dbType = node.DefaultDBType
+		}
+		statuses = append(statuses, NodeStatus{
+			NodeName:  nodeName,
+			NodeID:    n.GetNodeID().String(),
+			Role:      nodeConfig.Role,
+			DBType:    dbType,
+			BindIP:    n.GetURL(),
+			Paused:    n.GetPaused(),
+			DiskUsage: diskUsage.Nodes[nodeName],
+			Timings:   n.GetTimings(),
+			Runtime:   n.GetRuntimeInfo(),
+			Labels:    nodeConfig.Labels,
+		})
+	}
+	sort.Slice(statuses, func(i, j int) bool {
+		return statuses[i].NodeName < statuses[j].NodeName
+	})
+	return statuses
+}
@@ -0,0 +1,55 @@
+// Copyright (C) 2021-2024, Lux Partners Limited. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package network
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// ReadinessProbe is an app specific check, e.g. that a custom chain
+// serves requests, that must pass for the network to be healthy
+type ReadinessProbe struct {
+	// Identifies the probe in errors
+	Name string `json:"name"`
+	// URL that must answer a GET with a 2xx status. If it is a path
+	// (e.g. /ext/bc/mychain/ready), it is queried on the API of
+	// every running node.
+	URL string `json:"url,omitempty"`
+	// If not empty, the response body must contain it
+	ExpectBody string `json:"expectBody,omitempty"`
+	// If set instead of [URL], the probe passes when it returns nil.
+	// Not kept in snapshots.
+	Check func(ctx context.Context, nw Network) error `json:"-"`
+}
+
+// IsPath returns true if the probe URL is queried on every node
+func (p ReadinessProbe) IsPath() bool {
+	return strings.HasPrefix(p.URL, "/")
+}
+
+// Validate returns an error if the probe is invalid
+func (p ReadinessProbe) Validate() error {
+	switch {
+	case p.Name == "":
+		return errors.New("readiness probe name not given")
+	case p.URL == "" && p.Check == nil:
+		return fmt.Errorf("readiness probe %q: URL or check not given", p.Name)
+	case p.URL != "" && p.Check != nil:
+		return fmt.Errorf("readiness probe %q: URL and check can't be both given", p.Name)
+	case p.URL == "" || p.IsPath():
+		return nil
+	}
+	u, err := url.Parse(p.URL)
+	if err != nil {
+		return fmt.Errorf("readiness probe %q: %w", p.Name, err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return fmt.Errorf("readiness probe %q: URL must be a path or an http(s) URL, got %q", p.Name, p.URL)
+	}
+	return nil
+}
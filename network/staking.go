@@ -0,0 +1,196 @@
+// Copyright (C) 2021-2024, Lux Partners Limited. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package network
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/luxdefi/node/utils/constants"
+)
+
+// Node flags of the primary network staking parameters.
+// Nodes take them into account on networks other than mainnet and testnet.
+const (
+	minStakeDurationKey   = "min-stake-duration"
+	maxStakeDurationKey   = "max-stake-duration"
+	stakeMintingPeriodKey = "stake-minting-period"
+	uptimeRequirementKey  = "uptime-requirement"
+	minValidatorStakeKey  = "min-validator-stake"
+	minDelegatorStakeKey  = "min-delegator-stake"
+)
+
+var stakingFlagKeys = []string{
+	minStakeDurationKey,
+	maxStakeDurationKey,
+	stakeMintingPeriodKey,
+	uptimeRequirementKey,
+	minValidatorStakeKey,
+	minDelegatorStakeKey,
+}
+
+var ErrInvalidStakingConfig = errors.New("invalid staking config")
+
+// StakingConfig of the primary network, to shorten the staking periods so
+// that staking lifecycle tests (validation expiry, rewards) complete in
+// minutes rather than weeks. It is passed to every node as flags, and
+// the genesis validations are shortened to [GenesisStakeDuration].
+type StakingConfig struct {
+	MinStakeDuration time.Duration `json:"minStakeDuration"`
+	MaxStakeDuration time.Duration `json:"maxStakeDuration"`
+	// Duration of the genesis validations from the network creation.
+	// Defaults to MaxStakeDuration.
+	GenesisStakeDuration time.Duration `json:"genesisStakeDuration,omitempty"`
+	// Period over which rewards are minted, which scales the rewards of a
+	// stake duration. Must not be less than MaxStakeDuration, its default.
+	MintingPeriod time.Duration `json:"mintingPeriod,omitempty"`
+	// Optional fraction of the validation a validator must be up for to be
+	// rewarded, between 0 and 1
+	UptimeRequirement *float64 `json:"uptimeRequirement,omitempty"`
+	// Optional min stakes, in nLUX
+	MinValidatorStake uint64 `json:"minValidatorStake,omitempty"`
+	MinDelegatorStake uint64 `json:"minDelegatorStake,omitempty"`
+}
+
+// Validate returns an error if the config breaks the constraints the nodes
+// check on the staking parameters, or if the [numGenesisValidators]
+// genesis validations with an offset of [genesisStakeDurationOffset]
+// between each don't fit in the genesis stake duration.
+func (c *StakingConfig) Validate(numGenesisValidators int, genesisStakeDurationOffset time.Duration) error {
+	switch {
+	case c.MinStakeDuration < time.Second:
+		return fmt.Errorf("%w: min stake duration must be at least 1s", ErrInvalidStakingConfig)
+	case c.MaxStakeDuration < c.MinStakeDuration:
+		return fmt.Errorf("%w: max stake duration %s is less than min stake duration %s", ErrInvalidStakingConfig, c.MaxStakeDuration, c.MinStakeDuration)
+	case c.MintingPeriod != 0 && c.MintingPeriod < c.MaxStakeDuration:
+		return fmt.Errorf("%w: minting period %s is less than max stake duration %s", ErrInvalidStakingConfig, c.MintingPeriod, c.MaxStakeDuration)
+	case c.GenesisStakeDuration < 0 || c.GenesisStakeDuration > c.MaxStakeDuration:
+		return fmt.Errorf("%w: genesis stake duration %s is not within 0 and max stake duration %s", ErrInvalidStakingConfig, c.GenesisStakeDuration, c.MaxStakeDuration)
+	case c.UptimeRequirement != nil && (*c.UptimeRequirement < 0 || *c.UptimeRequirement > 1):
+		return fmt.Errorf("%w: uptime requirement %v is not within 0 and 1", ErrInvalidStakingConfig, *c.UptimeRequirement)
+	}
+	if numGenesisValidators > 1 {
+		offsets := genesisStakeDurationOffset * time.Duration(numGenesisValidators-1)
+		if offsets >= c.genesisStakeDuration() {
+			return fmt.Errorf("%w: genesis stake duration %s doesn't fit the stake duration offsets of %d genesis validators (%s)",
+				ErrInvalidStakingConfig, c.genesisStakeDuration(), numGenesisValidators, offsets)
+		}
+	}
+	return nil
+}
+
+func (c *StakingConfig) genesisStakeDuration() time.Duration {
+	if c.GenesisStakeDuration == 0 {
+		return c.MaxStakeDuration
+	}
+	return c.GenesisStakeDuration
+}
+
+// Flags returns the node flags of the config
+func (c *StakingConfig) Flags() map[string]interface{} {
+	mintingPeriod := c.MintingPeriod
+	if mintingPeriod == 0 {
+		mintingPeriod = c.MaxStakeDuration
+	}
+	flags := map[string]interface{}{
+		minStakeDurationKey:   c.MinStakeDuration.String(),
+		maxStakeDurationKey:   c.MaxStakeDuration.String(),
+		stakeMintingPeriodKey: mintingPeriod.String(),
+	}
+	if c.UptimeRequirement != nil {
+		flags[uptimeRequirementKey] = *c.UptimeRequirement
+	}
+	if c.MinValidatorStake != 0 {
+		flags[minValidatorStakeKey] = c.MinValidatorStake
+	}
+	if c.MinDelegatorStake != 0 {
+		flags[minDelegatorStakeKey] = c.MinDelegatorStake
+	}
+	return flags
+}
+
+// Returns an error if [c.Staking] is invalid on [networkID] or for the genesis
+func (c *Config) validateStaking(networkID uint32) error {
+	switch networkID {
+	case constants.MainnetID, constants.TestnetID:
+		return fmt.Errorf("%w: staking parameters are fixed on network ID %d", ErrInvalidStakingConfig, networkID)
+	}
+	numGenesisValidators, offset, err := genesisStakers(c.Genesis)
+	if err != nil {
+		return err
+	}
+	if err := c.Staking.Validate(numGenesisValidators, offset); err != nil {
+		return err
+	}
+	if err := checkNoStakingFlags(c.Flags); err != nil {
+		return err
+	}
+	for _, nodeConfig := range c.NodeConfigs {
+		if err := checkNoStakingFlags(nodeConfig.Flags); err != nil {
+			return fmt.Errorf("node %q: %w", nodeConfig.Name, err)
+		}
+	}
+	return nil
+}
+
+// Returns the number of genesis validators of [genesis]
+// and its stake duration offset between them
+func genesisStakers(genesis string) (int, time.Duration, error) {
+	genesisMap := struct {
+		InitialStakers             []json.RawMessage `json:"initialStakers"`
+		InitialStakeDurationOffset uint64            `json:"initialStakeDurationOffset"`
+	}{}
+	if err := json.Unmarshal([]byte(genesis), &genesisMap); err != nil {
+		return 0, 0, fmt.Errorf("couldn't parse genesis: %w", err)
+	}
+	return len(genesisMap.InitialStakers), time.Duration(genesisMap.InitialStakeDurationOffset) * time.Second, nil
+}
+
+// ApplyStaking shortens the genesis validations of [c.Genesis] as given by
+// [c.Staking], starting them at [now], and sets the staking flags of
+// [c.Staking] as network flags. Does nothing if [c.Staking] is nil.
+// The network flags map is copied, not modified.
+func (c *Config) ApplyStaking(now time.Time) error {
+	if c.Staking == nil {
+		return nil
+	}
+	genesisMap := map[string]interface{}{}
+	// keep the amounts of the genesis allocations exact
+	decoder := json.NewDecoder(strings.NewReader(c.Genesis))
+	decoder.UseNumber()
+	if err := decoder.Decode(&genesisMap); err != nil {
+		return fmt.Errorf("couldn't parse genesis: %w", err)
+	}
+	genesisMap["startTime"] = now.Unix()
+	genesisMap["initialStakeDuration"] = uint64(c.Staking.genesisStakeDuration() / time.Second)
+	genesis, err := json.Marshal(genesisMap)
+	if err != nil {
+		return err
+	}
+	c.Genesis = string(genesis)
+
+	flags := make(map[string]interface{}, len(c.Flags)+len(stakingFlagKeys))
+	for k, v := range c.Flags {
+		flags[k] = v
+	}
+	for k, v := range c.Staking.Flags() {
+		flags[k] = v
+	}
+	c.Flags = flags
+	return nil
+}
+
+// Returns an error if [flags] set any of the staking flags, which must be
+// the same on every node and are set from the staking config instead
+func checkNoStakingFlags(flags map[string]interface{}) error {
+	for _, key := range stakingFlagKeys {
+		if _, ok := flags[key]; ok {
+			return fmt.Errorf("%w: flag %q can't be given along with a staking config", ErrInvalidStakingConfig, key)
+		}
+	}
+	return nil
+}
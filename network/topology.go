@@ -0,0 +1,91 @@
+// Copyright (C) 2021-2024, Lux Partners Limited. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package network
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+)
+
+// TopologyConfigKey is the key of the topology in the global node config
+// JSON given to the server. It is not a node flag.
+const TopologyConfigKey = "network-topology"
+
+// Topology approximates a geo-distributed network on a single host. The
+// nodes are assigned to regions (see node.Config.Region), and the P2P
+// traffic between two regions is delayed by their latency, in each
+// direction, by the port forwarders of the public IPs of the nodes.
+type Topology struct {
+	// Region --> region --> latency between them. A latency given for
+	// (a, b) is also the one of (b, a). The latency within a region is
+	// the one of (a, a), if given, or else none.
+	Latencies map[string]map[string]time.Duration `json:"latencies"`
+}
+
+// Latency returns the latency between regions [from] and [to],
+// and whether it is given
+func (t *Topology) Latency(from string, to string) (time.Duration, bool) {
+	if latency, ok := t.Latencies[from][to]; ok {
+		return latency, true
+	}
+	latency, ok := t.Latencies[to][from]
+	return latency, ok
+}
+
+// Regions returns the regions of the latencies, sorted
+func (t *Topology) Regions() []string {
+	regions := map[string]struct{}{}
+	for from, latencies := range t.Latencies {
+		regions[from] = struct{}{}
+		for to := range latencies {
+			regions[to] = struct{}{}
+		}
+	}
+	names := make([]string, 0, len(regions))
+	for region := range regions {
+		names = append(names, region)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Validate returns an error if a latency is negative, or if
+// the latencies of (a, b) and (b, a) are both given and differ
+func (t *Topology) Validate() error {
+	for from, latencies := range t.Latencies {
+		for to, latency := range latencies {
+			if latency < 0 {
+				return fmt.Errorf("negative latency %s between regions %q and %q", latency, from, to)
+			}
+			if reverse, ok := t.Latencies[to][from]; ok && reverse != latency {
+				return fmt.Errorf("latency between regions %q and %q given as both %s and %s", from, to, latency, reverse)
+			}
+		}
+	}
+	return nil
+}
+
+// TakeTopologyConfigKey returns the topology of [TopologyConfigKey] in the
+// global node config JSON [flags], if given, and removes it from [flags]
+func TakeTopologyConfigKey(flags map[string]interface{}) (*Topology, error) {
+	v, ok := flags[TopologyConfigKey]
+	if !ok {
+		return nil, nil
+	}
+	delete(flags, TopologyConfigKey)
+	b, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	topology := &Topology{}
+	if err := json.Unmarshal(b, topology); err != nil {
+		return nil, fmt.Errorf("invalid %q value: %w", TopologyConfigKey, err)
+	}
+	if err := topology.Validate(); err != nil {
+		return nil, err
+	}
+	return topology, nil
+}
@@ -0,0 +1,148 @@
+// Copyright (C) 2021-2024, Lux Partners Limited. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package nodeconfig
+
+import (
+	"encoding/base64"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/luxdefi/netrunner/network/node"
+	"github.com/luxdefi/node/config"
+	"github.com/luxdefi/node/utils/constants"
+)
+
+// WriteFiles writes the files a node needs on startup, the staking
+// key/cert files under [keysDir] and the others under [nodeRootDir].
+// It returns flags used to point to those files. The chain and subnet
+// config files are validated first, see ValidateChainConfig and
+// ValidateSubnetConfig.
+func WriteFiles(networkID uint32, genesis []byte, nodeRootDir string, keysDir string, nodeConfig *node.Config) (map[string]string, error) {
+	for chainAlias, chainConfigFile := range nodeConfig.ChainConfigFiles {
+		if err := ValidateChainConfig(chainAlias, []byte(chainConfigFile)); err != nil {
+			return nil, err
+		}
+	}
+	for subnetID, subnetConfigFile := range nodeConfig.SubnetConfigFiles {
+		if err := ValidateSubnetConfig(subnetID, []byte(subnetConfigFile)); err != nil {
+			return nil, err
+		}
+	}
+	type file struct {
+		pathKey   string
+		flagValue string
+		path      string
+		contents  []byte
+	}
+	decodedStakingSigningKey, err := base64.StdEncoding.DecodeString(nodeConfig.StakingSigningKey)
+	if err != nil {
+		return nil, err
+	}
+	files := []file{
+		{
+			flagValue: filepath.Join(keysDir, StakingKeyFileName),
+			path:      filepath.Join(keysDir, StakingKeyFileName),
+			pathKey:   config.StakingTLSKeyPathKey,
+			contents:  []byte(nodeConfig.StakingKey),
+		},
+		{
+			flagValue: filepath.Join(keysDir, StakingCertFileName),
+			path:      filepath.Join(keysDir, StakingCertFileName),
+			pathKey:   config.StakingCertPathKey,
+			contents:  []byte(nodeConfig.StakingCert),
+		},
+		{
+			flagValue: filepath.Join(keysDir, StakingSigningKeyFileName),
+			path:      filepath.Join(keysDir, StakingSigningKeyFileName),
+			pathKey:   config.StakingSignerKeyPathKey,
+			contents:  decodedStakingSigningKey,
+		},
+	}
+	if networkID != constants.LocalID {
+		files = append(files, file{
+			flagValue: filepath.Join(nodeRootDir, GenesisFileName),
+			path:      filepath.Join(nodeRootDir, GenesisFileName),
+			pathKey:   config.GenesisConfigFileKey,
+			contents:  genesis,
+		})
+	}
+	if len(nodeConfig.ConfigFile) != 0 {
+		files = append(files, file{
+			flagValue: filepath.Join(nodeRootDir, ConfigFileName),
+			path:      filepath.Join(nodeRootDir, ConfigFileName),
+			pathKey:   config.ConfigFileKey,
+			contents:  []byte(nodeConfig.ConfigFile),
+		})
+	}
+	flags := map[string]string{}
+	for _, f := range files {
+		flags[f.pathKey] = f.flagValue
+		if err := createFileAndWrite(f.path, f.contents); err != nil {
+			return nil, fmt.Errorf("couldn't write file at %q: %w", f.path, err)
+		}
+	}
+	// chain configs dir
+	chainConfigDir := filepath.Join(nodeRootDir, ChainConfigSubDir)
+	if err := os.MkdirAll(chainConfigDir, 0o750); err != nil {
+		return nil, err
+	}
+	flags[config.ChainConfigDirKey] = chainConfigDir
+	// subnet configs dir
+	subnetConfigDir := filepath.Join(nodeRootDir, SubnetConfigSubDir)
+	if err := os.MkdirAll(subnetConfigDir, 0o750); err != nil {
+		return nil, err
+	}
+	flags[config.SubnetConfigDirKey] = subnetConfigDir
+	// chain configs
+	for chainAlias, chainConfigFile := range nodeConfig.ChainConfigFiles {
+		chainConfigPath := filepath.Join(chainConfigDir, chainAlias, ConfigFileName)
+		if err := createFileAndWrite(chainConfigPath, []byte(chainConfigFile)); err != nil {
+			return nil, fmt.Errorf("couldn't write file at %q: %w", chainConfigPath, err)
+		}
+	}
+	// network upgrades
+	for chainAlias, chainUpgradeFile := range nodeConfig.UpgradeConfigFiles {
+		chainUpgradePath := filepath.Join(chainConfigDir, chainAlias, UpgradeConfigFileName)
+		if err := createFileAndWrite(chainUpgradePath, []byte(chainUpgradeFile)); err != nil {
+			return nil, fmt.Errorf("couldn't write file at %q: %w", chainUpgradePath, err)
+		}
+	}
+	// subnet configs
+	for subnetID, subnetConfigFile := range nodeConfig.SubnetConfigFiles {
+		subnetConfigPath := filepath.Join(subnetConfigDir, subnetID+".json")
+		if err := createFileAndWrite(subnetConfigPath, []byte(subnetConfigFile)); err != nil {
+			return nil, fmt.Errorf("couldn't write file at %q: %w", subnetConfigPath, err)
+		}
+	}
+	return flags, nil
+}
+
+// createFileAndWrite creates a file with the given path and
+// writes the given contents
+func createFileAndWrite(path string, contents []byte) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0o750); err != nil {
+		return err
+	}
+	// write to a temp file renamed over [path], so that a failed
+	// write doesn't leave a truncated file behind
+	file, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".tmp")
+	if err != nil {
+		return err
+	}
+	if _, err := file.Write(contents); err != nil {
+		_ = file.Close()
+		_ = os.Remove(file.Name())
+		return err
+	}
+	if err := file.Close(); err != nil {
+		_ = os.Remove(file.Name())
+		return err
+	}
+	if err := os.Chmod(file.Name(), 0o644); err != nil {
+		_ = os.Remove(file.Name())
+		return err
+	}
+	return os.Rename(file.Name(), path)
+}
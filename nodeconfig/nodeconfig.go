@@ -0,0 +1,244 @@
+// Copyright (C) 2021-2024, Lux Partners Limited. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+// Package nodeconfig renders the config of a node as netrunner starts it:
+// the files it needs on disk (staking key/cert, genesis, config file, chain
+// and subnet configs) and the flags pointing the node to them, without
+// starting the node, so that other tools can run nodes configured as
+// netrunner does.
+package nodeconfig
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sort"
+
+	"github.com/luxdefi/netrunner/network/node"
+	"github.com/luxdefi/node/config"
+)
+
+// Layout of the files of a node
+const (
+	ConfigFileName            = "config.json"
+	UpgradeConfigFileName     = "upgrade.json"
+	StakingKeyFileName        = "staking.key"
+	StakingCertFileName       = "staking.crt"
+	StakingSigningKeyFileName = "signer.key"
+	GenesisFileName           = "genesis.json"
+	ChainConfigSubDir         = "chainConfigs"
+	SubnetConfigSubDir        = "subnetConfigs"
+	DefaultDBSubdir           = "db"
+	DefaultLogsSubdir         = "logs"
+)
+
+var ErrNoStakingKey = errors.New("no staking key/cert given")
+
+// Options of the rendering of a node config, given by the network of the node
+type Options struct {
+	// ID of the network. The genesis is written for the networks but the local one.
+	NetworkID uint32
+	Genesis   []byte
+	// Dir of the node files, and of its data unless given in its flags or config file
+	NodeDir string
+	// If not empty, base dirs of the node db, logs and staking key/cert
+	// files, instead of the node dir. See network.Config.
+	DBRootDir   string
+	LogsRootDir string
+	KeysRootDir string
+	// If not zero, ports of the node API and P2P, overriding the
+	// ones given in the node flags
+	APIPort uint16
+	P2PPort uint16
+	// Beacons of the node, as given to the bootstrap-ips and bootstrap-ids flags
+	BootstrapIPs string
+	BootstrapIDs string
+	// Plugin dir of the node if none is given in its flags or config file
+	DefaultPluginDir string
+	// If not empty, version of the node binary (e.g. v1.9.5). The flags
+	// renamed in later versions are given with their old names.
+	NodeVersion string
+}
+
+// Layout is a node config rendered on disk
+type Layout struct {
+	DataDir   string
+	DBDir     string
+	LogsDir   string
+	KeysDir   string
+	PluginDir string
+	// Host the node API listens on
+	HTTPHost string
+	// Host to reach the node API at
+	APIHost string
+	// Flags of the node, including the ones pointing to its files
+	Flags map[string]string
+	// Command line args of the node binary, sorted
+	Args []string
+}
+
+// Render writes the files of the node with [nodeConfig] as per [opts], and
+// returns its layout and flags. The flags given in [nodeConfig] take
+// precedence over the ones of the layout, but for the ports in [opts].
+// The staking key/cert of [nodeConfig] must be set.
+func Render(nodeConfig *node.Config, opts Options) (*Layout, error) {
+	if nodeConfig.StakingKey == "" || nodeConfig.StakingCert == "" {
+		return nil, ErrNoStakingKey
+	}
+	var configFile map[string]interface{}
+	if len(nodeConfig.ConfigFile) != 0 {
+		if err := json.Unmarshal([]byte(nodeConfig.ConfigFile), &configFile); err != nil {
+			return nil, fmt.Errorf("couldn't unmarshal config file: %w", err)
+		}
+	}
+
+	// httpHost from all configs for node
+	httpHost, err := ConfigEntry(nodeConfig.Flags, configFile, config.HTTPHostKey, nodeConfig.BindIP)
+	if err != nil {
+		return nil, err
+	}
+	// Tell the node to put all node related data in [opts.NodeDir] unless given in config file
+	dataDir, err := ConfigEntry(nodeConfig.Flags, configFile, config.DataDirKey, opts.NodeDir)
+	if err != nil {
+		return nil, err
+	}
+	// pluginDir from all configs for node, or else the default one
+	pluginDir, err := ConfigEntry(nodeConfig.Flags, configFile, config.PluginDirKey, "")
+	if err != nil {
+		return nil, err
+	}
+	defaultPluginDir := ""
+	if pluginDir == "" {
+		defaultPluginDir = opts.DefaultPluginDir
+		pluginDir = defaultPluginDir
+	}
+	// Tell the node to put the database in [opts.DBRootDir/<node name>], or else
+	// in [dataDir/db], unless given in config file
+	dbDir, err := ConfigEntry(nodeConfig.Flags, configFile, config.DBPathKey, VolumeDir(opts.DBRootDir, nodeConfig.Name, dataDir, DefaultDBSubdir))
+	if err != nil {
+		return nil, err
+	}
+	// Tell the node to put the log directory in [opts.LogsRootDir/<node name>], or else
+	// in [dataDir/logs], unless given in config file
+	logsDir, err := ConfigEntry(nodeConfig.Flags, configFile, config.LogsDirKey, VolumeDir(opts.LogsRootDir, nodeConfig.Name, dataDir, DefaultLogsSubdir))
+	if err != nil {
+		return nil, err
+	}
+
+	// Flags for Lux
+	flags := map[string]string{
+		config.NetworkNameKey:  fmt.Sprintf("%d", opts.NetworkID),
+		config.DataDirKey:      dataDir,
+		config.DBPathKey:       dbDir,
+		config.LogsDirKey:      logsDir,
+		config.BootstrapIPsKey: opts.BootstrapIPs,
+		config.BootstrapIDsKey: opts.BootstrapIDs,
+	}
+	if opts.APIPort != 0 {
+		flags[config.HTTPPortKey] = fmt.Sprintf("%d", opts.APIPort)
+	}
+	if opts.P2PPort != 0 {
+		flags[config.StakingPortKey] = fmt.Sprintf("%d", opts.P2PPort)
+	}
+
+	// Write staking key/cert etc. to disk so the new node can use them,
+	// and get flag that point the node to those files
+	keysDir := VolumeDir(opts.KeysRootDir, nodeConfig.Name, dataDir, "")
+	fileFlags, err := WriteFiles(opts.NetworkID, opts.Genesis, dataDir, keysDir, nodeConfig)
+	if err != nil {
+		return nil, err
+	}
+	for k := range fileFlags {
+		flags[k] = fileFlags[k]
+	}
+	if defaultPluginDir != "" {
+		flags[config.PluginDirKey] = defaultPluginDir
+	}
+
+	// Add flags given in node config.
+	// Note these will overwrite existing flags if the same flag is given twice.
+	for flagName, flagVal := range nodeConfig.Flags {
+		// the ports of [opts] may differ from the ones given, as they can be dynamic
+		if (flagName == config.HTTPPortKey && opts.APIPort != 0) || (flagName == config.StakingPortKey && opts.P2PPort != 0) {
+			continue
+		}
+		flags[flagName] = fmt.Sprintf("%v", flagVal)
+	}
+
+	// The bind IP takes precedence over the network wide public IP
+	apiHost := "localhost"
+	if nodeConfig.BindIP != "" {
+		flags[config.PublicIPKey] = AdvertisedIP(*nodeConfig)
+		flags[config.StakingHostKey] = nodeConfig.BindIP
+		flags[config.HTTPHostKey] = httpHost
+		apiHost = nodeConfig.BindIP
+		if httpHost != "0.0.0.0" && httpHost != "." {
+			apiHost = httpHost
+		}
+	}
+
+	argFlags := flags
+	if opts.NodeVersion != "" {
+		// map input flags to the corresponding luxd version, making sure
+		// that latest flags don't break old luxd versions
+		argFlags = FlagsForVersion(opts.NodeVersion, flags)
+	}
+	args := make([]string, 0, len(argFlags))
+	for k, v := range argFlags {
+		args = append(args, fmt.Sprintf("--%s=%s", k, v))
+	}
+	sort.Strings(args)
+
+	return &Layout{
+		DataDir:   dataDir,
+		DBDir:     dbDir,
+		LogsDir:   logsDir,
+		KeysDir:   keysDir,
+		PluginDir: pluginDir,
+		HTTPHost:  httpHost,
+		APIHost:   apiHost,
+		Flags:     flags,
+		Args:      args,
+	}, nil
+}
+
+// ConfigEntry returns an entry in the config file if it is found, otherwise returns the default value
+func ConfigEntry(
+	nodeConfigFlags map[string]interface{},
+	configFile map[string]interface{},
+	flag string,
+	defaultVal string,
+) (string, error) {
+	var entry string
+	if val, ok := nodeConfigFlags[flag]; ok {
+		if entry, ok := val.(string); ok {
+			return entry, nil
+		}
+		return "", fmt.Errorf("expected node config flag %q to be string but got %T", flag, entry)
+	}
+	if val, ok := configFile[flag]; ok {
+		if entry, ok := val.(string); ok {
+			return entry, nil
+		}
+		return "", fmt.Errorf("expected config file flag %q to be string but got %T", flag, entry)
+	}
+	return defaultVal, nil
+}
+
+// VolumeDir returns <[volumeRootDir]>/<[nodeName]> if [volumeRootDir]
+// is given, or else <[nodeDataDir]>/<[defaultSubdir]>
+func VolumeDir(volumeRootDir string, nodeName string, nodeDataDir string, defaultSubdir string) string {
+	if volumeRootDir != "" {
+		return filepath.Join(volumeRootDir, nodeName)
+	}
+	return filepath.Join(nodeDataDir, defaultSubdir)
+}
+
+// AdvertisedIP returns the IP the node with [nodeConfig] advertises to its peers
+func AdvertisedIP(nodeConfig node.Config) string {
+	if nodeConfig.PublicIP != "" {
+		return nodeConfig.PublicIP
+	}
+	return nodeConfig.BindIP
+}
@@ -0,0 +1,304 @@
+// Copyright (C) 2021-2024, Lux Partners Limited. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package nodeconfig
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/luxdefi/netrunner/network/node"
+	"github.com/luxdefi/node/config"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRender(t *testing.T) {
+	require := require.New(t)
+
+	nodeDir := t.TempDir()
+	keysRootDir := t.TempDir()
+	nodeConfig := &node.Config{
+		Name:        "node1",
+		StakingKey:  "stakingKey",
+		StakingCert: "stakingCert",
+		BindIP:      "127.0.0.2",
+		ConfigFile:  `{"log-dir": "/logs"}`,
+		Flags: map[string]interface{}{
+			config.HTTPPortKey: 9650,
+			"log-level":        "debug",
+			"track-subnets":    "subnet",
+		},
+	}
+	layout, err := Render(nodeConfig, Options{
+		NetworkID:    1337,
+		Genesis:      []byte("genesis"),
+		NodeDir:      nodeDir,
+		KeysRootDir:  keysRootDir,
+		APIPort:      9660,
+		P2PPort:      9661,
+		BootstrapIPs: "127.0.0.1:9651",
+		BootstrapIDs: "NodeID-1",
+		NodeVersion:  "v1.9.5",
+	})
+	require.NoError(err)
+	require.Equal(nodeDir, layout.DataDir)
+	require.Equal(filepath.Join(nodeDir, DefaultDBSubdir), layout.DBDir)
+	// given in the config file
+	require.Equal("/logs", layout.LogsDir)
+	require.Equal(filepath.Join(keysRootDir, "node1"), layout.KeysDir)
+	require.Equal("127.0.0.2", layout.APIHost)
+
+	// the ports of the options take precedence
+	require.Equal("9660", layout.Flags[config.HTTPPortKey])
+	require.Equal("9661", layout.Flags[config.StakingPortKey])
+	require.Equal("debug", layout.Flags["log-level"])
+	require.Equal("127.0.0.2", layout.Flags[config.PublicIPKey])
+	require.Equal("127.0.0.1:9651", layout.Flags[config.BootstrapIPsKey])
+	require.Equal(filepath.Join(keysRootDir, "node1", StakingKeyFileName), layout.Flags[config.StakingTLSKeyPathKey])
+	stakingKey, err := os.ReadFile(filepath.Join(keysRootDir, "node1", StakingKeyFileName))
+	require.NoError(err)
+	require.Equal([]byte("stakingKey"), stakingKey)
+	genesis, err := os.ReadFile(filepath.Join(nodeDir, GenesisFileName))
+	require.NoError(err)
+	require.Equal([]byte("genesis"), genesis)
+
+	// the args are given with the flag names of the node version
+	require.Contains(layout.Args, "--http-port=9660")
+	require.Contains(layout.Args, "--whitelisted-subnets=subnet")
+	require.NotContains(layout.Args, "--track-subnets=subnet")
+	require.IsIncreasing(layout.Args)
+
+	_, err = Render(&node.Config{}, Options{NodeDir: nodeDir})
+	require.ErrorIs(err, ErrNoStakingKey)
+}
+
+func TestFlagsForVersion(t *testing.T) {
+	require := require.New(t)
+
+	flags := map[string]string{
+		config.PluginDirKey: "/build/plugins/",
+		"track-subnets":     "subnet",
+	}
+	require.Equal(map[string]string{
+		"build-dir":           "/build",
+		"whitelisted-subnets": "subnet",
+	}, FlagsForVersion("v1.9.5", flags))
+	require.Equal(flags, FlagsForVersion("v1.9.6", flags))
+}
+
+func TestConfigEntry(t *testing.T) {
+	t.Parallel()
+	require := require.New(t)
+
+	// case: key not present
+	val, err := ConfigEntry(
+		map[string]interface{}{},
+		map[string]interface{}{"2": "2"},
+		"1",
+		"1",
+	)
+	require.NoError(err)
+	require.Equal("1", val)
+
+	// case: key present
+	val, err = ConfigEntry(
+		map[string]interface{}{},
+		map[string]interface{}{"1": "hi", "2": "2"},
+		"1",
+		"1",
+	)
+	require.NoError(err)
+	require.Equal("hi", val)
+
+	// case: key present wrong type
+	_, err = ConfigEntry(
+		map[string]interface{}{},
+		map[string]interface{}{"1": 1, "2": "2"},
+		"1",
+		"1",
+	)
+	require.Error(err)
+}
+
+func TestWriteFiles(t *testing.T) {
+	t.Parallel()
+	stakingKey := "stakingKey"
+	stakingCert := "stakingCert"
+	genesis := []byte("genesis")
+	configFile := "config file"
+	chainConfigFiles := map[string]string{
+		"C": `{"log-level":"info"}`,
+	}
+	tmpDir, err := os.MkdirTemp("", "netrunner-tests-*")
+	if err != nil {
+		t.Fatal(err)
+	}
+	stakingKeyPath := filepath.Join(tmpDir, StakingKeyFileName)
+	stakingCertPath := filepath.Join(tmpDir, StakingCertFileName)
+	stakingSigningKeyPath := filepath.Join(tmpDir, StakingSigningKeyFileName)
+	genesisPath := filepath.Join(tmpDir, GenesisFileName)
+	configFilePath := filepath.Join(tmpDir, ConfigFileName)
+	chainConfigDir := filepath.Join(tmpDir, ChainConfigSubDir)
+	subnetConfigDir := filepath.Join(tmpDir, SubnetConfigSubDir)
+	cChainConfigPath := filepath.Join(tmpDir, ChainConfigSubDir, "C", ConfigFileName)
+
+	type test struct {
+		name          string
+		shouldErr     bool
+		genesis       []byte
+		nodeConfig    node.Config
+		expectedFlags map[string]string
+	}
+
+	tests := []test{
+		{
+			name:      "no config files given",
+			shouldErr: false,
+			genesis:   genesis,
+			nodeConfig: node.Config{
+				StakingKey:  stakingKey,
+				StakingCert: stakingCert,
+			},
+			expectedFlags: map[string]string{
+				config.StakingTLSKeyPathKey:    stakingKeyPath,
+				config.StakingCertPathKey:      stakingCertPath,
+				config.StakingSignerKeyPathKey: stakingSigningKeyPath,
+				config.GenesisConfigFileKey:    genesisPath,
+				config.ChainConfigDirKey:       chainConfigDir,
+				config.SubnetConfigDirKey:      subnetConfigDir,
+			},
+		},
+		{
+			name:      "config file given but not c-chain config file",
+			shouldErr: false,
+			genesis:   genesis,
+			nodeConfig: node.Config{
+				StakingKey:  stakingKey,
+				StakingCert: stakingCert,
+				ConfigFile:  configFile,
+			},
+			expectedFlags: map[string]string{
+				config.StakingTLSKeyPathKey:    stakingKeyPath,
+				config.StakingCertPathKey:      stakingCertPath,
+				config.StakingSignerKeyPathKey: stakingSigningKeyPath,
+				config.GenesisConfigFileKey:    genesisPath,
+				config.ChainConfigDirKey:       chainConfigDir,
+				config.SubnetConfigDirKey:      subnetConfigDir,
+				config.ConfigFileKey:           configFilePath,
+			},
+		},
+		{
+			name:      "config file and c-chain config file given",
+			shouldErr: false,
+			genesis:   genesis,
+			nodeConfig: node.Config{
+				StakingKey:       stakingKey,
+				StakingCert:      stakingCert,
+				ConfigFile:       configFile,
+				ChainConfigFiles: chainConfigFiles,
+			},
+			expectedFlags: map[string]string{
+				config.StakingTLSKeyPathKey:    stakingKeyPath,
+				config.StakingCertPathKey:      stakingCertPath,
+				config.StakingSignerKeyPathKey: stakingSigningKeyPath,
+				config.GenesisConfigFileKey:    genesisPath,
+				config.ChainConfigDirKey:       chainConfigDir,
+				config.SubnetConfigDirKey:      subnetConfigDir,
+				config.ConfigFileKey:           configFilePath,
+			},
+		},
+		{
+			name:      "invalid c-chain config file given",
+			shouldErr: true,
+			genesis:   genesis,
+			nodeConfig: node.Config{
+				StakingKey:       stakingKey,
+				StakingCert:      stakingCert,
+				ChainConfigFiles: map[string]string{"C": `{"pruning-enable":false}`},
+			},
+		},
+		{
+			name:      "invalid subnet config file given",
+			shouldErr: true,
+			genesis:   genesis,
+			nodeConfig: node.Config{
+				StakingKey:        stakingKey,
+				StakingCert:       stakingCert,
+				SubnetConfigFiles: map[string]string{"subnet": `{"validatorsOnly":true}`},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require := require.New(t)
+			flags, err := WriteFiles(0, tt.genesis, tmpDir, tmpDir, &tt.nodeConfig)
+			if tt.shouldErr {
+				require.Error(err)
+				return
+			}
+			require.NoError(err)
+			// Make sure returned flags are right
+			require.Len(tt.expectedFlags, len(flags))
+			for k := range flags {
+				require.Equal(tt.expectedFlags[k], flags[k])
+			}
+			// Assert files created correctly
+			gotStakingKey, err := os.ReadFile(stakingKeyPath)
+			require.NoError(err)
+			require.Equal([]byte(tt.nodeConfig.StakingKey), gotStakingKey)
+			gotStakingCert, err := os.ReadFile(stakingCertPath)
+			require.NoError(err)
+			require.Equal([]byte(tt.nodeConfig.StakingCert), gotStakingCert)
+			gotGenesis, err := os.ReadFile(genesisPath)
+			require.NoError(err)
+			require.Equal(tt.genesis, gotGenesis)
+			if len(tt.nodeConfig.ConfigFile) > 0 {
+				gotConfigFile, err := os.ReadFile(configFilePath)
+				require.NoError(err)
+				require.Equal([]byte(configFile), gotConfigFile)
+			}
+			if tt.nodeConfig.ChainConfigFiles != nil {
+				gotCChainConfigFile, err := os.ReadFile(cChainConfigPath)
+				require.NoError(err)
+				require.Equal([]byte(chainConfigFiles["C"]), gotCChainConfigFile)
+			}
+		})
+	}
+}
+
+func TestValidateChainConfig(t *testing.T) {
+	require := require.New(t)
+
+	require.NoError(ValidateChainConfig("C", []byte(`{"pruning-enabled":false,"log-level":"info"}`)))
+	require.NoError(ValidateChainConfig("X", []byte(`{"index-transactions":true}`)))
+	require.NoError(ValidateChainConfig("P", []byte(`{"checksums-enabled":true}`)))
+	require.NoError(ValidateChainConfig("C", nil))
+	// the VM of the chain isn't known
+	require.NoError(ValidateChainConfig("2CA6j5zYzasynPsFeNoqWkmTCt3VScMvXUZHbfDJ8k3oGzAPtU", []byte("not json")))
+
+	err := ValidateChainConfig("C", []byte(`{"pruning-enable":false,"state-sync-enabled":true,"foo":1}`))
+	require.ErrorIs(err, ErrUnknownConfigKey)
+	require.ErrorContains(err, `"foo", "pruning-enable" (did you mean "pruning-enabled"?)`)
+
+	err = ValidateChainConfig("X", []byte(`{"index-transaction":true}`))
+	require.ErrorIs(err, ErrUnknownConfigKey)
+	require.ErrorContains(err, `did you mean "index-transactions"?`)
+
+	require.Error(ValidateChainConfig("C", []byte("not json")))
+	require.Error(ValidateChainConfig("P", []byte("[]")))
+
+	require.NoError(ValidateVMChainConfig(EVMName, []byte(`{"pruning-enabled":false}`)))
+	require.ErrorIs(ValidateVMChainConfig(EVMName, []byte(`{"prunning-enabled":false}`)), ErrUnknownConfigKey)
+	require.NoError(ValidateVMChainConfig("subnetevm", []byte(`{"feeRecipient":"0x"}`)))
+}
+
+func TestValidateSubnetConfig(t *testing.T) {
+	require := require.New(t)
+
+	require.NoError(ValidateSubnetConfig("subnet", []byte(`{"validatorOnly":true,"proposerMinBlockDelay":0}`)))
+	err := ValidateSubnetConfig("subnet", []byte(`{"validatorsOnly":true}`))
+	require.ErrorIs(err, ErrUnknownConfigKey)
+	require.ErrorContains(err, `did you mean "validatorOnly"?`)
+}
@@ -0,0 +1,282 @@
+// Copyright (C) 2021-2024, Lux Partners Limited. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package nodeconfig
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// VMs whose chain configs are validated
+const (
+	PlatformVMName = "platform"
+	AVMName        = "avm"
+	EVMName        = "evm"
+)
+
+// max edit distance of a key suggested for an unknown one
+const maxSuggestionDistance = 3
+
+var (
+	ErrUnknownConfigKey = errors.New("unknown config key")
+
+	// chain alias --> name of its VM
+	chainAliasVMs = map[string]string{
+		"P":            PlatformVMName,
+		PlatformVMName: PlatformVMName,
+		"X":            AVMName,
+		AVMName:        AVMName,
+		"C":            EVMName,
+		EVMName:        EVMName,
+	}
+
+	// VM name --> top level keys of its chain config, across the
+	// node versions netrunner supports
+	vmConfigKeys = map[string][]string{
+		PlatformVMName: {
+			"network",
+			"block-cache-size",
+			"tx-cache-size",
+			"transformed-subnet-tx-cache-size",
+			"reward-utxos-cache-size",
+			"chain-cache-size",
+			"chain-db-cache-size",
+			"block-id-cache-size",
+			"fx-owner-cache-size",
+			"checksums-enabled",
+			"mempool-prune-frequency",
+		},
+		AVMName: {
+			"network",
+			"index-transactions",
+			"index-allow-incomplete",
+			"checksums-enabled",
+		},
+		EVMName: {
+			"snowman-api-enabled",
+			"coreth-admin-api-enabled",
+			"coreth-admin-api-dir",
+			"warp-api-enabled",
+			"eth-apis",
+			"continuous-profiler-dir",
+			"continuous-profiler-frequency",
+			"continuous-profiler-max-files",
+			"rpc-gas-cap",
+			"rpc-tx-fee-cap",
+			"trie-clean-cache",
+			"trie-clean-journal",
+			"trie-clean-rejournal",
+			"trie-dirty-cache",
+			"trie-dirty-commit-target",
+			"trie-prefetcher-parallelism",
+			"snapshot-cache",
+			"preimages-enabled",
+			"snapshot-wait",
+			"snapshot-async",
+			"snapshot-verification-enabled",
+			"pruning-enabled",
+			"accepted-queue-limit",
+			"accepted-cache-size",
+			"commit-interval",
+			"allow-missing-tries",
+			"populate-missing-tries",
+			"populate-missing-tries-parallelism",
+			"prune-warp-db-enabled",
+			"metrics-enabled",
+			"metrics-expensive-enabled",
+			"local-txs-enabled",
+			"tx-pool-journal",
+			"tx-pool-rejournal",
+			"tx-pool-price-limit",
+			"tx-pool-price-bump",
+			"tx-pool-account-slots",
+			"tx-pool-global-slots",
+			"tx-pool-account-queue",
+			"tx-pool-global-queue",
+			"tx-pool-lifetime",
+			"api-max-duration",
+			"ws-cpu-refill-rate",
+			"ws-cpu-max-stored",
+			"api-max-blocks-per-request",
+			"allow-unfinalized-queries",
+			"allow-unprotected-txs",
+			"allow-unprotected-tx-hashes",
+			"keystore-directory",
+			"keystore-external-signer",
+			"keystore-insecure-unlock-allowed",
+			"remote-gossip-only-enabled",
+			"remote-tx-gossip-only-enabled",
+			"regossip-frequency",
+			"regossip-max-txs",
+			"regossip-txs-per-address",
+			"priority-regossip-frequency",
+			"priority-regossip-max-txs",
+			"priority-regossip-txs-per-address",
+			"priority-regossip-addresses",
+			"tx-regossip-frequency",
+			"tx-regossip-max-size",
+			"log-level",
+			"log-json-format",
+			"offline-pruning-enabled",
+			"offline-pruning-bloom-filter-size",
+			"offline-pruning-data-directory",
+			"max-outbound-active-requests",
+			"max-outbound-active-cross-chain-requests",
+			"state-sync-enabled",
+			"state-sync-skip-resume",
+			"state-sync-server-trie-cache",
+			"state-sync-ids",
+			"state-sync-commit-interval",
+			"state-sync-min-blocks",
+			"state-sync-request-size",
+			"inspect-database",
+			"skip-upgrade-check",
+			"skip-tx-indexing",
+			"tx-lookup-limit",
+		},
+	}
+
+	// top level keys of a subnet config
+	subnetConfigKeys = []string{
+		"validatorOnly",
+		"allowedNodes",
+		"consensusParameters",
+		"proposerMinBlockDelay",
+		"gossipConfig",
+		"gossipAcceptedFrontierValidatorSize",
+		"gossipAcceptedFrontierNonValidatorSize",
+		"gossipAcceptedFrontierPeerSize",
+		"gossipOnAcceptValidatorSize",
+		"gossipOnAcceptNonValidatorSize",
+		"gossipOnAcceptPeerSize",
+		"appGossipValidatorSize",
+		"appGossipNonValidatorSize",
+		"appGossipPeerSize",
+	}
+)
+
+// ValidateChainConfig returns an error if [chainConfig], the config file of
+// the chain with alias [chainAlias], isn't a JSON object or has top level
+// keys unknown to the VM of the chain. Only the chains of the primary
+// network (P, X and C) are validated, as the VM of the others isn't known
+// by alias.
+func ValidateChainConfig(chainAlias string, chainConfig []byte) error {
+	vmName, ok := chainAliasVMs[chainAlias]
+	if !ok {
+		return nil
+	}
+	if err := validateKeys(chainConfig, vmConfigKeys[vmName]); err != nil {
+		return fmt.Errorf("invalid %s chain config: %w", chainAlias, err)
+	}
+	return nil
+}
+
+// ValidateVMChainConfig returns an error if [chainConfig], the config file
+// of a chain of the VM [vmName], isn't a JSON object or has top level keys
+// unknown to the VM. The chain configs of other VMs than [PlatformVMName],
+// [AVMName] and [EVMName] aren't validated.
+func ValidateVMChainConfig(vmName string, chainConfig []byte) error {
+	keys, ok := vmConfigKeys[vmName]
+	if !ok {
+		return nil
+	}
+	if err := validateKeys(chainConfig, keys); err != nil {
+		return fmt.Errorf("invalid %s chain config: %w", vmName, err)
+	}
+	return nil
+}
+
+// ValidateSubnetConfig returns an error if [subnetConfig], the config file
+// of the subnet [subnetID], isn't a JSON object or has unknown top level keys
+func ValidateSubnetConfig(subnetID string, subnetConfig []byte) error {
+	if err := validateKeys(subnetConfig, subnetConfigKeys); err != nil {
+		return fmt.Errorf("invalid subnet %s config: %w", subnetID, err)
+	}
+	return nil
+}
+
+// Returns an error if [config] isn't a JSON object, or has top level keys
+// not in [knownKeys], suggesting the closest known key for each. An
+// empty [config] is valid, as the node takes it as no config.
+func validateKeys(config []byte, knownKeys []string) error {
+	if len(bytes.TrimSpace(config)) == 0 {
+		return nil
+	}
+	var entries map[string]json.RawMessage
+	if err := json.Unmarshal(config, &entries); err != nil {
+		return fmt.Errorf("expected a JSON object: %w", err)
+	}
+	known := make(map[string]struct{}, len(knownKeys))
+	for _, key := range knownKeys {
+		known[key] = struct{}{}
+	}
+	unknown := []string{}
+	for key := range entries {
+		if _, ok := known[key]; !ok {
+			unknown = append(unknown, key)
+		}
+	}
+	if len(unknown) == 0 {
+		return nil
+	}
+	sort.Strings(unknown)
+	msgs := make([]string, 0, len(unknown))
+	for _, key := range unknown {
+		msg := fmt.Sprintf("%q", key)
+		if suggestion := closestKey(key, knownKeys); suggestion != "" {
+			msg += fmt.Sprintf(" (did you mean %q?)", suggestion)
+		}
+		msgs = append(msgs, msg)
+	}
+	return fmt.Errorf("%w %s", ErrUnknownConfigKey, strings.Join(msgs, ", "))
+}
+
+// Returns the key of [keys] closest to [key], if within [maxSuggestionDistance]
+// edits of it, or else an empty string
+func closestKey(key string, keys []string) string {
+	closest := ""
+	closestDistance := maxSuggestionDistance + 1
+	for _, k := range keys {
+		if d := editDistance(strings.ToLower(key), strings.ToLower(k)); d < closestDistance {
+			closest = k
+			closestDistance = d
+		}
+	}
+	return closest
+}
+
+// Returns the Levenshtein distance between [a] and [b]
+func editDistance(a string, b string) int {
+	prev := make([]int, len(b)+1)
+	curr := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		curr[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			curr[j] = minInt(prev[j]+1, curr[j-1]+1, prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(b)]
+}
+
+func minInt(first int, rest ...int) int {
+	m := first
+	for _, v := range rest {
+		if v < m {
+			m = v
+		}
+	}
+	return m
+}
@@ -0,0 +1,54 @@
+// Copyright (C) 2021-2024, Lux Partners Limited. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package nodeconfig
+
+import (
+	_ "embed"
+	"encoding/json"
+	"path/filepath"
+	"strings"
+
+	"golang.org/x/exp/maps"
+	"golang.org/x/mod/semver"
+)
+
+type deprecatedFlagEsp struct {
+	Version  string `json:"version"`
+	OldName  string `json:"old_name"`
+	NewName  string `json:"new_name"`
+	ValueMap string `json:"value_map"`
+}
+
+var (
+	//go:embed deprecatedFlagsSupport.json
+	deprecatedFlagsSupportBytes []byte
+	deprecatedFlagsSupport      []deprecatedFlagEsp
+)
+
+// load deprecated luxd flags support information
+func init() {
+	if err := json.Unmarshal(deprecatedFlagsSupportBytes, &deprecatedFlagsSupport); err != nil {
+		panic(err)
+	}
+}
+
+// FlagsForVersion returns [givenFlags] made compatible with the node
+// version [luxdVersion], giving the flags renamed after it with their old names
+func FlagsForVersion(luxdVersion string, givenFlags map[string]string) map[string]string {
+	flags := maps.Clone(givenFlags)
+	for _, deprecatedFlagInfo := range deprecatedFlagsSupport {
+		if semver.Compare(luxdVersion, deprecatedFlagInfo.Version) < 0 {
+			if v, ok := flags[deprecatedFlagInfo.NewName]; ok {
+				if v != "" {
+					if deprecatedFlagInfo.ValueMap == "parent-dir" {
+						v = filepath.Dir(strings.TrimSuffix(v, "/"))
+					}
+					flags[deprecatedFlagInfo.OldName] = v
+				}
+				delete(flags, deprecatedFlagInfo.NewName)
+			}
+		}
+	}
+	return flags
+}
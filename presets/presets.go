@@ -0,0 +1,220 @@
+// Copyright (C) 2021-2024, Lux Partners Limited. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+// Package presets holds common network shapes, selectable by name. A preset
+// is fully parameterized: get it with Get, override any of its fields, and
+// build the network config with Preset.Config. The subnets of a preset are
+// created once the network is healthy, with Preset.SubnetSpecs.
+package presets
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/luxdefi/netrunner/local"
+	"github.com/luxdefi/netrunner/network"
+	"github.com/luxdefi/netrunner/network/node"
+	"golang.org/x/exp/maps"
+)
+
+// Preset names
+const (
+	// Five validators, as the default network
+	Default = "default"
+	// 11 validators, split among 3 subnets
+	Subnets = "subnets"
+	// Five validators, with archival and API nodes serving the history
+	// and the APIs apart from them
+	Archival = "archival"
+	// Five validators behind high latency links, as on a WAN
+	WAN = "wan"
+	// Six validators spread over three regions of different
+	// continents, with the latencies between them
+	Geo = "geo"
+)
+
+const (
+	// prefix of the public IPs of the nodes given a latency
+	publicIPPrefix = "127.0.1."
+	// max number of nodes given a latency, one per public IP
+	maxPublicIPNode = 254
+)
+
+var ErrUnknownPreset = errors.New("unknown network preset")
+
+// Preset is the shape of a network. The nodes are named node1, node2, ...,
+// with the validating nodes first, then the archival and API ones. The
+// first five nodes are the genesis validators. The other validating nodes
+// are registered as primary network validators with the subnets.
+type Preset struct {
+	// Number of validating nodes
+	NumNodes int `json:"numNodes"`
+	// Number of archival nodes (see node.RoleArchival)
+	NumArchivalNodes int `json:"numArchivalNodes,omitempty"`
+	// Number of non validating API nodes (see node.RoleAPI)
+	NumAPINodes int `json:"numAPINodes,omitempty"`
+	// Number of subnets, each validated by its share of the validating
+	// nodes, assigned round robin
+	NumSubnets int `json:"numSubnets,omitempty"`
+	// Delay of the P2P traffic between the nodes, in each direction. If
+	// given, each node advertises a public IP of the 127.0.1.0/24 loopback
+	// range, which the runner forwards to it with the delay (see
+	// node.Config.Latency). The range must be usable, as on Linux, or
+	// aliased to the loopback interface.
+	Latency time.Duration `json:"latency,omitempty"`
+	// Regions of the nodes and latencies between them. If given, the nodes
+	// are assigned to its regions round robin, in sorted order, and each
+	// node advertises a public IP as with [Latency], which then only
+	// applies to the traffic from outside the topology.
+	Topology *network.Topology `json:"topology,omitempty"`
+	// Flags of all the nodes, overriding the default ones
+	Flags map[string]interface{} `json:"flags,omitempty"`
+}
+
+var presets = map[string]Preset{
+	Default: {
+		NumNodes: local.DefaultNumNodes,
+	},
+	Subnets: {
+		NumNodes:   11,
+		NumSubnets: 3,
+	},
+	Archival: {
+		NumNodes:         local.DefaultNumNodes,
+		NumArchivalNodes: 2,
+		NumAPINodes:      2,
+	},
+	WAN: {
+		NumNodes: local.DefaultNumNodes,
+		Latency:  150 * time.Millisecond,
+	},
+	Geo: {
+		NumNodes: 6,
+		Topology: &network.Topology{
+			Latencies: map[string]map[string]time.Duration{
+				"us-east": {
+					"eu-west":      40 * time.Millisecond,
+					"ap-southeast": 90 * time.Millisecond,
+				},
+				"eu-west": {
+					"ap-southeast": 80 * time.Millisecond,
+				},
+			},
+		},
+	},
+}
+
+// Names returns the names of the presets, sorted
+func Names() []string {
+	names := maps.Keys(presets)
+	sort.Strings(names)
+	return names
+}
+
+// Get returns the preset [name], which can be overridden
+func Get(name string) (Preset, error) {
+	p, ok := presets[name]
+	if !ok {
+		return Preset{}, fmt.Errorf("%w %q, expected one of %v", ErrUnknownPreset, name, Names())
+	}
+	p.Flags = maps.Clone(p.Flags)
+	if p.Topology != nil {
+		latencies := make(map[string]map[string]time.Duration, len(p.Topology.Latencies))
+		for region, regionLatencies := range p.Topology.Latencies {
+			latencies[region] = maps.Clone(regionLatencies)
+		}
+		p.Topology = &network.Topology{Latencies: latencies}
+	}
+	return p, nil
+}
+
+// Validate returns an error if this preset is invalid
+func (p *Preset) Validate() error {
+	switch {
+	case p.NumNodes < 1:
+		return errors.New("a preset needs at least one validating node")
+	case p.NumArchivalNodes < 0 || p.NumAPINodes < 0 || p.NumSubnets < 0:
+		return errors.New("negative number of nodes or subnets")
+	case p.NumSubnets > p.NumNodes:
+		return fmt.Errorf("%d subnets can't be validated by %d nodes", p.NumSubnets, p.NumNodes)
+	case p.Latency < 0:
+		return fmt.Errorf("negative latency %s", p.Latency)
+	case p.publicIPs() && p.numNodes() > maxPublicIPNode:
+		return fmt.Errorf("latency can be given to at most %d nodes", maxPublicIPNode)
+	}
+	if p.Topology != nil {
+		if len(p.Topology.Regions()) == 0 {
+			return errors.New("a topology needs at least one region")
+		}
+		return p.Topology.Validate()
+	}
+	return nil
+}
+
+func (p *Preset) numNodes() int {
+	return p.NumNodes + p.NumArchivalNodes + p.NumAPINodes
+}
+
+// Returns true if the nodes advertise public IPs
+func (p *Preset) publicIPs() bool {
+	return p.Latency > 0 || p.Topology != nil
+}
+
+// Config returns the config of a network of this shape,
+// running [binaryPath]
+func (p *Preset) Config(binaryPath string) (network.Config, error) {
+	if err := p.Validate(); err != nil {
+		return network.Config{}, err
+	}
+	cfg, err := local.NewDefaultConfigNNodes(binaryPath, uint32(p.numNodes()))
+	if err != nil {
+		return network.Config{}, err
+	}
+	for k, v := range p.Flags {
+		cfg.Flags[k] = v
+	}
+	var regions []string
+	if p.Topology != nil {
+		topology := *p.Topology
+		cfg.Topology = &topology
+		regions = topology.Regions()
+	}
+	for i := range cfg.NodeConfigs {
+		nodeConfig := &cfg.NodeConfigs[i]
+		nodeConfig.Name = nodeName(i)
+		switch {
+		case i >= p.NumNodes+p.NumArchivalNodes:
+			nodeConfig.Role = node.RoleAPI
+			nodeConfig.IsBeacon = false
+		case i >= p.NumNodes:
+			nodeConfig.Role = node.RoleArchival
+			nodeConfig.IsBeacon = false
+		}
+		if p.publicIPs() {
+			nodeConfig.PublicIP = fmt.Sprintf("%s%d", publicIPPrefix, i+1)
+			nodeConfig.Latency = p.Latency
+		}
+		if len(regions) > 0 {
+			nodeConfig.Region = regions[i%len(regions)]
+		}
+	}
+	return cfg, nil
+}
+
+// SubnetSpecs returns the specs of the subnets of this preset, to be
+// created once the network is healthy
+func (p *Preset) SubnetSpecs() []network.SubnetSpec {
+	specs := make([]network.SubnetSpec, p.NumSubnets)
+	for i := 0; i < p.NumNodes && p.NumSubnets > 0; i++ {
+		spec := &specs[i%p.NumSubnets]
+		spec.Participants = append(spec.Participants, nodeName(i))
+	}
+	return specs
+}
+
+// Returns the name of the node at [index]
+func nodeName(index int) string {
+	return fmt.Sprintf("%s%d", network.DefaultNodeNamePrefix, index+1)
+}
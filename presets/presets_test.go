@@ -0,0 +1,99 @@
+// Copyright (C) 2021-2024, Lux Partners Limited. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package presets
+
+import (
+	"testing"
+	"time"
+
+	"github.com/luxdefi/netrunner/network/node"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGet(t *testing.T) {
+	require := require.New(t)
+
+	require.Equal([]string{Archival, Default, Geo, Subnets, WAN}, Names())
+	_, err := Get("unknown")
+	require.ErrorIs(err, ErrUnknownPreset)
+
+	// overriding a preset doesn't change it
+	p, err := Get(WAN)
+	require.NoError(err)
+	p.Flags = map[string]interface{}{"log-level": "debug"}
+	p, err = Get(WAN)
+	require.NoError(err)
+	require.Empty(p.Flags)
+
+	p, err = Get(Geo)
+	require.NoError(err)
+	p.Topology.Latencies["us-east"]["eu-west"] = time.Second
+	p, err = Get(Geo)
+	require.NoError(err)
+	require.Equal(40*time.Millisecond, p.Topology.Latencies["us-east"]["eu-west"])
+}
+
+func TestConfig(t *testing.T) {
+	require := require.New(t)
+
+	p, err := Get(Archival)
+	require.NoError(err)
+	p.NumAPINodes = 1
+	p.Flags = map[string]interface{}{"log-level": "debug"}
+	cfg, err := p.Config("node-binary")
+	require.NoError(err)
+	require.Len(cfg.NodeConfigs, 8)
+	require.Equal("node-binary", cfg.BinaryPath)
+	require.Equal("debug", cfg.Flags["log-level"])
+	for i, role := range []string{"", "", "", "", "", node.RoleArchival, node.RoleArchival, node.RoleAPI} {
+		nodeConfig := cfg.NodeConfigs[i]
+		require.Equal(nodeName(i), nodeConfig.Name)
+		require.Equal(role, nodeConfig.Role)
+		require.Equal(role == "", nodeConfig.IsBeacon)
+		require.Empty(nodeConfig.PublicIP)
+	}
+
+	p, err = Get(WAN)
+	require.NoError(err)
+	cfg, err = p.Config("node-binary")
+	require.NoError(err)
+	require.Len(cfg.NodeConfigs, 5)
+	require.Equal("127.0.1.5", cfg.NodeConfigs[4].PublicIP)
+	require.Equal(150*time.Millisecond, cfg.NodeConfigs[4].Latency)
+
+	p.NumSubnets = 6
+	_, err = p.Config("node-binary")
+	require.Error(err)
+
+	p, err = Get(Geo)
+	require.NoError(err)
+	cfg, err = p.Config("node-binary")
+	require.NoError(err)
+	require.NotNil(cfg.Topology)
+	for i, region := range []string{"ap-southeast", "eu-west", "us-east", "ap-southeast", "eu-west", "us-east"} {
+		nodeConfig := cfg.NodeConfigs[i]
+		require.Equal(region, nodeConfig.Region)
+		require.NotEmpty(nodeConfig.PublicIP)
+		require.Zero(nodeConfig.Latency)
+	}
+	latency, ok := cfg.Topology.Latency("ap-southeast", "us-east")
+	require.True(ok)
+	require.Equal(90*time.Millisecond, latency)
+}
+
+func TestSubnetSpecs(t *testing.T) {
+	require := require.New(t)
+
+	p, err := Get(Subnets)
+	require.NoError(err)
+	specs := p.SubnetSpecs()
+	require.Len(specs, 3)
+	require.Equal([]string{"node1", "node4", "node7", "node10"}, specs[0].Participants)
+	require.Equal([]string{"node2", "node5", "node8", "node11"}, specs[1].Participants)
+	require.Equal([]string{"node3", "node6", "node9"}, specs[2].Participants)
+
+	p, err = Get(Default)
+	require.NoError(err)
+	require.Empty(p.SubnetSpecs())
+}
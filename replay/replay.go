@@ -0,0 +1,273 @@
+// Copyright (C) 2021-2024, Lux Partners Limited. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+// Package replay exports the accepted blocks of a chain from a node index
+// into a portable file, and replays the transactions of an exported
+// C-Chain into a fresh network, to build regression fixtures out of
+// real chain activity.
+package replay
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/luxdefi/coreth/core/types"
+	"github.com/luxdefi/netrunner/network"
+	"github.com/luxdefi/netrunner/network/node"
+	"github.com/luxdefi/node/indexer"
+	"github.com/luxdefi/node/vms/proposervm/block"
+)
+
+const (
+	fileVersion = 1
+	// number of index containers fetched per call
+	exportBatchSize = 1024
+
+	defaultReceiptTimeout = time.Minute
+	receiptPollInterval   = 100 * time.Millisecond
+	// max line length of an export file, as blocks are base64 encoded in it
+	maxLineSize = 64 * 1024 * 1024
+)
+
+var (
+	ErrNoRunningNode      = errors.New("no running node")
+	ErrUnsupportedChain   = errors.New("only C-Chain exports can be replayed")
+	errUnknownFileVersion = errors.New("unknown export file version")
+	errMissingFileHeader  = errors.New("missing export file header")
+	errTxReceiptNotFound  = errors.New("tx receipt not found")
+	errReplayedTxFailed   = errors.New("replayed tx failed")
+)
+
+// Header is the first line of an export file
+type Header struct {
+	Version int `json:"version"`
+	// Chain alias (e.g. "C") or blockchain ID
+	Chain     string `json:"chain"`
+	NodeName  string `json:"nodeName"`
+	NumBlocks uint64 `json:"numBlocks"`
+}
+
+// Block is an accepted block of the node index, one per line
+// of an export file after the header
+type Block struct {
+	Index     uint64 `json:"index"`
+	ID        string `json:"id"`
+	Timestamp int64  `json:"timestamp"`
+	Bytes     []byte `json:"bytes"`
+}
+
+// ExportConfig of a chain export
+type ExportConfig struct {
+	// Node whose index is read. Defaults to the first running node by name.
+	// Its "index-enabled" flag must be set (e.g. node.RoleArchival).
+	NodeName string
+	// Chain alias or blockchain ID. Defaults to "C".
+	Chain string
+	// File to write
+	Path string
+}
+
+// Export writes the accepted blocks of a chain, in acceptance order, into
+// [cfg.Path]. Returns the header of the written file.
+func Export(ctx context.Context, nw network.Network, cfg ExportConfig) (*Header, error) {
+	if cfg.Chain == "" {
+		cfg.Chain = "C"
+	}
+	n, err := getNode(nw, cfg.NodeName)
+	if err != nil {
+		return nil, err
+	}
+	client := indexer.NewClient(n.GetAPIBaseURI() + "/ext/index/" + cfg.Chain + "/block")
+	_, lastIndex, err := client.GetLastAccepted(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't get last accepted block of the %s index of %q: %w", cfg.Chain, n.GetName(), err)
+	}
+
+	f, err := os.Create(cfg.Path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	w := bufio.NewWriter(f)
+	enc := json.NewEncoder(w)
+	header := &Header{
+		Version:   fileVersion,
+		Chain:     cfg.Chain,
+		NodeName:  n.GetName(),
+		NumBlocks: lastIndex + 1,
+	}
+	if err := enc.Encode(header); err != nil {
+		return nil, err
+	}
+	for index := uint64(0); index <= lastIndex; index += exportBatchSize {
+		containers, err := client.GetContainerRange(ctx, index, exportBatchSize)
+		if err != nil {
+			return nil, fmt.Errorf("couldn't get blocks from index %d: %w", index, err)
+		}
+		for i, container := range containers {
+			if err := enc.Encode(Block{
+				Index:     index + uint64(i),
+				ID:        container.ID.String(),
+				Timestamp: container.Timestamp,
+				Bytes:     container.Bytes,
+			}); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if err := w.Flush(); err != nil {
+		return nil, err
+	}
+	return header, f.Sync()
+}
+
+// IngestConfig of a replay
+type IngestConfig struct {
+	// Node the transactions are sent to. Defaults to the first running node by name.
+	NodeName string
+	// Export file to replay
+	Path string
+	// Max time to wait for the receipt of each transaction. Defaults to 1 minute.
+	ReceiptTimeout time.Duration
+}
+
+// IngestResult counts what was replayed
+type IngestResult struct {
+	Blocks uint64 `json:"blocks"`
+	Txs    uint64 `json:"txs"`
+	// Blocks with atomic txs, which are not replayed
+	BlocksWithAtomicTxs uint64 `json:"blocksWithAtomicTxs"`
+}
+
+// Ingest replays into [nw] the transactions of the C-Chain export at
+// [cfg.Path], block by block, waiting for the transactions of each block
+// to be accepted before sending the next ones. [nw] must have the genesis
+// of the exported network, so the transaction nonces and balances match.
+func Ingest(ctx context.Context, nw network.Network, cfg IngestConfig) (*IngestResult, error) {
+	if cfg.ReceiptTimeout == 0 {
+		cfg.ReceiptTimeout = defaultReceiptTimeout
+	}
+	n, err := getNode(nw, cfg.NodeName)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(cfg.Path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(nil, maxLineSize)
+
+	if !scanner.Scan() {
+		if err := scanner.Err(); err != nil {
+			return nil, err
+		}
+		return nil, errMissingFileHeader
+	}
+	header := Header{}
+	if err := json.Unmarshal(scanner.Bytes(), &header); err != nil {
+		return nil, fmt.Errorf("couldn't parse export file header: %w", err)
+	}
+	if header.Version != fileVersion {
+		return nil, fmt.Errorf("%w %d", errUnknownFileVersion, header.Version)
+	}
+	if header.Chain != "C" {
+		return nil, fmt.Errorf("%w, got %q", ErrUnsupportedChain, header.Chain)
+	}
+
+	ethClient := n.GetAPIClient().CChainEthAPI()
+	result := &IngestResult{}
+	for scanner.Scan() {
+		blk := Block{}
+		if err := json.Unmarshal(scanner.Bytes(), &blk); err != nil {
+			return nil, fmt.Errorf("couldn't parse block %d: %w", result.Blocks, err)
+		}
+		ethBlock, err := parseEthBlock(blk.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("couldn't parse block %d: %w", blk.Index, err)
+		}
+		if len(ethBlock.ExtData()) != 0 {
+			result.BlocksWithAtomicTxs++
+		}
+		txHashes := make([]common.Hash, 0, len(ethBlock.Transactions()))
+		for _, tx := range ethBlock.Transactions() {
+			if err := ethClient.SendTransaction(ctx, tx); err != nil {
+				return nil, fmt.Errorf("couldn't send tx %s of block %d: %w", tx.Hash(), blk.Index, err)
+			}
+			txHashes = append(txHashes, tx.Hash())
+		}
+		for _, txHash := range txHashes {
+			if err := awaitReceipt(ctx, n, txHash, cfg.ReceiptTimeout); err != nil {
+				return nil, fmt.Errorf("block %d: %w", blk.Index, err)
+			}
+		}
+		result.Blocks++
+		result.Txs += uint64(len(txHashes))
+	}
+	return result, scanner.Err()
+}
+
+// Returns the C-Chain block of [blkBytes], unwrapping it
+// from its snowman++ block if it was built after activation
+func parseEthBlock(blkBytes []byte) (*types.Block, error) {
+	if proposerBlk, err := block.Parse(blkBytes); err == nil {
+		blkBytes = proposerBlk.Block()
+	}
+	ethBlock := new(types.Block)
+	if err := rlp.DecodeBytes(blkBytes, ethBlock); err != nil {
+		return nil, err
+	}
+	return ethBlock, nil
+}
+
+// Waits for the successful receipt of [txHash]
+func awaitReceipt(ctx context.Context, n node.Node, txHash common.Hash, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	for {
+		receipt, err := n.GetAPIClient().CChainEthAPI().TransactionReceipt(ctx, txHash)
+		if err == nil {
+			if receipt.Status != types.ReceiptStatusSuccessful {
+				return fmt.Errorf("%w: %s", errReplayedTxFailed, txHash)
+			}
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("%w within %s: %s", errTxReceiptNotFound, timeout, txHash)
+		case <-time.After(receiptPollInterval):
+		}
+	}
+}
+
+// Returns the node of [nw] with [nodeName], or the
+// first running one by name if [nodeName] is empty
+func getNode(nw network.Network, nodeName string) (node.Node, error) {
+	if nodeName != "" {
+		return nw.GetNode(nodeName)
+	}
+	nodes, err := nw.GetAllNodes()
+	if err != nil {
+		return nil, err
+	}
+	nodeNames := make([]string, 0, len(nodes))
+	for name, n := range nodes {
+		if !n.GetPaused() {
+			nodeNames = append(nodeNames, name)
+		}
+	}
+	if len(nodeNames) == 0 {
+		return nil, ErrNoRunningNode
+	}
+	sort.Strings(nodeNames)
+	return nodes[nodeNames[0]], nil
+}
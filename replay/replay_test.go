@@ -0,0 +1,193 @@
+// Copyright (C) 2021-2024, Lux Partners Limited. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package replay
+
+import (
+	"context"
+	"encoding/json"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/ethereum/go-ethereum/common"
+	"github.com/ethereum/go-ethereum/rlp"
+	"github.com/luxdefi/coreth/core/types"
+	"github.com/luxdefi/netrunner/api"
+	apimocks "github.com/luxdefi/netrunner/api/mocks"
+	"github.com/luxdefi/netrunner/network"
+	"github.com/luxdefi/netrunner/network/node"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeNode is a node whose C-Chain API is mocked.
+// The methods not overridden panic.
+type fakeNode struct {
+	node.Node
+	name      string
+	paused    bool
+	apiClient api.Client
+}
+
+func (n *fakeNode) GetName() string {
+	return n.name
+}
+
+func (n *fakeNode) GetPaused() bool {
+	return n.paused
+}
+
+func (n *fakeNode) GetAPIClient() api.Client {
+	return n.apiClient
+}
+
+// fakeNetwork holds the nodes given by the test.
+// The methods not overridden panic.
+type fakeNetwork struct {
+	network.Network
+	nodes map[string]node.Node
+}
+
+func (nw *fakeNetwork) GetAllNodes() (map[string]node.Node, error) {
+	return nw.nodes, nil
+}
+
+func (nw *fakeNetwork) GetNode(nodeName string) (node.Node, error) {
+	n, ok := nw.nodes[nodeName]
+	if !ok {
+		return nil, network.ErrNodeNotFound
+	}
+	return n, nil
+}
+
+// Returns a network of a node replaying txs with receipts of [receiptStatus]
+func newReplayNetwork(ethClient *apimocks.EthClient, receiptStatus uint64) *fakeNetwork {
+	ethClient.On("SendTransaction", mock.Anything, mock.Anything).Return(nil)
+	ethClient.On("TransactionReceipt", mock.Anything, mock.Anything).Return(&types.Receipt{Status: receiptStatus}, nil)
+	apiClient := &apimocks.Client{}
+	apiClient.On("CChainEthAPI").Return(ethClient)
+	return &fakeNetwork{nodes: map[string]node.Node{
+		"node1": &fakeNode{name: "node1", apiClient: apiClient},
+	}}
+}
+
+// Writes an export file of [header] and of C-Chain blocks holding [blocksTxs]
+func writeExportFile(t *testing.T, header Header, blocksTxs ...[]*types.Transaction) string {
+	path := filepath.Join(t.TempDir(), "export.jsonl")
+	f, err := os.Create(path)
+	require.NoError(t, err)
+	defer f.Close()
+	enc := json.NewEncoder(f)
+	require.NoError(t, enc.Encode(header))
+	for i, txs := range blocksTxs {
+		ethBlock := types.NewBlockWithHeader(&types.Header{Number: big.NewInt(int64(i))}).WithBody(txs, nil)
+		blkBytes, err := rlp.EncodeToBytes(ethBlock)
+		require.NoError(t, err)
+		require.NoError(t, enc.Encode(Block{
+			Index: uint64(i),
+			ID:    ethBlock.Hash().String(),
+			Bytes: blkBytes,
+		}))
+	}
+	return path
+}
+
+func newTestTx(nonce uint64) *types.Transaction {
+	return types.NewTx(&types.LegacyTx{
+		Nonce:    nonce,
+		GasPrice: big.NewInt(1),
+		Gas:      21000,
+		To:       &common.Address{},
+		Value:    big.NewInt(1),
+	})
+}
+
+func TestIngest(t *testing.T) {
+	require := require.New(t)
+
+	txs := []*types.Transaction{newTestTx(0), newTestTx(1), newTestTx(2)}
+	path := writeExportFile(t,
+		Header{Version: fileVersion, Chain: "C", NodeName: "node1", NumBlocks: 3},
+		nil,
+		txs[:2],
+		txs[2:],
+	)
+	ethClient := &apimocks.EthClient{}
+	result, err := Ingest(context.Background(), newReplayNetwork(ethClient, types.ReceiptStatusSuccessful), IngestConfig{Path: path})
+	require.NoError(err)
+	require.Equal(&IngestResult{Blocks: 3, Txs: 3}, result)
+	sent := []common.Hash{}
+	for _, call := range ethClient.Calls {
+		if call.Method == "SendTransaction" {
+			sent = append(sent, call.Arguments.Get(1).(*types.Transaction).Hash())
+		}
+	}
+	require.Equal([]common.Hash{txs[0].Hash(), txs[1].Hash(), txs[2].Hash()}, sent)
+}
+
+func TestIngestFailedTx(t *testing.T) {
+	require := require.New(t)
+
+	path := writeExportFile(t, Header{Version: fileVersion, Chain: "C"}, []*types.Transaction{newTestTx(0)})
+	nw := newReplayNetwork(&apimocks.EthClient{}, types.ReceiptStatusFailed)
+	_, err := Ingest(context.Background(), nw, IngestConfig{Path: path, ReceiptTimeout: time.Second})
+	require.ErrorIs(err, errReplayedTxFailed)
+}
+
+func TestIngestFileHeader(t *testing.T) {
+	tests := []struct {
+		name        string
+		contents    string
+		expectedErr error
+	}{
+		{
+			name:        "empty file",
+			contents:    "",
+			expectedErr: errMissingFileHeader,
+		},
+		{
+			name:        "unknown version",
+			contents:    `{"version":2,"chain":"C"}`,
+			expectedErr: errUnknownFileVersion,
+		},
+		{
+			name:        "not the C-Chain",
+			contents:    `{"version":1,"chain":"X"}`,
+			expectedErr: ErrUnsupportedChain,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "export.jsonl")
+			require.NoError(t, os.WriteFile(path, []byte(tt.contents), 0o600))
+			nw := newReplayNetwork(&apimocks.EthClient{}, types.ReceiptStatusSuccessful)
+			_, err := Ingest(context.Background(), nw, IngestConfig{Path: path})
+			require.ErrorIs(t, err, tt.expectedErr)
+		})
+	}
+}
+
+func TestGetNode(t *testing.T) {
+	require := require.New(t)
+
+	nw := &fakeNetwork{nodes: map[string]node.Node{
+		"node1": &fakeNode{name: "node1", paused: true},
+		"node3": &fakeNode{name: "node3"},
+		"node2": &fakeNode{name: "node2"},
+	}}
+	n, err := getNode(nw, "")
+	require.NoError(err)
+	require.Equal("node2", n.GetName())
+	n, err = getNode(nw, "node1")
+	require.NoError(err)
+	require.Equal("node1", n.GetName())
+	_, err = getNode(nw, "node4")
+	require.ErrorIs(err, network.ErrNodeNotFound)
+
+	nw.nodes = map[string]node.Node{"node1": &fakeNode{name: "node1", paused: true}}
+	_, err = getNode(nw, "")
+	require.ErrorIs(err, ErrNoRunningNode)
+}
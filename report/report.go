@@ -0,0 +1,345 @@
+// Copyright (C) 2021-2024, Lux Partners Limited. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+// Package report builds structured reports of network runs,
+// rendered as JUnit XML or Markdown so CI systems can display them.
+package report
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/luxdefi/netrunner/network"
+)
+
+// Event is something that happened during the run
+type Event struct {
+	Time    time.Time `json:"time"`
+	Node    string    `json:"node,omitempty"`
+	Kind    string    `json:"kind"`
+	Message string    `json:"message"`
+}
+
+// Step is a timed operation of the run. A step with a non empty
+// Failure is considered failed.
+type Step struct {
+	Name     string        `json:"name"`
+	Start    time.Time     `json:"start"`
+	Duration time.Duration `json:"duration"`
+	Failure  string        `json:"failure,omitempty"`
+}
+
+// NodeSummary holds the final state of a node at the end of the run
+type NodeSummary struct {
+	Name     string `json:"name"`
+	Restarts int    `json:"restarts"`
+	// Chain alias --> last accepted height
+	FinalHeights map[string]uint64 `json:"finalHeights"`
+}
+
+// Report collects the events, steps and node summaries of a run.
+// It is safe for concurrent use.
+type Report struct {
+	lock   sync.Mutex
+	name   string
+	start  time.Time
+	end    time.Time
+	events []Event
+	steps  []Step
+	nodes  map[string]*NodeSummary
+}
+
+// New returns an empty report for the run [name], started now
+func New(name string) *Report {
+	return &Report{
+		name:  name,
+		start: time.Now(),
+		nodes: map[string]*NodeSummary{},
+	}
+}
+
+// AddEvent records an event of [kind] for [nodeName] (which may be empty)
+func (r *Report) AddEvent(nodeName string, kind string, msg string) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	r.events = append(r.events, Event{
+		Time:    time.Now(),
+		Node:    nodeName,
+		Kind:    kind,
+		Message: msg,
+	})
+}
+
+// RunStep executes [f] as a step named [name], recording its duration
+// and error (if any). The error returned by [f] is returned.
+func (r *Report) RunStep(name string, f func() error) error {
+	start := time.Now()
+	err := f()
+	step := Step{
+		Name:     name,
+		Start:    start,
+		Duration: time.Since(start),
+	}
+	if err != nil {
+		step.Failure = err.Error()
+	}
+	r.lock.Lock()
+	r.steps = append(r.steps, step)
+	r.lock.Unlock()
+	return err
+}
+
+// AddRestart increases the restart count of [nodeName]
+func (r *Report) AddRestart(nodeName string) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	r.getNode(nodeName).Restarts++
+}
+
+// SetFinalHeight sets the last accepted height of [chain] on [nodeName]
+func (r *Report) SetFinalHeight(nodeName string, chain string, height uint64) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	r.getNode(nodeName).FinalHeights[chain] = height
+}
+
+// CollectFinalHeights queries P-Chain and C-Chain heights of every
+// running node of [nw] and records them in the report.
+// Nodes that fail to answer get an error event instead.
+func (r *Report) CollectFinalHeights(ctx context.Context, nw network.Network) error {
+	nodes, err := nw.GetAllNodes()
+	if err != nil {
+		return err
+	}
+	for nodeName, node := range nodes {
+		if node.GetPaused() {
+			continue
+		}
+		pHeight, err := node.GetAPIClient().PChainAPI().GetHeight(ctx)
+		if err != nil {
+			r.AddEvent(nodeName, "error", fmt.Sprintf("couldn't get P-Chain height: %s", err))
+		} else {
+			r.SetFinalHeight(nodeName, "P", pHeight)
+		}
+		cHeight, err := node.GetAPIClient().CChainEthAPI().BlockNumber(ctx)
+		if err != nil {
+			r.AddEvent(nodeName, "error", fmt.Sprintf("couldn't get C-Chain height: %s", err))
+		} else {
+			r.SetFinalHeight(nodeName, "C", cHeight)
+		}
+	}
+	return nil
+}
+
+// Finish marks the end of the run
+func (r *Report) Finish() {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	r.end = time.Now()
+}
+
+// Failed returns true if any step failed
+func (r *Report) Failed() bool {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	for _, step := range r.steps {
+		if step.Failure != "" {
+			return true
+		}
+	}
+	return false
+}
+
+// Assumes [r.lock] is held.
+func (r *Report) getNode(nodeName string) *NodeSummary {
+	summary, ok := r.nodes[nodeName]
+	if !ok {
+		summary = &NodeSummary{
+			Name:         nodeName,
+			FinalHeights: map[string]uint64{},
+		}
+		r.nodes[nodeName] = summary
+	}
+	return summary
+}
+
+// Assumes [r.lock] is held.
+func (r *Report) duration() time.Duration {
+	if r.end.IsZero() {
+		return time.Since(r.start)
+	}
+	return r.end.Sub(r.start)
+}
+
+// Assumes [r.lock] is held.
+func (r *Report) sortedNodes() []*NodeSummary {
+	nodes := make([]*NodeSummary, 0, len(r.nodes))
+	for _, summary := range r.nodes {
+		nodes = append(nodes, summary)
+	}
+	sort.Slice(nodes, func(i, j int) bool {
+		return nodes[i].Name < nodes[j].Name
+	})
+	return nodes
+}
+
+type junitTestSuites struct {
+	XMLName xml.Name         `xml:"testsuites"`
+	Suites  []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name      string          `xml:"name,attr"`
+	Tests     int             `xml:"tests,attr"`
+	Failures  int             `xml:"failures,attr"`
+	Time      string          `xml:"time,attr"`
+	Timestamp string          `xml:"timestamp,attr"`
+	Cases     []junitTestCase `xml:"testcase"`
+	SystemOut string          `xml:"system-out,omitempty"`
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	ClassName string        `xml:"classname,attr"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitFailure `xml:"failure,omitempty"`
+}
+
+type junitFailure struct {
+	Message string `xml:"message,attr"`
+	Text    string `xml:",chardata"`
+}
+
+// WriteJUnit writes the report as JUnit XML, with one test case per step.
+// Events and node summaries are written to the suite system-out.
+func (r *Report) WriteJUnit(w io.Writer) error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	suite := junitTestSuite{
+		Name:      r.name,
+		Tests:     len(r.steps),
+		Time:      formatSeconds(r.duration()),
+		Timestamp: r.start.UTC().Format(time.RFC3339),
+	}
+	for _, step := range r.steps {
+		testCase := junitTestCase{
+			Name:      step.Name,
+			ClassName: r.name,
+			Time:      formatSeconds(step.Duration),
+		}
+		if step.Failure != "" {
+			suite.Failures++
+			testCase.Failure = &junitFailure{
+				Message: step.Failure,
+				Text:    step.Failure,
+			}
+		}
+		suite.Cases = append(suite.Cases, testCase)
+	}
+	systemOut := strings.Builder{}
+	for _, event := range r.events {
+		fmt.Fprintf(&systemOut, "%s %s %s %s\n", event.Time.UTC().Format(time.RFC3339), event.Kind, event.Node, event.Message)
+	}
+	for _, summary := range r.sortedNodes() {
+		fmt.Fprintf(&systemOut, "node %s restarts=%d heights=%s\n", summary.Name, summary.Restarts, formatHeights(summary.FinalHeights))
+	}
+	suite.SystemOut = systemOut.String()
+
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(junitTestSuites{Suites: []junitTestSuite{suite}}); err != nil {
+		return err
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+// WriteMarkdown writes the report as a Markdown document
+func (r *Report) WriteMarkdown(w io.Writer) error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	b := strings.Builder{}
+	result := "PASSED"
+	for _, step := range r.steps {
+		if step.Failure != "" {
+			result = "FAILED"
+			break
+		}
+	}
+	fmt.Fprintf(&b, "# %s\n\n", r.name)
+	fmt.Fprintf(&b, "**Result:** %s  \n", result)
+	fmt.Fprintf(&b, "**Started:** %s  \n", r.start.UTC().Format(time.RFC3339))
+	fmt.Fprintf(&b, "**Duration:** %s\n\n", r.duration().Round(time.Millisecond))
+
+	b.WriteString("## Steps\n\n")
+	b.WriteString("| Step | Duration | Result |\n")
+	b.WriteString("|------|----------|--------|\n")
+	for _, step := range r.steps {
+		stepResult := "ok"
+		if step.Failure != "" {
+			stepResult = "failed: " + escapeMarkdownCell(step.Failure)
+		}
+		fmt.Fprintf(&b, "| %s | %s | %s |\n", escapeMarkdownCell(step.Name), step.Duration.Round(time.Millisecond), stepResult)
+	}
+
+	if len(r.nodes) > 0 {
+		b.WriteString("\n## Nodes\n\n")
+		b.WriteString("| Node | Restarts | Final heights |\n")
+		b.WriteString("|------|----------|---------------|\n")
+		for _, summary := range r.sortedNodes() {
+			fmt.Fprintf(&b, "| %s | %d | %s |\n", summary.Name, summary.Restarts, formatHeights(summary.FinalHeights))
+		}
+	}
+
+	if len(r.events) > 0 {
+		b.WriteString("\n## Events\n\n")
+		for _, event := range r.events {
+			node := ""
+			if event.Node != "" {
+				node = " `" + event.Node + "`"
+			}
+			fmt.Fprintf(&b, "- %s **%s**%s %s\n", event.Time.UTC().Format(time.RFC3339), event.Kind, node, event.Message)
+		}
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func formatSeconds(d time.Duration) string {
+	return fmt.Sprintf("%.3f", d.Seconds())
+}
+
+func formatHeights(heights map[string]uint64) string {
+	chains := make([]string, 0, len(heights))
+	for chain := range heights {
+		chains = append(chains, chain)
+	}
+	sort.Strings(chains)
+	s := make([]string, 0, len(chains))
+	for _, chain := range chains {
+		s = append(s, fmt.Sprintf("%s:%d", chain, heights[chain]))
+	}
+	return strings.Join(s, " ")
+}
+
+func escapeMarkdownCell(s string) string {
+	s = strings.ReplaceAll(s, "|", "\\|")
+	return strings.ReplaceAll(s, "\n", " ")
+}
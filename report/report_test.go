@@ -0,0 +1,61 @@
+// Copyright (C) 2021-2024, Lux Partners Limited. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package report
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestReportWriteJUnit(t *testing.T) {
+	require := require.New(t)
+
+	r := New("soak")
+	require.NoError(r.RunStep("start network", func() error { return nil }))
+	require.Error(r.RunStep("create subnet", func() error { return errors.New("tx dropped") }))
+	r.AddRestart("node1")
+	r.SetFinalHeight("node1", "C", 42)
+	r.AddEvent("node1", "restart", "binary upgraded")
+	r.Finish()
+	require.True(r.Failed())
+
+	buf := bytes.Buffer{}
+	require.NoError(r.WriteJUnit(&buf))
+
+	suites := junitTestSuites{}
+	require.NoError(xml.Unmarshal(buf.Bytes(), &suites))
+	require.Len(suites.Suites, 1)
+	suite := suites.Suites[0]
+	require.Equal("soak", suite.Name)
+	require.Equal(2, suite.Tests)
+	require.Equal(1, suite.Failures)
+	require.Nil(suite.Cases[0].Failure)
+	require.NotNil(suite.Cases[1].Failure)
+	require.Equal("tx dropped", suite.Cases[1].Failure.Message)
+	require.Contains(suite.SystemOut, "node node1 restarts=1 heights=C:42")
+}
+
+func TestReportWriteMarkdown(t *testing.T) {
+	require := require.New(t)
+
+	r := New("smoke")
+	require.NoError(r.RunStep("wait | healthy", func() error { return nil }))
+	r.SetFinalHeight("node2", "P", 3)
+	r.SetFinalHeight("node2", "C", 7)
+	r.Finish()
+	require.False(r.Failed())
+
+	buf := bytes.Buffer{}
+	require.NoError(r.WriteMarkdown(&buf))
+	md := buf.String()
+	require.Contains(md, "# smoke")
+	require.Contains(md, "**Result:** PASSED")
+	require.Contains(md, "| wait \\| healthy |")
+	require.Contains(md, "| node2 | 0 | C:7 P:3 |")
+	require.NotContains(md, "## Events")
+}
@@ -121,6 +121,10 @@ const (
 	ControlService_WaitForHealthy_FullMethodName             = "/rpcpb.ControlService/WaitForHealthy"
 	ControlService_Status_FullMethodName                     = "/rpcpb.ControlService/Status"
 	ControlService_StreamStatus_FullMethodName               = "/rpcpb.ControlService/StreamStatus"
+	ControlService_StreamLogs_FullMethodName                 = "/rpcpb.ControlService/StreamLogs"
+	ControlService_PutFile_FullMethodName                    = "/rpcpb.ControlService/PutFile"
+	ControlService_GetFile_FullMethodName                    = "/rpcpb.ControlService/GetFile"
+	ControlService_AttachConsole_FullMethodName              = "/rpcpb.ControlService/AttachConsole"
 	ControlService_RemoveNode_FullMethodName                 = "/rpcpb.ControlService/RemoveNode"
 	ControlService_AddNode_FullMethodName                    = "/rpcpb.ControlService/AddNode"
 	ControlService_RestartNode_FullMethodName                = "/rpcpb.ControlService/RestartNode"
@@ -133,6 +137,13 @@ const (
 	ControlService_LoadSnapshot_FullMethodName               = "/rpcpb.ControlService/LoadSnapshot"
 	ControlService_RemoveSnapshot_FullMethodName             = "/rpcpb.ControlService/RemoveSnapshot"
 	ControlService_GetSnapshotNames_FullMethodName           = "/rpcpb.ControlService/GetSnapshotNames"
+	ControlService_SetNodeGroup_FullMethodName               = "/rpcpb.ControlService/SetNodeGroup"
+	ControlService_RemoveNodeGroup_FullMethodName            = "/rpcpb.ControlService/RemoveNodeGroup"
+	ControlService_GetNodeGroups_FullMethodName              = "/rpcpb.ControlService/GetNodeGroups"
+	ControlService_GroupOperation_FullMethodName             = "/rpcpb.ControlService/GroupOperation"
+	ControlService_AddPrimaryValidator_FullMethodName        = "/rpcpb.ControlService/AddPrimaryValidator"
+	ControlService_StartFromTemplate_FullMethodName          = "/rpcpb.ControlService/StartFromTemplate"
+	ControlService_GC_FullMethodName                         = "/rpcpb.ControlService/GC"
 )
 
 // ControlServiceClient is the client API for ControlService service.
@@ -151,6 +162,10 @@ type ControlServiceClient interface {
 	WaitForHealthy(ctx context.Context, in *WaitForHealthyRequest, opts ...grpc.CallOption) (*WaitForHealthyResponse, error)
 	Status(ctx context.Context, in *StatusRequest, opts ...grpc.CallOption) (*StatusResponse, error)
 	StreamStatus(ctx context.Context, in *StreamStatusRequest, opts ...grpc.CallOption) (ControlService_StreamStatusClient, error)
+	StreamLogs(ctx context.Context, in *StreamLogsRequest, opts ...grpc.CallOption) (ControlService_StreamLogsClient, error)
+	PutFile(ctx context.Context, opts ...grpc.CallOption) (ControlService_PutFileClient, error)
+	GetFile(ctx context.Context, in *GetFileRequest, opts ...grpc.CallOption) (ControlService_GetFileClient, error)
+	AttachConsole(ctx context.Context, opts ...grpc.CallOption) (ControlService_AttachConsoleClient, error)
 	RemoveNode(ctx context.Context, in *RemoveNodeRequest, opts ...grpc.CallOption) (*RemoveNodeResponse, error)
 	AddNode(ctx context.Context, in *AddNodeRequest, opts ...grpc.CallOption) (*AddNodeResponse, error)
 	RestartNode(ctx context.Context, in *RestartNodeRequest, opts ...grpc.CallOption) (*RestartNodeResponse, error)
@@ -163,6 +178,13 @@ type ControlServiceClient interface {
 	LoadSnapshot(ctx context.Context, in *LoadSnapshotRequest, opts ...grpc.CallOption) (*LoadSnapshotResponse, error)
 	RemoveSnapshot(ctx context.Context, in *RemoveSnapshotRequest, opts ...grpc.CallOption) (*RemoveSnapshotResponse, error)
 	GetSnapshotNames(ctx context.Context, in *GetSnapshotNamesRequest, opts ...grpc.CallOption) (*GetSnapshotNamesResponse, error)
+	SetNodeGroup(ctx context.Context, in *SetNodeGroupRequest, opts ...grpc.CallOption) (*SetNodeGroupResponse, error)
+	RemoveNodeGroup(ctx context.Context, in *RemoveNodeGroupRequest, opts ...grpc.CallOption) (*RemoveNodeGroupResponse, error)
+	GetNodeGroups(ctx context.Context, in *GetNodeGroupsRequest, opts ...grpc.CallOption) (*GetNodeGroupsResponse, error)
+	GroupOperation(ctx context.Context, in *GroupOperationRequest, opts ...grpc.CallOption) (*GroupOperationResponse, error)
+	AddPrimaryValidator(ctx context.Context, in *AddPrimaryValidatorRequest, opts ...grpc.CallOption) (*AddPrimaryValidatorResponse, error)
+	StartFromTemplate(ctx context.Context, in *StartFromTemplateRequest, opts ...grpc.CallOption) (*StartFromTemplateResponse, error)
+	GC(ctx context.Context, in *GCRequest, opts ...grpc.CallOption) (*GCResponse, error)
 }
 
 type controlServiceClient struct {
@@ -304,6 +326,135 @@ func (x *controlServiceStreamStatusClient) Recv() (*StreamStatusResponse, error)
 	return m, nil
 }
 
+func (c *controlServiceClient) StreamLogs(ctx context.Context, in *StreamLogsRequest, opts ...grpc.CallOption) (ControlService_StreamLogsClient, error) {
+	stream, err := c.cc.NewStream(ctx, &ControlService_ServiceDesc.Streams[1], ControlService_StreamLogs_FullMethodName, opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &controlServiceStreamLogsClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type ControlService_StreamLogsClient interface {
+	Recv() (*StreamLogsResponse, error)
+	grpc.ClientStream
+}
+
+type controlServiceStreamLogsClient struct {
+	grpc.ClientStream
+}
+
+func (x *controlServiceStreamLogsClient) Recv() (*StreamLogsResponse, error) {
+	m := new(StreamLogsResponse)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *controlServiceClient) PutFile(ctx context.Context, opts ...grpc.CallOption) (ControlService_PutFileClient, error) {
+	stream, err := c.cc.NewStream(ctx, &ControlService_ServiceDesc.Streams[2], ControlService_PutFile_FullMethodName, opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &controlServicePutFileClient{stream}
+	return x, nil
+}
+
+type ControlService_PutFileClient interface {
+	Send(*PutFileRequest) error
+	CloseAndRecv() (*PutFileResponse, error)
+	grpc.ClientStream
+}
+
+type controlServicePutFileClient struct {
+	grpc.ClientStream
+}
+
+func (x *controlServicePutFileClient) Send(m *PutFileRequest) error {
+	return x.ClientStream.SendMsg(m)
+}
+
+func (x *controlServicePutFileClient) CloseAndRecv() (*PutFileResponse, error) {
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	m := new(PutFileResponse)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *controlServiceClient) GetFile(ctx context.Context, in *GetFileRequest, opts ...grpc.CallOption) (ControlService_GetFileClient, error) {
+	stream, err := c.cc.NewStream(ctx, &ControlService_ServiceDesc.Streams[3], ControlService_GetFile_FullMethodName, opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &controlServiceGetFileClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type ControlService_GetFileClient interface {
+	Recv() (*GetFileResponse, error)
+	grpc.ClientStream
+}
+
+type controlServiceGetFileClient struct {
+	grpc.ClientStream
+}
+
+func (x *controlServiceGetFileClient) Recv() (*GetFileResponse, error) {
+	m := new(GetFileResponse)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *controlServiceClient) AttachConsole(ctx context.Context, opts ...grpc.CallOption) (ControlService_AttachConsoleClient, error) {
+	stream, err := c.cc.NewStream(ctx, &ControlService_ServiceDesc.Streams[4], ControlService_AttachConsole_FullMethodName, opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &controlServiceAttachConsoleClient{stream}
+	return x, nil
+}
+
+type ControlService_AttachConsoleClient interface {
+	Send(*AttachConsoleRequest) error
+	Recv() (*AttachConsoleResponse, error)
+	grpc.ClientStream
+}
+
+type controlServiceAttachConsoleClient struct {
+	grpc.ClientStream
+}
+
+func (x *controlServiceAttachConsoleClient) Send(m *AttachConsoleRequest) error {
+	return x.ClientStream.SendMsg(m)
+}
+
+func (x *controlServiceAttachConsoleClient) Recv() (*AttachConsoleResponse, error) {
+	m := new(AttachConsoleResponse)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
 func (c *controlServiceClient) RemoveNode(ctx context.Context, in *RemoveNodeRequest, opts ...grpc.CallOption) (*RemoveNodeResponse, error) {
 	out := new(RemoveNodeResponse)
 	err := c.cc.Invoke(ctx, ControlService_RemoveNode_FullMethodName, in, out, opts...)
@@ -412,6 +563,69 @@ func (c *controlServiceClient) GetSnapshotNames(ctx context.Context, in *GetSnap
 	return out, nil
 }
 
+func (c *controlServiceClient) SetNodeGroup(ctx context.Context, in *SetNodeGroupRequest, opts ...grpc.CallOption) (*SetNodeGroupResponse, error) {
+	out := new(SetNodeGroupResponse)
+	err := c.cc.Invoke(ctx, ControlService_SetNodeGroup_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *controlServiceClient) RemoveNodeGroup(ctx context.Context, in *RemoveNodeGroupRequest, opts ...grpc.CallOption) (*RemoveNodeGroupResponse, error) {
+	out := new(RemoveNodeGroupResponse)
+	err := c.cc.Invoke(ctx, ControlService_RemoveNodeGroup_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *controlServiceClient) GetNodeGroups(ctx context.Context, in *GetNodeGroupsRequest, opts ...grpc.CallOption) (*GetNodeGroupsResponse, error) {
+	out := new(GetNodeGroupsResponse)
+	err := c.cc.Invoke(ctx, ControlService_GetNodeGroups_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *controlServiceClient) GroupOperation(ctx context.Context, in *GroupOperationRequest, opts ...grpc.CallOption) (*GroupOperationResponse, error) {
+	out := new(GroupOperationResponse)
+	err := c.cc.Invoke(ctx, ControlService_GroupOperation_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *controlServiceClient) AddPrimaryValidator(ctx context.Context, in *AddPrimaryValidatorRequest, opts ...grpc.CallOption) (*AddPrimaryValidatorResponse, error) {
+	out := new(AddPrimaryValidatorResponse)
+	err := c.cc.Invoke(ctx, ControlService_AddPrimaryValidator_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *controlServiceClient) StartFromTemplate(ctx context.Context, in *StartFromTemplateRequest, opts ...grpc.CallOption) (*StartFromTemplateResponse, error) {
+	out := new(StartFromTemplateResponse)
+	err := c.cc.Invoke(ctx, ControlService_StartFromTemplate_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *controlServiceClient) GC(ctx context.Context, in *GCRequest, opts ...grpc.CallOption) (*GCResponse, error) {
+	out := new(GCResponse)
+	err := c.cc.Invoke(ctx, ControlService_GC_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
 // ControlServiceServer is the server API for ControlService service.
 // All implementations must embed UnimplementedControlServiceServer
 // for forward compatibility
@@ -428,6 +642,10 @@ type ControlServiceServer interface {
 	WaitForHealthy(context.Context, *WaitForHealthyRequest) (*WaitForHealthyResponse, error)
 	Status(context.Context, *StatusRequest) (*StatusResponse, error)
 	StreamStatus(*StreamStatusRequest, ControlService_StreamStatusServer) error
+	StreamLogs(*StreamLogsRequest, ControlService_StreamLogsServer) error
+	PutFile(ControlService_PutFileServer) error
+	GetFile(*GetFileRequest, ControlService_GetFileServer) error
+	AttachConsole(ControlService_AttachConsoleServer) error
 	RemoveNode(context.Context, *RemoveNodeRequest) (*RemoveNodeResponse, error)
 	AddNode(context.Context, *AddNodeRequest) (*AddNodeResponse, error)
 	RestartNode(context.Context, *RestartNodeRequest) (*RestartNodeResponse, error)
@@ -440,6 +658,13 @@ type ControlServiceServer interface {
 	LoadSnapshot(context.Context, *LoadSnapshotRequest) (*LoadSnapshotResponse, error)
 	RemoveSnapshot(context.Context, *RemoveSnapshotRequest) (*RemoveSnapshotResponse, error)
 	GetSnapshotNames(context.Context, *GetSnapshotNamesRequest) (*GetSnapshotNamesResponse, error)
+	SetNodeGroup(context.Context, *SetNodeGroupRequest) (*SetNodeGroupResponse, error)
+	RemoveNodeGroup(context.Context, *RemoveNodeGroupRequest) (*RemoveNodeGroupResponse, error)
+	GetNodeGroups(context.Context, *GetNodeGroupsRequest) (*GetNodeGroupsResponse, error)
+	GroupOperation(context.Context, *GroupOperationRequest) (*GroupOperationResponse, error)
+	AddPrimaryValidator(context.Context, *AddPrimaryValidatorRequest) (*AddPrimaryValidatorResponse, error)
+	StartFromTemplate(context.Context, *StartFromTemplateRequest) (*StartFromTemplateResponse, error)
+	GC(context.Context, *GCRequest) (*GCResponse, error)
 	mustEmbedUnimplementedControlServiceServer()
 }
 
@@ -483,6 +708,18 @@ func (UnimplementedControlServiceServer) Status(context.Context, *StatusRequest)
 func (UnimplementedControlServiceServer) StreamStatus(*StreamStatusRequest, ControlService_StreamStatusServer) error {
 	return status.Errorf(codes.Unimplemented, "method StreamStatus not implemented")
 }
+func (UnimplementedControlServiceServer) StreamLogs(*StreamLogsRequest, ControlService_StreamLogsServer) error {
+	return status.Errorf(codes.Unimplemented, "method StreamLogs not implemented")
+}
+func (UnimplementedControlServiceServer) PutFile(ControlService_PutFileServer) error {
+	return status.Errorf(codes.Unimplemented, "method PutFile not implemented")
+}
+func (UnimplementedControlServiceServer) GetFile(*GetFileRequest, ControlService_GetFileServer) error {
+	return status.Errorf(codes.Unimplemented, "method GetFile not implemented")
+}
+func (UnimplementedControlServiceServer) AttachConsole(ControlService_AttachConsoleServer) error {
+	return status.Errorf(codes.Unimplemented, "method AttachConsole not implemented")
+}
 func (UnimplementedControlServiceServer) RemoveNode(context.Context, *RemoveNodeRequest) (*RemoveNodeResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method RemoveNode not implemented")
 }
@@ -519,6 +756,27 @@ func (UnimplementedControlServiceServer) RemoveSnapshot(context.Context, *Remove
 func (UnimplementedControlServiceServer) GetSnapshotNames(context.Context, *GetSnapshotNamesRequest) (*GetSnapshotNamesResponse, error) {
 	return nil, status.Errorf(codes.Unimplemented, "method GetSnapshotNames not implemented")
 }
+func (UnimplementedControlServiceServer) SetNodeGroup(context.Context, *SetNodeGroupRequest) (*SetNodeGroupResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SetNodeGroup not implemented")
+}
+func (UnimplementedControlServiceServer) RemoveNodeGroup(context.Context, *RemoveNodeGroupRequest) (*RemoveNodeGroupResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RemoveNodeGroup not implemented")
+}
+func (UnimplementedControlServiceServer) GetNodeGroups(context.Context, *GetNodeGroupsRequest) (*GetNodeGroupsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetNodeGroups not implemented")
+}
+func (UnimplementedControlServiceServer) GroupOperation(context.Context, *GroupOperationRequest) (*GroupOperationResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GroupOperation not implemented")
+}
+func (UnimplementedControlServiceServer) AddPrimaryValidator(context.Context, *AddPrimaryValidatorRequest) (*AddPrimaryValidatorResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method AddPrimaryValidator not implemented")
+}
+func (UnimplementedControlServiceServer) StartFromTemplate(context.Context, *StartFromTemplateRequest) (*StartFromTemplateResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method StartFromTemplate not implemented")
+}
+func (UnimplementedControlServiceServer) GC(context.Context, *GCRequest) (*GCResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GC not implemented")
+}
 func (UnimplementedControlServiceServer) mustEmbedUnimplementedControlServiceServer() {}
 
 // UnsafeControlServiceServer may be embedded to opt out of forward compatibility for this service.
@@ -751,6 +1009,100 @@ func (x *controlServiceStreamStatusServer) Send(m *StreamStatusResponse) error {
 	return x.ServerStream.SendMsg(m)
 }
 
+func _ControlService_StreamLogs_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(StreamLogsRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(ControlServiceServer).StreamLogs(m, &controlServiceStreamLogsServer{stream})
+}
+
+type ControlService_StreamLogsServer interface {
+	Send(*StreamLogsResponse) error
+	grpc.ServerStream
+}
+
+type controlServiceStreamLogsServer struct {
+	grpc.ServerStream
+}
+
+func (x *controlServiceStreamLogsServer) Send(m *StreamLogsResponse) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _ControlService_PutFile_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(ControlServiceServer).PutFile(&controlServicePutFileServer{stream})
+}
+
+type ControlService_PutFileServer interface {
+	SendAndClose(*PutFileResponse) error
+	Recv() (*PutFileRequest, error)
+	grpc.ServerStream
+}
+
+type controlServicePutFileServer struct {
+	grpc.ServerStream
+}
+
+func (x *controlServicePutFileServer) SendAndClose(m *PutFileResponse) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func (x *controlServicePutFileServer) Recv() (*PutFileRequest, error) {
+	m := new(PutFileRequest)
+	if err := x.ServerStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func _ControlService_GetFile_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(GetFileRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(ControlServiceServer).GetFile(m, &controlServiceGetFileServer{stream})
+}
+
+type ControlService_GetFileServer interface {
+	Send(*GetFileResponse) error
+	grpc.ServerStream
+}
+
+type controlServiceGetFileServer struct {
+	grpc.ServerStream
+}
+
+func (x *controlServiceGetFileServer) Send(m *GetFileResponse) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _ControlService_AttachConsole_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(ControlServiceServer).AttachConsole(&controlServiceAttachConsoleServer{stream})
+}
+
+type ControlService_AttachConsoleServer interface {
+	Send(*AttachConsoleResponse) error
+	Recv() (*AttachConsoleRequest, error)
+	grpc.ServerStream
+}
+
+type controlServiceAttachConsoleServer struct {
+	grpc.ServerStream
+}
+
+func (x *controlServiceAttachConsoleServer) Send(m *AttachConsoleResponse) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func (x *controlServiceAttachConsoleServer) Recv() (*AttachConsoleRequest, error) {
+	m := new(AttachConsoleRequest)
+	if err := x.ServerStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
 func _ControlService_RemoveNode_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
 	in := new(RemoveNodeRequest)
 	if err := dec(in); err != nil {
@@ -967,6 +1319,132 @@ func _ControlService_GetSnapshotNames_Handler(srv interface{}, ctx context.Conte
 	return interceptor(ctx, in, info, handler)
 }
 
+func _ControlService_SetNodeGroup_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SetNodeGroupRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ControlServiceServer).SetNodeGroup(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ControlService_SetNodeGroup_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ControlServiceServer).SetNodeGroup(ctx, req.(*SetNodeGroupRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ControlService_RemoveNodeGroup_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RemoveNodeGroupRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ControlServiceServer).RemoveNodeGroup(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ControlService_RemoveNodeGroup_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ControlServiceServer).RemoveNodeGroup(ctx, req.(*RemoveNodeGroupRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ControlService_GetNodeGroups_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetNodeGroupsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ControlServiceServer).GetNodeGroups(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ControlService_GetNodeGroups_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ControlServiceServer).GetNodeGroups(ctx, req.(*GetNodeGroupsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ControlService_GroupOperation_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GroupOperationRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ControlServiceServer).GroupOperation(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ControlService_GroupOperation_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ControlServiceServer).GroupOperation(ctx, req.(*GroupOperationRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ControlService_AddPrimaryValidator_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(AddPrimaryValidatorRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ControlServiceServer).AddPrimaryValidator(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ControlService_AddPrimaryValidator_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ControlServiceServer).AddPrimaryValidator(ctx, req.(*AddPrimaryValidatorRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ControlService_StartFromTemplate_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(StartFromTemplateRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ControlServiceServer).StartFromTemplate(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ControlService_StartFromTemplate_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ControlServiceServer).StartFromTemplate(ctx, req.(*StartFromTemplateRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _ControlService_GC_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GCRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ControlServiceServer).GC(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: ControlService_GC_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ControlServiceServer).GC(ctx, req.(*GCRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
 // ControlService_ServiceDesc is the grpc.ServiceDesc for ControlService service.
 // It's only intended for direct use with grpc.RegisterService,
 // and not to be introspected or modified (even as a copy)
@@ -1066,6 +1544,34 @@ var ControlService_ServiceDesc = grpc.ServiceDesc{
 			MethodName: "GetSnapshotNames",
 			Handler:    _ControlService_GetSnapshotNames_Handler,
 		},
+		{
+			MethodName: "SetNodeGroup",
+			Handler:    _ControlService_SetNodeGroup_Handler,
+		},
+		{
+			MethodName: "RemoveNodeGroup",
+			Handler:    _ControlService_RemoveNodeGroup_Handler,
+		},
+		{
+			MethodName: "GetNodeGroups",
+			Handler:    _ControlService_GetNodeGroups_Handler,
+		},
+		{
+			MethodName: "GroupOperation",
+			Handler:    _ControlService_GroupOperation_Handler,
+		},
+		{
+			MethodName: "AddPrimaryValidator",
+			Handler:    _ControlService_AddPrimaryValidator_Handler,
+		},
+		{
+			MethodName: "StartFromTemplate",
+			Handler:    _ControlService_StartFromTemplate_Handler,
+		},
+		{
+			MethodName: "GC",
+			Handler:    _ControlService_GC_Handler,
+		},
 	},
 	Streams: []grpc.StreamDesc{
 		{
@@ -1073,6 +1579,27 @@ var ControlService_ServiceDesc = grpc.ServiceDesc{
 			Handler:       _ControlService_StreamStatus_Handler,
 			ServerStreams: true,
 		},
+		{
+			StreamName:    "StreamLogs",
+			Handler:       _ControlService_StreamLogs_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "PutFile",
+			Handler:       _ControlService_PutFile_Handler,
+			ClientStreams: true,
+		},
+		{
+			StreamName:    "GetFile",
+			Handler:       _ControlService_GetFile_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "AttachConsole",
+			Handler:       _ControlService_AttachConsole_Handler,
+			ServerStreams: true,
+			ClientStreams: true,
+		},
 	},
 	Metadata: "rpcpb/rpc.proto",
 }
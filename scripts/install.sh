@@ -21,6 +21,13 @@ Usage: $this [-b] bindir [-d] [tag]
    https://github.com/$PREFIX/releases
    If tag is missing, then the latest will be used.
 
+Environment:
+  NETRUNNER_DOWNLOAD_MIRROR  base URL of a mirror of the github release
+                             downloads, defaults to
+                             https://github.com/$PREFIX/releases/download
+  HTTPS_PROXY, ALL_PROXY     proxy used for downloads (curl also supports
+                             socks5:// proxies, wget only http ones)
+
 EOF
   exit 2
 }
@@ -345,7 +352,7 @@ log_prefix() {
 	echo "$PREFIX"
 }
 PLATFORM="${OS}/${ARCH}"
-GITHUB_DOWNLOAD=https://github.com/${OWNER}/${REPO}/releases/download
+GITHUB_DOWNLOAD=${NETRUNNER_DOWNLOAD_MIRROR:-https://github.com/${OWNER}/${REPO}/releases/download}
 
 uname_os_check "$OS"
 uname_arch_check "$ARCH"
@@ -38,6 +38,12 @@ else
     fi
 fi
 
+# Downloads can be redirected to mirrors of the github release pages
+# (e.g. inside restricted networks). curl honors the standard proxy
+# variables (HTTPS_PROXY, ALL_PROXY including socks5://, NO_PROXY).
+NODE_DOWNLOAD_MIRROR=${NODE_DOWNLOAD_MIRROR:-https://github.com/luxdefi/node/releases/download}
+SUBNET_EVM_DOWNLOAD_MIRROR=${SUBNET_EVM_DOWNLOAD_MIRROR:-https://github.com/luxdefi/subnet-evm/releases/download}
+
 echo "Running e2e tests with:"
 echo VERSION_1: ${VERSION_1}
 echo VERSION_2: ${VERSION_2}
@@ -50,10 +56,10 @@ then
     # https://github.com/luxdefi/node/releases
     GOARCH=$(go env GOARCH)
     GOOS=$(go env GOOS)
-    DOWNLOAD_URL=https://github.com/luxdefi/node/releases/download/v${VERSION_1}/node-linux-${GOARCH}-v${VERSION_1}.tar.gz
+    DOWNLOAD_URL=${NODE_DOWNLOAD_MIRROR}/v${VERSION_1}/node-linux-${GOARCH}-v${VERSION_1}.tar.gz
     DOWNLOAD_PATH=/tmp/node.tar.gz
     if [[ ${GOOS} == "darwin" ]]; then
-      DOWNLOAD_URL=https://github.com/luxdefi/node/releases/download/v${VERSION_1}/node-macos-v${VERSION_1}.zip
+      DOWNLOAD_URL=${NODE_DOWNLOAD_MIRROR}/v${VERSION_1}/node-macos-v${VERSION_1}.zip
       DOWNLOAD_PATH=/tmp/node.zip
     fi
 
@@ -62,7 +68,7 @@ then
     rm -f ${DOWNLOAD_PATH}
 
     echo "downloading node ${VERSION_1} at ${DOWNLOAD_URL}"
-    curl -L ${DOWNLOAD_URL} -o ${DOWNLOAD_PATH}
+    curl -fL ${DOWNLOAD_URL} -o ${DOWNLOAD_PATH}
 
     echo "extracting downloaded node"
     if [[ ${GOOS} == "linux" ]]; then
@@ -81,10 +87,10 @@ then
     # https://github.com/luxdefi/node/releases
     GOARCH=$(go env GOARCH)
     GOOS=$(go env GOOS)
-    DOWNLOAD_URL=https://github.com/luxdefi/node/releases/download/v${VERSION_2}/node-linux-${GOARCH}-v${VERSION_2}.tar.gz
+    DOWNLOAD_URL=${NODE_DOWNLOAD_MIRROR}/v${VERSION_2}/node-linux-${GOARCH}-v${VERSION_2}.tar.gz
     DOWNLOAD_PATH=/tmp/node.tar.gz
     if [[ ${GOOS} == "darwin" ]]; then
-      DOWNLOAD_URL=https://github.com/luxdefi/node/releases/download/v${VERSION_2}/node-macos-v${VERSION_2}.zip
+      DOWNLOAD_URL=${NODE_DOWNLOAD_MIRROR}/v${VERSION_2}/node-macos-v${VERSION_2}.zip
       DOWNLOAD_PATH=/tmp/node.zip
     fi
 
@@ -93,7 +99,7 @@ then
     rm -f ${DOWNLOAD_PATH}
 
     echo "downloading node ${VERSION_2} at ${DOWNLOAD_URL}"
-    curl -L ${DOWNLOAD_URL} -o ${DOWNLOAD_PATH}
+    curl -fL ${DOWNLOAD_URL} -o ${DOWNLOAD_PATH}
 
     echo "extracting downloaded node"
     if [[ ${GOOS} == "linux" ]]; then
@@ -112,17 +118,17 @@ then
     # https://github.com/luxdefi/subnet-evm/releases
     GOARCH=$(go env GOARCH)
     GOOS=$(go env GOOS)
-    DOWNLOAD_URL=https://github.com/luxdefi/subnet-evm/releases/download/v${SUBNET_EVM_VERSION}/subnet-evm_${SUBNET_EVM_VERSION}_linux_${GOARCH}.tar.gz
+    DOWNLOAD_URL=${SUBNET_EVM_DOWNLOAD_MIRROR}/v${SUBNET_EVM_VERSION}/subnet-evm_${SUBNET_EVM_VERSION}_linux_${GOARCH}.tar.gz
     DOWNLOAD_PATH=/tmp/subnet-evm.tar.gz
     if [[ ${GOOS} == "darwin" ]]; then
-      DOWNLOAD_URL=https://github.com/luxdefi/subnet-evm/releases/download/v${SUBNET_EVM_VERSION}/subnet-evm_${SUBNET_EVM_VERSION}_darwin_${GOARCH}.tar.gz
+      DOWNLOAD_URL=${SUBNET_EVM_DOWNLOAD_MIRROR}/v${SUBNET_EVM_VERSION}/subnet-evm_${SUBNET_EVM_VERSION}_darwin_${GOARCH}.tar.gz
     fi
 
     rm -rf /tmp/subnet-evm-v${SUBNET_EVM_VERSION}
     rm -f ${DOWNLOAD_PATH}
 
     echo "downloading subnet-evm ${SUBNET_EVM_VERSION} at ${DOWNLOAD_URL}"
-    curl -L ${DOWNLOAD_URL} -o ${DOWNLOAD_PATH}
+    curl -fL ${DOWNLOAD_URL} -o ${DOWNLOAD_PATH}
 
     echo "extracting downloaded subnet-evm"
     mkdir /tmp/subnet-evm-v${SUBNET_EVM_VERSION}
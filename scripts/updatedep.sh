@@ -6,5 +6,18 @@ if ! [[ "$0" =~ scripts/updatedep.sh ]]; then
   exit 255
 fi
 
+# direct deps pinned, as tidy would otherwise resolve them to their latest:
+# - zeroconf: mDNS advertising and browsing of servers (server/mdns.go,
+#   client/discover.go), no mDNS responder being in the std lib or in the deps
+# - goleveldb: read and compaction of the dbs of paused nodes (local/db_*.go),
+#   at the version of github.com/luxdefi/node, so that the on-disk format is
+#   the one the nodes write
+# - yaml.v3: parse of the expectation files (expect/expect.go), at the
+#   version github.com/stretchr/testify requires already
+go get \
+  github.com/grandcat/zeroconf@v1.0.0 \
+  github.com/syndtr/goleveldb@v1.0.1-0.20220614013038-64ee5596c38a \
+  gopkg.in/yaml.v3@v3.0.1
+
 # TODO: automatically bump up dependencies
 go mod tidy -v
@@ -0,0 +1,33 @@
+// Copyright (C) 2021-2024, Lux Partners Limited. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package server
+
+import (
+	"net/http"
+)
+
+const (
+	apiTracePath = "/v1/control/apitrace"
+
+	// number of API proxy requests whose traces are kept
+	maxAPITraces = 10000
+)
+
+// GET ?node=<name> returns the traces of the last requests served by the
+// node API proxy (method, params size, latency and status), oldest first.
+// Requires the API proxy to be run with tracing.
+func (s *server) handleAPITrace(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !s.authenticateHTTP(w, r) {
+		return
+	}
+	if s.apiTracer == nil {
+		http.Error(w, "API proxy tracing not enabled", http.StatusNotFound)
+		return
+	}
+	writeJSON(w, s.apiTracer.Traces(r.URL.Query().Get("node")))
+}
@@ -0,0 +1,67 @@
+// Copyright (C) 2021-2024, Lux Partners Limited. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package server
+
+import (
+	"net/http"
+	"path/filepath"
+	"strings"
+
+	"github.com/luxdefi/netrunner/binaries"
+	"golang.org/x/mod/semver"
+)
+
+const capabilitiesPath = "/v1/control/capabilities"
+
+// CapabilitiesResponse lists the capabilities of node binaries, and
+// whether they can run the nodes of one network with the given flags
+type CapabilitiesResponse struct {
+	Binaries   []*binaries.Capabilities `json:"binaries"`
+	Compatible bool                     `json:"compatible"`
+	// Why the binaries aren't compatible, if they aren't
+	Error string `json:"error,omitempty"`
+}
+
+// GET ?binary=<path or version>&binary=...&flag=<flag>&flag=... returns the
+// capabilities of the binaries, and whether they are compatible with each
+// other and the flags. Versions (e.g. v1.10.3) are downloaded if not cached.
+func (s *server) handleCapabilities(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if _, ok := s.httpTenant(w, r); !ok {
+		return
+	}
+	query := r.URL.Query()
+	binaryArgs := query["binary"]
+	if len(binaryArgs) == 0 {
+		http.Error(w, "no binary given", http.StatusBadRequest)
+		return
+	}
+	resp := CapabilitiesResponse{Binaries: []*binaries.Capabilities{}}
+	for _, binary := range binaryArgs {
+		binaryPath := binary
+		if semver.IsValid(binary) && !strings.ContainsRune(binary, filepath.Separator) {
+			var err error
+			binaryPath, err = binaries.Get(r.Context(), binary)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadGateway)
+				return
+			}
+		}
+		c, err := binaries.Inspect(r.Context(), binaryPath)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		resp.Binaries = append(resp.Binaries, c)
+	}
+	resp.Compatible = true
+	if err := binaries.CheckCompatible(resp.Binaries, query["flag"]); err != nil {
+		resp.Compatible = false
+		resp.Error = err.Error()
+	}
+	writeJSON(w, resp)
+}
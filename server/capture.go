@@ -0,0 +1,70 @@
+// Copyright (C) 2021-2024, Lux Partners Limited. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/luxdefi/netrunner/utils"
+	"go.uber.org/zap"
+)
+
+const capturePath = "/v1/control/capture"
+
+type captureRequest struct {
+	NodeName string `json:"nodeName"`
+	// Either "start" or "stop"
+	Action string `json:"action"`
+}
+
+type captureResponse struct {
+	// Path of the pcap file
+	Path string `json:"path"`
+}
+
+// POST {"nodeName": "node1", "action": "start"} starts capturing the node
+// traffic, {"action": "stop"} stops it. Both return the capture file path.
+func (s *server) handleCapture(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !s.authenticateHTTP(w, r) {
+		return
+	}
+	req := captureRequest{}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.network == nil {
+		http.Error(w, ErrNotBootstrapped.Error(), http.StatusServiceUnavailable)
+		return
+	}
+	s.log.Debug("Capture", utils.NodeField(req.NodeName), zap.String("action", req.Action))
+
+	var (
+		path string
+		err  error
+	)
+	switch req.Action {
+	case "start":
+		path, err = s.network.nw.StartCapture(r.Context(), req.NodeName)
+	case "stop":
+		path, err = s.network.nw.StopCapture(r.Context(), req.NodeName)
+	default:
+		http.Error(w, `action must be "start" or "stop"`, http.StatusBadRequest)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, captureResponse{Path: path})
+}
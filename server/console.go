@@ -0,0 +1,166 @@
+// Copyright (C) 2021-2024, Lux Partners Limited. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+
+	"github.com/luxdefi/netrunner/rpcpb"
+	"github.com/luxdefi/netrunner/utils"
+	"go.uber.org/zap"
+)
+
+// Actions of the AttachConsole requests
+const (
+	consoleActionSignal  = "signal"
+	consoleActionDump    = "dump"
+	consoleActionRestart = "restart"
+)
+
+var ErrInvalidConsoleAction = errors.New("invalid console action")
+
+// signals sent by the "signal" console action. SIGSTOP and SIGCONT
+// are left out, as FreezeNode and UnfreezeNode keep track of them.
+var consoleSignals = map[string]syscall.Signal{
+	"HUP":  syscall.SIGHUP,
+	"INT":  syscall.SIGINT,
+	"QUIT": syscall.SIGQUIT,
+	"KILL": syscall.SIGKILL,
+	"USR1": syscall.SIGUSR1,
+	"USR2": syscall.SIGUSR2,
+	"TERM": syscall.SIGTERM,
+}
+
+// AttachConsole streams the lines written by a node to its stdout and
+// stderr, across its restarts, while running the actions of the client
+// requests on the node. The stream goes on once the client is done
+// sending, until it cancels it.
+func (s *server) AttachConsole(stream rpcpb.ControlService_AttachConsoleServer) error {
+	first, err := stream.Recv()
+	if err != nil {
+		return err
+	}
+	nodeName := first.Name
+	s.log.Debug("AttachConsole", utils.NodeField(nodeName))
+
+	s.mu.RLock()
+	if s.network == nil {
+		s.mu.RUnlock()
+		return ErrNotBootstrapped
+	}
+	nw := s.network.nw
+	s.mu.RUnlock()
+
+	ctx, cancel := context.WithCancel(stream.Context())
+	defer cancel()
+	lines, err := nw.AttachConsole(ctx, nodeName)
+	if err != nil {
+		return err
+	}
+
+	// a stream can't be sent to concurrently
+	sendLock := sync.Mutex{}
+	send := func(resp *rpcpb.AttachConsoleResponse) error {
+		sendLock.Lock()
+		defer sendLock.Unlock()
+		return stream.Send(resp)
+	}
+
+	recvErrCh := make(chan error, 1)
+	go func() {
+		for req := first; ; {
+			if req.Action != "" {
+				resp := &rpcpb.AttachConsoleResponse{Action: req.Action}
+				if err := s.runConsoleAction(ctx, nodeName, req); err != nil {
+					resp.Error = err.Error()
+				}
+				if err := send(resp); err != nil {
+					recvErrCh <- err
+					return
+				}
+			}
+			var err error
+			req, err = stream.Recv()
+			if errors.Is(err, io.EOF) {
+				return
+			}
+			if err != nil {
+				recvErrCh <- err
+				return
+			}
+		}
+	}()
+
+	for {
+		select {
+		case line, ok := <-lines:
+			if !ok {
+				// the network is stopped
+				return nil
+			}
+			err = send(&rpcpb.AttachConsoleResponse{Stream: line.Stream, Line: line.Line})
+		case err = <-recvErrCh:
+		}
+		if err != nil {
+			if isClientCanceled(stream.Context().Err(), err) {
+				return nil
+			}
+			return err
+		}
+	}
+}
+
+// Runs the action of [req] on the node [nodeName]
+func (s *server) runConsoleAction(ctx context.Context, nodeName string, req *rpcpb.AttachConsoleRequest) error {
+	s.log.Info("console action", utils.NodeField(nodeName), zap.String("action", req.Action), zap.String("signal", req.Signal))
+
+	switch req.Action {
+	case consoleActionSignal:
+		sig, err := parseConsoleSignal(req.Signal)
+		if err != nil {
+			return err
+		}
+		return s.signalNode(ctx, nodeName, sig)
+	case consoleActionDump:
+		return s.signalNode(ctx, nodeName, syscall.SIGQUIT)
+	case consoleActionRestart:
+		_, err := s.RestartNode(ctx, &rpcpb.RestartNodeRequest{Name: nodeName})
+		return err
+	default:
+		return fmt.Errorf("%w %q: expected %s, %s or %s",
+			ErrInvalidConsoleAction, req.Action, consoleActionSignal, consoleActionDump, consoleActionRestart)
+	}
+}
+
+func (s *server) signalNode(ctx context.Context, nodeName string, sig syscall.Signal) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.network == nil {
+		return ErrNotBootstrapped
+	}
+	return s.network.nw.SignalNode(ctx, nodeName, sig)
+}
+
+// Returns the signal named [name], with or without its SIG prefix
+func parseConsoleSignal(name string) (syscall.Signal, error) {
+	sig, ok := consoleSignals[strings.TrimPrefix(strings.ToUpper(name), "SIG")]
+	if !ok {
+		supported := make([]string, 0, len(consoleSignals))
+		for name := range consoleSignals {
+			supported = append(supported, "SIG"+name)
+		}
+		sort.Strings(supported)
+		return 0, fmt.Errorf("%w: unsupported signal %q, expected one of %s",
+			ErrInvalidConsoleAction, name, strings.Join(supported, ", "))
+	}
+	return sig, nil
+}
@@ -0,0 +1,53 @@
+// Copyright (C) 2021-2024, Lux Partners Limited. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/luxdefi/netrunner/utils"
+	"go.uber.org/zap"
+)
+
+const compactDBPath = "/v1/control/compactdb"
+
+type compactDBRequest struct {
+	NodeName string `json:"nodeName"`
+	// If true, the C-chain state is pruned before compacting
+	Prune bool `json:"prune"`
+}
+
+// POST {"nodeName": "node1", "prune": true} compacts the db of the paused
+// node, e.g. before saving a snapshot, and returns the space reclaimed.
+func (s *server) handleCompactDB(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !s.authenticateHTTP(w, r) {
+		return
+	}
+	req := compactDBRequest{}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.network == nil {
+		http.Error(w, ErrNotBootstrapped.Error(), http.StatusServiceUnavailable)
+		return
+	}
+	s.log.Debug("CompactDB", utils.NodeField(req.NodeName), zap.Bool("prune", req.Prune))
+
+	compaction, err := s.network.nw.CompactNodeDB(r.Context(), req.NodeName, req.Prune)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, compaction)
+}
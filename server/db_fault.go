@@ -0,0 +1,71 @@
+// Copyright (C) 2021-2024, Lux Partners Limited. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/luxdefi/netrunner/network"
+	"github.com/luxdefi/netrunner/utils"
+	"go.uber.org/zap"
+)
+
+const corruptDBPath = "/v1/control/corruptdb"
+
+type corruptDBRequest struct {
+	NodeName string   `json:"nodeName"`
+	Files    []string `json:"files"`
+	// One of "truncate", "flip-bytes", "delete"
+	Mode     string `json:"mode"`
+	NumBytes int64  `json:"numBytes"`
+	Seed     int64  `json:"seed"`
+}
+
+type corruptDBResponse struct {
+	// Damaged files, relative to the node db dir
+	Files []string `json:"files"`
+}
+
+// POST {"nodeName": "node1", "files": ["*.ldb"], "mode": "flip-bytes", "seed": 1}
+// damages the db files of the paused node, to be resumed afterwards.
+func (s *server) handleCorruptDB(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !s.authenticateHTTP(w, r) {
+		return
+	}
+	req := corruptDBRequest{}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.network == nil {
+		http.Error(w, ErrNotBootstrapped.Error(), http.StatusServiceUnavailable)
+		return
+	}
+	s.log.Debug("CorruptDB",
+		utils.NodeField(req.NodeName),
+		zap.Strings("files", req.Files),
+		zap.String("mode", req.Mode),
+	)
+
+	files, err := s.network.nw.CorruptNodeDB(r.Context(), req.NodeName, network.DBCorruptionSpec{
+		Files:    req.Files,
+		Mode:     req.Mode,
+		NumBytes: req.NumBytes,
+		Seed:     req.Seed,
+	})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, corruptDBResponse{Files: files})
+}
@@ -0,0 +1,45 @@
+// Copyright (C) 2021-2024, Lux Partners Limited. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package server
+
+import (
+	"net/http"
+
+	"github.com/luxdefi/netrunner/utils"
+)
+
+const inspectDBPath = "/v1/control/inspectdb"
+
+// GET ?nodeName=node1 summarizes the db of the paused node: last accepted
+// block per chain, validator set and size breakdown by prefix.
+func (s *server) handleInspectDB(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !s.authenticateHTTP(w, r) {
+		return
+	}
+	nodeName := r.URL.Query().Get("nodeName")
+	if nodeName == "" {
+		http.Error(w, "missing nodeName", http.StatusBadRequest)
+		return
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.network == nil {
+		http.Error(w, ErrNotBootstrapped.Error(), http.StatusServiceUnavailable)
+		return
+	}
+	s.log.Debug("InspectDB", utils.NodeField(nodeName))
+
+	info, err := s.network.nw.InspectNodeDB(r.Context(), nodeName)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, info)
+}
@@ -0,0 +1,93 @@
+// Copyright (C) 2021-2024, Lux Partners Limited. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/luxdefi/netrunner/network"
+	"github.com/luxdefi/netrunner/network/node"
+	"github.com/luxdefi/node/ids"
+	"go.uber.org/zap"
+)
+
+const delegatorsPath = "/v1/control/delegators"
+
+type delegatorSpec struct {
+	NodeName string `json:"nodeName"`
+	// Optional elastic subnet and its staked asset
+	SubnetID string `json:"subnetID"`
+	AssetID  string `json:"assetID"`
+	// Optional, in nLUX or subnet asset units
+	Amount uint64 `json:"amount"`
+	// Optional RFC 3339 time
+	StartTime time.Time `json:"startTime"`
+	// Optional duration, e.g. "14d"
+	Duration string `json:"duration"`
+}
+
+type addDelegatorsRequest struct {
+	Delegators []delegatorSpec `json:"delegators"`
+}
+
+type addDelegatorsResponse struct {
+	TxIDs []ids.ID `json:"txIDs"`
+}
+
+// POST {"delegators": [{"nodeName": "node1", "amount": 25000000000}]}
+// delegates stake from the test keychain, returning the delegation tx IDs.
+func (s *server) handleAddDelegators(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !s.authenticateHTTP(w, r) {
+		return
+	}
+	req := addDelegatorsRequest{}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if len(req.Delegators) == 0 {
+		http.Error(w, "missing delegators", http.StatusBadRequest)
+		return
+	}
+	specs := make([]network.DelegatorSpec, len(req.Delegators))
+	for i, delegator := range req.Delegators {
+		specs[i] = network.DelegatorSpec{
+			NodeName:     delegator.NodeName,
+			SubnetID:     delegator.SubnetID,
+			AssetID:      delegator.AssetID,
+			StakedAmount: delegator.Amount,
+			StartTime:    delegator.StartTime,
+		}
+		if delegator.Duration != "" {
+			duration, err := node.ParseDuration(delegator.Duration)
+			if err != nil || duration <= 0 {
+				http.Error(w, "invalid duration "+delegator.Duration, http.StatusBadRequest)
+				return
+			}
+			specs[i].StakeDuration = duration
+		}
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.network == nil {
+		http.Error(w, ErrNotBootstrapped.Error(), http.StatusServiceUnavailable)
+		return
+	}
+	s.log.Debug("AddDelegators", zap.Int("num-of-delegators", len(specs)))
+
+	txIDs, err := s.network.nw.AddDelegators(r.Context(), specs)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, addDelegatorsResponse{TxIDs: txIDs})
+}
@@ -0,0 +1,86 @@
+// Copyright (C) 2021-2024, Lux Partners Limited. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/luxdefi/netrunner/devmode"
+	"github.com/luxdefi/netrunner/network"
+	"github.com/luxdefi/netrunner/utils"
+	"go.uber.org/zap"
+)
+
+const minePath = "/v1/control/mine"
+
+type mineRequest struct {
+	NodeName string `json:"nodeName"`
+	// EVM chain alias or blockchain ID. Defaults to "C".
+	Chain string `json:"chain"`
+	// Defaults to 1
+	NumBlocks uint64 `json:"numBlocks"`
+}
+
+type mineResponse struct {
+	// Height of the last block produced
+	Height uint64 `json:"height"`
+}
+
+// POST {"nodeName": "node1", "numBlocks": 5} produces blocks on demand on
+// an EVM chain of a dev network (see devmode), one at a time, each with a
+// transfer of the prefunded address to itself.
+func (s *server) handleMine(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !s.authenticateHTTP(w, r) {
+		return
+	}
+	req := mineRequest{
+		Chain:     "C",
+		NumBlocks: 1,
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if req.NodeName == "" {
+		http.Error(w, "missing nodeName", http.StatusBadRequest)
+		return
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.network == nil {
+		http.Error(w, ErrNotBootstrapped.Error(), http.StatusServiceUnavailable)
+		return
+	}
+	s.log.Debug("Mine", utils.NodeField(req.NodeName), zap.String("chain", req.Chain), zap.Uint64("num-blocks", req.NumBlocks))
+
+	node, err := s.network.nw.GetNode(req.NodeName)
+	if errors.Is(err, network.ErrNodeNotFound) {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	miner, err := devmode.NewMiner(node, req.Chain)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	defer miner.Close()
+	height, err := miner.MineBlocks(r.Context(), req.NumBlocks)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, mineResponse{Height: height})
+}
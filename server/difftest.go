@@ -0,0 +1,73 @@
+// Copyright (C) 2021-2024, Lux Partners Limited. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/luxdefi/netrunner/difftest"
+	"go.uber.org/zap"
+)
+
+const diffTestPath = "/v1/control/difftest"
+
+type diffTestRequest struct {
+	// Optional EVM chain API whose last blocks and balances are compared
+	// (e.g. "/ext/bc/C/rpc")
+	EVMEndpoint string `json:"evmEndpoint"`
+	// Addresses whose balances are compared on the EVM chain
+	Addresses []string `json:"addresses"`
+	// Number of EVM blocks compared. Defaults to 10.
+	NumBlocks uint64 `json:"numBlocks"`
+	// Additional calls
+	Calls []difftest.Call `json:"calls"`
+}
+
+// POST {"evmEndpoint": "/ext/bc/C/rpc", "addresses": ["0x..."]} sends
+// identical read calls to every running node, returning their responses
+// grouped by node and the calls the nodes diverge on.
+func (s *server) handleDiffTest(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !s.authenticateHTTP(w, r) {
+		return
+	}
+	req := diffTestRequest{}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if req.EVMEndpoint == "" && len(req.Calls) == 0 {
+		http.Error(w, "missing evmEndpoint or calls", http.StatusBadRequest)
+		return
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.network == nil {
+		http.Error(w, ErrNotBootstrapped.Error(), http.StatusServiceUnavailable)
+		return
+	}
+	s.log.Debug("DiffTest", zap.String("evm-endpoint", req.EVMEndpoint), zap.Int("num-of-calls", len(req.Calls)))
+
+	calls := req.Calls
+	if req.EVMEndpoint != "" {
+		evmCalls, err := difftest.EVMCalls(r.Context(), s.network.nw, req.EVMEndpoint, req.Addresses, req.NumBlocks)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		calls = append(evmCalls, calls...)
+	}
+	report, err := difftest.Mirror(r.Context(), s.network.nw, calls)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, report)
+}
@@ -0,0 +1,128 @@
+// Copyright (C) 2021-2024, Lux Partners Limited. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package server
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+
+	"github.com/luxdefi/netrunner/expect"
+	"github.com/luxdefi/netrunner/utils"
+	"go.uber.org/zap"
+)
+
+const (
+	assertionsPath = "/v1/control/assertions"
+	eventsPath     = "/v1/control/events"
+	runReportPath  = "/v1/control/runreport"
+
+	// max size of an assertions YAML document
+	maxAssertionsSize = 1024 * 1024
+)
+
+type eventRequest struct {
+	// Optional node the event is about
+	NodeName string `json:"nodeName"`
+	// Kind of the event, which starts the windows of the
+	// assertions registered after it (e.g. "partition-heal")
+	Kind    string `json:"kind"`
+	Message string `json:"message"`
+}
+
+// POST a YAML document of assertions (see expect.ParseYAML) registers
+// them. Assertions without an event are evaluated right away.
+func (s *server) handleAssertions(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !s.authenticateHTTP(w, r) {
+		return
+	}
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxAssertionsSize))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	assertions, err := expect.ParseYAML(body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.network == nil {
+		http.Error(w, ErrNotBootstrapped.Error(), http.StatusServiceUnavailable)
+		return
+	}
+	s.log.Debug("AddAssertions", zap.Int("num-of-assertions", len(assertions)))
+
+	if err := s.expectations.Add(s.network.nw, assertions...); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	writeJSON(w, assertions)
+}
+
+// POST {"kind": "partition-heal"} records a run event in the run report,
+// starting the windows of the assertions that are after it.
+func (s *server) handleEvent(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !s.authenticateHTTP(w, r) {
+		return
+	}
+	req := eventRequest{}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if req.Kind == "" {
+		http.Error(w, "missing kind", http.StatusBadRequest)
+		return
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.network == nil {
+		http.Error(w, ErrNotBootstrapped.Error(), http.StatusServiceUnavailable)
+		return
+	}
+	s.log.Debug("Event", utils.NodeField(req.NodeName), zap.String("kind", req.Kind))
+
+	s.expectations.Event(s.network.nw, req.NodeName, req.Kind, req.Message)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GET ?format=markdown|junit returns the run report, with the events and
+// a step per assertion evaluation, failed if the assertion didn't hold.
+// Add &wait=true to wait for the evaluations in progress first.
+func (s *server) handleRunReport(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !s.authenticateHTTP(w, r) {
+		return
+	}
+	if r.URL.Query().Get("wait") == "true" {
+		s.expectations.Wait()
+	}
+	switch format := r.URL.Query().Get("format"); format {
+	case "", "markdown":
+		w.Header().Set("Content-Type", "text/markdown")
+		_ = s.expectations.Report().WriteMarkdown(w)
+	case "junit":
+		w.Header().Set("Content-Type", "application/xml")
+		_ = s.expectations.Report().WriteJUnit(w)
+	default:
+		http.Error(w, "unknown format "+format, http.StatusBadRequest)
+	}
+}
@@ -0,0 +1,232 @@
+// Copyright (C) 2021-2024, Lux Partners Limited. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package server
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/luxdefi/netrunner/rpcpb"
+	"github.com/luxdefi/netrunner/utils"
+	"go.uber.org/zap"
+)
+
+const (
+	// size of the chunks of the files sent by GetFile
+	fileChunkSize = 64 * 1024
+	// default max size of the files transferred with PutFile and GetFile
+	defaultMaxFileSize = 512 * 1024 * 1024
+	// mode of the files written by PutFile, if not given
+	defaultFileMode = 0o644
+)
+
+var (
+	ErrInvalidFilePath  = errors.New("invalid file path")
+	ErrFileTooLarge     = errors.New("file too large")
+	ErrChecksumMismatch = errors.New("file checksum mismatch")
+)
+
+func (s *server) maxFileSize() int64 {
+	if s.cfg.MaxFileSize > 0 {
+		return s.cfg.MaxFileSize
+	}
+	return defaultMaxFileSize
+}
+
+// PutFile writes the file streamed by the client in the root dir of a node,
+// once fully received and checked against its checksum, if given
+func (s *server) PutFile(stream rpcpb.ControlService_PutFileServer) error {
+	first, err := stream.Recv()
+	if err != nil {
+		return err
+	}
+	s.log.Debug("PutFile", utils.NodeField(first.Name), zap.String("path", first.Path))
+
+	path, err := s.nodeFilePath(first.Name, first.Path)
+	if err != nil {
+		return err
+	}
+	mode := os.FileMode(first.Mode)
+	if mode == 0 {
+		mode = defaultFileMode
+	}
+	if mode&^os.ModePerm != 0 {
+		return fmt.Errorf("invalid file mode %o: expected permission bits only", first.Mode)
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o750); err != nil {
+		return err
+	}
+	// written next to the file, and renamed over it once checked,
+	// so that the node never reads a partial file
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = tmp.Close()
+		_ = os.Remove(tmp.Name())
+	}()
+
+	hash := sha256.New()
+	w := io.MultiWriter(tmp, hash)
+	maxSize := s.maxFileSize()
+	size := int64(0)
+	expectedSum := ""
+	for msg := first; ; {
+		size += int64(len(msg.Chunk))
+		if size > maxSize {
+			return fmt.Errorf("%w: more than %d bytes", ErrFileTooLarge, maxSize)
+		}
+		if _, err := w.Write(msg.Chunk); err != nil {
+			return err
+		}
+		if msg.Sha256 != "" {
+			expectedSum = msg.Sha256
+		}
+		msg, err = stream.Recv()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return err
+		}
+	}
+	sum := hex.EncodeToString(hash.Sum(nil))
+	if expectedSum != "" && !strings.EqualFold(expectedSum, sum) {
+		return fmt.Errorf("%w: expected %s, got %s", ErrChecksumMismatch, expectedSum, sum)
+	}
+	if err := tmp.Chmod(mode); err != nil {
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return err
+	}
+	s.log.Info("wrote node file",
+		utils.NodeField(first.Name),
+		zap.String("path", path),
+		zap.Int64("size", size),
+		zap.String("sha256", sum),
+	)
+	return stream.SendAndClose(&rpcpb.PutFileResponse{
+		Path:   filepath.ToSlash(filepath.Clean(first.Path)),
+		Size:   uint64(size),
+		Sha256: sum,
+	})
+}
+
+// GetFile sends a file of the root dir of a node in chunks,
+// followed by its checksum
+func (s *server) GetFile(req *rpcpb.GetFileRequest, stream rpcpb.ControlService_GetFileServer) error {
+	s.log.Debug("GetFile", utils.NodeField(req.Name), zap.String("path", req.Path))
+
+	path, err := s.nodeFilePath(req.Name, req.Path)
+	if err != nil {
+		return err
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	if !info.Mode().IsRegular() {
+		return fmt.Errorf("%w: %q is not a regular file", ErrInvalidFilePath, req.Path)
+	}
+	if maxSize := s.maxFileSize(); info.Size() > maxSize {
+		return fmt.Errorf("%w: %d bytes, more than %d", ErrFileTooLarge, info.Size(), maxSize)
+	}
+
+	hash := sha256.New()
+	r := io.TeeReader(f, hash)
+	buf := make([]byte, fileChunkSize)
+	for first := true; ; first = false {
+		n, err := io.ReadFull(r, buf)
+		// the first message gives the size, even of an empty file
+		if n > 0 || first {
+			resp := &rpcpb.GetFileResponse{Chunk: buf[:n]}
+			if first {
+				resp.Size = uint64(info.Size())
+			}
+			if err := stream.Send(resp); err != nil {
+				if isClientCanceled(stream.Context().Err(), err) {
+					return nil
+				}
+				return err
+			}
+		}
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			break
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return stream.Send(&rpcpb.GetFileResponse{Sha256: hex.EncodeToString(hash.Sum(nil))})
+}
+
+// Returns the path of the file [relPath] of the root dir of the node
+// [nodeName], that is its data dir, checking it doesn't lead out of it
+func (s *server) nodeFilePath(nodeName string, relPath string) (string, error) {
+	s.mu.RLock()
+	if s.network == nil {
+		s.mu.RUnlock()
+		return "", ErrNotBootstrapped
+	}
+	node, err := s.network.nw.GetNode(nodeName)
+	s.mu.RUnlock()
+	if err != nil {
+		return "", err
+	}
+	return resolveNodeFilePath(node.GetDataDir(), relPath)
+}
+
+// Returns the path of [relPath] in [rootDir], or an error if [relPath]
+// is absolute, or leads out of [rootDir] by its .. elements or by the
+// symlinks found on the way
+func resolveNodeFilePath(rootDir string, relPath string) (string, error) {
+	cleaned := filepath.Clean(filepath.FromSlash(relPath))
+	if relPath == "" || filepath.IsAbs(cleaned) || cleaned == "." || isOutside(cleaned) {
+		return "", fmt.Errorf("%w %q: expected a path relative to the node root dir", ErrInvalidFilePath, relPath)
+	}
+	path := filepath.Join(rootDir, cleaned)
+
+	realRoot, err := filepath.EvalSymlinks(rootDir)
+	if err != nil {
+		return "", err
+	}
+	// the longest part of the path that exists
+	existing := path
+	for {
+		if _, err := os.Lstat(existing); err == nil {
+			break
+		}
+		existing = filepath.Dir(existing)
+	}
+	realExisting, err := filepath.EvalSymlinks(existing)
+	if err != nil {
+		return "", err
+	}
+	rel, err := filepath.Rel(realRoot, realExisting)
+	if err != nil || isOutside(rel) {
+		return "", fmt.Errorf("%w %q: leads out of the node root dir", ErrInvalidFilePath, relPath)
+	}
+	return path, nil
+}
+
+// Returns true if the clean relative path [rel] leads to a parent dir
+func isOutside(rel string) bool {
+	return rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
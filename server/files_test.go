@@ -0,0 +1,188 @@
+// Copyright (C) 2021-2024, Lux Partners Limited. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package server
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/luxdefi/netrunner/rpcpb"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+)
+
+// putFileStream is a PutFile stream receiving [msgs]
+type putFileStream struct {
+	grpc.ServerStream
+	msgs []*rpcpb.PutFileRequest
+	resp *rpcpb.PutFileResponse
+}
+
+func (s *putFileStream) Recv() (*rpcpb.PutFileRequest, error) {
+	if len(s.msgs) == 0 {
+		return nil, io.EOF
+	}
+	msg := s.msgs[0]
+	s.msgs = s.msgs[1:]
+	return msg, nil
+}
+
+func (s *putFileStream) SendAndClose(resp *rpcpb.PutFileResponse) error {
+	s.resp = resp
+	return nil
+}
+
+func TestResolveNodeFilePath(t *testing.T) {
+	rootDir, err := filepath.EvalSymlinks(t.TempDir())
+	require.NoError(t, err)
+	outsideDir := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(rootDir, "configs"), 0o750))
+	require.NoError(t, os.WriteFile(filepath.Join(outsideDir, "secret"), nil, 0o600))
+	require.NoError(t, os.Symlink(outsideDir, filepath.Join(rootDir, "out")))
+	require.NoError(t, os.Symlink(filepath.Join(outsideDir, "secret"), filepath.Join(rootDir, "secret")))
+	require.NoError(t, os.Symlink(filepath.Join(rootDir, "configs"), filepath.Join(rootDir, "in")))
+
+	tests := []struct {
+		name         string
+		relPath      string
+		expectedPath string
+		expectedErr  error
+	}{
+		{
+			name:         "existing dir",
+			relPath:      "configs/config.json",
+			expectedPath: filepath.Join(rootDir, "configs", "config.json"),
+		},
+		{
+			name:         "missing dirs",
+			relPath:      "chainConfigs/C/config.json",
+			expectedPath: filepath.Join(rootDir, "chainConfigs", "C", "config.json"),
+		},
+		{
+			name:         ".. staying in the root",
+			relPath:      "chainConfigs/../configs/config.json",
+			expectedPath: filepath.Join(rootDir, "configs", "config.json"),
+		},
+		{
+			name:         "symlink in the root",
+			relPath:      "in/config.json",
+			expectedPath: filepath.Join(rootDir, "in", "config.json"),
+		},
+		{
+			name:        "empty",
+			relPath:     "",
+			expectedErr: ErrInvalidFilePath,
+		},
+		{
+			name:        "root",
+			relPath:     ".",
+			expectedErr: ErrInvalidFilePath,
+		},
+		{
+			name:        "absolute",
+			relPath:     "/etc/passwd",
+			expectedErr: ErrInvalidFilePath,
+		},
+		{
+			name:        "parent",
+			relPath:     "..",
+			expectedErr: ErrInvalidFilePath,
+		},
+		{
+			name:        ".. out of the root",
+			relPath:     "configs/../../config.json",
+			expectedErr: ErrInvalidFilePath,
+		},
+		{
+			name:        "symlinked dir out of the root",
+			relPath:     "out/config.json",
+			expectedErr: ErrInvalidFilePath,
+		},
+		{
+			name:        "symlinked dir out of the root, missing dirs",
+			relPath:     "out/a/b/config.json",
+			expectedErr: ErrInvalidFilePath,
+		},
+		{
+			name:        "symlinked file out of the root",
+			relPath:     "secret",
+			expectedErr: ErrInvalidFilePath,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require := require.New(t)
+			path, err := resolveNodeFilePath(rootDir, tt.relPath)
+			if tt.expectedErr != nil {
+				require.ErrorIs(err, tt.expectedErr)
+				return
+			}
+			require.NoError(err)
+			require.Equal(tt.expectedPath, path)
+		})
+	}
+}
+
+func TestPutFile(t *testing.T) {
+	content := []byte("{\"log-level\":\"debug\"}")
+	sum := sha256.Sum256(content)
+
+	tests := []struct {
+		name        string
+		sha256      string
+		expectedErr error
+	}{
+		{
+			name: "no checksum",
+		},
+		{
+			name:   "checksum",
+			sha256: hex.EncodeToString(sum[:]),
+		},
+		{
+			name:        "checksum mismatch",
+			sha256:      hex.EncodeToString(make([]byte, sha256.Size)),
+			expectedErr: ErrChecksumMismatch,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require := require.New(t)
+			dataDir := t.TempDir()
+			nw := newFakeNetwork("node1")
+			nw.nodes["node1"].dataDir = dataDir
+			s := newTestServer(t, Config{})
+			setTestNetwork(s, nw, "")
+
+			// the content is sent in two chunks, the checksum with the last one
+			stream := &putFileStream{msgs: []*rpcpb.PutFileRequest{
+				{Name: "node1", Path: "configs/config.json", Chunk: content[:4]},
+				{Chunk: content[4:], Sha256: tt.sha256},
+			}}
+			err := s.PutFile(stream)
+			path := filepath.Join(dataDir, "configs", "config.json")
+			if tt.expectedErr != nil {
+				require.ErrorIs(err, tt.expectedErr)
+				require.Nil(stream.resp)
+				// neither the file nor its temporary copy are left
+				require.NoFileExists(path)
+				entries, err := os.ReadDir(filepath.Dir(path))
+				require.NoError(err)
+				require.Empty(entries)
+				return
+			}
+			require.NoError(err)
+			require.Equal("configs/config.json", stream.resp.Path)
+			require.Equal(uint64(len(content)), stream.resp.Size)
+			require.Equal(hex.EncodeToString(sum[:]), stream.resp.Sha256)
+			written, err := os.ReadFile(path)
+			require.NoError(err)
+			require.Equal(content, written)
+		})
+	}
+}
@@ -0,0 +1,139 @@
+// Copyright (C) 2021-2024, Lux Partners Limited. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package server
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/luxdefi/netrunner/local"
+	"github.com/luxdefi/netrunner/rpcpb"
+	"github.com/luxdefi/netrunner/utils/constants"
+	"go.uber.org/zap"
+	"golang.org/x/exp/slices"
+)
+
+const gcInterval = time.Hour
+
+// Returns the default root data dir of the networks
+func defaultRootDataDir() string {
+	return filepath.Join(os.TempDir(), constants.RootDirPrefix)
+}
+
+// Returns the default root data dir and the ones of every tenant
+func allRootDataDirs() ([]string, error) {
+	rootDataDir := defaultRootDataDir()
+	tenantDirs, err := filepath.Glob(filepath.Join(rootDataDir, tenantsSubdir, "*"))
+	if err != nil {
+		return nil, err
+	}
+	return append([]string{rootDataDir}, tenantDirs...), nil
+}
+
+// Returns true if [tenant] can garbage collect the root dirs of every tenant
+func (s *server) isAdmin(tenant string) bool {
+	return !s.tenancyEnabled() || slices.Contains(s.cfg.AdminTenants, tenant)
+}
+
+// Returns the orphaned network root dirs directly under [parentDirs].
+// The root dirs of the served network and of the registered networks
+// not stopped, e.g. detached ones, are in use.
+func (s *server) findOrphanedRootDirs(parentDirs []string) ([]local.NetworkRootDir, error) {
+	s.mu.RLock()
+	inUse := []string{}
+	if s.network != nil {
+		inUse = append(inUse, s.clusterInfo.RootDataDir)
+	}
+	s.mu.RUnlock()
+
+	networks, err := s.registry.list("")
+	if err != nil {
+		return nil, err
+	}
+	for _, n := range networks {
+		if n.Status != RegistryStopped {
+			inUse = append(inUse, n.RootDataDir)
+		}
+	}
+	return local.FindOrphanedRootDirs(parentDirs, inUse)
+}
+
+// Periodically deletes the orphaned root dirs not modified
+// within [s.cfg.GCRetention], until [ctx] is done
+func (s *server) runJanitor(ctx context.Context) {
+	ticker := time.NewTicker(gcInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		parentDirs, err := allRootDataDirs()
+		if err != nil {
+			s.log.Warn("janitor couldn't find orphaned root dirs", zap.Error(err))
+			continue
+		}
+		dirs, err := s.findOrphanedRootDirs(parentDirs)
+		if err != nil {
+			s.log.Warn("janitor couldn't find orphaned root dirs", zap.Error(err))
+			continue
+		}
+		expired := []local.NetworkRootDir{}
+		for _, dir := range dirs {
+			if time.Since(dir.ModTime) > s.cfg.GCRetention {
+				expired = append(expired, dir)
+			}
+		}
+		if len(expired) == 0 {
+			continue
+		}
+		if err := local.RemoveRootDirs(expired); err != nil {
+			s.log.Warn("janitor couldn't remove orphaned root dirs", zap.Error(err))
+			continue
+		}
+		for _, dir := range expired {
+			s.log.Info("janitor removed orphaned root dir", zap.String("path", dir.Path), zap.Uint64("size", dir.Size))
+		}
+	}
+}
+
+// GC reports the orphaned network root dirs of the caller tenant, or of
+// every tenant if it is an admin, and deletes them if [req.Confirm]
+func (s *server) GC(ctx context.Context, req *rpcpb.GCRequest) (*rpcpb.GCResponse, error) {
+	s.log.Debug("GC", zap.Bool("confirm", req.Confirm))
+
+	tenant := tenantFromContext(ctx)
+	parentDirs := []string{tenantRootDataDir(defaultRootDataDir(), tenant)}
+	if s.isAdmin(tenant) {
+		var err error
+		parentDirs, err = allRootDataDirs()
+		if err != nil {
+			return nil, err
+		}
+	}
+	dirs, err := s.findOrphanedRootDirs(parentDirs)
+	if err != nil {
+		return nil, err
+	}
+	resp := &rpcpb.GCResponse{Dirs: []*rpcpb.OrphanedRootDir{}}
+	for _, dir := range dirs {
+		resp.Dirs = append(resp.Dirs, &rpcpb.OrphanedRootDir{
+			Path:    dir.Path,
+			Size:    dir.Size,
+			ModTime: dir.ModTime.UnixNano(),
+			Labels:  dir.Labels,
+		})
+		resp.ReclaimableBytes += dir.Size
+	}
+	if req.Confirm {
+		if err := local.RemoveRootDirs(dirs); err != nil {
+			return nil, err
+		}
+		resp.Deleted = true
+	}
+	return resp, nil
+}
@@ -0,0 +1,100 @@
+// Copyright (C) 2021-2024, Lux Partners Limited. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package server
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/luxdefi/netrunner/rpcpb"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGC(t *testing.T) {
+	t.Setenv("TMPDIR", t.TempDir())
+	newRootDir := func(tenant string, name string) string {
+		rootDir := filepath.Join(tenantRootDataDir(defaultRootDataDir(), tenant), name)
+		require.NoError(t, os.MkdirAll(rootDir, os.ModePerm))
+		return rootDir
+	}
+	untenanted := newRootDir("", "network-1")
+	tenantA := newRootDir("team-a", "network-1")
+	tenantB := newRootDir("team-b", "network-1")
+	served := newRootDir("team-a", "network-2")
+	registered := newRootDir("team-b", "network-2")
+
+	tests := []struct {
+		name     string
+		tenant   string
+		wantDirs []string
+	}{
+		{
+			name:     "tenant",
+			tenant:   "team-a",
+			wantDirs: []string{tenantA},
+		},
+		{
+			name:     "other tenant",
+			tenant:   "team-b",
+			wantDirs: []string{tenantB},
+		},
+		{
+			name:     "admin",
+			tenant:   "admin",
+			wantDirs: []string{untenanted, tenantA, tenantB},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require := require.New(t)
+
+			s := newTestServer(t, Config{
+				TenantTokens: map[string]string{"token-a": "team-a", "token-b": "team-b", "token-admin": "admin"},
+				AdminTenants: []string{"admin"},
+			})
+			setTestNetwork(s, newFakeNetwork(), "team-a")
+			s.clusterInfo.RootDataDir = served
+			// e.g. served by another server sharing the registry
+			s.registry.networks[registered] = &RegisteredNetwork{
+				RootDataDir: registered,
+				Tenant:      "team-b",
+				Status:      RegistryRunning,
+			}
+
+			ctx := context.WithValue(context.Background(), tenantCtxKey{}, tt.tenant)
+			resp, err := s.GC(ctx, &rpcpb.GCRequest{})
+			require.NoError(err)
+			require.False(resp.Deleted)
+			dirs := []string{}
+			for _, dir := range resp.Dirs {
+				dirs = append(dirs, dir.Path)
+			}
+			require.ElementsMatch(tt.wantDirs, dirs)
+		})
+	}
+}
+
+func TestGCConfirm(t *testing.T) {
+	require := require.New(t)
+
+	t.Setenv("TMPDIR", t.TempDir())
+	orphan := filepath.Join(tenantRootDataDir(defaultRootDataDir(), "team-a"), "network-1")
+	require.NoError(os.MkdirAll(orphan, os.ModePerm))
+	require.NoError(os.WriteFile(filepath.Join(orphan, "file"), []byte("data"), 0o600))
+	other := filepath.Join(tenantRootDataDir(defaultRootDataDir(), "team-b"), "network-1")
+	require.NoError(os.MkdirAll(other, os.ModePerm))
+
+	s := newTestServer(t, Config{TenantTokens: map[string]string{"token-a": "team-a", "token-b": "team-b"}})
+	ctx := context.WithValue(context.Background(), tenantCtxKey{}, "team-a")
+	resp, err := s.GC(ctx, &rpcpb.GCRequest{Confirm: true})
+	require.NoError(err)
+	require.True(resp.Deleted)
+	require.Len(resp.Dirs, 1)
+	require.Equal(orphan, resp.Dirs[0].Path)
+	require.Positive(resp.ReclaimableBytes)
+	require.NoDirExists(orphan)
+	require.DirExists(other)
+}
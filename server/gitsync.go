@@ -0,0 +1,341 @@
+// Copyright (C) 2021-2024, Lux Partners Limited. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/luxdefi/netrunner/rpcpb"
+	"go.uber.org/zap"
+)
+
+const (
+	gitSyncPath            = "/v1/control/gitsync"
+	defaultGitSyncBranch   = "main"
+	defaultGitSyncFile     = "network.json"
+	defaultGitSyncInterval = 30 * time.Second
+	gitCommandTimeout      = 2 * time.Minute
+)
+
+var ErrInvalidGitSyncSpec = errors.New("invalid git sync spec")
+
+// GitSyncSpec is the network the server keeps running, as given by the
+// spec file of the watched git repo
+type GitSyncSpec struct {
+	// Starts the network if none is running. As for any start request,
+	// its nodes are named node1 to node<N>.
+	Start *rpcpb.StartRequest `json:"start"`
+	// If not empty, names of the nodes of the network, mapped to their node
+	// config, as given to AddNode. The nodes not in here are removed, and the
+	// missing ones are added with the exec path and plugin dir of [Start].
+	Nodes map[string]string `json:"nodes,omitempty"`
+}
+
+func (spec *GitSyncSpec) validate() error {
+	if spec.Start == nil {
+		return fmt.Errorf("%w: no start request given", ErrInvalidGitSyncSpec)
+	}
+	return nil
+}
+
+// GitSyncStatus of the sync of the network with the watched git repo
+type GitSyncStatus struct {
+	Repo   string `json:"repo"`
+	Branch string `json:"branch"`
+	File   string `json:"file"`
+	// Revision of the last spec applied
+	Revision  string     `json:"revision,omitempty"`
+	LastSync  *time.Time `json:"lastSync,omitempty"`
+	LastError string     `json:"lastError,omitempty"`
+}
+
+// gitSyncer fetches the branch of the watched repo into a bare repo, and
+// gives the spec of every new revision to the server to reconcile with
+type gitSyncer struct {
+	repo     string
+	branch   string
+	file     string
+	interval time.Duration
+	// bare repo the branch is fetched into
+	dir string
+	// triggers a sync before the interval elapses (e.g. on a push webhook)
+	trigger chan struct{}
+
+	lock   sync.Mutex
+	status GitSyncStatus
+	// true if the spec of the last fetched revision failed to apply,
+	// so that it's applied again on the next sync
+	failed bool
+}
+
+func newGitSyncer(cfg Config) *gitSyncer {
+	g := &gitSyncer{
+		repo:     cfg.GitSyncRepo,
+		branch:   cfg.GitSyncBranch,
+		file:     cfg.GitSyncFile,
+		interval: cfg.GitSyncInterval,
+		trigger:  make(chan struct{}, 1),
+	}
+	if g.branch == "" {
+		g.branch = defaultGitSyncBranch
+	}
+	if g.file == "" {
+		g.file = defaultGitSyncFile
+	}
+	if g.interval == 0 {
+		g.interval = defaultGitSyncInterval
+	}
+	g.status = GitSyncStatus{Repo: g.repo, Branch: g.branch, File: g.file}
+	return g
+}
+
+func (g *gitSyncer) getStatus() GitSyncStatus {
+	g.lock.Lock()
+	defer g.lock.Unlock()
+
+	return g.status
+}
+
+// Requests a sync, unless one is requested already
+func (g *gitSyncer) requestSync() {
+	select {
+	case g.trigger <- struct{}{}:
+	default:
+	}
+}
+
+// Runs [args] as a git command on the bare repo of [g]
+func (g *gitSyncer) git(ctx context.Context, args ...string) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(ctx, gitCommandTimeout)
+	defer cancel()
+	stderr := &bytes.Buffer{}
+	cmd := exec.CommandContext(ctx, "git", append([]string{"--git-dir", g.dir}, args...)...)
+	cmd.Stderr = stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("git %s: %w: %s", args[0], err, strings.TrimSpace(stderr.String()))
+	}
+	return out, nil
+}
+
+// Fetches the branch, and returns its revision and spec file
+func (g *gitSyncer) fetch(ctx context.Context) (string, []byte, error) {
+	if _, err := g.git(ctx, "fetch", "--quiet", "--depth", "1", g.repo, "refs/heads/"+g.branch); err != nil {
+		return "", nil, err
+	}
+	out, err := g.git(ctx, "rev-parse", "FETCH_HEAD")
+	if err != nil {
+		return "", nil, err
+	}
+	revision := strings.TrimSpace(string(out))
+	specBytes, err := g.git(ctx, "show", revision+":"+g.file)
+	if err != nil {
+		return "", nil, err
+	}
+	return revision, specBytes, nil
+}
+
+// Syncs the network with the spec of the watched repo every
+// [s.gitSync.interval], or when requested, until [ctx] is done
+func (s *server) runGitSync(ctx context.Context) {
+	g := s.gitSync
+	dir, err := os.MkdirTemp("", "netrunner-gitsync-")
+	if err != nil {
+		s.log.Error("couldn't create git sync dir", zap.Error(err))
+		return
+	}
+	defer os.RemoveAll(dir)
+	g.dir = filepath.Join(dir, "repo.git")
+	if out, err := exec.CommandContext(ctx, "git", "init", "--quiet", "--bare", g.dir).CombinedOutput(); err != nil {
+		s.log.Error("couldn't init git sync repo", zap.Error(err), zap.String("output", string(out)))
+		return
+	}
+	s.log.Info("syncing network with git repo",
+		zap.String("repo", g.repo),
+		zap.String("branch", g.branch),
+		zap.String("file", g.file),
+		zap.Duration("interval", g.interval),
+	)
+
+	ticker := time.NewTicker(g.interval)
+	defer ticker.Stop()
+	for {
+		s.gitSyncOnce(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-g.trigger:
+		}
+	}
+}
+
+// Fetches the spec of the watched repo, and reconciles the
+// network with it if its revision is new or failed to apply
+func (s *server) gitSyncOnce(ctx context.Context) {
+	g := s.gitSync
+	revision, specBytes, err := g.fetch(ctx)
+	if err == nil {
+		g.lock.Lock()
+		unchanged := revision == g.status.Revision && !g.failed
+		g.lock.Unlock()
+		if unchanged {
+			return
+		}
+		s.log.Info("applying git sync spec", zap.String("revision", revision))
+		// the network is synced as the default tenant
+		err = s.applyGitSyncSpec(context.WithValue(ctx, tenantCtxKey{}, ""), specBytes)
+	}
+
+	now := time.Now()
+	g.lock.Lock()
+	defer g.lock.Unlock()
+
+	g.status.LastSync = &now
+	g.status.LastError = ""
+	if err != nil {
+		s.log.Warn("couldn't sync network with git repo", zap.String("revision", revision), zap.Error(err))
+		g.status.LastError = err.Error()
+	}
+	if revision != "" {
+		g.status.Revision = revision
+		g.failed = err != nil
+	}
+}
+
+// Reconciles the network with [specBytes]: starts it if none is running,
+// adds and removes nodes, and restarts the nodes whose chain configs
+// differ from the spec ones
+func (s *server) applyGitSyncSpec(ctx context.Context, specBytes []byte) error {
+	spec := &GitSyncSpec{}
+	if err := json.Unmarshal(specBytes, spec); err != nil {
+		return fmt.Errorf("%w: %s", ErrInvalidGitSyncSpec, err)
+	}
+	if err := spec.validate(); err != nil {
+		return err
+	}
+
+	s.resumeNetwork("", "")
+	s.mu.RLock()
+	running := s.network != nil
+	s.mu.RUnlock()
+	if !running {
+		if _, err := s.Start(ctx, spec.Start); err != nil {
+			return fmt.Errorf("couldn't start network: %w", err)
+		}
+	}
+
+	toAdd, toRemove, toRestart, err := s.diffGitSyncSpec(spec)
+	if err != nil {
+		return err
+	}
+	for _, name := range toRemove {
+		s.log.Info("git sync removing node", zap.String("node", name))
+		if _, err := s.RemoveNode(ctx, &rpcpb.RemoveNodeRequest{Name: name}); err != nil {
+			return fmt.Errorf("couldn't remove node %q: %w", name, err)
+		}
+	}
+	for _, name := range toAdd {
+		s.log.Info("git sync adding node", zap.String("node", name))
+		nodeConfig := spec.Nodes[name]
+		req := &rpcpb.AddNodeRequest{
+			Name:         name,
+			ExecPath:     spec.Start.GetExecPath(),
+			NodeConfig:   &nodeConfig,
+			ChainConfigs: spec.Start.GetChainConfigs(),
+			PluginDir:    spec.Start.GetPluginDir(),
+		}
+		if _, err := s.AddNode(ctx, req); err != nil {
+			return fmt.Errorf("couldn't add node %q: %w", name, err)
+		}
+	}
+	for _, name := range toRestart {
+		s.log.Info("git sync restarting node with new chain configs", zap.String("node", name))
+		req := &rpcpb.RestartNodeRequest{
+			Name:         name,
+			ChainConfigs: spec.Start.GetChainConfigs(),
+		}
+		if _, err := s.RestartNode(ctx, req); err != nil {
+			return fmt.Errorf("couldn't restart node %q: %w", name, err)
+		}
+	}
+	return nil
+}
+
+// Returns the sorted names of the nodes to add to and remove from the
+// network to match [spec], and of the nodes to restart with its chain configs
+func (s *server) diffGitSyncSpec(spec *GitSyncSpec) ([]string, []string, []string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.network == nil {
+		return nil, nil, nil, ErrNotBootstrapped
+	}
+	nodes, err := s.network.nw.GetAllNodes()
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	toAdd, toRemove, toRestart := []string{}, []string{}, []string{}
+	if len(spec.Nodes) != 0 {
+		for name := range spec.Nodes {
+			if _, ok := nodes[name]; !ok {
+				toAdd = append(toAdd, name)
+			}
+		}
+		for name := range nodes {
+			if _, ok := spec.Nodes[name]; !ok {
+				toRemove = append(toRemove, name)
+			}
+		}
+	}
+	for name, node := range nodes {
+		if _, ok := spec.Nodes[name]; !ok && len(spec.Nodes) != 0 {
+			continue
+		}
+		chainConfigFiles := node.GetConfig().ChainConfigFiles
+		for chainAlias, chainConfig := range spec.Start.GetChainConfigs() {
+			if chainConfigFiles[chainAlias] != chainConfig {
+				toRestart = append(toRestart, name)
+				break
+			}
+		}
+	}
+	sort.Strings(toAdd)
+	sort.Strings(toRemove)
+	sort.Strings(toRestart)
+	return toAdd, toRemove, toRestart, nil
+}
+
+// GET returns the git sync status, POST syncs the network with the
+// watched repo now (e.g. from a push webhook)
+func (s *server) handleGitSync(w http.ResponseWriter, r *http.Request) {
+	if !s.authenticateHTTP(w, r) {
+		return
+	}
+	if s.gitSync == nil {
+		http.Error(w, "git sync not enabled", http.StatusNotFound)
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPost:
+		s.gitSync.requestSync()
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	writeJSON(w, s.gitSync.getStatus())
+}
@@ -0,0 +1,192 @@
+// Copyright (C) 2021-2024, Lux Partners Limited. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/luxdefi/netrunner/network/node"
+	"github.com/luxdefi/netrunner/rpcpb"
+	"github.com/stretchr/testify/require"
+)
+
+// gitTestRepo is a bare repo, the watched one, pushed to from a clone
+type gitTestRepo struct {
+	t        *testing.T
+	bareDir  string
+	cloneDir string
+}
+
+func newGitTestRepo(t *testing.T) *gitTestRepo {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not found")
+	}
+	dir := t.TempDir()
+	r := &gitTestRepo{
+		t:        t,
+		bareDir:  filepath.Join(dir, "origin.git"),
+		cloneDir: filepath.Join(dir, "clone"),
+	}
+	r.git(dir, "init", "--quiet", "--bare", r.bareDir)
+	r.git(dir, "init", "--quiet", r.cloneDir)
+	return r
+}
+
+func (r *gitTestRepo) git(dir string, args ...string) string {
+	args = append([]string{"-C", dir, "-c", "user.name=test", "-c", "user.email=test@example.com"}, args...)
+	out, err := exec.Command("git", args...).CombinedOutput()
+	require.NoError(r.t, err, string(out))
+	return strings.TrimSpace(string(out))
+}
+
+// Commits [spec] as the spec file on the main branch of the
+// bare repo, and returns the revision
+func (r *gitTestRepo) push(spec interface{}) string {
+	b, err := json.Marshal(spec)
+	require.NoError(r.t, err)
+	require.NoError(r.t, os.WriteFile(filepath.Join(r.cloneDir, defaultGitSyncFile), b, 0o600))
+	r.git(r.cloneDir, "add", defaultGitSyncFile)
+	r.git(r.cloneDir, "commit", "--quiet", "--allow-empty", "-m", "spec")
+	r.git(r.cloneDir, "push", "--quiet", r.bareDir, "HEAD:refs/heads/"+defaultGitSyncBranch)
+	return r.git(r.cloneDir, "rev-parse", "HEAD")
+}
+
+// Returns a server serving a network of [nodes], by name, with the
+// chain configs given, syncing with [repo] into a new bare repo
+func newGitSyncTestServer(t *testing.T, repo *gitTestRepo, nodes map[string]map[string]string) *server {
+	s := newTestServer(t, Config{GitSyncRepo: repo.bareDir})
+	nw := newFakeNetwork()
+	for name, chainConfigs := range nodes {
+		nw.nodes[name] = &fakeNode{config: node.Config{Name: name, ChainConfigFiles: chainConfigs}}
+	}
+	setTestNetwork(s, nw, "")
+	s.gitSync = newGitSyncer(s.cfg)
+	s.gitSync.dir = filepath.Join(t.TempDir(), "repo.git")
+	repo.git(t.TempDir(), "init", "--quiet", "--bare", s.gitSync.dir)
+	return s
+}
+
+// TestGitSyncDiff checks the nodes added, removed and restarted to
+// reconcile the network with the specs pushed to the watched repo
+func TestGitSyncDiff(t *testing.T) {
+	repo := newGitTestRepo(t)
+	s := newGitSyncTestServer(t, repo, map[string]map[string]string{
+		"node1": {"C": `{"log-level":"info"}`},
+		"node2": {"C": `{"log-level":"debug"}`},
+		"node3": {"C": `{"log-level":"info"}`},
+	})
+
+	tests := []struct {
+		name              string
+		spec              *GitSyncSpec
+		expectedToAdd     []string
+		expectedToRemove  []string
+		expectedToRestart []string
+	}{
+		{
+			name: "no nodes given",
+			spec: &GitSyncSpec{Start: &rpcpb.StartRequest{
+				ChainConfigs: map[string]string{"C": `{"log-level":"info"}`},
+			}},
+			expectedToAdd:     []string{},
+			expectedToRemove:  []string{},
+			expectedToRestart: []string{"node2"},
+		},
+		{
+			name: "nodes added and removed",
+			spec: &GitSyncSpec{
+				Start: &rpcpb.StartRequest{},
+				Nodes: map[string]string{"node1": "{}", "node2": "{}", "node4": "{}", "node5": "{}"},
+			},
+			expectedToAdd:     []string{"node4", "node5"},
+			expectedToRemove:  []string{"node3"},
+			expectedToRestart: []string{},
+		},
+		{
+			name: "removed nodes not restarted",
+			spec: &GitSyncSpec{
+				Start: &rpcpb.StartRequest{
+					ChainConfigs: map[string]string{"C": `{"log-level":"trace"}`},
+				},
+				Nodes: map[string]string{"node1": "{}", "node4": "{}"},
+			},
+			expectedToAdd:     []string{"node4"},
+			expectedToRemove:  []string{"node2", "node3"},
+			expectedToRestart: []string{"node1"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require := require.New(t)
+			expectedRevision := repo.push(tt.spec)
+			revision, specBytes, err := s.gitSync.fetch(context.Background())
+			require.NoError(err)
+			require.Equal(expectedRevision, revision)
+
+			spec := &GitSyncSpec{}
+			require.NoError(json.Unmarshal(specBytes, spec))
+			require.NoError(spec.validate())
+			toAdd, toRemove, toRestart, err := s.diffGitSyncSpec(spec)
+			require.NoError(err)
+			require.Equal(tt.expectedToAdd, toAdd)
+			require.Equal(tt.expectedToRemove, toRemove)
+			require.Equal(tt.expectedToRestart, toRestart)
+		})
+	}
+}
+
+// TestGitSyncOnce checks that a revision is applied once, unless its
+// spec fails to apply, in which case it's applied again on the next sync
+func TestGitSyncOnce(t *testing.T) {
+	require := require.New(t)
+	repo := newGitTestRepo(t)
+	s := newGitSyncTestServer(t, repo, map[string]map[string]string{
+		"node1": {},
+	})
+	ctx := context.Background()
+
+	// a spec the network already matches
+	revision := repo.push(&GitSyncSpec{
+		Start: &rpcpb.StartRequest{},
+		Nodes: map[string]string{"node1": "{}"},
+	})
+	s.gitSyncOnce(ctx)
+	status := s.gitSync.getStatus()
+	require.Equal(revision, status.Revision)
+	require.Empty(status.LastError)
+	require.NotNil(status.LastSync)
+	require.False(s.gitSync.failed)
+
+	// not applied again
+	s.gitSyncOnce(ctx)
+	require.Same(status.LastSync, s.gitSync.getStatus().LastSync)
+
+	// a spec that fails to apply
+	revision = repo.push(&GitSyncSpec{})
+	s.gitSyncOnce(ctx)
+	status = s.gitSync.getStatus()
+	require.Equal(revision, status.Revision)
+	require.Contains(status.LastError, ErrInvalidGitSyncSpec.Error())
+	require.True(s.gitSync.failed)
+
+	// applied again, though its revision is the same
+	lastSync := status.LastSync
+	s.gitSyncOnce(ctx)
+	status = s.gitSync.getStatus()
+	require.NotSame(lastSync, status.LastSync)
+	require.Contains(status.LastError, ErrInvalidGitSyncSpec.Error())
+
+	// a fetch failure keeps the last revision
+	s.gitSync.repo = filepath.Join(t.TempDir(), "missing.git")
+	s.gitSyncOnce(ctx)
+	status = s.gitSync.getStatus()
+	require.Equal(revision, status.Revision)
+	require.NotEmpty(status.LastError)
+}
@@ -0,0 +1,156 @@
+// Copyright (C) 2021-2024, Lux Partners Limited. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/luxdefi/netrunner/rpcpb"
+	"github.com/luxdefi/netrunner/utils"
+	"go.uber.org/zap"
+	"golang.org/x/exp/maps"
+)
+
+// Operations applicable to the nodes of a group
+const (
+	GroupOpRestart  = "restart"
+	GroupOpPause    = "pause"
+	GroupOpResume   = "resume"
+	GroupOpFreeze   = "freeze"
+	GroupOpUnfreeze = "unfreeze"
+	GroupOpRemove   = "remove"
+)
+
+var ErrInvalidGroupOperation = errors.New("invalid group operation")
+
+func (s *server) SetNodeGroup(_ context.Context, req *rpcpb.SetNodeGroupRequest) (*rpcpb.SetNodeGroupResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.log.Debug("SetNodeGroup", zap.String("group", req.Name), zap.Strings("nodes", req.NodeNames))
+
+	if s.network == nil {
+		return nil, ErrNotBootstrapped
+	}
+	if err := s.network.nw.SetNodeGroup(req.Name, req.NodeNames); err != nil {
+		return nil, err
+	}
+	nodeNames, err := s.network.nw.GetNodeGroup(req.Name)
+	if err != nil {
+		return nil, err
+	}
+	return &rpcpb.SetNodeGroupResponse{Group: &rpcpb.NodeGroup{Name: req.Name, NodeNames: nodeNames}}, nil
+}
+
+func (s *server) RemoveNodeGroup(_ context.Context, req *rpcpb.RemoveNodeGroupRequest) (*rpcpb.RemoveNodeGroupResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.log.Debug("RemoveNodeGroup", zap.String("group", req.Name))
+
+	if s.network == nil {
+		return nil, ErrNotBootstrapped
+	}
+	if err := s.network.nw.RemoveNodeGroup(req.Name); err != nil {
+		return nil, err
+	}
+	return &rpcpb.RemoveNodeGroupResponse{}, nil
+}
+
+func (s *server) GetNodeGroups(context.Context, *rpcpb.GetNodeGroupsRequest) (*rpcpb.GetNodeGroupsResponse, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	s.log.Debug("GetNodeGroups")
+
+	if s.network == nil {
+		return nil, ErrNotBootstrapped
+	}
+	groups, err := s.network.nw.GetNodeGroups()
+	if err != nil {
+		return nil, err
+	}
+	groupNames := maps.Keys(groups)
+	sort.Strings(groupNames)
+	resp := &rpcpb.GetNodeGroupsResponse{}
+	for _, groupName := range groupNames {
+		resp.Groups = append(resp.Groups, &rpcpb.NodeGroup{Name: groupName, NodeNames: groups[groupName]})
+	}
+	return resp, nil
+}
+
+// Applies the operation to the nodes of the group, one at a time by name,
+// stopping at the first failure
+func (s *server) GroupOperation(ctx context.Context, req *rpcpb.GroupOperationRequest) (*rpcpb.GroupOperationResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.log.Debug("GroupOperation", zap.String("group", req.Group), zap.String("operation", req.Operation))
+
+	if s.network == nil {
+		return nil, ErrNotBootstrapped
+	}
+	op, err := s.groupOperation(req.Operation)
+	if err != nil {
+		return nil, err
+	}
+	nodeNames, err := s.network.nw.GetNodeGroup(req.Group)
+	if err != nil {
+		return nil, err
+	}
+	for i, nodeName := range nodeNames {
+		if err := op(ctx, nodeName); err != nil {
+			// the nodes before it were changed, so the cluster info is updated anyway
+			_ = s.network.UpdateNodeInfo()
+			return nil, fmt.Errorf("%s of group %q stopped at node %q, after nodes %v: %w",
+				req.Operation, req.Group, nodeName, nodeNames[:i], err)
+		}
+		s.log.Info("applied group operation",
+			utils.NodeField(nodeName),
+			zap.String("group", req.Group),
+			zap.String("operation", req.Operation),
+		)
+	}
+
+	if err := s.network.UpdateNodeInfo(); err != nil {
+		return nil, err
+	}
+
+	s.clusterInfo.NodeNames = maps.Keys(s.network.nodeInfos)
+	sort.Strings(s.clusterInfo.NodeNames)
+	s.clusterInfo.NodeInfos = s.network.nodeInfos
+
+	clusterInfo, err := deepCopy(s.clusterInfo)
+	if err != nil {
+		return nil, err
+	}
+	return &rpcpb.GroupOperationResponse{ClusterInfo: clusterInfo, NodeNames: nodeNames}, nil
+}
+
+// Returns the function applying [operation] to a node.
+// Assumes [s.mu] is held.
+func (s *server) groupOperation(operation string) (func(ctx context.Context, nodeName string) error, error) {
+	nw := s.network.nw
+	switch operation {
+	case GroupOpRestart:
+		return func(ctx context.Context, nodeName string) error {
+			return nw.RestartNode(ctx, nodeName, "", "", "", nil, nil, nil)
+		}, nil
+	case GroupOpPause:
+		return nw.PauseNode, nil
+	case GroupOpResume:
+		return nw.ResumeNode, nil
+	case GroupOpFreeze:
+		return nw.FreezeNode, nil
+	case GroupOpUnfreeze:
+		return nw.UnfreezeNode, nil
+	case GroupOpRemove:
+		return nw.RemoveNode, nil
+	default:
+		return nil, fmt.Errorf("%w %q", ErrInvalidGroupOperation, operation)
+	}
+}
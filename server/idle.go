@@ -0,0 +1,272 @@
+// Copyright (C) 2021-2024, Lux Partners Limited. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package server
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/luxdefi/netrunner/rpcpb"
+	"github.com/shirou/gopsutil/process"
+	"go.uber.org/zap"
+)
+
+const (
+	// how often the network is checked for idleness
+	idleCheckInterval = 10 * time.Second
+	// node CPU usage, over all the nodes, below which the network is idle
+	defaultIdleMaxCPUPercent = 5
+	// prefix of the snapshots of the suspended networks
+	idleSnapshotPrefix = "idle-"
+)
+
+// IdleAction is what the server does with a network found idle
+type IdleAction string
+
+const (
+	// Freezes the node processes (SIGSTOP), keeping them in memory
+	IdleFreeze IdleAction = "freeze"
+	// Saves a snapshot of the network, which stops it
+	IdleSnapshot IdleAction = "snapshot"
+)
+
+// idleTracker records the last activity on the network
+type idleTracker struct {
+	lock         sync.Mutex
+	lastActivity time.Time
+
+	// network whose node CPU time was last measured, the total
+	// CPU time of its nodes in seconds, and when it was measured.
+	// Only accessed by the idle monitor.
+	cpuNetwork   *localNetwork
+	cpuSeconds   float64
+	cpuCheckedAt time.Time
+}
+
+func (t *idleTracker) markActive() {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	t.lastActivity = time.Now()
+}
+
+func (t *idleTracker) idleFor() time.Duration {
+	t.lock.Lock()
+	defer t.lock.Unlock()
+
+	return time.Since(t.lastActivity)
+}
+
+// suspension is the state of a network suspended while idle
+type suspension struct {
+	action IdleAction
+	// nodes frozen by the suspension, if [IdleFreeze]
+	frozenNodes []string
+	// snapshot of the network and the tenant that owned it, if [IdleSnapshot]
+	snapshotName string
+	tenant       string
+}
+
+// Periodically suspends the network once it had no control call,
+// API traffic or node CPU load within [s.cfg.IdleTimeout], until [ctx] is done
+func (s *server) runIdleMonitor(ctx context.Context) {
+	ticker := time.NewTicker(idleCheckInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		s.checkIdle()
+	}
+}
+
+// Suspends the network if it is idle
+func (s *server) checkIdle() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.network == nil || s.network.nw == nil || s.suspended != nil {
+		return
+	}
+	if s.nodesBusy() {
+		s.idle.markActive()
+	}
+	idleFor := s.idle.idleFor()
+	if idleFor < s.cfg.IdleTimeout {
+		return
+	}
+	s.log.Info("network idle, suspending it",
+		zap.Duration("idle-for", idleFor.Truncate(time.Second)),
+		zap.String("action", string(s.cfg.IdleAction)),
+	)
+	s.suspendNetwork()
+}
+
+// Returns true if the node processes used more CPU than
+// [s.cfg.IdleMaxCPUPercent] since the last call.
+// Assumes [s.mu] is held.
+func (s *server) nodesBusy() bool {
+	nodes, err := s.network.nw.GetAllNodes()
+	if err != nil {
+		return false
+	}
+	cpuSeconds := 0.0
+	for _, n := range nodes {
+		pid := n.GetRuntimeInfo().PID
+		if pid == 0 {
+			continue
+		}
+		proc, err := process.NewProcess(int32(pid))
+		if err != nil {
+			continue
+		}
+		times, err := proc.Times()
+		if err != nil {
+			continue
+		}
+		cpuSeconds += times.User + times.System
+	}
+	now := time.Now()
+	prevNetwork, prevSeconds, prevCheckedAt := s.idle.cpuNetwork, s.idle.cpuSeconds, s.idle.cpuCheckedAt
+	s.idle.cpuNetwork, s.idle.cpuSeconds, s.idle.cpuCheckedAt = s.network, cpuSeconds, now
+	if prevNetwork != s.network {
+		return false
+	}
+	elapsed := now.Sub(prevCheckedAt).Seconds()
+	if elapsed <= 0 {
+		return false
+	}
+	// restarted nodes start over from zero, which is not load
+	used := cpuSeconds - prevSeconds
+	return used > 0 && used/elapsed*100 > s.cfg.IdleMaxCPUPercent
+}
+
+// Suspends the network as per [s.cfg.IdleAction]. On failure, the
+// network is left running, unless its snapshot couldn't be saved.
+// Assumes [s.mu] is held.
+func (s *server) suspendNetwork() {
+	switch s.cfg.IdleAction {
+	case IdleSnapshot:
+		tenant := s.networkTenant
+		snapshotName := s.saveTimestampedSnapshot(idleSnapshotPrefix)
+		s.stopAndRemoveNetwork(nil)
+		if snapshotName == "" {
+			return
+		}
+		s.suspended = &suspension{
+			action:       IdleSnapshot,
+			snapshotName: snapshotName,
+			tenant:       tenant,
+		}
+	default:
+		ctx, cancel := context.WithTimeout(s.rootCtx, stopTimeout)
+		defer cancel()
+		nodes, err := s.network.nw.GetAllNodes()
+		if err != nil {
+			s.log.Warn("couldn't freeze idle network", zap.Error(err))
+			return
+		}
+		frozen := []string{}
+		for name, n := range nodes {
+			if n.GetPaused() {
+				continue
+			}
+			if err := s.network.nw.FreezeNode(ctx, name); err != nil {
+				s.log.Warn("couldn't freeze idle network", zap.Error(err))
+				s.unfreezeNodes(frozen)
+				return
+			}
+			frozen = append(frozen, name)
+		}
+		s.suspended = &suspension{
+			action:      IdleFreeze,
+			frozenNodes: frozen,
+		}
+	}
+	s.log.Info("network suspended")
+}
+
+// Unfreezes [names], logging failures.
+// Assumes [s.mu] is held.
+func (s *server) unfreezeNodes(names []string) {
+	ctx, cancel := context.WithTimeout(s.rootCtx, stopTimeout)
+	defer cancel()
+	for _, name := range names {
+		if err := s.network.nw.UnfreezeNode(ctx, name); err != nil {
+			s.log.Warn("couldn't unfreeze node", zap.String("node", name), zap.Error(err))
+		}
+	}
+}
+
+// Marks the network active and resumes it if it is suspended, ahead of
+// the call of [fullMethod] by [tenant]. A network suspended with a
+// snapshot is only resumed for the tenant that owned it, and is dropped
+// if another network was created in the meantime. It's not resumed for
+// the calls creating a network, which would find it running, nor for
+// Stop, which would stop it right away: it's dropped instead, and kept
+// as its snapshot.
+func (s *server) resumeNetwork(tenant string, fullMethod string) {
+	if s.cfg.IdleTimeout <= 0 {
+		return
+	}
+	s.idle.markActive()
+
+	// serializes the resumes, so that a request isn't
+	// served before the network it resumes is loaded
+	s.resumeLock.Lock()
+	defer s.resumeLock.Unlock()
+
+	s.mu.Lock()
+	sus := s.suspended
+	if sus == nil || (sus.action == IdleSnapshot && sus.tenant != tenant) {
+		s.mu.Unlock()
+		return
+	}
+	_, createsNetwork := networkCreationMethods[fullMethod]
+	if fullMethod == stopMethod || (sus.action == IdleSnapshot && createsNetwork) {
+		// a frozen network is still there, and unfrozen by its stop
+		s.suspended = nil
+		s.mu.Unlock()
+		s.log.Info("dropping suspended network",
+			zap.String("method", operationName(fullMethod)),
+			zap.String("snapshot-name", sus.snapshotName),
+		)
+		return
+	}
+	s.suspended = nil
+	if sus.action == IdleFreeze {
+		defer s.mu.Unlock()
+		s.log.Info("resuming network, unfreezing its nodes")
+		s.unfreezeNodes(sus.frozenNodes)
+		return
+	}
+	s.mu.Unlock()
+
+	s.log.Info("resuming network from its snapshot", zap.String("snapshot-name", sus.snapshotName))
+	ctx := context.WithValue(s.rootCtx, tenantCtxKey{}, tenant)
+	if _, err := s.LoadSnapshot(ctx, &rpcpb.LoadSnapshotRequest{SnapshotName: sus.snapshotName}); err != nil {
+		s.log.Warn("couldn't resume network", zap.String("snapshot-name", sus.snapshotName), zap.Error(err))
+	}
+}
+
+// Wraps [h] so that its requests mark the network active and resume it
+func (s *server) newIdleHandler(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tenant := ""
+		if s.tenancyEnabled() {
+			var ok bool
+			// [h] rejects the request
+			if tenant, ok = s.tenantFromAuthorization(r.Header.Get("Authorization")); !ok {
+				h.ServeHTTP(w, r)
+				return
+			}
+		}
+		s.resumeNetwork(tenant, gatewayFullMethod(r.URL.Path))
+		h.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,125 @@
+// Copyright (C) 2021-2024, Lux Partners Limited. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package server
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+const statusMethod = "/rpcpb.ControlService/Status"
+
+func TestIdleFreeze(t *testing.T) {
+	tests := []struct {
+		name       string
+		method     string
+		wantPaused bool
+	}{
+		{
+			name:       "resumed",
+			method:     statusMethod,
+			wantPaused: false,
+		},
+		{
+			name:       "dropped on stop",
+			method:     stopMethod,
+			wantPaused: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require := require.New(t)
+
+			s := newTestServer(t, Config{IdleTimeout: time.Minute, IdleAction: IdleFreeze})
+			nw := newFakeNetwork("node1", "node2")
+			nw.nodes["node2"].paused = true
+			setTestNetwork(s, nw, "")
+
+			s.mu.Lock()
+			s.suspendNetwork()
+			s.mu.Unlock()
+			require.NotNil(s.suspended)
+			require.Equal([]string{"node1"}, s.suspended.frozenNodes)
+			require.True(nw.nodes["node1"].paused)
+
+			s.resumeNetwork("", tt.method)
+			require.Nil(s.suspended)
+			require.NotNil(s.network)
+			require.Equal(tt.wantPaused, nw.nodes["node1"].paused)
+			// paused before the suspension
+			require.True(nw.nodes["node2"].paused)
+		})
+	}
+}
+
+func TestIdleSnapshot(t *testing.T) {
+	tests := []struct {
+		name          string
+		tenant        string
+		method        string
+		wantSuspended bool
+	}{
+		{
+			name:          "other tenant",
+			tenant:        "bob",
+			method:        statusMethod,
+			wantSuspended: true,
+		},
+		{
+			name:          "dropped on start",
+			tenant:        "alice",
+			method:        "/rpcpb.ControlService/Start",
+			wantSuspended: false,
+		},
+		{
+			name:          "dropped on load snapshot",
+			tenant:        "alice",
+			method:        "/rpcpb.ControlService/LoadSnapshot",
+			wantSuspended: false,
+		},
+		{
+			name:          "dropped on stop",
+			tenant:        "alice",
+			method:        stopMethod,
+			wantSuspended: false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require := require.New(t)
+
+			s := newTestServer(t, Config{IdleTimeout: time.Minute, IdleAction: IdleSnapshot})
+			nw := newFakeNetwork("node1")
+			setTestNetwork(s, nw, "alice")
+
+			s.mu.Lock()
+			s.suspendNetwork()
+			s.mu.Unlock()
+			require.Len(nw.snapshots, 1)
+			require.True(strings.HasPrefix(nw.snapshots[0], idleSnapshotPrefix))
+			require.True(nw.stopped)
+			require.Nil(s.network)
+			require.NotNil(s.suspended)
+			require.Equal(nw.snapshots[0], s.suspended.snapshotName)
+			require.Equal("alice", s.suspended.tenant)
+
+			s.resumeNetwork(tt.tenant, tt.method)
+			require.Equal(tt.wantSuspended, s.suspended != nil)
+			// not loaded from the snapshot
+			require.Nil(s.network)
+		})
+	}
+}
+
+func TestIdleDisabled(t *testing.T) {
+	require := require.New(t)
+
+	s := newTestServer(t, Config{IdleAction: IdleFreeze})
+	s.suspended = &suspension{action: IdleFreeze}
+	s.resumeNetwork("", statusMethod)
+	require.NotNil(s.suspended)
+}
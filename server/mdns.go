@@ -0,0 +1,57 @@
+// Copyright (C) 2021-2024, Lux Partners Limited. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package server
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+
+	"github.com/grandcat/zeroconf"
+	"github.com/luxdefi/netrunner/utils/constants"
+)
+
+// Advertises the gRPC endpoint of the server over mDNS.
+// Returns the function that stops advertising it.
+func (s *server) advertiseMDNS() (func(), error) {
+	port, err := portNumber(s.cfg.Port)
+	if err != nil {
+		return nil, err
+	}
+	txt := []string{
+		fmt.Sprintf("%s=%d", constants.MDNSRPCVersionKey, RPCVersion),
+		fmt.Sprintf("%s=%t", constants.MDNSTenancyKey, s.tenancyEnabled()),
+	}
+	if !s.cfg.GwDisabled {
+		gwPort, err := portNumber(s.cfg.GwPort)
+		if err != nil {
+			return nil, err
+		}
+		txt = append(txt, fmt.Sprintf("%s=%d", constants.MDNSGatewayPortKey, gwPort))
+	}
+	hostname, err := os.Hostname()
+	if err != nil {
+		hostname = "netrunner"
+	}
+	instance := fmt.Sprintf("netrunner-%s-%d", hostname, port)
+	mdnsServer, err := zeroconf.Register(instance, constants.MDNSService, constants.MDNSDomain, port, txt, nil)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't advertise server over mDNS: %w", err)
+	}
+	return mdnsServer.Shutdown, nil
+}
+
+// Returns the port number of a listen address such as ":8080"
+func portNumber(addr string) (int, error) {
+	_, portStr, err := net.SplitHostPort(addr)
+	if err != nil {
+		return 0, fmt.Errorf("invalid address %q: %w", addr, err)
+	}
+	port, err := strconv.Atoi(portStr)
+	if err != nil {
+		return 0, fmt.Errorf("invalid port in address %q: %w", addr, err)
+	}
+	return port, nil
+}
@@ -0,0 +1,73 @@
+// Copyright (C) 2021-2024, Lux Partners Limited. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package server
+
+import (
+	"context"
+	"net/http"
+	"sort"
+
+	"github.com/luxdefi/netrunner/metrics"
+	"github.com/luxdefi/netrunner/network/node"
+	"go.uber.org/zap"
+)
+
+const metricsPath = "/metrics"
+
+// Returns the metrics endpoints of the running nodes of the network,
+// labeled as in the generated prometheus conf
+func (s *server) getMetricsTargets() ([]metrics.Target, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.network == nil {
+		return nil, ErrNotBootstrapped
+	}
+	nodes, err := s.network.nw.GetAllNodes()
+	if err != nil {
+		return nil, err
+	}
+	targets := []metrics.Target{}
+	for name, n := range nodes {
+		if n.GetPaused() {
+			continue
+		}
+		labels := n.GetConfig().Labels
+		if s.network.cfg.Name != "" {
+			labels = node.MergeLabels(labels, map[string]string{"network": s.network.cfg.Name})
+		}
+		targets = append(targets, metrics.Target{
+			Node:   name,
+			URL:    n.GetAPIBaseURI() + "/ext/metrics",
+			Labels: labels,
+		})
+	}
+	sort.Slice(targets, func(i, j int) bool {
+		return targets[i].Node < targets[j].Node
+	})
+	return targets, nil
+}
+
+// Serves the node metrics of the last scrape, aggregated
+func (s *server) handleMetrics(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !s.authenticateHTTP(w, r) {
+		return
+	}
+	if s.metricsScraper == nil {
+		http.Error(w, "metrics scraping not enabled", http.StatusNotFound)
+		return
+	}
+	s.metricsScraper.ServeHTTP(w, r)
+}
+
+// Scrapes the node metrics every [s.cfg.MetricsScrapeInterval],
+// until [ctx] is done
+func (s *server) runMetricsScraper(ctx context.Context) {
+	s.log.Info("scraping node metrics", zap.Duration("interval", s.cfg.MetricsScrapeInterval), zap.String("path", metricsPath))
+	s.metricsScraper.Run(ctx, s.cfg.MetricsScrapeInterval)
+}
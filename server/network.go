@@ -10,6 +10,7 @@ import (
 	"os"
 	"path/filepath"
 	"sort"
+	"strconv"
 	"strings"
 	"sync"
 
@@ -18,6 +19,7 @@ import (
 	"github.com/luxdefi/netrunner/network/node"
 	"github.com/luxdefi/netrunner/rpcpb"
 	"github.com/luxdefi/netrunner/utils/constants"
+	"github.com/luxdefi/netrunner/utils/failpoint"
 	"github.com/luxdefi/netrunner/ux"
 	"github.com/luxdefi/node/config"
 	"github.com/luxdefi/node/ids"
@@ -45,7 +47,6 @@ scrape_configs:
   - job_name: node
     metrics_path: /ext/metrics
     static_configs:
-      - targets:
 `
 )
 
@@ -61,6 +62,8 @@ type localNetwork struct {
 	nw network.Network
 
 	nodeInfos map[string]*rpcpb.NodeInfo
+	// labels of the network, as kept in its root dir metadata
+	labels map[string]string
 
 	options localNetworkOptions
 
@@ -74,6 +77,7 @@ type localNetwork struct {
 	subnets map[string]*rpcpb.SubnetInfo
 
 	prometheusConfPath string
+	portMapPath        string
 }
 
 type chainInfo struct {
@@ -107,6 +111,20 @@ type localNetworkOptions struct {
 	reassignPortsIfUsed bool
 
 	dynamicPorts bool
+
+	// max bytes used on disk by the network, 0 if unlimited
+	diskBudget uint64
+
+	// labels of the network
+	labels map[string]string
+
+	// if not empty, base dirs of the node dbs, logs and staking files
+	dbRootDir   string
+	logsRootDir string
+	keysRootDir string
+
+	// if not empty, executable run whenever the port map changes
+	portMapHook string
 }
 
 func newLocalNetwork(opts localNetworkOptions) (*localNetwork, error) {
@@ -150,6 +168,12 @@ func (lc *localNetwork) createConfig() error {
 		}
 	}
 
+	// the topology is not a node flag
+	cfg.Topology, err = network.TakeTopologyConfigKey(globalConfig)
+	if err != nil {
+		return err
+	}
+
 	// set flags applied to all nodes
 	for k, v := range globalConfig {
 		cfg.Flags[k] = v
@@ -200,11 +224,46 @@ func (lc *localNetwork) createConfig() error {
 				return err
 			}
 		}
+		// the role, features, public IP, latency and region are not node flags
+		if v, ok := customNodeConfig[node.RoleConfigKey]; ok {
+			role, ok := v.(string)
+			if !ok {
+				return fmt.Errorf("invalid %q value %v of node %q", node.RoleConfigKey, v, nodeName)
+			}
+			cfg.NodeConfigs[i].Role = role
+			cfg.NodeConfigs[i].IsBeacon = false
+			delete(customNodeConfig, node.RoleConfigKey)
+		}
+		if v, ok := customNodeConfig[node.FeaturesConfigKey]; ok {
+			features, err := node.ParseFeatures(v)
+			if err != nil {
+				return fmt.Errorf("invalid %q value %v of node %q: %w", node.FeaturesConfigKey, v, nodeName, err)
+			}
+			cfg.NodeConfigs[i].Features = features
+			delete(customNodeConfig, node.FeaturesConfigKey)
+		}
+		if v, ok := customNodeConfig[node.ResourcesConfigKey]; ok {
+			resources, err := node.ParseResourceLimits(v)
+			if err != nil {
+				return fmt.Errorf("invalid %q value %v of node %q: %w", node.ResourcesConfigKey, v, nodeName, err)
+			}
+			cfg.NodeConfigs[i].Resources = resources
+			delete(customNodeConfig, node.ResourcesConfigKey)
+		}
+		if err := cfg.NodeConfigs[i].TakeLinkConfigKeys(customNodeConfig); err != nil {
+			return fmt.Errorf("node %q: %w", nodeName, err)
+		}
 		for k, v := range customNodeConfig {
 			cfg.NodeConfigs[i].Flags[k] = v
 		}
 	}
 
+	cfg.DiskBudget = lc.options.diskBudget
+	cfg.DBRootDir = lc.options.dbRootDir
+	cfg.LogsRootDir = lc.options.logsRootDir
+	cfg.KeysRootDir = lc.options.keysRootDir
+	cfg.Labels = lc.options.labels
+
 	lc.cfg = cfg
 	return nil
 }
@@ -244,6 +303,7 @@ func (lc *localNetwork) Start(ctx context.Context) error {
 		return err
 	}
 
+	failpoint.Inject(failpoint.BeforeHealthWait)
 	if err := lc.awaitHealthyAndUpdateNetworkInfo(ctx); err != nil {
 		return err
 	}
@@ -626,7 +686,10 @@ func (lc *localNetwork) AwaitHealthyAndUpdateNetworkInfo(ctx context.Context) er
 func (lc *localNetwork) awaitHealthyAndUpdateNetworkInfo(ctx context.Context) error {
 	ux.Print(lc.log, logging.Blue.Wrap(logging.Bold.Wrap("waiting for all nodes to report healthy...")))
 
-	if err := lc.nw.Healthy(ctx); err != nil {
+	err := lc.nw.HealthyWithProgress(ctx, func(progress network.NodeHealthProgress) {
+		lc.log.Info(fmt.Sprintf(logging.LightBlue.Wrap("[health of node %q] %s"), progress.Node, progress))
+	})
+	if err != nil {
 		return err
 	}
 
@@ -668,6 +731,20 @@ func (lc *localNetwork) updateNodeInfo() error {
 		return err
 	}
 
+	// also covers the networks loaded from a snapshot
+	if metadata, err := local.ReadRootDirMetadata(lc.options.rootDataDir); err == nil {
+		lc.labels = metadata.Labels
+	}
+
+	endpoints := map[string][]*rpcpb.NodeEndpoint{}
+	for _, e := range network.NewEndpointCatalog(nodes, lc.customChainNames()).Endpoints {
+		endpoints[e.NodeName] = append(endpoints[e.NodeName], &rpcpb.NodeEndpoint{
+			Chain:    e.Chain,
+			Url:      e.URL,
+			Protocol: e.Protocol,
+		})
+	}
+
 	lc.nodeInfos = make(map[string]*rpcpb.NodeInfo)
 	for name, node := range nodes {
 		trackSubnets, err := node.GetFlag(config.TrackSubnetsKey)
@@ -675,17 +752,23 @@ func (lc *localNetwork) updateNodeInfo() error {
 			return err
 		}
 
+		nodeConfig := node.GetConfig()
 		lc.nodeInfos[name] = &rpcpb.NodeInfo{
 			Name:               node.GetName(),
-			Uri:                fmt.Sprintf("http://%s:%d", node.GetURL(), node.GetAPIPort()),
+			Uri:                node.GetAPIBaseURI(),
 			Id:                 node.GetNodeID().String(),
 			ExecPath:           node.GetBinaryPath(),
 			LogDir:             node.GetLogsDir(),
 			DbDir:              node.GetDbDir(),
 			Config:             []byte(node.GetConfigFile()),
 			PluginDir:          node.GetPluginDir(),
-			WhitelistedSubnets: trackSubnets,
+			WhitelistedSubnets: trackSubnets.String(),
 			Paused:             node.GetPaused(),
+			Timings:            newNodeTimings(node.GetTimings()),
+			Runtime:            newNodeRuntimeInfo(node.GetRuntimeInfo()),
+			Labels:             nodeConfig.Labels,
+			Role:               nodeConfig.Role,
+			Endpoints:          endpoints[name],
 		}
 
 		// update default exec and pluginDir if empty (snapshots started without these params)
@@ -696,18 +779,118 @@ func (lc *localNetwork) updateNodeInfo() error {
 			lc.pluginDir = node.GetPluginDir()
 		}
 	}
+	if err := lc.generatePortMap(nodes); err != nil {
+		return err
+	}
 	return lc.generatePrometheusConf()
 }
 
+// Returns [timings] as reported in the node infos
+func newNodeTimings(timings node.Timings) *rpcpb.NodeTimings {
+	nodeTimings := &rpcpb.NodeTimings{
+		ProcessSpawn: int64(timings.ProcessSpawn),
+		ApiUp:        int64(timings.APIUp),
+		Healthy:      int64(timings.Healthy),
+	}
+	if !timings.StartedAt.IsZero() {
+		nodeTimings.StartedAt = timings.StartedAt.UnixNano()
+	}
+	return nodeTimings
+}
+
+// Returns [info] as reported in the node infos
+func newNodeRuntimeInfo(info node.RuntimeInfo) *rpcpb.NodeRuntimeInfo {
+	runtimeInfo := &rpcpb.NodeRuntimeInfo{
+		Pid:      int32(info.PID),
+		Restarts: info.Restarts,
+	}
+	if !info.StartedAt.IsZero() {
+		runtimeInfo.StartedAt = info.StartedAt.UnixNano()
+	}
+	if info.LastExitCode != nil {
+		lastExitCode := int32(*info.LastExitCode)
+		runtimeInfo.LastExitCode = &lastExitCode
+	}
+	return runtimeInfo
+}
+
+// Returns the catalog of node RPC endpoints, including custom chains.
+// Assumes [lc.lock] isn't held.
+func (lc *localNetwork) GetEndpointCatalog() (network.EndpointCatalog, error) {
+	lc.lock.Lock()
+	defer lc.lock.Unlock()
+
+	if lc.nw == nil {
+		return network.EndpointCatalog{}, ErrNotBootstrapped
+	}
+	nodes, err := lc.nw.GetAllNodes()
+	if err != nil {
+		return network.EndpointCatalog{}, err
+	}
+	return network.NewEndpointCatalog(nodes, lc.customChainNames()), nil
+}
+
+// Returns the custom chain names mapped to their blockchain IDs.
+// Assumes [lc.lock] is held.
+func (lc *localNetwork) customChainNames() map[string]string {
+	customChains := map[string]string{}
+	for chainID, chainInfo := range lc.customChainIDToInfo {
+		customChains[chainInfo.info.ChainName] = chainID.String()
+	}
+	return customChains
+}
+
+// Returns the status of every node of the network, and the network disk usage.
+// Assumes [lc.lock] isn't held.
+func (lc *localNetwork) GetNodeStatuses() ([]network.NodeStatus, network.DiskUsage, error) {
+	lc.lock.Lock()
+	defer lc.lock.Unlock()
+
+	if lc.nw == nil {
+		return nil, network.DiskUsage{}, ErrNotBootstrapped
+	}
+	nodes, err := lc.nw.GetAllNodes()
+	if err != nil {
+		return nil, network.DiskUsage{}, err
+	}
+	diskUsage, err := lc.nw.GetDiskUsage()
+	if err != nil {
+		return nil, network.DiskUsage{}, err
+	}
+	return network.NewNodeStatuses(nodes, diskUsage), diskUsage, nil
+}
+
 func (lc *localNetwork) generatePrometheusConf() error {
 	if lc.prometheusConfPath == "" {
 		lc.prometheusConfPath = filepath.Join(lc.options.rootDataDir, prometheusConfFname)
 		lc.log.Info(fmt.Sprintf(logging.Cyan.Wrap("prometheus conf file %s"), lc.prometheusConfPath))
 	}
 	prometheusConf := prometheusConfCommon
-	for _, nodeInfo := range lc.nodeInfos {
-		if !nodeInfo.Paused {
-			prometheusConf += "        - " + strings.TrimPrefix(nodeInfo.Uri, "http://") + "\n"
+	nodeNames := maps.Keys(lc.nodeInfos)
+	sort.Strings(nodeNames)
+	for _, nodeName := range nodeNames {
+		nodeInfo := lc.nodeInfos[nodeName]
+		if nodeInfo.Paused {
+			continue
+		}
+		prometheusConf += "      - targets:\n"
+		prometheusConf += "        - " + strings.TrimPrefix(nodeInfo.Uri, "http://") + "\n"
+		labels := map[string]string{}
+		if n, err := lc.nw.GetNode(nodeName); err == nil {
+			labels = n.GetConfig().Labels
+		}
+		if lc.cfg.Name != "" {
+			// label node metrics with the network name, to tell apart named networks
+			labels = node.MergeLabels(labels, map[string]string{"network": lc.cfg.Name})
+		}
+		if len(labels) == 0 {
+			continue
+		}
+		prometheusConf += "        labels:\n"
+		labelKeys := maps.Keys(labels)
+		sort.Strings(labelKeys)
+		for _, k := range labelKeys {
+			prometheusConf += "          " + k + ": " + strconv.Quote(labels[k]) + "\n"
 		}
 	}
 	file, err := os.Create(lc.prometheusConfPath)
@@ -0,0 +1,43 @@
+// Copyright (C) 2021-2024, Lux Partners Limited. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package server
+
+import (
+	"net/http"
+
+	"github.com/luxdefi/netrunner/network"
+)
+
+const nodesPath = "/v1/control/nodes"
+
+type nodesResponse struct {
+	Nodes     []network.NodeStatus `json:"nodes"`
+	DiskUsage network.DiskUsage    `json:"diskUsage"`
+}
+
+// GET returns the status of every node of the network, including the
+// settings not part of the rpcpb node info, and the network disk usage
+func (s *server) handleNodes(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !s.authenticateHTTP(w, r) {
+		return
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.network == nil {
+		http.Error(w, ErrNotBootstrapped.Error(), http.StatusServiceUnavailable)
+		return
+	}
+	statuses, diskUsage, err := s.network.GetNodeStatuses()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return
+	}
+	writeJSON(w, nodesResponse{Nodes: statuses, DiskUsage: diskUsage})
+}
@@ -0,0 +1,481 @@
+// Copyright (C) 2021-2024, Lux Partners Limited. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/luxdefi/netrunner/network"
+)
+
+const (
+	openAPIPath   = "/v1/openapi.json"
+	endpointsPath = "/v1/control/endpoints"
+)
+
+// gateway routes as defined in rpcpb/rpc.proto, mapped to the RPC they invoke
+var gatewayRoutes = map[string]string{
+	"/v1/ping":                               "PingService.Ping",
+	"/v1/control/rpcversion":                 "ControlService.RPCVersion",
+	"/v1/control/start":                      "ControlService.Start",
+	"/v1/control/createblockchains":          "ControlService.CreateBlockchains",
+	"/v1/control/transformelasticsubnets":    "ControlService.TransformElasticSubnets",
+	"/v1/control/addpermissionlessvalidator": "ControlService.AddPermissionlessValidator",
+	"/v1/control/removesubnetvalidator":      "ControlService.RemoveSubnetValidator",
+	"/v1/control/createsubnets":              "ControlService.CreateSubnets",
+	"/v1/control/health":                     "ControlService.Health",
+	"/v1/control/uris":                       "ControlService.URIs",
+	"/v1/control/waitforhealthy":             "ControlService.WaitForHealthy",
+	"/v1/control/status":                     "ControlService.Status",
+	"/v1/control/streamstatus":               "ControlService.StreamStatus",
+	"/v1/control/streamlogs":                 "ControlService.StreamLogs",
+	"/v1/control/putfile":                    "ControlService.PutFile",
+	"/v1/control/getfile":                    "ControlService.GetFile",
+	"/v1/control/attachconsole":              "ControlService.AttachConsole",
+	"/v1/control/removenode":                 "ControlService.RemoveNode",
+	"/v1/control/addnode":                    "ControlService.AddNode",
+	"/v1/control/restartnode":                "ControlService.RestartNode",
+	"/v1/control/pausenode":                  "ControlService.PauseNode",
+	"/v1/control/resumenode":                 "ControlService.ResumeNode",
+	"/v1/control/stop":                       "ControlService.Stop",
+	"/v1/control/attachpeer":                 "ControlService.AttachPeer",
+	"/v1/control/sendoutboundmessage":        "ControlService.SendOutboundMessage",
+	"/v1/control/savesnapshot":               "ControlService.SaveSnapshot",
+	"/v1/control/loadsnapshot":               "ControlService.LoadSnapshot",
+	"/v1/control/removesnapshot":             "ControlService.RemoveSnapshot",
+	"/v1/control/getsnapshotnames":           "ControlService.GetSnapshotNames",
+	"/v1/control/setnodegroup":               "ControlService.SetNodeGroup",
+	"/v1/control/removenodegroup":            "ControlService.RemoveNodeGroup",
+	"/v1/control/getnodegroups":              "ControlService.GetNodeGroups",
+	"/v1/control/groupoperation":             "ControlService.GroupOperation",
+	"/v1/control/addprimaryvalidator":        "ControlService.AddPrimaryValidator",
+	"/v1/control/startfromtemplate":          "ControlService.StartFromTemplate",
+	"/v1/control/gc":                         "ControlService.GC",
+}
+
+// Returns the gRPC method invoked by the gateway route [path]
+// (e.g. "/rpcpb.ControlService/Start"), or "" if it's not one
+func gatewayFullMethod(path string) string {
+	rpcName, ok := gatewayRoutes[path]
+	if !ok {
+		return ""
+	}
+	return "/rpcpb." + strings.Replace(rpcName, ".", "/", 1)
+}
+
+// OpenAPISpec returns an OpenAPI 3 stub describing the gRPC gateway routes.
+// Request and response bodies are the JSON mapping of the rpcpb messages
+// named after each operation.
+func OpenAPISpec() ([]byte, error) {
+	paths := map[string]interface{}{}
+	routes := make([]string, 0, len(gatewayRoutes))
+	for route := range gatewayRoutes {
+		routes = append(routes, route)
+	}
+	sort.Strings(routes)
+	for _, route := range routes {
+		rpcName := gatewayRoutes[route]
+		paths[route] = map[string]interface{}{
+			"post": map[string]interface{}{
+				"operationId": rpcName,
+				"requestBody": map[string]interface{}{
+					"content": map[string]interface{}{
+						"application/json": map[string]interface{}{
+							"schema": map[string]interface{}{"type": "object"},
+						},
+					},
+				},
+				"responses": map[string]interface{}{
+					"200": map[string]interface{}{
+						"description": rpcName + " response",
+					},
+				},
+			},
+		}
+	}
+	paths[endpointsPath] = map[string]interface{}{
+		"get": map[string]interface{}{
+			"operationId": "Endpoints",
+			"responses": map[string]interface{}{
+				"200": map[string]interface{}{
+					"description": "catalog of node RPC endpoints reachable in the running network",
+				},
+			},
+		},
+	}
+	paths[templatesPath] = map[string]interface{}{
+		"get": map[string]interface{}{
+			"operationId": "ListTemplates",
+			"responses": map[string]interface{}{
+				"200": map[string]interface{}{
+					"description": "registered network templates",
+				},
+			},
+		},
+		"post": map[string]interface{}{
+			"operationId": "RegisterTemplate",
+			"responses": map[string]interface{}{
+				"200": map[string]interface{}{
+					"description": "registered network template",
+				},
+			},
+		},
+	}
+	paths[gitSyncPath] = map[string]interface{}{
+		"get": map[string]interface{}{
+			"operationId": "GitSyncStatus",
+			"responses": map[string]interface{}{
+				"200": map[string]interface{}{
+					"description": "status of the sync of the network with its git repo",
+				},
+			},
+		},
+		"post": map[string]interface{}{
+			"operationId": "GitSync",
+			"responses": map[string]interface{}{
+				"200": map[string]interface{}{
+					"description": "git sync status, the sync being requested",
+				},
+			},
+		},
+	}
+	paths[metricsPath] = map[string]interface{}{
+		"get": map[string]interface{}{
+			"operationId": "Metrics",
+			"responses": map[string]interface{}{
+				"200": map[string]interface{}{
+					"description": "node metrics in the prometheus text format, labeled by node name",
+				},
+			},
+		},
+	}
+	paths[capabilitiesPath] = map[string]interface{}{
+		"get": map[string]interface{}{
+			"operationId": "BinaryCapabilities",
+			"responses": map[string]interface{}{
+				"200": map[string]interface{}{
+					"description": "capabilities of the node binaries, and whether they are compatible",
+				},
+			},
+		},
+	}
+	paths[capturePath] = map[string]interface{}{
+		"post": map[string]interface{}{
+			"operationId": "Capture",
+			"responses": map[string]interface{}{
+				"200": map[string]interface{}{
+					"description": "path of the node traffic capture file",
+				},
+			},
+		},
+	}
+	paths[corruptDBPath] = map[string]interface{}{
+		"post": map[string]interface{}{
+			"operationId": "CorruptDB",
+			"responses": map[string]interface{}{
+				"200": map[string]interface{}{
+					"description": "db files of the paused node that were damaged",
+				},
+			},
+		},
+	}
+	paths[inspectDBPath] = map[string]interface{}{
+		"get": map[string]interface{}{
+			"operationId": "InspectDB",
+			"responses": map[string]interface{}{
+				"200": map[string]interface{}{
+					"description": "last accepted blocks, validators and size by prefix of the paused node db",
+				},
+			},
+		},
+	}
+	paths[compactDBPath] = map[string]interface{}{
+		"post": map[string]interface{}{
+			"operationId": "CompactDB",
+			"responses": map[string]interface{}{
+				"200": map[string]interface{}{
+					"description": "db size of the paused node before and after compaction",
+				},
+			},
+		},
+	}
+	paths[exportChainPath] = map[string]interface{}{
+		"post": map[string]interface{}{
+			"operationId": "ExportChain",
+			"responses": map[string]interface{}{
+				"200": map[string]interface{}{
+					"description": "header of the file the accepted chain blocks were exported to",
+				},
+			},
+		},
+	}
+	paths[replayChainPath] = map[string]interface{}{
+		"post": map[string]interface{}{
+			"operationId": "ReplayChain",
+			"responses": map[string]interface{}{
+				"200": map[string]interface{}{
+					"description": "number of blocks and txs of the C-Chain export replayed into the network",
+				},
+			},
+		},
+	}
+	paths[rollbackNodePath] = map[string]interface{}{
+		"post": map[string]interface{}{
+			"operationId": "RollbackNode",
+			"responses": map[string]interface{}{
+				"200": map[string]interface{}{
+					"description": "cluster info after restarting the node with its config before the last restart",
+				},
+			},
+		},
+	}
+	paths[upgradeNodesPath] = map[string]interface{}{
+		"post": map[string]interface{}{
+			"operationId": "UpgradeNodes",
+			"responses": map[string]interface{}{
+				"200": map[string]interface{}{
+					"description": "cluster info after restarting the nodes one at a time with the new binary",
+				},
+			},
+		},
+	}
+	paths[schedulesPath] = map[string]interface{}{
+		"get": map[string]interface{}{
+			"operationId": "ListScheduledOperations",
+			"responses": map[string]interface{}{
+				"200": map[string]interface{}{
+					"description": "operations scheduled on the network, with their next and last runs",
+				},
+			},
+		},
+		"post": map[string]interface{}{
+			"operationId": "ScheduleOperation",
+			"responses": map[string]interface{}{
+				"200": map[string]interface{}{
+					"description": "scheduled operation, with its ID and next run",
+				},
+			},
+		},
+		"delete": map[string]interface{}{
+			"operationId": "CancelScheduledOperation",
+			"responses": map[string]interface{}{
+				"200": map[string]interface{}{
+					"description": "scheduled operation of the id query parameter removed",
+				},
+			},
+		},
+	}
+	paths[registryPath] = map[string]interface{}{
+		"get": map[string]interface{}{
+			"operationId": "ListRegisteredNetworks",
+			"responses": map[string]interface{}{
+				"200": map[string]interface{}{
+					"description": "networks of the server, including the ones of previous servers",
+				},
+			},
+		},
+		"delete": map[string]interface{}{
+			"operationId": "RemoveRegisteredNetwork",
+			"responses": map[string]interface{}{
+				"200": map[string]interface{}{
+					"description": "network of the rootDataDir query parameter removed, its nodes terminated if detached",
+				},
+			},
+		},
+	}
+	paths[readinessProbePath] = map[string]interface{}{
+		"post": map[string]interface{}{
+			"operationId": "AddReadinessProbe",
+			"responses": map[string]interface{}{
+				"200": map[string]interface{}{
+					"description": "readiness probe registered, to pass for the network to be healthy",
+				},
+			},
+		},
+	}
+	paths[validatorsPath] = map[string]interface{}{
+		"get": map[string]interface{}{
+			"operationId": "Validators",
+			"responses": map[string]interface{}{
+				"200": map[string]interface{}{
+					"description": "uptime, stake and delegation info of the current validators as seen by each node, and the discrepancies between their views",
+				},
+			},
+		},
+	}
+	paths[delegatorsPath] = map[string]interface{}{
+		"post": map[string]interface{}{
+			"operationId": "AddDelegators",
+			"responses": map[string]interface{}{
+				"200": map[string]interface{}{
+					"description": "IDs of the txs delegating stake from the test keychain to the validators",
+				},
+			},
+		},
+	}
+	paths[diffTestPath] = map[string]interface{}{
+		"post": map[string]interface{}{
+			"operationId": "DiffTest",
+			"responses": map[string]interface{}{
+				"200": map[string]interface{}{
+					"description": "responses of every node to identical read calls, grouped by node, and the number of divergent calls",
+				},
+			},
+		},
+	}
+	paths[assertionsPath] = map[string]interface{}{
+		"post": map[string]interface{}{
+			"operationId": "AddAssertions",
+			"responses": map[string]interface{}{
+				"200": map[string]interface{}{
+					"description": "assertions registered from the YAML document, evaluated on the run events",
+				},
+			},
+		},
+	}
+	paths[eventsPath] = map[string]interface{}{
+		"post": map[string]interface{}{
+			"operationId": "AddEvent",
+			"responses": map[string]interface{}{
+				"204": map[string]interface{}{
+					"description": "event recorded in the run report, starting the assertions after it",
+				},
+			},
+		},
+	}
+	paths[runReportPath] = map[string]interface{}{
+		"get": map[string]interface{}{
+			"operationId": "RunReport",
+			"responses": map[string]interface{}{
+				"200": map[string]interface{}{
+					"description": "run report, as Markdown or JUnit XML, with the pass/fail result of each assertion evaluation",
+				},
+			},
+		},
+	}
+	paths[apiTracePath] = map[string]interface{}{
+		"get": map[string]interface{}{
+			"operationId": "APITrace",
+			"responses": map[string]interface{}{
+				"200": map[string]interface{}{
+					"description": "method, params size, latency and status of the last requests served by the node API proxy",
+				},
+			},
+		},
+	}
+	paths[minePath] = map[string]interface{}{
+		"post": map[string]interface{}{
+			"operationId": "Mine",
+			"responses": map[string]interface{}{
+				"200": map[string]interface{}{
+					"description": "height of the last block produced on demand on the EVM chain of the dev node",
+				},
+			},
+		},
+	}
+	paths[ttlPath] = map[string]interface{}{
+		"get": map[string]interface{}{
+			"operationId": "GetRemainingTime",
+			"responses": map[string]interface{}{
+				"200": map[string]interface{}{
+					"description": "TTL of the network and remaining time until it is stopped",
+				},
+			},
+		},
+		"post": map[string]interface{}{
+			"operationId": "SetTTL",
+			"responses": map[string]interface{}{
+				"200": map[string]interface{}{
+					"description": "TTL of the network, set from now on, and remaining time until it is stopped",
+				},
+			},
+		},
+	}
+	paths[nodesPath] = map[string]interface{}{
+		"get": map[string]interface{}{
+			"operationId": "Nodes",
+			"responses": map[string]interface{}{
+				"200": map[string]interface{}{
+					"description": "status of the network nodes, including role, db type, bind IP, disk usage, bring-up timings and process runtime info",
+				},
+			},
+		},
+	}
+	spec := map[string]interface{}{
+		"openapi": "3.0.3",
+		"info": map[string]interface{}{
+			"title":   "netrunner control gateway",
+			"version": "v1",
+		},
+		"paths": paths,
+	}
+	return json.MarshalIndent(spec, "", "  ")
+}
+
+// Serves the OpenAPI stub and the endpoint catalog,
+// delegating everything else to the gRPC gateway.
+func (s *server) newGatewayHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc(openAPIPath, func(w http.ResponseWriter, _ *http.Request) {
+		spec, err := OpenAPISpec()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write(spec)
+	})
+	mux.HandleFunc(endpointsPath, func(w http.ResponseWriter, r *http.Request) {
+		if !s.authenticateHTTP(w, r) {
+			return
+		}
+		catalog, err := s.getEndpointCatalog()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusServiceUnavailable)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(catalog)
+	})
+	s.registerTemplateHandlers(mux)
+	mux.HandleFunc(gitSyncPath, s.handleGitSync)
+	mux.HandleFunc(metricsPath, s.handleMetrics)
+	mux.HandleFunc(capabilitiesPath, s.handleCapabilities)
+	mux.HandleFunc(capturePath, s.handleCapture)
+	mux.HandleFunc(nodesPath, s.handleNodes)
+	mux.HandleFunc(corruptDBPath, s.handleCorruptDB)
+	mux.HandleFunc(inspectDBPath, s.handleInspectDB)
+	mux.HandleFunc(compactDBPath, s.handleCompactDB)
+	mux.HandleFunc(exportChainPath, s.handleExportChain)
+	mux.HandleFunc(replayChainPath, s.handleReplayChain)
+	mux.HandleFunc(rollbackNodePath, s.handleRollbackNode)
+	mux.HandleFunc(upgradeNodesPath, s.handleUpgradeNodes)
+	mux.HandleFunc(schedulesPath, s.handleSchedules)
+	mux.HandleFunc(registryPath, s.handleRegistry)
+	mux.HandleFunc(readinessProbePath, s.handleReadinessProbe)
+	mux.HandleFunc(validatorsPath, s.handleValidators)
+	mux.HandleFunc(delegatorsPath, s.handleAddDelegators)
+	mux.HandleFunc(diffTestPath, s.handleDiffTest)
+	mux.HandleFunc(assertionsPath, s.handleAssertions)
+	mux.HandleFunc(eventsPath, s.handleEvent)
+	mux.HandleFunc(runReportPath, s.handleRunReport)
+	mux.HandleFunc(apiTracePath, s.handleAPITrace)
+	mux.HandleFunc(minePath, s.handleMine)
+	mux.HandleFunc(ttlPath, s.handleTTL)
+	mux.Handle("/", s.gwMux)
+	return mux
+}
+
+func (s *server) getEndpointCatalog() (network.EndpointCatalog, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.network == nil {
+		return network.EndpointCatalog{}, ErrNotBootstrapped
+	}
+	return s.network.GetEndpointCatalog()
+}
@@ -0,0 +1,126 @@
+// Copyright (C) 2021-2024, Lux Partners Limited. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package server
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/luxdefi/netrunner/network/node"
+	"github.com/luxdefi/node/utils/logging"
+	"go.uber.org/zap"
+	"golang.org/x/exp/maps"
+)
+
+const (
+	portMapFname = "ports.json"
+	// max time the port map hook may run
+	portMapHookTimeout = 30 * time.Second
+	// env var with the port map file path given to the hook
+	portMapHookEnvVar = "NETRUNNER_PORT_MAP"
+)
+
+// PortMap is the machine-readable map of the ports used by the network,
+// written to the network root dir whenever the nodes change, for
+// firewall and port-forwarding automation
+type PortMap struct {
+	Network string      `json:"network,omitempty"`
+	Nodes   []NodePorts `json:"nodes"`
+}
+
+// NodePorts are the ports of a node. The node metrics are served
+// by its API, so the metrics port is the API port.
+type NodePorts struct {
+	Name        string `json:"name"`
+	NodeID      string `json:"nodeID"`
+	Host        string `json:"host"`
+	APIPort     uint16 `json:"apiPort"`
+	P2PPort     uint16 `json:"p2pPort"`
+	MetricsPort uint16 `json:"metricsPort"`
+	MetricsPath string `json:"metricsPath"`
+	Paused      bool   `json:"paused,omitempty"`
+}
+
+// Returns the port map of [nodes], sorted by node name so that
+// the same ports always give the same map
+func newPortMap(networkName string, nodes map[string]node.Node) PortMap {
+	portMap := PortMap{
+		Network: networkName,
+		Nodes:   []NodePorts{},
+	}
+	nodeNames := maps.Keys(nodes)
+	sort.Strings(nodeNames)
+	for _, nodeName := range nodeNames {
+		n := nodes[nodeName]
+		portMap.Nodes = append(portMap.Nodes, NodePorts{
+			Name:        nodeName,
+			NodeID:      n.GetNodeID().String(),
+			Host:        n.GetURL(),
+			APIPort:     n.GetAPIPort(),
+			P2PPort:     n.GetP2PPort(),
+			MetricsPort: n.GetAPIPort(),
+			MetricsPath: "/ext/metrics",
+			Paused:      n.GetPaused(),
+		})
+	}
+	return portMap
+}
+
+// Writes the port map of [nodes] to the network root dir and, if the
+// ports changed and a hook is set, runs the hook with the path of the
+// port map file. A failed hook is logged, as the network did change.
+// Assumes [lc.lock] is held.
+func (lc *localNetwork) generatePortMap(nodes map[string]node.Node) error {
+	if lc.portMapPath == "" {
+		lc.portMapPath = filepath.Join(lc.options.rootDataDir, portMapFname)
+		lc.log.Info(fmt.Sprintf(logging.Cyan.Wrap("port map file %s"), lc.portMapPath))
+	}
+	b, err := json.MarshalIndent(newPortMap(lc.cfg.Name, nodes), "", "  ")
+	if err != nil {
+		return err
+	}
+	b = append(b, '\n')
+	if prev, err := os.ReadFile(lc.portMapPath); err == nil && bytes.Equal(prev, b) {
+		return nil
+	}
+	// written to a temp file first so that readers never see a partial map
+	tmpPath := lc.portMapPath + ".tmp"
+	if err := os.WriteFile(tmpPath, b, 0o644); err != nil { //nolint:gosec
+		return err
+	}
+	if err := os.Rename(tmpPath, lc.portMapPath); err != nil {
+		return err
+	}
+	if lc.options.portMapHook != "" {
+		lc.runPortMapHook(b)
+	}
+	return nil
+}
+
+// Runs the port map hook with the port map file path as argument and
+// in [portMapHookEnvVar], and the port map [portMap] in its stdin
+func (lc *localNetwork) runPortMapHook(portMap []byte) {
+	ctx, cancel := context.WithTimeout(context.Background(), portMapHookTimeout)
+	defer cancel()
+	cmd := exec.CommandContext(ctx, lc.options.portMapHook, lc.portMapPath) //nolint:gosec
+	cmd.Env = append(os.Environ(), portMapHookEnvVar+"="+lc.portMapPath)
+	cmd.Stdin = bytes.NewReader(portMap)
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		lc.log.Warn("port map hook failed",
+			zap.String("hook", lc.options.portMapHook),
+			zap.String("output", string(out)),
+			zap.Error(err),
+		)
+		return
+	}
+	lc.log.Debug("ran port map hook", zap.String("hook", lc.options.portMapHook))
+}
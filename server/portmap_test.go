@@ -0,0 +1,131 @@
+// Copyright (C) 2021-2024, Lux Partners Limited. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package server
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/luxdefi/netrunner/network"
+	"github.com/luxdefi/netrunner/network/node"
+	"github.com/luxdefi/node/ids"
+	"github.com/luxdefi/node/utils/logging"
+	"github.com/stretchr/testify/require"
+)
+
+// portMapNode is a node with the ports set by the test.
+// The methods not overridden panic.
+type portMapNode struct {
+	node.Node
+	nodeID  ids.NodeID
+	apiPort uint16
+	p2pPort uint16
+	paused  bool
+}
+
+func (n *portMapNode) GetNodeID() ids.NodeID {
+	return n.nodeID
+}
+
+func (*portMapNode) GetURL() string {
+	return "127.0.0.1"
+}
+
+func (n *portMapNode) GetAPIPort() uint16 {
+	return n.apiPort
+}
+
+func (n *portMapNode) GetP2PPort() uint16 {
+	return n.p2pPort
+}
+
+func (n *portMapNode) GetPaused() bool {
+	return n.paused
+}
+
+// TestGeneratePortMap checks the port map file, and that the hook
+// is run with it whenever the ports change
+func TestGeneratePortMap(t *testing.T) {
+	require := require.New(t)
+	dir := t.TempDir()
+	hookOutput := filepath.Join(dir, "hook.out")
+	hook := filepath.Join(dir, "hook.sh")
+	// appends its arg, env var and stdin to [hookOutput]
+	require.NoError(os.WriteFile(hook, []byte(`#!/bin/sh
+{ echo "$1 $`+portMapHookEnvVar+`"; cat; } >> "`+hookOutput+`"
+`), 0o700))
+	rootDataDir := filepath.Join(dir, "network")
+	require.NoError(os.MkdirAll(rootDataDir, os.ModePerm))
+	lc := &localNetwork{
+		log:     logging.NoLog{},
+		cfg:     network.Config{Name: "devnet"},
+		options: localNetworkOptions{rootDataDir: rootDataDir, portMapHook: hook},
+	}
+	node1 := &portMapNode{nodeID: ids.NodeID{1}, apiPort: 9650, p2pPort: 9651}
+	node2 := &portMapNode{nodeID: ids.NodeID{2}, apiPort: 9652, p2pPort: 9653, paused: true}
+	nodes := map[string]node.Node{"node2": node2, "node1": node1}
+
+	require.NoError(lc.generatePortMap(nodes))
+	portMapPath := filepath.Join(rootDataDir, portMapFname)
+	require.Equal(portMapPath, lc.portMapPath)
+	portMapBytes, err := os.ReadFile(portMapPath)
+	require.NoError(err)
+	portMap := PortMap{}
+	require.NoError(json.Unmarshal(portMapBytes, &portMap))
+	require.Equal(PortMap{
+		Network: "devnet",
+		Nodes: []NodePorts{
+			{
+				Name:        "node1",
+				NodeID:      node1.nodeID.String(),
+				Host:        "127.0.0.1",
+				APIPort:     9650,
+				P2PPort:     9651,
+				MetricsPort: 9650,
+				MetricsPath: "/ext/metrics",
+			},
+			{
+				Name:        "node2",
+				NodeID:      node2.nodeID.String(),
+				Host:        "127.0.0.1",
+				APIPort:     9652,
+				P2PPort:     9653,
+				MetricsPort: 9652,
+				MetricsPath: "/ext/metrics",
+				Paused:      true,
+			},
+		},
+	}, portMap)
+	hookRun := portMapPath + " " + portMapPath + "\n" + string(portMapBytes)
+	requireFileContents(t, hookOutput, hookRun)
+
+	// the hook isn't run if the ports didn't change
+	require.NoError(lc.generatePortMap(nodes))
+	requireFileContents(t, hookOutput, hookRun)
+
+	node2.paused = false
+	node2.apiPort = 9654
+	require.NoError(lc.generatePortMap(nodes))
+	portMapBytes, err = os.ReadFile(portMapPath)
+	require.NoError(err)
+	require.Contains(string(portMapBytes), `"apiPort": 9654`)
+	requireFileContents(t, hookOutput, hookRun+portMapPath+" "+portMapPath+"\n"+string(portMapBytes))
+	require.NoFileExists(portMapPath + ".tmp")
+
+	// a failed hook doesn't fail the port map
+	require.NoError(os.WriteFile(hook, []byte("#!/bin/sh\nexit 1\n"), 0o700))
+	delete(nodes, "node2")
+	require.NoError(lc.generatePortMap(nodes))
+	portMapBytes, err = os.ReadFile(portMapPath)
+	require.NoError(err)
+	require.NotContains(string(portMapBytes), "node2")
+}
+
+func requireFileContents(t *testing.T, path string, expected string) {
+	b, err := os.ReadFile(path)
+	require.NoError(t, err)
+	require.Equal(t, expected, string(b))
+}
@@ -0,0 +1,36 @@
+// Copyright (C) 2021-2024, Lux Partners Limited. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package server
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/luxdefi/netrunner/expose"
+	"go.uber.org/zap"
+)
+
+// Returns the handler of the node API reverse proxy.
+// With tenancy enabled, requests must carry the token of the tenant owning
+// the network, which is removed before the request reaches the node.
+func (s *server) newAPIProxyHandler() http.Handler {
+	proxy := expose.NewProxy(s.log, s.getEndpointCatalog, s.apiTracer)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !s.authenticateHTTP(w, r) {
+			return
+		}
+		if s.tenancyEnabled() {
+			r.Header.Del("Authorization")
+		}
+		proxy.ServeHTTP(w, r)
+	})
+}
+
+// Serves the node API reverse proxy until it is closed
+func (s *server) runAPIProxy() {
+	s.log.Info("serving node API proxy", zap.String("port", s.cfg.APIProxyPort))
+	if err := s.apiProxyServer.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		s.log.Warn("node API proxy failed", zap.Error(err))
+	}
+}
@@ -0,0 +1,61 @@
+// Copyright (C) 2021-2024, Lux Partners Limited. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package server
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/luxdefi/netrunner/utils"
+	"github.com/luxdefi/node/utils/units"
+)
+
+// rough memory footprint of a luxd node on a local network,
+// used to estimate the memory usage of a network
+const estimatedNodeMemoryBytes = 512 * units.MiB
+
+var ErrQuotaExceeded = errors.New("network quota exceeded")
+
+// Quota limits the resources a network may use on the host.
+// Zero values mean no limit.
+type Quota struct {
+	// Max number of nodes, including paused ones
+	MaxNodes uint32
+	// Max size of the network root data dir. Also enforced
+	// continuously as the disk budget of started networks.
+	MaxDiskBytes uint64
+	// Max memory, estimated from the number of nodes
+	MaxMemoryBytes uint64
+}
+
+// checkNodes returns an error if a network of [numNodes]
+// nodes would exceed the node or memory limits
+func (q Quota) checkNodes(numNodes uint32) error {
+	if q.MaxNodes != 0 && numNodes > q.MaxNodes {
+		return fmt.Errorf("%w: %d nodes requested, max is %d", ErrQuotaExceeded, numNodes, q.MaxNodes)
+	}
+	if q.MaxMemoryBytes != 0 && uint64(numNodes)*estimatedNodeMemoryBytes > q.MaxMemoryBytes {
+		return fmt.Errorf(
+			"%w: %d nodes are estimated to use %d bytes of memory, max is %d",
+			ErrQuotaExceeded, numNodes, uint64(numNodes)*estimatedNodeMemoryBytes, q.MaxMemoryBytes,
+		)
+	}
+	return nil
+}
+
+// checkDisk returns an error if the size of [rootDataDir]
+// is over the disk limit
+func (q Quota) checkDisk(rootDataDir string) error {
+	if q.MaxDiskBytes == 0 {
+		return nil
+	}
+	size, err := utils.DirSize(rootDataDir)
+	if err != nil {
+		return fmt.Errorf("couldn't compute disk usage of %q: %w", rootDataDir, err)
+	}
+	if size > q.MaxDiskBytes {
+		return fmt.Errorf("%w: network uses %d bytes of disk, max is %d", ErrQuotaExceeded, size, q.MaxDiskBytes)
+	}
+	return nil
+}
@@ -0,0 +1,100 @@
+// Copyright (C) 2021-2024, Lux Partners Limited. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package server
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestQuotaCheckNodes(t *testing.T) {
+	tests := []struct {
+		name        string
+		quota       Quota
+		numNodes    uint32
+		expectedErr error
+	}{
+		{
+			name:     "no limit",
+			numNodes: 100,
+		},
+		{
+			name:     "at node limit",
+			quota:    Quota{MaxNodes: 5},
+			numNodes: 5,
+		},
+		{
+			name:        "over node limit",
+			quota:       Quota{MaxNodes: 5},
+			numNodes:    6,
+			expectedErr: ErrQuotaExceeded,
+		},
+		{
+			name:     "at memory limit",
+			quota:    Quota{MaxMemoryBytes: 2 * estimatedNodeMemoryBytes},
+			numNodes: 2,
+		},
+		{
+			name:        "over memory limit",
+			quota:       Quota{MaxMemoryBytes: 2*estimatedNodeMemoryBytes + 1},
+			numNodes:    3,
+			expectedErr: ErrQuotaExceeded,
+		},
+		{
+			name:        "under node limit, over memory limit",
+			quota:       Quota{MaxNodes: 10, MaxMemoryBytes: estimatedNodeMemoryBytes},
+			numNodes:    2,
+			expectedErr: ErrQuotaExceeded,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.ErrorIs(t, tt.quota.checkNodes(tt.numNodes), tt.expectedErr)
+		})
+	}
+}
+
+func TestQuotaCheckDisk(t *testing.T) {
+	rootDataDir := t.TempDir()
+	nodeDir := filepath.Join(rootDataDir, "node1")
+	require.NoError(t, os.MkdirAll(nodeDir, 0o750))
+	require.NoError(t, os.WriteFile(filepath.Join(rootDataDir, "config.json"), make([]byte, 100), 0o600))
+	require.NoError(t, os.WriteFile(filepath.Join(nodeDir, "db"), make([]byte, 1000), 0o600))
+
+	tests := []struct {
+		name        string
+		quota       Quota
+		rootDataDir string
+		expectedErr error
+	}{
+		{
+			name:        "no limit",
+			rootDataDir: rootDataDir,
+		},
+		{
+			name:        "at limit",
+			quota:       Quota{MaxDiskBytes: 1100},
+			rootDataDir: rootDataDir,
+		},
+		{
+			name:        "over limit",
+			quota:       Quota{MaxDiskBytes: 1099},
+			rootDataDir: rootDataDir,
+			expectedErr: ErrQuotaExceeded,
+		},
+		{
+			name:        "root data dir not created yet",
+			quota:       Quota{MaxDiskBytes: 1},
+			rootDataDir: filepath.Join(rootDataDir, "missing"),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require.ErrorIs(t, tt.quota.checkDisk(tt.rootDataDir), tt.expectedErr)
+		})
+	}
+}
@@ -0,0 +1,51 @@
+// Copyright (C) 2021-2024, Lux Partners Limited. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/luxdefi/netrunner/network"
+	"go.uber.org/zap"
+)
+
+const readinessProbePath = "/v1/control/readinessprobe"
+
+// POST {"name": "mychain", "url": "/ext/bc/mychain/ready", "expectBody": "ok"}
+// registers a readiness probe that must pass for the network to be healthy.
+// A URL path is queried on the API of every running node.
+func (s *server) handleReadinessProbe(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !s.authenticateHTTP(w, r) {
+		return
+	}
+	probe := network.ReadinessProbe{}
+	if err := json.NewDecoder(r.Body).Decode(&probe); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if err := probe.Validate(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.network == nil {
+		http.Error(w, ErrNotBootstrapped.Error(), http.StatusServiceUnavailable)
+		return
+	}
+	s.log.Debug("AddReadinessProbe", zap.String("probe", probe.Name), zap.String("url", probe.URL))
+
+	if err := s.network.nw.AddReadinessProbe(probe); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, probe)
+}
@@ -0,0 +1,362 @@
+// Copyright (C) 2021-2024, Lux Partners Limited. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/luxdefi/netrunner/local"
+	"github.com/shirou/gopsutil/process"
+	"go.uber.org/zap"
+	"golang.org/x/exp/slices"
+)
+
+const registryPath = "/v1/control/registry"
+
+// Statuses of the registered networks
+const (
+	// Served by this server
+	RegistryRunning = "running"
+	// Nodes left running, by this server on detach or by a previous one
+	RegistryDetached = "detached"
+	// Stopped by a server
+	RegistryStopped = "stopped"
+)
+
+var ErrRegisteredNetworkNotFound = errors.New("registered network not found")
+
+// RegisteredNode is a node of a registered network
+type RegisteredNode struct {
+	Name    string `json:"name"`
+	PID     int    `json:"pid"`
+	APIPort uint16 `json:"apiPort"`
+	P2PPort uint16 `json:"p2pPort"`
+}
+
+// RegisteredNetwork is a network created by a server, identified
+// by its root dir. The nodes of the stopped networks are not kept.
+type RegisteredNetwork struct {
+	// Empty for unnamed networks
+	Name        string           `json:"name,omitempty"`
+	RootDataDir string           `json:"rootDataDir"`
+	Tenant      string           `json:"tenant,omitempty"`
+	Status      string           `json:"status"`
+	Nodes       []RegisteredNode `json:"nodes,omitempty"`
+	UpdatedAt   time.Time        `json:"updatedAt"`
+}
+
+// Returns true if a node of the network is still running. A node is
+// only considered running if its process references the root dir of
+// the network, so that a PID reused by another process isn't taken for it.
+func (n *RegisteredNetwork) running() bool {
+	for _, node := range n.Nodes {
+		if nodeProcessRunning(node.PID, n.RootDataDir) {
+			return true
+		}
+	}
+	return false
+}
+
+func nodeProcessRunning(pid int, rootDataDir string) bool {
+	if pid <= 0 {
+		return false
+	}
+	proc, err := process.NewProcess(int32(pid))
+	if err != nil {
+		return false
+	}
+	cmdLine, err := proc.Cmdline()
+	return err == nil && strings.Contains(cmdLine, rootDataDir)
+}
+
+// networkRegistry keeps the networks of the server, persisting
+// them as a json file at [path] if it is not empty, so that
+// they outlive the server
+type networkRegistry struct {
+	lock sync.Mutex
+	path string
+	// root dir --> network
+	networks map[string]*RegisteredNetwork
+}
+
+// Loads the networks persisted at [path]. The networks left with nodes
+// running are adopted as detached: the server doesn't serve them, but
+// lists them until their nodes exit, and terminates their nodes on
+// removal. The ones whose nodes all exited without being stopped are
+// dropped, and returned so that their root dirs are removed.
+func newNetworkRegistry(path string) (*networkRegistry, []*RegisteredNetwork, error) {
+	r := &networkRegistry{
+		path:     path,
+		networks: map[string]*RegisteredNetwork{},
+	}
+	if path == "" {
+		return r, nil, nil
+	}
+	b, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return r, nil, nil
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	networks := []*RegisteredNetwork{}
+	if err := json.Unmarshal(b, &networks); err != nil {
+		return nil, nil, fmt.Errorf("couldn't unmarshal network registry file %q: %w", path, err)
+	}
+	for _, n := range networks {
+		r.networks[n.RootDataDir] = n
+	}
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	dead := r.reconcile(RegistryRunning, RegistryDetached)
+	return r, dead, r.save()
+}
+
+// Marks the networks of [statuses] as detached if a node of theirs is
+// still running, or else drops them, returning the dropped ones.
+// Stopped networks are dropped once their root dir is removed.
+// Assumes [r.lock] is held.
+func (r *networkRegistry) reconcile(statuses ...string) []*RegisteredNetwork {
+	dead := []*RegisteredNetwork{}
+	for rootDir, n := range r.networks {
+		if n.Status == RegistryStopped {
+			if _, err := os.Stat(rootDir); errors.Is(err, os.ErrNotExist) {
+				delete(r.networks, rootDir)
+			}
+			continue
+		}
+		if !slices.Contains(statuses, n.Status) {
+			continue
+		}
+		if n.running() {
+			n.Status = RegistryDetached
+			continue
+		}
+		delete(r.networks, rootDir)
+		dead = append(dead, n)
+	}
+	return dead
+}
+
+// Writes the networks to [r.path], if not empty.
+// Assumes [r.lock] is held.
+func (r *networkRegistry) save() error {
+	if r.path == "" {
+		return nil
+	}
+	b, err := json.MarshalIndent(r.sorted(""), "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(r.path), os.ModePerm); err != nil {
+		return err
+	}
+	// replaced at once, so that a crash doesn't leave it truncated
+	tmpPath := r.path + ".tmp"
+	if err := os.WriteFile(tmpPath, b, 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmpPath, r.path)
+}
+
+// Returns the networks of [tenant] sorted by root dir, or all
+// of them if [tenant] is empty.
+// Assumes [r.lock] is held.
+func (r *networkRegistry) sorted(tenant string) []*RegisteredNetwork {
+	networks := make([]*RegisteredNetwork, 0, len(r.networks))
+	for _, n := range r.networks {
+		if tenant == "" || n.Tenant == tenant {
+			networks = append(networks, n)
+		}
+	}
+	sort.Slice(networks, func(i, j int) bool {
+		return networks[i].RootDataDir < networks[j].RootDataDir
+	})
+	return networks
+}
+
+// Records [n], replacing any network with the same root dir
+func (r *networkRegistry) put(n *RegisteredNetwork) error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	n.UpdatedAt = time.Now()
+	r.networks[n.RootDataDir] = n
+	return r.save()
+}
+
+// Sets the status of the network of [rootDir], if any. The nodes of the
+// network are dropped if stopped.
+func (r *networkRegistry) setStatus(rootDir string, status string) error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	n, ok := r.networks[rootDir]
+	if !ok {
+		return nil
+	}
+	n.Status = status
+	n.UpdatedAt = time.Now()
+	if status == RegistryStopped {
+		n.Nodes = nil
+	}
+	return r.save()
+}
+
+// Returns a copy of the networks of [tenant], with the detached
+// networks whose nodes exited dropped, and their root dirs removed
+func (r *networkRegistry) list(tenant string) ([]RegisteredNetwork, error) {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	dead := r.reconcile(RegistryDetached)
+	networks := []RegisteredNetwork{}
+	for _, n := range r.sorted(tenant) {
+		networks = append(networks, *n)
+	}
+	if len(dead) == 0 {
+		return networks, nil
+	}
+	if err := r.save(); err != nil {
+		return networks, err
+	}
+	_, err := removeDeadRootDirs(dead)
+	return networks, err
+}
+
+// Removes the root dirs of the [dead] networks, returning the removed
+// ones. A root dir is kept if it is still in use, e.g. locked by another
+// runner or referenced by a running process.
+func removeDeadRootDirs(dead []*RegisteredNetwork) ([]local.NetworkRootDir, error) {
+	removed := []local.NetworkRootDir{}
+	for _, n := range dead {
+		rootDir := filepath.Clean(n.RootDataDir)
+		orphans, err := local.FindOrphanedRootDirs([]string{filepath.Dir(rootDir)}, nil)
+		if err != nil {
+			return removed, err
+		}
+		for _, orphan := range orphans {
+			if orphan.Path != rootDir {
+				continue
+			}
+			if err := local.RemoveRootDirs([]local.NetworkRootDir{orphan}); err != nil {
+				return removed, err
+			}
+			removed = append(removed, orphan)
+		}
+	}
+	return removed, nil
+}
+
+// Removes the network of [rootDir] of [tenant]. The nodes of a detached
+// network still running are terminated first. The network served by the
+// server can't be removed.
+func (r *networkRegistry) remove(rootDir string, tenant string) error {
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	n, ok := r.networks[rootDir]
+	if !ok || (tenant != "" && n.Tenant != tenant) {
+		return fmt.Errorf("%w: %q", ErrRegisteredNetworkNotFound, rootDir)
+	}
+	if n.Status == RegistryRunning {
+		return fmt.Errorf("network %q is served by the server, stop it instead", rootDir)
+	}
+	for _, node := range n.Nodes {
+		if !nodeProcessRunning(node.PID, n.RootDataDir) {
+			continue
+		}
+		// the node processes lead their own process group
+		if err := syscall.Kill(-node.PID, syscall.SIGTERM); err != nil && !errors.Is(err, syscall.ESRCH) {
+			return fmt.Errorf("couldn't terminate node %q: %w", node.Name, err)
+		}
+	}
+	delete(r.networks, rootDir)
+	return r.save()
+}
+
+// Records the network as [status], with its current nodes. Failures are
+// logged, as the registry doesn't affect the network.
+// Assumes [s.mu] is held.
+func (s *server) registerNetwork(status string) {
+	if s.network == nil || s.clusterInfo == nil {
+		return
+	}
+	n := &RegisteredNetwork{
+		Name:        s.network.cfg.Name,
+		RootDataDir: s.clusterInfo.RootDataDir,
+		Tenant:      s.networkTenant,
+		Status:      status,
+	}
+	nodes, err := s.network.nw.GetAllNodes()
+	if err == nil {
+		for name, node := range nodes {
+			n.Nodes = append(n.Nodes, RegisteredNode{
+				Name:    name,
+				PID:     node.GetRuntimeInfo().PID,
+				APIPort: node.GetAPIPort(),
+				P2PPort: node.GetP2PPort(),
+			})
+		}
+		sort.Slice(n.Nodes, func(i, j int) bool {
+			return n.Nodes[i].Name < n.Nodes[j].Name
+		})
+	}
+	if err := s.registry.put(n); err != nil {
+		s.log.Warn("couldn't register network", zap.String("root-data-dir", n.RootDataDir), zap.Error(err))
+	}
+}
+
+// Records the network as stopped. Failures are logged.
+// Assumes [s.mu] is held.
+func (s *server) unregisterNetwork() {
+	if s.network == nil || s.clusterInfo == nil {
+		return
+	}
+	if err := s.registry.setStatus(s.clusterInfo.RootDataDir, RegistryStopped); err != nil {
+		s.log.Warn("couldn't register network", zap.String("root-data-dir", s.clusterInfo.RootDataDir), zap.Error(err))
+	}
+}
+
+// GET lists the networks of the registry, DELETE ?rootDataDir=<dir>
+// removes one, terminating its nodes if it is detached
+func (s *server) handleRegistry(w http.ResponseWriter, r *http.Request) {
+	tenant, ok := s.httpTenant(w, r)
+	if !ok {
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+		networks, err := s.registry.list(tenant)
+		if err != nil {
+			s.log.Warn("couldn't update network registry", zap.Error(err))
+		}
+		writeJSON(w, networks)
+	case http.MethodDelete:
+		rootDir := r.URL.Query().Get("rootDataDir")
+		if err := s.registry.remove(rootDir, tenant); err != nil {
+			status := http.StatusConflict
+			if errors.Is(err, ErrRegisteredNetworkNotFound) {
+				status = http.StatusNotFound
+			}
+			http.Error(w, err.Error(), status)
+			return
+		}
+		s.log.Info("network removed from registry", zap.String("root-data-dir", rootDir))
+		writeJSON(w, map[string]string{"rootDataDir": rootDir})
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
@@ -0,0 +1,209 @@
+// Copyright (C) 2021-2024, Lux Partners Limited. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package server
+
+import (
+	"encoding/json"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"syscall"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+// Starts a process referencing [rootDir] in its command line, as a node
+// of the network would, in its own process group
+func startRootDirProcess(t *testing.T, rootDir string) *exec.Cmd {
+	cmd := exec.Command("sh", "-c", "sleep 30; :", "sh", rootDir)
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	require.NoError(t, cmd.Start())
+	t.Cleanup(func() {
+		_ = syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+		_ = cmd.Wait()
+	})
+	return cmd
+}
+
+func newRegistryRootDir(t *testing.T, parentDir string, name string) string {
+	rootDir := filepath.Join(parentDir, name)
+	require.NoError(t, os.MkdirAll(rootDir, os.ModePerm))
+	return rootDir
+}
+
+func TestNetworkRegistryReload(t *testing.T) {
+	require := require.New(t)
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "registry.json")
+	stopped := newRegistryRootDir(t, dir, "network-stopped")
+	stoppedRemoved := filepath.Join(dir, "network-stopped-removed")
+	served := newRegistryRootDir(t, dir, "network-served")
+	crashed := newRegistryRootDir(t, dir, "network-crashed")
+	detachedDead := newRegistryRootDir(t, dir, "network-detached-dead")
+	cmd := startRootDirProcess(t, served)
+
+	r, dead, err := newNetworkRegistry(path)
+	require.NoError(err)
+	require.Empty(dead)
+	for _, n := range []*RegisteredNetwork{
+		{RootDataDir: stopped, Status: RegistryStopped},
+		{RootDataDir: stoppedRemoved, Status: RegistryStopped},
+		{
+			Name:        "devnet",
+			RootDataDir: served,
+			Tenant:      "team-a",
+			Status:      RegistryRunning,
+			Nodes:       []RegisteredNode{{Name: "node1", PID: cmd.Process.Pid, APIPort: 9650, P2PPort: 9651}},
+		},
+		// the nodes exited along the crashed server
+		{RootDataDir: crashed, Status: RegistryRunning, Nodes: []RegisteredNode{{Name: "node1"}}},
+		{RootDataDir: detachedDead, Status: RegistryDetached, Nodes: []RegisteredNode{{Name: "node1"}}},
+	} {
+		require.NoError(r.put(n))
+	}
+
+	// a restarted server
+	r, dead, err = newNetworkRegistry(path)
+	require.NoError(err)
+	deadDirs := []string{}
+	for _, n := range dead {
+		deadDirs = append(deadDirs, n.RootDataDir)
+	}
+	require.ElementsMatch([]string{crashed, detachedDead}, deadDirs)
+	networks, err := r.list("")
+	require.NoError(err)
+	require.Len(networks, 2)
+	require.Equal(served, networks[0].RootDataDir)
+	require.Equal("devnet", networks[0].Name)
+	require.Equal("team-a", networks[0].Tenant)
+	require.Equal(RegistryDetached, networks[0].Status)
+	require.Equal([]RegisteredNode{{Name: "node1", PID: cmd.Process.Pid, APIPort: 9650, P2PPort: 9651}}, networks[0].Nodes)
+	require.Equal(stopped, networks[1].RootDataDir)
+	require.Equal(RegistryStopped, networks[1].Status)
+	networks, err = r.list("team-a")
+	require.NoError(err)
+	require.Len(networks, 1)
+	require.Equal(served, networks[0].RootDataDir)
+	networks, err = r.list("team-b")
+	require.NoError(err)
+	require.Empty(networks)
+
+	removed, err := removeDeadRootDirs(dead)
+	require.NoError(err)
+	require.Len(removed, 2)
+	require.NoDirExists(crashed)
+	require.NoDirExists(detachedDead)
+	require.DirExists(stopped)
+	require.DirExists(served)
+
+	// the detached network is dropped once its nodes exit,
+	// and its root dir removed
+	require.NoError(syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL))
+	_ = cmd.Wait()
+	networks, err = r.list("")
+	require.NoError(err)
+	require.Len(networks, 1)
+	require.Equal(stopped, networks[0].RootDataDir)
+	require.NoDirExists(served)
+
+	r, dead, err = newNetworkRegistry(path)
+	require.NoError(err)
+	require.Empty(dead)
+	require.Len(r.networks, 1)
+}
+
+func TestNetworkRegistryRemove(t *testing.T) {
+	dir := t.TempDir()
+	running := newRegistryRootDir(t, dir, "network-running")
+	detached := newRegistryRootDir(t, dir, "network-detached")
+	stopped := newRegistryRootDir(t, dir, "network-stopped")
+
+	tests := []struct {
+		name               string
+		rootDir            string
+		tenant             string
+		expectedErr        error
+		expectedErrText    string
+		expectedRegistered bool
+		expectedKilled     bool
+	}{
+		{
+			name:           "detached",
+			rootDir:        detached,
+			tenant:         "team-a",
+			expectedKilled: true,
+		},
+		{
+			name:    "stopped, no tenancy",
+			rootDir: stopped,
+		},
+		{
+			name:               "other tenant",
+			rootDir:            detached,
+			tenant:             "team-b",
+			expectedErr:        ErrRegisteredNetworkNotFound,
+			expectedRegistered: true,
+		},
+		{
+			name:        "unknown",
+			rootDir:     filepath.Join(dir, "network-unknown"),
+			tenant:      "team-a",
+			expectedErr: ErrRegisteredNetworkNotFound,
+		},
+		{
+			name:               "served",
+			rootDir:            running,
+			tenant:             "team-a",
+			expectedErrText:    "is served by the server",
+			expectedRegistered: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require := require.New(t)
+
+			path := filepath.Join(t.TempDir(), "registry.json")
+			r, _, err := newNetworkRegistry(path)
+			require.NoError(err)
+			cmd := startRootDirProcess(t, detached)
+			for _, n := range []*RegisteredNetwork{
+				{RootDataDir: running, Tenant: "team-a", Status: RegistryRunning},
+				{
+					RootDataDir: detached,
+					Tenant:      "team-a",
+					Status:      RegistryDetached,
+					Nodes:       []RegisteredNode{{Name: "node1", PID: cmd.Process.Pid}},
+				},
+				{RootDataDir: stopped, Tenant: "team-b", Status: RegistryStopped},
+			} {
+				require.NoError(r.put(n))
+			}
+
+			err = r.remove(tt.rootDir, tt.tenant)
+			switch {
+			case tt.expectedErr != nil:
+				require.ErrorIs(err, tt.expectedErr)
+			case tt.expectedErrText != "":
+				require.ErrorContains(err, tt.expectedErrText)
+			default:
+				require.NoError(err)
+			}
+			_, registered := r.networks[tt.rootDir]
+			require.Equal(tt.expectedRegistered, registered)
+			// the change is persisted
+			b, err := os.ReadFile(path)
+			require.NoError(err)
+			persisted := []*RegisteredNetwork{}
+			require.NoError(json.Unmarshal(b, &persisted))
+			require.Len(persisted, len(r.networks))
+
+			if tt.expectedKilled {
+				require.Error(cmd.Wait())
+				require.Equal(syscall.SIGTERM, cmd.ProcessState.Sys().(syscall.WaitStatus).Signal())
+			}
+		})
+	}
+}
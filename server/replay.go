@@ -0,0 +1,115 @@
+// Copyright (C) 2021-2024, Lux Partners Limited. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/luxdefi/netrunner/replay"
+	"github.com/luxdefi/netrunner/utils"
+	"go.uber.org/zap"
+)
+
+const (
+	exportChainPath = "/v1/control/exportchain"
+	replayChainPath = "/v1/control/replaychain"
+)
+
+type exportChainRequest struct {
+	// Optional, defaults to the first running node
+	NodeName string `json:"nodeName"`
+	// Optional, defaults to "C"
+	Chain string `json:"chain"`
+	// File to write on the server host
+	Path string `json:"path"`
+}
+
+type replayChainRequest struct {
+	// Optional, defaults to the first running node
+	NodeName string `json:"nodeName"`
+	// Export file on the server host
+	Path string `json:"path"`
+}
+
+// POST {"chain": "C", "path": "/tmp/c-blocks.jsonl"} exports the accepted
+// blocks of the chain from the node index, returning the file header.
+func (s *server) handleExportChain(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !s.authenticateHTTP(w, r) {
+		return
+	}
+	req := exportChainRequest{}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if req.Path == "" {
+		http.Error(w, "missing path", http.StatusBadRequest)
+		return
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.network == nil {
+		http.Error(w, ErrNotBootstrapped.Error(), http.StatusServiceUnavailable)
+		return
+	}
+	s.log.Debug("ExportChain", utils.NodeField(req.NodeName), zap.String("chain", req.Chain), zap.String("path", req.Path))
+
+	header, err := replay.Export(r.Context(), s.network.nw, replay.ExportConfig{
+		NodeName: req.NodeName,
+		Chain:    req.Chain,
+		Path:     req.Path,
+	})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, header)
+}
+
+// POST {"path": "/tmp/c-blocks.jsonl"} replays the transactions of a
+// C-Chain export into the running network, returning what was replayed.
+func (s *server) handleReplayChain(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !s.authenticateHTTP(w, r) {
+		return
+	}
+	req := replayChainRequest{}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if req.Path == "" {
+		http.Error(w, "missing path", http.StatusBadRequest)
+		return
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.network == nil {
+		http.Error(w, ErrNotBootstrapped.Error(), http.StatusServiceUnavailable)
+		return
+	}
+	s.log.Debug("ReplayChain", utils.NodeField(req.NodeName), zap.String("path", req.Path))
+
+	result, err := replay.Ingest(r.Context(), s.network.nw, replay.IngestConfig{
+		NodeName: req.NodeName,
+		Path:     req.Path,
+	})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, result)
+}
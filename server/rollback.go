@@ -0,0 +1,59 @@
+// Copyright (C) 2021-2024, Lux Partners Limited. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+
+	"github.com/luxdefi/netrunner/utils"
+	"golang.org/x/exp/maps"
+)
+
+const rollbackNodePath = "/v1/control/rollbacknode"
+
+type rollbackNodeRequest struct {
+	NodeName string `json:"nodeName"`
+}
+
+// POST {"nodeName": "node1"} restarts the node with the config it had
+// before its last restart, returning the cluster info.
+func (s *server) handleRollbackNode(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !s.authenticateHTTP(w, r) {
+		return
+	}
+	req := rollbackNodeRequest{}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.network == nil {
+		http.Error(w, ErrNotBootstrapped.Error(), http.StatusServiceUnavailable)
+		return
+	}
+	s.log.Debug("RollbackNode", utils.NodeField(req.NodeName))
+
+	if err := s.network.nw.RollbackNode(r.Context(), req.NodeName); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if err := s.network.UpdateNodeInfo(); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	s.clusterInfo.NodeNames = maps.Keys(s.network.nodeInfos)
+	sort.Strings(s.clusterInfo.NodeNames)
+	s.clusterInfo.NodeInfos = s.network.nodeInfos
+
+	writeJSON(w, s.clusterInfo)
+}
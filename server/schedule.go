@@ -0,0 +1,314 @@
+// Copyright (C) 2021-2024, Lux Partners Limited. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/luxdefi/netrunner/network/node"
+	"github.com/luxdefi/netrunner/rpcpb"
+	"go.uber.org/zap"
+)
+
+const (
+	schedulesPath = "/v1/control/schedules"
+	// prefix of the snapshots saved by scheduled operations
+	scheduledSnapshotPrefix = "scheduled-"
+	// layout of the daily schedules
+	dailyTimeLayout = "15:04"
+)
+
+// Operations that can be scheduled
+const (
+	ScheduledRestartNode  = "restart-node"
+	ScheduledPauseNode    = "pause-node"
+	ScheduledResumeNode   = "resume-node"
+	ScheduledSaveSnapshot = "save-snapshot"
+	ScheduledStop         = "stop"
+)
+
+var (
+	ErrScheduleNotFound = errors.New("scheduled operation not found")
+	ErrInvalidSchedule  = errors.New("invalid scheduled operation")
+)
+
+// ScheduledOperation is an operation on the network of the tenant that
+// registered it, run at a wall-clock time. It is run once at [At] if it
+// is a timestamp (e.g. "2024-01-02T15:04:05Z"), every day at [At] if it is
+// a local time of day (e.g. "02:00"), or every [Every] (e.g. "1h").
+// The save-snapshot operation keeps the network running from its snapshot.
+type ScheduledOperation struct {
+	ID        string     `json:"id"`
+	Operation string     `json:"operation"`
+	NodeName  string     `json:"nodeName,omitempty"`
+	At        string     `json:"at,omitempty"`
+	Every     string     `json:"every,omitempty"`
+	NextRun   *time.Time `json:"nextRun,omitempty"`
+	LastRun   *time.Time `json:"lastRun,omitempty"`
+	LastError string     `json:"lastError,omitempty"`
+	Runs      int        `json:"runs"`
+
+	tenant string
+	timer  *time.Timer
+	// parsed schedule, with either [once], [daily] or [every] set
+	once  time.Time
+	daily *time.Time
+	every time.Duration
+}
+
+func (op *ScheduledOperation) parse() error {
+	switch op.Operation {
+	case ScheduledRestartNode, ScheduledPauseNode, ScheduledResumeNode:
+		if op.NodeName == "" {
+			return fmt.Errorf("%w: %s needs a node name", ErrInvalidSchedule, op.Operation)
+		}
+	case ScheduledSaveSnapshot, ScheduledStop:
+	default:
+		return fmt.Errorf("%w: unknown operation %q", ErrInvalidSchedule, op.Operation)
+	}
+	if (op.At == "") == (op.Every == "") {
+		return fmt.Errorf("%w: either at or every must be given", ErrInvalidSchedule)
+	}
+	if op.Every != "" {
+		every, err := node.ParseDuration(op.Every)
+		if err != nil || every <= 0 {
+			return fmt.Errorf("%w: invalid every %q", ErrInvalidSchedule, op.Every)
+		}
+		op.every = every
+		return nil
+	}
+	if daily, err := time.ParseInLocation(dailyTimeLayout, op.At, time.Local); err == nil {
+		op.daily = &daily
+		return nil
+	}
+	once, err := time.Parse(time.RFC3339, op.At)
+	if err != nil {
+		return fmt.Errorf("%w: invalid at %q, expected HH:MM or an RFC3339 timestamp", ErrInvalidSchedule, op.At)
+	}
+	op.once = once
+	return nil
+}
+
+// Returns the next time the operation is run after [now],
+// or false if it isn't run anymore
+func (op *ScheduledOperation) nextRun(now time.Time) (time.Time, bool) {
+	switch {
+	case op.every > 0:
+		return now.Add(op.every), true
+	case op.daily != nil:
+		next := time.Date(now.Year(), now.Month(), now.Day(), op.daily.Hour(), op.daily.Minute(), 0, 0, time.Local)
+		if !next.After(now) {
+			next = next.AddDate(0, 0, 1)
+		}
+		return next, true
+	default:
+		return op.once, op.Runs == 0
+	}
+}
+
+// scheduler keeps the scheduled operations, in memory only
+type scheduler struct {
+	lock       sync.Mutex
+	operations map[string]*ScheduledOperation
+	nextID     uint64
+}
+
+func newScheduler() *scheduler {
+	return &scheduler{
+		operations: map[string]*ScheduledOperation{},
+	}
+}
+
+// Returns a copy of the operations of [tenant], by ID
+func (sc *scheduler) list(tenant string) []ScheduledOperation {
+	sc.lock.Lock()
+	defer sc.lock.Unlock()
+
+	ops := []ScheduledOperation{}
+	for _, op := range sc.operations {
+		if op.tenant == tenant {
+			ops = append(ops, *op)
+		}
+	}
+	sort.Slice(ops, func(i, j int) bool {
+		return ops[i].ID < ops[j].ID
+	})
+	return ops
+}
+
+// Registers [op] for [tenant] and schedules its first run
+func (s *server) scheduleOperation(op *ScheduledOperation, tenant string) error {
+	if err := op.parse(); err != nil {
+		return err
+	}
+
+	s.schedules.lock.Lock()
+	defer s.schedules.lock.Unlock()
+
+	s.schedules.nextID++
+	op.ID = fmt.Sprintf("%04d", s.schedules.nextID)
+	op.tenant = tenant
+	op.NextRun, op.LastRun, op.LastError, op.Runs = nil, nil, "", 0
+	if !s.armScheduledOperation(op) {
+		return fmt.Errorf("%w: %q is in the past", ErrInvalidSchedule, op.At)
+	}
+	s.schedules.operations[op.ID] = op
+	s.log.Info("operation scheduled",
+		zap.String("id", op.ID),
+		zap.String("operation", op.Operation),
+		zap.Time("next-run", *op.NextRun),
+	)
+	return nil
+}
+
+// Sets the timer of the next run of [op], returning
+// false if it isn't run anymore.
+// Assumes [s.schedules.lock] is held.
+func (s *server) armScheduledOperation(op *ScheduledOperation) bool {
+	now := time.Now()
+	next, ok := op.nextRun(now)
+	if !ok || next.Before(now) {
+		op.NextRun = nil
+		return false
+	}
+	op.NextRun = &next
+	id := op.ID
+	op.timer = time.AfterFunc(next.Sub(now), func() {
+		s.runScheduledOperation(id)
+	})
+	return true
+}
+
+// Removes the operation [id] of [tenant]
+func (s *server) unscheduleOperation(id string, tenant string) error {
+	s.schedules.lock.Lock()
+	defer s.schedules.lock.Unlock()
+
+	op, ok := s.schedules.operations[id]
+	if !ok || op.tenant != tenant {
+		return fmt.Errorf("%w: %q", ErrScheduleNotFound, id)
+	}
+	if op.timer != nil {
+		op.timer.Stop()
+	}
+	delete(s.schedules.operations, id)
+	return nil
+}
+
+// Runs the operation [id], records its outcome and schedules its next run.
+// Operations run once stay listed, with their outcome, until removed.
+// Operations are not run anymore once the server is closed.
+func (s *server) runScheduledOperation(id string) {
+	if s.rootCtx.Err() != nil {
+		return
+	}
+	s.schedules.lock.Lock()
+	op, ok := s.schedules.operations[id]
+	if !ok {
+		s.schedules.lock.Unlock()
+		return
+	}
+	operation, nodeName, tenant := op.Operation, op.NodeName, op.tenant
+	s.schedules.lock.Unlock()
+
+	s.log.Info("running scheduled operation", zap.String("id", id), zap.String("operation", operation))
+	err := s.runOperation(operation, nodeName, tenant)
+	if err != nil {
+		s.log.Warn("scheduled operation failed", zap.String("id", id), zap.String("operation", operation), zap.Error(err))
+	}
+
+	s.schedules.lock.Lock()
+	defer s.schedules.lock.Unlock()
+
+	// unscheduled while running
+	if s.schedules.operations[id] != op {
+		return
+	}
+	now := time.Now()
+	op.LastRun = &now
+	op.LastError = ""
+	if err != nil {
+		op.LastError = err.Error()
+	}
+	op.Runs++
+	s.armScheduledOperation(op)
+}
+
+// Runs [operation] on the network of [tenant]
+func (s *server) runOperation(operation string, nodeName string, tenant string) error {
+	if err := s.checkTenantAccess(tenant); err != nil {
+		return err
+	}
+	fullMethod := ""
+	if operation == ScheduledStop {
+		fullMethod = stopMethod
+	}
+	s.resumeNetwork(tenant, fullMethod)
+	ctx := context.WithValue(s.rootCtx, tenantCtxKey{}, tenant)
+	var err error
+	switch operation {
+	case ScheduledRestartNode:
+		_, err = s.RestartNode(ctx, &rpcpb.RestartNodeRequest{Name: nodeName})
+	case ScheduledPauseNode:
+		_, err = s.PauseNode(ctx, &rpcpb.PauseNodeRequest{Name: nodeName})
+	case ScheduledResumeNode:
+		_, err = s.ResumeNode(ctx, &rpcpb.ResumeNodeRequest{Name: nodeName})
+	case ScheduledSaveSnapshot:
+		snapshotName := scheduledSnapshotPrefix + time.Now().Format(shutdownSnapshotTimeFormat)
+		if _, err = s.SaveSnapshot(ctx, &rpcpb.SaveSnapshotRequest{SnapshotName: snapshotName}); err == nil {
+			_, err = s.LoadSnapshot(ctx, &rpcpb.LoadSnapshotRequest{SnapshotName: snapshotName})
+		}
+	case ScheduledStop:
+		_, err = s.Stop(ctx, &rpcpb.StopRequest{})
+	}
+	return err
+}
+
+// GET lists the scheduled operations, POST {"operation": "restart-node",
+// "nodeName": "node1", "at": "02:00"} schedules one, and DELETE ?id=0001
+// removes one
+func (s *server) handleSchedules(w http.ResponseWriter, r *http.Request) {
+	tenant, ok := s.httpTenant(w, r)
+	if !ok {
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+		writeJSON(w, s.schedules.list(tenant))
+	case http.MethodPost:
+		op := &ScheduledOperation{}
+		if err := json.NewDecoder(r.Body).Decode(op); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := s.scheduleOperation(op, tenant); err != nil {
+			status := http.StatusInternalServerError
+			if errors.Is(err, ErrInvalidSchedule) {
+				status = http.StatusBadRequest
+			}
+			http.Error(w, err.Error(), status)
+			return
+		}
+		s.schedules.lock.Lock()
+		scheduled := *op
+		s.schedules.lock.Unlock()
+		writeJSON(w, scheduled)
+	case http.MethodDelete:
+		id := r.URL.Query().Get("id")
+		if err := s.unscheduleOperation(id, tenant); err != nil {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		writeJSON(w, map[string]string{"id": id})
+	default:
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+	}
+}
@@ -0,0 +1,157 @@
+// Copyright (C) 2021-2024, Lux Partners Limited. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package server
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestScheduledOperationParse(t *testing.T) {
+	tests := []struct {
+		name          string
+		op            ScheduledOperation
+		expectedErr   error
+		expectedEvery time.Duration
+		expectedDaily string
+		expectedOnce  time.Time
+	}{
+		{
+			name:          "every",
+			op:            ScheduledOperation{Operation: ScheduledSaveSnapshot, Every: "1h30m"},
+			expectedEvery: 90 * time.Minute,
+		},
+		{
+			name:          "daily",
+			op:            ScheduledOperation{Operation: ScheduledRestartNode, NodeName: "node1", At: "02:00"},
+			expectedDaily: "02:00",
+		},
+		{
+			name:         "once",
+			op:           ScheduledOperation{Operation: ScheduledStop, At: "2024-01-02T15:04:05Z"},
+			expectedOnce: time.Date(2024, 1, 2, 15, 4, 5, 0, time.UTC),
+		},
+		{
+			name:        "missing node name",
+			op:          ScheduledOperation{Operation: ScheduledPauseNode, Every: "1h"},
+			expectedErr: ErrInvalidSchedule,
+		},
+		{
+			name:        "unknown operation",
+			op:          ScheduledOperation{Operation: "reboot", Every: "1h"},
+			expectedErr: ErrInvalidSchedule,
+		},
+		{
+			name:        "at and every",
+			op:          ScheduledOperation{Operation: ScheduledStop, At: "02:00", Every: "1h"},
+			expectedErr: ErrInvalidSchedule,
+		},
+		{
+			name:        "no at nor every",
+			op:          ScheduledOperation{Operation: ScheduledStop},
+			expectedErr: ErrInvalidSchedule,
+		},
+		{
+			name:        "invalid every",
+			op:          ScheduledOperation{Operation: ScheduledStop, Every: "often"},
+			expectedErr: ErrInvalidSchedule,
+		},
+		{
+			name:        "non positive every",
+			op:          ScheduledOperation{Operation: ScheduledStop, Every: "0s"},
+			expectedErr: ErrInvalidSchedule,
+		},
+		{
+			name:        "invalid time of day",
+			op:          ScheduledOperation{Operation: ScheduledStop, At: "25:00"},
+			expectedErr: ErrInvalidSchedule,
+		},
+		{
+			name:        "invalid timestamp",
+			op:          ScheduledOperation{Operation: ScheduledStop, At: "tomorrow"},
+			expectedErr: ErrInvalidSchedule,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require := require.New(t)
+			op := tt.op
+			err := op.parse()
+			require.ErrorIs(err, tt.expectedErr)
+			if tt.expectedErr != nil {
+				return
+			}
+			require.Equal(tt.expectedEvery, op.every)
+			require.True(tt.expectedOnce.Equal(op.once))
+			if tt.expectedDaily == "" {
+				require.Nil(op.daily)
+			} else {
+				require.NotNil(op.daily)
+				require.Equal(tt.expectedDaily, op.daily.Format(dailyTimeLayout))
+			}
+		})
+	}
+}
+
+func TestScheduledOperationNextRun(t *testing.T) {
+	now := time.Date(2024, 1, 2, 10, 30, 0, 0, time.Local)
+	once := time.Date(2024, 1, 5, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name         string
+		op           ScheduledOperation
+		expectedNext time.Time
+		expectedOk   bool
+	}{
+		{
+			name:         "every",
+			op:           ScheduledOperation{Every: "1h"},
+			expectedNext: now.Add(time.Hour),
+			expectedOk:   true,
+		},
+		{
+			name:         "daily, later today",
+			op:           ScheduledOperation{At: "18:00"},
+			expectedNext: time.Date(2024, 1, 2, 18, 0, 0, 0, time.Local),
+			expectedOk:   true,
+		},
+		{
+			name:         "daily, already passed today",
+			op:           ScheduledOperation{At: "02:00"},
+			expectedNext: time.Date(2024, 1, 3, 2, 0, 0, 0, time.Local),
+			expectedOk:   true,
+		},
+		{
+			name:         "daily, now",
+			op:           ScheduledOperation{At: "10:30"},
+			expectedNext: time.Date(2024, 1, 3, 10, 30, 0, 0, time.Local),
+			expectedOk:   true,
+		},
+		{
+			name:         "once, not run",
+			op:           ScheduledOperation{At: once.Format(time.RFC3339)},
+			expectedNext: once,
+			expectedOk:   true,
+		},
+		{
+			name:         "once, run",
+			op:           ScheduledOperation{At: once.Format(time.RFC3339), Runs: 1},
+			expectedNext: once,
+			expectedOk:   false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require := require.New(t)
+			op := tt.op
+			op.Operation = ScheduledStop
+			require.NoError(op.parse())
+			next, ok := op.nextRun(now)
+			require.Equal(tt.expectedOk, ok)
+			require.True(tt.expectedNext.Equal(next), "expected %s, got %s", tt.expectedNext, next)
+		})
+	}
+}
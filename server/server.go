@@ -5,6 +5,7 @@
 package server
 
 import (
+	"bufio"
 	"context"
 	"encoding/json"
 	"errors"
@@ -21,11 +22,16 @@ import (
 
 	"go.uber.org/multierr"
 
+	"github.com/luxdefi/netrunner/expect"
+	"github.com/luxdefi/netrunner/expose"
+	"github.com/luxdefi/netrunner/metrics"
 	"github.com/luxdefi/netrunner/network"
 	"github.com/luxdefi/netrunner/network/node"
+	"github.com/luxdefi/netrunner/report"
 	"github.com/luxdefi/netrunner/rpcpb"
 	"github.com/luxdefi/netrunner/utils"
 	"github.com/luxdefi/netrunner/utils/constants"
+	"github.com/luxdefi/netrunner/utils/failpoint"
 	"github.com/luxdefi/node/config"
 	"github.com/luxdefi/node/message"
 	"github.com/luxdefi/node/snow/networking/router"
@@ -51,6 +57,13 @@ const (
 	defaultStartTimeout   = 5 * time.Minute
 	waitForHealthyTimeout = 3 * time.Minute
 
+	// max size of a streamed node log line
+	maxLogLineSize = 1024 * 1024
+
+	shutdownSnapshotPrefix     = "shutdown-"
+	shutdownSnapshotTimeFormat = "20060102-150405"
+	shutdownSnapshotTimeout    = 2 * time.Minute
+
 	networkRootDirPrefix   = "network"
 	TimeParseLayout        = "2006-01-02 15:04:05"
 	StakingMinimumLeadTime = 25 * time.Second
@@ -59,6 +72,8 @@ const (
 var (
 	ErrInvalidVMName          = errors.New("invalid VM name")
 	ErrInvalidPort            = errors.New("invalid port")
+	ErrInvalidShutdownMode    = errors.New("invalid shutdown mode")
+	ErrInvalidIdleAction      = errors.New("invalid idle action")
 	ErrNotEnoughNodesForStart = errors.New("not enough nodes specified for start")
 	ErrAlreadyBootstrapped    = errors.New("already bootstrapped")
 	ErrNotBootstrapped        = errors.New("not bootstrapped")
@@ -80,10 +95,95 @@ type Config struct {
 	RedirectNodesOutput bool
 	SnapshotsDir        string
 	LogLevel            logging.Level
+	// resource limits applied to the network
+	Quota Quota
+	// Map from tenant token to tenant ID. If not empty, control calls
+	// must carry a tenant token, and tenants only see their own network.
+	TenantTokens map[string]string
+	// Tenants allowed to garbage collect the network root dirs
+	// of every tenant. Others only access their own.
+	AdminTenants []string
+	// Directory where network templates are persisted.
+	// If empty, templates are kept in memory only.
+	TemplatesDir string
+	// If not empty, file where the networks of the server are persisted,
+	// so that a restarted server lists the previous ones, adopts the ones
+	// with nodes still running as detached, and drops the dead ones,
+	// removing their root dirs
+	RegistryFile string
+	// If not zero, orphaned network root dirs not modified within
+	// this duration are periodically deleted
+	GCRetention time.Duration
+	// If not empty, port of a reverse proxy serving the API of every
+	// node under /<node name>/ (e.g. /node1/ext/bc/C/rpc)
+	APIProxyPort string
+	// If true, the requests served by the API proxy are logged and
+	// the last ones are kept, for GET /v1/control/apitrace
+	APIProxyTrace bool
+	// If true, the server is advertised over mDNS for local discovery
+	MDNSEnabled bool
+	// If not empty, base dirs of the node dbs, logs and staking files
+	// of started networks. See network.Config.
+	DBRootDir   string
+	LogsRootDir string
+	KeysRootDir string
+	// If not empty, executable run with the path of the port map file of
+	// the network whenever the node ports change, e.g. to update the
+	// host firewall rules. See PortMap.
+	PortMapHook string
+	// What to do with the running network when the server is closed.
+	// Defaults to ShutdownStop.
+	ShutdownMode ShutdownMode
+	// If not zero, lifetime of the created networks, which are stopped
+	// once it elapses, unless given another one on creation
+	NetworkTTL time.Duration
+	// If true, the networks are snapshotted when their TTL expires
+	NetworkTTLSnapshot bool
+	// If not zero, the network is suspended once it had no control call,
+	// API proxy traffic or node CPU load for this duration, and resumed
+	// on the next control call
+	IdleTimeout time.Duration
+	// How the idle network is suspended. Defaults to IdleFreeze.
+	IdleAction IdleAction
+	// Node CPU usage in percent, over all the nodes, above which the
+	// network is not idle. Defaults to 5.
+	IdleMaxCPUPercent float64
+	// If not empty, git repo whose spec file the network is kept in sync
+	// with, starting it and adding, removing and restarting its nodes
+	// whenever the branch changes. See GitSyncSpec.
+	GitSyncRepo string
+	// Branch and spec file of [GitSyncRepo].
+	// Default to main and network.json.
+	GitSyncBranch string
+	GitSyncFile   string
+	// How often [GitSyncRepo] is fetched. Defaults to 30s.
+	GitSyncInterval time.Duration
+	// If not zero, the node metrics are scraped at this interval, and
+	// served aggregated, labeled by node name, at GET /metrics
+	MetricsScrapeInterval time.Duration
+	// Max size in bytes of the files transferred to and from the
+	// node dirs with PutFile and GetFile. Defaults to 512 MiB.
+	MaxFileSize int64
 }
 
+// ShutdownMode is what the server does with the running network when closed
+type ShutdownMode string
+
+const (
+	// Stops the network
+	ShutdownStop ShutdownMode = "stop"
+	// Saves a snapshot of the network, which stops it
+	ShutdownSnapshot ShutdownMode = "snapshot"
+	// Leaves the nodes running, so the network outlives the server
+	ShutdownDetach ShutdownMode = "detach"
+)
+
 type Server interface {
 	Run(rootCtx context.Context) error
+	// Kill makes the network stop on close kill the node processes
+	// and their descendants instead of waiting for them to exit.
+	// Used to force the shutdown on a second interrupt.
+	Kill()
 }
 
 type server struct {
@@ -95,6 +195,9 @@ type server struct {
 	rootCtx    context.Context
 	rootCancel context.CancelFunc
 	closed     chan struct{}
+	// cancelled to kill the node processes on network stop
+	killCtx    context.Context
+	killCancel context.CancelFunc
 
 	ln         net.Listener
 	gRPCServer *grpc.Server
@@ -102,12 +205,38 @@ type server struct {
 	gwMux    *runtime.ServeMux
 	gwServer *http.Server
 
+	apiProxyServer *http.Server
+	// traces the requests of the API proxy, if enabled
+	apiTracer *expose.Tracer
+
 	clusterInfo *rpcpb.ClusterInfo
 	// Controls running nodes.
 	// Invariant: If [network] is non-nil, then [clusterInfo] is non-nil.
 
 	network    *localNetwork
 	asyncErrCh chan error
+	// tenant that created [network], if tenancy is enabled
+	networkTenant string
+	// lifetime of [network], if any
+	ttl *networkTTL
+	// last activity on [network]
+	idle idleTracker
+	// serializes the resumes of the suspended network
+	resumeLock sync.Mutex
+	// non-nil if the network is suspended while idle
+	suspended *suspension
+
+	templates *templateRegistry
+	// networks of the server, including the previous ones
+	registry *networkRegistry
+	// operations run at a wall-clock time
+	schedules *scheduler
+	// assertions evaluated on the run events, and the run report
+	expectations *expect.Engine
+	// syncs the network with a git repo, if enabled
+	gitSync *gitSyncer
+	// scrapes the node metrics, if enabled
+	metricsScraper *metrics.Scraper
 
 	rpcpb.UnimplementedPingServiceServer
 	rpcpb.UnimplementedControlServiceServer
@@ -127,6 +256,48 @@ func New(cfg Config, log logging.Logger) (Server, error) {
 	if cfg.Port == "" || cfg.GwPort == "" {
 		return nil, ErrInvalidPort
 	}
+	switch cfg.ShutdownMode {
+	case "":
+		cfg.ShutdownMode = ShutdownStop
+	case ShutdownStop, ShutdownSnapshot, ShutdownDetach:
+	default:
+		return nil, fmt.Errorf("%w %q", ErrInvalidShutdownMode, cfg.ShutdownMode)
+	}
+	switch cfg.IdleAction {
+	case "":
+		cfg.IdleAction = IdleFreeze
+	case IdleFreeze, IdleSnapshot:
+	default:
+		return nil, fmt.Errorf("%w %q", ErrInvalidIdleAction, cfg.IdleAction)
+	}
+	if cfg.IdleMaxCPUPercent == 0 {
+		cfg.IdleMaxCPUPercent = defaultIdleMaxCPUPercent
+	}
+
+	templates, err := newTemplateRegistry(cfg.TemplatesDir)
+	if err != nil {
+		return nil, err
+	}
+
+	registry, deadNetworks, err := newNetworkRegistry(cfg.RegistryFile)
+	if err != nil {
+		return nil, err
+	}
+	for _, n := range deadNetworks {
+		log.Info("dropped network with no node running from registry", zap.String("root-data-dir", n.RootDataDir))
+	}
+	removedDirs, err := removeDeadRootDirs(deadNetworks)
+	if err != nil {
+		log.Warn("couldn't remove root dirs of dead networks", zap.Error(err))
+	}
+	for _, dir := range removedDirs {
+		log.Info("removed root dir of dead network", zap.String("path", dir.Path), zap.Uint64("size", dir.Size))
+	}
+	for _, n := range registry.networks {
+		if n.Status == RegistryDetached {
+			log.Info("adopted detached network with nodes running", zap.String("root-data-dir", n.RootDataDir), zap.Int("num-nodes", len(n.Nodes)))
+		}
+	}
 
 	listener, err := net.Listen("tcp", cfg.Port)
 	if err != nil {
@@ -134,19 +305,42 @@ func New(cfg Config, log logging.Logger) (Server, error) {
 	}
 
 	s := &server{
-		cfg:        cfg,
-		log:        log,
-		closed:     make(chan struct{}),
-		ln:         listener,
-		gRPCServer: grpc.NewServer(),
-		mu:         new(sync.RWMutex),
-		asyncErrCh: make(chan error, 1),
-	}
+		cfg:          cfg,
+		log:          log,
+		closed:       make(chan struct{}),
+		ln:           listener,
+		mu:           new(sync.RWMutex),
+		asyncErrCh:   make(chan error, 1),
+		templates:    templates,
+		registry:     registry,
+		schedules:    newScheduler(),
+		expectations: expect.NewEngine(report.New("netrunner")),
+	}
+	if cfg.APIProxyPort != "" && cfg.APIProxyTrace {
+		s.apiTracer = expose.NewTracer(maxAPITraces)
+	}
+	if cfg.GitSyncRepo != "" {
+		s.gitSync = newGitSyncer(cfg)
+	}
+	if cfg.MetricsScrapeInterval > 0 {
+		s.metricsScraper = metrics.NewScraper(log, s.getMetricsTargets)
+	}
+	s.killCtx, s.killCancel = context.WithCancel(context.Background())
+	s.gRPCServer = grpc.NewServer(
+		grpc.UnaryInterceptor(s.tenancyUnaryInterceptor),
+		grpc.StreamInterceptor(s.tenancyStreamInterceptor),
+	)
 	if !cfg.GwDisabled {
 		s.gwMux = runtime.NewServeMux()
 		s.gwServer = &http.Server{ //nolint // TODO add ReadHeaderTimeout
 			Addr:    cfg.GwPort,
-			Handler: s.gwMux,
+			Handler: s.newIdleHandler(s.newGatewayHandler()),
+		}
+	}
+	if cfg.APIProxyPort != "" {
+		s.apiProxyServer = &http.Server{ //nolint // TODO add ReadHeaderTimeout
+			Addr:    cfg.APIProxyPort,
+			Handler: s.newIdleHandler(s.newAPIProxyHandler()),
 		}
 	}
 
@@ -160,6 +354,37 @@ func (s *server) Run(rootCtx context.Context) (err error) {
 	rpcpb.RegisterPingServiceServer(s.gRPCServer, s)
 	rpcpb.RegisterControlServiceServer(s.gRPCServer, s)
 
+	if s.cfg.GCRetention > 0 {
+		go s.runJanitor(s.rootCtx)
+	}
+
+	if s.cfg.IdleTimeout > 0 {
+		go s.runIdleMonitor(s.rootCtx)
+	}
+
+	if s.gitSync != nil {
+		go s.runGitSync(s.rootCtx)
+	}
+
+	if s.metricsScraper != nil {
+		go s.runMetricsScraper(s.rootCtx)
+	}
+
+	if s.cfg.MDNSEnabled {
+		if stopMDNS, err := s.advertiseMDNS(); err != nil {
+			s.log.Warn("server not advertised over mDNS", zap.Error(err))
+		} else {
+			defer stopMDNS()
+		}
+	}
+
+	if s.apiProxyServer != nil {
+		go s.runAPIProxy()
+		defer func() {
+			s.log.Warn("closed node API proxy", zap.Error(s.apiProxyServer.Close()))
+		}()
+	}
+
 	gRPCErrChan := make(chan error)
 	go func() {
 		s.log.Info("serving gRPC server", zap.String("port", s.cfg.Port))
@@ -237,15 +462,58 @@ func (s *server) Run(rootCtx context.Context) (err error) {
 	defer s.mu.Unlock()
 
 	if s.network != nil {
-		// Close the network.
-		s.stopAndRemoveNetwork(nil)
-		s.log.Warn("network stopped")
+		s.shutdownNetwork()
 	}
 
 	s.rootCancel()
 	return err
 }
 
+// See Server
+func (s *server) Kill() {
+	s.killCancel()
+}
+
+// Handles the network on server close, according to [s.cfg.ShutdownMode].
+// Assumes [s.mu] is held.
+func (s *server) shutdownNetwork() {
+	switch s.cfg.ShutdownMode {
+	case ShutdownDetach:
+		s.log.Warn("network detached, nodes left running", zap.String("root-data-dir", s.clusterInfo.RootDataDir))
+		if s.suspended != nil {
+			s.unfreezeNodes(s.suspended.frozenNodes)
+			s.suspended = nil
+		}
+		s.registerNetwork(RegistryDetached)
+		s.clearNetworkTTL()
+		s.network = nil
+		return
+	case ShutdownSnapshot:
+		s.saveTimestampedSnapshot(shutdownSnapshotPrefix)
+	}
+	// Close the network.
+	s.stopAndRemoveNetwork(nil)
+	s.log.Warn("network stopped")
+}
+
+// Saves a snapshot of the network named after [prefix] and the current
+// time, which stops it. Failures are logged, as the network is stopped
+// anyway by the caller. Returns the snapshot name, or the empty string
+// if it couldn't be saved.
+// Assumes [s.mu] is held.
+func (s *server) saveTimestampedSnapshot(prefix string) string {
+	snapshotName := prefix + time.Now().Format(shutdownSnapshotTimeFormat)
+	ctx, cancel := context.WithTimeout(s.killCtx, shutdownSnapshotTimeout)
+	snapshotPath, err := s.network.nw.SaveSnapshot(ctx, snapshotName)
+	cancel()
+	if err != nil {
+		s.log.Warn("couldn't save network snapshot, stopping it", zap.Error(err))
+		return ""
+	}
+	s.log.Warn("network snapshot saved", zap.String("snapshot-path", snapshotPath))
+	return snapshotName
+}
+
 func (s *server) Ping(context.Context, *rpcpb.PingRequest) (*rpcpb.PingResponse, error) {
 	s.log.Debug("received ping request")
 	return &rpcpb.PingResponse{Pid: int32(os.Getpid())}, nil
@@ -257,7 +525,7 @@ func (s *server) RPCVersion(context.Context, *rpcpb.RPCVersionRequest) (*rpcpb.R
 	return &rpcpb.RPCVersionResponse{Version: RPCVersion}, nil
 }
 
-func (s *server) Start(_ context.Context, req *rpcpb.StartRequest) (*rpcpb.StartResponse, error) {
+func (s *server) Start(ctx context.Context, req *rpcpb.StartRequest) (*rpcpb.StartResponse, error) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
@@ -279,6 +547,11 @@ func (s *server) Start(_ context.Context, req *rpcpb.StartRequest) (*rpcpb.Start
 		return nil, err
 	}
 
+	ttl, ttlSnapshot, err := s.networkTTLFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
 	pluginDir := req.GetPluginDir()
 	chainSpecs := []network.BlockchainSpec{}
 	if len(req.GetBlockchainSpecs()) > 0 {
@@ -300,11 +573,10 @@ func (s *server) Start(_ context.Context, req *rpcpb.StartRequest) (*rpcpb.Start
 		pid               = int32(os.Getpid())
 		globalNodeConfig  = req.GetGlobalNodeConfig()
 		customNodeConfigs = req.GetCustomNodeConfigs()
-		err               error
 	)
 
 	if len(rootDataDir) == 0 {
-		rootDataDir = filepath.Join(os.TempDir(), constants.RootDirPrefix)
+		rootDataDir = tenantRootDataDir(filepath.Join(os.TempDir(), constants.RootDirPrefix), tenantFromContext(ctx))
 		err = os.MkdirAll(rootDataDir, os.ModePerm)
 		if err != nil {
 			return nil, err
@@ -321,6 +593,10 @@ func (s *server) Start(_ context.Context, req *rpcpb.StartRequest) (*rpcpb.Start
 		numNodes = uint32(len(customNodeConfigs))
 	}
 
+	if err := s.cfg.Quota.checkNodes(numNodes); err != nil {
+		return nil, err
+	}
+
 	s.clusterInfo = &rpcpb.ClusterInfo{
 		Pid:         pid,
 		RootDataDir: rootDataDir,
@@ -342,10 +618,18 @@ func (s *server) Start(_ context.Context, req *rpcpb.StartRequest) (*rpcpb.Start
 		reassignPortsIfUsed: req.GetReassignPortsIfUsed(),
 		dynamicPorts:        req.GetDynamicPorts(),
 		snapshotsDir:        s.cfg.SnapshotsDir,
+		diskBudget:          s.cfg.Quota.MaxDiskBytes,
+		labels:              req.Labels,
+		dbRootDir:           s.cfg.DBRootDir,
+		logsRootDir:         s.cfg.LogsRootDir,
+		keysRootDir:         s.cfg.KeysRootDir,
+		portMapHook:         s.cfg.PortMapHook,
 	})
 	if err != nil {
 		return nil, err
 	}
+	s.networkTenant = tenantFromContext(ctx)
+	s.setNetworkTTL(ttl, ttlSnapshot)
 
 	s.log.Info("starting",
 		zap.String("exec-path", execPath),
@@ -358,9 +642,9 @@ func (s *server) Start(_ context.Context, req *rpcpb.StartRequest) (*rpcpb.Start
 		zap.String("global-node-config", globalNodeConfig),
 	)
 
-	ctx, cancel := context.WithTimeout(context.Background(), waitForHealthyTimeout)
+	startCtx, cancel := context.WithTimeout(context.Background(), waitForHealthyTimeout)
 	defer cancel()
-	if err := s.network.Start(ctx); err != nil {
+	if err := s.network.Start(startCtx); err != nil {
 		s.log.Warn("start failed to complete", zap.Error(err))
 		s.stopAndRemoveNetwork(nil)
 		return nil, err
@@ -405,6 +689,8 @@ func (s *server) updateClusterInfo() {
 		s.clusterInfo.CustomChains[chainID.String()] = chainInfo.info
 	}
 	s.clusterInfo.Subnets = s.network.subnets
+	s.clusterInfo.Labels = s.network.labels
+	s.registerNetwork(RegistryRunning)
 }
 
 // wait until some of this conditions is met:
@@ -826,15 +1112,20 @@ func (s *server) stopAndRemoveNetwork(err error) {
 		s.asyncErrCh <- err
 	}
 	if s.network != nil {
-		ctx, cancel := context.WithTimeout(context.Background(), stopTimeout)
+		failpoint.Inject(failpoint.BeforeStop)
+		ctx, cancel := context.WithTimeout(s.killCtx, stopTimeout)
 		defer cancel()
 		s.network.Stop(ctx)
+		s.unregisterNetwork()
 	}
 	if s.clusterInfo != nil {
 		s.clusterInfo.Healthy = false
 		s.clusterInfo.CustomChainsHealthy = false
 	}
+	s.clearNetworkTTL()
+	s.suspended = nil
 	s.network = nil
+	s.networkTenant = ""
 }
 
 // TODO document this
@@ -881,6 +1172,41 @@ func (s *server) StreamStatus(req *rpcpb.StreamStatusRequest, stream rpcpb.Contr
 	return err
 }
 
+// StreamLogs sends the lines of the log of a node, from its start.
+// If following, the lines written later are sent as they come,
+// until the client cancels.
+func (s *server) StreamLogs(req *rpcpb.StreamLogsRequest, stream rpcpb.ControlService_StreamLogsServer) error {
+	s.log.Debug("StreamLogs", utils.NodeField(req.Name), zap.String("log-name", req.LogName), zap.Bool("follow", req.Follow))
+
+	s.mu.RLock()
+	if s.network == nil {
+		s.mu.RUnlock()
+		return ErrNotBootstrapped
+	}
+	node, err := s.network.nw.GetNode(req.Name)
+	s.mu.RUnlock()
+	if err != nil {
+		return err
+	}
+
+	logs, err := node.StreamLogs(stream.Context(), req.LogName, req.Follow)
+	if err != nil {
+		return err
+	}
+	defer logs.Close()
+	scanner := bufio.NewScanner(logs)
+	scanner.Buffer(make([]byte, 0, bufio.MaxScanTokenSize), maxLogLineSize)
+	for scanner.Scan() {
+		if err := stream.Send(&rpcpb.StreamLogsResponse{Line: scanner.Text()}); err != nil {
+			if isClientCanceled(stream.Context().Err(), err) {
+				return nil
+			}
+			return err
+		}
+	}
+	return scanner.Err()
+}
+
 // TODO document this
 func (s *server) sendLoop(stream rpcpb.ControlService_StreamStatusServer, interval time.Duration) {
 	s.log.Info("start status send loop")
@@ -936,11 +1262,11 @@ func (s *server) recvLoop(stream rpcpb.ControlService_StreamStatusServer) error
 	}
 }
 
-func (s *server) AddNode(_ context.Context, req *rpcpb.AddNodeRequest) (*rpcpb.AddNodeResponse, error) {
+func (s *server) AddNode(ctx context.Context, req *rpcpb.AddNodeRequest) (*rpcpb.AddNodeResponse, error) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	s.log.Debug("AddNode", zap.String("name", req.Name))
+	s.log.Debug("AddNode", utils.NodeField(req.Name))
 
 	if s.network == nil {
 		return nil, ErrNotBootstrapped
@@ -957,6 +1283,49 @@ func (s *server) AddNode(_ context.Context, req *rpcpb.AddNodeRequest) (*rpcpb.A
 		nodeFlags[config.PluginDirKey] = req.GetPluginDir()
 	}
 
+	// the role is not a node flag
+	nodeRole := ""
+	if v, ok := nodeFlags[node.RoleConfigKey]; ok {
+		nodeRole, ok = v.(string)
+		if !ok {
+			return nil, fmt.Errorf("invalid %q value %v", node.RoleConfigKey, v)
+		}
+		delete(nodeFlags, node.RoleConfigKey)
+	}
+	var primaryValidator *node.PrimaryValidatorConfig
+	if req.PrimaryValidator != nil {
+		cfg := newPrimaryValidatorConfig(req.PrimaryValidator)
+		if err := cfg.Validate(); err != nil {
+			return nil, err
+		}
+		primaryValidator = &cfg
+	}
+	var features map[string]bool
+	if v, ok := nodeFlags[node.FeaturesConfigKey]; ok {
+		var err error
+		features, err = node.ParseFeatures(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %q value %v: %w", node.FeaturesConfigKey, v, err)
+		}
+		delete(nodeFlags, node.FeaturesConfigKey)
+	}
+	var resources *node.ResourceLimits
+	if v, ok := nodeFlags[node.ResourcesConfigKey]; ok {
+		var err error
+		resources, err = node.ParseResourceLimits(v)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %q value %v: %w", node.ResourcesConfigKey, v, err)
+		}
+		delete(nodeFlags, node.ResourcesConfigKey)
+	}
+
+	if err := s.cfg.Quota.checkNodes(uint32(len(s.network.nodeInfos)) + 1); err != nil {
+		return nil, err
+	}
+	if err := s.cfg.Quota.checkDisk(s.clusterInfo.RootDataDir); err != nil {
+		return nil, err
+	}
+
 	nodeConfig := node.Config{
 		Name:               req.Name,
 		Flags:              nodeFlags,
@@ -966,11 +1335,23 @@ func (s *server) AddNode(_ context.Context, req *rpcpb.AddNodeRequest) (*rpcpb.A
 		ChainConfigFiles:   req.ChainConfigs,
 		UpgradeConfigFiles: req.UpgradeConfigs,
 		SubnetConfigFiles:  req.SubnetConfigs,
+		Role:               nodeRole,
+		Features:           features,
+		Resources:          resources,
+	}
+	// the public IP and latency are not node flags either
+	if err := nodeConfig.TakeLinkConfigKeys(nodeFlags); err != nil {
+		return nil, err
 	}
 
 	if _, err := s.network.nw.AddNode(nodeConfig); err != nil {
 		return nil, err
 	}
+	// registered apart from AddNode, so that canceling the request cancels it
+	var registerErr error
+	if primaryValidator != nil {
+		registerErr = s.network.nw.AddPrimaryValidator(ctx, req.Name, *primaryValidator)
+	}
 
 	if err := s.network.UpdateNodeInfo(); err != nil {
 		return nil, err
@@ -980,6 +1361,11 @@ func (s *server) AddNode(_ context.Context, req *rpcpb.AddNodeRequest) (*rpcpb.A
 	sort.Strings(s.clusterInfo.NodeNames)
 	s.clusterInfo.NodeInfos = s.network.nodeInfos
 
+	if registerErr != nil {
+		// the node is left running
+		return nil, fmt.Errorf("node %q added but not registered as primary network validator: %w", req.Name, registerErr)
+	}
+
 	clusterInfo, err := deepCopy(s.clusterInfo)
 	if err != nil {
 		return nil, err
@@ -991,13 +1377,23 @@ func (s *server) RemoveNode(ctx context.Context, req *rpcpb.RemoveNodeRequest) (
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	s.log.Debug("RemoveNode", zap.String("name", req.Name))
+	s.log.Debug("RemoveNode", utils.NodeField(req.Name), zap.Bool("drain", req.Drain))
 
 	if s.network == nil {
 		return nil, ErrNotBootstrapped
 	}
 
-	if err := s.network.nw.RemoveNode(ctx, req.Name); err != nil {
+	if req.Drain {
+		opts := network.DrainOptions{
+			Timeout:                time.Duration(req.DrainTimeout),
+			RemoveSubnetValidators: req.RemoveSubnetValidators,
+			GracePeriod:            time.Duration(req.GracePeriod),
+			AwaitPeerUpdate:        req.AwaitPeerUpdate,
+		}
+		if err := s.network.nw.DrainNode(ctx, req.Name, opts); err != nil {
+			return nil, err
+		}
+	} else if err := s.network.nw.RemoveNode(ctx, req.Name); err != nil {
 		return nil, err
 	}
 
@@ -1020,7 +1416,7 @@ func (s *server) RestartNode(ctx context.Context, req *rpcpb.RestartNodeRequest)
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	s.log.Debug("RestartNode", zap.String("name", req.Name))
+	s.log.Debug("RestartNode", utils.NodeField(req.Name))
 
 	if s.network == nil {
 		return nil, ErrNotBootstrapped
@@ -1058,7 +1454,7 @@ func (s *server) PauseNode(ctx context.Context, req *rpcpb.PauseNodeRequest) (*r
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	s.log.Debug("PauseNode", zap.String("name", req.Name))
+	s.log.Debug("PauseNode", utils.NodeField(req.Name))
 
 	if s.network == nil {
 		return nil, ErrNotBootstrapped
@@ -1086,7 +1482,7 @@ func (s *server) ResumeNode(ctx context.Context, req *rpcpb.ResumeNodeRequest) (
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	s.log.Debug("ResumeNode", zap.String("name", req.Name))
+	s.log.Debug("ResumeNode", utils.NodeField(req.Name))
 
 	if s.network == nil {
 		return nil, ErrNotBootstrapped
@@ -1110,6 +1506,41 @@ func (s *server) ResumeNode(ctx context.Context, req *rpcpb.ResumeNodeRequest) (
 	return &rpcpb.ResumeNodeResponse{ClusterInfo: s.clusterInfo}, nil
 }
 
+func (s *server) AddPrimaryValidator(ctx context.Context, req *rpcpb.AddPrimaryValidatorRequest) (*rpcpb.AddPrimaryValidatorResponse, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.log.Debug("AddPrimaryValidator", utils.NodeField(req.Name), zap.Uint64("stake", req.GetPrimaryValidator().GetStake()))
+
+	if s.network == nil {
+		return nil, ErrNotBootstrapped
+	}
+
+	if err := s.network.nw.AddPrimaryValidator(ctx, req.Name, newPrimaryValidatorConfig(req.PrimaryValidator)); err != nil {
+		return nil, err
+	}
+
+	if err := s.network.UpdateNodeInfo(); err != nil {
+		return nil, err
+	}
+
+	s.clusterInfo.NodeNames = maps.Keys(s.network.nodeInfos)
+	sort.Strings(s.clusterInfo.NodeNames)
+	s.clusterInfo.NodeInfos = s.network.nodeInfos
+
+	return &rpcpb.AddPrimaryValidatorResponse{ClusterInfo: s.clusterInfo}, nil
+}
+
+// Returns the config of the primary network validation given by [spec],
+// which may be nil to take the defaults
+func newPrimaryValidatorConfig(spec *rpcpb.PrimaryValidatorSpec) node.PrimaryValidatorConfig {
+	return node.PrimaryValidatorConfig{
+		Stake:         spec.GetStake(),
+		Duration:      spec.GetDuration().AsDuration(),
+		DelegationFee: spec.GetDelegationFee(),
+	}
+}
+
 func (s *server) Stop(context.Context, *rpcpb.StopRequest) (*rpcpb.StopResponse, error) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
@@ -1132,7 +1563,7 @@ func (lh *loggingInboundHandler) HandleInbound(_ context.Context, m message.Inbo
 	lh.log.Debug(
 		"inbound handler received a message",
 		zap.String("message", m.Op().String()),
-		zap.String("node-name", lh.nodeName),
+		utils.NodeField(lh.nodeName),
 	)
 }
 
@@ -1158,7 +1589,7 @@ func (s *server) AttachPeer(ctx context.Context, req *rpcpb.AttachPeerRequest) (
 	}
 
 	newPeerID := newPeer.ID().String()
-	s.log.Debug("new peer is attached to", zap.String("peer-ID", newPeerID), zap.String("node-name", node.GetName()))
+	s.log.Debug("new peer is attached to", zap.String("peer-ID", newPeerID), utils.NodeField(node.GetName()))
 
 	if s.clusterInfo.AttachedPeerInfos == nil {
 		s.clusterInfo.AttachedPeerInfos = make(map[string]*rpcpb.ListOfAttachedPeerInfo)
@@ -1198,7 +1629,7 @@ func (s *server) SendOutboundMessage(ctx context.Context, req *rpcpb.SendOutboun
 	return &rpcpb.SendOutboundMessageResponse{Sent: sent}, err
 }
 
-func (s *server) LoadSnapshot(_ context.Context, req *rpcpb.LoadSnapshotRequest) (*rpcpb.LoadSnapshotResponse, error) {
+func (s *server) LoadSnapshot(ctx context.Context, req *rpcpb.LoadSnapshotRequest) (*rpcpb.LoadSnapshotResponse, error) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
@@ -1208,10 +1639,14 @@ func (s *server) LoadSnapshot(_ context.Context, req *rpcpb.LoadSnapshotRequest)
 		return nil, ErrAlreadyBootstrapped
 	}
 
-	var err error
+	ttl, ttlSnapshot, err := s.networkTTLFromContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
 	rootDataDir := req.GetRootDataDir()
 	if len(rootDataDir) == 0 {
-		rootDataDir = filepath.Join(os.TempDir(), constants.RootDirPrefix)
+		rootDataDir = tenantRootDataDir(filepath.Join(os.TempDir(), constants.RootDirPrefix), tenantFromContext(ctx))
 		err = os.MkdirAll(rootDataDir, os.ModePerm)
 		if err != nil {
 			return nil, err
@@ -1237,10 +1672,13 @@ func (s *server) LoadSnapshot(_ context.Context, req *rpcpb.LoadSnapshotRequest)
 		logLevel:            s.cfg.LogLevel,
 		reassignPortsIfUsed: req.GetReassignPortsIfUsed(),
 		snapshotsDir:        s.cfg.SnapshotsDir,
+		portMapHook:         s.cfg.PortMapHook,
 	})
 	if err != nil {
 		return nil, err
 	}
+	s.networkTenant = tenantFromContext(ctx)
+	s.setNetworkTTL(ttl, ttlSnapshot)
 	s.clusterInfo = &rpcpb.ClusterInfo{
 		Pid:         pid,
 		RootDataDir: rootDataDir,
@@ -1253,9 +1691,9 @@ func (s *server) LoadSnapshot(_ context.Context, req *rpcpb.LoadSnapshotRequest)
 		return nil, err
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), waitForHealthyTimeout)
+	healthyCtx, cancel := context.WithTimeout(context.Background(), waitForHealthyTimeout)
 	defer cancel()
-	err = s.network.AwaitHealthyAndUpdateNetworkInfo(ctx)
+	err = s.network.AwaitHealthyAndUpdateNetworkInfo(healthyCtx)
 	if err != nil {
 		s.log.Warn("snapshot load failed to complete. stopping network and cleaning up network", zap.Error(err))
 		s.stopAndRemoveNetwork(err)
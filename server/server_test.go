@@ -0,0 +1,250 @@
+// Copyright (C) 2021-2024, Lux Partners Limited. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package server
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/luxdefi/netrunner/network"
+	"github.com/luxdefi/netrunner/network/node"
+	"github.com/luxdefi/netrunner/rpcpb"
+	"github.com/luxdefi/node/utils/logging"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeNode is a node whose paused state, data dir and config are set
+// by the test. The methods not overridden panic.
+type fakeNode struct {
+	node.Node
+	paused  bool
+	dataDir string
+	config  node.Config
+}
+
+func (n *fakeNode) GetPaused() bool {
+	return n.paused
+}
+
+func (n *fakeNode) GetConfig() node.Config {
+	return n.config
+}
+
+func (n *fakeNode) GetDataDir() string {
+	return n.dataDir
+}
+
+func (*fakeNode) GetRuntimeInfo() node.RuntimeInfo {
+	return node.RuntimeInfo{}
+}
+
+func (*fakeNode) GetAPIPort() uint16 {
+	return 0
+}
+
+func (*fakeNode) GetP2PPort() uint16 {
+	return 0
+}
+
+// fakeNetwork is an in-memory network recording the calls made on it.
+// The methods not overridden panic.
+type fakeNetwork struct {
+	network.Network
+
+	lock      sync.Mutex
+	nodes     map[string]*fakeNode
+	snapshots []string
+	stopped   bool
+	// error of the context given to Stop, once stopped
+	stopCtxErr error
+}
+
+func newFakeNetwork(names ...string) *fakeNetwork {
+	nw := &fakeNetwork{nodes: map[string]*fakeNode{}}
+	for _, name := range names {
+		nw.nodes[name] = &fakeNode{}
+	}
+	return nw
+}
+
+func (nw *fakeNetwork) GetAllNodes() (map[string]node.Node, error) {
+	nw.lock.Lock()
+	defer nw.lock.Unlock()
+
+	nodes := map[string]node.Node{}
+	for name, n := range nw.nodes {
+		nodes[name] = n
+	}
+	return nodes, nil
+}
+
+func (nw *fakeNetwork) GetNode(name string) (node.Node, error) {
+	nw.lock.Lock()
+	defer nw.lock.Unlock()
+
+	n, ok := nw.nodes[name]
+	if !ok {
+		return nil, network.ErrNodeNotFound
+	}
+	return n, nil
+}
+
+func (nw *fakeNetwork) GetNodeNames() ([]string, error) {
+	nw.lock.Lock()
+	defer nw.lock.Unlock()
+
+	names := []string{}
+	for name := range nw.nodes {
+		names = append(names, name)
+	}
+	return names, nil
+}
+
+func (nw *fakeNetwork) FreezeNode(_ context.Context, name string) error {
+	return nw.setPaused(name, true)
+}
+
+func (nw *fakeNetwork) UnfreezeNode(_ context.Context, name string) error {
+	return nw.setPaused(name, false)
+}
+
+func (nw *fakeNetwork) setPaused(name string, paused bool) error {
+	nw.lock.Lock()
+	defer nw.lock.Unlock()
+
+	n, ok := nw.nodes[name]
+	if !ok {
+		return network.ErrNodeNotFound
+	}
+	n.paused = paused
+	return nil
+}
+
+func (nw *fakeNetwork) SaveSnapshot(_ context.Context, snapshotName string) (string, error) {
+	nw.lock.Lock()
+	defer nw.lock.Unlock()
+
+	nw.snapshots = append(nw.snapshots, snapshotName)
+	nw.stopped = true
+	return "/snapshots/" + snapshotName, nil
+}
+
+func (nw *fakeNetwork) Stop(ctx context.Context) error {
+	nw.lock.Lock()
+	defer nw.lock.Unlock()
+
+	nw.stopped = true
+	nw.stopCtxErr = ctx.Err()
+	return nil
+}
+
+// Returns a server with no network nor listeners
+func newTestServer(t *testing.T, cfg Config) *server {
+	s := &server{
+		cfg:        cfg,
+		log:        logging.NoLog{},
+		closed:     make(chan struct{}),
+		mu:         new(sync.RWMutex),
+		asyncErrCh: make(chan error, 1),
+		registry:   &networkRegistry{networks: map[string]*RegisteredNetwork{}},
+	}
+	s.rootCtx, s.rootCancel = context.WithCancel(context.Background())
+	s.killCtx, s.killCancel = context.WithCancel(context.Background())
+	t.Cleanup(func() {
+		s.rootCancel()
+		s.killCancel()
+	})
+	return s
+}
+
+// Serves [nw] from [s], as created by [tenant]
+func setTestNetwork(s *server, nw network.Network, tenant string) {
+	s.network = &localNetwork{
+		log:       logging.NoLog{},
+		nw:        nw,
+		nodeInfos: map[string]*rpcpb.NodeInfo{},
+		stopCh:    make(chan struct{}),
+	}
+	s.clusterInfo = &rpcpb.ClusterInfo{RootDataDir: "/tmp/test-network", Healthy: true}
+	s.networkTenant = tenant
+}
+
+func TestNewShutdownMode(t *testing.T) {
+	_, err := New(Config{Port: ":0", GwPort: ":0", ShutdownMode: "pause"}, logging.NoLog{})
+	require.ErrorIs(t, err, ErrInvalidShutdownMode)
+}
+
+func TestShutdownNetwork(t *testing.T) {
+	tests := []struct {
+		mode             ShutdownMode
+		kill             bool
+		expectedStopped  bool
+		expectedSnapshot bool
+		expectedStatus   string
+		expectedStopErr  error
+		expectedUnpaused bool
+	}{
+		{
+			mode:            ShutdownStop,
+			expectedStopped: true,
+			expectedStatus:  RegistryStopped,
+		},
+		{
+			mode:            ShutdownStop,
+			kill:            true,
+			expectedStopped: true,
+			expectedStatus:  RegistryStopped,
+			expectedStopErr: context.Canceled,
+		},
+		{
+			mode:             ShutdownSnapshot,
+			expectedStopped:  true,
+			expectedSnapshot: true,
+			expectedStatus:   RegistryStopped,
+		},
+		{
+			mode:             ShutdownDetach,
+			expectedStatus:   RegistryDetached,
+			expectedUnpaused: true,
+		},
+	}
+	for _, tt := range tests {
+		name := string(tt.mode)
+		if tt.kill {
+			name += " killed"
+		}
+		t.Run(name, func(t *testing.T) {
+			require := require.New(t)
+			s := newTestServer(t, Config{ShutdownMode: tt.mode})
+			nw := newFakeNetwork("node1", "node2")
+			setTestNetwork(s, nw, "")
+			s.registerNetwork(RegistryRunning)
+			// node1 frozen while the network is idle
+			require.NoError(nw.FreezeNode(context.Background(), "node1"))
+			s.suspended = &suspension{action: IdleFreeze, frozenNodes: []string{"node1"}}
+			if tt.kill {
+				s.Kill()
+			}
+
+			s.mu.Lock()
+			s.shutdownNetwork()
+			s.mu.Unlock()
+
+			require.Nil(s.network)
+			require.Nil(s.suspended)
+			require.Equal(tt.expectedStopped, nw.stopped)
+			require.ErrorIs(nw.stopCtxErr, tt.expectedStopErr)
+			if tt.expectedSnapshot {
+				require.Len(nw.snapshots, 1)
+				require.True(strings.HasPrefix(nw.snapshots[0], shutdownSnapshotPrefix))
+			} else {
+				require.Empty(nw.snapshots)
+			}
+			require.Equal(!tt.expectedUnpaused, nw.nodes["node1"].paused)
+			require.Equal(tt.expectedStatus, s.registry.networks["/tmp/test-network"].Status)
+		})
+	}
+}
@@ -0,0 +1,61 @@
+// Copyright (C) 2021-2024, Lux Partners Limited. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package server
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/luxdefi/netrunner/staking"
+	"github.com/luxdefi/node/ids"
+	"go.uber.org/zap"
+)
+
+const validatorsPath = "/v1/control/validators"
+
+// GET ?subnetID=...&uptimeTolerance=5 aggregates the uptime, stake and
+// delegation info of the current validators as seen by every running
+// node, flagging where their views disagree. Defaults to the primary network.
+func (s *server) handleValidators(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !s.authenticateHTTP(w, r) {
+		return
+	}
+	cfg := staking.InspectConfig{}
+	if subnetID := r.URL.Query().Get("subnetID"); subnetID != "" {
+		var err error
+		cfg.SubnetID, err = ids.FromString(subnetID)
+		if err != nil {
+			http.Error(w, "invalid subnetID: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+	}
+	if tolerance := r.URL.Query().Get("uptimeTolerance"); tolerance != "" {
+		f, err := strconv.ParseFloat(tolerance, 32)
+		if err != nil || f <= 0 {
+			http.Error(w, "invalid uptimeTolerance", http.StatusBadRequest)
+			return
+		}
+		cfg.UptimeTolerance = float32(f)
+	}
+
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.network == nil {
+		http.Error(w, ErrNotBootstrapped.Error(), http.StatusServiceUnavailable)
+		return
+	}
+	s.log.Debug("Validators", zap.Stringer("subnet-id", cfg.SubnetID))
+
+	report, err := staking.Inspect(r.Context(), s.network.nw, cfg)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	writeJSON(w, report)
+}
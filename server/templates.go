@@ -0,0 +1,218 @@
+// Copyright (C) 2021-2024, Lux Partners Limited. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/luxdefi/netrunner/rpcpb"
+	"go.uber.org/zap"
+	"google.golang.org/protobuf/proto"
+)
+
+const (
+	templatesPath      = "/v1/control/templates"
+	templateFileSuffix = ".json"
+)
+
+var (
+	ErrTemplateNotFound = errors.New("network template not found")
+	ErrInvalidTemplate  = errors.New("invalid network template")
+
+	templateNameRegex = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9_.-]*$`)
+)
+
+// NetworkTemplate is a named recipe to start networks.
+// Instances are created either from [Start] or, if [Snapshot]
+// is given, by loading the snapshot with the options in [Load].
+type NetworkTemplate struct {
+	Name     string                     `json:"name"`
+	Start    *rpcpb.StartRequest        `json:"start,omitempty"`
+	Snapshot string                     `json:"snapshot,omitempty"`
+	Load     *rpcpb.LoadSnapshotRequest `json:"load,omitempty"`
+}
+
+func (t *NetworkTemplate) validate() error {
+	if !templateNameRegex.MatchString(t.Name) {
+		return fmt.Errorf("%w: invalid name %q", ErrInvalidTemplate, t.Name)
+	}
+	if t.Snapshot == "" && t.Start == nil {
+		return fmt.Errorf("%w: either a start request or a snapshot must be given", ErrInvalidTemplate)
+	}
+	if t.Snapshot != "" && t.Start != nil {
+		return fmt.Errorf("%w: start request and snapshot are mutually exclusive", ErrInvalidTemplate)
+	}
+	return nil
+}
+
+// templateRegistry keeps the registered templates, persisting
+// them as json files in [dir] if it is not empty
+type templateRegistry struct {
+	lock      sync.RWMutex
+	dir       string
+	templates map[string]*NetworkTemplate
+}
+
+// Loads the templates persisted in [dir]
+func newTemplateRegistry(dir string) (*templateRegistry, error) {
+	r := &templateRegistry{
+		dir:       dir,
+		templates: map[string]*NetworkTemplate{},
+	}
+	if dir == "" {
+		return r, nil
+	}
+	if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+		return nil, err
+	}
+	matches, err := filepath.Glob(filepath.Join(dir, "*"+templateFileSuffix))
+	if err != nil {
+		return nil, err
+	}
+	for _, match := range matches {
+		b, err := os.ReadFile(match)
+		if err != nil {
+			return nil, err
+		}
+		template := &NetworkTemplate{}
+		if err := json.Unmarshal(b, template); err != nil {
+			return nil, fmt.Errorf("couldn't unmarshal template file %q: %w", match, err)
+		}
+		if err := template.validate(); err != nil {
+			return nil, fmt.Errorf("template file %q: %w", match, err)
+		}
+		r.templates[template.Name] = template
+	}
+	return r, nil
+}
+
+// Registers [template], replacing any previous one with the same name
+func (r *templateRegistry) register(template *NetworkTemplate) error {
+	if err := template.validate(); err != nil {
+		return err
+	}
+	r.lock.Lock()
+	defer r.lock.Unlock()
+
+	if r.dir != "" {
+		b, err := json.MarshalIndent(template, "", "  ")
+		if err != nil {
+			return err
+		}
+		if err := os.WriteFile(filepath.Join(r.dir, template.Name+templateFileSuffix), b, 0o600); err != nil {
+			return err
+		}
+	}
+	r.templates[template.Name] = template
+	return nil
+}
+
+func (r *templateRegistry) get(name string) (*NetworkTemplate, error) {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
+	template, ok := r.templates[name]
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrTemplateNotFound, name)
+	}
+	return template, nil
+}
+
+// Returns the registered templates sorted by name
+func (r *templateRegistry) list() []*NetworkTemplate {
+	r.lock.RLock()
+	defer r.lock.RUnlock()
+
+	templates := make([]*NetworkTemplate, 0, len(r.templates))
+	for _, template := range r.templates {
+		templates = append(templates, template)
+	}
+	sort.Slice(templates, func(i, j int) bool {
+		return templates[i].Name < templates[j].Name
+	})
+	return templates
+}
+
+// Starts a network from the template [name]
+func (s *server) startFromTemplate(ctx context.Context, name string) (*rpcpb.ClusterInfo, error) {
+	template, err := s.templates.get(name)
+	if err != nil {
+		return nil, err
+	}
+	// the requests are modified by the calls, so work on copies
+	if template.Snapshot != "" {
+		req := &rpcpb.LoadSnapshotRequest{}
+		if template.Load != nil {
+			req = proto.Clone(template.Load).(*rpcpb.LoadSnapshotRequest)
+		}
+		req.SnapshotName = template.Snapshot
+		resp, err := s.LoadSnapshot(ctx, req)
+		if err != nil {
+			return nil, err
+		}
+		return resp.ClusterInfo, nil
+	}
+	resp, err := s.Start(ctx, proto.Clone(template.Start).(*rpcpb.StartRequest))
+	if err != nil {
+		return nil, err
+	}
+	return resp.ClusterInfo, nil
+}
+
+// Serves template registration (POST) and listing (GET). Instances
+// are started from a template with the StartFromTemplate RPC.
+func (s *server) registerTemplateHandlers(mux *http.ServeMux) {
+	mux.HandleFunc(templatesPath, func(w http.ResponseWriter, r *http.Request) {
+		if _, ok := s.httpTenant(w, r); !ok {
+			return
+		}
+		switch r.Method {
+		case http.MethodGet:
+			writeJSON(w, s.templates.list())
+		case http.MethodPost:
+			template := &NetworkTemplate{}
+			if err := json.NewDecoder(r.Body).Decode(template); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			if err := s.templates.register(template); err != nil {
+				status := http.StatusInternalServerError
+				if errors.Is(err, ErrInvalidTemplate) {
+					status = http.StatusBadRequest
+				}
+				http.Error(w, err.Error(), status)
+				return
+			}
+			writeJSON(w, template)
+		default:
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		}
+	})
+}
+
+// StartFromTemplate starts a network from the registered template [req.Name]
+func (s *server) StartFromTemplate(ctx context.Context, req *rpcpb.StartFromTemplateRequest) (*rpcpb.StartFromTemplateResponse, error) {
+	s.log.Debug("StartFromTemplate", zap.String("name", req.Name))
+
+	clusterInfo, err := s.startFromTemplate(ctx, strings.TrimSpace(req.Name))
+	if err != nil {
+		return nil, err
+	}
+	return &rpcpb.StartFromTemplateResponse{ClusterInfo: clusterInfo}, nil
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(v)
+}
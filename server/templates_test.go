@@ -0,0 +1,118 @@
+// Copyright (C) 2021-2024, Lux Partners Limited. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package server
+
+import (
+	"context"
+	"testing"
+
+	"github.com/luxdefi/netrunner/rpcpb"
+	"github.com/luxdefi/netrunner/utils"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/proto"
+)
+
+func TestNetworkTemplateValidate(t *testing.T) {
+	tests := []struct {
+		name     string
+		template NetworkTemplate
+		wantErr  bool
+	}{
+		{
+			name:     "start request",
+			template: NetworkTemplate{Name: "five-nodes", Start: &rpcpb.StartRequest{}},
+		},
+		{
+			name:     "snapshot",
+			template: NetworkTemplate{Name: "from_snapshot.1", Snapshot: "snap"},
+		},
+		{
+			name:     "invalid name",
+			template: NetworkTemplate{Name: "../escape", Start: &rpcpb.StartRequest{}},
+			wantErr:  true,
+		},
+		{
+			name:     "neither start request nor snapshot",
+			template: NetworkTemplate{Name: "empty"},
+			wantErr:  true,
+		},
+		{
+			name:     "both start request and snapshot",
+			template: NetworkTemplate{Name: "both", Start: &rpcpb.StartRequest{}, Snapshot: "snap"},
+			wantErr:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.template.validate()
+			if tt.wantErr {
+				require.ErrorIs(t, err, ErrInvalidTemplate)
+				return
+			}
+			require.NoError(t, err)
+		})
+	}
+}
+
+func TestTemplateRegistryPersists(t *testing.T) {
+	require := require.New(t)
+
+	dir := t.TempDir()
+	r, err := newTemplateRegistry(dir)
+	require.NoError(err)
+
+	numNodes := uint32(7)
+	require.NoError(r.register(&NetworkTemplate{
+		Name:  "seven-nodes",
+		Start: &rpcpb.StartRequest{ExecPath: "/bin/node", NumNodes: &numNodes},
+	}))
+	require.NoError(r.register(&NetworkTemplate{
+		Name:     "from-snapshot",
+		Snapshot: "snap",
+		Load:     &rpcpb.LoadSnapshotRequest{PluginDir: "/plugins"},
+	}))
+	require.ErrorIs(r.register(&NetworkTemplate{Name: "invalid"}), ErrInvalidTemplate)
+
+	// reloaded from the template files
+	r, err = newTemplateRegistry(dir)
+	require.NoError(err)
+	templates := r.list()
+	require.Len(templates, 2)
+	require.Equal("from-snapshot", templates[0].Name)
+	require.Equal("seven-nodes", templates[1].Name)
+
+	template, err := r.get("seven-nodes")
+	require.NoError(err)
+	require.True(proto.Equal(&rpcpb.StartRequest{ExecPath: "/bin/node", NumNodes: &numNodes}, template.Start))
+	template, err = r.get("from-snapshot")
+	require.NoError(err)
+	require.Equal("snap", template.Snapshot)
+	require.Equal("/plugins", template.Load.PluginDir)
+
+	_, err = r.get("missing")
+	require.ErrorIs(err, ErrTemplateNotFound)
+}
+
+func TestStartFromTemplate(t *testing.T) {
+	require := require.New(t)
+
+	s := newTestServer(t, Config{})
+	var err error
+	s.templates, err = newTemplateRegistry("")
+	require.NoError(err)
+
+	_, err = s.StartFromTemplate(context.Background(), &rpcpb.StartFromTemplateRequest{Name: "missing"})
+	require.ErrorIs(err, ErrTemplateNotFound)
+
+	template := &NetworkTemplate{
+		Name:  "no-exec",
+		Start: &rpcpb.StartRequest{ExecPath: "/nonexistent/node"},
+	}
+	require.NoError(s.templates.register(template))
+	_, err = s.StartFromTemplate(context.Background(), &rpcpb.StartFromTemplateRequest{Name: " no-exec "})
+	require.ErrorIs(err, utils.ErrNotExists)
+	// the defaults set by Start went to a copy
+	require.Nil(template.Start.NumNodes)
+	require.Nil(s.network)
+}
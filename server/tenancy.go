@@ -0,0 +1,192 @@
+// Copyright (C) 2021-2024, Lux Partners Limited. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package server
+
+import (
+	"context"
+	"net/http"
+	"path/filepath"
+	"strings"
+
+	"github.com/luxdefi/netrunner/utils"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+const (
+	// gRPC metadata key holding the tenant token.
+	// The gRPC gateway maps the HTTP Authorization header to it.
+	authorizationMetadataKey = "authorization"
+	bearerPrefix             = "Bearer "
+	tenantsSubdir            = "tenants"
+)
+
+// methods that don't require a tenant token
+var publicMethods = map[string]struct{}{
+	"/rpcpb.PingService/Ping":          {},
+	"/rpcpb.ControlService/RPCVersion": {},
+}
+
+// method stopping the network
+const stopMethod = "/rpcpb.ControlService/Stop"
+
+// methods that create the network, so the caller tenant becomes its owner
+var networkCreationMethods = map[string]struct{}{
+	"/rpcpb.ControlService/Start":             {},
+	"/rpcpb.ControlService/LoadSnapshot":      {},
+	"/rpcpb.ControlService/StartFromTemplate": {},
+}
+
+// methods that don't act on the network, so that they're served
+// whichever tenant owns it
+var networkIndependentMethods = map[string]struct{}{
+	"/rpcpb.ControlService/GC": {},
+}
+
+type tenantCtxKey struct{}
+
+// Returns the tenant of the request, or the empty string if tenancy is disabled
+func tenantFromContext(ctx context.Context) string {
+	tenant, _ := ctx.Value(tenantCtxKey{}).(string)
+	return tenant
+}
+
+func (s *server) tenancyEnabled() bool {
+	return len(s.cfg.TenantTokens) > 0
+}
+
+// Returns the tenant the token in [authorization] belongs to
+func (s *server) tenantFromAuthorization(authorization string) (string, bool) {
+	token := strings.TrimPrefix(authorization, bearerPrefix)
+	tenant, ok := s.cfg.TenantTokens[token]
+	return tenant, ok
+}
+
+// Returns an error if the network is owned by a tenant different from [tenant].
+// Networks of other tenants are hidden, as if there was no network.
+func (s *server) checkTenantAccess(tenant string) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.network != nil && s.networkTenant != tenant {
+		return ErrNotBootstrapped
+	}
+	return nil
+}
+
+// Authenticates the request and adds its tenant to the context
+func (s *server) authenticate(ctx context.Context, fullMethod string) (context.Context, error) {
+	if !s.tenancyEnabled() {
+		return ctx, nil
+	}
+	if _, ok := publicMethods[fullMethod]; ok {
+		return ctx, nil
+	}
+	md, _ := metadata.FromIncomingContext(ctx)
+	values := md.Get(authorizationMetadataKey)
+	if len(values) == 0 {
+		return nil, status.Error(codes.Unauthenticated, "missing tenant token")
+	}
+	tenant, ok := s.tenantFromAuthorization(values[0])
+	if !ok {
+		return nil, status.Error(codes.Unauthenticated, "invalid tenant token")
+	}
+	_, createsNetwork := networkCreationMethods[fullMethod]
+	_, networkIndependent := networkIndependentMethods[fullMethod]
+	if !createsNetwork && !networkIndependent {
+		if err := s.checkTenantAccess(tenant); err != nil {
+			return nil, err
+		}
+	}
+	return context.WithValue(ctx, tenantCtxKey{}, tenant), nil
+}
+
+func (s *server) tenancyUnaryInterceptor(
+	ctx context.Context,
+	req interface{},
+	info *grpc.UnaryServerInfo,
+	handler grpc.UnaryHandler,
+) (_ interface{}, err error) {
+	defer utils.StartOperation(s.log, operationName(info.FullMethod))(&err)
+
+	ctx, err = s.authenticate(ctx, info.FullMethod)
+	if err != nil {
+		return nil, err
+	}
+	if _, ok := publicMethods[info.FullMethod]; !ok {
+		s.resumeNetwork(tenantFromContext(ctx), info.FullMethod)
+	}
+	return handler(ctx, req)
+}
+
+type tenantServerStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (ss *tenantServerStream) Context() context.Context {
+	return ss.ctx
+}
+
+func (s *server) tenancyStreamInterceptor(
+	srv interface{},
+	ss grpc.ServerStream,
+	info *grpc.StreamServerInfo,
+	handler grpc.StreamHandler,
+) (err error) {
+	defer utils.StartOperation(s.log, operationName(info.FullMethod))(&err)
+
+	ctx, err := s.authenticate(ss.Context(), info.FullMethod)
+	if err != nil {
+		return err
+	}
+	if _, ok := publicMethods[info.FullMethod]; !ok {
+		s.resumeNetwork(tenantFromContext(ctx), info.FullMethod)
+	}
+	return handler(srv, &tenantServerStream{ServerStream: ss, ctx: ctx})
+}
+
+// Returns the RPC name of [fullMethod] (e.g. "Start" for "/rpcpb.ControlService/Start")
+func operationName(fullMethod string) string {
+	return fullMethod[strings.LastIndex(fullMethod, "/")+1:]
+}
+
+// Same as [authenticate], for the HTTP routes served outside of the gRPC gateway.
+// Writes the error response and returns false if the request is rejected.
+func (s *server) authenticateHTTP(w http.ResponseWriter, r *http.Request) bool {
+	tenant, ok := s.httpTenant(w, r)
+	if !ok {
+		return false
+	}
+	if err := s.checkTenantAccess(tenant); err != nil {
+		http.Error(w, err.Error(), http.StatusServiceUnavailable)
+		return false
+	}
+	return true
+}
+
+// Returns the tenant of an HTTP request, writing the error response
+// and returning false if its token is not valid.
+// Returns the empty string if tenancy is disabled.
+func (s *server) httpTenant(w http.ResponseWriter, r *http.Request) (string, bool) {
+	if !s.tenancyEnabled() {
+		return "", true
+	}
+	tenant, ok := s.tenantFromAuthorization(r.Header.Get("Authorization"))
+	if !ok {
+		http.Error(w, "invalid tenant token", http.StatusUnauthorized)
+		return "", false
+	}
+	return tenant, true
+}
+
+// Returns the root data dir of [tenant] under [rootDataDir]
+func tenantRootDataDir(rootDataDir string, tenant string) string {
+	if tenant == "" {
+		return rootDataDir
+	}
+	return filepath.Join(rootDataDir, tenantsSubdir, tenant)
+}
@@ -0,0 +1,176 @@
+// Copyright (C) 2021-2024, Lux Partners Limited. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package server
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+func TestAuthenticate(t *testing.T) {
+	s := newTestServer(t, Config{TenantTokens: map[string]string{"token-a": "team-a", "token-b": "team-b"}})
+	setTestNetwork(s, newFakeNetwork("node1"), "team-a")
+
+	tests := []struct {
+		name           string
+		fullMethod     string
+		authorization  string
+		expectedTenant string
+		expectedCode   codes.Code
+		expectedErr    error
+	}{
+		{
+			name:       "public method without token",
+			fullMethod: "/rpcpb.PingService/Ping",
+		},
+		{
+			name:         "missing token",
+			fullMethod:   "/rpcpb.ControlService/Health",
+			expectedCode: codes.Unauthenticated,
+		},
+		{
+			name:          "invalid token",
+			fullMethod:    "/rpcpb.ControlService/Health",
+			authorization: "Bearer token-c",
+			expectedCode:  codes.Unauthenticated,
+		},
+		{
+			name:           "network owner",
+			fullMethod:     "/rpcpb.ControlService/Health",
+			authorization:  "Bearer token-a",
+			expectedTenant: "team-a",
+		},
+		{
+			name:           "network owner without bearer prefix",
+			fullMethod:     "/rpcpb.ControlService/Health",
+			authorization:  "token-a",
+			expectedTenant: "team-a",
+		},
+		{
+			name:          "network of other tenant hidden",
+			fullMethod:    "/rpcpb.ControlService/Health",
+			authorization: "Bearer token-b",
+			expectedErr:   ErrNotBootstrapped,
+		},
+		{
+			name:          "network of other tenant not stopped",
+			fullMethod:    stopMethod,
+			authorization: "Bearer token-b",
+			expectedErr:   ErrNotBootstrapped,
+		},
+		{
+			name:           "network creation by other tenant",
+			fullMethod:     "/rpcpb.ControlService/Start",
+			authorization:  "Bearer token-b",
+			expectedTenant: "team-b",
+		},
+		{
+			name:           "network independent method of other tenant",
+			fullMethod:     "/rpcpb.ControlService/GC",
+			authorization:  "Bearer token-b",
+			expectedTenant: "team-b",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require := require.New(t)
+			ctx := context.Background()
+			if tt.authorization != "" {
+				ctx = metadata.NewIncomingContext(ctx, metadata.Pairs(authorizationMetadataKey, tt.authorization))
+			}
+			ctx, err := s.authenticate(ctx, tt.fullMethod)
+			switch {
+			case tt.expectedErr != nil:
+				require.ErrorIs(err, tt.expectedErr)
+			case tt.expectedCode != codes.OK:
+				require.Equal(tt.expectedCode, status.Code(err))
+			default:
+				require.NoError(err)
+				require.Equal(tt.expectedTenant, tenantFromContext(ctx))
+			}
+		})
+	}
+}
+
+func TestAuthenticateTenancyDisabled(t *testing.T) {
+	require := require.New(t)
+
+	s := newTestServer(t, Config{})
+	setTestNetwork(s, newFakeNetwork("node1"), "")
+	ctx, err := s.authenticate(context.Background(), "/rpcpb.ControlService/Health")
+	require.NoError(err)
+	require.Empty(tenantFromContext(ctx))
+}
+
+func TestCheckTenantAccess(t *testing.T) {
+	require := require.New(t)
+
+	s := newTestServer(t, Config{TenantTokens: map[string]string{"token-a": "team-a", "token-b": "team-b"}})
+	// no network to hide
+	require.NoError(s.checkTenantAccess("team-a"))
+	require.NoError(s.checkTenantAccess("team-b"))
+
+	setTestNetwork(s, newFakeNetwork("node1"), "team-a")
+	require.NoError(s.checkTenantAccess("team-a"))
+	require.ErrorIs(s.checkTenantAccess("team-b"), ErrNotBootstrapped)
+}
+
+func TestAuthenticateHTTP(t *testing.T) {
+	s := newTestServer(t, Config{TenantTokens: map[string]string{"token-a": "team-a", "token-b": "team-b"}})
+	setTestNetwork(s, newFakeNetwork("node1"), "team-a")
+
+	tests := []struct {
+		name           string
+		authorization  string
+		expectedOK     bool
+		expectedStatus int
+	}{
+		{
+			name:           "missing token",
+			expectedStatus: http.StatusUnauthorized,
+		},
+		{
+			name:           "invalid token",
+			authorization:  "Bearer token-c",
+			expectedStatus: http.StatusUnauthorized,
+		},
+		{
+			name:           "network of other tenant",
+			authorization:  "Bearer token-b",
+			expectedStatus: http.StatusServiceUnavailable,
+		},
+		{
+			name:           "network owner",
+			authorization:  "Bearer token-a",
+			expectedOK:     true,
+			expectedStatus: http.StatusOK,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require := require.New(t)
+			r := httptest.NewRequest(http.MethodGet, "/v1/control/ttl", nil)
+			if tt.authorization != "" {
+				r.Header.Set("Authorization", tt.authorization)
+			}
+			w := httptest.NewRecorder()
+			require.Equal(tt.expectedOK, s.authenticateHTTP(w, r))
+			require.Equal(tt.expectedStatus, w.Code)
+		})
+	}
+}
+
+func TestTenantRootDataDir(t *testing.T) {
+	require := require.New(t)
+
+	require.Equal("/tmp/netrunner", tenantRootDataDir("/tmp/netrunner", ""))
+	require.Equal("/tmp/netrunner/tenants/team-a", tenantRootDataDir("/tmp/netrunner", "team-a"))
+}
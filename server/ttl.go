@@ -0,0 +1,180 @@
+// Copyright (C) 2021-2024, Lux Partners Limited. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/luxdefi/netrunner/network/node"
+	"github.com/luxdefi/netrunner/utils/constants"
+	"go.uber.org/zap"
+	"google.golang.org/grpc/metadata"
+)
+
+const (
+	ttlPath = "/v1/control/ttl"
+	// prefix of the snapshots of the expired networks
+	ttlSnapshotPrefix = "ttl-"
+)
+
+// networkTTL is the lifetime of the running network, which is stopped
+// once it expires
+type networkTTL struct {
+	ttl      time.Duration
+	deadline time.Time
+	// true to snapshot the network when it expires
+	snapshot bool
+	timer    *time.Timer
+}
+
+type ttlInfo struct {
+	// Empty if the network has no TTL
+	TTL      string     `json:"ttl,omitempty"`
+	Deadline *time.Time `json:"deadline,omitempty"`
+	// Time left until the network is stopped, e.g. "1h59m58s"
+	Remaining        string `json:"remaining,omitempty"`
+	RemainingSeconds int64  `json:"remainingSeconds,omitempty"`
+	// True if the network is snapshotted when it expires
+	Snapshot bool `json:"snapshot"`
+}
+
+// Returns the TTL of a network created with the request of [ctx], and
+// whether the network is snapshotted when it expires. They are given in
+// the request metadata, or else by the server config.
+func (s *server) networkTTLFromContext(ctx context.Context) (time.Duration, bool, error) {
+	ttl, snapshot := s.cfg.NetworkTTL, s.cfg.NetworkTTLSnapshot
+	md, _ := metadata.FromIncomingContext(ctx)
+	if values := md.Get(constants.NetworkTTLMetadataKey); len(values) > 0 {
+		var err error
+		ttl, err = node.ParseDuration(values[0])
+		if err != nil || ttl < 0 {
+			return 0, false, fmt.Errorf("invalid network TTL %q", values[0])
+		}
+	}
+	if values := md.Get(constants.NetworkTTLSnapshotMetadataKey); len(values) > 0 {
+		var err error
+		snapshot, err = strconv.ParseBool(values[0])
+		if err != nil {
+			return 0, false, fmt.Errorf("invalid network TTL snapshot %q", values[0])
+		}
+	}
+	return ttl, snapshot, nil
+}
+
+// Stops the running network, snapshotting it first if [snapshot], once
+// [ttl] elapses, instead of the previous TTL if any. A zero TTL removes it.
+// Assumes [s.mu] is held.
+func (s *server) setNetworkTTL(ttl time.Duration, snapshot bool) {
+	s.clearNetworkTTL()
+	if ttl <= 0 {
+		return
+	}
+	nw := s.network
+	s.ttl = &networkTTL{
+		ttl:      ttl,
+		deadline: time.Now().Add(ttl),
+		snapshot: snapshot,
+		timer: time.AfterFunc(ttl, func() {
+			s.expireNetwork(nw)
+		}),
+	}
+	s.log.Info("network TTL set", zap.Duration("ttl", ttl), zap.Bool("snapshot", snapshot))
+}
+
+// Removes the TTL of the running network, if any.
+// Assumes [s.mu] is held.
+func (s *server) clearNetworkTTL() {
+	if s.ttl != nil {
+		s.ttl.timer.Stop()
+		s.ttl = nil
+	}
+}
+
+// Stops [nw] once its TTL expired, unless it's not running anymore
+func (s *server) expireNetwork(nw *localNetwork) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.network != nw || s.ttl == nil {
+		return
+	}
+	s.log.Warn("network TTL expired, stopping network", zap.Duration("ttl", s.ttl.ttl))
+	if s.ttl.snapshot {
+		s.saveTimestampedSnapshot(ttlSnapshotPrefix)
+	}
+	s.stopAndRemoveNetwork(nil)
+}
+
+// Returns the TTL info of the running network.
+// Assumes [s.mu] is held.
+func (s *server) getTTLInfo() ttlInfo {
+	if s.ttl == nil {
+		return ttlInfo{}
+	}
+	remaining := time.Until(s.ttl.deadline).Truncate(time.Second)
+	if remaining < 0 {
+		remaining = 0
+	}
+	deadline := s.ttl.deadline
+	return ttlInfo{
+		TTL:              s.ttl.ttl.String(),
+		Deadline:         &deadline,
+		Remaining:        remaining.String(),
+		RemainingSeconds: int64(remaining.Seconds()),
+		Snapshot:         s.ttl.snapshot,
+	}
+}
+
+// GET returns the TTL and remaining time of the network. POST {"ttl": "2h",
+// "snapshot": true} sets its TTL from now on, or removes it if zero.
+func (s *server) handleTTL(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !s.authenticateHTTP(w, r) {
+		return
+	}
+	var (
+		ttl      time.Duration
+		snapshot bool
+	)
+	if r.Method == http.MethodPost {
+		req := struct {
+			TTL      string `json:"ttl"`
+			Snapshot bool   `json:"snapshot"`
+		}{}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if req.TTL != "" {
+			var err error
+			ttl, err = node.ParseDuration(req.TTL)
+			if err != nil || ttl < 0 {
+				http.Error(w, "invalid ttl "+req.TTL, http.StatusBadRequest)
+				return
+			}
+		}
+		snapshot = req.Snapshot
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.network == nil {
+		http.Error(w, ErrNotBootstrapped.Error(), http.StatusServiceUnavailable)
+		return
+	}
+	if r.Method == http.MethodPost {
+		s.log.Debug("SetTTL", zap.Duration("ttl", ttl), zap.Bool("snapshot", snapshot))
+		s.setNetworkTTL(ttl, snapshot)
+	}
+	writeJSON(w, s.getTTLInfo())
+}
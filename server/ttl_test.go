@@ -0,0 +1,185 @@
+// Copyright (C) 2021-2024, Lux Partners Limited. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/luxdefi/netrunner/utils/constants"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc/metadata"
+)
+
+func TestNetworkTTLFromContext(t *testing.T) {
+	s := newTestServer(t, Config{NetworkTTL: time.Hour, NetworkTTLSnapshot: true})
+
+	tests := []struct {
+		name             string
+		md               metadata.MD
+		expectedTTL      time.Duration
+		expectedSnapshot bool
+		expectedErr      bool
+	}{
+		{
+			name:             "server config",
+			expectedTTL:      time.Hour,
+			expectedSnapshot: true,
+		},
+		{
+			name:             "request TTL",
+			md:               metadata.Pairs(constants.NetworkTTLMetadataKey, "2h"),
+			expectedTTL:      2 * time.Hour,
+			expectedSnapshot: true,
+		},
+		{
+			name: "request without TTL",
+			md: metadata.Pairs(
+				constants.NetworkTTLMetadataKey, "0s",
+				constants.NetworkTTLSnapshotMetadataKey, "false",
+			),
+		},
+		{
+			name:        "invalid TTL",
+			md:          metadata.Pairs(constants.NetworkTTLMetadataKey, "soon"),
+			expectedErr: true,
+		},
+		{
+			name:        "negative TTL",
+			md:          metadata.Pairs(constants.NetworkTTLMetadataKey, "-1h"),
+			expectedErr: true,
+		},
+		{
+			name:        "invalid snapshot",
+			md:          metadata.Pairs(constants.NetworkTTLSnapshotMetadataKey, "maybe"),
+			expectedErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require := require.New(t)
+			ctx := context.Background()
+			if tt.md != nil {
+				ctx = metadata.NewIncomingContext(ctx, tt.md)
+			}
+			ttl, snapshot, err := s.networkTTLFromContext(ctx)
+			if tt.expectedErr {
+				require.Error(err)
+				return
+			}
+			require.NoError(err)
+			require.Equal(tt.expectedTTL, ttl)
+			require.Equal(tt.expectedSnapshot, snapshot)
+		})
+	}
+}
+
+func TestNetworkTTLExpiry(t *testing.T) {
+	for _, snapshot := range []bool{false, true} {
+		snapshot := snapshot
+		name := "stop"
+		if snapshot {
+			name = "snapshot"
+		}
+		t.Run(name, func(t *testing.T) {
+			require := require.New(t)
+			s := newTestServer(t, Config{})
+			nw := newFakeNetwork("node1")
+			setTestNetwork(s, nw, "")
+
+			s.mu.Lock()
+			s.setNetworkTTL(50*time.Millisecond, snapshot)
+			s.mu.Unlock()
+			require.Eventually(func() bool {
+				s.mu.RLock()
+				defer s.mu.RUnlock()
+				return s.network == nil
+			}, 5*time.Second, 10*time.Millisecond)
+
+			require.True(nw.stopped)
+			require.Nil(s.ttl)
+			if snapshot {
+				require.Len(nw.snapshots, 1)
+				require.True(strings.HasPrefix(nw.snapshots[0], ttlSnapshotPrefix))
+			} else {
+				require.Empty(nw.snapshots)
+			}
+		})
+	}
+}
+
+func TestNetworkTTLReplaced(t *testing.T) {
+	require := require.New(t)
+	s := newTestServer(t, Config{})
+	nw := newFakeNetwork("node1")
+	setTestNetwork(s, nw, "")
+
+	s.mu.Lock()
+	s.setNetworkTTL(time.Hour, false)
+	expired := s.network
+	// a zero TTL removes it
+	s.setNetworkTTL(0, false)
+	require.Nil(s.ttl)
+	s.mu.Unlock()
+	s.expireNetwork(expired)
+	require.False(nw.stopped)
+
+	// the TTL of a network doesn't stop the next one
+	s.mu.Lock()
+	s.setNetworkTTL(time.Hour, false)
+	s.mu.Unlock()
+	nextNw := newFakeNetwork("node1")
+	setTestNetwork(s, nextNw, "")
+	s.expireNetwork(expired)
+	require.False(nw.stopped)
+	require.False(nextNw.stopped)
+	require.NotNil(s.network)
+}
+
+func TestHandleTTL(t *testing.T) {
+	s := newTestServer(t, Config{})
+
+	// returns the status and decoded TTL info of a request to the TTL route
+	request := func(method string, body string) (int, ttlInfo) {
+		w := httptest.NewRecorder()
+		s.handleTTL(w, httptest.NewRequest(method, ttlPath, strings.NewReader(body)))
+		info := ttlInfo{}
+		if w.Code == http.StatusOK {
+			require.NoError(t, json.Unmarshal(w.Body.Bytes(), &info))
+		}
+		return w.Code, info
+	}
+
+	status, _ := request(http.MethodGet, "")
+	require.Equal(t, http.StatusServiceUnavailable, status)
+
+	setTestNetwork(s, newFakeNetwork("node1"), "")
+	status, info := request(http.MethodGet, "")
+	require.Equal(t, http.StatusOK, status)
+	require.Equal(t, ttlInfo{}, info)
+
+	status, info = request(http.MethodPost, `{"ttl": "1h", "snapshot": true}`)
+	require.Equal(t, http.StatusOK, status)
+	require.Equal(t, "1h0m0s", info.TTL)
+	require.NotNil(t, info.Deadline)
+	require.InDelta(t, time.Hour.Seconds(), info.RemainingSeconds, 2)
+	require.True(t, info.Snapshot)
+
+	status, _ = request(http.MethodPost, `{"ttl": "-1h"}`)
+	require.Equal(t, http.StatusBadRequest, status)
+	status, _ = request(http.MethodPut, "")
+	require.Equal(t, http.StatusMethodNotAllowed, status)
+
+	status, info = request(http.MethodPost, `{}`)
+	require.Equal(t, http.StatusOK, status)
+	require.Equal(t, ttlInfo{}, info)
+	s.mu.Lock()
+	require.Nil(t, s.ttl)
+	s.mu.Unlock()
+}
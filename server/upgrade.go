@@ -0,0 +1,94 @@
+// Copyright (C) 2021-2024, Lux Partners Limited. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/luxdefi/netrunner/network"
+	"github.com/luxdefi/netrunner/network/node"
+	"go.uber.org/zap"
+	"golang.org/x/exp/maps"
+)
+
+const upgradeNodesPath = "/v1/control/upgradenodes"
+
+type upgradeNodesRequest struct {
+	BinaryPath string   `json:"binaryPath"`
+	PluginDir  string   `json:"pluginDir"`
+	NodeNames  []string `json:"nodeNames"`
+	// e.g. "5m"
+	NodeTimeout       string `json:"nodeTimeout"`
+	Interval          string `json:"interval"`
+	RollbackOnFailure bool   `json:"rollbackOnFailure"`
+}
+
+// POST {"binaryPath": "/path/to/luxd", "interval": "30s"} restarts the
+// nodes one at a time with the binary, waiting for each to be healthy,
+// returning the cluster info. See network.UpgradeOptions.
+func (s *server) handleUpgradeNodes(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !s.authenticateHTTP(w, r) {
+		return
+	}
+	req := upgradeNodesRequest{}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	opts := network.UpgradeOptions{
+		NodeNames:         req.NodeNames,
+		PluginDir:         req.PluginDir,
+		RollbackOnFailure: req.RollbackOnFailure,
+	}
+	for _, d := range []struct {
+		name  string
+		value string
+		dst   *time.Duration
+	}{
+		{"nodeTimeout", req.NodeTimeout, &opts.NodeTimeout},
+		{"interval", req.Interval, &opts.Interval},
+	} {
+		if d.value == "" {
+			continue
+		}
+		duration, err := node.ParseDuration(d.value)
+		if err != nil || duration < 0 {
+			http.Error(w, "invalid "+d.name+" "+d.value, http.StatusBadRequest)
+			return
+		}
+		*d.dst = duration
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.network == nil {
+		http.Error(w, ErrNotBootstrapped.Error(), http.StatusServiceUnavailable)
+		return
+	}
+	s.log.Debug("UpgradeNodes", zap.String("binary-path", req.BinaryPath), zap.Strings("node-names", req.NodeNames))
+
+	upgradeErr := s.network.nw.UpgradeNodes(r.Context(), req.BinaryPath, opts)
+	// nodes may have been upgraded before the failure
+	if err := s.network.UpdateNodeInfo(); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	s.clusterInfo.NodeNames = maps.Keys(s.network.nodeInfos)
+	sort.Strings(s.clusterInfo.NodeNames)
+	s.clusterInfo.NodeInfos = s.network.nodeInfos
+	if upgradeErr != nil {
+		http.Error(w, upgradeErr.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, s.clusterInfo)
+}
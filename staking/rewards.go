@@ -0,0 +1,228 @@
+// Copyright (C) 2021-2024, Lux Partners Limited. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package staking
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/luxdefi/netrunner/network"
+	"github.com/luxdefi/netrunner/network/node"
+	"github.com/luxdefi/node/api"
+	"github.com/luxdefi/node/ids"
+	"github.com/luxdefi/node/vms/components/lux"
+	"github.com/luxdefi/node/vms/platformvm"
+	"github.com/luxdefi/node/vms/platformvm/txs"
+	"github.com/luxdefi/node/vms/secp256k1fx"
+)
+
+var ErrNoRunningNode = errors.New("no running node")
+
+// Staker is a validation or delegation recorded while current,
+// so its reward can be verified once it ends
+type Staker struct {
+	TxID   ids.ID     `json:"txID"`
+	NodeID ids.NodeID `json:"nodeID"`
+	// Tx of the validation, for delegators
+	ValidatorTxID ids.ID    `json:"validatorTxID,omitempty"`
+	Weight        uint64    `json:"weight"`
+	EndTime       time.Time `json:"endTime"`
+	// Reward computed by the P-Chain with the reward formula when the staker was added
+	PotentialReward uint64 `json:"potentialReward"`
+	// Last uptime percentage of a validator seen on record
+	Uptime *float32 `json:"uptime,omitempty"`
+}
+
+// StakerReward is the reward received by a staker
+type StakerReward struct {
+	TxID     ids.ID `json:"txID"`
+	Expected uint64 `json:"expected"`
+	Received uint64 `json:"received"`
+}
+
+// RewardCheck compares the rewards minted for a validation, including
+// its delegations, with the potential rewards of its stakers. The
+// total is compared, as the split of the delegation fees between the
+// validator and its delegators depends on the node version.
+type RewardCheck struct {
+	NodeID        ids.NodeID     `json:"nodeID"`
+	ValidatorTxID ids.ID         `json:"validatorTxID"`
+	Expected      uint64         `json:"expected"`
+	Received      uint64         `json:"received"`
+	Stakers       []StakerReward `json:"stakers"`
+	// Empty if the rewards match
+	Mismatch string `json:"mismatch,omitempty"`
+}
+
+// RewardReport of the ended validations
+type RewardReport struct {
+	Checks []RewardCheck `json:"checks"`
+	// Validations still current, whose rewards aren't minted yet
+	Pending    []ids.ID `json:"pending"`
+	Mismatches int      `json:"mismatches"`
+}
+
+// RecordStakers returns the current validators and delegators of
+// [subnetID], to be given to VerifyRewards once their staking periods end
+// (e.g. with the short staking periods of network.StakingConfig).
+func RecordStakers(ctx context.Context, nw network.Network, subnetID ids.ID) ([]Staker, error) {
+	n, err := getRunningNode(nw)
+	if err != nil {
+		return nil, err
+	}
+	pClient := n.GetAPIClient().PChainAPI()
+	vdrs, err := pClient.GetCurrentValidators(ctx, subnetID, nil)
+	if err != nil {
+		return nil, err
+	}
+	nodeIDs := make([]ids.NodeID, len(vdrs))
+	for i, vdr := range vdrs {
+		nodeIDs[i] = vdr.NodeID
+	}
+	// delegators are only listed when the validators are given
+	vdrs, err = pClient.GetCurrentValidators(ctx, subnetID, nodeIDs)
+	if err != nil {
+		return nil, err
+	}
+	stakers := []Staker{}
+	for _, vdr := range vdrs {
+		stakers = append(stakers, Staker{
+			TxID:            vdr.TxID,
+			NodeID:          vdr.NodeID,
+			Weight:          vdr.Weight,
+			EndTime:         time.Unix(int64(vdr.EndTime), 0),
+			PotentialReward: derefUint64(vdr.PotentialReward),
+			Uptime:          vdr.Uptime,
+		})
+		for _, delegator := range vdr.Delegators {
+			stakers = append(stakers, Staker{
+				TxID:            delegator.TxID,
+				NodeID:          delegator.NodeID,
+				ValidatorTxID:   vdr.TxID,
+				Weight:          delegator.Weight,
+				EndTime:         time.Unix(int64(delegator.EndTime), 0),
+				PotentialReward: derefUint64(delegator.PotentialReward),
+			})
+		}
+	}
+	return stakers, nil
+}
+
+// VerifyRewards fetches the reward UTXOs of the [stakers] recorded with
+// RecordStakers and compares them, per validation, with their potential
+// rewards. Validations not ended yet are reported as pending.
+// A validator under the uptime requirement is not rewarded, which is
+// reported as a mismatch along with its recorded uptime.
+func VerifyRewards(ctx context.Context, nw network.Network, subnetID ids.ID, stakers []Staker) (*RewardReport, error) {
+	n, err := getRunningNode(nw)
+	if err != nil {
+		return nil, err
+	}
+	pClient := n.GetAPIClient().PChainAPI()
+	vdrs, err := pClient.GetCurrentValidators(ctx, subnetID, nil)
+	if err != nil {
+		return nil, err
+	}
+	current := map[ids.ID]struct{}{}
+	for _, vdr := range vdrs {
+		current[vdr.TxID] = struct{}{}
+	}
+
+	// validator tx ID --> its validator and delegators
+	validations := map[ids.ID][]Staker{}
+	validationIDs := []ids.ID{}
+	for _, staker := range stakers {
+		validationID := staker.ValidatorTxID
+		if validationID == ids.Empty {
+			validationID = staker.TxID
+		}
+		if _, ok := validations[validationID]; !ok {
+			validationIDs = append(validationIDs, validationID)
+		}
+		validations[validationID] = append(validations[validationID], staker)
+	}
+	sort.Slice(validationIDs, func(i, j int) bool {
+		return validationIDs[i].String() < validationIDs[j].String()
+	})
+
+	report := &RewardReport{
+		Checks:  []RewardCheck{},
+		Pending: []ids.ID{},
+	}
+	for _, validationID := range validationIDs {
+		if _, ok := current[validationID]; ok {
+			report.Pending = append(report.Pending, validationID)
+			continue
+		}
+		check := RewardCheck{ValidatorTxID: validationID}
+		var validator *Staker
+		for i, staker := range validations[validationID] {
+			if staker.TxID == validationID {
+				validator = &validations[validationID][i]
+			}
+			received, err := getReward(ctx, pClient, staker.TxID)
+			if err != nil {
+				return nil, fmt.Errorf("couldn't get reward UTXOs of staker tx %s: %w", staker.TxID, err)
+			}
+			check.NodeID = staker.NodeID
+			check.Expected += staker.PotentialReward
+			check.Received += received
+			check.Stakers = append(check.Stakers, StakerReward{
+				TxID:     staker.TxID,
+				Expected: staker.PotentialReward,
+				Received: received,
+			})
+		}
+		if check.Received != check.Expected {
+			check.Mismatch = fmt.Sprintf("expected %d, received %d", check.Expected, check.Received)
+			if check.Received == 0 && validator != nil && validator.Uptime != nil {
+				check.Mismatch += fmt.Sprintf(" (validator uptime on record: %.2f%%)", *validator.Uptime)
+			}
+			report.Mismatches++
+		}
+		report.Checks = append(report.Checks, check)
+	}
+	return report, nil
+}
+
+// Returns the sum of the reward UTXOs of the staker tx [txID]
+func getReward(ctx context.Context, pClient platformvm.Client, txID ids.ID) (uint64, error) {
+	utxosBytes, err := pClient.GetRewardUTXOs(ctx, &api.GetTxArgs{TxID: txID})
+	if err != nil {
+		return 0, err
+	}
+	var reward uint64
+	for _, utxoBytes := range utxosBytes {
+		utxo := &lux.UTXO{}
+		if _, err := txs.Codec.Unmarshal(utxoBytes, utxo); err != nil {
+			return 0, err
+		}
+		if out, ok := utxo.Out.(*secp256k1fx.TransferOutput); ok {
+			reward += out.Amount()
+		}
+	}
+	return reward, nil
+}
+
+// Returns the first running node of [nw] by name
+func getRunningNode(nw network.Network) (node.Node, error) {
+	nodes, err := nw.GetAllNodes()
+	if err != nil {
+		return nil, err
+	}
+	nodeNames := make([]string, 0, len(nodes))
+	for name, n := range nodes {
+		if !n.GetPaused() {
+			nodeNames = append(nodeNames, name)
+		}
+	}
+	if len(nodeNames) == 0 {
+		return nil, ErrNoRunningNode
+	}
+	sort.Strings(nodeNames)
+	return nodes[nodeNames[0]], nil
+}
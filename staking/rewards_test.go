@@ -0,0 +1,192 @@
+// Copyright (C) 2021-2024, Lux Partners Limited. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package staking
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/luxdefi/netrunner/api"
+	apimocks "github.com/luxdefi/netrunner/api/mocks"
+	"github.com/luxdefi/netrunner/network"
+	"github.com/luxdefi/netrunner/network/node"
+	nodeapi "github.com/luxdefi/node/api"
+	"github.com/luxdefi/node/ids"
+	"github.com/luxdefi/node/utils/rpc"
+	"github.com/luxdefi/node/vms/platformvm"
+	"github.com/stretchr/testify/require"
+)
+
+// fakePChain serves the current validators set by the test, with their
+// delegators only when the validators are given, and no reward UTXOs.
+// The methods not overridden panic.
+type fakePChain struct {
+	platformvm.Client
+	validators []platformvm.ClientPermissionlessValidator
+	rewardTxs  []ids.ID
+}
+
+func (c *fakePChain) GetCurrentValidators(_ context.Context, _ ids.ID, nodeIDs []ids.NodeID, _ ...rpc.Option) ([]platformvm.ClientPermissionlessValidator, error) {
+	vdrs := []platformvm.ClientPermissionlessValidator{}
+	for _, vdr := range c.validators {
+		if nodeIDs == nil {
+			vdr.Delegators = nil
+		}
+		vdrs = append(vdrs, vdr)
+	}
+	return vdrs, nil
+}
+
+func (c *fakePChain) GetRewardUTXOs(_ context.Context, args *nodeapi.GetTxArgs, _ ...rpc.Option) ([][]byte, error) {
+	c.rewardTxs = append(c.rewardTxs, args.TxID)
+	return nil, nil
+}
+
+// fakeNode serves the P-Chain API set by the test.
+// The methods not overridden panic.
+type fakeNode struct {
+	node.Node
+	paused    bool
+	apiClient *apimocks.Client
+}
+
+func (n *fakeNode) GetPaused() bool {
+	return n.paused
+}
+
+func (n *fakeNode) GetAPIClient() api.Client {
+	return n.apiClient
+}
+
+// fakeNetwork holds the nodes set by the test.
+// The methods not overridden panic.
+type fakeNetwork struct {
+	network.Network
+	nodes map[string]node.Node
+}
+
+func (nw *fakeNetwork) GetAllNodes() (map[string]node.Node, error) {
+	return nw.nodes, nil
+}
+
+func newRewardsNetwork(pChain *fakePChain) *fakeNetwork {
+	apiClient := &apimocks.Client{}
+	apiClient.On("PChainAPI").Return(pChain)
+	return &fakeNetwork{nodes: map[string]node.Node{
+		"node1": &fakeNode{paused: true},
+		"node2": &fakeNode{apiClient: apiClient},
+	}}
+}
+
+func TestRecordStakers(t *testing.T) {
+	require := require.New(t)
+
+	nodeID := ids.GenerateTestNodeID()
+	validatorTxID := ids.GenerateTestID()
+	delegatorTxID := ids.GenerateTestID()
+	validatorReward := uint64(1000)
+	delegatorReward := uint64(100)
+	uptime := float32(99.5)
+	pChain := &fakePChain{validators: []platformvm.ClientPermissionlessValidator{
+		{
+			ClientStaker: platformvm.ClientStaker{
+				TxID:    validatorTxID,
+				NodeID:  nodeID,
+				Weight:  2000,
+				EndTime: 2000,
+			},
+			PotentialReward: &validatorReward,
+			Uptime:          &uptime,
+			Delegators: []platformvm.ClientDelegator{
+				{
+					ClientStaker: platformvm.ClientStaker{
+						TxID:    delegatorTxID,
+						NodeID:  nodeID,
+						Weight:  25,
+						EndTime: 1500,
+					},
+					PotentialReward: &delegatorReward,
+				},
+			},
+		},
+	}}
+
+	stakers, err := RecordStakers(context.Background(), newRewardsNetwork(pChain), ids.Empty)
+	require.NoError(err)
+	require.Equal([]Staker{
+		{
+			TxID:            validatorTxID,
+			NodeID:          nodeID,
+			Weight:          2000,
+			EndTime:         time.Unix(2000, 0),
+			PotentialReward: validatorReward,
+			Uptime:          &uptime,
+		},
+		{
+			TxID:            delegatorTxID,
+			NodeID:          nodeID,
+			ValidatorTxID:   validatorTxID,
+			Weight:          25,
+			EndTime:         time.Unix(1500, 0),
+			PotentialReward: delegatorReward,
+		},
+	}, stakers)
+}
+
+func TestVerifyRewards(t *testing.T) {
+	require := require.New(t)
+
+	endedNodeID := ids.GenerateTestNodeID()
+	endedTxID := ids.GenerateTestID()
+	endedDelegatorTxID := ids.GenerateTestID()
+	unrewardedTxID := ids.GenerateTestID()
+	currentTxID := ids.GenerateTestID()
+	uptime := float32(62.5)
+	stakers := []Staker{
+		{TxID: endedTxID, NodeID: endedNodeID, PotentialReward: 1000, Uptime: &uptime},
+		{TxID: endedDelegatorTxID, NodeID: endedNodeID, ValidatorTxID: endedTxID, PotentialReward: 100},
+		{TxID: unrewardedTxID, NodeID: ids.GenerateTestNodeID()},
+		{TxID: currentTxID, NodeID: ids.GenerateTestNodeID(), PotentialReward: 1000},
+	}
+	pChain := &fakePChain{validators: []platformvm.ClientPermissionlessValidator{
+		{ClientStaker: platformvm.ClientStaker{TxID: currentTxID}},
+	}}
+
+	report, err := VerifyRewards(context.Background(), newRewardsNetwork(pChain), ids.Empty, stakers)
+	require.NoError(err)
+	require.Equal([]ids.ID{currentTxID}, report.Pending)
+	require.Equal(1, report.Mismatches)
+	require.Len(report.Checks, 2)
+	// the rewards of the current validation aren't fetched
+	require.ElementsMatch([]ids.ID{endedTxID, endedDelegatorTxID, unrewardedTxID}, pChain.rewardTxs)
+
+	checks := map[ids.ID]RewardCheck{}
+	for _, check := range report.Checks {
+		checks[check.ValidatorTxID] = check
+	}
+	ended := checks[endedTxID]
+	require.Equal(endedNodeID, ended.NodeID)
+	require.Equal(uint64(1100), ended.Expected)
+	require.Zero(ended.Received)
+	require.Equal([]StakerReward{
+		{TxID: endedTxID, Expected: 1000},
+		{TxID: endedDelegatorTxID, Expected: 100},
+	}, ended.Stakers)
+	require.Equal("expected 1100, received 0 (validator uptime on record: 62.50%)", ended.Mismatch)
+	require.Empty(checks[unrewardedTxID].Mismatch)
+}
+
+func TestGetRunningNode(t *testing.T) {
+	require := require.New(t)
+
+	nw := newRewardsNetwork(&fakePChain{})
+	n, err := getRunningNode(nw)
+	require.NoError(err)
+	require.Equal(nw.nodes["node2"], n)
+
+	nw.nodes["node2"].(*fakeNode).paused = true
+	_, err = getRunningNode(nw)
+	require.ErrorIs(err, ErrNoRunningNode)
+}
@@ -0,0 +1,281 @@
+// Copyright (C) 2021-2024, Lux Partners Limited. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+// Package staking inspects the validators of a network as seen by each of
+// its nodes, aggregating uptime, stake and delegation info and flagging
+// where the views of the nodes disagree.
+package staking
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/luxdefi/netrunner/network"
+	"github.com/luxdefi/node/ids"
+	"github.com/luxdefi/node/vms/platformvm"
+)
+
+// default max difference, in percentage points, between
+// the uptimes of a validator reported by the nodes
+const defaultUptimeTolerance = 10
+
+// Fields compared between the views of the nodes
+const (
+	FieldPresence        = "presence"
+	FieldWeight          = "weight"
+	FieldEndTime         = "endTime"
+	FieldDelegatorCount  = "delegatorCount"
+	FieldDelegatorWeight = "delegatorWeight"
+	FieldUptime          = "uptime"
+)
+
+// Delegator of a validator
+type Delegator struct {
+	TxID            ids.ID    `json:"txID"`
+	Weight          uint64    `json:"weight"`
+	StartTime       time.Time `json:"startTime"`
+	EndTime         time.Time `json:"endTime"`
+	PotentialReward uint64    `json:"potentialReward"`
+}
+
+// Validator aggregates the views of the nodes of a current validator.
+// Stake and delegation info is the one of the first node, by name,
+// that sees the validator.
+type Validator struct {
+	NodeID ids.NodeID `json:"nodeID"`
+	// Name of the validator in the network, empty if external
+	NodeName        string      `json:"nodeName,omitempty"`
+	TxID            ids.ID      `json:"txID"`
+	Weight          uint64      `json:"weight"`
+	StartTime       time.Time   `json:"startTime"`
+	EndTime         time.Time   `json:"endTime"`
+	DelegationFee   float32     `json:"delegationFee"`
+	PotentialReward uint64      `json:"potentialReward"`
+	DelegatorCount  uint64      `json:"delegatorCount"`
+	DelegatorWeight uint64      `json:"delegatorWeight"`
+	Delegators      []Delegator `json:"delegators,omitempty"`
+	// Observer node name --> uptime percentage of the validator it reports
+	Uptimes map[string]float32 `json:"uptimes"`
+	// Observer node name --> true if it is connected to the validator
+	Connected map[string]bool `json:"connected"`
+}
+
+// MinUptime returns the lowest uptime reported for the validator, 0 if none
+func (v Validator) MinUptime() float32 {
+	first := true
+	var minUptime float32
+	for _, uptime := range v.Uptimes {
+		if first || uptime < minUptime {
+			minUptime = uptime
+			first = false
+		}
+	}
+	return minUptime
+}
+
+// Discrepancy is a difference between the views of two nodes on a validator
+type Discrepancy struct {
+	NodeID ids.NodeID `json:"nodeID"`
+	// One of the Field constants
+	Field string `json:"field"`
+	// Node whose view is compared against, the first one by name
+	Reference      string `json:"reference"`
+	ReferenceValue string `json:"referenceValue"`
+	Observer       string `json:"observer"`
+	ObserverValue  string `json:"observerValue"`
+}
+
+// Report of the validators of a subnet
+type Report struct {
+	SubnetID ids.ID `json:"subnetID"`
+	// Running nodes whose view was inspected, by name
+	Observers []string `json:"observers"`
+	// Sorted by node ID
+	Validators    []Validator   `json:"validators"`
+	Discrepancies []Discrepancy `json:"discrepancies"`
+	// Node name --> error querying its view
+	Errors map[string]string `json:"errors,omitempty"`
+}
+
+// InspectConfig of a validators inspection
+type InspectConfig struct {
+	// Defaults to the primary network
+	SubnetID ids.ID
+	// Max difference, in percentage points, between the uptimes of a
+	// validator reported by the nodes. Defaults to 10.
+	UptimeTolerance float32
+}
+
+// Inspect queries the current validators of [cfg.SubnetID] from every
+// running node of [nw] and aggregates them. Nodes that fail to answer are
+// recorded in the report errors. Returns an error only if no node answers.
+func Inspect(ctx context.Context, nw network.Network, cfg InspectConfig) (*Report, error) {
+	if cfg.UptimeTolerance == 0 {
+		cfg.UptimeTolerance = defaultUptimeTolerance
+	}
+	nodes, err := nw.GetAllNodes()
+	if err != nil {
+		return nil, err
+	}
+	nodeNames := make(map[ids.NodeID]string, len(nodes))
+	views := map[string][]platformvm.ClientPermissionlessValidator{}
+	errs := map[string]string{}
+	for nodeName, node := range nodes {
+		nodeNames[node.GetNodeID()] = nodeName
+		if node.GetPaused() {
+			continue
+		}
+		vdrs, err := node.GetAPIClient().PChainAPI().GetCurrentValidators(ctx, cfg.SubnetID, nil)
+		if err != nil {
+			errs[nodeName] = err.Error()
+			continue
+		}
+		views[nodeName] = vdrs
+	}
+	if len(views) == 0 {
+		if len(errs) == 0 {
+			return nil, fmt.Errorf("no running node to get the validators of subnet %s from", cfg.SubnetID)
+		}
+		return nil, fmt.Errorf("couldn't get the validators of subnet %s from any node: %v", cfg.SubnetID, errs)
+	}
+	report := aggregate(views, nodeNames, cfg.UptimeTolerance)
+	report.SubnetID = cfg.SubnetID
+	if len(errs) > 0 {
+		report.Errors = errs
+	}
+	return report, nil
+}
+
+// Aggregates the validators seen by each observer node in [views],
+// comparing the view of each observer with the one of the first by name
+func aggregate(
+	views map[string][]platformvm.ClientPermissionlessValidator,
+	nodeNames map[ids.NodeID]string,
+	uptimeTolerance float32,
+) *Report {
+	observers := make([]string, 0, len(views))
+	for observer := range views {
+		observers = append(observers, observer)
+	}
+	sort.Strings(observers)
+
+	report := &Report{
+		Observers:     observers,
+		Validators:    []Validator{},
+		Discrepancies: []Discrepancy{},
+	}
+	// node ID --> observer --> view of the validator
+	byNodeID := map[ids.NodeID]map[string]platformvm.ClientPermissionlessValidator{}
+	for observer, vdrs := range views {
+		for _, vdr := range vdrs {
+			if _, ok := byNodeID[vdr.NodeID]; !ok {
+				byNodeID[vdr.NodeID] = map[string]platformvm.ClientPermissionlessValidator{}
+			}
+			byNodeID[vdr.NodeID][observer] = vdr
+		}
+	}
+	nodeIDs := make([]ids.NodeID, 0, len(byNodeID))
+	for nodeID := range byNodeID {
+		nodeIDs = append(nodeIDs, nodeID)
+	}
+	sort.Slice(nodeIDs, func(i, j int) bool {
+		return nodeIDs[i].String() < nodeIDs[j].String()
+	})
+
+	for _, nodeID := range nodeIDs {
+		observed := byNodeID[nodeID]
+		var (
+			reference string
+			ref       platformvm.ClientPermissionlessValidator
+		)
+		for _, observer := range observers {
+			if vdr, ok := observed[observer]; ok {
+				reference, ref = observer, vdr
+				break
+			}
+		}
+		validator := newValidator(ref)
+		validator.NodeName = nodeNames[nodeID]
+
+		addDiscrepancy := func(field string, observer string, refValue interface{}, value interface{}) {
+			report.Discrepancies = append(report.Discrepancies, Discrepancy{
+				NodeID:         nodeID,
+				Field:          field,
+				Reference:      reference,
+				ReferenceValue: fmt.Sprint(refValue),
+				Observer:       observer,
+				ObserverValue:  fmt.Sprint(value),
+			})
+		}
+		for _, observer := range observers {
+			vdr, ok := observed[observer]
+			if !ok {
+				addDiscrepancy(FieldPresence, observer, true, false)
+				continue
+			}
+			if vdr.Uptime != nil {
+				validator.Uptimes[observer] = *vdr.Uptime
+			}
+			if vdr.Connected != nil {
+				validator.Connected[observer] = *vdr.Connected
+			}
+			if vdr.Weight != ref.Weight {
+				addDiscrepancy(FieldWeight, observer, ref.Weight, vdr.Weight)
+			}
+			if vdr.EndTime != ref.EndTime {
+				addDiscrepancy(FieldEndTime, observer, time.Unix(int64(ref.EndTime), 0).UTC(), time.Unix(int64(vdr.EndTime), 0).UTC())
+			}
+			if count := derefUint64(vdr.DelegatorCount); count != validator.DelegatorCount {
+				addDiscrepancy(FieldDelegatorCount, observer, validator.DelegatorCount, count)
+			}
+			if weight := derefUint64(vdr.DelegatorWeight); weight != validator.DelegatorWeight {
+				addDiscrepancy(FieldDelegatorWeight, observer, validator.DelegatorWeight, weight)
+			}
+		}
+		if refUptime, ok := validator.Uptimes[reference]; ok {
+			for _, observer := range observers {
+				uptime, ok := validator.Uptimes[observer]
+				if ok && (uptime-refUptime > uptimeTolerance || refUptime-uptime > uptimeTolerance) {
+					addDiscrepancy(FieldUptime, observer, refUptime, uptime)
+				}
+			}
+		}
+		report.Validators = append(report.Validators, validator)
+	}
+	return report
+}
+
+func newValidator(vdr platformvm.ClientPermissionlessValidator) Validator {
+	validator := Validator{
+		NodeID:          vdr.NodeID,
+		TxID:            vdr.TxID,
+		Weight:          vdr.Weight,
+		StartTime:       time.Unix(int64(vdr.StartTime), 0).UTC(),
+		EndTime:         time.Unix(int64(vdr.EndTime), 0).UTC(),
+		DelegationFee:   vdr.DelegationFee,
+		PotentialReward: derefUint64(vdr.PotentialReward),
+		DelegatorCount:  derefUint64(vdr.DelegatorCount),
+		DelegatorWeight: derefUint64(vdr.DelegatorWeight),
+		Uptimes:         map[string]float32{},
+		Connected:       map[string]bool{},
+	}
+	for _, delegator := range vdr.Delegators {
+		validator.Delegators = append(validator.Delegators, Delegator{
+			TxID:            delegator.TxID,
+			Weight:          delegator.Weight,
+			StartTime:       time.Unix(int64(delegator.StartTime), 0).UTC(),
+			EndTime:         time.Unix(int64(delegator.EndTime), 0).UTC(),
+			PotentialReward: derefUint64(delegator.PotentialReward),
+		})
+	}
+	return validator
+}
+
+func derefUint64(v *uint64) uint64 {
+	if v == nil {
+		return 0
+	}
+	return *v
+}
@@ -0,0 +1,61 @@
+// Copyright (C) 2021-2024, Lux Partners Limited. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package staking
+
+import (
+	"testing"
+
+	"github.com/luxdefi/node/ids"
+	"github.com/luxdefi/node/vms/platformvm"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAggregate(t *testing.T) {
+	require := require.New(t)
+
+	nodeID1 := ids.GenerateTestNodeID()
+	nodeID2 := ids.GenerateTestNodeID()
+	newView := func(nodeID ids.NodeID, weight uint64, uptime float32, delegators uint64) platformvm.ClientPermissionlessValidator {
+		return platformvm.ClientPermissionlessValidator{
+			ClientStaker: platformvm.ClientStaker{
+				NodeID:  nodeID,
+				Weight:  weight,
+				EndTime: 2000,
+			},
+			Uptime:         &uptime,
+			DelegatorCount: &delegators,
+		}
+	}
+	views := map[string][]platformvm.ClientPermissionlessValidator{
+		"node1": {newView(nodeID1, 100, 99, 1), newView(nodeID2, 100, 98, 0)},
+		// disagrees on the delegators of node1 and on the uptime of node2
+		"node2": {newView(nodeID1, 100, 95, 2), newView(nodeID2, 100, 70, 0)},
+		// doesn't see node2
+		"node3": {newView(nodeID1, 100, 97, 1)},
+	}
+	nodeNames := map[ids.NodeID]string{nodeID1: "node1", nodeID2: "node2"}
+
+	report := aggregate(views, nodeNames, 10)
+	require.Equal([]string{"node1", "node2", "node3"}, report.Observers)
+	require.Len(report.Validators, 2)
+	for _, validator := range report.Validators {
+		require.Equal(nodeNames[validator.NodeID], validator.NodeName)
+		require.Equal(uint64(100), validator.Weight)
+	}
+
+	type key struct {
+		nodeID   ids.NodeID
+		field    string
+		observer string
+	}
+	found := map[key]Discrepancy{}
+	for _, d := range report.Discrepancies {
+		require.Equal("node1", d.Reference)
+		found[key{d.NodeID, d.Field, d.Observer}] = d
+	}
+	require.Len(found, 3)
+	require.Equal("2", found[key{nodeID1, FieldDelegatorCount, "node2"}].ObserverValue)
+	require.Equal("70", found[key{nodeID2, FieldUptime, "node2"}].ObserverValue)
+	require.Contains(found, key{nodeID2, FieldPresence, "node3"})
+}
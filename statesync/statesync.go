@@ -0,0 +1,190 @@
+// Copyright (C) 2021-2024, Lux Partners Limited. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+// Package statesync checks that a fresh node can bootstrap a running
+// network with state sync, so state sync regressions are caught locally.
+package statesync
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/big"
+	"sort"
+	"time"
+
+	"github.com/luxdefi/netrunner/network"
+	"github.com/luxdefi/netrunner/network/node"
+)
+
+const (
+	defaultNodeName     = "state-sync-node"
+	defaultTimeout      = 10 * time.Minute
+	defaultPollInterval = time.Second
+)
+
+var ErrNoReferenceNode = errors.New("no running node to compare the synced state with")
+
+// Config of a state sync check
+type Config struct {
+	// Name of the node to add. Defaults to "state-sync-node".
+	NodeName string
+	// Binary of the node to add. Defaults to the network one.
+	BinaryPath string
+	// Chain alias --> chain config of the added node, on top of the
+	// node.RoleAPI preset (e.g. to lower "state-sync-min-blocks")
+	ChainConfigFiles map[string]string
+	// Max time for the node to become healthy. Defaults to 10 minutes.
+	Timeout time.Duration
+	// Defaults to 1 second.
+	PollInterval time.Duration
+	// If true, the node is left running after the check
+	KeepNode bool
+}
+
+// Result of a state sync check
+type Result struct {
+	NodeName string `json:"nodeName"`
+	// Node used as source of truth
+	ReferenceNodeName string `json:"referenceNodeName"`
+	// Time from the node being added to it being healthy
+	SyncDuration time.Duration `json:"syncDuration"`
+	// Heights of the reference node when the node was added
+	TargetPHeight uint64 `json:"targetPHeight"`
+	TargetCHeight uint64 `json:"targetCHeight"`
+	// Heights of the node once healthy
+	SyncedPHeight uint64 `json:"syncedPHeight"`
+	SyncedCHeight uint64 `json:"syncedCHeight"`
+	// True if the node reached the target heights and its C-Chain
+	// block at the target height matches the reference one
+	Verified bool `json:"verified"`
+	// Reason of the verification failure
+	Failure string `json:"failure,omitempty"`
+}
+
+// Run adds a fresh node with state sync enabled to [nw], waits for it
+// to become healthy and verifies its chain state against a running node.
+// An error is returned if the check couldn't be run; a failed verification
+// is reported in the result instead.
+func Run(ctx context.Context, nw network.Network, cfg Config) (*Result, error) {
+	if cfg.NodeName == "" {
+		cfg.NodeName = defaultNodeName
+	}
+	if cfg.Timeout == 0 {
+		cfg.Timeout = defaultTimeout
+	}
+	if cfg.PollInterval == 0 {
+		cfg.PollInterval = defaultPollInterval
+	}
+
+	reference, err := referenceNode(nw)
+	if err != nil {
+		return nil, err
+	}
+	result := &Result{
+		NodeName:          cfg.NodeName,
+		ReferenceNodeName: reference.GetName(),
+	}
+	result.TargetPHeight, err = reference.GetAPIClient().PChainAPI().GetHeight(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't get P-Chain height of %q: %w", reference.GetName(), err)
+	}
+	result.TargetCHeight, err = reference.GetAPIClient().CChainEthAPI().BlockNumber(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't get C-Chain height of %q: %w", reference.GetName(), err)
+	}
+
+	start := time.Now()
+	syncNode, err := nw.AddNode(node.Config{
+		Name:             cfg.NodeName,
+		BinaryPath:       cfg.BinaryPath,
+		ChainConfigFiles: cfg.ChainConfigFiles,
+		Role:             node.RoleAPI,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("couldn't add node %q: %w", cfg.NodeName, err)
+	}
+	if !cfg.KeepNode {
+		defer func() {
+			_ = nw.RemoveNode(context.Background(), cfg.NodeName)
+		}()
+	}
+	if err := awaitHealthy(ctx, syncNode, cfg.Timeout, cfg.PollInterval); err != nil {
+		return nil, err
+	}
+	result.SyncDuration = time.Since(start)
+
+	if err := verify(ctx, reference, syncNode, result); err != nil {
+		result.Failure = err.Error()
+		return result, nil
+	}
+	result.Verified = true
+	return result, nil
+}
+
+// Returns the first running node of [nw] by name
+func referenceNode(nw network.Network) (node.Node, error) {
+	nodes, err := nw.GetAllNodes()
+	if err != nil {
+		return nil, err
+	}
+	nodeNames := make([]string, 0, len(nodes))
+	for nodeName, n := range nodes {
+		if !n.GetPaused() {
+			nodeNames = append(nodeNames, nodeName)
+		}
+	}
+	if len(nodeNames) == 0 {
+		return nil, ErrNoReferenceNode
+	}
+	sort.Strings(nodeNames)
+	return nodes[nodeNames[0]], nil
+}
+
+func awaitHealthy(ctx context.Context, n node.Node, timeout time.Duration, pollInterval time.Duration) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	for {
+		health, err := n.GetAPIClient().HealthAPI().Health(ctx, nil)
+		if err == nil && health.Healthy {
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("node %q failed to sync within %s", n.GetName(), timeout)
+		case <-time.After(pollInterval):
+		}
+	}
+}
+
+// Fills the synced heights of [result] and checks them against the targets
+func verify(ctx context.Context, reference node.Node, syncNode node.Node, result *Result) error {
+	var err error
+	result.SyncedPHeight, err = syncNode.GetAPIClient().PChainAPI().GetHeight(ctx)
+	if err != nil {
+		return fmt.Errorf("couldn't get P-Chain height: %w", err)
+	}
+	result.SyncedCHeight, err = syncNode.GetAPIClient().CChainEthAPI().BlockNumber(ctx)
+	if err != nil {
+		return fmt.Errorf("couldn't get C-Chain height: %w", err)
+	}
+	if result.SyncedPHeight < result.TargetPHeight {
+		return fmt.Errorf("P-Chain height %d is below target %d", result.SyncedPHeight, result.TargetPHeight)
+	}
+	if result.SyncedCHeight < result.TargetCHeight {
+		return fmt.Errorf("C-Chain height %d is below target %d", result.SyncedCHeight, result.TargetCHeight)
+	}
+	height := new(big.Int).SetUint64(result.TargetCHeight)
+	expected, err := reference.GetAPIClient().CChainEthAPI().HeaderByNumber(ctx, height)
+	if err != nil {
+		return fmt.Errorf("couldn't get C-Chain block %d of %q: %w", result.TargetCHeight, reference.GetName(), err)
+	}
+	synced, err := syncNode.GetAPIClient().CChainEthAPI().HeaderByNumber(ctx, height)
+	if err != nil {
+		return fmt.Errorf("couldn't get C-Chain block %d: %w", result.TargetCHeight, err)
+	}
+	if synced.Hash() != expected.Hash() {
+		return fmt.Errorf("C-Chain block %d hash %s doesn't match %s", result.TargetCHeight, synced.Hash(), expected.Hash())
+	}
+	return nil
+}
@@ -0,0 +1,192 @@
+// Copyright (C) 2021-2024, Lux Partners Limited. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package statesync
+
+import (
+	"context"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/luxdefi/coreth/core/types"
+	"github.com/luxdefi/netrunner/api"
+	apimocks "github.com/luxdefi/netrunner/api/mocks"
+	healthmocks "github.com/luxdefi/netrunner/local/mocks/health"
+	"github.com/luxdefi/netrunner/network"
+	"github.com/luxdefi/netrunner/network/node"
+	"github.com/luxdefi/node/api/health"
+	"github.com/luxdefi/node/utils/rpc"
+	"github.com/luxdefi/node/vms/platformvm"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+type fakePChain struct {
+	platformvm.Client
+	height uint64
+}
+
+func (c *fakePChain) GetHeight(context.Context, ...rpc.Option) (uint64, error) {
+	return c.height, nil
+}
+
+// fakeNode serves the heights, C-Chain header and health set by the test.
+// The methods not overridden panic.
+type fakeNode struct {
+	node.Node
+	name      string
+	paused    bool
+	apiClient *apimocks.Client
+}
+
+func newFakeNode(name string, pHeight uint64, cHeight uint64, header *types.Header, healthy bool) *fakeNode {
+	ethClient := &apimocks.EthClient{}
+	ethClient.On("BlockNumber", mock.Anything).Return(cHeight, nil)
+	ethClient.On("HeaderByNumber", mock.Anything, mock.Anything).Return(header, nil)
+	healthClient := &healthmocks.Client{}
+	healthClient.On("Health", mock.Anything, mock.Anything).Return(&health.APIReply{Healthy: healthy}, nil)
+	apiClient := &apimocks.Client{}
+	apiClient.On("PChainAPI").Return(&fakePChain{height: pHeight})
+	apiClient.On("CChainEthAPI").Return(ethClient)
+	apiClient.On("HealthAPI").Return(healthClient)
+	return &fakeNode{name: name, apiClient: apiClient}
+}
+
+func (n *fakeNode) GetName() string {
+	return n.name
+}
+
+func (n *fakeNode) GetPaused() bool {
+	return n.paused
+}
+
+func (n *fakeNode) GetAPIClient() api.Client {
+	return n.apiClient
+}
+
+// fakeNetwork adds [syncNode] on AddNode. The methods not overridden panic.
+type fakeNetwork struct {
+	network.Network
+	nodes    map[string]node.Node
+	syncNode *fakeNode
+	added    []node.Config
+	removed  []string
+}
+
+func (nw *fakeNetwork) GetAllNodes() (map[string]node.Node, error) {
+	return nw.nodes, nil
+}
+
+func (nw *fakeNetwork) AddNode(config node.Config) (node.Node, error) {
+	nw.added = append(nw.added, config)
+	nw.syncNode.name = config.Name
+	return nw.syncNode, nil
+}
+
+func (nw *fakeNetwork) RemoveNode(_ context.Context, nodeName string) error {
+	nw.removed = append(nw.removed, nodeName)
+	return nil
+}
+
+func TestRun(t *testing.T) {
+	targetHeader := &types.Header{Number: big.NewInt(5)}
+	otherHeader := &types.Header{Number: big.NewInt(5), Extra: []byte("fork")}
+
+	tests := []struct {
+		name            string
+		cfg             Config
+		syncNode        *fakeNode
+		expectedFailure string
+		expectedRemoved []string
+	}{
+		{
+			name:            "verified",
+			syncNode:        newFakeNode("", 10, 6, targetHeader, true),
+			expectedRemoved: []string{defaultNodeName},
+		},
+		{
+			name:            "node kept",
+			cfg:             Config{NodeName: "synced", KeepNode: true},
+			syncNode:        newFakeNode("", 10, 5, targetHeader, true),
+			expectedRemoved: nil,
+		},
+		{
+			name:            "P-Chain below target",
+			syncNode:        newFakeNode("", 9, 5, targetHeader, true),
+			expectedFailure: "P-Chain height 9 is below target 10",
+			expectedRemoved: []string{defaultNodeName},
+		},
+		{
+			name:            "C-Chain below target",
+			syncNode:        newFakeNode("", 10, 4, targetHeader, true),
+			expectedFailure: "C-Chain height 4 is below target 5",
+			expectedRemoved: []string{defaultNodeName},
+		},
+		{
+			name:            "C-Chain block mismatch",
+			syncNode:        newFakeNode("", 10, 5, otherHeader, true),
+			expectedFailure: "C-Chain block 5 hash",
+			expectedRemoved: []string{defaultNodeName},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			require := require.New(t)
+			nw := &fakeNetwork{
+				nodes: map[string]node.Node{
+					"node1": newFakeNode("node1", 10, 5, targetHeader, true),
+				},
+				syncNode: tt.syncNode,
+			}
+			result, err := Run(context.Background(), nw, tt.cfg)
+			require.NoError(err)
+			require.Len(nw.added, 1)
+			require.Equal(node.RoleAPI, nw.added[0].Role)
+			require.Equal(nw.added[0].Name, result.NodeName)
+			require.Equal("node1", result.ReferenceNodeName)
+			require.Equal(uint64(10), result.TargetPHeight)
+			require.Equal(uint64(5), result.TargetCHeight)
+			require.Equal(tt.expectedFailure == "", result.Verified)
+			require.Contains(result.Failure, tt.expectedFailure)
+			require.Equal(tt.expectedRemoved, nw.removed)
+		})
+	}
+}
+
+func TestRunNotHealthy(t *testing.T) {
+	require := require.New(t)
+
+	nw := &fakeNetwork{
+		nodes: map[string]node.Node{
+			"node1": newFakeNode("node1", 10, 5, &types.Header{}, true),
+		},
+		syncNode: newFakeNode("", 0, 0, &types.Header{}, false),
+	}
+	_, err := Run(context.Background(), nw, Config{
+		Timeout:      50 * time.Millisecond,
+		PollInterval: 10 * time.Millisecond,
+	})
+	require.ErrorContains(err, "failed to sync within 50ms")
+	// the node is removed all the same
+	require.Equal([]string{defaultNodeName}, nw.removed)
+}
+
+func TestReferenceNode(t *testing.T) {
+	require := require.New(t)
+
+	paused := newFakeNode("node1", 0, 0, nil, true)
+	paused.paused = true
+	nw := &fakeNetwork{nodes: map[string]node.Node{
+		"node1": paused,
+		"node3": newFakeNode("node3", 0, 0, nil, true),
+		"node2": newFakeNode("node2", 0, 0, nil, true),
+	}}
+	reference, err := referenceNode(nw)
+	require.NoError(err)
+	require.Equal("node2", reference.GetName())
+
+	nw.nodes = map[string]node.Node{"node1": paused}
+	_, err = referenceNode(nw)
+	require.ErrorIs(err, ErrNoReferenceNode)
+}
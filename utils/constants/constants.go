@@ -9,6 +9,19 @@ const (
 	LogNameControl = "control"
 	LogNameTest    = "test"
 	RootDirPrefix  = "network-runner-root-data"
+
+	// mDNS service type and domain netrunner servers are advertised under
+	MDNSService = "_netrunner._tcp"
+	MDNSDomain  = "local."
+	// TXT record keys of the advertised servers
+	MDNSGatewayPortKey = "gateway-port"
+	MDNSRPCVersionKey  = "rpc-version"
+	MDNSTenancyKey     = "tenancy"
+
+	// gRPC metadata keys of the network creation calls giving the TTL of
+	// the network (e.g. "2h"), and "true" to snapshot it once expired
+	NetworkTTLMetadataKey         = "netrunner-network-ttl"
+	NetworkTTLSnapshotMetadataKey = "netrunner-network-ttl-snapshot"
 )
 
 var (
@@ -0,0 +1,115 @@
+// Copyright (C) 2021-2024, Lux Partners Limited. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+// Package failpoint makes the server crash or stall at defined points of
+// its operations, to test that a restarted server handles the state left
+// behind: listing the networks with nodes left running as detached,
+// dropping the dead networks and cleaning up the orphaned dirs.
+package failpoint
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/exp/slices"
+)
+
+// Failure points
+const (
+	// The files of a node are written, and its process isn't started yet
+	AfterWriteFiles = "after-write-files"
+	// The node processes of a new network are started, and the
+	// network isn't healthy nor registered yet
+	BeforeHealthWait = "before-health-wait"
+	// The network is about to be stopped, with its nodes running
+	BeforeStop = "before-stop"
+)
+
+const (
+	// Env var with the failure points to enable, as given to Enable
+	EnvVar = "NETRUNNER_FAILPOINTS"
+	// Exit code of the process crashed at a failure point
+	CrashExitCode = 86
+
+	crashAction = "crash"
+	delayAction = "delay:"
+)
+
+var (
+	ErrInvalidSpec = errors.New("invalid failpoint spec")
+
+	points = []string{AfterWriteFiles, BeforeHealthWait, BeforeStop}
+
+	lock sync.RWMutex
+	// failure point --> its action
+	actions = map[string]action{}
+)
+
+// What a failure point does: crash the process, or else sleep [delay]
+type action struct {
+	crash bool
+	delay time.Duration
+}
+
+// Enable enables the failure points of [spec], replacing the enabled
+// ones. [spec] is a comma-separated list of <point>=<action>, where the
+// action is "crash" or "delay:<duration>" (e.g.
+// "after-write-files=crash,before-health-wait=delay:30s").
+// An empty [spec] disables all of them.
+func Enable(spec string) error {
+	enabled := map[string]action{}
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		point, actionStr, ok := strings.Cut(entry, "=")
+		if !ok {
+			return fmt.Errorf("%w %q: expected <point>=<action>", ErrInvalidSpec, entry)
+		}
+		if !slices.Contains(points, point) {
+			return fmt.Errorf("%w %q: unknown point %q, expected one of %s", ErrInvalidSpec, entry, point, strings.Join(points, ", "))
+		}
+		switch {
+		case actionStr == crashAction:
+			enabled[point] = action{crash: true}
+		case strings.HasPrefix(actionStr, delayAction):
+			delay, err := time.ParseDuration(strings.TrimPrefix(actionStr, delayAction))
+			if err != nil {
+				return fmt.Errorf("%w %q: %s", ErrInvalidSpec, entry, err)
+			}
+			enabled[point] = action{delay: delay}
+		default:
+			return fmt.Errorf("%w %q: unknown action %q, expected %q or %q", ErrInvalidSpec, entry, actionStr, crashAction, delayAction+"<duration>")
+		}
+	}
+
+	lock.Lock()
+	defer lock.Unlock()
+
+	actions = enabled
+	return nil
+}
+
+// Inject crashes the process, with [CrashExitCode] and without running
+// any cleanup, or stalls it, as enabled for [point]. Does nothing if
+// [point] isn't enabled.
+func Inject(point string) {
+	lock.RLock()
+	a, ok := actions[point]
+	lock.RUnlock()
+
+	if !ok {
+		return
+	}
+	if a.crash {
+		fmt.Fprintf(os.Stderr, "failpoint %s: crashing\n", point)
+		os.Exit(CrashExitCode)
+	}
+	fmt.Fprintf(os.Stderr, "failpoint %s: delaying %s\n", point, a.delay)
+	time.Sleep(a.delay)
+}
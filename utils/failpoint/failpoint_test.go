@@ -0,0 +1,55 @@
+// Copyright (C) 2021-2024, Lux Partners Limited. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package failpoint
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestEnable(t *testing.T) {
+	require := require.New(t)
+	defer func() {
+		require.NoError(Enable(""))
+	}()
+
+	require.NoError(Enable("after-write-files=crash, before-health-wait=delay:30s"))
+	require.Equal(map[string]action{
+		AfterWriteFiles:  {crash: true},
+		BeforeHealthWait: {delay: 30 * time.Second},
+	}, actions)
+
+	for _, spec := range []string{
+		"after-write-files",
+		"unknown=crash",
+		"before-stop=exit",
+		"before-stop=delay:soon",
+	} {
+		require.ErrorIs(Enable(spec), ErrInvalidSpec, spec)
+	}
+	// the enabled points are kept on error
+	require.Len(actions, 2)
+
+	require.NoError(Enable(""))
+	require.Empty(actions)
+}
+
+func TestInjectDelay(t *testing.T) {
+	require := require.New(t)
+	defer func() {
+		require.NoError(Enable(""))
+	}()
+
+	// not enabled
+	start := time.Now()
+	Inject(BeforeStop)
+	require.Less(time.Since(start), 50*time.Millisecond)
+
+	require.NoError(Enable("before-stop=delay:100ms"))
+	start = time.Now()
+	Inject(BeforeStop)
+	require.GreaterOrEqual(time.Since(start), 100*time.Millisecond)
+}
@@ -0,0 +1,108 @@
+// Copyright (C) 2021-2024, Lux Partners Limited. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package utils
+
+import (
+	"time"
+
+	"github.com/luxdefi/node/utils/logging"
+	"go.uber.org/zap"
+)
+
+// Keys of the fields shared by the runner log entries, so that
+// embedding applications can route and filter them
+const (
+	NetworkLogKey   = "network"
+	NodeLogKey      = "node"
+	OperationLogKey = "operation"
+	DurationLogKey  = "duration"
+)
+
+var _ logging.Logger = (*fieldsLogger)(nil)
+
+func NetworkField(networkName string) zap.Field {
+	return zap.String(NetworkLogKey, networkName)
+}
+
+func NodeField(nodeName string) zap.Field {
+	return zap.String(NodeLogKey, nodeName)
+}
+
+func OperationField(op string) zap.Field {
+	return zap.String(OperationLogKey, op)
+}
+
+func DurationField(d time.Duration) zap.Field {
+	return zap.Duration(DurationLogKey, d)
+}
+
+// StartOperation returns a func logging the end of [op] when called with
+// its error, if any, and the time elapsed since StartOperation was called.
+// Meant to be deferred on a named error result:
+//
+//	defer utils.StartOperation(log, "add-node")(&err)
+func StartOperation(log logging.Logger, op string, fields ...zap.Field) func(*error) {
+	start := time.Now()
+	return func(errPtr *error) {
+		allFields := make([]zap.Field, 0, len(fields)+3)
+		allFields = append(allFields, OperationField(op), DurationField(time.Since(start)))
+		allFields = append(allFields, fields...)
+		if errPtr != nil && *errPtr != nil {
+			log.Warn("operation failed", append(allFields, zap.Error(*errPtr))...)
+			return
+		}
+		log.Debug("operation completed", allFields...)
+	}
+}
+
+// fieldsLogger appends a fixed set of fields to every log entry
+type fieldsLogger struct {
+	logging.Logger
+	fields []zap.Field
+}
+
+// WithFields returns a logger that adds [fields] to every entry written to [log]
+func WithFields(log logging.Logger, fields ...zap.Field) logging.Logger {
+	if len(fields) == 0 {
+		return log
+	}
+	return &fieldsLogger{
+		Logger: log,
+		fields: fields,
+	}
+}
+
+func (l *fieldsLogger) Fatal(msg string, fields ...zap.Field) {
+	l.Logger.Fatal(msg, l.with(fields)...)
+}
+
+func (l *fieldsLogger) Error(msg string, fields ...zap.Field) {
+	l.Logger.Error(msg, l.with(fields)...)
+}
+
+func (l *fieldsLogger) Warn(msg string, fields ...zap.Field) {
+	l.Logger.Warn(msg, l.with(fields)...)
+}
+
+func (l *fieldsLogger) Info(msg string, fields ...zap.Field) {
+	l.Logger.Info(msg, l.with(fields)...)
+}
+
+func (l *fieldsLogger) Trace(msg string, fields ...zap.Field) {
+	l.Logger.Trace(msg, l.with(fields)...)
+}
+
+func (l *fieldsLogger) Debug(msg string, fields ...zap.Field) {
+	l.Logger.Debug(msg, l.with(fields)...)
+}
+
+func (l *fieldsLogger) Verbo(msg string, fields ...zap.Field) {
+	l.Logger.Verbo(msg, l.with(fields)...)
+}
+
+func (l *fieldsLogger) with(fields []zap.Field) []zap.Field {
+	allFields := make([]zap.Field, 0, len(l.fields)+len(fields))
+	allFields = append(allFields, l.fields...)
+	return append(allFields, fields...)
+}
@@ -0,0 +1,54 @@
+// Copyright (C) 2021-2024, Lux Partners Limited. All rights reserved.
+// See the file LICENSE for licensing terms.
+
+package utils
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/luxdefi/node/utils/logging"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// records the fields of the entries logged at debug and warn levels
+type recordingLogger struct {
+	logging.NoLog
+	debug [][]zap.Field
+	warn  [][]zap.Field
+}
+
+func (l *recordingLogger) Debug(_ string, fields ...zap.Field) {
+	l.debug = append(l.debug, fields)
+}
+
+func (l *recordingLogger) Warn(_ string, fields ...zap.Field) {
+	l.warn = append(l.warn, fields)
+}
+
+func fieldKeys(fields []zap.Field) []string {
+	keys := make([]string, 0, len(fields))
+	for _, field := range fields {
+		keys = append(keys, field.Key)
+	}
+	return keys
+}
+
+func TestStartOperation(t *testing.T) {
+	require := require.New(t)
+	log := &recordingLogger{}
+	nodeLog := WithFields(log, NetworkField("net1"))
+
+	var err error
+	StartOperation(nodeLog, "add-node", NodeField("node1"))(&err)
+	require.Len(log.debug, 1)
+	require.Equal([]string{NetworkLogKey, OperationLogKey, DurationLogKey, NodeLogKey}, fieldKeys(log.debug[0]))
+	require.Equal(zapcore.DurationType, log.debug[0][2].Type)
+
+	err = errors.New("failed")
+	StartOperation(nodeLog, "add-node", NodeField("node1"))(&err)
+	require.Len(log.warn, 1)
+	require.Equal([]string{NetworkLogKey, OperationLogKey, DurationLogKey, NodeLogKey, "error"}, fieldKeys(log.warn[0]))
+}
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"io/fs"
 	"os"
+	"path/filepath"
 	"time"
 
 	rpcb "github.com/luxdefi/netrunner/rpcpb"
@@ -93,6 +94,33 @@ func MkDirWithTimestamp(dirPrefix string) (string, error) {
 	return dirName, os.MkdirAll(dirName, os.ModePerm)
 }
 
+// DirSize returns the total size of the regular files under [dir]
+func DirSize(dir string) (uint64, error) {
+	var size uint64
+	err := filepath.WalkDir(dir, func(_ string, d fs.DirEntry, err error) error {
+		if err != nil {
+			// files may be removed by the nodes while walking
+			if errors.Is(err, fs.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+		size += uint64(info.Size())
+		return nil
+	})
+	return size, err
+}
+
 func VerifySubnetHasCorrectParticipants(
 	log logging.Logger,
 	subnetParticipants []string,